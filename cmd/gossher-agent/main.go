@@ -0,0 +1,28 @@
+// Command gossher-agent relays commands and file transfers to hosts of a network
+// segment that gossher cannot reach directly. Install it on a relay host in the
+// segment and set relay_host_id on the hosts behind it; gossher starts the agent
+// over SSH and speaks the relay protocol on its stdin and stdout (see package
+// relay). The agent reaches the hosts with the relay host's ssh client.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"gossher/internal/relay"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	agent := &relay.Agent{Dialer: relay.CommandDialer{}}
+	if err := agent.Serve(ctx, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "gossher-agent: %v\n", err)
+		stop()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,99 @@
+// Command gossher-runner is a headless exec server. Deploy it in a network zone
+// with an inventory of the zone's hosts; it only runs jobs signed by the issuers
+// in its keyring and only on hosts within its scope (see package runner). Hosts
+// are reached with the native SSH client (transport.SSHDialer), which checks the
+// host keys pinned in the inventory, or the user's known_hosts.
+//
+// Serve it behind a TLS-terminating proxy, or with -cert and -key.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gossher/internal/audit"
+	"gossher/internal/executor"
+	"gossher/internal/inventory"
+	"gossher/internal/runner"
+	"gossher/internal/transport"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "gossher-runner: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	listen := flag.String("listen", "127.0.0.1:8443", "address to listen on")
+	dataDir := flag.String("data-dir", "", "inventory data directory (default from config.yaml)")
+	keyring := flag.String("keyring", "", "file of trusted issuers: one \"name base64-ed25519-key\" per line")
+	scope := flag.String("scope", "", "target spec of the hosts jobs may run on (default all)")
+	certFile := flag.String("cert", "", "TLS certificate file")
+	keyFile := flag.String("key", "", "TLS key file")
+	flag.Parse()
+
+	if *keyring == "" {
+		return errors.New("-keyring is required")
+	}
+	keys, err := runner.LoadKeyring(*keyring)
+	if err != nil {
+		return err
+	}
+
+	dir := *dataDir
+	if dir == "" {
+		if err := inventory.Load(); err != nil {
+			return err
+		}
+		dir = inventory.GetDataDir()
+	}
+	m := inventory.NewManager(dir)
+	if err := m.Load(); err != nil {
+		return err
+	}
+	if *scope != "" {
+		if _, err := m.ResolveTargetSpec(*scope); err != nil {
+			return fmt.Errorf("invalid -scope: %w", err)
+		}
+	}
+
+	srv := &http.Server{
+		Addr: *listen,
+		Handler: &runner.Server{
+			Manager:  m,
+			Executor: executor.NewWithDialer(m, transport.SSHDialer{}),
+			Keys:     keys,
+			Scope:    *scope,
+			Audit:    audit.OpenInDir(dir),
+		},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+
+	if *certFile != "" {
+		err = srv.ListenAndServeTLS(*certFile, *keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gossher/internal/events"
+	"gossher/internal/redact"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog(t *testing.T) {
+	dir := t.TempDir()
+	log := OpenInDir(dir)
+
+	events, err := log.Events()
+	require.NoError(t, err)
+	assert.Empty(t, events, "missing log is empty")
+
+	redact.Add("hunter22")
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, log.Record(Event{Time: at, Actor: "alice", Action: "exec", Target: "web01",
+		Details: map[string]string{"command": "echo hunter22 | sudo -S true"}}))
+	require.NoError(t, log.Record(Event{Action: "login"}))
+	assert.Error(t, log.Record(Event{}))
+
+	events, err = log.Events()
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, at, events[0].Time)
+	assert.Equal(t, "echo [REDACTED] | sudo -S true", events[0].Details["command"])
+	assert.NotEmpty(t, events[1].Actor)
+	assert.False(t, events[1].Time.IsZero())
+
+	info, err := os.Stat(filepath.Join(dir, DefaultFilename))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestSubscribe(t *testing.T) {
+	log := OpenInDir(t.TempDir())
+	bus := events.NewBus()
+	unsubscribe := log.Subscribe(bus)
+
+	bus.Publish(events.Event{Type: events.ExecStarted, HostID: "web01", Command: "uptime"})
+	bus.Publish(events.Event{Type: events.ExecFinished, HostID: "web01", Command: "uptime", ExitCode: 2, Duration: time.Second})
+	bus.Publish(events.Event{Type: events.AuthFailed, HostID: "db01", Error: "permission denied"})
+	unsubscribe()
+	bus.Publish(events.Event{Type: events.AuthFailed, HostID: "db02"})
+
+	recorded, err := log.Events()
+	require.NoError(t, err)
+	require.Len(t, recorded, 2, "exec starts are not recorded by default")
+
+	assert.Equal(t, "event.exec_finished", recorded[0].Action)
+	assert.Equal(t, "web01", recorded[0].Target)
+	assert.Equal(t, map[string]string{"command": "uptime", "exit_code": "2", "duration": "1s"}, recorded[0].Details)
+	assert.Equal(t, "event.auth_failed", recorded[1].Action)
+	assert.Equal(t, "permission denied", recorded[1].Details["error"])
+
+	t.Run("selected types", func(t *testing.T) {
+		log := OpenInDir(t.TempDir())
+		bus := events.NewBus()
+		log.Subscribe(bus, events.HostStatusChanged)
+
+		bus.Publish(events.Event{Type: events.AuthFailed, HostID: "db01"})
+		bus.Publish(events.Event{Type: events.HostStatusChanged, HostID: "db01", Status: "Offline", PreviousStatus: "Online"})
+
+		recorded, err := log.Events()
+		require.NoError(t, err)
+		require.Len(t, recorded, 1)
+		assert.Equal(t, map[string]string{"status": "Offline", "previous_status": "Online"}, recorded[0].Details)
+	})
+}
@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"strconv"
+
+	"gossher/internal/events"
+)
+
+// DefaultEventTypes are the bus events recorded by Subscribe when no types are given.
+var DefaultEventTypes = []events.Type{
+	events.Connected,
+	events.Disconnected,
+	events.AuthFailed,
+	events.ExecFinished,
+}
+
+// Subscribe records events published on the bus, DefaultEventTypes unless types are
+// given, as "event.<type>" actions targeting the host. Write errors are dropped so
+// a failing log never blocks publishers. The returned function stops recording.
+func (l *Log) Subscribe(bus *events.Bus, types ...events.Type) (unsubscribe func()) {
+	if len(types) == 0 {
+		types = DefaultEventTypes
+	}
+	return bus.Subscribe(func(e events.Event) {
+		_ = l.Record(eventRecord(e))
+	}, types...)
+}
+
+// eventRecord converts a bus event to an audit event.
+func eventRecord(e events.Event) Event {
+	details := map[string]string{}
+	if e.Command != "" {
+		details["command"] = e.Command
+	}
+	if e.Type == events.ExecFinished {
+		details["exit_code"] = strconv.Itoa(e.ExitCode)
+		details["duration"] = e.Duration.String()
+	}
+	if e.Error != "" {
+		details["error"] = e.Error
+	}
+	if e.Status != "" {
+		details["status"] = e.Status
+		details["previous_status"] = e.PreviousStatus
+	}
+	if len(details) == 0 {
+		details = nil
+	}
+
+	return Event{
+		Time:    e.Time,
+		Action:  "event." + string(e.Type),
+		Target:  e.HostID,
+		Details: details,
+	}
+}
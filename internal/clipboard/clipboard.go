@@ -0,0 +1,125 @@
+// Package clipboard copies text, such as connection strings, to the system
+// clipboard with the platform's clipboard tool: pbcopy, wl-copy, xclip, xsel or
+// clip.exe.
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"gossher/internal/inventory"
+)
+
+// ErrUnavailable is returned when no clipboard tool is installed.
+var ErrUnavailable = errors.New("no clipboard tool available")
+
+// Replaced in tests.
+var (
+	lookPath = exec.LookPath
+	command  = exec.Command
+)
+
+// waitDelay bounds the wait for the output of a tool after it exited: xclip and
+// wl-copy fork a child that keeps serving the selection with the inherited
+// stderr open.
+var waitDelay = time.Second
+
+// maxStderr is the length of tool output kept for error messages.
+const maxStderr = 4096
+
+// tool is an external command that reads clipboard content from stdin.
+type tool struct {
+	name string
+	args []string
+}
+
+// candidates returns the clipboard tools to try for the current platform, in order of preference.
+func candidates() []tool {
+	switch runtime.GOOS {
+	case "darwin":
+		return []tool{{name: "pbcopy"}}
+	case "windows":
+		return []tool{{name: "clip.exe"}}
+	}
+
+	var tools []tool
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		tools = append(tools, tool{name: "wl-copy"})
+	}
+	tools = append(tools,
+		tool{name: "xclip", args: []string{"-selection", "clipboard"}},
+		tool{name: "xsel", args: []string{"--clipboard", "--input"}},
+		// WSL exposes the Windows clipboard
+		tool{name: "clip.exe"},
+	)
+	return tools
+}
+
+// Available reports whether a clipboard tool can be found.
+func Available() bool {
+	_, err := find()
+	return err == nil
+}
+
+func find() (tool, error) {
+	for _, t := range candidates() {
+		if _, err := lookPath(t.name); err == nil {
+			return t, nil
+		}
+	}
+	return tool{}, ErrUnavailable
+}
+
+// Copy places text on the system clipboard.
+func Copy(text string) error {
+	t, err := find()
+	if err != nil {
+		return err
+	}
+
+	stderr := &limitedBuffer{limit: maxStderr}
+	cmd := command(t.name, t.args...)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stderr = stderr
+	cmd.WaitDelay = waitDelay
+	if err := cmd.Run(); err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+		return fmt.Errorf("failed to copy with %s: %w: %s", t.name, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it.
+type limitedBuffer struct {
+	buf   []byte
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - len(b.buf); room > 0 {
+		b.buf = append(b.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	return string(b.buf)
+}
+
+// CopyConnection renders a host's connection string (ssh command, scp template or
+// address:port) from its resolved credential and jump hosts and copies it.
+// The copied text is returned so callers can echo it.
+func CopyConnection(m *inventory.Manager, hostID string, format inventory.ConnectionFormat) (string, error) {
+	text, err := m.ConnectionString(hostID, format)
+	if err != nil {
+		return "", err
+	}
+	if err := Copy(text); err != nil {
+		return text, err
+	}
+	return text, nil
+}
@@ -0,0 +1,86 @@
+package clipboard
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubTools makes only the named tools available and runs script with sh instead
+// of them; the tool name and arguments are in "$@".
+func stubTools(t *testing.T, script string, names ...string) {
+	oldLookPath, oldCommand, oldDelay := lookPath, command, waitDelay
+	t.Cleanup(func() { lookPath, command, waitDelay = oldLookPath, oldCommand, oldDelay })
+
+	lookPath = func(name string) (string, error) {
+		for _, n := range names {
+			if n == name {
+				return "/usr/bin/" + name, nil
+			}
+		}
+		return "", exec.ErrNotFound
+	}
+	command = func(name string, args ...string) *exec.Cmd {
+		return exec.Command("sh", append([]string{"-c", script, name}, args...)...)
+	}
+	waitDelay = 50 * time.Millisecond
+}
+
+func TestCopy(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("tests the Linux clipboard tools")
+	}
+	t.Setenv("WAYLAND_DISPLAY", "")
+	out := filepath.Join(t.TempDir(), "clipboard")
+
+	t.Run("uses the first available tool", func(t *testing.T) {
+		stubTools(t, `echo "$@" > `+out+`.args; cat > `+out, "xsel", "clip.exe")
+		require.True(t, Available())
+		require.NoError(t, Copy("ssh deploy@web01"))
+
+		data, err := os.ReadFile(out)
+		require.NoError(t, err)
+		assert.Equal(t, "ssh deploy@web01", string(data))
+		args, err := os.ReadFile(out + ".args")
+		require.NoError(t, err)
+		assert.Equal(t, "--clipboard --input\n", string(args))
+	})
+
+	t.Run("does not wait for forked children", func(t *testing.T) {
+		// Like xclip, which keeps serving the selection in the background.
+		stubTools(t, `cat > `+out+`; sleep 5 &`, "xclip")
+		start := time.Now()
+		require.NoError(t, Copy("10.0.0.1:22"))
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("reports the output of failing tools", func(t *testing.T) {
+		stubTools(t, `echo "Error: Can't open display: (null)" >&2; exit 1`, "xclip")
+		err := Copy("text")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to copy with xclip")
+		assert.Contains(t, err.Error(), "Can't open display")
+	})
+
+	t.Run("without a tool", func(t *testing.T) {
+		stubTools(t, "true")
+		assert.False(t, Available())
+		assert.ErrorIs(t, Copy("text"), ErrUnavailable)
+	})
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{limit: 4}
+	n, err := b.Write([]byte("abc"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	n, _ = b.Write([]byte("defg"))
+	assert.Equal(t, 4, n, "writes never fail")
+	assert.Equal(t, "abcd", b.String())
+}
@@ -0,0 +1,238 @@
+// Package crash turns panics into bug report bundles. A Reporter deferred at the
+// top of main and of long-running goroutines writes a zip file with the panic and
+// its stack, the configuration with secrets masked, the recent log lines and audit
+// events, and the environment, then lets the panic continue. Every part is redacted
+// and the inventory itself, with its credentials, is never included.
+package crash
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"gossher/internal/audit"
+	"gossher/internal/inventory"
+	"gossher/internal/redact"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultDir is the directory of the data directory crash bundles are written to.
+const DefaultDir = "crash-reports"
+
+// DefaultLogLines is the number of lines a LogTail keeps.
+const DefaultLogLines = 200
+
+// auditEvents is the number of recent audit events included.
+const auditEvents = 50
+
+// ===== Log tail =====
+
+// LogTail keeps the last lines written to it. Tee the application's log output
+// through it so a crash bundle shows what happened before the panic.
+type LogTail struct {
+	mu      sync.Mutex
+	max     int
+	lines   []string
+	partial string
+}
+
+// NewLogTail creates a LogTail keeping n lines (DefaultLogLines if n <= 0).
+func NewLogTail(n int) *LogTail {
+	if n <= 0 {
+		n = DefaultLogLines
+	}
+	return &LogTail{max: n}
+}
+
+// Write implements io.Writer.
+func (t *LogTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	text := t.partial + string(p)
+	lines := strings.Split(text, "\n")
+	t.partial = lines[len(lines)-1]
+	t.lines = append(t.lines, lines[:len(lines)-1]...)
+	if over := len(t.lines) - t.max; over > 0 {
+		t.lines = append(t.lines[:0:0], t.lines[over:]...)
+	}
+	return len(p), nil
+}
+
+// Lines returns the kept lines, oldest first, including an unterminated last line.
+func (t *LogTail) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lines := append([]string(nil), t.lines...)
+	if t.partial != "" {
+		lines = append(lines, t.partial)
+	}
+	return lines
+}
+
+// ===== Reporter =====
+
+// Reporter writes crash bundles.
+type Reporter struct {
+	// Dir receives the bundles, e.g. filepath.Join(dataDir, DefaultDir).
+	Dir string
+	// Log, Audit and Manager are optional. The Manager is only used to learn the
+	// secrets of its credentials and hosts so they are redacted.
+	Log     *LogTail
+	Audit   *audit.Log
+	Manager *inventory.Manager
+	// Stderr receives the path of the written bundle (default os.Stderr).
+	Stderr io.Writer
+}
+
+// Recover writes a bundle for a panic and panics again, so the program still ends
+// with the usual stack trace. Defer it:
+//
+//	defer reporter.Recover()
+func (r *Reporter) Recover() {
+	value := recover()
+	if value == nil {
+		return
+	}
+
+	stderr := r.Stderr
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	if path, err := r.Write(value, debug.Stack()); err != nil {
+		fmt.Fprintf(stderr, "gossher crashed and the crash report could not be written: %v\n", err)
+	} else {
+		fmt.Fprintf(stderr, "gossher crashed; please attach %s to a bug report\n", path)
+	}
+	panic(value)
+}
+
+// Write writes a bundle for a panic value and its stack and returns its path.
+func (r *Reporter) Write(value any, stack []byte) (string, error) {
+	if err := os.MkdirAll(r.Dir, inventory.DefaultDirMode); err != nil {
+		return "", fmt.Errorf("failed to create crash report directory: %w", err)
+	}
+
+	now := time.Now()
+	path := filepath.Join(r.Dir, fmt.Sprintf("crash-%s.zip", now.UTC().Format("20060102-150405.000000000")))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create crash report: %w", err)
+	}
+
+	red := r.redactor()
+	zw := zip.NewWriter(f)
+	add := func(name, content string) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, redact.String(red.String(content)))
+		return err
+	}
+
+	type part struct{ name, content string }
+	parts := []part{
+		{"panic.txt", fmt.Sprintf("panic: %v\n\n%s", value, stack)},
+		{"environment.txt", environment(now)},
+		{"config.yaml", sanitizedConfig()},
+	}
+	if r.Log != nil {
+		parts = append(parts, part{"log.txt", strings.Join(r.Log.Lines(), "\n") + "\n"})
+	}
+	if r.Audit != nil {
+		parts = append(parts, part{"audit.log", auditTail(r.Audit)})
+	}
+
+	for _, p := range parts {
+		if err = add(p.name, p.content); err != nil {
+			break
+		}
+	}
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write crash report: %w", err)
+	}
+	return path, nil
+}
+
+// redactor returns a redactor knowing the secrets of the manager's credentials and
+// hosts; the secrets registered with the default redactor are removed separately.
+func (r *Reporter) redactor() *redact.Redactor {
+	red := redact.New()
+	if r.Manager == nil {
+		return red
+	}
+	for _, c := range r.Manager.ListCredentials() {
+		red.AddCredential(c)
+	}
+	for _, h := range r.Manager.ListHosts() {
+		if conn, err := r.Manager.ResolveConnection(h.ID); err == nil {
+			red.AddConnection(conn)
+		}
+	}
+	return red
+}
+
+// environment describes the process.
+func environment(now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "time: %s\n", now.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "os: %s\narch: %s\ngo: %s\n", runtime.GOOS, runtime.GOARCH, runtime.Version())
+	fmt.Fprintf(&b, "goroutines: %d\n", runtime.NumGoroutine())
+	if info, ok := debug.ReadBuildInfo(); ok {
+		fmt.Fprintf(&b, "module: %s %s\n", info.Main.Path, info.Main.Version)
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" || s.Key == "vcs.modified" {
+				fmt.Fprintf(&b, "%s: %s\n", s.Key, s.Value)
+			}
+		}
+	}
+	return b.String()
+}
+
+// sanitizedConfig renders the loaded configuration with secrets masked. A crash
+// before the configuration was loaded is noted instead.
+func sanitizedConfig() (out string) {
+	defer func() {
+		if recover() != nil {
+			out = "# config not loaded\n"
+		}
+	}()
+	data, err := yaml.Marshal(inventory.SanitizedConfig())
+	if err != nil {
+		return fmt.Sprintf("# failed to marshal config: %v\n", err)
+	}
+	return string(data)
+}
+
+// auditTail renders the most recent audit events, one per line, without details.
+func auditTail(l *audit.Log) string {
+	events, err := l.Events()
+	if err != nil {
+		return fmt.Sprintf("# failed to read audit log: %v\n", err)
+	}
+	if len(events) > auditEvents {
+		events = events[len(events)-auditEvents:]
+	}
+	var b strings.Builder
+	for _, e := range events {
+		fmt.Fprintf(&b, "%s %s %s %s\n", e.Time.UTC().Format(time.RFC3339), e.Actor, e.Action, e.Target)
+	}
+	return b.String()
+}
@@ -0,0 +1,98 @@
+package crash
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"gossher/internal/audit"
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readBundle returns the files of a crash bundle by name.
+func readBundle(t *testing.T, path string) map[string]string {
+	zr, err := zip.OpenReader(path)
+	require.NoError(t, err)
+	defer zr.Close()
+
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		files[f.Name] = string(data)
+	}
+	return files
+}
+
+func TestLogTail(t *testing.T) {
+	tail := NewLogTail(2)
+	fmt.Fprint(tail, "one\ntwo\nthr")
+	fmt.Fprint(tail, "ee\nfour")
+	assert.Equal(t, []string{"two", "three", "four"}, tail.Lines())
+
+	fmt.Fprint(tail, "\n")
+	assert.Equal(t, []string{"three", "four"}, tail.Lines())
+}
+
+func TestReporter(t *testing.T) {
+	setup := func(t *testing.T) *Reporter {
+		m := inventory.NewManager(t.TempDir())
+		require.NoError(t, m.Load())
+		cred := inventory.NewCredential("admin", "admin", "root")
+		cred.Password = "correct-horse-battery"
+		require.NoError(t, m.AddCredential(cred))
+
+		log := audit.Open(t.TempDir() + "/audit.log")
+		require.NoError(t, log.Record(audit.Event{Action: "exec", Target: "web01"}))
+
+		tail := NewLogTail(0)
+		fmt.Fprintln(tail, "connecting to web01 with correct-horse-battery")
+
+		return &Reporter{Dir: t.TempDir(), Log: tail, Audit: log, Manager: m, Stderr: io.Discard}
+	}
+
+	t.Run("writes a redacted bundle", func(t *testing.T) {
+		r := setup(t)
+		path, err := r.Write("boom: password=correct-horse-battery", []byte("goroutine 1 [running]:\nmain.main()\n"))
+		require.NoError(t, err)
+
+		files := readBundle(t, path)
+		assert.Contains(t, files["panic.txt"], "boom")
+		assert.Contains(t, files["panic.txt"], "main.main()")
+		assert.Contains(t, files["log.txt"], "connecting to web01")
+		assert.Contains(t, files["audit.log"], "exec web01")
+		assert.Contains(t, files["environment.txt"], "go: go")
+		assert.Contains(t, files["config.yaml"], "config not loaded")
+		for name, content := range files {
+			assert.NotContains(t, content, "correct-horse-battery", name)
+		}
+	})
+
+	t.Run("recovers, reports and panics again", func(t *testing.T) {
+		r := setup(t)
+		var stderr bytes.Buffer
+		r.Stderr = &stderr
+
+		assert.PanicsWithValue(t, "boom", func() {
+			defer r.Recover()
+			panic("boom")
+		})
+		assert.Contains(t, stderr.String(), "please attach")
+		assert.Contains(t, stderr.String(), r.Dir)
+	})
+
+	t.Run("does nothing without a panic", func(t *testing.T) {
+		r := setup(t)
+		assert.NotPanics(t, func() {
+			defer r.Recover()
+		})
+	})
+}
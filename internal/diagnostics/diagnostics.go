@@ -0,0 +1,384 @@
+// Package diagnostics checks the environment gossher runs in, beyond what
+// inventory.Manager.Doctor checks in the configuration and the inventory: the tools
+// it shells out to, the ssh-agent, the password manager CLIs, the modes of private
+// keys, the clocks of the hosts and the reachability of the bastions. The result is
+// a Report with secrets redacted that can be attached to bug reports.
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gossher/internal/executor"
+	"gossher/internal/inventory"
+	"gossher/internal/redact"
+	"gossher/internal/secrets"
+	"gossher/internal/transport"
+)
+
+// Check names, in addition to the inventory.Doctor* checks.
+const (
+	CheckBinary         = "binary"
+	CheckAgent          = "ssh-agent"
+	CheckKeychain       = "keychain"
+	CheckKeyPermissions = "key-permissions"
+	CheckClockSkew      = "clock-skew"
+	CheckBastion        = "bastion"
+)
+
+const (
+	// DefaultMaxSkew is the clock difference above which a host is reported.
+	DefaultMaxSkew = 5 * time.Second
+	// DefaultDialTimeout limits each bastion reachability check.
+	DefaultDialTimeout = 5 * time.Second
+)
+
+// clockCommand prints the host's time in seconds since the epoch.
+const clockCommand = "date -u +%s"
+
+// Options selects and tunes the checks of a run.
+type Options struct {
+	// ClockTarget is a target spec (see inventory.TargetSpec) of the hosts whose
+	// clocks are compared with the local one. Empty skips the check, which connects
+	// to every selected host.
+	ClockTarget string
+	// MaxSkew is the tolerated clock difference (default DefaultMaxSkew).
+	MaxSkew time.Duration
+	// DialTimeout limits each bastion check (default DefaultDialTimeout).
+	DialTimeout time.Duration
+	// SkipNetwork skips the bastion and clock checks.
+	SkipNetwork bool
+}
+
+// Runner runs the diagnostics. The functions reaching outside the process can be
+// replaced, mainly for tests.
+type Runner struct {
+	Manager *inventory.Manager
+	// Executor runs the clock check; without it the check is skipped.
+	Executor *executor.Executor
+
+	LookPath func(file string) (string, error)
+	Getenv   func(key string) string
+	Dial     func(ctx context.Context, network, address string) (net.Conn, error)
+	Now      func() time.Time
+}
+
+// New creates a Runner using the real environment.
+func New(m *inventory.Manager, e *executor.Executor) *Runner {
+	var d net.Dialer
+	return &Runner{
+		Manager:  m,
+		Executor: e,
+		LookPath: exec.LookPath,
+		Getenv:   os.Getenv,
+		Dial:     d.DialContext,
+		Now:      time.Now,
+	}
+}
+
+// findings collects the findings of a run.
+type findings []inventory.DoctorFinding
+
+func (f *findings) add(check string, severity inventory.Severity, subject, format string, args ...any) {
+	*f = append(*f, inventory.DoctorFinding{Check: check, Severity: severity, Subject: subject, Message: fmt.Sprintf(format, args...)})
+}
+
+// Run runs Manager.Doctor and the environment checks and returns the report. Failing
+// checks are findings, not errors; Run only stops early when ctx is done.
+func (r *Runner) Run(ctx context.Context, opts Options) *Report {
+	if opts.MaxSkew <= 0 {
+		opts.MaxSkew = DefaultMaxSkew
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = DefaultDialTimeout
+	}
+
+	report := &Report{
+		GeneratedAt: r.Now().UTC(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		GoVersion:   runtime.Version(),
+	}
+
+	f := findings(r.Manager.Doctor())
+	hosts := r.Manager.ListHosts()
+	red := redact.New()
+	conns := make(map[string]*inventory.ResolvedConnection, len(hosts))
+	for _, h := range hosts {
+		if conn, err := r.Manager.ResolveConnection(h.ID); err == nil {
+			conns[h.ID] = conn
+			red.AddConnection(conn)
+		}
+	}
+	for _, c := range r.Manager.ListCredentials() {
+		red.AddCredential(c)
+	}
+
+	r.checkBinaries(&f, hosts)
+	r.checkAgent(&f, hosts, conns)
+	r.checkKeychain(&f)
+	r.checkKeyPermissions(&f, hosts)
+	if !opts.SkipNetwork {
+		r.checkBastions(ctx, &f, hosts, conns, opts.DialTimeout)
+		if opts.ClockTarget != "" {
+			r.checkClocks(ctx, &f, opts)
+		}
+	}
+
+	// Shareable: no secrets and no home directory.
+	home, _ := os.UserHomeDir()
+	for i := range f {
+		f[i].Subject = shorten(redact.String(red.String(f[i].Subject)), home)
+		f[i].Message = shorten(redact.String(red.String(f[i].Message)), home)
+	}
+	rank := map[inventory.Severity]int{inventory.SeverityError: 0, inventory.SeverityWarning: 1, inventory.SeverityInfo: 2}
+	sort.SliceStable(f, func(i, j int) bool { return rank[f[i].Severity] < rank[f[j].Severity] })
+	report.Findings = f
+	return report
+}
+
+// shorten replaces the home directory in s with ~.
+func shorten(s, home string) string {
+	if home == "" || home == "/" {
+		return s
+	}
+	return strings.ReplaceAll(s, home, "~")
+}
+
+// checkBinaries reports missing tools: the shell of local hosts and gpg for
+// encrypted data files.
+func (r *Runner) checkBinaries(f *findings, hosts []*inventory.Host) {
+	var local []string
+	for _, h := range hosts {
+		if h.IsLocal() {
+			local = append(local, h.ID)
+		}
+	}
+	if len(local) > 0 && runtime.GOOS != "windows" {
+		r.requireBinary(f, "sh", "local hosts "+strings.Join(local, ", "))
+	}
+
+	var encrypted string
+	filepath.WalkDir(r.Manager.GetDataDir(), func(path string, d fs.DirEntry, err error) error {
+		if err == nil && encrypted == "" && !d.IsDir() && strings.HasSuffix(path, secrets.GPGExtension) {
+			encrypted = path
+		}
+		return nil
+	})
+	if encrypted != "" {
+		r.requireBinary(f, "gpg", "encrypted data file "+encrypted)
+	}
+}
+
+// requireBinary reports a missing binary needed by what.
+func (r *Runner) requireBinary(f *findings, binary, what string) {
+	if path, err := r.LookPath(binary); err != nil {
+		f.add(CheckBinary, inventory.SeverityError, binary, "%s not found in PATH; needed by %s", binary, what)
+	} else {
+		f.add(CheckBinary, inventory.SeverityInfo, binary, "found at %s", path)
+	}
+}
+
+// checkAgent reports an ssh-agent that cannot be reached while hosts rely on it.
+func (r *Runner) checkAgent(f *findings, hosts []*inventory.Host, conns map[string]*inventory.ResolvedConnection) {
+	var users []string
+	for _, h := range hosts {
+		if conn, ok := conns[h.ID]; ok && !conn.Local && usesAgent(conn) {
+			users = append(users, h.ID)
+		}
+	}
+	severity := inventory.SeverityInfo
+	if len(users) > 0 {
+		severity = inventory.SeverityError
+	}
+
+	sock := r.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		if len(users) > 0 {
+			f.add(CheckAgent, severity, "ssh-agent", "SSH_AUTH_SOCK is not set; hosts without a key or password need the agent: %s", strings.Join(users, ", "))
+		} else {
+			f.add(CheckAgent, severity, "ssh-agent", "SSH_AUTH_SOCK is not set")
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDialTimeout)
+	defer cancel()
+	conn, err := r.Dial(ctx, "unix", sock)
+	if err != nil {
+		f.add(CheckAgent, severity, "ssh-agent", "cannot connect to the agent at %s: %v", sock, err)
+		return
+	}
+	conn.Close()
+	f.add(CheckAgent, inventory.SeverityInfo, "ssh-agent", "agent reachable at %s", sock)
+}
+
+// usesAgent reports whether a connection has no authentication of its own, so the
+// agent is used.
+func usesAgent(conn *inventory.ResolvedConnection) bool {
+	return conn.KeyPath == "" && conn.Password == "" && conn.PrivateKey == "" && conn.Provider == ""
+}
+
+// checkKeychain reports password managers used by credentials whose provider is not
+// registered or whose CLI is missing. The provider names are those of the CLIs.
+func (r *Runner) checkKeychain(f *findings) {
+	users := map[string][]string{}
+	for _, c := range r.Manager.ListCredentials() {
+		if c.Provider != "" {
+			users[c.Provider] = append(users[c.Provider], c.ID)
+		}
+	}
+
+	names := make([]string, 0, len(users))
+	for name := range users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		subject := "provider " + name
+		creds := strings.Join(users[name], ", ")
+		if _, ok := inventory.GetCredentialProvider(name); !ok {
+			f.add(CheckKeychain, inventory.SeverityError, subject, "provider is not registered; used by credentials %s", creds)
+			continue
+		}
+		if _, err := r.LookPath(name); err != nil {
+			f.add(CheckKeychain, inventory.SeverityError, subject, "%s CLI not found in PATH; used by credentials %s", name, creds)
+		}
+	}
+}
+
+// checkKeyPermissions reports private keys readable by others, which ssh refuses to
+// use. Keys inside the data directory are covered by Manager.CheckPermissions.
+func (r *Runner) checkKeyPermissions(f *findings, hosts []*inventory.Host) {
+	dataDir := r.Manager.GetDataDir()
+	seen := map[string]bool{}
+	check := func(subject, keyPath string) {
+		if keyPath == "" {
+			return
+		}
+		p := keyPath
+		if strings.HasPrefix(p, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				p = filepath.Join(home, p[2:])
+			}
+		}
+		if !filepath.IsAbs(p) || seen[p] {
+			return
+		}
+		seen[p] = true
+		if rel, err := filepath.Rel(dataDir, p); err == nil && !strings.HasPrefix(rel, "..") {
+			return
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			// Missing keys are reported by Doctor.
+			return
+		}
+		if mode := info.Mode().Perm(); mode&0o077 != 0 {
+			f.add(CheckKeyPermissions, inventory.SeverityWarning, subject, "key file %s has mode %#o; ssh requires 0600", keyPath, mode)
+		}
+	}
+
+	for _, h := range hosts {
+		check("host "+h.ID, h.KeyPath)
+	}
+	for _, c := range r.Manager.ListCredentials() {
+		check("credential "+c.ID, c.KeyPath)
+	}
+}
+
+// checkBastions dials the jump hosts that are reached directly.
+func (r *Runner) checkBastions(ctx context.Context, f *findings, hosts []*inventory.Host, conns map[string]*inventory.ResolvedConnection, timeout time.Duration) {
+	bastions := map[string]bool{}
+	for _, h := range hosts {
+		if h.JumpHostID != "" {
+			bastions[h.JumpHostID] = true
+		}
+	}
+	ids := make([]string, 0, len(bastions))
+	for id := range bastions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		subject := "host " + id
+		conn, ok := conns[id]
+		if !ok {
+			// Unresolvable hosts are reported by Doctor.
+			continue
+		}
+		if len(conn.Jumps) > 0 {
+			f.add(CheckBastion, inventory.SeverityInfo, subject, "reached through %s; not checked directly", conn.Jumps[len(conn.Jumps)-1].HostID)
+			continue
+		}
+
+		address := net.JoinHostPort(conn.Address, strconv.Itoa(conn.Port))
+		if conn.GatewayURL != "" {
+			address += " (through its gateway)"
+		}
+		dialCtx, cancel := context.WithTimeout(ctx, timeout)
+		start := r.Now()
+		c, err := transport.DialFirstHop(dialCtx, conn, r.Dial)
+		cancel()
+		if err != nil {
+			f.add(CheckBastion, inventory.SeverityError, subject, "bastion %s is not reachable: %v", address, err)
+			continue
+		}
+		c.Close()
+		f.add(CheckBastion, inventory.SeverityInfo, subject, "bastion %s reachable in %s", address, r.Now().Sub(start).Round(time.Millisecond))
+	}
+}
+
+// checkClocks compares the clocks of the hosts of opts.ClockTarget with the local one.
+func (r *Runner) checkClocks(ctx context.Context, f *findings, opts Options) {
+	if r.Executor == nil {
+		f.add(CheckClockSkew, inventory.SeverityInfo, "clock", "no executor; clock check skipped")
+		return
+	}
+
+	results, err := r.Executor.Exec(ctx, executor.ExecOptions{Command: clockCommand, Target: opts.ClockTarget})
+	if err != nil {
+		f.add(CheckClockSkew, inventory.SeverityError, "clock", "%v", err)
+		return
+	}
+	for _, res := range results {
+		subject := "host " + res.HostID
+		if !res.OK() {
+			f.add(CheckClockSkew, inventory.SeverityWarning, subject, "cannot read the clock: %s", resultError(res))
+			continue
+		}
+		secs, err := strconv.ParseInt(strings.TrimSpace(res.Stdout), 10, 64)
+		if err != nil {
+			f.add(CheckClockSkew, inventory.SeverityWarning, subject, "unexpected output of %q: %q", clockCommand, strings.TrimSpace(res.Stdout))
+			continue
+		}
+		// The host read its clock somewhere during the run; compare with the middle.
+		local := res.Started.Add(res.Duration / 2)
+		skew := time.Unix(secs, 0).Sub(local).Round(time.Second)
+		if skew.Abs() > opts.MaxSkew {
+			f.add(CheckClockSkew, inventory.SeverityWarning, subject, "clock is off by %s (tolerance %s)", skew, opts.MaxSkew)
+		}
+	}
+}
+
+// resultError describes why a clock command failed.
+func resultError(res executor.Result) string {
+	if res.Err != nil {
+		return res.Err.Error()
+	}
+	if msg := strings.TrimSpace(res.Stderr); msg != "" {
+		return msg
+	}
+	return fmt.Sprintf("exit status %d", res.ExitCode)
+}
@@ -0,0 +1,222 @@
+package diagnostics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gossher/internal/executor"
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clockRunner answers the clock command with the local time shifted by skew[host].
+type clockRunner struct {
+	skew map[string]time.Duration
+}
+
+func (r clockRunner) Run(ctx context.Context, conn *inventory.ResolvedConnection, command string, stdout, stderr io.Writer) (int, error) {
+	if conn.HostID == "down" {
+		return -1, errors.New("connection refused")
+	}
+	fmt.Fprintf(stdout, "%d\n", time.Now().Add(r.skew[conn.HostID]).Unix())
+	return 0, nil
+}
+
+func setupRunner(t *testing.T) (*Runner, *inventory.Manager) {
+	m := inventory.NewManager(t.TempDir())
+	require.NoError(t, m.Load())
+
+	bastion := inventory.NewHost("bastion", "bastion", "192.0.2.1")
+	bastion.User = "jump"
+	bastion.Password = "s3cret-jump-pass"
+	require.NoError(t, m.AddHost(bastion))
+
+	web := inventory.NewHost("web01", "web01", "10.0.0.1")
+	web.User = "deploy"
+	web.JumpHostID = "bastion"
+	require.NoError(t, m.AddHost(web))
+
+	r := New(m, executor.New(m, clockRunner{skew: map[string]time.Duration{"web01": time.Minute}}))
+	r.LookPath = func(file string) (string, error) { return "/usr/bin/" + file, nil }
+	r.Getenv = func(string) string { return "" }
+	r.Dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+	return r, m
+}
+
+// only returns the findings of one check.
+func only(report *Report, check string) []inventory.DoctorFinding {
+	var out []inventory.DoctorFinding
+	for _, f := range report.Findings {
+		if f.Check == check {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func TestRun(t *testing.T) {
+	t.Run("reports a missing agent for hosts relying on it", func(t *testing.T) {
+		r, _ := setupRunner(t)
+
+		agent := only(r.Run(context.Background(), Options{SkipNetwork: true}), CheckAgent)
+		require.Len(t, agent, 1)
+		assert.Equal(t, inventory.SeverityError, agent[0].Severity)
+		assert.Contains(t, agent[0].Message, "web01")
+	})
+
+	t.Run("dials the agent socket", func(t *testing.T) {
+		r, _ := setupRunner(t)
+		r.Getenv = func(string) string { return "/tmp/agent.sock" }
+		var dialed string
+		r.Dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed = network + ":" + address
+			return nil, errors.New("no such file")
+		}
+
+		agent := only(r.Run(context.Background(), Options{SkipNetwork: true}), CheckAgent)
+		assert.Equal(t, "unix:/tmp/agent.sock", dialed)
+		require.Len(t, agent, 1)
+		assert.Equal(t, inventory.SeverityError, agent[0].Severity)
+		assert.Contains(t, agent[0].Message, "no such file")
+	})
+
+	t.Run("checks bastion reachability", func(t *testing.T) {
+		r, _ := setupRunner(t)
+		var dialed []string
+		r.Dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed = append(dialed, address)
+			return nil, errors.New("i/o timeout")
+		}
+
+		bastion := only(r.Run(context.Background(), Options{}), CheckBastion)
+		assert.Equal(t, []string{"192.0.2.1:22"}, dialed)
+		require.Len(t, bastion, 1)
+		assert.Equal(t, inventory.SeverityError, bastion[0].Severity)
+		assert.Equal(t, "host bastion", bastion[0].Subject)
+	})
+
+	t.Run("skips the network checks", func(t *testing.T) {
+		r, _ := setupRunner(t)
+
+		report := r.Run(context.Background(), Options{SkipNetwork: true, ClockTarget: "host:web01"})
+		assert.Empty(t, only(report, CheckBastion))
+		assert.Empty(t, only(report, CheckClockSkew))
+	})
+
+	t.Run("reports clock skew", func(t *testing.T) {
+		r, m := setupRunner(t)
+		down := inventory.NewHost("down", "down", "10.0.0.2")
+		down.User = "deploy"
+		require.NoError(t, m.AddHost(down))
+
+		skew := only(r.Run(context.Background(), Options{ClockTarget: "host:web01 + host:bastion + host:down"}), CheckClockSkew)
+		require.Len(t, skew, 2)
+		bySubject := map[string]string{}
+		for _, f := range skew {
+			bySubject[f.Subject] = f.Message
+		}
+		assert.Contains(t, bySubject["host web01"], "clock is off by")
+		assert.Contains(t, bySubject["host down"], "connection refused")
+	})
+
+	t.Run("reports missing password manager CLIs", func(t *testing.T) {
+		r, m := setupRunner(t)
+		cred := inventory.NewCredential("vault", "vault", "deploy")
+		cred.Provider = "op"
+		cred.ItemID = "abc"
+		require.NoError(t, m.AddCredential(cred))
+		r.LookPath = func(file string) (string, error) { return "", exec404(file) }
+
+		keychain := only(r.Run(context.Background(), Options{SkipNetwork: true}), CheckKeychain)
+		require.Len(t, keychain, 1)
+		assert.Equal(t, "provider op", keychain[0].Subject)
+		assert.Contains(t, keychain[0].Message, "vault")
+	})
+
+	t.Run("reports key files readable by others", func(t *testing.T) {
+		r, m := setupRunner(t)
+		key := filepath.Join(t.TempDir(), "id_ed25519")
+		require.NoError(t, os.WriteFile(key, []byte("key"), 0o644))
+		h, _ := m.GetHost("web01")
+		h.KeyPath = key
+		require.NoError(t, m.UpdateHost(h))
+
+		perms := only(r.Run(context.Background(), Options{SkipNetwork: true}), CheckKeyPermissions)
+		require.Len(t, perms, 1)
+		assert.Equal(t, "host web01", perms[0].Subject)
+		assert.Contains(t, perms[0].Message, "0644")
+	})
+
+	t.Run("redacts secrets", func(t *testing.T) {
+		r, _ := setupRunner(t)
+		r.Dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+			return nil, errors.New("auth with s3cret-jump-pass rejected")
+		}
+
+		report := r.Run(context.Background(), Options{})
+		var out bytes.Buffer
+		require.NoError(t, report.WriteMarkdown(&out))
+		assert.NotContains(t, out.String(), "s3cret-jump-pass")
+		assert.Contains(t, out.String(), "rejected")
+	})
+}
+
+// exec404 is the error of a binary missing from PATH.
+func exec404(file string) error {
+	return fmt.Errorf("exec: %q: executable file not found in $PATH", file)
+}
+
+func TestReport(t *testing.T) {
+	report := &Report{
+		GeneratedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		OS:          "linux",
+		Arch:        "amd64",
+		GoVersion:   "go1.25",
+		Findings: []inventory.DoctorFinding{
+			{Check: CheckBastion, Severity: inventory.SeverityError, Subject: "host bastion", Message: "a|b"},
+		},
+	}
+
+	t.Run("markdown", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, report.Write(&out, ReportMarkdown))
+		assert.Contains(t, out.String(), "- Platform: linux/amd64")
+		assert.Contains(t, out.String(), `| error | host bastion | bastion | a\|b |`)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, report.Write(&out, ReportJSON))
+		var decoded Report
+		require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+		assert.Equal(t, report.Findings, decoded.Findings)
+		assert.True(t, decoded.Failed())
+	})
+
+	t.Run("text", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, report.Write(&out, ReportText))
+		assert.True(t, strings.HasPrefix(out.String(), "gossher diagnostics 2026-01-02T03:04:05Z"))
+		assert.Contains(t, out.String(), "host bastion")
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		assert.Error(t, report.Write(io.Discard, "html"))
+	})
+}
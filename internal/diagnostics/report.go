@@ -0,0 +1,93 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gossher/internal/inventory"
+)
+
+// ReportFormat is the rendering of a report.
+type ReportFormat string
+
+const (
+	// ReportText renders an aligned table for the terminal.
+	ReportText ReportFormat = "text"
+	// ReportMarkdown renders a table to paste into issues.
+	ReportMarkdown ReportFormat = "markdown"
+	// ReportJSON renders indented JSON.
+	ReportJSON ReportFormat = "json"
+)
+
+// Report is the result of a diagnostics run. Secrets known to the inventory and the
+// home directory are removed from the findings.
+type Report struct {
+	GeneratedAt time.Time                 `json:"generated_at"`
+	OS          string                    `json:"os"`
+	Arch        string                    `json:"arch"`
+	GoVersion   string                    `json:"go_version"`
+	Findings    []inventory.DoctorFinding `json:"findings"`
+}
+
+// Failed reports whether any finding is an error.
+func (r *Report) Failed() bool {
+	return inventory.DoctorFailed(r.Findings)
+}
+
+// Write renders the report in the given format.
+func (r *Report) Write(w io.Writer, format ReportFormat) error {
+	switch format {
+	case ReportText:
+		return r.WriteText(w)
+	case ReportMarkdown:
+		return r.WriteMarkdown(w)
+	case ReportJSON:
+		return r.WriteJSON(w)
+	}
+	return fmt.Errorf("unsupported report format: %q", format)
+}
+
+// WriteText renders the environment followed by the findings table.
+func (r *Report) WriteText(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "gossher diagnostics %s (%s/%s, %s)\n\n",
+		r.GeneratedAt.Format(time.RFC3339), r.OS, r.Arch, r.GoVersion); err != nil {
+		return err
+	}
+	return inventory.WriteDoctorFindings(w, r.Findings)
+}
+
+// WriteMarkdown renders the report as a Markdown section with a findings table.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## gossher diagnostics\n\n")
+	fmt.Fprintf(&b, "- Generated: %s\n- Platform: %s/%s\n- Go: %s\n\n", r.GeneratedAt.Format(time.RFC3339), r.OS, r.Arch, r.GoVersion)
+	if len(r.Findings) == 0 {
+		b.WriteString("No findings.\n")
+	} else {
+		b.WriteString("| Severity | Subject | Check | Message |\n|---|---|---|---|\n")
+		for _, f := range r.Findings {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", f.Severity, markdownCell(f.Subject), f.Check, markdownCell(f.Message))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON renders the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// markdownCell escapes text for a table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
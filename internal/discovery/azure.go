@@ -0,0 +1,133 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// DefaultAzureURL is the Resource Manager endpoint used when Azure.URL is empty.
+const DefaultAzureURL = "https://management.azure.com"
+
+// azureVMQuery lists the VMs of a subscription with the addresses of their first
+// network interface in one Resource Graph query.
+const azureVMQuery = `Resources
+| where type =~ 'microsoft.compute/virtualmachines'
+| extend nicId = tolower(tostring(properties.networkProfile.networkInterfaces[0].id)),
+	powerState = tostring(properties.extended.instanceView.powerState.code)
+| join kind=leftouter (Resources
+	| where type =~ 'microsoft.network/networkinterfaces'
+	| extend ipConfig = properties.ipConfigurations[0]
+	| project nicId = tolower(id), privateIp = tostring(ipConfig.properties.privateIPAddress),
+		publicIpId = tolower(tostring(ipConfig.properties.publicIPAddress.id))) on nicId
+| join kind=leftouter (Resources
+	| where type =~ 'microsoft.network/publicipaddresses'
+	| project publicIpId = tolower(id), publicIp = tostring(properties.ipAddress)) on publicIpId
+| project id, name, location, resourceGroup, tags, powerState, privateIp, publicIp
+| order by name asc`
+
+// Ensure Azure implements the interfaces
+var (
+	_ CloudProvider = (*Azure)(nil)
+)
+
+// Azure lists the virtual machines of a subscription through Azure Resource Graph.
+type Azure struct {
+	// Subscription is the subscription ID.
+	Subscription string
+	// ResourceGroups limits the listed VMs to these resource groups; empty lists all.
+	ResourceGroups []string
+	// TokenEnv names an environment variable holding a Resource Manager access
+	// token; without it the token is requested from az.
+	TokenEnv string
+	// Binary is the az executable (default "az").
+	Binary string
+	// URL is the Resource Manager endpoint (default DefaultAzureURL).
+	URL string
+	// HTTPClient sends the API requests (default one with a 30 second timeout).
+	HTTPClient *http.Client
+}
+
+// Name implements CloudProvider.
+func (p *Azure) Name() string {
+	return "azure"
+}
+
+// azureVM is a row of azureVMQuery.
+type azureVM struct {
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	Location      string            `json:"location"`
+	ResourceGroup string            `json:"resourceGroup"`
+	Tags          map[string]string `json:"tags"`
+	PowerState    string            `json:"powerState"`
+	PrivateIP     string            `json:"privateIp"`
+	PublicIP      string            `json:"publicIp"`
+}
+
+// List implements CloudProvider. The zone of an instance is its location.
+func (p *Azure) List(ctx context.Context) ([]Instance, error) {
+	binary := p.Binary
+	if binary == "" {
+		binary = "az"
+	}
+	base := p.URL
+	if base == "" {
+		base = DefaultAzureURL
+	}
+	base = strings.TrimSuffix(base, "/")
+
+	token, err := accessToken(ctx, p.TokenEnv, binary, "account", "get-access-token",
+		"--resource", DefaultAzureURL+"/", "--query", "accessToken", "--output", "tsv")
+	if err != nil {
+		return nil, err
+	}
+	endpoint := base + "/providers/Microsoft.ResourceGraph/resources?api-version=2021-03-01"
+
+	var instances []Instance
+	skipToken := ""
+	for {
+		options := map[string]any{"resultFormat": "objectArray"}
+		if skipToken != "" {
+			options["$skipToken"] = skipToken
+		}
+		body := map[string]any{
+			"subscriptions": []string{p.Subscription},
+			"query":         azureVMQuery,
+			"options":       options,
+		}
+
+		var reply struct {
+			Data      []azureVM `json:"data"`
+			SkipToken string    `json:"$skipToken"`
+		}
+		if err := apiRequest(ctx, p.HTTPClient, http.MethodPost, endpoint, "Bearer "+token, body, &reply); err != nil {
+			return nil, fmt.Errorf("failed to list azure vms: %w", err)
+		}
+
+		for _, vm := range reply.Data {
+			if len(p.ResourceGroups) > 0 && !slices.ContainsFunc(p.ResourceGroups, func(rg string) bool {
+				return strings.EqualFold(rg, vm.ResourceGroup)
+			}) {
+				continue
+			}
+			instances = append(instances, Instance{
+				ID:        vm.ID,
+				Name:      vm.Name,
+				Zone:      vm.Location,
+				PrivateIP: vm.PrivateIP,
+				PublicIP:  vm.PublicIP,
+				Labels:    vm.Tags,
+				Running:   vm.PowerState == "PowerState/running",
+			})
+		}
+
+		if reply.SkipToken == "" {
+			break
+		}
+		skipToken = reply.SkipToken
+	}
+	return instances, nil
+}
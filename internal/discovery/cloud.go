@@ -0,0 +1,161 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"gossher/internal/inventory"
+)
+
+// Ensure Cloud implements the interfaces
+var (
+	_ Provider = (*Cloud)(nil)
+)
+
+// Instance is a virtual machine listed by a cloud provider.
+type Instance struct {
+	// ID is the provider's identifier of the instance.
+	ID   string
+	Name string
+	// Zone is the zone or region the instance runs in.
+	Zone      string
+	PrivateIP string
+	PublicIP  string
+	// Labels are the labels (GCE) or tags (Azure) of the instance.
+	Labels  map[string]string
+	Running bool
+}
+
+// CloudProvider lists the instances of a cloud account.
+type CloudProvider interface {
+	// Name identifies the provider, e.g. "gce".
+	Name() string
+	// List returns all instances, running or not.
+	List(ctx context.Context) ([]Instance, error)
+}
+
+// Cloud turns the running instances of a CloudProvider into hosts, mapping labels
+// to tags and picking the address by preference.
+type Cloud struct {
+	Provider CloudProvider
+	// Address selects the public or private address (default private); instances
+	// without the preferred address use the other one.
+	Address inventory.AddressPreference
+	// Labels limits the labels mapped to tags to these keys; empty maps all labels.
+	Labels []string
+	// User is the login of discovered hosts.
+	User string
+}
+
+// Name implements Provider.
+func (c *Cloud) Name() string {
+	return c.Provider.Name()
+}
+
+// Discover implements Provider. Hosts are identified by their normalized instance
+// name and tagged with zone: and key:value for each mapped label.
+func (c *Cloud) Discover(ctx context.Context) ([]*inventory.Host, error) {
+	instances, err := c.Provider.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []*inventory.Host
+	for _, inst := range instances {
+		if !inst.Running {
+			continue
+		}
+		address := c.address(inst)
+		if address == "" {
+			continue
+		}
+
+		h := inventory.NewHost(inventory.NormalizeID(inst.Name), inst.Name, address)
+		h.User = c.User
+		h.Vars["instance_id"] = inst.ID
+		if inst.Zone != "" {
+			h.AddTag("zone:" + inst.Zone)
+		}
+		for _, tag := range c.labelTags(inst.Labels) {
+			h.AddTag(tag)
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+func (c *Cloud) address(inst Instance) string {
+	if c.Address == inventory.AddressPublic {
+		if inst.PublicIP != "" {
+			return inst.PublicIP
+		}
+		return inst.PrivateIP
+	}
+	if inst.PrivateIP != "" {
+		return inst.PrivateIP
+	}
+	return inst.PublicIP
+}
+
+// labelTags returns the tags of the mapped labels, sorted: key:value, or key alone
+// for labels without a value.
+func (c *Cloud) labelTags(labels map[string]string) []string {
+	keys := c.Labels
+	if len(keys) == 0 {
+		for k := range labels {
+			keys = append(keys, k)
+		}
+	}
+
+	var tags []string
+	for _, k := range keys {
+		v, ok := labels[k]
+		switch {
+		case !ok:
+		case v == "":
+			tags = append(tags, k)
+		default:
+			tags = append(tags, k+":"+v)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// ===== Configuration =====
+
+// FromSource creates the provider described by a discovery source.
+func FromSource(src inventory.DiscoverySource) (Provider, error) {
+	if err := src.Validate(); err != nil {
+		return nil, err
+	}
+
+	var p CloudProvider
+	switch src.Type {
+	case inventory.DiscoveryGCE:
+		p = &GCE{Project: src.Project, Zones: src.Zones, TokenEnv: src.TokenEnv}
+	case inventory.DiscoveryAzure:
+		p = &Azure{Subscription: src.Subscription, ResourceGroups: src.ResourceGroups, TokenEnv: src.TokenEnv}
+	}
+	return &Cloud{Provider: p, Address: src.Address, Labels: src.Labels, User: src.User}, nil
+}
+
+// ProfileProviders creates the providers of the discovery sources configured for
+// a profile.
+func ProfileProviders(profile string) ([]Provider, error) {
+	p, err := inventory.GetProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	providers := make([]Provider, 0, len(p.Discovery))
+	for _, src := range p.Discovery {
+		provider, err := FromSource(src)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", profile, err)
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
@@ -0,0 +1,198 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticCloud lists a fixed set of instances.
+type staticCloud []Instance
+
+func (c staticCloud) Name() string {
+	return "static"
+}
+
+func (c staticCloud) List(ctx context.Context) ([]Instance, error) {
+	return c, nil
+}
+
+func TestCloud(t *testing.T) {
+	instances := staticCloud{
+		{ID: "1", Name: "web01", Zone: "europe-west1-b", PrivateIP: "10.0.0.1", PublicIP: "34.1.1.1",
+			Labels: map[string]string{"env": "prod", "team": "web", "managed": ""}, Running: true},
+		{ID: "2", Name: "batch", PrivateIP: "10.0.0.2", Running: true},
+		{ID: "3", Name: "stopped", PrivateIP: "10.0.0.3"},
+	}
+
+	t.Run("private address and all labels", func(t *testing.T) {
+		c := &Cloud{Provider: instances, User: "deploy"}
+
+		hosts, err := c.Discover(context.Background())
+		require.NoError(t, err)
+		require.Len(t, hosts, 2)
+		assert.Equal(t, "static", c.Name())
+
+		assert.Equal(t, "web01", hosts[0].ID)
+		assert.Equal(t, "10.0.0.1", hosts[0].Address)
+		assert.Equal(t, "deploy", hosts[0].User)
+		assert.Equal(t, "1", hosts[0].Vars["instance_id"])
+		assert.ElementsMatch(t, []string{"zone:europe-west1-b", "env:prod", "team:web", "managed"}, hosts[0].Tags)
+	})
+
+	t.Run("public address and selected labels", func(t *testing.T) {
+		c := &Cloud{Provider: instances, Address: inventory.AddressPublic, Labels: []string{"env", "missing"}}
+
+		hosts, err := c.Discover(context.Background())
+		require.NoError(t, err)
+		require.Len(t, hosts, 2)
+		assert.Equal(t, "34.1.1.1", hosts[0].Address)
+		assert.ElementsMatch(t, []string{"zone:europe-west1-b", "env:prod"}, hosts[0].Tags)
+		assert.Equal(t, "10.0.0.2", hosts[1].Address, "falls back to the private address")
+	})
+}
+
+func TestGCE(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gce-token" {
+			http.Error(w, "unauthenticated", http.StatusUnauthorized)
+			return
+		}
+		require.Equal(t, "/compute/v1/projects/my-project/aggregated/instances", r.URL.Path)
+
+		if r.URL.Query().Get("pageToken") == "" {
+			io.WriteString(w, `{"items": {
+				"zones/europe-west1-b": {"instances": [{"id": "11", "name": "web01", "status": "RUNNING",
+					"zone": "https://www.googleapis.com/compute/v1/projects/my-project/zones/europe-west1-b",
+					"labels": {"env": "prod"},
+					"networkInterfaces": [{"networkIP": "10.132.0.2", "accessConfigs": [{"natIP": "34.76.1.2"}]}]}]},
+				"zones/us-east1-c": {"warning": {"code": "NO_RESULTS_ON_PAGE"}}
+			}, "nextPageToken": "p2"}`)
+			return
+		}
+		io.WriteString(w, `{"items": {
+			"zones/us-east1-c": {"instances": [{"id": "12", "name": "db01", "status": "TERMINATED",
+				"zone": "projects/my-project/zones/us-east1-c", "networkInterfaces": [{"networkIP": "10.142.0.5"}]}]}
+		}}`)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("GCE_TOKEN", "gce-token")
+
+	t.Run("list", func(t *testing.T) {
+		p := &GCE{Project: "my-project", TokenEnv: "GCE_TOKEN", URL: srv.URL}
+
+		instances, err := p.List(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []Instance{
+			{ID: "12", Name: "db01", Zone: "us-east1-c", PrivateIP: "10.142.0.5"},
+			{ID: "11", Name: "web01", Zone: "europe-west1-b", PrivateIP: "10.132.0.2", PublicIP: "34.76.1.2",
+				Labels: map[string]string{"env": "prod"}, Running: true},
+		}, instances)
+	})
+
+	t.Run("zones", func(t *testing.T) {
+		p := &GCE{Project: "my-project", Zones: []string{"us-east1-c"}, TokenEnv: "GCE_TOKEN", URL: srv.URL}
+
+		instances, err := p.List(context.Background())
+		require.NoError(t, err)
+		require.Len(t, instances, 1)
+		assert.Equal(t, "db01", instances[0].Name)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		p := &GCE{Project: "my-project", TokenEnv: "GCE_TOKEN_UNSET", URL: srv.URL}
+
+		_, err := p.List(context.Background())
+		assert.ErrorContains(t, err, "GCE_TOKEN_UNSET is not set")
+	})
+}
+
+func TestAzure(t *testing.T) {
+	var bodies []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer az-token" {
+			http.Error(w, "unauthenticated", http.StatusUnauthorized)
+			return
+		}
+		require.Equal(t, "/providers/Microsoft.ResourceGraph/resources", r.URL.Path)
+
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+
+		if _, ok := body["options"].(map[string]any)["$skipToken"]; !ok {
+			io.WriteString(w, `{"data": [
+				{"id": "/subscriptions/sub1/resourceGroups/web/providers/Microsoft.Compute/virtualMachines/web01",
+				 "name": "web01", "location": "westeurope", "resourceGroup": "WEB", "tags": {"env": "prod"},
+				 "powerState": "PowerState/running", "privateIp": "10.1.0.4", "publicIp": "20.1.2.3"}
+			], "$skipToken": "next"}`)
+			return
+		}
+		io.WriteString(w, `{"data": [
+			{"id": "/subscriptions/sub1/resourceGroups/batch/providers/Microsoft.Compute/virtualMachines/job01",
+			 "name": "job01", "location": "westeurope", "resourceGroup": "batch",
+			 "powerState": "PowerState/deallocated", "privateIp": "10.2.0.4"}
+		]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	// az prints the token for the Resource Manager resource.
+	az := filepath.Join(t.TempDir(), "az")
+	require.NoError(t, os.WriteFile(az, []byte("#!/bin/sh\n[ \"$1 $2\" = \"account get-access-token\" ] && echo az-token\n"), 0755))
+
+	t.Run("list", func(t *testing.T) {
+		bodies = nil
+		p := &Azure{Subscription: "sub1", Binary: az, URL: srv.URL}
+
+		instances, err := p.List(context.Background())
+		require.NoError(t, err)
+		require.Len(t, instances, 2)
+		assert.Equal(t, Instance{
+			ID:        "/subscriptions/sub1/resourceGroups/web/providers/Microsoft.Compute/virtualMachines/web01",
+			Name:      "web01",
+			Zone:      "westeurope",
+			PrivateIP: "10.1.0.4",
+			PublicIP:  "20.1.2.3",
+			Labels:    map[string]string{"env": "prod"},
+			Running:   true,
+		}, instances[0])
+		assert.False(t, instances[1].Running)
+
+		require.Len(t, bodies, 2)
+		assert.Equal(t, []any{"sub1"}, bodies[0]["subscriptions"])
+		assert.Equal(t, "next", bodies[1]["options"].(map[string]any)["$skipToken"])
+	})
+
+	t.Run("resource groups", func(t *testing.T) {
+		p := &Azure{Subscription: "sub1", ResourceGroups: []string{"web"}, Binary: az, URL: srv.URL}
+
+		instances, err := p.List(context.Background())
+		require.NoError(t, err)
+		require.Len(t, instances, 1)
+		assert.Equal(t, "web01", instances[0].Name)
+	})
+}
+
+func TestFromSource(t *testing.T) {
+	p, err := FromSource(inventory.DiscoverySource{Type: inventory.DiscoveryGCE, Project: "my-project", Address: inventory.AddressPublic})
+	require.NoError(t, err)
+	assert.Equal(t, "gce", p.Name())
+	assert.Equal(t, inventory.AddressPublic, p.(*Cloud).Address)
+
+	p, err = FromSource(inventory.DiscoverySource{Type: inventory.DiscoveryAzure, Subscription: "sub1"})
+	require.NoError(t, err)
+	assert.Equal(t, "azure", p.Name())
+
+	_, err = FromSource(inventory.DiscoverySource{Type: inventory.DiscoveryAzure})
+	assert.ErrorContains(t, err, "subscription is required")
+}
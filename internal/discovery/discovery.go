@@ -0,0 +1,182 @@
+// Package discovery imports machines from external sources, such as cloud APIs or
+// plugins, into the inventory as hosts.
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gossher/internal/inventory"
+)
+
+// Provider lists the machines of an external source as hosts.
+type Provider interface {
+	// Name identifies the provider, e.g. "netbox".
+	Name() string
+	// Discover returns the machines currently known to the source. Hosts need an ID;
+	// the same machine must get the same ID on every call.
+	Discover(ctx context.Context) ([]*inventory.Host, error)
+}
+
+var (
+	providers   = map[string]Provider{}
+	providersMu sync.RWMutex
+)
+
+// Register makes a provider available by name, replacing any provider registered
+// under the same name.
+func Register(p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[p.Name()] = p
+}
+
+// Get returns the provider registered under name.
+func Get(name string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+// Names returns the names of the registered providers, sorted.
+func Names() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ===== Helpers =====
+
+// defaultHTTPClient sends API requests of providers without their own client.
+var defaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// accessToken returns the token in the environment variable env if it is set,
+// and otherwise the output of a CLI printing one, such as gcloud or az.
+func accessToken(ctx context.Context, env, binary string, args ...string) (string, error) {
+	if env != "" {
+		token := os.Getenv(env)
+		if token == "" {
+			return "", fmt.Errorf("environment variable %s is not set", env)
+		}
+		return token, nil
+	}
+
+	out, err := runTool(ctx, binary, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to get an access token: %w", err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// runTool runs a CLI and returns its standard output.
+func runTool(ctx context.Context, binary string, args ...string) (string, error) {
+	if _, err := exec.LookPath(binary); err != nil {
+		return "", fmt.Errorf("%s is not installed", binary)
+	}
+
+	cmd := exec.CommandContext(ctx, binary, args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s %s: %w: %s", filepath.Base(binary), args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// apiRequest sends a JSON API request with the given Authorization header,
+// encoding body and decoding the response into out when they are not nil.
+func apiRequest(ctx context.Context, client *http.Client, method, target, auth string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", method, req.URL.Path, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// preferredAddress picks the address to connect to: the first private IPv4
+// address, then any other global IPv4 address, then a global IPv6 address.
+// Loopback and link-local addresses are never picked.
+func preferredAddress(addrs []netip.Addr) (string, bool) {
+	rank := func(a netip.Addr) int {
+		switch {
+		case a.IsLoopback() || a.IsLinkLocalUnicast() || a.IsUnspecified() || !a.IsValid():
+			return 0
+		case a.Is4() && a.IsPrivate():
+			return 3
+		case a.Is4():
+			return 2
+		}
+		return 1
+	}
+
+	var best netip.Addr
+	bestRank := 0
+	for _, a := range addrs {
+		a = a.Unmap()
+		if r := rank(a); r > bestRank {
+			best, bestRank = a, r
+		}
+	}
+	if bestRank == 0 {
+		return "", false
+	}
+	return best.String(), true
+}
@@ -0,0 +1,224 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticProvider discovers a fixed list of hosts.
+type staticProvider struct {
+	hosts []*inventory.Host
+	err   error
+}
+
+func (p *staticProvider) Name() string {
+	return "static"
+}
+
+func (p *staticProvider) Discover(ctx context.Context) ([]*inventory.Host, error) {
+	clones := make([]*inventory.Host, len(p.hosts))
+	for i, h := range p.hosts {
+		clones[i] = h.Clone().(*inventory.Host)
+	}
+	return clones, p.err
+}
+
+func discovered(id, address string) *inventory.Host {
+	h := inventory.NewHost(id, id, address)
+	h.User = "deploy"
+	return h
+}
+
+func TestRegister(t *testing.T) {
+	Register(&staticProvider{})
+	p, ok := Get("static")
+	require.True(t, ok)
+	assert.Equal(t, "static", p.Name())
+	assert.Contains(t, Names(), "static")
+
+	_, ok = Get("missing")
+	assert.False(t, ok)
+}
+
+func TestSync(t *testing.T) {
+	m := inventory.NewManager(t.TempDir())
+	require.NoError(t, m.Load())
+
+	existing := discovered("web01", "10.0.0.1")
+	existing.Description = "kept"
+	require.NoError(t, m.AddHost(existing))
+	require.NoError(t, m.AddHost(discovered("web02", "10.0.0.2")))
+
+	p := &staticProvider{hosts: []*inventory.Host{
+		discovered("web01", "10.0.1.1"),
+		discovered("web02", "10.0.0.2"),
+		discovered("web03", "10.0.0.3"),
+		discovered("", "10.0.0.4"),
+		inventory.NewHost("web05", "web05", "10.0.0.5"),
+	}}
+
+	result, err := Sync(context.Background(), m, p)
+	assert.ErrorContains(t, err, `"10.0.0.4" has no ID`)
+	assert.ErrorContains(t, err, "web05", "invalid hosts are reported")
+	require.NotNil(t, result)
+	assert.Equal(t, []string{"web03"}, result.Added)
+	assert.Equal(t, []string{"web01"}, result.Updated)
+	assert.Equal(t, []string{"web02"}, result.Unchanged)
+
+	h, _ := m.GetHost("web01")
+	assert.Equal(t, "10.0.1.1", h.Address)
+	assert.Equal(t, "kept", h.Description, "other settings are left alone")
+	_, ok := m.GetHost("web03")
+	assert.True(t, ok)
+
+	t.Run("provider error", func(t *testing.T) {
+		_, err := Sync(context.Background(), m, &staticProvider{err: errors.New("api down")})
+		assert.ErrorContains(t, err, "api down")
+	})
+}
+
+func TestSchedule(t *testing.T) {
+	m := inventory.NewManager(t.TempDir())
+	require.NoError(t, m.Load())
+	p := &staticProvider{hosts: []*inventory.Host{discovered("web01", "10.0.0.1")}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var results []*SyncResult
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Schedule(ctx, m, p, time.Millisecond, func(r *SyncResult, err error) {
+			assert.NoError(t, err)
+			results = append(results, r)
+			if len(results) == 2 {
+				cancel()
+			}
+		})
+	}()
+	<-done
+
+	require.Len(t, results, 2)
+	assert.Equal(t, []string{"web01"}, results[0].Added)
+	assert.Equal(t, []string{"web01"}, results[1].Unchanged)
+}
+
+func TestPlan(t *testing.T) {
+	m := inventory.NewManager(t.TempDir())
+	require.NoError(t, m.Load())
+
+	byVar := discovered("old-name", "10.0.0.1")
+	byVar.Vars["instance_id"] = "i-123"
+	require.NoError(t, m.AddHost(byVar))
+	byAddress := discovered("manual", "10.0.0.2")
+	byAddress.Description = "added by hand"
+	require.NoError(t, m.AddHost(byAddress))
+	require.NoError(t, m.AddHost(discovered("web04", "10.0.0.4")))
+
+	renamed := discovered("new-name", "10.0.1.1")
+	renamed.Vars["instance_id"] = "i-123"
+	sameAddress := discovered("web02", "10.0.0.2")
+	sameAddress.Vars["mac"] = "52:54:00:00:00:02"
+	p := &staticProvider{hosts: []*inventory.Host{
+		renamed,
+		sameAddress,
+		discovered("web02-copy", "10.0.0.2"),
+		discovered("web04", "10.0.0.4"),
+		discovered("", "10.0.0.9"),
+	}}
+
+	plan, err := Plan(context.Background(), m, p)
+	require.NoError(t, err)
+	require.Len(t, plan.Changes, 4)
+
+	assert.Equal(t, ActionUpdate, plan.Changes[0].Action)
+	assert.Equal(t, "old-name", plan.Changes[0].HostID)
+	assert.Equal(t, MatchVar, plan.Changes[0].MatchedBy)
+	assert.Equal(t, []FieldChange{{Field: "address", Old: "10.0.0.1", New: "10.0.1.1"}}, plan.Changes[0].Fields)
+
+	assert.Equal(t, ActionUpdate, plan.Changes[1].Action)
+	assert.Equal(t, "manual", plan.Changes[1].HostID)
+	assert.Equal(t, MatchAddress, plan.Changes[1].MatchedBy)
+	assert.Equal(t, ActionAdd, plan.Changes[2].Action, "each existing host is matched once")
+	assert.Equal(t, ActionUnchanged, plan.Changes[3].Action)
+	require.Len(t, plan.Errors, 1)
+
+	assert.Equal(t, `~ old-name (matched by var instance_id): address 10.0.0.1 -> 10.0.1.1
+~ manual (matched by address): vars.mac "" -> 52:54:00:00:00:02
++ web02-copy 10.0.0.2:22
+! static: discovered host "10.0.0.9" has no ID
+1 unchanged
+`, plan.Diff())
+
+	t.Run("dry run leaves the inventory alone", func(t *testing.T) {
+		h, _ := m.GetHost("old-name")
+		assert.Equal(t, "10.0.0.1", h.Address)
+		_, ok := m.GetHost("web02-copy")
+		assert.False(t, ok)
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		result, err := Apply(m, plan)
+		assert.ErrorContains(t, err, "has no ID")
+		assert.Equal(t, []string{"web02-copy"}, result.Added)
+		assert.Equal(t, []string{"old-name", "manual"}, result.Updated)
+		assert.Equal(t, []string{"web04"}, result.Unchanged)
+
+		h, _ := m.GetHost("old-name")
+		assert.Equal(t, "10.0.1.1", h.Address)
+		_, ok := m.GetHost("new-name")
+		assert.False(t, ok, "matched hosts keep their ID")
+
+		h, _ = m.GetHost("manual")
+		assert.Equal(t, "added by hand", h.Description)
+		assert.Equal(t, "52:54:00:00:00:02", h.Vars["mac"])
+	})
+}
+
+func TestSyncLifecycle(t *testing.T) {
+	m := inventory.NewManager(t.TempDir())
+	require.NoError(t, m.Load())
+	require.NoError(t, m.AddHost(discovered("manual", "10.0.0.9")))
+
+	p := &staticProvider{hosts: []*inventory.Host{discovered("web01", "10.0.0.1"), discovered("web02", "10.0.0.2")}}
+	_, err := Sync(context.Background(), m, p)
+	require.NoError(t, err)
+	h, _ := m.GetHost("web01")
+	assert.Equal(t, "static", h.Source)
+
+	t.Run("missing hosts become stale", func(t *testing.T) {
+		p.hosts = p.hosts[1:]
+		plan, err := Plan(context.Background(), m, p)
+		require.NoError(t, err)
+		assert.Contains(t, plan.Diff(), "- web01 no longer discovered, marked stale\n")
+
+		result, err := Apply(m, plan)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web01"}, result.Stale, "hosts from other sources are left alone")
+
+		h, _ := m.GetHost("web01")
+		assert.Equal(t, inventory.LifecycleStale, h.State())
+		assert.False(t, h.StaleSince.IsZero())
+
+		result, err = Sync(context.Background(), m, p)
+		require.NoError(t, err)
+		assert.Empty(t, result.Stale, "stale hosts are marked once")
+	})
+
+	t.Run("rediscovered hosts become active", func(t *testing.T) {
+		p.hosts = append(p.hosts, discovered("web01", "10.0.0.1"))
+		result, err := Sync(context.Background(), m, p)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web01"}, result.Updated)
+
+		h, _ := m.GetHost("web01")
+		assert.Equal(t, inventory.LifecycleActive, h.State())
+		assert.True(t, h.StaleSince.IsZero())
+	})
+}
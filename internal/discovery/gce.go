@@ -0,0 +1,124 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// DefaultGCEURL is the Compute Engine API used when GCE.URL is empty.
+const DefaultGCEURL = "https://compute.googleapis.com"
+
+// Ensure GCE implements the interfaces
+var (
+	_ CloudProvider = (*GCE)(nil)
+)
+
+// GCE lists the Compute Engine instances of a project.
+type GCE struct {
+	// Project is the project ID.
+	Project string
+	// Zones limits the listed instances to these zones; empty lists all zones.
+	Zones []string
+	// TokenEnv names an environment variable holding an OAuth access token;
+	// without it the token is printed by gcloud.
+	TokenEnv string
+	// Binary is the gcloud executable (default "gcloud").
+	Binary string
+	// URL is the API endpoint (default DefaultGCEURL).
+	URL string
+	// HTTPClient sends the API requests (default one with a 30 second timeout).
+	HTTPClient *http.Client
+}
+
+// Name implements CloudProvider.
+func (p *GCE) Name() string {
+	return "gce"
+}
+
+// gceInstance holds the fields of a Compute Engine instance used by List.
+type gceInstance struct {
+	ID                string            `json:"id"`
+	Name              string            `json:"name"`
+	Zone              string            `json:"zone"`
+	Status            string            `json:"status"`
+	Labels            map[string]string `json:"labels"`
+	NetworkInterfaces []struct {
+		NetworkIP     string `json:"networkIP"`
+		AccessConfigs []struct {
+			NatIP string `json:"natIP"`
+		} `json:"accessConfigs"`
+	} `json:"networkInterfaces"`
+}
+
+// List implements CloudProvider. Addresses are taken from the first network
+// interface.
+func (p *GCE) List(ctx context.Context) ([]Instance, error) {
+	binary := p.Binary
+	if binary == "" {
+		binary = "gcloud"
+	}
+	token, err := accessToken(ctx, p.TokenEnv, binary, "auth", "print-access-token")
+	if err != nil {
+		return nil, err
+	}
+	base := p.URL
+	if base == "" {
+		base = DefaultGCEURL
+	}
+	endpoint := strings.TrimSuffix(base, "/") + "/compute/v1/projects/" + url.PathEscape(p.Project) + "/aggregated/instances"
+
+	var instances []Instance
+	pageToken := ""
+	for {
+		query := url.Values{"maxResults": {"500"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		var reply struct {
+			Items map[string]struct {
+				Instances []gceInstance `json:"instances"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := apiRequest(ctx, p.HTTPClient, http.MethodGet, endpoint+"?"+query.Encode(), "Bearer "+token, nil, &reply); err != nil {
+			return nil, fmt.Errorf("failed to list gce instances: %w", err)
+		}
+
+		for _, scope := range reply.Items {
+			for _, gi := range scope.Instances {
+				inst := Instance{
+					ID:      gi.ID,
+					Name:    gi.Name,
+					Zone:    path.Base(gi.Zone),
+					Labels:  gi.Labels,
+					Running: gi.Status == "RUNNING",
+				}
+				if len(p.Zones) > 0 && !slices.Contains(p.Zones, inst.Zone) {
+					continue
+				}
+				if len(gi.NetworkInterfaces) > 0 {
+					nic := gi.NetworkInterfaces[0]
+					inst.PrivateIP = nic.NetworkIP
+					if len(nic.AccessConfigs) > 0 {
+						inst.PublicIP = nic.AccessConfigs[0].NatIP
+					}
+				}
+				instances = append(instances, inst)
+			}
+		}
+
+		if reply.NextPageToken == "" {
+			break
+		}
+		pageToken = reply.NextPageToken
+	}
+
+	slices.SortFunc(instances, func(a, b Instance) int { return strings.Compare(a.Name, b.Name) })
+	return instances, nil
+}
@@ -0,0 +1,133 @@
+package discovery
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+
+	"gossher/internal/inventory"
+)
+
+// DefaultLibvirtURI is the connection URI used when Libvirt.URI is empty.
+const DefaultLibvirtURI = "qemu:///system"
+
+// Ensure Libvirt implements the interfaces
+var (
+	_ Provider = (*Libvirt)(nil)
+)
+
+// Libvirt discovers the running domains of a libvirt daemon with virsh. Addresses
+// are reported by the QEMU guest agent, so domains without a running agent are
+// skipped.
+type Libvirt struct {
+	// URI is the libvirt connection URI, e.g. qemu+ssh://root@kvm01/system
+	// (default DefaultLibvirtURI).
+	URI string
+	// Binary is the virsh executable (default "virsh").
+	Binary string
+	// User is the login of discovered hosts.
+	User string
+}
+
+// Name implements Provider.
+func (p *Libvirt) Name() string {
+	return "libvirt"
+}
+
+// Discover implements Provider. Hosts are identified by their normalized domain
+// name and tagged with node: for the hypervisor's hostname.
+func (p *Libvirt) Discover(ctx context.Context) ([]*inventory.Host, error) {
+	out, err := p.virsh(ctx, "hostname")
+	if err != nil {
+		return nil, err
+	}
+	node := strings.TrimSpace(out)
+
+	out, err = p.virsh(ctx, "list", "--name", "--state-running")
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []*inventory.Host
+	for _, domain := range strings.Split(out, "\n") {
+		domain = strings.TrimSpace(domain)
+		if domain == "" {
+			continue
+		}
+
+		out, err := p.virsh(ctx, "domifaddr", domain, "--source", "agent")
+		if err != nil {
+			// The guest agent is not installed or not running yet.
+			continue
+		}
+		ifaces := parseDomIfAddr(out)
+		addrs := make([]netip.Addr, 0, len(ifaces))
+		for _, iface := range ifaces {
+			addrs = append(addrs, iface.addr)
+		}
+		address, ok := preferredAddress(addrs)
+		if !ok {
+			continue
+		}
+
+		h := inventory.NewHost(inventory.NormalizeID(domain), domain, address)
+		h.User = p.User
+		h.Vars["libvirt_domain"] = domain
+		for _, iface := range ifaces {
+			if iface.addr.String() == address && iface.mac != "" {
+				h.Vars["mac"] = iface.mac
+			}
+		}
+		if node != "" {
+			h.AddTag("node:" + node)
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+// domainAddr is an address of a domain interface.
+type domainAddr struct {
+	mac  string
+	addr netip.Addr
+}
+
+// parseDomIfAddr extracts the addresses from the table printed by virsh domifaddr.
+// Further addresses of an interface are printed with "-" as name and MAC:
+//
+//	Name       MAC address          Protocol     Address
+//	-------------------------------------------------------------------
+//	eth0       52:54:00:8a:2b:3c    ipv4         192.168.122.10/24
+//	-          -                    ipv6         fe80::5054:ff:fe8a:2b3c/64
+func parseDomIfAddr(out string) []domainAddr {
+	var addrs []domainAddr
+	mac := ""
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(fields[len(fields)-1])
+		if err != nil {
+			continue
+		}
+		if fields[1] != "-" {
+			mac = strings.ToLower(fields[1])
+		}
+		addrs = append(addrs, domainAddr{mac: mac, addr: prefix.Addr()})
+	}
+	return addrs
+}
+
+// virsh runs a virsh command against the configured URI and returns its output.
+func (p *Libvirt) virsh(ctx context.Context, args ...string) (string, error) {
+	binary := p.Binary
+	if binary == "" {
+		binary = "virsh"
+	}
+	uri := p.URI
+	if uri == "" {
+		uri = DefaultLibvirtURI
+	}
+	return runTool(ctx, binary, append([]string{"--connect", uri}, args...)...)
+}
@@ -0,0 +1,66 @@
+package discovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVirsh writes a virsh stand-in answering the commands used by Libvirt.
+func fakeVirsh(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "virsh")
+	script := `#!/bin/sh
+[ "$1" = "--connect" ] && [ "$2" = "qemu:///system" ] || { echo "bad uri $2" >&2; exit 1; }
+shift 2
+case "$1 $2" in
+"hostname "*) echo kvm01 ;;
+"list --name") printf 'web01\nnoagent\n\n' ;;
+"domifaddr web01") cat <<EOF
+ Name       MAC address          Protocol     Address
+-------------------------------------------------------------------------------
+ lo         00:00:00:00:00:00    ipv4         127.0.0.1/8
+ -          -                    ipv6         ::1/128
+ eth0       52:54:00:8a:2b:3c    ipv4         192.168.122.10/24
+EOF
+;;
+"domifaddr noagent") echo "error: Guest agent is not responding" >&2; exit 1 ;;
+*) echo "unexpected $*" >&2; exit 1 ;;
+esac
+`
+	require.NoError(t, os.WriteFile(path, []byte(script), 0755))
+	return path
+}
+
+func TestLibvirt(t *testing.T) {
+	t.Run("running domains", func(t *testing.T) {
+		p := &Libvirt{Binary: fakeVirsh(t), User: "admin"}
+
+		hosts, err := p.Discover(context.Background())
+		require.NoError(t, err)
+		require.Len(t, hosts, 1)
+		assert.Equal(t, "web01", hosts[0].ID)
+		assert.Equal(t, "192.168.122.10", hosts[0].Address)
+		assert.Equal(t, "admin", hosts[0].User)
+		assert.Equal(t, []string{"node:kvm01"}, hosts[0].Tags)
+		assert.Equal(t, "web01", hosts[0].Vars["libvirt_domain"])
+		assert.Equal(t, "52:54:00:8a:2b:3c", hosts[0].Vars["mac"])
+	})
+
+	t.Run("connection error", func(t *testing.T) {
+		p := &Libvirt{Binary: fakeVirsh(t), URI: "qemu+ssh://kvm02/system"}
+
+		_, err := p.Discover(context.Background())
+		assert.ErrorContains(t, err, "bad uri qemu+ssh://kvm02/system")
+	})
+
+	t.Run("virsh missing", func(t *testing.T) {
+		p := &Libvirt{Binary: filepath.Join(t.TempDir(), "virsh")}
+
+		_, err := p.Discover(context.Background())
+		assert.ErrorContains(t, err, "not installed")
+	})
+}
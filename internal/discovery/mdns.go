@@ -0,0 +1,137 @@
+package discovery
+
+import (
+	"context"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gossher/internal/inventory"
+)
+
+// Ensure MDNS implements the interfaces
+var (
+	_ Provider = (*MDNS)(nil)
+)
+
+// MDNS discovers the machines advertising SSH (_ssh._tcp) on the local network
+// with avahi-browse, e.g. in a lab or office. Discovered hosts are proposals:
+// review them before syncing, as any machine on the network can advertise.
+type MDNS struct {
+	// Binary is the avahi-browse executable (default "avahi-browse").
+	Binary string
+	// Service is the browsed service type (default "_ssh._tcp").
+	Service string
+	// Hostname connects by the advertised .local name instead of the address,
+	// which survives DHCP changes but needs mDNS name resolution.
+	Hostname bool
+	// User is the login of discovered hosts.
+	User string
+}
+
+// Name implements Provider.
+func (p *MDNS) Name() string {
+	return "mdns"
+}
+
+// mdnsService collects the resolved records of one advertised service.
+type mdnsService struct {
+	name     string
+	hostname string
+	port     int
+	addrs    []netip.Addr
+}
+
+// Discover implements Provider. Hosts are identified by their normalized service
+// name and tagged with mdns. A service resolved on several interfaces or
+// protocols becomes one host.
+func (p *MDNS) Discover(ctx context.Context) ([]*inventory.Host, error) {
+	binary := p.Binary
+	if binary == "" {
+		binary = "avahi-browse"
+	}
+	service := p.Service
+	if service == "" {
+		service = "_ssh._tcp"
+	}
+
+	// --parsable prints one line per resolved record; --terminate exits once the
+	// cache is dumped instead of browsing forever.
+	out, err := runTool(ctx, binary, "--parsable", "--resolve", "--terminate", service)
+	if err != nil {
+		return nil, err
+	}
+
+	services := make(map[string]*mdnsService)
+	for _, line := range strings.Split(out, "\n") {
+		// =;interface;protocol;name;type;domain;hostname;address;port;txt
+		fields := strings.Split(line, ";")
+		if len(fields) < 9 || fields[0] != "=" {
+			continue
+		}
+
+		name := unescapeAvahi(fields[3])
+		s, ok := services[name]
+		if !ok {
+			s = &mdnsService{name: name, hostname: fields[6]}
+			s.port, _ = strconv.Atoi(fields[8])
+			services[name] = s
+		}
+		if a, err := netip.ParseAddr(fields[7]); err == nil {
+			s.addrs = append(s.addrs, a)
+		}
+	}
+
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var hosts []*inventory.Host
+	for _, name := range names {
+		s := services[name]
+		address := s.hostname
+		if !p.Hostname || address == "" {
+			var ok bool
+			if address, ok = preferredAddress(s.addrs); !ok {
+				continue
+			}
+		}
+
+		id := inventory.NormalizeID(s.name)
+		if id == "" {
+			continue
+		}
+		h := inventory.NewHost(id, s.name, address)
+		h.User = p.User
+		if s.port > 0 {
+			h.Port = s.port
+		}
+		h.AddTag("mdns")
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+// unescapeAvahi decodes the \DDD decimal escapes avahi-browse uses for special
+// characters in service names, e.g. "web\03201" for "web 01".
+func unescapeAvahi(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.Atoi(s[i+1 : i+4]); err == nil && n < 256 {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
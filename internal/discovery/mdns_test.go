@@ -0,0 +1,65 @@
+package discovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAvahi writes an avahi-browse stand-in printing resolved _ssh._tcp services.
+func fakeAvahi(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "avahi-browse")
+	script := `#!/bin/sh
+[ "$*" = "--parsable --resolve --terminate _ssh._tcp" ] || { echo "unexpected $*" >&2; exit 1; }
+cat <<'EOF'
++;eth0;IPv4;web\03201;_ssh._tcp;local
+=;eth0;IPv6;web\03201;_ssh._tcp;local;web01.local;fe80::1;22;
+=;eth0;IPv4;web\03201;_ssh._tcp;local;web01.local;192.168.1.10;22;
+=;wlan0;IPv4;web\03201;_ssh._tcp;local;web01.local;192.168.1.10;22;
+=;eth0;IPv4;nas;_ssh._tcp;local;nas.local;192.168.1.20;2222;"model=DS920"
+=;eth0;IPv6;linklocal;_ssh._tcp;local;ll.local;fe80::2;22;
+EOF
+`
+	require.NoError(t, os.WriteFile(path, []byte(script), 0755))
+	return path
+}
+
+func TestMDNS(t *testing.T) {
+	t.Run("resolved services", func(t *testing.T) {
+		p := &MDNS{Binary: fakeAvahi(t), User: "pi"}
+
+		hosts, err := p.Discover(context.Background())
+		require.NoError(t, err)
+		require.Len(t, hosts, 2, "services without a usable address are skipped")
+
+		assert.Equal(t, "nas", hosts[0].ID)
+		assert.Equal(t, "192.168.1.20", hosts[0].Address)
+		assert.Equal(t, 2222, hosts[0].Port)
+
+		assert.Equal(t, "web-01", hosts[1].ID)
+		assert.Equal(t, "web 01", hosts[1].Name)
+		assert.Equal(t, "192.168.1.10", hosts[1].Address)
+		assert.Equal(t, 22, hosts[1].Port)
+		assert.Equal(t, "pi", hosts[1].User)
+		assert.Equal(t, []string{"mdns"}, hosts[1].Tags)
+	})
+
+	t.Run("hostname", func(t *testing.T) {
+		p := &MDNS{Binary: fakeAvahi(t), Hostname: true}
+
+		hosts, err := p.Discover(context.Background())
+		require.NoError(t, err)
+		require.Len(t, hosts, 3)
+		assert.Equal(t, "ll.local", hosts[0].Address)
+		assert.Equal(t, "web01.local", hosts[2].Address)
+	})
+
+	t.Run("unescape", func(t *testing.T) {
+		assert.Equal(t, "a b.c", unescapeAvahi(`a\032b\046c`))
+		assert.Equal(t, `trailing\03`, unescapeAvahi(`trailing\03`))
+	})
+}
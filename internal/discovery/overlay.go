@@ -0,0 +1,205 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strings"
+
+	"gossher/internal/inventory"
+)
+
+// Ensure the overlay providers implement the interfaces
+var (
+	_ Provider = (*Tailscale)(nil)
+	_ Provider = (*ZeroTier)(nil)
+)
+
+// ===== Tailscale =====
+
+// Tailscale discovers the peers of the local tailnet from tailscale status. Overlay
+// addresses can change when nodes are re-registered; Sync updates the addresses
+// of known hosts on every run.
+type Tailscale struct {
+	// Binary is the tailscale executable (default "tailscale").
+	Binary string
+	// MagicDNS connects by MagicDNS name instead of the Tailscale IPv4 address.
+	MagicDNS bool
+	// OnlineOnly skips peers that are offline.
+	OnlineOnly bool
+	// User is the login of discovered hosts.
+	User string
+}
+
+// Name implements Provider.
+func (p *Tailscale) Name() string {
+	return "tailscale"
+}
+
+// tailscalePeer is a node in the output of tailscale status --json.
+type tailscalePeer struct {
+	HostName     string   `json:"HostName"`
+	DNSName      string   `json:"DNSName"`
+	OS           string   `json:"OS"`
+	TailscaleIPs []string `json:"TailscaleIPs"`
+	Online       bool     `json:"Online"`
+	Tags         []string `json:"Tags"`
+}
+
+// Discover implements Provider. Hosts are identified by their normalized host
+// name and tagged with os: and the ACL tags of the node, e.g. tag:server becomes
+// tailscale:server.
+func (p *Tailscale) Discover(ctx context.Context) ([]*inventory.Host, error) {
+	binary := p.Binary
+	if binary == "" {
+		binary = "tailscale"
+	}
+	out, err := runTool(ctx, binary, "status", "--json")
+	if err != nil {
+		return nil, err
+	}
+
+	var status struct {
+		Peer map[string]tailscalePeer `json:"Peer"`
+	}
+	if err := json.Unmarshal([]byte(out), &status); err != nil {
+		return nil, fmt.Errorf("tailscale status: failed to parse output: %w", err)
+	}
+
+	keys := make([]string, 0, len(status.Peer))
+	for k := range status.Peer {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var hosts []*inventory.Host
+	for _, k := range keys {
+		peer := status.Peer[k]
+		if p.OnlineOnly && !peer.Online {
+			continue
+		}
+
+		address := strings.TrimSuffix(peer.DNSName, ".")
+		if !p.MagicDNS || address == "" {
+			var ok bool
+			if address, ok = overlayAddress(peer.TailscaleIPs); !ok {
+				continue
+			}
+		}
+
+		h := inventory.NewHost(inventory.NormalizeID(peer.HostName), peer.HostName, address)
+		h.User = p.User
+		if peer.OS != "" {
+			h.AddTag("os:" + peer.OS)
+		}
+		for _, tag := range peer.Tags {
+			h.AddTag("tailscale:" + strings.TrimPrefix(tag, "tag:"))
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+// overlayAddress returns the first IPv4 address of ips, or the first IPv6 address
+// if there is none.
+func overlayAddress(ips []string) (string, bool) {
+	var v6 string
+	for _, ip := range ips {
+		a, err := netip.ParseAddr(ip)
+		if err != nil {
+			continue
+		}
+		if a.Is4() {
+			return a.String(), true
+		}
+		if v6 == "" {
+			v6 = a.String()
+		}
+	}
+	return v6, v6 != ""
+}
+
+// ===== ZeroTier =====
+
+// DefaultZeroTierURL is the ZeroTier Central API used when ZeroTier.URL is empty.
+const DefaultZeroTierURL = "https://api.zerotier.com"
+
+// ZeroTier discovers the authorized members of a ZeroTier network from the
+// Central API. Like Tailscale, Sync refreshes the addresses of known hosts.
+type ZeroTier struct {
+	// URL is the Central API, or a compatible controller (default DefaultZeroTierURL).
+	URL string
+	// Token is a Central API token.
+	Token string
+	// NetworkID is the 16-digit ID of the network.
+	NetworkID string
+	// User is the login of discovered hosts.
+	User string
+	// HTTPClient sends the API requests (default one with a 30 second timeout).
+	HTTPClient *http.Client
+}
+
+// Name implements Provider.
+func (p *ZeroTier) Name() string {
+	return "zerotier"
+}
+
+// zeroTierMember is a member of the network member list.
+type zeroTierMember struct {
+	NodeID      string `json:"nodeId"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Config      struct {
+		Authorized    bool     `json:"authorized"`
+		IPAssignments []string `json:"ipAssignments"`
+	} `json:"config"`
+}
+
+// Discover implements Provider. Hosts are identified by their normalized member
+// name, or "zt-<node id>" for unnamed members.
+func (p *ZeroTier) Discover(ctx context.Context) ([]*inventory.Host, error) {
+	if p.NetworkID == "" {
+		return nil, fmt.Errorf("zerotier: no network ID")
+	}
+	base := p.URL
+	if base == "" {
+		base = DefaultZeroTierURL
+	}
+
+	target := strings.TrimSuffix(base, "/") + "/api/v1/network/" + url.PathEscape(p.NetworkID) + "/member"
+	var members []zeroTierMember
+	if err := apiRequest(ctx, p.HTTPClient, http.MethodGet, target, "token "+p.Token, nil, &members); err != nil {
+		return nil, fmt.Errorf("failed to list zerotier members: %w", err)
+	}
+
+	var hosts []*inventory.Host
+	for _, m := range members {
+		if !m.Config.Authorized {
+			continue
+		}
+		address, ok := overlayAddress(m.Config.IPAssignments)
+		if !ok {
+			continue
+		}
+
+		id := inventory.NormalizeID(m.Name)
+		if id == "" {
+			id = "zt-" + m.NodeID
+		}
+		name := m.Name
+		if name == "" {
+			name = id
+		}
+
+		h := inventory.NewHost(id, name, address)
+		h.Description = m.Description
+		h.User = p.User
+		h.Vars["zerotier_node_id"] = m.NodeID
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
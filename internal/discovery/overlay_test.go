@@ -0,0 +1,124 @@
+package discovery
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTailscale writes a tailscale stand-in printing a status with the given
+// address for web01.
+func fakeTailscale(t *testing.T, dir, web01IP string) string {
+	path := filepath.Join(dir, "tailscale")
+	script := `#!/bin/sh
+[ "$1 $2" = "status --json" ] || exit 1
+cat <<EOF
+{
+  "Self": {"HostName": "laptop", "TailscaleIPs": ["100.64.0.1"]},
+  "Peer": {
+    "nodekey:b": {"HostName": "web01", "DNSName": "web01.tail1234.ts.net.", "OS": "linux",
+      "TailscaleIPs": ["fd7a:115c:a1e0::2", "` + web01IP + `"], "Online": true, "Tags": ["tag:server"]},
+    "nodekey:a": {"HostName": "Old Phone", "DNSName": "old-phone.tail1234.ts.net.", "OS": "android",
+      "TailscaleIPs": ["100.64.0.9"], "Online": false}
+  }
+}
+EOF
+`
+	require.NoError(t, os.WriteFile(path, []byte(script), 0755))
+	return path
+}
+
+func TestTailscale(t *testing.T) {
+	t.Run("peers", func(t *testing.T) {
+		p := &Tailscale{Binary: fakeTailscale(t, t.TempDir(), "100.64.0.2"), User: "deploy"}
+
+		hosts, err := p.Discover(context.Background())
+		require.NoError(t, err)
+		require.Len(t, hosts, 2)
+
+		assert.Equal(t, "old-phone", hosts[0].ID)
+		assert.Equal(t, "web01", hosts[1].ID)
+		assert.Equal(t, "100.64.0.2", hosts[1].Address)
+		assert.Equal(t, "deploy", hosts[1].User)
+		assert.ElementsMatch(t, []string{"os:linux", "tailscale:server"}, hosts[1].Tags)
+	})
+
+	t.Run("magic dns and online only", func(t *testing.T) {
+		p := &Tailscale{Binary: fakeTailscale(t, t.TempDir(), "100.64.0.2"), MagicDNS: true, OnlineOnly: true}
+
+		hosts, err := p.Discover(context.Background())
+		require.NoError(t, err)
+		require.Len(t, hosts, 1)
+		assert.Equal(t, "web01.tail1234.ts.net", hosts[0].Address)
+	})
+
+	t.Run("sync refreshes addresses", func(t *testing.T) {
+		m := inventory.NewManager(t.TempDir())
+		require.NoError(t, m.Load())
+		dir := t.TempDir()
+
+		p := &Tailscale{Binary: fakeTailscale(t, dir, "100.64.0.2"), User: "deploy"}
+		_, err := Sync(context.Background(), m, p)
+		require.NoError(t, err)
+
+		fakeTailscale(t, dir, "100.64.0.7")
+		result, err := Sync(context.Background(), m, p)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web01"}, result.Updated)
+
+		h, _ := m.GetHost("web01")
+		assert.Equal(t, "100.64.0.7", h.Address)
+	})
+}
+
+func TestZeroTier(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token zt-secret" {
+			http.Error(w, `{"message":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/network/8056c2e21c000001/member" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, `[
+			{"nodeId": "a1b2c3d4e5", "name": "nas", "description": "storage", "config": {"authorized": true, "ipAssignments": ["10.147.17.20"]}},
+			{"nodeId": "f6e5d4c3b2", "name": "", "config": {"authorized": true, "ipAssignments": ["fd00::2"]}},
+			{"nodeId": "0000000001", "name": "pending", "config": {"authorized": false, "ipAssignments": []}}
+		]`)
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Run("authorized members", func(t *testing.T) {
+		p := &ZeroTier{URL: srv.URL, Token: "zt-secret", NetworkID: "8056c2e21c000001", User: "admin"}
+
+		hosts, err := p.Discover(context.Background())
+		require.NoError(t, err)
+		require.Len(t, hosts, 2)
+
+		assert.Equal(t, "nas", hosts[0].ID)
+		assert.Equal(t, "10.147.17.20", hosts[0].Address)
+		assert.Equal(t, "storage", hosts[0].Description)
+		assert.Equal(t, "a1b2c3d4e5", hosts[0].Vars["zerotier_node_id"])
+
+		assert.Equal(t, "zt-f6e5d4c3b2", hosts[1].ID)
+		assert.Equal(t, "fd00::2", hosts[1].Address)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := (&ZeroTier{URL: srv.URL, Token: "wrong", NetworkID: "8056c2e21c000001"}).Discover(context.Background())
+		assert.ErrorContains(t, err, "401")
+
+		_, err = (&ZeroTier{URL: srv.URL, Token: "zt-secret"}).Discover(context.Background())
+		assert.ErrorContains(t, err, "no network ID")
+	})
+}
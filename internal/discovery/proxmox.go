@@ -0,0 +1,184 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gossher/internal/inventory"
+)
+
+// Ensure Proxmox implements the interfaces
+var (
+	_ Provider = (*Proxmox)(nil)
+)
+
+// Proxmox discovers the running VMs and containers of a Proxmox VE cluster. QEMU
+// VMs report their addresses through the guest agent, so VMs without a running
+// agent are skipped; containers report theirs directly.
+type Proxmox struct {
+	// URL is the API endpoint, e.g. https://pve.example.com:8006.
+	URL string
+	// TokenID (user@realm!name) and Secret form the API token.
+	TokenID string
+	Secret  string
+	// User is the login of discovered hosts.
+	User string
+	// HTTPClient sends the API requests (default one with a 30 second timeout),
+	// e.g. to trust the self-signed certificate of the cluster.
+	HTTPClient *http.Client
+}
+
+// Name implements Provider.
+func (p *Proxmox) Name() string {
+	return "proxmox"
+}
+
+// pveResource is a guest of the cluster resources list.
+type pveResource struct {
+	VMID     int    `json:"vmid"`
+	Name     string `json:"name"`
+	Node     string `json:"node"`
+	Type     string `json:"type"`
+	Status   string `json:"status"`
+	Pool     string `json:"pool"`
+	Tags     string `json:"tags"`
+	Template int    `json:"template"`
+}
+
+// Discover implements Provider. Hosts are identified by their normalized name,
+// or "pve-<vmid>" for unnamed guests, and tagged with node:, pool: and type: as
+// well as the Proxmox tags of the guest.
+func (p *Proxmox) Discover(ctx context.Context) ([]*inventory.Host, error) {
+	var resources []pveResource
+	if err := p.get(ctx, "/cluster/resources?type=vm", &resources); err != nil {
+		return nil, fmt.Errorf("failed to list proxmox guests: %w", err)
+	}
+
+	var hosts []*inventory.Host
+	for _, r := range resources {
+		if r.Status != "running" || r.Template == 1 {
+			continue
+		}
+
+		addrs, err := p.addresses(ctx, r)
+		if err != nil {
+			// The guest agent is not installed or not running yet.
+			continue
+		}
+		address, ok := preferredAddress(addrs)
+		if !ok {
+			continue
+		}
+
+		id := inventory.NormalizeID(r.Name)
+		if id == "" {
+			id = fmt.Sprintf("pve-%d", r.VMID)
+		}
+		name := r.Name
+		if name == "" {
+			name = id
+		}
+
+		h := inventory.NewHost(id, name, address)
+		h.User = p.User
+		h.Vars["proxmox_node"] = r.Node
+		h.Vars["proxmox_vmid"] = strconv.Itoa(r.VMID)
+		h.AddTag("node:" + r.Node)
+		h.AddTag("type:" + r.Type)
+		if r.Pool != "" {
+			h.AddTag("pool:" + r.Pool)
+		}
+		for _, tag := range strings.FieldsFunc(r.Tags, func(c rune) bool { return c == ';' || c == ',' || c == ' ' }) {
+			h.AddTag(tag)
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+// addresses returns the addresses reported for a guest.
+func (p *Proxmox) addresses(ctx context.Context, r pveResource) ([]netip.Addr, error) {
+	base := fmt.Sprintf("/nodes/%s/%s/%d", url.PathEscape(r.Node), r.Type, r.VMID)
+
+	var addrs []netip.Addr
+	switch r.Type {
+	case "qemu":
+		var reply struct {
+			Result []struct {
+				Name        string `json:"name"`
+				IPAddresses []struct {
+					Address string `json:"ip-address"`
+				} `json:"ip-addresses"`
+			} `json:"result"`
+		}
+		if err := p.get(ctx, base+"/agent/network-get-interfaces", &reply); err != nil {
+			return nil, err
+		}
+		for _, iface := range reply.Result {
+			for _, ip := range iface.IPAddresses {
+				if a, err := netip.ParseAddr(ip.Address); err == nil {
+					addrs = append(addrs, a)
+				}
+			}
+		}
+
+	case "lxc":
+		var ifaces []struct {
+			Inet  string `json:"inet"`
+			Inet6 string `json:"inet6"`
+		}
+		if err := p.get(ctx, base+"/interfaces", &ifaces); err != nil {
+			return nil, err
+		}
+		for _, iface := range ifaces {
+			for _, s := range []string{iface.Inet, iface.Inet6} {
+				if prefix, err := netip.ParsePrefix(s); err == nil {
+					addrs = append(addrs, prefix.Addr())
+				}
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported guest type %s", r.Type)
+	}
+	return addrs, nil
+}
+
+// get sends an API request and decodes the data member of the response into out.
+func (p *Proxmox) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.URL, "/")+"/api2/json"+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("PVEAPIToken=%s=%s", p.TokenID, p.Secret))
+
+	client := p.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("GET %s: %s: %s", req.URL.Path, resp.Status, strings.TrimSpace(string(detail)))
+	}
+
+	var reply struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return json.Unmarshal(reply.Data, out)
+}
@@ -0,0 +1,106 @@
+package discovery
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fakeProxmox(t *testing.T) *Proxmox {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api2/json/cluster/resources", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "PVEAPIToken=root@pam!gossher=s3cret" {
+			http.Error(w, "authentication failure", http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "vm", r.URL.Query().Get("type"))
+		io.WriteString(w, `{"data": [
+			{"vmid": 100, "name": "web01", "node": "pve1", "type": "qemu", "status": "running", "pool": "prod", "tags": "web;debian"},
+			{"vmid": 101, "name": "noagent", "node": "pve1", "type": "qemu", "status": "running"},
+			{"vmid": 102, "name": "stopped", "node": "pve1", "type": "qemu", "status": "stopped"},
+			{"vmid": 103, "name": "tmpl", "node": "pve1", "type": "qemu", "status": "running", "template": 1},
+			{"vmid": 200, "name": "dns", "node": "pve2", "type": "lxc", "status": "running"}
+		]}`)
+	})
+	mux.HandleFunc("/api2/json/nodes/pve1/qemu/100/agent/network-get-interfaces", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data": {"result": [
+			{"name": "lo", "ip-addresses": [{"ip-address": "127.0.0.1"}, {"ip-address": "::1"}]},
+			{"name": "eth0", "ip-addresses": [{"ip-address": "fe80::1"}, {"ip-address": "192.168.1.10"}]}
+		]}}`)
+	})
+	mux.HandleFunc("/api2/json/nodes/pve1/qemu/101/agent/network-get-interfaces", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"data": null}`, http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/api2/json/nodes/pve2/lxc/200/interfaces", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data": [
+			{"name": "lo", "inet": "127.0.0.1/8"},
+			{"name": "eth0", "inet": "192.168.1.53/24", "inet6": "2001:db8::53/64"}
+		]}`)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return &Proxmox{URL: srv.URL, TokenID: "root@pam!gossher", Secret: "s3cret", User: "root"}
+}
+
+func TestProxmox(t *testing.T) {
+	t.Run("running guests", func(t *testing.T) {
+		p := fakeProxmox(t)
+
+		hosts, err := p.Discover(context.Background())
+		require.NoError(t, err)
+		require.Len(t, hosts, 2)
+
+		assert.Equal(t, "web01", hosts[0].ID)
+		assert.Equal(t, "192.168.1.10", hosts[0].Address)
+		assert.Equal(t, "root", hosts[0].User)
+		assert.ElementsMatch(t, []string{"node:pve1", "type:qemu", "pool:prod", "web", "debian"}, hosts[0].Tags)
+		assert.Equal(t, "100", hosts[0].Vars["proxmox_vmid"])
+
+		assert.Equal(t, "dns", hosts[1].ID)
+		assert.Equal(t, "192.168.1.53", hosts[1].Address)
+		assert.ElementsMatch(t, []string{"node:pve2", "type:lxc"}, hosts[1].Tags)
+	})
+
+	t.Run("bad token", func(t *testing.T) {
+		p := fakeProxmox(t)
+		p.Secret = "wrong"
+
+		_, err := p.Discover(context.Background())
+		assert.ErrorContains(t, err, "401")
+	})
+}
+
+func TestPreferredAddress(t *testing.T) {
+	parse := func(addrs ...string) []netip.Addr {
+		var out []netip.Addr
+		for _, a := range addrs {
+			out = append(out, netip.MustParseAddr(a))
+		}
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		addrs []netip.Addr
+		want  string
+	}{
+		{"private ipv4 first", parse("2001:db8::1", "203.0.113.5", "10.0.0.5"), "10.0.0.5"},
+		{"public ipv4 over ipv6", parse("2001:db8::1", "203.0.113.5"), "203.0.113.5"},
+		{"global ipv6", parse("::1", "fe80::1", "2001:db8::1"), "2001:db8::1"},
+		{"none usable", parse("127.0.0.1", "fe80::1"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := preferredAddress(tt.addrs)
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/progress"
+)
+
+// IdentityVars are the vars identifying a machine across syncs, e.g. after it was
+// renamed on the provider or added to the inventory by hand. A discovered host
+// matches an existing host that has the same value for any of them.
+var IdentityVars = []string{"instance_id", "mac", "proxmox_vmid", "zerotier_node_id", "libvirt_domain"}
+
+// Match describes how a discovered host was matched to an existing one.
+type Match string
+
+const (
+	MatchNone    Match = ""
+	MatchID      Match = "id"
+	MatchVar     Match = "var"
+	MatchAddress Match = "address"
+)
+
+// Action is the planned change for a discovered host.
+type Action string
+
+const (
+	ActionAdd       Action = "add"
+	ActionUpdate    Action = "update"
+	ActionUnchanged Action = "unchanged"
+	// ActionStale marks a host imported by the provider that it no longer reports.
+	ActionStale Action = "stale"
+)
+
+// FieldChange is a changed setting of an existing host.
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// Change is the planned change for one discovered host.
+type Change struct {
+	Action Action
+	// HostID is the ID of the existing host for updates, else of the new host.
+	HostID string
+	// Host is the host to add, or the existing host with the changes applied.
+	Host *inventory.Host
+	// MatchedBy tells how an existing host was found; Key names the identity
+	// var for MatchVar.
+	MatchedBy Match
+	Key       string
+	Fields    []FieldChange
+}
+
+// SyncPlan lists the changes a sync would make, in discovery order.
+type SyncPlan struct {
+	Provider string
+	Changes  []Change
+	// Errors holds the discovered hosts that cannot be synced, e.g. without an ID.
+	Errors []error
+}
+
+// SyncResult lists the hosts touched by a sync by ID.
+type SyncResult struct {
+	Added     []string
+	Updated   []string
+	Unchanged []string
+	Stale     []string
+}
+
+// Plan discovers the hosts of p and reconciles them with the inventory without
+// changing it. A discovered host updates the existing host with the same ID, else
+// the one sharing an identity var (see IdentityVars), else the one with the same
+// address and port; hosts matching none are added. Each existing host is matched
+// at most once.
+//
+// Updates set the address and port and the vars the provider reports, and make
+// stale or retired hosts active again; other settings of existing hosts are left
+// alone. Added hosts record the provider as their Source; active hosts from the
+// same source that are no longer discovered become stale.
+func Plan(ctx context.Context, m *inventory.Manager, p Provider) (*SyncPlan, error) {
+	discovered, err := p.Discover(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover hosts with %s: %w", p.Name(), err)
+	}
+
+	existing := m.ListHosts()
+	claimed := make(map[string]bool)
+	plan := &SyncPlan{Provider: p.Name()}
+	for _, h := range discovered {
+		if h.ID == "" {
+			plan.Errors = append(plan.Errors, fmt.Errorf("%s: discovered host %q has no ID", p.Name(), h.Address))
+			continue
+		}
+
+		target, match, key := reconcile(h, existing, claimed)
+		if target == nil {
+			if h.Type == "" {
+				h.Type = inventory.TypeHost
+			}
+			h.Source = p.Name()
+			plan.Changes = append(plan.Changes, Change{Action: ActionAdd, HostID: h.ID, Host: h})
+			continue
+		}
+		claimed[target.ID] = true
+
+		updated := target.Clone().(*inventory.Host)
+		fields := merge(updated, h)
+		change := Change{Action: ActionUnchanged, HostID: target.ID, Host: updated, MatchedBy: match, Key: key, Fields: fields}
+		if len(fields) > 0 {
+			change.Action = ActionUpdate
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	now := time.Now()
+	for _, e := range existing {
+		if claimed[e.ID] || e.Source != p.Name() || e.State() != inventory.LifecycleActive {
+			continue
+		}
+		updated := e.Clone().(*inventory.Host)
+		updated.SetState(inventory.LifecycleStale, now)
+		plan.Changes = append(plan.Changes, Change{
+			Action: ActionStale,
+			HostID: e.ID,
+			Host:   updated,
+			Fields: []FieldChange{{Field: "lifecycle", Old: string(inventory.LifecycleActive), New: string(inventory.LifecycleStale)}},
+		})
+	}
+	return plan, nil
+}
+
+// reconcile finds the existing host a discovered host refers to.
+func reconcile(h *inventory.Host, existing []*inventory.Host, claimed map[string]bool) (*inventory.Host, Match, string) {
+	for _, e := range existing {
+		if !claimed[e.ID] && e.ID == h.ID {
+			return e, MatchID, ""
+		}
+	}
+	for _, key := range IdentityVars {
+		v := h.Vars[key]
+		if v == "" {
+			continue
+		}
+		for _, e := range existing {
+			if !claimed[e.ID] && strings.EqualFold(e.Vars[key], v) {
+				return e, MatchVar, key
+			}
+		}
+	}
+	for _, e := range existing {
+		if !claimed[e.ID] && !e.IsLocal() && e.Address == h.Address && (h.Port == 0 || e.Port == h.Port) {
+			return e, MatchAddress, ""
+		}
+	}
+	return nil, MatchNone, ""
+}
+
+// merge applies the discovered settings to an existing host and returns the changes.
+func merge(e, h *inventory.Host) []FieldChange {
+	var fields []FieldChange
+	if e.Address != h.Address {
+		fields = append(fields, FieldChange{Field: "address", Old: e.Address, New: h.Address})
+		e.Address = h.Address
+	}
+	if h.Port != 0 && e.Port != h.Port {
+		fields = append(fields, FieldChange{Field: "port", Old: strconv.Itoa(e.Port), New: strconv.Itoa(h.Port)})
+		e.Port = h.Port
+	}
+
+	if state := e.State(); state != inventory.LifecycleActive {
+		fields = append(fields, FieldChange{Field: "lifecycle", Old: string(state), New: string(inventory.LifecycleActive)})
+		e.SetState(inventory.LifecycleActive, time.Time{})
+	}
+
+	keys := make([]string, 0, len(h.Vars))
+	for k := range h.Vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if old, ok := e.Vars[k]; !ok || old != h.Vars[k] {
+			fields = append(fields, FieldChange{Field: "vars." + k, Old: old, New: h.Vars[k]})
+			if e.Vars == nil {
+				e.Vars = make(map[string]string)
+			}
+			e.Vars[k] = h.Vars[k]
+		}
+	}
+	return fields
+}
+
+// Diff describes the planned changes, one line per added, updated or stale host:
+//
+//	~ web01 (matched by var instance_id): address 10.0.0.1 -> 10.0.1.1
+//	+ web03 10.0.0.3:22
+//	- web05 no longer discovered, marked stale
+//
+// Unchanged hosts are counted in the last line.
+func (p *SyncPlan) Diff() string {
+	var b strings.Builder
+	unchanged := 0
+	for _, c := range p.Changes {
+		switch c.Action {
+		case ActionAdd:
+			fmt.Fprintf(&b, "+ %s %s:%d\n", c.HostID, c.Host.Address, c.Host.Port)
+		case ActionUpdate:
+			fmt.Fprintf(&b, "~ %s", c.HostID)
+			if c.MatchedBy != MatchID {
+				fmt.Fprintf(&b, " (matched by %s", c.MatchedBy)
+				if c.Key != "" {
+					fmt.Fprintf(&b, " %s", c.Key)
+				}
+				b.WriteString(")")
+			}
+			parts := make([]string, len(c.Fields))
+			for i, f := range c.Fields {
+				parts[i] = fmt.Sprintf("%s %s -> %s", f.Field, quoteEmpty(f.Old), quoteEmpty(f.New))
+			}
+			fmt.Fprintf(&b, ": %s\n", strings.Join(parts, ", "))
+		case ActionStale:
+			fmt.Fprintf(&b, "- %s no longer discovered, marked stale\n", c.HostID)
+		default:
+			unchanged++
+		}
+	}
+	for _, err := range p.Errors {
+		fmt.Fprintf(&b, "! %v\n", err)
+	}
+	fmt.Fprintf(&b, "%d unchanged\n", unchanged)
+	return b.String()
+}
+
+func quoteEmpty(s string) string {
+	if s == "" {
+		return `""`
+	}
+	return s
+}
+
+// Apply makes the changes of a plan. Hosts that cannot be stored are reported in
+// the joined error, together with the errors of the plan; the others are still
+// synced.
+func Apply(m *inventory.Manager, plan *SyncPlan) (*SyncResult, error) {
+	return ApplyWithProgress(m, plan, nil)
+}
+
+// ApplyWithProgress is Apply reporting every change to r as a host: stored changes
+// as ok, unchanged hosts as skipped and failures as failed.
+func ApplyWithProgress(m *inventory.Manager, plan *SyncPlan, r progress.Reporter) (*SyncResult, error) {
+	task := progress.Start(r, "sync: "+plan.Provider, progress.UnitHosts, int64(len(plan.Changes)))
+	result := &SyncResult{}
+	errs := append([]error(nil), plan.Errors...)
+	for _, c := range plan.Changes {
+		id := c.HostID
+		if c.Action == ActionAdd {
+			id = c.Host.ID
+		}
+		if err := applyChange(m, c, result); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", plan.Provider, err))
+			task.Host(id, progress.HostFailed, err)
+		} else if c.Action == ActionUnchanged {
+			task.Host(id, progress.HostSkipped, nil)
+		} else {
+			task.Host(id, progress.HostOK, nil)
+		}
+	}
+	err := errors.Join(errs...)
+	task.Finish(err)
+	return result, err
+}
+
+// applyChange stores one change and records it in result.
+func applyChange(m *inventory.Manager, c Change, result *SyncResult) error {
+	switch c.Action {
+	case ActionAdd:
+		if err := m.AddHost(c.Host); err != nil {
+			return err
+		}
+		result.Added = append(result.Added, c.Host.ID)
+	case ActionUpdate:
+		if err := m.UpdateHost(c.Host); err != nil {
+			return err
+		}
+		result.Updated = append(result.Updated, c.HostID)
+	case ActionStale:
+		if err := m.UpdateHost(c.Host); err != nil {
+			return err
+		}
+		result.Stale = append(result.Stale, c.HostID)
+	default:
+		result.Unchanged = append(result.Unchanged, c.HostID)
+	}
+	return nil
+}
+
+// Sync plans and applies the changes for the hosts discovered by p; see Plan.
+func Sync(ctx context.Context, m *inventory.Manager, p Provider) (*SyncResult, error) {
+	plan, err := Plan(ctx, m, p)
+	if err != nil {
+		return nil, err
+	}
+	return Apply(m, plan)
+}
+
+// SyncWithProgress is Sync reporting the applied changes to r; see ApplyWithProgress.
+func SyncWithProgress(ctx context.Context, m *inventory.Manager, p Provider, r progress.Reporter) (*SyncResult, error) {
+	plan, err := Plan(ctx, m, p)
+	if err != nil {
+		return nil, err
+	}
+	return ApplyWithProgress(m, plan, r)
+}
+
+// Schedule syncs p into m right away and then every interval until ctx is done.
+// report, if not nil, receives the outcome of every sync. Schedule blocks; run it
+// in a goroutine.
+func Schedule(ctx context.Context, m *inventory.Manager, p Provider, interval time.Duration, report func(*SyncResult, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := Sync(ctx, m, p)
+		if report != nil {
+			report(result, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
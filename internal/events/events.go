@@ -0,0 +1,219 @@
+// Package events publishes connection and execution events to in-process
+// subscribers such as UI layers, notifiers and the audit log.
+package events
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Type identifies the kind of an event.
+type Type string
+
+const (
+	// Connected is published when a connection to a host was established.
+	Connected Type = "connected"
+	// Disconnected is published when a connection to a host was closed.
+	Disconnected Type = "disconnected"
+	// AuthFailed is published when logging in to a host failed.
+	AuthFailed Type = "auth_failed"
+	// ExecStarted is published before a command runs on a host.
+	ExecStarted Type = "exec_started"
+	// ExecFinished is published after a command ran on a host, successfully or not.
+	ExecFinished Type = "exec_finished"
+	// HostStatusChanged is published when the runtime status of a host changes.
+	HostStatusChanged Type = "host_status_changed"
+)
+
+// Event is a single published event. Fields that do not apply to the type are empty.
+type Event struct {
+	Type   Type
+	Time   time.Time
+	HostID string
+
+	// Command is the command of exec events.
+	Command string
+	// ExitCode and Duration are set on ExecFinished.
+	ExitCode int
+	Duration time.Duration
+	// Error describes the failure of AuthFailed, ExecFinished and Disconnected events.
+	Error string
+
+	// Status and PreviousStatus are set on HostStatusChanged.
+	Status         string
+	PreviousStatus string
+}
+
+// Handler receives events. Handlers are called synchronously by Publish and must
+// not block; hand work off to a goroutine or use Bus.Channel instead.
+type Handler func(Event)
+
+// subscription is a handler with the event types it wants; no types means all.
+type subscription struct {
+	handler Handler
+	types   map[Type]bool
+}
+
+// wants reports whether the subscription receives events of type t.
+func (s *subscription) wants(t Type) bool {
+	return len(s.types) == 0 || s.types[t]
+}
+
+// Bus delivers published events to subscribers. It is safe for concurrent use; the
+// zero value is ready to use.
+type Bus struct {
+	mu   sync.RWMutex
+	next int
+	subs map[int]*subscription
+}
+
+// NewBus creates an empty bus.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a handler for the given event types, or every type when none
+// are given. The returned function removes the subscription.
+func (b *Bus) Subscribe(h Handler, types ...Type) (unsubscribe func()) {
+	sub := &subscription{handler: h}
+	if len(types) > 0 {
+		sub.types = make(map[Type]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subs == nil {
+		b.subs = make(map[int]*subscription)
+	}
+	id := b.next
+	b.next++
+	b.subs[id] = sub
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subs, id)
+		})
+	}
+}
+
+// Channel subscribes a buffered channel of the given size. Events published while
+// the buffer is full are dropped so a slow reader never blocks publishers. The
+// channel is closed by the returned function.
+func (b *Bus) Channel(size int, types ...Type) (<-chan Event, func()) {
+	ch := make(chan Event, size)
+	var mu sync.Mutex
+	closed := false
+
+	unsubscribe := b.Subscribe(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	}, types...)
+
+	return ch, func() {
+		unsubscribe()
+		mu.Lock()
+		defer mu.Unlock()
+		if !closed {
+			closed = true
+			close(ch)
+		}
+	}
+}
+
+// Publish delivers an event to every interested subscriber in subscription order.
+// A missing time is set to now. A panicking handler does not keep the event from
+// other subscribers. Publishing on a nil bus does nothing.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	ids := make([]int, 0, len(b.subs))
+	for id, sub := range b.subs {
+		if sub.wants(e.Type) {
+			ids = append(ids, id)
+		}
+	}
+	handlers := make([]Handler, 0, len(ids))
+	sort.Ints(ids)
+	for _, id := range ids {
+		handlers = append(handlers, b.subs[id].handler)
+	}
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		deliver(h, e)
+	}
+}
+
+// deliver calls a handler, recovering from panics.
+func deliver(h Handler, e Event) {
+	defer func() { _ = recover() }()
+	h(e)
+}
+
+// ===== Notifiers =====
+
+// NotifyQueueSize is the number of events queued for a notifier before further
+// events are dropped.
+const NotifyQueueSize = 64
+
+// Notifier forwards events to an external service such as a chat webhook or a
+// plugin.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// AddNotifier subscribes a notifier to the given event types, or every type when
+// none are given. Events are delivered in order on a separate goroutine so a slow
+// notifier never blocks publishers; see NotifyQueueSize. onError, if set, receives
+// delivery errors. The returned function stops the notifier once the queued events
+// were delivered.
+func (b *Bus) AddNotifier(n Notifier, onError func(error), types ...Type) (stop func()) {
+	ch, unsubscribe := b.Channel(NotifyQueueSize, types...)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for e := range ch {
+			if err := n.Notify(context.Background(), e); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}()
+
+	return func() {
+		unsubscribe()
+		<-done
+	}
+}
+
+// ===== Default bus =====
+
+var defaultBus = NewBus()
+
+// Default returns the process-wide bus the executor and inventory publish to
+// unless configured otherwise.
+func Default() *Bus {
+	return defaultBus
+}
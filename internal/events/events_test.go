@@ -0,0 +1,124 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	t.Run("delivers to subscribers of the type in order", func(t *testing.T) {
+		b := NewBus()
+		var got []string
+		b.Subscribe(func(e Event) { got = append(got, "all:"+string(e.Type)) })
+		b.Subscribe(func(e Event) { got = append(got, "auth:"+e.HostID) }, AuthFailed)
+
+		b.Publish(Event{Type: ExecStarted, HostID: "web01"})
+		b.Publish(Event{Type: AuthFailed, HostID: "web02"})
+		assert.Equal(t, []string{"all:exec_started", "all:auth_failed", "auth:web02"}, got)
+	})
+
+	t.Run("sets missing time", func(t *testing.T) {
+		var b Bus
+		var got Event
+		b.Subscribe(func(e Event) { got = e })
+
+		b.Publish(Event{Type: Connected})
+		assert.False(t, got.Time.IsZero())
+
+		at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		b.Publish(Event{Type: Connected, Time: at})
+		assert.Equal(t, at, got.Time)
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		b := NewBus()
+		calls := 0
+		unsubscribe := b.Subscribe(func(Event) { calls++ })
+
+		b.Publish(Event{Type: Connected})
+		unsubscribe()
+		unsubscribe()
+		b.Publish(Event{Type: Connected})
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("panicking handler does not stop delivery", func(t *testing.T) {
+		b := NewBus()
+		delivered := false
+		b.Subscribe(func(Event) { panic("boom") })
+		b.Subscribe(func(Event) { delivered = true })
+
+		assert.NotPanics(t, func() { b.Publish(Event{Type: Disconnected}) })
+		assert.True(t, delivered)
+	})
+
+	t.Run("handlers may subscribe while publishing", func(t *testing.T) {
+		b := NewBus()
+		b.Subscribe(func(Event) { b.Subscribe(func(Event) {}) })
+		assert.NotPanics(t, func() { b.Publish(Event{Type: Connected}) })
+	})
+
+	t.Run("nil bus", func(t *testing.T) {
+		var b *Bus
+		assert.NotPanics(t, func() { b.Publish(Event{Type: Connected}) })
+	})
+}
+
+func TestChannel(t *testing.T) {
+	b := NewBus()
+	ch, stop := b.Channel(1, ExecFinished)
+
+	b.Publish(Event{Type: ExecStarted, HostID: "web01"})
+	b.Publish(Event{Type: ExecFinished, HostID: "web01"})
+	b.Publish(Event{Type: ExecFinished, HostID: "web02"})
+
+	e := <-ch
+	assert.Equal(t, "web01", e.HostID, "events beyond the buffer are dropped")
+
+	stop()
+	stop()
+	b.Publish(Event{Type: ExecFinished, HostID: "web03"})
+	_, ok := <-ch
+	require.False(t, ok, "channel is closed")
+}
+
+// recordingNotifier records the hosts of notified events.
+type recordingNotifier struct {
+	hosts []string
+	fail  bool
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, e Event) error {
+	n.hosts = append(n.hosts, e.HostID)
+	if n.fail {
+		return errors.New("webhook unreachable")
+	}
+	return nil
+}
+
+func TestAddNotifier(t *testing.T) {
+	b := NewBus()
+	n := &recordingNotifier{}
+	stop := b.AddNotifier(n, nil, AuthFailed)
+
+	b.Publish(Event{Type: AuthFailed, HostID: "web01"})
+	b.Publish(Event{Type: Connected, HostID: "web02"})
+	b.Publish(Event{Type: AuthFailed, HostID: "db01"})
+	stop()
+	b.Publish(Event{Type: AuthFailed, HostID: "db02"})
+	assert.Equal(t, []string{"web01", "db01"}, n.hosts, "queued events are delivered before stop returns")
+
+	t.Run("reports errors", func(t *testing.T) {
+		var errs []error
+		stop := b.AddNotifier(&recordingNotifier{fail: true}, func(err error) { errs = append(errs, err) })
+		b.Publish(Event{Type: Connected})
+		stop()
+		require.Len(t, errs, 1)
+		assert.EqualError(t, errs[0], "webhook unreachable")
+	})
+}
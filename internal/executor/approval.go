@@ -0,0 +1,363 @@
+package executor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gossher/internal/audit"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PlansDir is the subdirectory of the data directory holding saved plans.
+const PlansDir = "plans"
+
+// PlanStatus is the review state of a saved plan.
+type PlanStatus string
+
+const (
+	PlanPending  PlanStatus = "pending"
+	PlanApproved PlanStatus = "approved"
+	PlanRejected PlanStatus = "rejected"
+	PlanExecuted PlanStatus = "executed"
+)
+
+// ErrNotApproved is returned when executing a plan that has not been approved.
+var ErrNotApproved = errors.New("plan is not approved")
+
+// PlanRequest is the part of ExecOptions a saved plan is created from.
+type PlanRequest struct {
+	Command string   `yaml:"command"`
+	HostIDs []string `yaml:"host_ids,omitempty"`
+	Groups  []string `yaml:"groups,omitempty"`
+	Target  string   `yaml:"target,omitempty"`
+	Force   bool     `yaml:"force,omitempty"`
+
+	IncludePassive bool      `yaml:"include_passive,omitempty"`
+	ByPriority     bool      `yaml:"by_priority,omitempty"`
+	OnePerGroup    GroupPick `yaml:"one_per_group,omitempty"`
+}
+
+// SavedPlan is an execution plan waiting for review by a second user.
+type SavedPlan struct {
+	ID      string      `yaml:"id"`
+	Status  PlanStatus  `yaml:"status"`
+	Request PlanRequest `yaml:"request"`
+	Plan    Plan        `yaml:"plan"`
+
+	CreatedBy  string    `yaml:"created_by"`
+	CreatedAt  time.Time `yaml:"created_at"`
+	ReviewedBy string    `yaml:"reviewed_by,omitempty"`
+	ReviewedAt time.Time `yaml:"reviewed_at,omitempty"`
+	Comment    string    `yaml:"comment,omitempty"`
+	ExecutedAt time.Time `yaml:"executed_at,omitempty"`
+}
+
+// options converts the request back into ExecOptions.
+func (r PlanRequest) options() ExecOptions {
+	return ExecOptions{
+		Command: r.Command,
+		HostIDs: append([]string(nil), r.HostIDs...),
+		Groups:  append([]string(nil), r.Groups...),
+		Target:  r.Target,
+		Force:   r.Force,
+
+		IncludePassive: r.IncludePassive,
+		ByPriority:     r.ByPriority,
+		OnePerGroup:    r.OnePerGroup,
+	}
+}
+
+// PlanStore keeps saved plans as YAML files and records reviews in the audit log.
+type PlanStore struct {
+	dir   string
+	audit *audit.Log
+	mu    sync.Mutex
+}
+
+// NewPlanStore creates a store under dataDir/plans. A nil log disables auditing.
+func NewPlanStore(dataDir string, log *audit.Log) *PlanStore {
+	return &PlanStore{dir: filepath.Join(dataDir, PlansDir), audit: log}
+}
+
+// Save stores a plan for review. The plan is saved as planned, including
+// violations; approving a blocked plan is what confirms it.
+func (s *PlanStore) Save(plan *Plan, opts ExecOptions, author string) (*SavedPlan, error) {
+	if author == "" {
+		author = audit.CurrentUser()
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate plan ID: %w", err)
+	}
+
+	sp := &SavedPlan{
+		ID:     id,
+		Status: PlanPending,
+		Request: PlanRequest{
+			Command: opts.Command,
+			HostIDs: append([]string(nil), opts.HostIDs...),
+			Groups:  append([]string(nil), opts.Groups...),
+			Target:  opts.Target,
+			Force:   opts.Force || plan.Blocked,
+
+			IncludePassive: opts.IncludePassive,
+			ByPriority:     opts.ByPriority,
+			OnePerGroup:    opts.OnePerGroup,
+		},
+		Plan:      *plan,
+		CreatedBy: author,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.write(sp); err != nil {
+		return nil, err
+	}
+	return sp, s.record(author, "plan.create", sp, nil)
+}
+
+// Get loads a saved plan.
+func (s *PlanStore) Get(id string) (*SavedPlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(id)
+}
+
+// List returns all saved plans, newest first.
+func (s *PlanStore) List() ([]*SavedPlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list plans: %w", err)
+	}
+
+	var plans []*SavedPlan
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yaml") {
+			continue
+		}
+		sp, err := s.read(strings.TrimSuffix(entry.Name(), ".yaml"))
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, sp)
+	}
+	sort.Slice(plans, func(i, j int) bool {
+		return plans[i].CreatedAt.After(plans[j].CreatedAt)
+	})
+	return plans, nil
+}
+
+// Approve marks a pending plan approved. The reviewer must not be its author.
+func (s *PlanStore) Approve(id, reviewer, comment string) error {
+	return s.review(id, reviewer, comment, PlanApproved)
+}
+
+// Reject marks a pending plan rejected.
+func (s *PlanStore) Reject(id, reviewer, comment string) error {
+	return s.review(id, reviewer, comment, PlanRejected)
+}
+
+func (s *PlanStore) review(id, reviewer, comment string, status PlanStatus) error {
+	if reviewer == "" {
+		return fmt.Errorf("reviewer cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sp, err := s.read(id)
+	if err != nil {
+		return err
+	}
+	if sp.Status != PlanPending {
+		return fmt.Errorf("plan %s is already %s", id, sp.Status)
+	}
+	if reviewer == sp.CreatedBy {
+		return fmt.Errorf("plan %s must be reviewed by someone other than its author", id)
+	}
+
+	sp.Status = status
+	sp.ReviewedBy = reviewer
+	sp.ReviewedAt = time.Now().UTC()
+	sp.Comment = comment
+
+	if err := s.write(sp); err != nil {
+		return err
+	}
+	return s.record(reviewer, "plan."+string(status), sp, map[string]string{"comment": comment})
+}
+
+// markExecuted records that an approved plan ran.
+func (s *PlanStore) markExecuted(id, actor string, failed int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sp, err := s.read(id)
+	if err != nil {
+		return err
+	}
+	sp.Status = PlanExecuted
+	sp.ExecutedAt = time.Now().UTC()
+
+	if err := s.write(sp); err != nil {
+		return err
+	}
+	return s.record(actor, "plan.execute", sp, map[string]string{"failed_hosts": fmt.Sprint(failed)})
+}
+
+// record writes an audit event for a plan. Caller must hold the lock.
+func (s *PlanStore) record(actor, action string, sp *SavedPlan, details map[string]string) error {
+	if s.audit == nil {
+		return nil
+	}
+	if details == nil {
+		details = map[string]string{}
+	}
+	details["command"] = sp.Request.Command
+	details["hosts"] = fmt.Sprint(len(sp.Plan.Hosts))
+
+	return s.audit.Record(audit.Event{
+		Actor:   actor,
+		Action:  action,
+		Target:  sp.ID,
+		Details: details,
+	})
+}
+
+// path returns the file of a plan. Caller must hold the lock.
+func (s *PlanStore) path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid plan ID %q", id)
+	}
+	return filepath.Join(s.dir, id+".yaml"), nil
+}
+
+// read loads a plan. Caller must hold the lock.
+func (s *PlanStore) read(id string) (*SavedPlan, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("plan %s not found", id)
+		}
+		return nil, fmt.Errorf("failed to read plan %s: %w", id, err)
+	}
+
+	var sp SavedPlan
+	if err := yaml.Unmarshal(data, &sp); err != nil {
+		return nil, fmt.Errorf("failed to parse plan %s: %w", id, err)
+	}
+	return &sp, nil
+}
+
+// write stores a plan. Caller must hold the lock.
+func (s *PlanStore) write(sp *SavedPlan) error {
+	path, err := s.path(sp.ID)
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(sp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan: %w", err)
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create plans directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write plan %s: %w", sp.ID, err)
+	}
+	return nil
+}
+
+// newID returns a sortable, unique ID for plans and runs.
+func newID() (string, error) {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(b), nil
+}
+
+// ExecuteSaved runs an approved plan. The plan is re-resolved first and refused if
+// the targets or rendered commands changed since it was approved.
+func (e *Executor) ExecuteSaved(ctx context.Context, store *PlanStore, id, actor string) ([]Result, error) {
+	if actor == "" {
+		actor = audit.CurrentUser()
+	}
+
+	sp, err := store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if sp.Status != PlanApproved {
+		return nil, fmt.Errorf("%w: plan %s is %s", ErrNotApproved, id, sp.Status)
+	}
+
+	opts := sp.Request.options()
+	opts.SkipChecks = true
+	current, err := e.Plan(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := samePlan(&sp.Plan, current); err != nil {
+		return nil, fmt.Errorf("plan %s no longer matches the inventory: %w", id, err)
+	}
+
+	results, err := e.Exec(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	failed := 0
+	for i := range results {
+		if results[i].Failed() {
+			failed++
+		}
+	}
+	if err := store.markExecuted(id, actor, failed); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// samePlan compares the targets and rendered commands of two plans.
+func samePlan(approved, current *Plan) error {
+	if len(approved.Hosts) != len(current.Hosts) {
+		return fmt.Errorf("target count changed from %d to %d", len(approved.Hosts), len(current.Hosts))
+	}
+	for i := range approved.Hosts {
+		a, c := approved.Hosts[i], current.Hosts[i]
+		if a.HostID != c.HostID {
+			return fmt.Errorf("target %d changed from %s to %s", i+1, a.HostID, c.HostID)
+		}
+		if a.Command != c.Command {
+			return fmt.Errorf("command for %s changed", a.HostID)
+		}
+		if a.Destination != c.Destination {
+			return fmt.Errorf("destination of %s changed", a.HostID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"gossher/internal/audit"
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanApproval(t *testing.T) {
+	setup := func(t *testing.T) (*Executor, *fakeRunner, *PlanStore, *audit.Log) {
+		e, runner := setupExecutor(t)
+		log := audit.OpenInDir(e.manager.GetDataDir())
+		return e, runner, NewPlanStore(e.manager.GetDataDir(), log), log
+	}
+	save := func(t *testing.T, e *Executor, store *PlanStore, opts ExecOptions) *SavedPlan {
+		opts.SkipChecks = true
+		plan, err := e.Plan(context.Background(), opts)
+		require.NoError(t, err)
+		sp, err := store.Save(plan, opts, "alice")
+		require.NoError(t, err)
+		return sp
+	}
+
+	t.Run("approved plan runs once", func(t *testing.T) {
+		e, runner, store, log := setup(t)
+		sp := save(t, e, store, ExecOptions{Command: "uptime", Groups: []string{"web"}})
+		assert.Equal(t, PlanPending, sp.Status)
+
+		_, err := e.ExecuteSaved(context.Background(), store, sp.ID, "bob")
+		assert.ErrorIs(t, err, ErrNotApproved)
+
+		assert.Error(t, store.Approve(sp.ID, "alice", ""), "authors cannot approve their own plans")
+		require.NoError(t, store.Approve(sp.ID, "bob", "looks good"))
+		assert.Error(t, store.Reject(sp.ID, "carol", ""), "already reviewed")
+
+		results, err := e.ExecuteSaved(context.Background(), store, sp.ID, "bob")
+		require.NoError(t, err)
+		assert.Len(t, results, 2)
+		assert.ElementsMatch(t, []string{"web01", "web02"}, runner.ran)
+
+		got, err := store.Get(sp.ID)
+		require.NoError(t, err)
+		assert.Equal(t, PlanExecuted, got.Status)
+		assert.Equal(t, "bob", got.ReviewedBy)
+
+		_, err = e.ExecuteSaved(context.Background(), store, sp.ID, "bob")
+		assert.ErrorIs(t, err, ErrNotApproved)
+
+		events, err := log.Events()
+		require.NoError(t, err)
+		var actions []string
+		for _, ev := range events {
+			actions = append(actions, ev.Actor+":"+ev.Action)
+		}
+		assert.Equal(t, []string{"alice:plan.create", "bob:plan.approved", "bob:plan.execute"}, actions)
+		assert.Equal(t, "looks good", events[1].Details["comment"])
+	})
+
+	t.Run("inventory changes invalidate the approval", func(t *testing.T) {
+		e, runner, store, _ := setup(t)
+		sp := save(t, e, store, ExecOptions{Command: "uptime", Groups: []string{"web"}})
+		require.NoError(t, store.Approve(sp.ID, "bob", ""))
+
+		g, _ := e.manager.GetGroup("web")
+		g.AddHost("db01")
+		require.NoError(t, e.manager.UpdateGroup(g))
+
+		_, err := e.ExecuteSaved(context.Background(), store, sp.ID, "bob")
+		assert.Error(t, err)
+		assert.Empty(t, runner.ran)
+	})
+
+	t.Run("approval confirms blocked commands", func(t *testing.T) {
+		e, runner, store, _ := setup(t)
+		e.SetCommandPolicy(&inventory.CommandPolicy{Rules: []inventory.CommandRule{
+			{Name: "no-reboot", Deny: []string{`\breboot\b`}},
+		}})
+
+		sp := save(t, e, store, ExecOptions{Command: "reboot", HostIDs: []string{"web01"}})
+		assert.True(t, sp.Plan.Blocked)
+		require.NoError(t, store.Approve(sp.ID, "bob", "maintenance window"))
+
+		_, err := e.ExecuteSaved(context.Background(), store, sp.ID, "bob")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web01"}, runner.ran)
+	})
+
+	t.Run("list and invalid IDs", func(t *testing.T) {
+		e, _, store, _ := setup(t)
+		save(t, e, store, ExecOptions{Command: "uptime", HostIDs: []string{"web01"}})
+		sp := save(t, e, store, ExecOptions{Command: "id", HostIDs: []string{"web01"}})
+		require.NoError(t, store.Reject(sp.ID, "bob", "not needed"))
+
+		plans, err := store.List()
+		require.NoError(t, err)
+		assert.Len(t, plans, 2)
+
+		_, err = store.Get("../config")
+		assert.Error(t, err)
+	})
+}
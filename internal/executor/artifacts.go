@@ -0,0 +1,131 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gossher/internal/inventory"
+	"gossher/internal/redact"
+)
+
+// ArtifactsDir is the subdirectory of a workflow run directory holding fetched
+// artifacts, as ArtifactsDir/<step>/<host>/<file>.
+const ArtifactsDir = "artifacts"
+
+// Downloader is implemented by runners that can copy files from hosts. Runners
+// without it fetch artifacts with "cat".
+type Downloader interface {
+	Download(ctx context.Context, conn *inventory.ResolvedConnection, remotePath string, dst io.Writer) error
+}
+
+// Artifact is a file fetched from a host after a workflow step.
+type Artifact struct {
+	HostID string `yaml:"host" json:"host"`
+	Path   string `yaml:"path" json:"path"`
+	// File is the local copy, relative to the run directory.
+	File  string `yaml:"file,omitempty" json:"file,omitempty"`
+	Size  int64  `yaml:"size" json:"size"`
+	Error string `yaml:"error,omitempty" json:"error,omitempty"`
+}
+
+// collectArtifacts fetches the artifacts of a step from the hosts it ran on into
+// runDir. Missing files are reported in the artifacts without failing the step.
+func (e *Executor) collectArtifacts(ctx context.Context, runDir string, s *inventory.WorkflowStep, hosts []StepHost, concurrency int) []Artifact {
+	hostIDs := make([]string, len(hosts))
+	for i, h := range hosts {
+		hostIDs[i] = h.HostID
+	}
+	perHost := forEachHost(ctx, hostIDs, concurrency, func(ctx context.Context, hostID string) []Artifact {
+		var artifacts []Artifact
+		for _, p := range s.Artifacts {
+			artifacts = append(artifacts, e.fetchArtifact(ctx, runDir, s.Name, hostID, p))
+		}
+		return artifacts
+	}, func(hostID string, err error) []Artifact {
+		var artifacts []Artifact
+		for _, p := range s.Artifacts {
+			artifacts = append(artifacts, Artifact{HostID: hostID, Path: p, Error: err.Error()})
+		}
+		return artifacts
+	})
+
+	var artifacts []Artifact
+	for _, a := range perHost {
+		artifacts = append(artifacts, a...)
+	}
+	return artifacts
+}
+
+// fetchArtifact copies one remote file into the run directory.
+func (e *Executor) fetchArtifact(ctx context.Context, runDir, step, hostID, remotePath string) Artifact {
+	a := Artifact{HostID: hostID, Path: remotePath}
+
+	conn, err := e.manager.ResolveConnection(hostID)
+	if err == nil {
+		err = conn.ResolveSecrets()
+	}
+	if err != nil {
+		a.Error = redact.Error(err).Error()
+		return a
+	}
+	redact.Default().AddConnection(conn)
+	e.applyTimeouts(conn, ExecOptions{})
+
+	var buf bytes.Buffer
+	if err := e.download(ctx, conn, remotePath, &buf); err != nil {
+		a.Error = redact.Error(err).Error()
+		return a
+	}
+
+	rel := filepath.Join(ArtifactsDir, artifactName(step), artifactName(hostID), artifactName(remotePath))
+	dst := filepath.Join(runDir, rel)
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		a.Error = fmt.Sprintf("failed to create artifacts directory: %v", err)
+		return a
+	}
+	if err := os.WriteFile(dst, buf.Bytes(), 0600); err != nil {
+		a.Error = fmt.Sprintf("failed to write artifact: %v", err)
+		return a
+	}
+	a.File = filepath.ToSlash(rel)
+	a.Size = int64(buf.Len())
+	return a
+}
+
+// download copies a remote file with the runner's Downloader, or "cat".
+func (e *Executor) download(ctx context.Context, conn *inventory.ResolvedConnection, remotePath string, dst io.Writer) error {
+	runner := e.runnerFor(conn)
+	if d, ok := runner.(Downloader); ok {
+		return d.Download(ctx, conn, remotePath, dst)
+	}
+
+	var stderr bytes.Buffer
+	code, err := runner.Run(ctx, conn, "cat -- "+inventory.ShellQuote(remotePath), dst, &stderr)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = fmt.Sprintf("exit status %d", code)
+		}
+		return fmt.Errorf("failed to fetch %s: %s", remotePath, msg)
+	}
+	return nil
+}
+
+// artifactName turns a path or ID into a single file name, e.g.
+// "/var/log/app.log" into "var_log_app.log".
+func artifactName(s string) string {
+	s = strings.Trim(filepath.ToSlash(s), "/")
+	s = strings.NewReplacer("/", "_", `\`, "_", ":", "_").Replace(s)
+	if s == "" || strings.HasPrefix(s, ".") {
+		s = "_" + s
+	}
+	return s
+}
@@ -0,0 +1,35 @@
+package executor
+
+import (
+	"context"
+
+	"gossher/internal/events"
+	"gossher/internal/inventory"
+	"gossher/internal/redact"
+)
+
+// Ensure Executor can serve credential tests
+var (
+	_ inventory.Authenticator = (*Executor)(nil)
+)
+
+// Authenticate logs in on a connection. Runners that implement inventory.Authenticator
+// log in without opening a session; others run "true". Local hosts need no login.
+// Failures are published as events.AuthFailed.
+func (e *Executor) Authenticate(ctx context.Context, conn *inventory.ResolvedConnection) error {
+	if conn.Local {
+		return nil
+	}
+	redact.Default().AddConnection(conn)
+
+	var err error
+	if auth, ok := e.runner.(inventory.Authenticator); ok {
+		err = redact.Error(auth.Authenticate(ctx, conn))
+	} else {
+		_, err = e.step(ctx, conn, "true", 0)
+	}
+	if err != nil {
+		e.publish(events.Event{Type: events.AuthFailed, HostID: conn.HostID, Error: err.Error()})
+	}
+	return err
+}
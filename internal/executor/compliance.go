@@ -0,0 +1,230 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/term"
+)
+
+// CheckStatus is the outcome of a check on one host.
+type CheckStatus string
+
+const (
+	CheckPass CheckStatus = "pass"
+	CheckFail CheckStatus = "fail"
+	// CheckError means the check could not be run, e.g. the host was unreachable.
+	CheckError CheckStatus = "error"
+)
+
+// CheckResult is one cell of a compliance matrix.
+type CheckResult struct {
+	HostID   string      `yaml:"host" json:"host"`
+	CheckID  string      `yaml:"check" json:"check"`
+	Status   CheckStatus `yaml:"status" json:"status"`
+	ExitCode int         `yaml:"exit_code" json:"exit_code"`
+	Message  string      `yaml:"message,omitempty" json:"message,omitempty"`
+}
+
+// ComplianceOptions selects the hosts and checks of a compliance run.
+type ComplianceOptions struct {
+	// HostIDs and Groups select the hosts; all hosts with attached checks by default.
+	HostIDs []string
+	Groups  []string
+	// CheckIDs limits the run to some checks; all attached checks by default.
+	CheckIDs []string
+
+	// Concurrency limits parallel hosts (default DefaultConcurrency); the checks of a
+	// host run one after another.
+	Concurrency int
+	// Timeout limits each check; zero means no limit.
+	Timeout time.Duration
+}
+
+// ComplianceMatrix holds the results of checks per host. Checks not attached to a
+// host have no result.
+type ComplianceMatrix struct {
+	Hosts   []string      `yaml:"hosts" json:"hosts"`
+	Checks  []string      `yaml:"checks" json:"checks"`
+	Results []CheckResult `yaml:"results" json:"results"`
+}
+
+// RunChecks runs the checks attached to the selected hosts and collects a
+// host × check matrix.
+func (e *Executor) RunChecks(ctx context.Context, opts ComplianceOptions) (*ComplianceMatrix, error) {
+	hosts, err := e.complianceHosts(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := map[string]bool{}
+	for _, id := range opts.CheckIDs {
+		if _, ok := e.manager.GetCheck(id); !ok {
+			return nil, fmt.Errorf("check %s not found", id)
+		}
+		wanted[id] = true
+	}
+
+	concurrency := e.concurrency(opts.Concurrency)
+
+	checksFor := func(hostID string) []*inventory.Check {
+		var checks []*inventory.Check
+		for _, c := range e.manager.ChecksFor(hostID) {
+			if len(wanted) == 0 || wanted[c.ID] {
+				checks = append(checks, c)
+			}
+		}
+		return checks
+	}
+	perHost := forEachHost(ctx, hosts, concurrency, func(ctx context.Context, hostID string) []CheckResult {
+		var results []CheckResult
+		for _, c := range checksFor(hostID) {
+			results = append(results, e.runCheck(ctx, hostID, c, opts.Timeout))
+		}
+		return results
+	}, func(hostID string, err error) []CheckResult {
+		var results []CheckResult
+		for _, c := range checksFor(hostID) {
+			results = append(results, CheckResult{
+				HostID: hostID, CheckID: c.ID, Status: CheckError, ExitCode: -1, Message: err.Error(),
+			})
+		}
+		return results
+	})
+
+	m := &ComplianceMatrix{Hosts: hosts}
+	seen := map[string]bool{}
+	for _, results := range perHost {
+		for _, r := range results {
+			m.Results = append(m.Results, r)
+			if !seen[r.CheckID] {
+				seen[r.CheckID] = true
+				m.Checks = append(m.Checks, r.CheckID)
+			}
+		}
+	}
+	sort.Strings(m.Checks)
+	return m, nil
+}
+
+// complianceHosts returns the selected hosts, or every host with an attached check.
+func (e *Executor) complianceHosts(opts ComplianceOptions) ([]string, error) {
+	if len(opts.HostIDs) > 0 || len(opts.Groups) > 0 {
+		return e.ResolveTargets(ExecOptions{HostIDs: opts.HostIDs, Groups: opts.Groups})
+	}
+
+	var hosts []string
+	for _, h := range e.manager.ListHosts() {
+		if len(e.manager.ChecksFor(h.ID)) > 0 {
+			hosts = append(hosts, h.ID)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts have checks attached")
+	}
+	return hosts, nil
+}
+
+// runCheck runs one check on one host. Checks are subject to the command policy
+// and are never forced.
+func (e *Executor) runCheck(ctx context.Context, hostID string, c *inventory.Check, timeout time.Duration) CheckResult {
+	cr := CheckResult{HostID: hostID, CheckID: c.ID, ExitCode: -1}
+	opts := ExecOptions{Command: c.Command, Timeout: timeout}
+
+	violations, err := e.violations([]string{hostID}, opts)
+	if err != nil {
+		cr.Status, cr.Message = CheckError, err.Error()
+		return cr
+	}
+	if len(violations) > 0 {
+		cr.Status, cr.Message = CheckError, (&BlockedError{Violations: violations}).Error()
+		return cr
+	}
+
+	r := e.runHost(ctx, hostID, opts)
+	cr.ExitCode = r.ExitCode
+	if r.Err != nil {
+		cr.Status, cr.Message = CheckError, r.Err.Error()
+		return cr
+	}
+	if reason := c.Evaluate(r.ExitCode, r.Stdout); reason != "" {
+		cr.Status, cr.Message = CheckFail, reason
+		return cr
+	}
+	cr.Status = CheckPass
+	return cr
+}
+
+// Get returns the result of a check on a host.
+func (m *ComplianceMatrix) Get(hostID, checkID string) (CheckResult, bool) {
+	for _, r := range m.Results {
+		if r.HostID == hostID && r.CheckID == checkID {
+			return r, true
+		}
+	}
+	return CheckResult{}, false
+}
+
+// Count returns the number of results with the given status.
+func (m *ComplianceMatrix) Count(status CheckStatus) int {
+	n := 0
+	for _, r := range m.Results {
+		if r.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Compliant reports whether every check passed.
+func (m *ComplianceMatrix) Compliant() bool {
+	return m.Count(CheckPass) == len(m.Results)
+}
+
+// JSON encodes the matrix for machine consumption.
+func (m *ComplianceMatrix) JSON() ([]byte, error) {
+	return json.MarshalIndent(m, "", "  ")
+}
+
+// WriteText prints one row per host and one column per check, followed by the
+// reasons of failures.
+func (m *ComplianceMatrix) WriteText(w io.Writer) error {
+	tw := term.NewTable(w)
+
+	fmt.Fprintf(tw, "HOST\t%s\n", strings.Join(m.Checks, "\t"))
+	for _, hostID := range m.Hosts {
+		cells := make([]string, len(m.Checks))
+		for i, checkID := range m.Checks {
+			cells[i] = "-"
+			if r, ok := m.Get(hostID, checkID); ok {
+				cells[i] = strings.ToUpper(string(r.Status))
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", hostID, strings.Join(cells, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, r := range m.Results {
+		if r.Status != CheckPass {
+			problems = append(problems, fmt.Sprintf("%s/%s: %s", r.HostID, r.CheckID, r.Message))
+		}
+	}
+	if len(problems) > 0 {
+		fmt.Fprintln(w)
+		for _, p := range problems {
+			fmt.Fprintln(w, p)
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d passed, %d failed, %d errors\n", m.Count(CheckPass), m.Count(CheckFail), m.Count(CheckError))
+	return nil
+}
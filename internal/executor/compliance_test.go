@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunChecks(t *testing.T) {
+	setup := func(t *testing.T) *Executor {
+		base, inner := setupExecutor(t)
+		runner := &scriptedRunner{fakeRunner: inner, probes: map[string]map[string]probeReply{
+			"web01": {"sshd -T": {out: "permitrootlogin no\n"}},
+			"web02": {"sshd -T": {out: "permitrootlogin yes\n"}},
+			"db01":  {"sshd -T": {out: "permitrootlogin no\n"}, "systemctl": {code: 3}},
+		}}
+		e := New(base.manager, runner)
+
+		ssh := inventory.NewCheck("ssh", "No root login", "sshd -T")
+		ssh.ExpectRegex = `permitrootlogin no`
+		ssh.Groups = []string{"all"}
+		require.NoError(t, e.manager.AddCheck(ssh))
+
+		nginx := inventory.NewCheck("nginx", "nginx running", "systemctl is-active nginx")
+		nginx.Groups = []string{"web"}
+		require.NoError(t, e.manager.AddCheck(nginx))
+
+		ntp := inventory.NewCheck("ntp", "ntp running", "systemctl is-active ntp")
+		ntp.HostIDs = []string{"db01"}
+		require.NoError(t, e.manager.AddCheck(ntp))
+		return e
+	}
+
+	t.Run("builds host by check matrix", func(t *testing.T) {
+		e := setup(t)
+
+		m, err := e.RunChecks(context.Background(), ComplianceOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"db01", "web01", "web02"}, m.Hosts)
+		assert.Equal(t, []string{"nginx", "ntp", "ssh"}, m.Checks)
+		assert.Len(t, m.Results, 6)
+
+		r, ok := m.Get("web02", "ssh")
+		require.True(t, ok)
+		assert.Equal(t, CheckFail, r.Status)
+		r, _ = m.Get("db01", "ntp")
+		assert.Equal(t, CheckFail, r.Status)
+		assert.Contains(t, r.Message, "exit status 3")
+		r, _ = m.Get("web01", "nginx")
+		assert.Equal(t, CheckPass, r.Status)
+		_, ok = m.Get("db01", "nginx")
+		assert.False(t, ok, "nginx is not attached to db01")
+
+		assert.Equal(t, 4, m.Count(CheckPass))
+		assert.False(t, m.Compliant())
+
+		var buf bytes.Buffer
+		require.NoError(t, m.WriteText(&buf))
+		assert.Contains(t, buf.String(), "web02/ssh: output does not match")
+		assert.Contains(t, buf.String(), "4 passed, 2 failed, 0 errors")
+
+		data, err := m.JSON()
+		require.NoError(t, err)
+		var decoded ComplianceMatrix
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, m.Results, decoded.Results)
+	})
+
+	t.Run("filters hosts and checks", func(t *testing.T) {
+		e := setup(t)
+
+		m, err := e.RunChecks(context.Background(), ComplianceOptions{Groups: []string{"web"}, CheckIDs: []string{"ssh"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web01", "web02"}, m.Hosts)
+		assert.Equal(t, []string{"ssh"}, m.Checks)
+
+		_, err = e.RunChecks(context.Background(), ComplianceOptions{CheckIDs: []string{"missing"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("policy blocks are errors", func(t *testing.T) {
+		e := setup(t)
+		e.SetCommandPolicy(&inventory.CommandPolicy{Rules: []inventory.CommandRule{{Name: "no-systemctl", Deny: []string{`systemctl`}}}})
+
+		m, err := e.RunChecks(context.Background(), ComplianceOptions{HostIDs: []string{"db01"}})
+		require.NoError(t, err)
+		r, _ := m.Get("db01", "ntp")
+		assert.Equal(t, CheckError, r.Status)
+		r, _ = m.Get("db01", "ssh")
+		assert.Equal(t, CheckPass, r.Status)
+	})
+}
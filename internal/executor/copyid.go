@@ -0,0 +1,175 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/redact"
+)
+
+// ErrNoPassword is returned by CopyID for hosts without a password to log in with.
+var ErrNoPassword = errors.New("no password to authenticate with")
+
+// CopyIDOptions configures CopyID.
+type CopyIDOptions struct {
+	// CredentialID selects the credential whose public key is installed; it defaults
+	// to the host's credential. The key's ".pub" file must exist next to it.
+	CredentialID string
+
+	// ClearPassword removes the stored password once key-based login works. An inline
+	// host password is cleared and the host switched to the key credential; when the
+	// password belongs to the key credential itself it is cleared there, which affects
+	// every host using that credential.
+	ClearPassword bool
+
+	// Timeout limits each step on the host; zero means no limit.
+	Timeout time.Duration
+}
+
+// CopyIDResult reports what CopyID did.
+type CopyIDResult struct {
+	HostID       string
+	CredentialID string
+	// Installed is false when the key was already in authorized_keys.
+	Installed bool
+	// PasswordCleared is set when the stored password was removed.
+	PasswordCleared bool
+}
+
+// CopyID works like ssh-copy-id: it logs in with the host's password, appends the
+// public key of the credential to ~/.ssh/authorized_keys unless it is already there,
+// verifies that the key alone logs in and then optionally clears the stored password.
+func (e *Executor) CopyID(ctx context.Context, hostID string, opts CopyIDOptions) (*CopyIDResult, error) {
+	h, ok := e.manager.GetHost(hostID)
+	if !ok {
+		return nil, fmt.Errorf("host %s not found", hostID)
+	}
+
+	credID := opts.CredentialID
+	if credID == "" {
+		credID = h.CredentialID
+	}
+	if credID == "" {
+		return nil, fmt.Errorf("host %s: no credential selected", hostID)
+	}
+	cred, ok := e.manager.GetCredential(credID)
+	if !ok {
+		return nil, fmt.Errorf("credential %s not found", credID)
+	}
+	publicKey, err := e.manager.PublicKey(credID)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := e.manager.ResolveConnection(hostID)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.ResolveSecrets(); err != nil {
+		return nil, redact.Error(err)
+	}
+	if conn.Password == "" {
+		return nil, fmt.Errorf("host %s: %w", hostID, ErrNoPassword)
+	}
+	redact.Default().AddConnection(conn)
+
+	result := &CopyIDResult{HostID: hostID, CredentialID: credID}
+
+	// Log in with the password only, so a key that is already installed does not
+	// hide a wrong password.
+	passwordConn := *conn
+	passwordConn.KeyPath = ""
+	passwordConn.PrivateKey = ""
+	passwordConn.Passphrase = ""
+
+	out, err := e.step(ctx, &passwordConn, installKeyCommand(publicKey), opts.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("host %s: failed to install key: %w", hostID, err)
+	}
+	result.Installed = strings.TrimSpace(out) == "added"
+
+	keyConn := *conn
+	keyConn.Jumps = nil
+	keyConn.Password = ""
+	keyConn.PrivateKey = ""
+	keyConn.KeyPath = cred.KeyPath
+	keyConn.Passphrase = cred.Passphrase
+	keyConn.PassphraseFile = cred.PassphraseFile
+	keyConn.Provider, keyConn.ItemID = cred.Provider, cred.ItemID
+	if err := keyConn.ResolveSecrets(); err != nil {
+		return result, redact.Error(err)
+	}
+	keyConn.Jumps = conn.Jumps
+
+	if _, err := e.step(ctx, &keyConn, "true", opts.Timeout); err != nil {
+		return result, fmt.Errorf("host %s: key-based login failed: %w", hostID, err)
+	}
+
+	if opts.ClearPassword {
+		cleared, err := e.clearPassword(h, cred, conn.User)
+		if err != nil {
+			return result, err
+		}
+		result.PasswordCleared = cleared
+	}
+	return result, nil
+}
+
+// clearPassword removes the password of a host that now logs in with cred.
+func (e *Executor) clearPassword(h *inventory.Host, cred *inventory.Credential, user string) (bool, error) {
+	if h.Password == "" && h.CredentialID == cred.ID {
+		if cred.Password == "" {
+			return false, nil
+		}
+		cred.Password = ""
+		return true, e.manager.UpdateCredential(cred)
+	}
+
+	h.Password = ""
+	if h.CredentialID != cred.ID {
+		// Keep the login user when the key credential names another one.
+		if h.User == "" {
+			h.User = user
+		}
+		h.CredentialID = cred.ID
+	}
+	return true, e.manager.UpdateHost(h)
+}
+
+// step runs one command of a multi-step operation and returns its output. Non-zero
+// exit codes are errors.
+func (e *Executor) step(ctx context.Context, conn *inventory.ResolvedConnection, command string, timeout time.Duration) (string, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	code, err := e.runnerFor(conn).Run(ctx, conn, command, &stdout, &stderr)
+	if err != nil {
+		return "", redact.Error(err)
+	}
+	if code != 0 {
+		msg := strings.TrimSpace(redact.String(stderr.String()))
+		if msg == "" {
+			return "", fmt.Errorf("exit status %d", code)
+		}
+		return "", fmt.Errorf("exit status %d: %s", code, msg)
+	}
+	return stdout.String(), nil
+}
+
+// installKeyCommand appends a public key to authorized_keys unless it is already
+// listed, printing "added" or "present".
+func installKeyCommand(publicKey string) string {
+	key := inventory.ShellQuote(publicKey)
+	return "umask 077; mkdir -p ~/.ssh && touch ~/.ssh/authorized_keys && " +
+		"if grep -qxF -- " + key + " ~/.ssh/authorized_keys; then echo present; " +
+		"else printf '%s\\n' " + key + " >> ~/.ssh/authorized_keys && echo added; fi"
+}
@@ -0,0 +1,162 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"gossher/internal/events"
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sshdRunner emulates a server that accepts the password "hunter22" and keys listed
+// in authorized_keys.
+type sshdRunner struct {
+	mu         sync.Mutex
+	authorized map[string]bool
+	commands   []string
+	rejectKeys bool
+}
+
+func (r *sshdRunner) Run(ctx context.Context, conn *inventory.ResolvedConnection, command string, stdout, stderr io.Writer) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case conn.KeyPath != "" && conn.Password == "":
+		if r.rejectKeys || !r.authorized[conn.HostID] {
+			return -1, errors.New("permission denied (publickey)")
+		}
+	case conn.Password == "hunter22" && conn.KeyPath == "":
+	default:
+		return -1, errors.New("permission denied")
+	}
+
+	r.commands = append(r.commands, command)
+	if strings.Contains(command, "authorized_keys") {
+		if r.authorized[conn.HostID] {
+			fmt.Fprintln(stdout, "present")
+		} else {
+			r.authorized[conn.HostID] = true
+			fmt.Fprintln(stdout, "added")
+		}
+	}
+	return 0, nil
+}
+
+func TestCopyID(t *testing.T) {
+	setup := func(t *testing.T) (*Executor, *sshdRunner) {
+		base, _ := setupExecutor(t)
+
+		keys := t.TempDir()
+		keyPath := filepath.Join(keys, "id_ed25519")
+		require.NoError(t, os.WriteFile(keyPath, []byte("PRIVATE"), 0600))
+		require.NoError(t, os.WriteFile(keyPath+".pub", []byte("ssh-ed25519 AAAAC3 deploy@laptop\n"), 0644))
+
+		cred := inventory.NewCredential("deploy-key", "Deploy key", "deploy")
+		cred.KeyPath = keyPath
+		require.NoError(t, base.manager.AddCredential(cred))
+
+		runner := &sshdRunner{authorized: map[string]bool{}}
+		return New(base.manager, runner), runner
+	}
+
+	t.Run("installs the key and clears the password", func(t *testing.T) {
+		e, runner := setup(t)
+
+		res, err := e.CopyID(context.Background(), "web01", CopyIDOptions{CredentialID: "deploy-key", ClearPassword: true})
+		require.NoError(t, err)
+		assert.True(t, res.Installed)
+		assert.True(t, res.PasswordCleared)
+		assert.Contains(t, runner.commands[0], "'ssh-ed25519 AAAAC3 deploy@laptop'")
+		assert.Equal(t, "true", runner.commands[1])
+
+		h, _ := e.manager.GetHost("web01")
+		assert.Empty(t, h.Password)
+		assert.Equal(t, "deploy-key", h.CredentialID)
+		assert.Equal(t, "deploy", h.User)
+
+		results, err := e.Exec(context.Background(), ExecOptions{Command: "id", HostIDs: []string{"web01"}})
+		require.NoError(t, err)
+		assert.True(t, results[0].OK(), "the host now logs in with the key")
+	})
+
+	t.Run("keeps the password unless asked", func(t *testing.T) {
+		e, runner := setup(t)
+		runner.authorized["web02"] = true
+
+		res, err := e.CopyID(context.Background(), "web02", CopyIDOptions{CredentialID: "deploy-key"})
+		require.NoError(t, err)
+		assert.False(t, res.Installed, "the key was already authorized")
+		assert.False(t, res.PasswordCleared)
+
+		h, _ := e.manager.GetHost("web02")
+		assert.Equal(t, "hunter22", h.Password)
+	})
+
+	t.Run("failed verification keeps the password", func(t *testing.T) {
+		e, runner := setup(t)
+		runner.rejectKeys = true
+
+		_, err := e.CopyID(context.Background(), "web01", CopyIDOptions{CredentialID: "deploy-key", ClearPassword: true})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "key-based login failed")
+
+		h, _ := e.manager.GetHost("web01")
+		assert.Equal(t, "hunter22", h.Password)
+	})
+
+	t.Run("preconditions", func(t *testing.T) {
+		e, _ := setup(t)
+
+		_, err := e.CopyID(context.Background(), "web01", CopyIDOptions{})
+		assert.Error(t, err, "no credential selected")
+		_, err = e.CopyID(context.Background(), "missing", CopyIDOptions{CredentialID: "deploy-key"})
+		assert.Error(t, err)
+
+		h, _ := e.manager.GetHost("db01")
+		h.Password = ""
+		require.NoError(t, e.manager.UpdateHost(h))
+		_, err = e.CopyID(context.Background(), "db01", CopyIDOptions{CredentialID: "deploy-key"})
+		assert.ErrorIs(t, err, ErrNoPassword)
+	})
+}
+
+func TestAuthenticate(t *testing.T) {
+	base, _ := setupExecutor(t)
+	runner := &sshdRunner{authorized: map[string]bool{}}
+	e := New(base.manager, runner)
+
+	wrong := inventory.NewCredential("wrong", "Wrong", "deploy")
+	wrong.Password = "guess"
+	require.NoError(t, e.manager.AddCredential(wrong))
+	right := inventory.NewCredential("right", "Right", "deploy")
+	right.Password = "hunter22"
+	require.NoError(t, e.manager.AddCredential(right))
+
+	bus := events.NewBus()
+	e.SetEventBus(bus)
+	failed, stop := bus.Channel(10, events.AuthFailed)
+	defer stop()
+
+	e.manager.SetAuthenticator(e)
+	matrix, err := e.manager.TestAllHosts(context.Background())
+	require.NoError(t, err)
+	for _, hostID := range matrix.Hosts {
+		assert.Equal(t, []string{"right"}, matrix.Working(hostID), hostID)
+	}
+	assert.Equal(t, []string{"true", "true", "true"}, runner.commands, "runners without login support run true")
+
+	require.Len(t, failed, 3, "failed logins are published")
+	ev := <-failed
+	assert.Contains(t, ev.Error, "permission denied")
+}
@@ -0,0 +1,248 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/redact"
+)
+
+// DefaultDeployMode is the mode of deployed files when DeployOptions.Mode is unset.
+const DefaultDeployMode os.FileMode = 0o644
+
+// DeployOptions describes a template deploy: a local Go template rendered for each
+// host with the same data as command templates (see TemplateData) and uploaded to
+// a remote path.
+type DeployOptions struct {
+	// HostIDs, Groups and Target select the hosts as in ExecOptions.
+	HostIDs []string
+	Groups  []string
+	Target  string
+
+	// Template is the path of the local template.
+	Template string
+	// Destination is the remote path of the rendered file.
+	Destination string
+	// Mode is the mode of the remote file (default DefaultDeployMode). Owner and
+	// Group, when set, are applied with chown.
+	Mode  os.FileMode
+	Owner string
+	Group string
+
+	// NoBackup overwrites a changed file without keeping the previous version,
+	// which is otherwise copied to <destination>.<timestamp>.bak next to it.
+	NoBackup bool
+	// Restart is a systemd service restarted on hosts where the file changed.
+	Restart string
+
+	// Inputs are the values of {{.Inputs.name}} in the template.
+	Inputs map[string]string
+
+	// Concurrency limits parallel hosts (default DefaultConcurrency) and Timeout
+	// each host's deploy.
+	Concurrency int
+	Timeout     time.Duration
+
+	// DryRun reports which hosts would change without writing anything.
+	DryRun bool
+}
+
+// DeployResult is the outcome of a deploy on one host.
+type DeployResult struct {
+	HostID string
+	// Changed is set when the content, mode or owner of the file differed.
+	Changed bool
+	// Backup is the remote path the previous file was copied to.
+	Backup string
+	// Restarted is set when the service was restarted.
+	Restarted bool
+	Err       error
+	Duration  time.Duration
+}
+
+// OK reports whether the deploy succeeded.
+func (r *DeployResult) OK() bool {
+	return r.Err == nil
+}
+
+// remoteFile is the state of a deployed file on a host.
+type remoteFile struct {
+	exists  bool
+	mode    os.FileMode
+	owner   string
+	group   string
+	content []byte
+}
+
+// Deploy renders a template for each selected host and uploads it where the file
+// differs, backing up the previous file and restarting the service of hosts whose
+// file changed. Files and their owners are compared on the host with stat, so the
+// hosts need GNU coreutils; the connecting user needs the permissions to write,
+// chown and restart.
+func (e *Executor) Deploy(ctx context.Context, opts DeployOptions) ([]DeployResult, error) {
+	if opts.Template == "" || opts.Destination == "" {
+		return nil, fmt.Errorf("template and destination are required")
+	}
+	source, err := os.ReadFile(inventory.ExpandPath(opts.Template))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	tmpl, err := template.New(filepath.Base(opts.Template)).Option("missingkey=error").Parse(string(source))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	if opts.Mode == 0 {
+		opts.Mode = DefaultDeployMode
+	}
+
+	targets, err := e.ResolveTargets(ExecOptions{HostIDs: opts.HostIDs, Groups: opts.Groups, Target: opts.Target})
+	if err != nil {
+		return nil, err
+	}
+	if opts.Restart != "" {
+		if err := e.checkPolicy(targets, ExecOptions{Command: serviceCommand("restart", opts.Restart)}); err != nil {
+			return nil, err
+		}
+	}
+
+	return forEachHost(ctx, targets, e.concurrency(opts.Concurrency), func(ctx context.Context, hostID string) DeployResult {
+		return e.deployHost(ctx, hostID, tmpl, opts)
+	}, func(hostID string, err error) DeployResult {
+		return DeployResult{HostID: hostID, Err: err}
+	}), nil
+}
+
+// deployHost renders and deploys the template on one host.
+func (e *Executor) deployHost(ctx context.Context, hostID string, tmpl *template.Template, opts DeployOptions) (r DeployResult) {
+	started := time.Now()
+	r.HostID = hostID
+	defer func() { r.Duration = time.Since(started) }()
+
+	data, err := e.templateData(hostID, opts.Inputs)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	var content bytes.Buffer
+	if err := tmpl.Execute(&content, data); err != nil {
+		r.Err = fmt.Errorf("host %s: failed to render template: %w", hostID, err)
+		return r
+	}
+
+	conn, err := e.manager.ResolveConnection(hostID)
+	if err == nil {
+		err = conn.ResolveSecrets()
+	}
+	if err != nil {
+		r.Err = redact.Error(err)
+		return r
+	}
+	redact.Default().AddConnection(conn)
+	e.applyTimeouts(conn, ExecOptions{Timeout: opts.Timeout})
+	if conn.Timeouts.Command > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conn.Timeouts.Command)
+		defer cancel()
+	}
+
+	current, err := e.statRemote(ctx, conn, opts.Destination)
+	if err != nil {
+		r.Err = fmt.Errorf("host %s: %w", hostID, err)
+		return r
+	}
+	contentChanged := !current.exists || !bytes.Equal(current.content, content.Bytes())
+	modeChanged := current.exists && current.mode != opts.Mode.Perm()
+	ownerChanged := (opts.Owner != "" && opts.Owner != current.owner) || (opts.Group != "" && opts.Group != current.group)
+	r.Changed = contentChanged || modeChanged || ownerChanged
+	if !r.Changed || opts.DryRun {
+		return r
+	}
+
+	dest := inventory.ShellQuote(opts.Destination)
+	if contentChanged {
+		uploader, ok := e.runnerFor(conn).(Uploader)
+		if !ok {
+			r.Err = ErrNoUploader
+			return r
+		}
+		if current.exists && !opts.NoBackup {
+			backup := opts.Destination + "." + started.UTC().Format("20060102T150405Z") + ".bak"
+			if _, err := e.step(ctx, conn, "cp -p -- "+dest+" "+inventory.ShellQuote(backup), 0); err != nil {
+				r.Err = fmt.Errorf("host %s: failed to back up %s: %w", hostID, opts.Destination, err)
+				return r
+			}
+			r.Backup = backup
+		}
+		if err := uploader.Upload(ctx, conn, bytes.NewReader(content.Bytes()), opts.Destination, opts.Mode); err != nil {
+			r.Err = redact.Error(err)
+			return r
+		}
+	}
+	if modeChanged {
+		mode := strconv.FormatUint(uint64(opts.Mode.Perm()), 8)
+		if _, err := e.step(ctx, conn, "chmod "+mode+" -- "+dest, 0); err != nil {
+			r.Err = fmt.Errorf("host %s: failed to chmod %s: %w", hostID, opts.Destination, err)
+			return r
+		}
+	}
+	if owner := ownerSpec(opts.Owner, opts.Group); owner != "" {
+		if _, err := e.step(ctx, conn, "chown "+inventory.ShellQuote(owner)+" -- "+dest, 0); err != nil {
+			r.Err = fmt.Errorf("host %s: failed to chown %s: %w", hostID, opts.Destination, err)
+			return r
+		}
+	}
+
+	if opts.Restart != "" {
+		if _, err := e.step(ctx, conn, serviceCommand("restart", opts.Restart), 0); err != nil {
+			r.Err = fmt.Errorf("host %s: failed to restart %s: %w", hostID, opts.Restart, err)
+			return r
+		}
+		r.Restarted = true
+	}
+	return r
+}
+
+// statRemote reads the mode, owner and content of a remote file. A missing file
+// is not an error.
+func (e *Executor) statRemote(ctx context.Context, conn *inventory.ResolvedConnection, path string) (remoteFile, error) {
+	quoted := inventory.ShellQuote(path)
+	out, err := e.step(ctx, conn, "if [ -e "+quoted+" ]; then stat -c '%a %U %G' -- "+quoted+"; fi", 0)
+	if err != nil {
+		return remoteFile{}, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return remoteFile{}, nil
+	}
+	if len(fields) != 3 {
+		return remoteFile{}, fmt.Errorf("unexpected stat output for %s: %q", path, strings.TrimSpace(out))
+	}
+	mode, err := strconv.ParseUint(fields[0], 8, 32)
+	if err != nil {
+		return remoteFile{}, fmt.Errorf("unexpected stat output for %s: %q", path, strings.TrimSpace(out))
+	}
+
+	f := remoteFile{exists: true, mode: os.FileMode(mode).Perm(), owner: fields[1], group: fields[2]}
+	var content bytes.Buffer
+	if err := e.download(ctx, conn, path, &content); err != nil {
+		return remoteFile{}, err
+	}
+	f.content = content.Bytes()
+	return f, nil
+}
+
+// ownerSpec returns the chown argument for an owner and group, either optional.
+func ownerSpec(owner, group string) string {
+	if group == "" {
+		return owner
+	}
+	return owner + ":" + group
+}
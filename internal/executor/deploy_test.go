@@ -0,0 +1,151 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// restartRecorder is a LocalRunner that records systemctl commands instead of
+// running them.
+type restartRecorder struct {
+	LocalRunner
+	restarts []string
+}
+
+func (r *restartRecorder) Run(ctx context.Context, conn *inventory.ResolvedConnection, command string, stdout, stderr io.Writer) (int, error) {
+	if strings.HasPrefix(command, "systemctl ") {
+		r.restarts = append(r.restarts, command)
+		return 0, nil
+	}
+	return r.LocalRunner.Run(ctx, conn, command, stdout, stderr)
+}
+
+func TestDeploy(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("uses GNU stat")
+	}
+
+	setup := func(t *testing.T) (*Executor, *restartRecorder, string) {
+		e, _ := setupExecutor(t)
+		build := inventory.NewLocalHost("build", "build")
+		build.Vars = map[string]string{"port": "8080"}
+		require.NoError(t, e.manager.AddHost(build))
+		recorder := &restartRecorder{}
+		e.local = recorder
+
+		dir := t.TempDir()
+		tmpl := filepath.Join(dir, "app.conf.tmpl")
+		require.NoError(t, os.WriteFile(tmpl, []byte("host={{.HostID}}\nport={{.Vars.port}}\n"), 0o600))
+		return e, recorder, dir
+	}
+
+	t.Run("renders, backs up and restarts on change", func(t *testing.T) {
+		e, recorder, dir := setup(t)
+		dest := filepath.Join(dir, "app.conf")
+		opts := DeployOptions{
+			HostIDs:     []string{"build"},
+			Template:    filepath.Join(dir, "app.conf.tmpl"),
+			Destination: dest,
+			Mode:        0o640,
+			Restart:     "app",
+		}
+
+		results, err := e.Deploy(context.Background(), opts)
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+		assert.True(t, results[0].Changed)
+		assert.Empty(t, results[0].Backup, "nothing to back up")
+		assert.True(t, results[0].Restarted)
+		data, err := os.ReadFile(dest)
+		require.NoError(t, err)
+		assert.Equal(t, "host=build\nport=8080\n", string(data))
+		info, err := os.Stat(dest)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+
+		results, err = e.Deploy(context.Background(), opts)
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+		assert.False(t, results[0].Changed)
+		assert.False(t, results[0].Restarted)
+		assert.Equal(t, []string{"systemctl restart -- app"}, recorder.restarts)
+
+		require.NoError(t, os.WriteFile(dest, []byte("edited\n"), 0o640))
+		results, err = e.Deploy(context.Background(), opts)
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+		assert.True(t, results[0].Changed)
+		require.NotEmpty(t, results[0].Backup)
+		backup, err := os.ReadFile(results[0].Backup)
+		require.NoError(t, err)
+		assert.Equal(t, "edited\n", string(backup))
+		assert.Len(t, recorder.restarts, 2)
+	})
+
+	t.Run("mode changes and dry runs", func(t *testing.T) {
+		e, recorder, dir := setup(t)
+		dest := filepath.Join(dir, "app.conf")
+		require.NoError(t, os.WriteFile(dest, []byte("host=build\nport=8080\n"), 0o600))
+		opts := DeployOptions{
+			HostIDs:     []string{"build"},
+			Template:    filepath.Join(dir, "app.conf.tmpl"),
+			Destination: dest,
+			NoBackup:    true,
+			DryRun:      true,
+		}
+
+		results, err := e.Deploy(context.Background(), opts)
+		require.NoError(t, err)
+		assert.True(t, results[0].Changed)
+		info, err := os.Stat(dest)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "dry runs change nothing")
+
+		opts.DryRun = false
+		results, err = e.Deploy(context.Background(), opts)
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+		assert.True(t, results[0].Changed)
+		assert.Empty(t, results[0].Backup)
+		info, err = os.Stat(dest)
+		require.NoError(t, err)
+		assert.Equal(t, DefaultDeployMode, info.Mode().Perm())
+		assert.Empty(t, recorder.restarts)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		e, _, dir := setup(t)
+		_, err := e.Deploy(context.Background(), DeployOptions{HostIDs: []string{"build"}, Destination: "/tmp/x"})
+		assert.Error(t, err)
+		_, err = e.Deploy(context.Background(), DeployOptions{
+			HostIDs: []string{"build"}, Template: filepath.Join(dir, "missing"), Destination: "/tmp/x",
+		})
+		assert.Error(t, err)
+
+		bad := filepath.Join(dir, "bad.tmpl")
+		require.NoError(t, os.WriteFile(bad, []byte("{{.Vars.missing}}"), 0o600))
+		results, err := e.Deploy(context.Background(), DeployOptions{
+			HostIDs: []string{"build"}, Template: bad, Destination: filepath.Join(dir, "out"),
+		})
+		require.NoError(t, err)
+		assert.Error(t, results[0].Err)
+		assert.NoFileExists(t, filepath.Join(dir, "out"))
+	})
+}
+
+func TestOwnerSpec(t *testing.T) {
+	assert.Equal(t, "", ownerSpec("", ""))
+	assert.Equal(t, "app", ownerSpec("app", ""))
+	assert.Equal(t, "app:www", ownerSpec("app", "www"))
+	assert.Equal(t, ":www", ownerSpec("", "www"))
+}
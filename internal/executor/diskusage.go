@@ -0,0 +1,207 @@
+package executor
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gossher/internal/inventory"
+)
+
+// DefaultDiskCacheTTL is how long DiskExplorer reuses a scanned directory.
+const DefaultDiskCacheTTL = 5 * time.Minute
+
+// DirEntry is a file or directory inside a scanned directory.
+type DirEntry struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// Size is the disk usage in bytes, of the whole tree for directories.
+	Size int64 `json:"size"`
+	Dir  bool  `json:"dir"`
+}
+
+// DirUsage is the disk usage of a directory and its entries, largest first.
+type DirUsage struct {
+	HostID  string     `json:"host_id"`
+	Path    string     `json:"path"`
+	Size    int64      `json:"size"`
+	Entries []DirEntry `json:"entries"`
+	Scanned time.Time  `json:"scanned"`
+}
+
+// Parent returns the path of the parent directory, empty for the root.
+func (u *DirUsage) Parent() string {
+	if u.Path == "/" {
+		return ""
+	}
+	return path.Dir(u.Path)
+}
+
+// DiskExplorer finds what fills a disk: it scans one directory level at a time
+// with du on the host, so that a UI can drill down into the largest entries, and
+// caches the scans per host and path. du stays on the file system of the scanned
+// directory.
+type DiskExplorer struct {
+	executor *Executor
+	// TTL is how long scans are reused (default DefaultDiskCacheTTL).
+	TTL time.Duration
+	// Sudo scans with "sudo -n", so that directories of other users count.
+	Sudo bool
+	// Now returns the current time (default time.Now).
+	Now func() time.Time
+
+	mu    sync.Mutex
+	cache map[diskKey]*DirUsage
+}
+
+// diskKey identifies a scanned directory.
+type diskKey struct {
+	hostID string
+	path   string
+}
+
+// NewDiskExplorer creates an explorer scanning through e.
+func NewDiskExplorer(e *Executor) *DiskExplorer {
+	return &DiskExplorer{executor: e, cache: make(map[diskKey]*DirUsage)}
+}
+
+// Usage returns the disk usage of a directory of a host, from the cache when it
+// was scanned less than TTL ago. dir must be absolute.
+func (x *DiskExplorer) Usage(ctx context.Context, hostID, dir string) (*DirUsage, error) {
+	dir, err := cleanDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	x.mu.Lock()
+	u, ok := x.cache[diskKey{hostID, dir}]
+	x.mu.Unlock()
+	if ok && x.now().Sub(u.Scanned) < cmp.Or(x.TTL, DefaultDiskCacheTTL) {
+		return u, nil
+	}
+	return x.Refresh(ctx, hostID, dir)
+}
+
+// Refresh scans a directory of a host, replacing its cached scan. The cached scans
+// of the directories above it are dropped, as their sizes include it.
+func (x *DiskExplorer) Refresh(ctx context.Context, hostID, dir string) (*DirUsage, error) {
+	dir, err := cleanDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	quoted := inventory.ShellQuote(dir)
+	command := "{ du -xak -d 1 -- " + quoted + " 2>/dev/null; echo '#dirs'; find " + quoted + " -mindepth 1 -maxdepth 1 -type d 2>/dev/null; true; }"
+	if x.Sudo {
+		command = "sudo -n sh -c " + inventory.ShellQuote(command)
+	}
+	results, err := x.executor.Exec(ctx, ExecOptions{Command: command, HostIDs: []string{hostID}})
+	if err != nil {
+		return nil, err
+	}
+	r := results[0]
+	switch {
+	case r.Err != nil:
+		return nil, r.Err
+	case r.ExitCode != 0:
+		return nil, fmt.Errorf("host %s: du exited with status %d: %s", hostID, r.ExitCode, strings.TrimSpace(r.Stderr))
+	}
+
+	u, err := parseDiskUsage(r.Stdout, dir)
+	if err != nil {
+		return nil, fmt.Errorf("host %s: %w", hostID, err)
+	}
+	u.HostID, u.Scanned = hostID, x.now()
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for p := dir; p != "/"; {
+		p = path.Dir(p)
+		delete(x.cache, diskKey{hostID, p})
+	}
+	x.cache[diskKey{hostID, dir}] = u
+	return u, nil
+}
+
+// Invalidate drops the cached scans of a host, all of them when dir is empty,
+// otherwise those of dir and of the directories above and below it.
+func (x *DiskExplorer) Invalidate(hostID, dir string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	dir = path.Clean(dir)
+	for key := range x.cache {
+		if key.hostID != hostID {
+			continue
+		}
+		if dir == "." || isPathWithin(key.path, dir) || isPathWithin(dir, key.path) {
+			delete(x.cache, key)
+		}
+	}
+}
+
+func (x *DiskExplorer) now() time.Time {
+	if x.Now != nil {
+		return x.Now()
+	}
+	return time.Now()
+}
+
+// cleanDir validates and cleans an absolute directory path.
+func cleanDir(dir string) (string, error) {
+	if !strings.HasPrefix(dir, "/") {
+		return "", fmt.Errorf("path %q is not absolute", dir)
+	}
+	return path.Clean(dir), nil
+}
+
+// isPathWithin reports whether p is dir or below it.
+func isPathWithin(p, dir string) bool {
+	return p == dir || dir == "/" || strings.HasPrefix(p, dir+"/")
+}
+
+// parseDiskUsage parses the du output of a directory followed by "#dirs" and the
+// directories inside it.
+func parseDiskUsage(out, dir string) (*DirUsage, error) {
+	sizes, dirList, _ := strings.Cut(out, "#dirs\n")
+	dirs := make(map[string]bool)
+	for _, line := range strings.Split(dirList, "\n") {
+		if line != "" {
+			dirs[path.Clean(line)] = true
+		}
+	}
+
+	u := &DirUsage{Path: dir, Entries: []DirEntry{}}
+	found := false
+	for _, line := range strings.Split(sizes, "\n") {
+		kb, p, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		size, err := strconv.ParseInt(kb, 10, 64)
+		if err != nil {
+			continue
+		}
+		p = path.Clean(p)
+		if p == dir {
+			u.Size, found = size*1024, true
+			continue
+		}
+		if path.Dir(p) != dir {
+			continue
+		}
+		u.Entries = append(u.Entries, DirEntry{Name: path.Base(p), Path: p, Size: size * 1024, Dir: dirs[p]})
+	}
+	if !found {
+		return nil, fmt.Errorf("cannot read %s", dir)
+	}
+	slices.SortFunc(u.Entries, func(a, b DirEntry) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), strings.Compare(a.Name, b.Name))
+	})
+	return u, nil
+}
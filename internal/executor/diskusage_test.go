@@ -0,0 +1,100 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDiskUsage(t *testing.T) {
+	out := "4\t/var/log/empty\n120\t/var/log/syslog\n2048\t/var/log/journal\n2180\t/var/log\n#dirs\n/var/log/empty\n/var/log/journal\n"
+	u, err := parseDiskUsage(out, "/var/log")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2180*1024), u.Size)
+	assert.Equal(t, []DirEntry{
+		{Name: "journal", Path: "/var/log/journal", Size: 2048 * 1024, Dir: true},
+		{Name: "syslog", Path: "/var/log/syslog", Size: 120 * 1024},
+		{Name: "empty", Path: "/var/log/empty", Size: 4 * 1024, Dir: true},
+	}, u.Entries)
+	assert.Equal(t, "/var", u.Parent())
+
+	_, err = parseDiskUsage("#dirs\n", "/root")
+	assert.Error(t, err, "unreadable directories have no total")
+}
+
+func TestDiskExplorer(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("uses GNU du")
+	}
+
+	e, _ := setupExecutor(t)
+	require.NoError(t, e.manager.AddHost(inventory.NewLocalHost("build", "build")))
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "big", "nested"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "big", "nested", "blob"), make([]byte, 256*1024), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "small"), make([]byte, 10), 0o600))
+
+	now := time.Now()
+	x := NewDiskExplorer(e)
+	x.Now = func() time.Time { return now }
+
+	u, err := x.Usage(context.Background(), "build", dir+"/")
+	require.NoError(t, err)
+	assert.Equal(t, dir, u.Path)
+	require.Len(t, u.Entries, 2)
+	assert.Equal(t, "big", u.Entries[0].Name)
+	assert.True(t, u.Entries[0].Dir)
+	assert.GreaterOrEqual(t, u.Entries[0].Size, int64(256*1024))
+	assert.False(t, u.Entries[1].Dir)
+
+	big, err := x.Usage(context.Background(), "build", u.Entries[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, "nested", big.Entries[0].Name)
+
+	t.Run("caches until the TTL", func(t *testing.T) {
+		// Scanning big dropped the scan of its parent.
+		_, err := x.Usage(context.Background(), "build", dir)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "new"), make([]byte, 10), 0o600))
+		cached, err := x.Usage(context.Background(), "build", dir)
+		require.NoError(t, err)
+		assert.Len(t, cached.Entries, 2)
+		assert.True(t, cached.Scanned.Equal(now))
+
+		now = now.Add(DefaultDiskCacheTTL)
+		fresh, err := x.Usage(context.Background(), "build", dir)
+		require.NoError(t, err)
+		assert.Len(t, fresh.Entries, 3)
+	})
+
+	t.Run("refresh drops the parents", func(t *testing.T) {
+		_, err := x.Usage(context.Background(), "build", dir)
+		require.NoError(t, err)
+		_, err = x.Refresh(context.Background(), "build", filepath.Join(dir, "big"))
+		require.NoError(t, err)
+		x.mu.Lock()
+		_, cached := x.cache[diskKey{"build", dir}]
+		x.mu.Unlock()
+		assert.False(t, cached)
+
+		x.Invalidate("build", dir)
+		x.mu.Lock()
+		assert.Empty(t, x.cache)
+		x.mu.Unlock()
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := x.Usage(context.Background(), "build", "relative/path")
+		assert.Error(t, err)
+		_, err = x.Usage(context.Background(), "build", filepath.Join(dir, "missing"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,616 @@
+// Package executor runs commands on inventory hosts concurrently.
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gossher/internal/events"
+	"gossher/internal/inventory"
+	"gossher/internal/progress"
+	"gossher/internal/redact"
+	"gossher/internal/transport"
+)
+
+// Ensure transport.Runner implements the interfaces
+var (
+	_ Runner     = transport.Runner{}
+	_ Uploader   = transport.Runner{}
+	_ Downloader = transport.Runner{}
+)
+
+// DefaultConcurrency is the number of hosts a command runs on at the same time.
+const DefaultConcurrency = 10
+
+// ErrCommandBlocked is returned when the command policy blocks a command.
+var ErrCommandBlocked = errors.New("command blocked by policy")
+
+// Runner runs a single command on a resolved connection.
+type Runner interface {
+	Run(ctx context.Context, conn *inventory.ResolvedConnection, command string, stdout, stderr io.Writer) (exitCode int, err error)
+}
+
+// ExecOptions describes a command execution.
+type ExecOptions struct {
+	Command string
+
+	// HostIDs and Groups select the targets; group members include nested groups.
+	HostIDs []string
+	Groups  []string
+	// Target adds the hosts of a target spec such as "group:web + tag:canary - host:web03";
+	// see inventory.TargetSpec.
+	Target string
+	// IncludePassive keeps the passive (priority 0) members of Groups, which are
+	// left out by default. ByPriority orders the targets by descending group
+	// priority, e.g. to run on primaries before standbys; hosts not selected
+	// through a group have inventory.DefaultPriority. Hosts start in target order,
+	// so with a Concurrency of 1 each host finishes before the next one starts.
+	IncludePassive bool
+	ByPriority     bool
+	// OnePerGroup runs on a single host of each of Groups, picked as it says,
+	// instead of on all their members. With ByPriority the first pick is the
+	// member with the highest priority.
+	OnePerGroup GroupPick
+
+	// Concurrency limits parallel hosts (default DefaultConcurrency).
+	Concurrency int
+
+	// Timeout (the command timeout) limits each host's run, ConnectTimeout each
+	// connection and TotalTimeout the whole run. Zero inherits the timeout from
+	// CommandTimeouts, then the host, then the executor's defaults; see
+	// inventory.Timeouts. Unset everywhere means no limit.
+	Timeout        time.Duration
+	ConnectTimeout time.Duration
+	TotalTimeout   time.Duration
+	// CommandTimeouts are the timeouts of the saved command being run.
+	CommandTimeouts inventory.Timeouts
+
+	// Force runs commands blocked by the command policy. When Confirm is set it is
+	// asked with the violations first and the run is aborted unless it returns true.
+	Force   bool
+	Confirm func(violations []inventory.CommandViolation) bool
+
+	// DryRun plans the execution without running the command. Each result carries
+	// its HostPlan; use Executor.Plan for the complete plan.
+	DryRun bool
+	// SkipChecks skips connectivity and sudo checks during a dry run.
+	SkipChecks bool
+
+	// Preflight checks run on each host before the command; hosts failing them are
+	// skipped or, with PreflightFail, reported as failed.
+	Preflight          []inventory.Preflight
+	OnPreflightFailure inventory.PreflightAction
+
+	// Inputs are the values of {{.Inputs.name}} in the command. ExecSaved resolves the
+	// inputs the saved command declares from them, asking Prompt for missing ones.
+	Inputs map[string]string
+	Prompt inventory.InputPrompt
+
+	// Extractors derive structured values from the output of successful runs.
+	Extractors []Extractor
+
+	// Snapshot captures the given paths and services on each host before and after
+	// the command; the differences are in Result.Snapshot and the run's history.
+	Snapshot *inventory.Snapshot
+
+	// HistoryDir, when set, keeps a log of the run: each host's output is written to
+	// HistoryDir/<run ID>/<host>.log as it finishes and the exit codes are summarized
+	// in RunIndexFile next to them. Use filepath.Join(dataDir, HistoryDir).
+	HistoryDir string
+
+	// Progress receives the progress of the run, counted in hosts.
+	Progress progress.Reporter
+}
+
+// Result is the outcome of a command on one host.
+type Result struct {
+	HostID   string
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Err      error
+	Started  time.Time
+	Duration time.Duration
+
+	// Values holds the outputs of ExecOptions.Extractors by name.
+	Values map[string]any
+
+	// Skipped is set when the host did not pass its pre-flight checks; Err holds the reason.
+	Skipped bool
+
+	// Plan is set instead of the output fields for dry runs.
+	Plan *HostPlan
+
+	// Snapshot is what changed on the host during runs with ExecOptions.Snapshot.
+	Snapshot *SnapshotDiff
+
+	// RunID and LogFile locate the log of runs with ExecOptions.HistoryDir.
+	RunID   string
+	LogFile string
+}
+
+// OK reports whether the command ran and exited with status 0.
+func (r *Result) OK() bool {
+	return r.Err == nil && r.ExitCode == 0 && !r.Skipped
+}
+
+// Failed reports whether the command failed; skipped hosts did not fail.
+func (r *Result) Failed() bool {
+	return !r.Skipped && !r.OK()
+}
+
+// BlockedError lists the policy violations that stopped an execution.
+type BlockedError struct {
+	Violations []inventory.CommandViolation
+	// Declined is set when the command was forced but the confirmation was declined.
+	Declined bool
+}
+
+func (e *BlockedError) Error() string {
+	lines := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		lines[i] = v.String()
+	}
+	msg := ErrCommandBlocked.Error()
+	if e.Declined {
+		msg += " (confirmation declined)"
+	}
+	return msg + ": " + strings.Join(lines, "; ")
+}
+
+func (e *BlockedError) Unwrap() error {
+	return ErrCommandBlocked
+}
+
+// Executor runs commands on hosts of an inventory.
+type Executor struct {
+	manager *inventory.Manager
+	runner  Runner
+	local   Runner
+
+	mu       sync.RWMutex
+	policy   *inventory.CommandPolicy
+	timeouts inventory.Timeouts
+	tuning   inventory.ExecutorTuning
+	bus      *events.Bus
+}
+
+// New creates an Executor for the inventory using runner to reach hosts. Local
+// hosts run commands with LocalRunner. Events are published to events.Default().
+func New(m *inventory.Manager, runner Runner) *Executor {
+	return &Executor{manager: m, runner: runner, local: LocalRunner{}, bus: events.Default()}
+}
+
+// NewWithDialer creates an Executor that reaches hosts with sessions of d, one per
+// command or file transfer; see transport.Runner. Before each session is dialed,
+// the networks the host requires are checked and its port knocking sequence sent.
+// Connection statistics are recorded in m (see inventory.Manager.LinkStats).
+func NewWithDialer(m *inventory.Manager, d transport.Dialer) *Executor {
+	return New(m, transport.Runner{
+		Dialer: transport.NetworkDialer{Dialer: transport.KnockDialer{Dialer: d}},
+		Stats:  m,
+	})
+}
+
+// SetEventBus sets the bus exec and authentication events are published to. Nil
+// disables publishing.
+func (e *Executor) SetEventBus(b *events.Bus) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.bus = b
+}
+
+// publish sends an event to the configured bus, if any.
+func (e *Executor) publish(ev events.Event) {
+	e.mu.RLock()
+	b := e.bus
+	e.mu.RUnlock()
+	b.Publish(ev)
+}
+
+// SetCommandPolicy sets the policy enforced by Exec. Nil disables enforcement.
+func (e *Executor) SetCommandPolicy(p *inventory.CommandPolicy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.policy = p
+}
+
+// SetTimeouts sets the global default timeouts, usually inventory.GetTimeouts().
+func (e *Executor) SetTimeouts(t inventory.Timeouts) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.timeouts = t
+}
+
+// SetTuning sets the performance knobs, usually inventory.GetExecutorTuning().
+func (e *Executor) SetTuning(t inventory.ExecutorTuning) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tuning = t
+}
+
+// concurrency returns the number of parallel hosts of a run that asks for n: n
+// itself, else the tuned concurrency, else DefaultConcurrency.
+func (e *Executor) concurrency(n int) int {
+	if n > 0 {
+		return n
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.tuning.Concurrency > 0 {
+		return e.tuning.Concurrency
+	}
+	return DefaultConcurrency
+}
+
+// resolveTimeouts returns the effective timeouts of a run on a host with the given
+// timeouts; pass the zero value for settings that are not per host.
+func (e *Executor) resolveTimeouts(opts ExecOptions, host inventory.Timeouts) inventory.Timeouts {
+	e.mu.RLock()
+	defaults := e.timeouts
+	e.mu.RUnlock()
+
+	invocation := inventory.Timeouts{
+		Connect: opts.ConnectTimeout,
+		Command: opts.Timeout,
+		Total:   opts.TotalTimeout,
+	}
+	return inventory.ResolveTimeouts(invocation, opts.CommandTimeouts, host, defaults)
+}
+
+// applyTimeouts replaces the host timeouts of a connection and its jump hosts with
+// the effective ones.
+func (e *Executor) applyTimeouts(conn *inventory.ResolvedConnection, opts ExecOptions) {
+	for _, j := range conn.Jumps {
+		j.Timeouts = e.resolveTimeouts(opts, j.Timeouts)
+		j.Timeouts.Total = 0
+	}
+	conn.Timeouts = e.resolveTimeouts(opts, conn.Timeouts)
+	conn.Timeouts.Total = 0
+}
+
+// ResolveTargets returns the IDs of the selected hosts in selection order, or by
+// priority with ByPriority, without duplicates.
+func (e *Executor) ResolveTargets(opts ExecOptions) ([]string, error) {
+	var targets []string
+	seen := map[string]bool{}
+
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			targets = append(targets, id)
+		}
+	}
+
+	if err := opts.OnePerGroup.Validate(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range opts.HostIDs {
+		if _, ok := e.manager.GetHost(id); !ok {
+			return nil, fmt.Errorf("host %s not found", id)
+		}
+		add(id)
+	}
+	priorities := map[string]int{}
+	for _, name := range opts.Groups {
+		members, err := e.manager.ResolveGroupMembers(name)
+		if err != nil {
+			return nil, err
+		}
+		if !opts.IncludePassive {
+			members = slices.DeleteFunc(members, inventory.GroupMember.Passive)
+		}
+		if opts.OnePerGroup != "" {
+			members = slices.DeleteFunc(members, func(gm inventory.GroupMember) bool {
+				_, ok := e.manager.GetHost(gm.HostID)
+				return !ok
+			})
+		}
+		if opts.OnePerGroup != "" && len(members) > 0 {
+			if opts.ByPriority {
+				inventory.SortMembersByPriority(members)
+			}
+			id := e.pickMember(members, opts.OnePerGroup)
+			members = slices.DeleteFunc(members, func(gm inventory.GroupMember) bool { return gm.HostID != id })
+		}
+		for _, gm := range members {
+			if p, ok := priorities[gm.HostID]; !ok || gm.Priority > p {
+				priorities[gm.HostID] = gm.Priority
+			}
+			add(gm.HostID)
+		}
+	}
+	if opts.Target != "" {
+		ids, err := e.manager.ResolveTargetSpec(opts.Target)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			add(id)
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no target hosts selected")
+	}
+	if opts.ByPriority {
+		priority := func(id string) int {
+			if p, ok := priorities[id]; ok {
+				return p
+			}
+			return inventory.DefaultPriority
+		}
+		slices.SortStableFunc(targets, func(a, b string) int {
+			return priority(b) - priority(a)
+		})
+	}
+	return targets, nil
+}
+
+// violations checks the command as rendered for each target against the policy.
+func (e *Executor) violations(targets []string, opts ExecOptions) ([]inventory.CommandViolation, error) {
+	e.mu.RLock()
+	policy := e.policy
+	e.mu.RUnlock()
+
+	if policy.IsEmpty() {
+		return nil, nil
+	}
+
+	var violations []inventory.CommandViolation
+	for _, id := range targets {
+		command, err := e.renderCommand(opts.Command, id, opts.Inputs)
+		if err != nil {
+			// Rendering errors are reported per host; check the raw command meanwhile
+			command = opts.Command
+		}
+		v, err := e.manager.CheckCommand(policy, []string{id}, command)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, v...)
+	}
+	return violations, nil
+}
+
+// checkPolicy enforces the command policy on all targets before anything runs.
+func (e *Executor) checkPolicy(targets []string, opts ExecOptions) error {
+	violations, err := e.violations(targets, opts)
+	if err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	if !opts.Force {
+		return &BlockedError{Violations: violations}
+	}
+	if opts.Confirm != nil && !opts.Confirm(violations) {
+		return &BlockedError{Violations: violations, Declined: true}
+	}
+	return nil
+}
+
+// Exec runs the command on every target and returns one result per host in target
+// order. Failures on single hosts are reported in their results; the returned error
+// is for problems that prevent the run as a whole.
+func (e *Executor) Exec(ctx context.Context, opts ExecOptions) ([]Result, error) {
+	if strings.TrimSpace(opts.Command) == "" {
+		return nil, fmt.Errorf("command cannot be empty")
+	}
+
+	if opts.DryRun {
+		return e.dryRun(ctx, opts)
+	}
+
+	targets, err := e.ResolveTargets(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.checkPolicy(targets, opts); err != nil {
+		return nil, err
+	}
+
+	var log *runLog
+	if opts.HistoryDir != "" {
+		if log, err = newRunLog(opts.HistoryDir, targets, opts); err != nil {
+			return nil, err
+		}
+	}
+	return e.execTargets(ctx, targets, opts, log)
+}
+
+// execTargets runs the command on the targets, recording results in log if it is set.
+func (e *Executor) execTargets(ctx context.Context, targets []string, opts ExecOptions, log *runLog) ([]Result, error) {
+	concurrency := e.concurrency(opts.Concurrency)
+	if total := e.resolveTimeouts(opts, inventory.Timeouts{}).Total; total > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, total)
+		defer cancel()
+	}
+
+	task := progress.Start(opts.Progress, "exec: "+redact.String(opts.Command), progress.UnitHosts, int64(len(targets)))
+	// done reports and logs each result as its host finishes.
+	done := func(r Result) Result {
+		if log != nil {
+			if err := log.record(&r, ctx.Err() != nil); err != nil && r.Err == nil {
+				r.Err = err
+			}
+		}
+		reportResult(task, &r)
+		return r
+	}
+	results := forEachHost(ctx, targets, concurrency, func(ctx context.Context, hostID string) Result {
+		return done(e.runHost(ctx, hostID, opts))
+	}, func(hostID string, err error) Result {
+		return done(Result{HostID: hostID, Err: err})
+	})
+
+	if log != nil {
+		if err := log.finish(); err != nil {
+			task.Finish(err)
+			return results, err
+		}
+	}
+	task.Finish(ctx.Err())
+	return results, nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it, or everything if limit
+// is zero, and counts the rest.
+type limitedBuffer struct {
+	buf     bytes.Buffer
+	limit   int
+	dropped int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if b.limit > 0 {
+		if room := b.limit - b.buf.Len(); room < len(p) {
+			b.dropped += len(p) - max(room, 0)
+			p = p[:max(room, 0)]
+		}
+	}
+	b.buf.Write(p)
+	return n, nil
+}
+
+// String returns the kept output with a note about the dropped bytes.
+func (b *limitedBuffer) String() string {
+	if b.dropped == 0 {
+		return b.buf.String()
+	}
+	return fmt.Sprintf("%s\n[%d bytes of output dropped]", b.buf.String(), b.dropped)
+}
+
+// reportResult reports the outcome of a host to a progress task.
+func reportResult(task *progress.Task, r *Result) {
+	switch {
+	case r.Skipped:
+		task.Host(r.HostID, progress.HostSkipped, r.Err)
+	case r.OK():
+		task.Host(r.HostID, progress.HostOK, nil)
+	case r.Err != nil:
+		task.Host(r.HostID, progress.HostFailed, r.Err)
+	default:
+		task.Host(r.HostID, progress.HostFailed, fmt.Errorf("exit code %d", r.ExitCode))
+	}
+}
+
+// dryRun plans the execution and reports the plan of each host as its result.
+func (e *Executor) dryRun(ctx context.Context, opts ExecOptions) ([]Result, error) {
+	plan, err := e.Plan(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Blocked {
+		return nil, &BlockedError{Violations: plan.Violations}
+	}
+
+	results := make([]Result, len(plan.Hosts))
+	for i := range plan.Hosts {
+		hp := plan.Hosts[i]
+		results[i] = Result{HostID: hp.HostID, Plan: &hp}
+		if hp.Error != "" {
+			results[i].ExitCode = -1
+			results[i].Err = errors.New(hp.Error)
+		}
+	}
+	return results, nil
+}
+
+// runHost runs the command on one host.
+func (e *Executor) runHost(ctx context.Context, hostID string, opts ExecOptions) (r Result) {
+	r = Result{HostID: hostID, ExitCode: -1, Started: time.Now()}
+	defer func() { r.Duration = time.Since(r.Started) }()
+
+	conn, err := e.manager.ResolveConnection(hostID)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	if err := conn.ResolveSecrets(); err != nil {
+		r.Err = redact.Error(err)
+		return r
+	}
+	redact.Default().AddConnection(conn)
+
+	e.applyTimeouts(conn, opts)
+	// Snapshots are not limited by the command timeout so that the state after a
+	// timed out command is still captured.
+	snapshotCtx := ctx
+	if conn.Timeouts.Command > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conn.Timeouts.Command)
+		defer cancel()
+	}
+
+	command, err := e.renderCommand(opts.Command, hostID, opts.Inputs)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	if len(opts.Preflight) > 0 {
+		if err := e.runPreflight(ctx, conn, opts.Preflight); err != nil {
+			r.Err = redact.Error(err)
+			r.Skipped = opts.OnPreflightFailure != inventory.PreflightFail && errors.Is(err, ErrPreflightFailed)
+			return r
+		}
+	}
+
+	if opts.Snapshot != nil {
+		r.Snapshot = &SnapshotDiff{HostID: hostID}
+		if r.Snapshot.Before, err = e.takeSnapshot(snapshotCtx, conn, opts.Snapshot); err != nil {
+			r.Snapshot.Error = "before: " + err.Error()
+		}
+	}
+
+	logged := redact.String(command)
+	e.publish(events.Event{Type: events.ExecStarted, HostID: hostID, Command: logged})
+
+	e.mu.RLock()
+	limit := e.tuning.MaxOutput
+	e.mu.RUnlock()
+	stdout, stderr := &limitedBuffer{limit: limit}, &limitedBuffer{limit: limit}
+	r.ExitCode, err = e.runnerFor(conn).Run(ctx, conn, command, stdout, stderr)
+	if err != nil {
+		r.Err = redact.Error(err)
+	}
+	r.Stdout = redact.String(stdout.String())
+	r.Stderr = redact.String(stderr.String())
+
+	if r.Snapshot != nil && r.Snapshot.Error == "" {
+		if after, err := e.takeSnapshot(snapshotCtx, conn, opts.Snapshot); err != nil {
+			r.Snapshot.Error = "after: " + err.Error()
+		} else {
+			r.Snapshot = diffSnapshots(r.Snapshot.Before, after)
+		}
+	}
+
+	finished := events.Event{
+		Type:     events.ExecFinished,
+		HostID:   hostID,
+		Command:  logged,
+		ExitCode: r.ExitCode,
+		Duration: time.Since(r.Started),
+	}
+	if r.Err != nil {
+		finished.Error = r.Err.Error()
+	}
+	e.publish(finished)
+
+	if r.Err == nil && r.ExitCode == 0 {
+		extract(&r, opts.Extractors)
+	}
+	return r
+}
@@ -0,0 +1,413 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"gossher/internal/events"
+	"gossher/internal/inventory"
+	"gossher/internal/progress"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRunner echoes the command and records which hosts it ran on.
+type fakeRunner struct {
+	mu   sync.Mutex
+	ran  []string
+	fail map[string]int
+}
+
+func (r *fakeRunner) Run(ctx context.Context, conn *inventory.ResolvedConnection, command string, stdout, stderr io.Writer) (int, error) {
+	r.mu.Lock()
+	r.ran = append(r.ran, conn.HostID)
+	r.mu.Unlock()
+
+	if code, ok := r.fail[conn.HostID]; ok {
+		fmt.Fprintf(stderr, "failed on %s", conn.HostID)
+		return code, nil
+	}
+	fmt.Fprintf(stdout, "%s@%s: %s", conn.User, conn.HostID, command)
+	return 0, nil
+}
+
+func setupExecutor(t *testing.T) (*Executor, *fakeRunner) {
+	m := inventory.NewManager(t.TempDir())
+	require.NoError(t, m.Load())
+
+	for _, id := range []string{"web01", "web02", "db01"} {
+		h := inventory.NewHost(id, id, "10.0.0.1")
+		h.User = "deploy"
+		h.Password = "hunter22"
+		if id == "db01" {
+			h.AddTag("env:prod")
+		}
+		require.NoError(t, m.AddHost(h))
+	}
+
+	web := inventory.NewGroup("web")
+	web.AddHost("web01")
+	web.AddHost("web02")
+	require.NoError(t, m.AddGroup(web))
+
+	all := inventory.NewGroup("all")
+	all.AddChildGroup("web")
+	all.AddHost("db01")
+	require.NoError(t, m.AddGroup(all))
+
+	runner := &fakeRunner{fail: map[string]int{}}
+	return New(m, runner), runner
+}
+
+func TestExec(t *testing.T) {
+	t.Run("runs on resolved targets in order", func(t *testing.T) {
+		e, _ := setupExecutor(t)
+
+		results, err := e.Exec(context.Background(), ExecOptions{
+			Command: "uptime",
+			HostIDs: []string{"db01"},
+			Groups:  []string{"all"},
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+
+		assert.Equal(t, "db01", results[0].HostID)
+		assert.Equal(t, "web01", results[1].HostID)
+		assert.Equal(t, "deploy@web01: uptime", results[1].Stdout)
+		assert.True(t, results[1].OK())
+	})
+
+	t.Run("starts hosts in target order", func(t *testing.T) {
+		e, runner := setupExecutor(t)
+		var hostIDs []string
+		for i := range 50 {
+			id := fmt.Sprintf("app%02d", i)
+			h := inventory.NewHost(id, id, "10.0.1.1")
+			h.User = "deploy"
+			require.NoError(t, e.manager.AddHost(h))
+			hostIDs = append(hostIDs, id)
+		}
+
+		_, err := e.Exec(context.Background(), ExecOptions{Command: "uptime", HostIDs: hostIDs, Concurrency: 1})
+		require.NoError(t, err)
+		assert.Equal(t, hostIDs, runner.ran)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		runner.ran = nil
+		results, err := e.Exec(ctx, ExecOptions{Command: "uptime", HostIDs: hostIDs, Concurrency: 4})
+		require.NoError(t, err)
+		assert.Empty(t, runner.ran)
+		require.Len(t, results, 50)
+		assert.Equal(t, "app49", results[49].HostID)
+		assert.ErrorIs(t, results[49].Err, context.Canceled)
+	})
+
+	t.Run("reports progress per host", func(t *testing.T) {
+		e, runner := setupExecutor(t)
+		runner.fail["web02"] = 3
+		state := progress.NewState()
+
+		_, err := e.Exec(context.Background(), ExecOptions{Command: "uptime", Groups: []string{"web"}, Progress: state})
+		require.NoError(t, err)
+
+		tasks := state.Tasks()
+		require.Len(t, tasks, 1)
+		assert.Equal(t, progress.KindFinish, tasks[0].Kind)
+		assert.Equal(t, "exec: uptime", tasks[0].Title)
+		assert.Equal(t, int64(2), tasks[0].Done)
+		assert.Equal(t, 1, tasks[0].Succeeded)
+		assert.Equal(t, 1, tasks[0].Failed)
+	})
+
+	t.Run("reports failures per host", func(t *testing.T) {
+		e, runner := setupExecutor(t)
+		runner.fail["web02"] = 3
+
+		results, err := e.Exec(context.Background(), ExecOptions{Command: "false", Groups: []string{"web"}})
+		require.NoError(t, err)
+		assert.True(t, results[0].OK())
+		assert.False(t, results[1].OK())
+		assert.Equal(t, 3, results[1].ExitCode)
+	})
+
+	t.Run("redacts secrets from output", func(t *testing.T) {
+		e, _ := setupExecutor(t)
+
+		results, err := e.Exec(context.Background(), ExecOptions{
+			Command: "echo hunter22 | sudo -S id",
+			HostIDs: []string{"web01"},
+		})
+		require.NoError(t, err)
+		assert.NotContains(t, results[0].Stdout, "hunter22")
+	})
+
+	t.Run("target spec", func(t *testing.T) {
+		e, _ := setupExecutor(t)
+		opts := ExecOptions{Command: "uptime", HostIDs: []string{"web02"}, Target: "group:all - tag:env=prod"}
+
+		plan, err := e.Plan(context.Background(), ExecOptions{Command: opts.Command, HostIDs: opts.HostIDs, Target: opts.Target, SkipChecks: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web02", "web01"}, plan.Targets)
+
+		results, err := e.Exec(context.Background(), opts)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, "web02", results[0].HostID)
+		assert.Equal(t, "web01", results[1].HostID)
+
+		_, err = e.Exec(context.Background(), ExecOptions{Command: "id", Target: "group:web -"})
+		assert.Error(t, err)
+	})
+
+	t.Run("group priorities", func(t *testing.T) {
+		e, _ := setupExecutor(t)
+		require.NoError(t, e.manager.SetGroupHostPriority("web", "web01", 0))
+		require.NoError(t, e.manager.SetGroupHostPriority("all", "db01", 5))
+
+		targets, err := e.ResolveTargets(ExecOptions{Groups: []string{"all"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"db01", "web02"}, targets)
+
+		targets, err = e.ResolveTargets(ExecOptions{Groups: []string{"all"}, IncludePassive: true, ByPriority: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"db01", "web02", "web01"}, targets)
+
+		targets, err = e.ResolveTargets(ExecOptions{HostIDs: []string{"web01"}, Groups: []string{"web"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web01", "web02"}, targets)
+	})
+
+	t.Run("runs primaries before standbys", func(t *testing.T) {
+		base, _ := setupExecutor(t)
+		runner := &orderRunner{}
+		e := New(base.manager, runner)
+		db := inventory.NewGroup("db")
+		for _, id := range []string{"db-standby1", "db-primary", "db-standby2"} {
+			h := inventory.NewHost(id, id, "10.0.2.1")
+			h.User = "deploy"
+			require.NoError(t, e.manager.AddHost(h))
+			db.AddHost(id)
+		}
+		require.NoError(t, e.manager.AddGroup(db))
+		require.NoError(t, e.manager.SetGroupHostPriority("db", "db-primary", 10))
+
+		_, err := e.Exec(context.Background(), ExecOptions{Command: "pg_ctl reload", Groups: []string{"db"}, ByPriority: true, Concurrency: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"start db-primary", "end db-primary",
+			"start db-standby1", "end db-standby1",
+			"start db-standby2", "end db-standby2",
+		}, runner.events)
+	})
+
+	t.Run("invalid targets", func(t *testing.T) {
+		e, _ := setupExecutor(t)
+
+		_, err := e.Exec(context.Background(), ExecOptions{Command: "id"})
+		assert.Error(t, err)
+		_, err = e.Exec(context.Background(), ExecOptions{Command: "id", Groups: []string{"missing"}})
+		assert.Error(t, err)
+		_, err = e.Exec(context.Background(), ExecOptions{HostIDs: []string{"web01"}})
+		assert.Error(t, err)
+	})
+}
+
+func TestCommandPolicy(t *testing.T) {
+	policy := &inventory.CommandPolicy{Rules: []inventory.CommandRule{
+		{Name: "destructive", Deny: inventory.DefaultDenyPatterns},
+		{Name: "prod-readonly", Tags: []string{"env:prod"}, Allow: []string{`^(uptime|df -h|systemctl status \S+)$`}},
+	}}
+	require.NoError(t, policy.Validate())
+
+	t.Run("denied everywhere", func(t *testing.T) {
+		e, runner := setupExecutor(t)
+		e.SetCommandPolicy(policy)
+
+		for _, cmd := range []string{"rm -rf /", "sudo rm -rf /*", "mkfs.ext4 /dev/sdb1", "dd if=/dev/zero of=/dev/sda"} {
+			_, err := e.Exec(context.Background(), ExecOptions{Command: cmd, Groups: []string{"web"}})
+			assert.ErrorIs(t, err, ErrCommandBlocked, cmd)
+		}
+		assert.Empty(t, runner.ran, "nothing runs when blocked")
+
+		_, err := e.Exec(context.Background(), ExecOptions{Command: "rm -rf /tmp/cache", Groups: []string{"web"}})
+		assert.NoError(t, err)
+	})
+
+	t.Run("allow list by tag", func(t *testing.T) {
+		e, runner := setupExecutor(t)
+		e.SetCommandPolicy(policy)
+
+		_, err := e.Exec(context.Background(), ExecOptions{Command: "apt upgrade -y", Groups: []string{"all"}})
+		var blocked *BlockedError
+		require.True(t, errors.As(err, &blocked))
+		require.Len(t, blocked.Violations, 1)
+		assert.Equal(t, "db01", blocked.Violations[0].HostID)
+		assert.Equal(t, "prod-readonly", blocked.Violations[0].Rule)
+		assert.Empty(t, runner.ran)
+
+		_, err = e.Exec(context.Background(), ExecOptions{Command: "uptime", Groups: []string{"all"}})
+		assert.NoError(t, err)
+	})
+
+	t.Run("force with confirmation", func(t *testing.T) {
+		e, runner := setupExecutor(t)
+		e.SetCommandPolicy(policy)
+
+		opts := ExecOptions{Command: "reboot", HostIDs: []string{"web01"}, Force: true}
+
+		opts.Confirm = func([]inventory.CommandViolation) bool { return false }
+		_, err := e.Exec(context.Background(), opts)
+		var blocked *BlockedError
+		require.True(t, errors.As(err, &blocked))
+		assert.True(t, blocked.Declined)
+
+		var asked []inventory.CommandViolation
+		opts.Confirm = func(v []inventory.CommandViolation) bool { asked = v; return true }
+		results, err := e.Exec(context.Background(), opts)
+		require.NoError(t, err)
+		assert.Len(t, asked, 1)
+		assert.True(t, results[0].OK())
+		assert.Equal(t, []string{"web01"}, runner.ran)
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		bad := &inventory.CommandPolicy{Rules: []inventory.CommandRule{{Name: "bad", Deny: []string{"("}}}}
+		assert.Error(t, bad.Validate())
+	})
+}
+
+// timeoutRunner records the effective timeouts of each run.
+func TestExecEvents(t *testing.T) {
+	e, runner := setupExecutor(t)
+	runner.fail["web02"] = 3
+
+	bus := events.NewBus()
+	e.SetEventBus(bus)
+	var mu sync.Mutex
+	got := map[string][]events.Event{}
+	bus.Subscribe(func(ev events.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		got[ev.HostID] = append(got[ev.HostID], ev)
+	}, events.ExecStarted, events.ExecFinished)
+
+	_, err := e.Exec(context.Background(), ExecOptions{Command: "echo hunter22", Groups: []string{"web"}})
+	require.NoError(t, err)
+
+	require.Len(t, got["web01"], 2)
+	assert.Equal(t, events.ExecStarted, got["web01"][0].Type)
+	assert.Equal(t, "echo [REDACTED]", got["web01"][0].Command, "commands are redacted")
+	assert.Equal(t, events.ExecFinished, got["web01"][1].Type)
+	assert.Equal(t, 0, got["web01"][1].ExitCode)
+
+	require.Len(t, got["web02"], 2)
+	assert.Equal(t, 3, got["web02"][1].ExitCode)
+
+	t.Run("disabled", func(t *testing.T) {
+		e.SetEventBus(nil)
+		_, err := e.Exec(context.Background(), ExecOptions{Command: "uptime", HostIDs: []string{"db01"}})
+		require.NoError(t, err)
+		assert.Empty(t, got["db01"])
+	})
+}
+
+// orderRunner records when each host starts and ends.
+type orderRunner struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *orderRunner) Run(ctx context.Context, conn *inventory.ResolvedConnection, command string, stdout, stderr io.Writer) (int, error) {
+	r.mu.Lock()
+	r.events = append(r.events, "start "+conn.HostID)
+	r.mu.Unlock()
+	time.Sleep(time.Millisecond)
+	r.mu.Lock()
+	r.events = append(r.events, "end "+conn.HostID)
+	r.mu.Unlock()
+	return 0, nil
+}
+
+type timeoutRunner struct {
+	mu        sync.Mutex
+	timeouts  map[string]inventory.Timeouts
+	deadlines map[string]bool
+	block     bool
+}
+
+func (r *timeoutRunner) Run(ctx context.Context, conn *inventory.ResolvedConnection, command string, stdout, stderr io.Writer) (int, error) {
+	_, hasDeadline := ctx.Deadline()
+	r.mu.Lock()
+	r.timeouts[conn.HostID] = conn.Timeouts
+	r.deadlines[conn.HostID] = hasDeadline
+	r.mu.Unlock()
+
+	if r.block {
+		<-ctx.Done()
+		return -1, ctx.Err()
+	}
+	return 0, nil
+}
+
+func TestTimeouts(t *testing.T) {
+	newExecutor := func(t *testing.T) (*Executor, *timeoutRunner) {
+		base, _ := setupExecutor(t)
+		runner := &timeoutRunner{timeouts: map[string]inventory.Timeouts{}, deadlines: map[string]bool{}}
+		return New(base.manager, runner), runner
+	}
+
+	t.Run("precedence", func(t *testing.T) {
+		e, runner := newExecutor(t)
+		e.SetTimeouts(inventory.Timeouts{Connect: 30 * time.Second, Command: time.Hour})
+
+		h, _ := e.manager.GetHost("web02")
+		h.Timeouts = inventory.Timeouts{Connect: 5 * time.Second, Command: 10 * time.Minute}
+		require.NoError(t, e.manager.UpdateHost(h))
+
+		_, err := e.Exec(context.Background(), ExecOptions{
+			Command:         "uptime",
+			Groups:          []string{"web"},
+			Timeout:         time.Minute,
+			CommandTimeouts: inventory.Timeouts{Connect: 20 * time.Second, Command: 2 * time.Minute},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, inventory.Timeouts{Connect: 20 * time.Second, Command: time.Minute}, runner.timeouts["web01"])
+		assert.Equal(t, inventory.Timeouts{Connect: 20 * time.Second, Command: time.Minute}, runner.timeouts["web02"])
+
+		_, err = e.Exec(context.Background(), ExecOptions{Command: "uptime", Groups: []string{"web"}})
+		require.NoError(t, err)
+		assert.Equal(t, inventory.Timeouts{Connect: 30 * time.Second, Command: time.Hour}, runner.timeouts["web01"])
+		assert.Equal(t, inventory.Timeouts{Connect: 5 * time.Second, Command: 10 * time.Minute}, runner.timeouts["web02"])
+	})
+
+	t.Run("unset timeouts do not limit the run", func(t *testing.T) {
+		e, runner := newExecutor(t)
+
+		_, err := e.Exec(context.Background(), ExecOptions{Command: "uptime", HostIDs: []string{"web01"}})
+		require.NoError(t, err)
+		assert.True(t, runner.timeouts["web01"].IsZero())
+		assert.False(t, runner.deadlines["web01"])
+	})
+
+	t.Run("total timeout cancels the run", func(t *testing.T) {
+		e, runner := newExecutor(t)
+		runner.block = true
+
+		results, err := e.Exec(context.Background(), ExecOptions{
+			Command:      "sleep 60",
+			Groups:       []string{"all"},
+			TotalTimeout: 20 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		for _, r := range results {
+			assert.ErrorIs(t, r.Err, context.DeadlineExceeded, r.HostID)
+		}
+	})
+}
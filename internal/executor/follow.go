@@ -0,0 +1,195 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/redact"
+)
+
+// DefaultFollowLines is the number of existing lines Follow starts with, like tail.
+const DefaultFollowLines = 10
+
+// FollowOptions describes a "tail -f" across hosts.
+type FollowOptions struct {
+	// HostIDs, Groups and Target select the hosts as in ExecOptions.
+	HostIDs []string
+	Groups  []string
+	Target  string
+
+	// File is the remote file to follow with "tail -F"; Unit a systemd unit whose
+	// journal is followed instead. Exactly one must be set.
+	File string
+	Unit string
+	// Lines is the number of existing lines to start with (default
+	// DefaultFollowLines); negative starts with new lines only.
+	Lines int
+
+	// Filter keeps only the lines matching it and Exclude drops the lines matching
+	// it; both are matched on this side, so the hosts need no grep.
+	Filter  *regexp.Regexp
+	Exclude *regexp.Regexp
+
+	// Sudo follows with "sudo -n", e.g. for /var/log files.
+	Sudo bool
+}
+
+// LogLine is a line received from a followed host.
+type LogLine struct {
+	HostID string
+	// Time is when the line was received.
+	Time time.Time
+	Text string
+	// Stderr is set for lines the follow command wrote to stderr.
+	Stderr bool
+}
+
+// Format renders the line with its time and host prefix, the host padded to width
+// so that the lines of several hosts align.
+func (l LogLine) Format(width int) string {
+	sep := "|"
+	if l.Stderr {
+		sep = "!"
+	}
+	return fmt.Sprintf("%s %-*s %s %s", l.Time.Format("15:04:05.000"), width, l.HostID, sep, l.Text)
+}
+
+// command returns the follow command.
+func (o *FollowOptions) command() (string, error) {
+	if (o.File == "") == (o.Unit == "") {
+		return "", fmt.Errorf("either a file or a unit to follow is required")
+	}
+	lines := o.Lines
+	switch {
+	case lines == 0:
+		lines = DefaultFollowLines
+	case lines < 0:
+		lines = 0
+	}
+
+	var command string
+	if o.File != "" {
+		command = "tail -n " + strconv.Itoa(lines) + " -F -- " + inventory.ShellQuote(o.File)
+	} else {
+		command = "journalctl --no-pager --output=short-iso -f -n " + strconv.Itoa(lines) + " -u " + inventory.ShellQuote(o.Unit)
+	}
+	if o.Sudo {
+		command = "sudo -n " + command
+	}
+	return command, nil
+}
+
+// keep reports whether a line passes the filters.
+func (o *FollowOptions) keep(text string) bool {
+	if o.Filter != nil && !o.Filter.MatchString(text) {
+		return false
+	}
+	return o.Exclude == nil || !o.Exclude.MatchString(text)
+}
+
+// Follow follows a file or journal on the selected hosts at the same time and
+// passes their lines to out as they arrive, one call at a time. It returns when
+// ctx is done or every host's command ended; the error joins the failures of
+// hosts whose command ended for another reason than ctx.
+func (e *Executor) Follow(ctx context.Context, opts FollowOptions, out func(LogLine)) error {
+	command, err := opts.command()
+	if err != nil {
+		return err
+	}
+	targets, err := e.ResolveTargets(ExecOptions{HostIDs: opts.HostIDs, Groups: opts.Groups, Target: opts.Target})
+	if err != nil {
+		return err
+	}
+	if err := e.checkPolicy(targets, ExecOptions{Command: command}); err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	emit := func(l LogLine) {
+		if !opts.keep(l.Text) {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		out(l)
+	}
+
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, hostID := range targets {
+		wg.Add(1)
+		go func(i int, hostID string) {
+			defer wg.Done()
+			errs[i] = e.followHost(ctx, hostID, command, emit)
+		}(i, hostID)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// followHost runs the follow command on one host until it ends or ctx is done.
+func (e *Executor) followHost(ctx context.Context, hostID, command string, emit func(LogLine)) error {
+	conn, err := e.manager.ResolveConnection(hostID)
+	if err == nil {
+		err = conn.ResolveSecrets()
+	}
+	if err != nil {
+		return fmt.Errorf("host %s: %w", hostID, redact.Error(err))
+	}
+	redact.Default().AddConnection(conn)
+
+	stdout := &lineWriter{emit: func(text string) { emit(LogLine{HostID: hostID, Time: time.Now(), Text: text}) }}
+	stderr := &lineWriter{emit: func(text string) { emit(LogLine{HostID: hostID, Time: time.Now(), Text: text, Stderr: true}) }}
+	code, err := e.runnerFor(conn).Run(ctx, conn, command, stdout, stderr)
+	stdout.Flush()
+	stderr.Flush()
+
+	switch {
+	case ctx.Err() != nil:
+		return nil
+	case err != nil:
+		return fmt.Errorf("host %s: %w", hostID, redact.Error(err))
+	case code != 0:
+		return fmt.Errorf("host %s: follow exited with status %d", hostID, code)
+	}
+	return nil
+}
+
+// lineWriter passes the complete lines written to it to emit, redacted.
+type lineWriter struct {
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	emit func(string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := w.buf.Next(i + 1)
+		w.emit(redact.String(string(bytes.TrimRight(line, "\r\n"))))
+	}
+}
+
+// Flush emits a last line without newline.
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() > 0 {
+		w.emit(redact.String(w.buf.String()))
+		w.buf.Reset()
+	}
+}
@@ -0,0 +1,124 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamingRunner writes a few lines in chunks, then blocks until cancelled or,
+// for hosts in fail, exits with status 1.
+type streamingRunner struct {
+	mu       sync.Mutex
+	commands []string
+	fail     map[string]bool
+}
+
+func (r *streamingRunner) Run(ctx context.Context, conn *inventory.ResolvedConnection, command string, stdout, stderr io.Writer) (int, error) {
+	r.mu.Lock()
+	r.commands = append(r.commands, command)
+	r.mu.Unlock()
+
+	fmt.Fprintf(stdout, "%s: GET /health 200\n%s: GET /ad", conn.HostID, conn.HostID)
+	fmt.Fprintf(stdout, "min 500\n%s: last line", conn.HostID)
+	if r.fail[conn.HostID] {
+		fmt.Fprint(stderr, "tail: cannot open\n")
+		return 1, nil
+	}
+	<-ctx.Done()
+	return -1, ctx.Err()
+}
+
+func TestFollow(t *testing.T) {
+	setup := func(t *testing.T) (*Executor, *streamingRunner) {
+		base, _ := setupExecutor(t)
+		runner := &streamingRunner{fail: map[string]bool{}}
+		return New(base.manager, runner), runner
+	}
+
+	collect := func(t *testing.T, e *Executor, opts FollowOptions, want int) ([]LogLine, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		var lines []LogLine
+		err := e.Follow(ctx, opts, func(l LogLine) {
+			lines = append(lines, l)
+			if len(lines) == want {
+				cancel()
+			}
+		})
+		return lines, err
+	}
+
+	t.Run("interleaves lines of all hosts", func(t *testing.T) {
+		e, runner := setup(t)
+		// Last lines without newline arrive when the follow ends.
+		lines, err := collect(t, e, FollowOptions{Groups: []string{"web"}, File: "/var/log/app.log", Lines: 50}, 4)
+		require.NoError(t, err)
+		require.Len(t, lines, 6)
+
+		perHost := map[string][]string{}
+		for _, l := range lines {
+			assert.False(t, l.Time.IsZero())
+			perHost[l.HostID] = append(perHost[l.HostID], l.Text)
+		}
+		assert.Equal(t, []string{"web01: GET /health 200", "web01: GET /admin 500", "web01: last line"}, perHost["web01"])
+		assert.Len(t, perHost["web02"], 3)
+		assert.Equal(t, "tail -n 50 -F -- /var/log/app.log", runner.commands[0])
+	})
+
+	t.Run("filters on the client", func(t *testing.T) {
+		e, runner := setup(t)
+		lines, err := collect(t, e, FollowOptions{
+			HostIDs: []string{"web01"},
+			Unit:    "nginx",
+			Filter:  regexp.MustCompile(`GET`),
+			Exclude: regexp.MustCompile(` 200$`),
+			Sudo:    true,
+		}, 1)
+		require.NoError(t, err)
+		require.Len(t, lines, 1)
+		assert.Equal(t, "web01: GET /admin 500", lines[0].Text)
+		assert.Equal(t, "sudo -n journalctl --no-pager --output=short-iso -f -n 10 -u nginx", runner.commands[0])
+	})
+
+	t.Run("reports hosts whose command ends", func(t *testing.T) {
+		e, runner := setup(t)
+		runner.fail["web01"] = true
+		lines, err := collect(t, e, FollowOptions{HostIDs: []string{"web01"}, File: "/missing", Lines: -1}, 100)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "web01")
+		var stderr []string
+		for _, l := range lines {
+			if l.Stderr {
+				stderr = append(stderr, l.Text)
+			}
+		}
+		assert.Equal(t, []string{"tail: cannot open"}, stderr)
+		assert.Equal(t, "tail -n 0 -F -- /missing", runner.commands[0])
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		e, _ := setup(t)
+		err := e.Follow(context.Background(), FollowOptions{HostIDs: []string{"web01"}}, func(LogLine) {})
+		assert.Error(t, err)
+		err = e.Follow(context.Background(), FollowOptions{HostIDs: []string{"web01"}, File: "a", Unit: "b"}, func(LogLine) {})
+		assert.Error(t, err)
+	})
+}
+
+func TestLogLineFormat(t *testing.T) {
+	l := LogLine{HostID: "web01", Time: time.Date(2024, 5, 14, 10, 2, 3, 4e6, time.Local), Text: "hello"}
+	assert.Equal(t, "10:02:03.004 web01  | hello", l.Format(6))
+	l.Stderr = true
+	assert.True(t, strings.Contains(l.Format(0), "web01 ! hello"))
+}
@@ -0,0 +1,268 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/redact"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HistoryDir is the subdirectory of the data directory holding execution logs.
+const HistoryDir = "history"
+
+// RunIndexFile is the summary file of a logged run.
+const RunIndexFile = "index.yaml"
+
+// RunIndex summarizes a logged run; it is rewritten as hosts finish, so an
+// interrupted run can be resumed from it.
+type RunIndex struct {
+	ID      string   `yaml:"id"`
+	Command string   `yaml:"command"`
+	HostIDs []string `yaml:"host_ids,omitempty"`
+	Groups  []string `yaml:"groups,omitempty"`
+	Target  string   `yaml:"target,omitempty"`
+	Force   bool     `yaml:"force,omitempty"`
+
+	Preflight          []inventory.Preflight     `yaml:"preflight,omitempty"`
+	OnPreflightFailure inventory.PreflightAction `yaml:"on_preflight_failure,omitempty"`
+	Snapshot           *inventory.Snapshot       `yaml:"snapshot,omitempty"`
+
+	// Inputs holds the values of {{.Inputs.name}} in the command, except secret
+	// ones: their names are in SecretInputs and Resume asks for them again.
+	Inputs       map[string]string `yaml:"inputs,omitempty"`
+	SecretInputs []string          `yaml:"secret_inputs,omitempty"`
+
+	// Targets lists every host of the run in target order.
+	Targets []string `yaml:"targets"`
+
+	Started time.Time `yaml:"started"`
+	// Finished is zero while the run is in progress or when it crashed.
+	Finished time.Time   `yaml:"finished,omitempty"`
+	Resumed  []time.Time `yaml:"resumed,omitempty"`
+
+	Hosts []RunEntry `yaml:"hosts"`
+}
+
+// RunEntry is the outcome of a logged run on one host.
+type RunEntry struct {
+	HostID   string        `yaml:"host"`
+	ExitCode int           `yaml:"exit_code"`
+	Error    string        `yaml:"error,omitempty"`
+	Skipped  bool          `yaml:"skipped,omitempty"`
+	Duration time.Duration `yaml:"duration"`
+	// Interrupted is set when the run was cancelled before the host completed.
+	Interrupted bool `yaml:"interrupted,omitempty"`
+	// Log is the host's log file, relative to the run directory.
+	Log string `yaml:"log"`
+	// Snapshot is what changed on the host, for runs with a snapshot.
+	Snapshot *SnapshotDiff `yaml:"snapshot,omitempty"`
+}
+
+// Completed reports whether the host ran to completion, successfully or not.
+func (r RunEntry) Completed() bool {
+	return !r.Interrupted
+}
+
+// Failed reports whether the host completed with an error or non-zero exit code.
+func (r RunEntry) Failed() bool {
+	return r.Completed() && !r.Skipped && (r.Error != "" || r.ExitCode != 0)
+}
+
+// Entry returns the entry of a host.
+func (idx *RunIndex) Entry(hostID string) (RunEntry, bool) {
+	for _, e := range idx.Hosts {
+		if e.HostID == hostID {
+			return e, true
+		}
+	}
+	return RunEntry{}, false
+}
+
+// Pending returns the targets that did not complete, in target order; with
+// retryFailed, hosts that failed are included.
+func (idx *RunIndex) Pending(retryFailed bool) []string {
+	var pending []string
+	for _, id := range idx.Targets {
+		e, ok := idx.Entry(id)
+		if !ok || !e.Completed() || (retryFailed && e.Failed()) {
+			pending = append(pending, id)
+		}
+	}
+	return pending
+}
+
+// runLog writes the output of a run to HistoryDir-style directories.
+type runLog struct {
+	dir   string
+	mu    sync.Mutex
+	index RunIndex
+}
+
+// newRunLog creates the directory of a new run under historyDir.
+func newRunLog(historyDir string, targets []string, opts ExecOptions) (*runLog, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate run ID: %w", err)
+	}
+
+	dir := filepath.Join(historyDir, id)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create run directory: %w", err)
+	}
+
+	l := &runLog{dir: dir, index: RunIndex{
+		ID:      id,
+		Command: opts.Command,
+		HostIDs: append([]string(nil), opts.HostIDs...),
+		Groups:  append([]string(nil), opts.Groups...),
+		Target:  opts.Target,
+		Force:   opts.Force,
+
+		Preflight:          opts.Preflight,
+		OnPreflightFailure: opts.OnPreflightFailure,
+		Snapshot:           opts.Snapshot,
+
+		Targets: append([]string(nil), targets...),
+		Started: time.Now().UTC(),
+	}}
+	for name, value := range opts.Inputs {
+		// Values holding a registered secret, such as those of secret inputs, are
+		// redacted and must not be written to disk.
+		if redact.String(value) != value {
+			l.index.SecretInputs = append(l.index.SecretInputs, name)
+			continue
+		}
+		if l.index.Inputs == nil {
+			l.index.Inputs = map[string]string{}
+		}
+		l.index.Inputs[name] = value
+	}
+	sort.Strings(l.index.SecretInputs)
+	return l, l.writeIndex()
+}
+
+// openRunLog reopens a logged run to record more results.
+func openRunLog(historyDir, runID string) (*runLog, error) {
+	index, err := ReadRunIndex(historyDir, runID)
+	if err != nil {
+		return nil, err
+	}
+	return &runLog{dir: filepath.Join(historyDir, runID), index: *index}, nil
+}
+
+// record writes a host's output to its log file and adds it to the index, replacing
+// an earlier entry of the host. interrupted marks results of a cancelled run.
+func (l *runLog) record(r *Result, interrupted bool) error {
+	name := logFilename(r.HostID)
+	if err := os.WriteFile(filepath.Join(l.dir, name), formatHostLog(l.index.Command, r), 0600); err != nil {
+		return fmt.Errorf("failed to write log of %s: %w", r.HostID, err)
+	}
+	r.RunID = l.index.ID
+	r.LogFile = filepath.Join(l.dir, name)
+
+	entry := RunEntry{
+		HostID:   r.HostID,
+		ExitCode: r.ExitCode,
+		Skipped:  r.Skipped,
+		Duration: r.Duration,
+		Log:      name,
+		Snapshot: r.Snapshot,
+	}
+	if r.Err != nil {
+		entry.Error = r.Err.Error()
+		entry.Interrupted = interrupted
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, e := range l.index.Hosts {
+		if e.HostID == r.HostID {
+			l.index.Hosts[i] = entry
+			return l.writeIndex()
+		}
+	}
+	l.index.Hosts = append(l.index.Hosts, entry)
+	return l.writeIndex()
+}
+
+// finish marks the run as complete.
+func (l *runLog) finish() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.index.Finished = time.Now().UTC()
+	return l.writeIndex()
+}
+
+// writeIndex replaces the index file. Caller must hold the lock or own the log.
+func (l *runLog) writeIndex() error {
+	data, err := yaml.Marshal(&l.index)
+	if err != nil {
+		return fmt.Errorf("failed to marshal run index: %w", err)
+	}
+	tmp := filepath.Join(l.dir, "."+RunIndexFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write run index: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(l.dir, RunIndexFile)); err != nil {
+		return fmt.Errorf("failed to write run index: %w", err)
+	}
+	return nil
+}
+
+// logFilename returns the log file name of a host.
+func logFilename(hostID string) string {
+	return strings.NewReplacer("/", "_", `\`, "_").Replace(hostID) + ".log"
+}
+
+// formatHostLog renders a host's result as a log file.
+func formatHostLog(command string, r *Result) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# host: %s\n", r.HostID)
+	fmt.Fprintf(&b, "# command: %s\n", command)
+	fmt.Fprintf(&b, "# started: %s\n", r.Started.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "# duration: %s\n", r.Duration)
+	fmt.Fprintf(&b, "# exit code: %d\n", r.ExitCode)
+	if r.Err != nil {
+		fmt.Fprintf(&b, "# error: %s\n", r.Err)
+	}
+
+	for _, section := range []struct{ name, text string }{{"stdout", r.Stdout}, {"stderr", r.Stderr}} {
+		if section.text == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "\n--- %s ---\n%s", section.name, section.text)
+		if !strings.HasSuffix(section.text, "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	return []byte(b.String())
+}
+
+// ReadRunIndex loads the index of a logged run.
+func ReadRunIndex(historyDir, runID string) (*RunIndex, error) {
+	if runID == "" || strings.ContainsAny(runID, `/\`) || strings.HasPrefix(runID, ".") {
+		return nil, fmt.Errorf("invalid run ID %q", runID)
+	}
+
+	data, err := os.ReadFile(filepath.Join(historyDir, runID, RunIndexFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("run %s not found", runID)
+		}
+		return nil, fmt.Errorf("failed to read run %s: %w", runID, err)
+	}
+
+	var index RunIndex
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse run %s: %w", runID, err)
+	}
+	return &index, nil
+}
@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecHistory(t *testing.T) {
+	e, runner := setupExecutor(t)
+	runner.fail["web02"] = 2
+	history := filepath.Join(t.TempDir(), HistoryDir)
+
+	results, err := e.Exec(context.Background(), ExecOptions{
+		Command:    "uptime",
+		Groups:     []string{"web"},
+		HistoryDir: history,
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	runID := results[0].RunID
+	require.NotEmpty(t, runID)
+	assert.Equal(t, runID, results[1].RunID)
+	assert.Equal(t, filepath.Join(history, runID, "web01.log"), results[0].LogFile)
+
+	data, err := os.ReadFile(results[0].LogFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "# exit code: 0\n")
+	assert.Contains(t, string(data), "--- stdout ---\ndeploy@web01: uptime\n")
+
+	data, err = os.ReadFile(results[1].LogFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "--- stderr ---\nfailed on web02\n")
+
+	index, err := ReadRunIndex(history, runID)
+	require.NoError(t, err)
+	assert.Equal(t, "uptime", index.Command)
+	assert.Equal(t, []string{"web"}, index.Groups)
+	assert.False(t, index.Finished.IsZero())
+	require.Len(t, index.Hosts, 2)
+
+	codes := map[string]int{}
+	for _, h := range index.Hosts {
+		codes[h.HostID] = h.ExitCode
+		assert.FileExists(t, filepath.Join(history, runID, h.Log))
+	}
+	assert.Equal(t, map[string]int{"web01": 0, "web02": 2}, codes)
+
+	t.Run("runs without history keep output in memory only", func(t *testing.T) {
+		results, err := e.Exec(context.Background(), ExecOptions{Command: "id", HostIDs: []string{"web01"}})
+		require.NoError(t, err)
+		assert.Empty(t, results[0].RunID)
+		assert.Empty(t, results[0].LogFile)
+	})
+
+	t.Run("unknown runs", func(t *testing.T) {
+		_, err := ReadRunIndex(history, "missing")
+		assert.Error(t, err)
+		_, err = ReadRunIndex(history, "../etc")
+		assert.Error(t, err)
+	})
+}
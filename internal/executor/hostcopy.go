@@ -0,0 +1,195 @@
+package executor
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/redact"
+)
+
+// HostCopyVia is how HostCopy moves a file between hosts.
+type HostCopyVia string
+
+const (
+	// HostCopyAuto copies directly when the destination can be reached from the
+	// source and relays otherwise, or when the direct copy fails.
+	HostCopyAuto HostCopyVia = "auto"
+	// HostCopyDirect runs scp on the source host, which needs its own access to
+	// the destination (e.g. a key or a forwarded agent); nothing passes through
+	// this machine.
+	HostCopyDirect HostCopyVia = "direct"
+	// HostCopyRelay streams the download from the source into the upload to the
+	// destination through this machine, without staging the file on disk.
+	HostCopyRelay HostCopyVia = "relay"
+)
+
+// HostCopyOptions describes a host-to-host copy.
+type HostCopyOptions struct {
+	SourceHost string `json:"source_host,omitempty"`
+	SourcePath string `json:"source_path,omitempty"`
+	DestHost   string `json:"dest_host,omitempty"`
+	DestPath   string `json:"dest_path,omitempty"`
+	// Mode is the mode of the copy; unset keeps the source's mode for direct
+	// copies and uses DefaultDeployMode for relayed ones.
+	Mode os.FileMode `json:"mode,omitempty"`
+	// Via is how the file is moved (default HostCopyAuto).
+	Via HostCopyVia `json:"via,omitempty"`
+}
+
+// validate checks that the options are complete.
+func (o *HostCopyOptions) validate() error {
+	if o.SourceHost == "" || o.SourcePath == "" || o.DestHost == "" || o.DestPath == "" {
+		return fmt.Errorf("source and destination host and path are required")
+	}
+	switch o.Via {
+	case "", HostCopyAuto, HostCopyDirect, HostCopyRelay:
+		return nil
+	}
+	return fmt.Errorf("invalid copy mode: %s", o.Via)
+}
+
+// HostCopyResult reports a host-to-host copy.
+type HostCopyResult struct {
+	// Via is how the file was moved: direct or relay.
+	Via HostCopyVia `json:"via"`
+	// Bytes is the size of relayed copies; direct copies are not counted.
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration"`
+	// DirectError is why an automatic copy fell back to relaying.
+	DirectError string `json:"direct_error,omitempty"`
+}
+
+// HostCopy copies a file from one host to another without staging it on this
+// machine: directly with scp on the source when possible, relayed as a stream
+// otherwise; see HostCopyVia.
+func (e *Executor) HostCopy(ctx context.Context, opts HostCopyOptions) (*HostCopyResult, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	via := cmp.Or(opts.Via, HostCopyAuto)
+
+	src, err := e.copyConnection(opts.SourceHost)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := e.copyConnection(opts.DestHost)
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	result := &HostCopyResult{}
+	if via != HostCopyRelay {
+		err := e.copyDirect(ctx, src, dst, opts)
+		if err == nil {
+			result.Via, result.Duration = HostCopyDirect, time.Since(started)
+			return result, nil
+		}
+		if via == HostCopyDirect || ctx.Err() != nil {
+			return nil, err
+		}
+		result.DirectError = err.Error()
+	}
+
+	n, err := e.copyRelay(ctx, src, dst, opts)
+	if err != nil {
+		return nil, err
+	}
+	result.Via, result.Bytes, result.Duration = HostCopyRelay, n, time.Since(started)
+	return result, nil
+}
+
+// copyConnection resolves the connection of a copy endpoint.
+func (e *Executor) copyConnection(hostID string) (*inventory.ResolvedConnection, error) {
+	conn, err := e.manager.ResolveConnection(hostID)
+	if err == nil {
+		err = conn.ResolveSecrets()
+	}
+	if err != nil {
+		return nil, redact.Error(err)
+	}
+	redact.Default().AddConnection(conn)
+	e.applyTimeouts(conn, ExecOptions{})
+	return conn, nil
+}
+
+// copyDirect runs scp on the source host to push the file to the destination.
+func (e *Executor) copyDirect(ctx context.Context, src, dst *inventory.ResolvedConnection, opts HostCopyOptions) error {
+	if dst.Local || len(dst.Jumps) > 0 || dst.Relay != nil || dst.GatewayURL != "" || len(dst.Knock) > 0 {
+		return fmt.Errorf("host %s cannot be reached directly from %s", dst.HostID, src.HostID)
+	}
+
+	command := "scp -q -p -o BatchMode=yes"
+	if dst.Port != 0 && dst.Port != 22 {
+		command += " -P " + strconv.Itoa(dst.Port)
+	}
+	if opt := dst.ConnectTimeoutOption(); opt != nil {
+		command += " " + strings.Join(opt, " ")
+	}
+	command += " -- " + inventory.ShellQuote(opts.SourcePath) + " " + inventory.ShellQuote(dst.RemotePath(opts.DestPath))
+	if err := e.checkPolicy([]string{src.HostID}, ExecOptions{Command: command}); err != nil {
+		return err
+	}
+
+	if _, err := e.step(ctx, src, command, 0); err != nil {
+		return fmt.Errorf("direct copy from %s to %s failed: %w", src.HostID, dst.HostID, err)
+	}
+	if opts.Mode != 0 {
+		mode := strconv.FormatUint(uint64(opts.Mode.Perm()), 8)
+		if _, err := e.step(ctx, dst, "chmod "+mode+" -- "+inventory.ShellQuote(opts.DestPath), 0); err != nil {
+			return fmt.Errorf("host %s: failed to chmod %s: %w", dst.HostID, opts.DestPath, err)
+		}
+	}
+	return nil
+}
+
+// copyRelay streams the download from the source into the upload to the
+// destination and returns the bytes copied.
+func (e *Executor) copyRelay(ctx context.Context, src, dst *inventory.ResolvedConnection, opts HostCopyOptions) (int64, error) {
+	uploader, ok := e.runnerFor(dst).(Uploader)
+	if !ok {
+		return 0, fmt.Errorf("host %s: %w", dst.HostID, ErrNoUploader)
+	}
+
+	pr, pw := io.Pipe()
+	downloaded := make(chan error, 1)
+	go func() {
+		err := e.download(ctx, src, opts.SourcePath, pw)
+		pw.CloseWithError(err)
+		downloaded <- err
+	}()
+
+	counter := &countingPipe{r: pr}
+	err := uploader.Upload(ctx, dst, counter, opts.DestPath, cmp.Or(opts.Mode, DefaultDeployMode))
+	// Stop the download when the upload gave up early.
+	pr.CloseWithError(io.ErrClosedPipe)
+	derr := <-downloaded
+	switch {
+	case derr != nil && !errors.Is(derr, io.ErrClosedPipe):
+		return 0, fmt.Errorf("host %s: failed to read %s: %w", src.HostID, opts.SourcePath, redact.Error(derr))
+	case err != nil:
+		return 0, fmt.Errorf("host %s: failed to write %s: %w", dst.HostID, opts.DestPath, redact.Error(err))
+	}
+	return counter.n.Load(), nil
+}
+
+// countingPipe counts the bytes read through it.
+type countingPipe struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingPipe) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,137 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scpRunner records the commands run through uploadingRunner and can fail scp.
+type scpRunner struct {
+	*uploadingRunner
+	mu       sync.Mutex
+	commands []string
+	scpFails bool
+}
+
+func (r *scpRunner) Run(ctx context.Context, conn *inventory.ResolvedConnection, command string, stdout, stderr io.Writer) (int, error) {
+	r.mu.Lock()
+	r.commands = append(r.commands, conn.HostID+": "+command)
+	r.mu.Unlock()
+	if r.scpFails && strings.HasPrefix(command, "scp ") {
+		io.WriteString(stderr, "Host key verification failed.")
+		return 1, nil
+	}
+	return r.uploadingRunner.Run(ctx, conn, command, stdout, stderr)
+}
+
+func TestHostCopy(t *testing.T) {
+	setup := func(t *testing.T) (*Executor, *scpRunner) {
+		base, inner := setupExecutor(t)
+		runner := &scpRunner{uploadingRunner: &uploadingRunner{fakeRunner: inner, uploads: map[string]string{}}}
+		return New(base.manager, runner), runner
+	}
+	ctx := context.Background()
+
+	t.Run("copies directly with scp on the source", func(t *testing.T) {
+		e, runner := setup(t)
+		h, ok := e.manager.GetHost("web02")
+		require.True(t, ok)
+		h.Port = 2222
+		require.NoError(t, e.manager.UpdateHost(h))
+
+		result, err := e.HostCopy(ctx, HostCopyOptions{
+			SourceHost: "web01", SourcePath: "/srv/app v2.tar",
+			DestHost: "web02", DestPath: "/tmp/app.tar", Mode: 0o600,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, HostCopyDirect, result.Via)
+		assert.Empty(t, result.DirectError)
+		assert.Equal(t, []string{
+			"web01: scp -q -p -o BatchMode=yes -P 2222 -- '/srv/app v2.tar' deploy@10.0.0.1:/tmp/app.tar",
+			"web02: chmod 600 -- /tmp/app.tar",
+		}, runner.commands)
+		assert.Empty(t, runner.uploads)
+	})
+
+	t.Run("falls back to relaying", func(t *testing.T) {
+		e, runner := setup(t)
+		runner.scpFails = true
+
+		result, err := e.HostCopy(ctx, HostCopyOptions{SourceHost: "web01", SourcePath: "/srv/app.tar", DestHost: "web02", DestPath: "/tmp/app.tar"})
+		require.NoError(t, err)
+		assert.Equal(t, HostCopyRelay, result.Via)
+		assert.Contains(t, result.DirectError, "Host key verification failed")
+		content := "deploy@web01: cat -- /srv/app.tar"
+		assert.Equal(t, content, runner.uploads["web02:/tmp/app.tar"])
+		assert.Equal(t, int64(len(content)), result.Bytes)
+	})
+
+	t.Run("direct copies do not fall back", func(t *testing.T) {
+		e, runner := setup(t)
+		runner.scpFails = true
+
+		_, err := e.HostCopy(ctx, HostCopyOptions{SourceHost: "web01", SourcePath: "/a", DestHost: "web02", DestPath: "/b", Via: HostCopyDirect})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "direct copy from web01 to web02 failed")
+		assert.Empty(t, runner.uploads)
+	})
+
+	t.Run("relays when the destination is not reachable from the source", func(t *testing.T) {
+		e, runner := setup(t)
+		require.NoError(t, e.manager.AddHost(inventory.NewLocalHost("build", "build")))
+		dir := t.TempDir()
+		data := bytes.Repeat([]byte("artifact"), 64*1024)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "app.tar"), data, 0o644))
+
+		result, err := e.HostCopy(ctx, HostCopyOptions{
+			SourceHost: "build", SourcePath: filepath.Join(dir, "app.tar"),
+			DestHost: "build", DestPath: filepath.Join(dir, "copy.tar"), Mode: 0o600,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, HostCopyRelay, result.Via)
+		assert.Contains(t, result.DirectError, "cannot be reached directly")
+		assert.Equal(t, int64(len(data)), result.Bytes)
+		assert.Empty(t, runner.commands)
+
+		copied, err := os.ReadFile(filepath.Join(dir, "copy.tar"))
+		require.NoError(t, err)
+		assert.Equal(t, data, copied)
+		info, err := os.Stat(filepath.Join(dir, "copy.tar"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	})
+
+	t.Run("reports read failures of the source", func(t *testing.T) {
+		e, _ := setup(t)
+		require.NoError(t, e.manager.AddHost(inventory.NewLocalHost("build", "build")))
+		dir := t.TempDir()
+
+		_, err := e.HostCopy(ctx, HostCopyOptions{
+			SourceHost: "build", SourcePath: filepath.Join(dir, "missing"),
+			DestHost: "build", DestPath: filepath.Join(dir, "copy"), Via: HostCopyRelay,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read")
+	})
+
+	t.Run("validates options", func(t *testing.T) {
+		e, _ := setup(t)
+		_, err := e.HostCopy(ctx, HostCopyOptions{SourceHost: "web01", SourcePath: "/a", DestHost: "web02"})
+		assert.Error(t, err)
+		_, err = e.HostCopy(ctx, HostCopyOptions{SourceHost: "web01", SourcePath: "/a", DestHost: "web02", DestPath: "/b", Via: "carrier-pigeon"})
+		assert.Error(t, err)
+		_, err = e.HostCopy(ctx, HostCopyOptions{SourceHost: "nope", SourcePath: "/a", DestHost: "web02", DestPath: "/b"})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,197 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gossher/internal/inventory"
+	"gossher/internal/testssh"
+	"gossher/internal/transport"
+	"gossher/internal/transport/transportmock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// setupNetwork creates an executor connecting to testssh servers: web01 and web02
+// with passwords, db01 with a key behind the bastion host.
+func setupNetwork(t *testing.T) (*Executor, map[string]*testssh.Server) {
+	m := inventory.NewManager(t.TempDir())
+	require.NoError(t, m.Load())
+	n := testssh.NewNetwork()
+	servers := map[string]*testssh.Server{}
+
+	key, err := testssh.GenerateKey()
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(keyPath, key, 0o600))
+
+	for i, id := range []string{"bastion", "web01", "web02", "db01"} {
+		address := "10.0.0." + string(rune('1'+i))
+		s, err := n.NewServer(address, 22, t.TempDir())
+		require.NoError(t, err)
+		servers[id] = s
+
+		h := inventory.NewHost(id, id, address)
+		h.User = "deploy"
+		if id == "db01" {
+			require.NoError(t, s.AuthorizeKey("deploy", key))
+			h.KeyPath = keyPath
+			h.JumpHostID = "bastion"
+		} else {
+			s.AddUser("deploy", "hunter22")
+			h.Password = "hunter22"
+		}
+		require.NoError(t, m.AddHost(h))
+	}
+	return New(m, n), servers
+}
+
+func TestTestSSH(t *testing.T) {
+	t.Run("exec", func(t *testing.T) {
+		e, servers := setupNetwork(t)
+		servers["web02"].Handle("uptime", func(s *testssh.Session) int {
+			s.Stderr.Write([]byte("load too high"))
+			return 2
+		})
+
+		results, err := e.Exec(context.Background(), ExecOptions{Command: "uptime", HostIDs: []string{"web01", "web02"}})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, 127, results[0].ExitCode)
+		assert.Equal(t, 2, results[1].ExitCode)
+		assert.Equal(t, "load too high", results[1].Stderr)
+	})
+
+	t.Run("key through jump host", func(t *testing.T) {
+		e, servers := setupNetwork(t)
+		results, err := e.Exec(context.Background(), ExecOptions{Command: "whoami", HostIDs: []string{"db01"}})
+		require.NoError(t, err)
+		require.True(t, results[0].OK(), results[0].Err)
+		assert.Equal(t, "deploy\n", results[0].Stdout)
+		assert.Equal(t, testssh.AuthPublicKey, servers["db01"].Requests()[0].Auth)
+	})
+
+	t.Run("auth failure", func(t *testing.T) {
+		e, _ := setupNetwork(t)
+		h, _ := e.manager.GetHost("web01")
+		h.Password = "wrong"
+		require.NoError(t, e.manager.UpdateHost(h))
+
+		results, err := e.Exec(context.Background(), ExecOptions{Command: "true", HostIDs: []string{"web01"}})
+		require.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, testssh.ErrAuthFailed)
+	})
+
+	t.Run("pinned host keys", func(t *testing.T) {
+		e, servers := setupNetwork(t)
+		h, _ := e.manager.GetHost("web01")
+		h.AddHostKey(inventory.HostKey{KeyType: "ssh-ed25519", Fingerprint: "SHA256:stale"})
+		require.NoError(t, e.manager.UpdateHost(h))
+
+		results, err := e.Exec(context.Background(), ExecOptions{Command: "true", HostIDs: []string{"web01"}})
+		require.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, transport.ErrHostKeyMismatch)
+		assert.Empty(t, servers["web01"].Requests())
+
+		h.AddHostKey(inventory.HostKey{KeyType: "ssh-ed25519", Fingerprint: ssh.FingerprintSHA256(servers["web01"].HostKey())})
+		require.NoError(t, e.manager.UpdateHost(h))
+		results, err = e.Exec(context.Background(), ExecOptions{Command: "true", HostIDs: []string{"web01"}})
+		require.NoError(t, err)
+		assert.True(t, results[0].OK(), results[0].Err)
+	})
+
+	t.Run("workflow transfer", func(t *testing.T) {
+		e, servers := setupNetwork(t)
+		src := filepath.Join(t.TempDir(), "app.txt")
+		require.NoError(t, os.WriteFile(src, []byte("v2"), 0o644))
+
+		w := inventory.NewWorkflow("deploy", "deploy")
+		w.Target = "host:web01 + host:web02"
+		w.AddStep(inventory.WorkflowStep{Name: "upload", Kind: inventory.StepTransfer, Source: src, Destination: "/srv/app.txt"})
+		w.AddStep(inventory.WorkflowStep{Name: "verify", Kind: inventory.StepExec, Command: "cat /srv/app.txt"})
+		require.NoError(t, e.manager.AddWorkflow(w))
+
+		run, err := e.RunWorkflow(context.Background(), "deploy", WorkflowOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, StepOK, run.Status)
+		for _, id := range []string{"web01", "web02"} {
+			data, err := servers[id].ReadFile("/srv/app.txt")
+			require.NoError(t, err)
+			assert.Equal(t, "v2", string(data))
+		}
+	})
+}
+
+func TestNewWithDialer(t *testing.T) {
+	base, _ := setupExecutor(t)
+	uploads := map[string]string{}
+	dialer := &transportmock.Dialer{
+		DialFunc: func(ctx context.Context, conn *inventory.ResolvedConnection) (transport.Session, error) {
+			xfer := &transportmock.Transfer{
+				UploadFunc: func(ctx context.Context, src io.Reader, remotePath string, mode os.FileMode) error {
+					data, err := io.ReadAll(src)
+					uploads[conn.HostID+":"+remotePath] = string(data)
+					return err
+				},
+			}
+			return &transportmock.Session{
+				RunFunc: func(ctx context.Context, command string, stdout, stderr io.Writer) (int, error) {
+					if conn.HostID == "web02" {
+						return 1, nil
+					}
+					fmt.Fprintf(stdout, "%s: %s", conn.HostID, command)
+					return 0, nil
+				},
+				TransferFunc: func(context.Context) (transport.Transfer, error) { return xfer, nil },
+			}, nil
+		},
+	}
+	e := NewWithDialer(base.manager, dialer)
+
+	t.Run("exec", func(t *testing.T) {
+		results, err := e.Exec(context.Background(), ExecOptions{Command: "uptime", Groups: []string{"web"}, Concurrency: 1})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, "web01: uptime", results[0].Stdout)
+		assert.Equal(t, 1, results[1].ExitCode)
+		assert.Len(t, dialer.Calls(), 2)
+	})
+
+	t.Run("pinned host keys", func(t *testing.T) {
+		e, servers := setupNetwork(t)
+		h, _ := e.manager.GetHost("web01")
+		h.AddHostKey(inventory.HostKey{KeyType: "ssh-ed25519", Fingerprint: "SHA256:stale"})
+		require.NoError(t, e.manager.UpdateHost(h))
+
+		results, err := e.Exec(context.Background(), ExecOptions{Command: "true", HostIDs: []string{"web01"}})
+		require.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, transport.ErrHostKeyMismatch)
+		assert.Empty(t, servers["web01"].Requests())
+
+		h.AddHostKey(inventory.HostKey{KeyType: "ssh-ed25519", Fingerprint: ssh.FingerprintSHA256(servers["web01"].HostKey())})
+		require.NoError(t, e.manager.UpdateHost(h))
+		results, err = e.Exec(context.Background(), ExecOptions{Command: "true", HostIDs: []string{"web01"}})
+		require.NoError(t, err)
+		assert.True(t, results[0].OK(), results[0].Err)
+	})
+
+	t.Run("workflow transfer", func(t *testing.T) {
+		src := filepath.Join(t.TempDir(), "app.txt")
+		require.NoError(t, os.WriteFile(src, []byte("v3"), 0o644))
+		w := inventory.NewWorkflow("push", "push")
+		w.Target = "host:web01"
+		w.AddStep(inventory.WorkflowStep{Name: "upload", Kind: inventory.StepTransfer, Source: src, Destination: "/srv/app.txt"})
+		require.NoError(t, e.manager.AddWorkflow(w))
+
+		run, err := e.RunWorkflow(context.Background(), "push", WorkflowOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, StepOK, run.Status)
+		assert.Equal(t, map[string]string{"web01:/srv/app.txt": "v3"}, uploads)
+	})
+}
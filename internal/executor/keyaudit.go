@@ -0,0 +1,327 @@
+package executor
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gossher/internal/inventory"
+	"gossher/internal/term"
+)
+
+// keyAuditScript prints a "#user" line per account of the host followed by the
+// authorized_keys files found in its home directory, each introduced by a
+// "#file" line, and "#unreadable" for files the connecting user cannot read.
+const keyAuditScript = `(getent passwd || cat /etc/passwd) 2>/dev/null | while IFS=: read -r name _ uid _ _ home shell; do
+  printf '#user\t%s\t%s\t%s\t%s\n' "$name" "$uid" "$home" "$shell"
+  for f in "$home/.ssh/authorized_keys" "$home/.ssh/authorized_keys2"; do
+    if [ -r "$f" ]; then printf '#file\t%s\n' "$f"; cat "$f"; echo
+    elif [ -e "$f" ]; then printf '#unreadable\t%s\n' "$f"; fi
+  done
+done`
+
+// authorizedKeyPattern finds the key of an authorized_keys line after its
+// options: the key type, the base64 blob and the comment.
+var authorizedKeyPattern = regexp.MustCompile(`(?:^|\s)((?:ssh-|ecdsa-sha2-|sk-ssh-|sk-ecdsa-)\S+)\s+([A-Za-z0-9+/]+=*)(?:\s+(.*))?$`)
+
+// nologinShells are the shells of accounts that cannot log in interactively.
+var nologinShells = []string{"nologin", "false", "sync", "shutdown", "halt"}
+
+// KeyAuditOptions configures AuditKeys.
+type KeyAuditOptions struct {
+	// Sensitive is a target spec of the hosts where unknown keys are findings,
+	// e.g. "tag:env=prod"; empty means every audited host.
+	Sensitive string
+	// KnownKeys are public keys outside the inventory that are expected, e.g. of
+	// colleagues, by name. Keys of inventory credentials are always known.
+	KnownKeys map[string]string
+	// Sudo reads the accounts with "sudo -n", so that the authorized_keys of other
+	// users can be read.
+	Sudo bool
+}
+
+// AuthorizedKey is a key of an authorized_keys file.
+type AuthorizedKey struct {
+	File        string `json:"file"`
+	Type        string `json:"type"`
+	Fingerprint string `json:"fingerprint"`
+	Comment     string `json:"comment,omitempty"`
+	// Options are the options before the key, e.g. from="10.0.0.0/8".
+	Options string `json:"options,omitempty"`
+	// CredentialID is the inventory credential of the key, Known the name of the
+	// matching KeyAuditOptions.KnownKeys entry.
+	CredentialID string `json:"credential_id,omitempty"`
+	Known        string `json:"known,omitempty"`
+}
+
+// Unknown reports whether the key matches neither a credential nor a known key.
+func (k *AuthorizedKey) Unknown() bool {
+	return k.CredentialID == "" && k.Known == ""
+}
+
+// RemoteUser is an account of a host with its authorized keys.
+type RemoteUser struct {
+	Name  string `json:"name"`
+	UID   int    `json:"uid"`
+	Home  string `json:"home"`
+	Shell string `json:"shell"`
+	// Login is set for accounts with a login shell.
+	Login bool            `json:"login"`
+	Keys  []AuthorizedKey `json:"keys,omitempty"`
+	// Unreadable are authorized_keys files that exist but could not be read.
+	Unreadable []string `json:"unreadable,omitempty"`
+}
+
+// HostKeyAudit is the accounts and keys of a host. Accounts without a login shell
+// are only listed when they have keys.
+type HostKeyAudit struct {
+	HostID    string       `json:"host_id"`
+	Sensitive bool         `json:"sensitive"`
+	Users     []RemoteUser `json:"users"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// KeyFinding is an unknown key on a sensitive host.
+type KeyFinding struct {
+	HostID      string `json:"host_id"`
+	User        string `json:"user"`
+	File        string `json:"file"`
+	Fingerprint string `json:"fingerprint"`
+	Comment     string `json:"comment,omitempty"`
+}
+
+// KeyAudit is the result of AuditKeys.
+type KeyAudit struct {
+	Hosts    []HostKeyAudit `json:"hosts"`
+	Findings []KeyFinding   `json:"findings"`
+}
+
+// AuditKeys enumerates the accounts of the selected hosts and their
+// authorized_keys, maps the keys back to inventory credentials and known keys, and
+// reports unknown keys on sensitive hosts as findings.
+func (e *Executor) AuditKeys(ctx context.Context, opts ExecOptions, audit KeyAuditOptions) (*KeyAudit, error) {
+	sensitive := map[string]bool{}
+	if audit.Sensitive != "" {
+		ids, err := e.manager.ResolveTargetSpec(audit.Sensitive)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sensitive hosts: %w", err)
+		}
+		for _, id := range ids {
+			sensitive[id] = true
+		}
+	}
+
+	known, err := e.knownKeys(audit.KnownKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Command = keyAuditScript
+	if audit.Sudo {
+		opts.Command = "sudo -n sh -c " + inventory.ShellQuote(keyAuditScript)
+	}
+	results, err := e.Exec(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &KeyAudit{Findings: []KeyFinding{}}
+	for _, r := range results {
+		h := HostKeyAudit{HostID: r.HostID, Sensitive: audit.Sensitive == "" || sensitive[r.HostID]}
+		switch {
+		case r.Err != nil:
+			h.Error = r.Err.Error()
+		case r.ExitCode != 0:
+			h.Error = fmt.Sprintf("listing accounts exited with status %d: %s", r.ExitCode, strings.TrimSpace(r.Stderr))
+		default:
+			h.Users = parseKeyAudit(r.Stdout, known)
+		}
+		if h.Sensitive {
+			for _, u := range h.Users {
+				for _, k := range u.Keys {
+					if k.Unknown() {
+						report.Findings = append(report.Findings, KeyFinding{
+							HostID: h.HostID, User: u.Name, File: k.File, Fingerprint: k.Fingerprint, Comment: k.Comment,
+						})
+					}
+				}
+			}
+		}
+		report.Hosts = append(report.Hosts, h)
+	}
+	sort.Slice(report.Hosts, func(i, j int) bool { return report.Hosts[i].HostID < report.Hosts[j].HostID })
+	sort.SliceStable(report.Findings, func(i, j int) bool { return report.Findings[i].HostID < report.Findings[j].HostID })
+	return report, nil
+}
+
+// keyOwner names the credential or known key a fingerprint belongs to.
+type keyOwner struct {
+	credentialID string
+	known        string
+}
+
+// knownKeys maps the fingerprints of the inventory's credential keys and of extra
+// known keys to their owner. Credentials whose public key cannot be read are
+// skipped.
+func (e *Executor) knownKeys(extra map[string]string) (map[string]keyOwner, error) {
+	known := make(map[string]keyOwner)
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		k, ok := parseAuthorizedKey(extra[name])
+		if !ok {
+			return nil, fmt.Errorf("known key %s is not a public key", name)
+		}
+		known[k.Fingerprint] = keyOwner{known: name}
+	}
+
+	for _, c := range e.manager.ListCredentials() {
+		if c.KeyPath == "" {
+			continue
+		}
+		pub, err := e.manager.PublicKey(c.ID)
+		if err != nil {
+			continue
+		}
+		if k, ok := parseAuthorizedKey(pub); ok {
+			owner := known[k.Fingerprint]
+			owner.credentialID = c.ID
+			known[k.Fingerprint] = owner
+		}
+	}
+	return known, nil
+}
+
+// parseAuthorizedKey parses an authorized_keys or .pub line.
+func parseAuthorizedKey(line string) (AuthorizedKey, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return AuthorizedKey{}, false
+	}
+	m := authorizedKeyPattern.FindStringSubmatchIndex(line)
+	if m == nil {
+		return AuthorizedKey{}, false
+	}
+	blob, err := base64.StdEncoding.DecodeString(line[m[4]:m[5]])
+	if err != nil {
+		return AuthorizedKey{}, false
+	}
+	k := AuthorizedKey{
+		Type:        line[m[2]:m[3]],
+		Fingerprint: inventory.FingerprintSHA256(blob),
+		Options:     strings.TrimSpace(line[:m[2]]),
+	}
+	if m[6] >= 0 {
+		k.Comment = strings.TrimSpace(line[m[6]:m[7]])
+	}
+	return k, true
+}
+
+// parseKeyAudit parses the output of keyAuditScript.
+func parseKeyAudit(out string, known map[string]keyOwner) []RemoteUser {
+	users := []RemoteUser{}
+	var user *RemoteUser
+	var file string
+	flush := func() {
+		if user != nil && (user.Login || len(user.Keys) > 0 || len(user.Unreadable) > 0) {
+			users = append(users, *user)
+		}
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		switch fields[0] {
+		case "#user":
+			flush()
+			user, file = &RemoteUser{}, ""
+			if len(fields) == 5 {
+				user.Name, user.Home, user.Shell = fields[1], fields[3], fields[4]
+				user.UID, _ = strconv.Atoi(fields[2])
+				user.Login = isLoginShell(user.Shell)
+			}
+		case "#file":
+			if len(fields) == 2 {
+				file = fields[1]
+			}
+		case "#unreadable":
+			if user != nil && len(fields) == 2 {
+				user.Unreadable = append(user.Unreadable, fields[1])
+			}
+		default:
+			if user == nil || file == "" {
+				continue
+			}
+			k, ok := parseAuthorizedKey(line)
+			if !ok {
+				continue
+			}
+			k.File = file
+			owner := known[k.Fingerprint]
+			k.CredentialID, k.Known = owner.credentialID, owner.known
+			user.Keys = append(user.Keys, k)
+		}
+	}
+	flush()
+	return users
+}
+
+// isLoginShell reports whether an account's shell allows logging in.
+func isLoginShell(shell string) bool {
+	if shell == "" {
+		return false
+	}
+	base := shell[strings.LastIndexByte(shell, '/')+1:]
+	for _, s := range nologinShells {
+		if base == s {
+			return false
+		}
+	}
+	return true
+}
+
+// JSON encodes the audit for machine consumption.
+func (a *KeyAudit) JSON() ([]byte, error) {
+	return json.MarshalIndent(a, "", "  ")
+}
+
+// WriteText prints one row per key, followed by the findings.
+func (a *KeyAudit) WriteText(w io.Writer) error {
+	tw := term.NewTable(w)
+	fmt.Fprintln(tw, "HOST\tUSER\tFINGERPRINT\tCOMMENT\tOWNER")
+	for _, h := range a.Hosts {
+		if h.Error != "" {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\terror: %s\n", h.HostID, h.Error)
+			continue
+		}
+		for _, u := range h.Users {
+			for _, k := range u.Keys {
+				owner := "unknown"
+				switch {
+				case k.CredentialID != "":
+					owner = "credential " + k.CredentialID
+				case k.Known != "":
+					owner = k.Known
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", h.HostID, u.Name, k.Fingerprint, k.Comment, owner)
+			}
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\n%d unknown keys on sensitive hosts\n", len(a.Findings))
+	for _, f := range a.Findings {
+		fmt.Fprintf(w, "%s: %s %s (%s) in %s\n", f.HostID, f.User, f.Fingerprint, f.Comment, f.File)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPublicKey(seed, comment string) string {
+	return "ssh-ed25519 " + base64.StdEncoding.EncodeToString([]byte("ed25519-"+seed)) + " " + comment
+}
+
+func TestParseAuthorizedKey(t *testing.T) {
+	k, ok := parseAuthorizedKey(`from="10.0.0.0/8",command="uptime" ` + testPublicKey("a", "ops@laptop"))
+	require.True(t, ok)
+	assert.Equal(t, "ssh-ed25519", k.Type)
+	assert.Equal(t, "ops@laptop", k.Comment)
+	assert.Equal(t, `from="10.0.0.0/8",command="uptime"`, k.Options)
+	assert.Equal(t, inventory.FingerprintSHA256([]byte("ed25519-a")), k.Fingerprint)
+
+	for _, bad := range []string{"", "# comment", "ssh-rsa", "not a key at all"} {
+		_, ok := parseAuthorizedKey(bad)
+		assert.False(t, ok, bad)
+	}
+}
+
+func TestAuditKeys(t *testing.T) {
+	base, inner := setupExecutor(t)
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "id_ed25519")
+	require.NoError(t, os.WriteFile(keyPath+".pub", []byte(testPublicKey("ops", "ops@laptop")+"\n"), 0o600))
+	cred := inventory.NewCredential("ops", "Ops", "deploy")
+	cred.KeyPath = keyPath
+	require.NoError(t, base.manager.AddCredential(cred))
+
+	output := strings.Join([]string{
+		"#user\troot\t0\t/root\t/bin/bash",
+		"#file\t/root/.ssh/authorized_keys",
+		testPublicKey("ops", "ops@laptop"),
+		"# old key",
+		testPublicKey("mallory", "mallory@evil"),
+		"",
+		"#user\tdaemon\t1\t/usr/sbin\t/usr/sbin/nologin",
+		"#user\tbackup\t34\t/var/backups\t/usr/sbin/nologin",
+		"#file\t/var/backups/.ssh/authorized_keys",
+		testPublicKey("alice", "alice@desk"),
+		"#user\tdeploy\t1000\t/home/deploy\t/bin/bash",
+		"#unreadable\t/home/deploy/.ssh/authorized_keys",
+		"",
+	}, "\n")
+	runner := &scriptedRunner{fakeRunner: inner, probes: map[string]map[string]probeReply{
+		"web01": {"(getent passwd": {out: output}, "sudo -n sh -c": {out: output}},
+		"db01":  {"(getent passwd": {out: output}},
+	}}
+	e := New(base.manager, runner)
+
+	audit, err := e.AuditKeys(context.Background(), ExecOptions{HostIDs: []string{"web01", "db01"}}, KeyAuditOptions{
+		Sensitive: "tag:env=prod",
+		KnownKeys: map[string]string{"alice": testPublicKey("alice", "")},
+	})
+	require.NoError(t, err)
+	require.Len(t, audit.Hosts, 2)
+
+	db01 := audit.Hosts[0]
+	assert.Equal(t, "db01", db01.HostID)
+	assert.True(t, db01.Sensitive)
+	require.Len(t, db01.Users, 3, "daemon has no login shell and no keys")
+	root := db01.Users[0]
+	assert.Equal(t, "root", root.Name)
+	assert.True(t, root.Login)
+	require.Len(t, root.Keys, 2)
+	assert.Equal(t, "ops", root.Keys[0].CredentialID)
+	assert.True(t, root.Keys[1].Unknown())
+	assert.Equal(t, "alice", db01.Users[1].Keys[0].Known)
+	assert.Equal(t, []string{"/home/deploy/.ssh/authorized_keys"}, db01.Users[2].Unreadable)
+
+	assert.False(t, audit.Hosts[1].Sensitive)
+	assert.Equal(t, []KeyFinding{{
+		HostID: "db01", User: "root", File: "/root/.ssh/authorized_keys",
+		Fingerprint: inventory.FingerprintSHA256([]byte("ed25519-mallory")), Comment: "mallory@evil",
+	}}, audit.Findings)
+
+	t.Run("sudo and invalid options", func(t *testing.T) {
+		audit, err := e.AuditKeys(context.Background(), ExecOptions{HostIDs: []string{"web01"}}, KeyAuditOptions{Sudo: true})
+		require.NoError(t, err)
+		assert.True(t, audit.Hosts[0].Sensitive, "every host is sensitive by default")
+		assert.Len(t, audit.Findings, 2)
+
+		_, err = e.AuditKeys(context.Background(), ExecOptions{HostIDs: []string{"web01"}}, KeyAuditOptions{KnownKeys: map[string]string{"x": "nope"}})
+		assert.Error(t, err)
+		_, err = e.AuditKeys(context.Background(), ExecOptions{HostIDs: []string{"web01"}}, KeyAuditOptions{Sensitive: "bogus:x"})
+		assert.Error(t, err)
+	})
+}
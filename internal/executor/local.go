@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"gossher/internal/inventory"
+)
+
+// Ensure LocalRunner implements the interfaces
+var (
+	_ Runner     = LocalRunner{}
+	_ Uploader   = LocalRunner{}
+	_ Downloader = LocalRunner{}
+)
+
+// localWaitDelay bounds the wait for output after a cancelled command was killed.
+const localWaitDelay = time.Second
+
+// LocalRunner runs commands on this machine through the system shell. The executor
+// uses it for hosts with inventory.ConnectionLocal, so local steps report the same
+// results as remote ones.
+type LocalRunner struct{}
+
+// Run runs command with "sh -c" ("cmd /C" on Windows).
+func (LocalRunner) Run(ctx context.Context, conn *inventory.ResolvedConnection, command string, stdout, stderr io.Writer) (int, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	default:
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Children of a killed shell may keep the output open; stop waiting for them.
+	cmd.WaitDelay = localWaitDelay
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return -1, ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, fmt.Errorf("failed to run local command: %w", err)
+	}
+	return 0, nil
+}
+
+// Upload copies src to remotePath on this machine.
+func (LocalRunner) Upload(ctx context.Context, conn *inventory.ResolvedConnection, src io.Reader, remotePath string, mode os.FileMode) error {
+	f, err := os.OpenFile(inventory.ExpandPath(remotePath), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", remotePath, err)
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", remotePath, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", remotePath, err)
+	}
+	return os.Chmod(inventory.ExpandPath(remotePath), mode)
+}
+
+// Download copies the file at remotePath on this machine to dst.
+func (LocalRunner) Download(ctx context.Context, conn *inventory.ResolvedConnection, remotePath string, dst io.Writer) error {
+	f, err := os.Open(inventory.ExpandPath(remotePath))
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", remotePath, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(dst, f); err != nil {
+		return fmt.Errorf("failed to read %s: %w", remotePath, err)
+	}
+	return nil
+}
+
+// runnerFor returns the runner that reaches a connection's host.
+func (e *Executor) runnerFor(conn *inventory.ResolvedConnection) Runner {
+	if conn.Local {
+		return e.local
+	}
+	return e.runner
+}
@@ -0,0 +1,61 @@
+package executor
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+
+	t.Run("exit codes and output", func(t *testing.T) {
+		results, err := runLocal(t, "echo out; echo err >&2; exit 3", 0)
+		require.NoError(t, err)
+		assert.Equal(t, "out\n", results[0].Stdout)
+		assert.Equal(t, "err\n", results[0].Stderr)
+		assert.Equal(t, 3, results[0].ExitCode)
+		assert.NoError(t, results[0].Err)
+	})
+
+	t.Run("timeouts kill the command", func(t *testing.T) {
+		results, err := runLocal(t, "sleep 5", 50*time.Millisecond)
+		require.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, context.DeadlineExceeded)
+	})
+
+	t.Run("mixes with remote hosts", func(t *testing.T) {
+		e, runner := setupExecutor(t)
+		build := inventory.NewLocalHost("build", "build")
+		require.NoError(t, e.manager.AddHost(build))
+
+		results, err := e.Exec(context.Background(), ExecOptions{
+			Command: "echo {{.HostID}}",
+			HostIDs: []string{"build", "web01"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "build\n", results[0].Stdout)
+		assert.Equal(t, "deploy@web01: echo web01", results[1].Stdout)
+		assert.Equal(t, []string{"web01"}, runner.ran, "local hosts bypass the runner")
+
+		plan, err := e.Plan(context.Background(), ExecOptions{Command: "make", HostIDs: []string{"build"}})
+		require.NoError(t, err)
+		assert.Equal(t, "local", plan.Hosts[0].Destination)
+		assert.True(t, plan.Hosts[0].Reachable)
+	})
+}
+
+// runLocal runs a command on a local pseudo-host.
+func runLocal(t *testing.T, command string, timeout time.Duration) ([]Result, error) {
+	e, _ := setupExecutor(t)
+	require.NoError(t, e.manager.AddHost(inventory.NewLocalHost("build", "build")))
+	return e.Exec(context.Background(), ExecOptions{Command: command, HostIDs: []string{"build"}, Timeout: timeout})
+}
@@ -0,0 +1,447 @@
+package executor
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PackagesDir is the subdirectory of the data directory holding the package
+// documents of hosts.
+const PackagesDir = "packages"
+
+// packagesCommand prints the package manager found on the host followed by one
+// "name<TAB>version<TAB>arch" line per installed package (apk prints its own
+// list format).
+const packagesCommand = `if command -v dpkg-query >/dev/null 2>&1; then echo dpkg; dpkg-query -W -f='${db:Status-Abbrev}\t${Package}\t${Version}\t${Architecture}\n' | sed -n 's/^ii \t//p'
+elif command -v rpm >/dev/null 2>&1; then echo rpm; rpm -qa --qf '%{NAME}\t%{EPOCH}:%{VERSION}-%{RELEASE}\t%{ARCH}\n'
+elif command -v apk >/dev/null 2>&1; then echo apk; apk list --installed 2>/dev/null
+else echo 'no supported package manager (dpkg, rpm, apk)' >&2; exit 3; fi`
+
+// Package is an installed package.
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Arch    string `json:"arch,omitempty"`
+}
+
+// PackageFacts is the document of the packages installed on a host.
+type PackageFacts struct {
+	HostID string `json:"host_id"`
+	// Manager is the package manager: dpkg, rpm or apk.
+	Manager   string    `json:"manager"`
+	Collected time.Time `json:"collected"`
+	Packages  []Package `json:"packages"`
+	// Err is set when the packages could not be listed; such documents are not
+	// stored.
+	Err error `json:"-"`
+}
+
+// Find returns the installed versions of a package, several for multi-arch or
+// multi-version installs.
+func (f *PackageFacts) Find(name string) []Package {
+	var found []Package
+	for _, p := range f.Packages {
+		if p.Name == name {
+			found = append(found, p)
+		}
+	}
+	return found
+}
+
+// CollectPackages lists the installed packages of the selected hosts with dpkg,
+// rpm or apk, whichever the host has. The documents of hosts that could be listed
+// are saved to store unless it is nil. Lists cut by the MaxOutput tuning of the
+// executor are reported as errors.
+func (e *Executor) CollectPackages(ctx context.Context, opts ExecOptions, store *PackageStore) ([]PackageFacts, error) {
+	opts.Command = packagesCommand
+	results, err := e.Exec(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]PackageFacts, len(results))
+	for i, r := range results {
+		doc := PackageFacts{HostID: r.HostID, Collected: r.Started.UTC()}
+		switch {
+		case r.Err != nil:
+			doc.Err = r.Err
+		case r.ExitCode != 0:
+			doc.Err = fmt.Errorf("listing packages exited with status %d: %s", r.ExitCode, strings.TrimSpace(r.Stderr))
+		default:
+			doc.Manager, doc.Packages, doc.Err = parsePackages(r.Stdout)
+		}
+		if doc.Err == nil && store != nil {
+			if err := store.Save(&doc); err != nil {
+				doc.Err = err
+			}
+		}
+		docs[i] = doc
+	}
+	return docs, nil
+}
+
+// droppedOutput matches the note limitedBuffer appends to cut output.
+var droppedOutput = regexp.MustCompile(`\n\[\d+ bytes of output dropped\]$`)
+
+// parsePackages parses the output of packagesCommand.
+func parsePackages(out string) (string, []Package, error) {
+	if droppedOutput.MatchString(out) {
+		return "", nil, fmt.Errorf("package list exceeds the executor's max output")
+	}
+	manager, list, _ := strings.Cut(out, "\n")
+	manager = strings.TrimSpace(manager)
+
+	var packages []Package
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		var p Package
+		switch manager {
+		case "dpkg", "rpm":
+			fields := strings.Split(line, "\t")
+			if len(fields) < 2 {
+				return "", nil, fmt.Errorf("unexpected %s output: %q", manager, line)
+			}
+			p = Package{Name: fields[0], Version: strings.TrimPrefix(fields[1], "(none):")}
+			if len(fields) > 2 && fields[2] != "(none)" {
+				p.Arch = fields[2]
+			}
+		case "apk":
+			var ok bool
+			if p, ok = parseAPKLine(line); !ok {
+				return "", nil, fmt.Errorf("unexpected apk output: %q", line)
+			}
+		default:
+			return "", nil, fmt.Errorf("unsupported package manager %q", manager)
+		}
+		packages = append(packages, p)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Name != packages[j].Name {
+			return packages[i].Name < packages[j].Name
+		}
+		return packages[i].Arch < packages[j].Arch
+	})
+	return manager, packages, nil
+}
+
+// parseAPKLine parses a line of "apk list --installed", e.g.
+// "openssl-3.1.4-r5 x86_64 {openssl} (Apache-2.0) [installed]".
+func parseAPKLine(line string) (Package, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return Package{}, false
+	}
+	parts := strings.Split(fields[0], "-")
+	if len(parts) < 3 {
+		return Package{}, false
+	}
+	n := len(parts)
+	return Package{
+		Name:    strings.Join(parts[:n-2], "-"),
+		Version: parts[n-2] + "-" + parts[n-1],
+		Arch:    fields[1],
+	}, true
+}
+
+// ===== Store =====
+
+// PackageStore keeps the package document of each host as a JSON file.
+type PackageStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewPackageStore creates a store under dataDir/packages.
+func NewPackageStore(dataDir string) *PackageStore {
+	return &PackageStore{dir: filepath.Join(dataDir, PackagesDir)}
+}
+
+// Save stores the document of a host, replacing the previous one.
+func (s *PackageStore) Save(doc *PackageFacts) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.path(doc.HostID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal packages: %w", err)
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create packages directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write packages of %s: %w", doc.HostID, err)
+	}
+	return nil
+}
+
+// Get returns the document of a host.
+func (s *PackageStore) Get(hostID string) (*PackageFacts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(hostID)
+}
+
+// List returns the documents of all hosts, sorted by host.
+func (s *PackageStore) List() ([]*PackageFacts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list packages: %w", err)
+	}
+
+	var docs []*PackageFacts
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		doc, err := s.read(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].HostID < docs[j].HostID })
+	return docs, nil
+}
+
+// Query returns the installed packages of all hosts matching a query.
+func (s *PackageStore) Query(q PackageQuery) ([]PackageMatch, error) {
+	docs, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	return q.Match(docs), nil
+}
+
+// path returns the file of a host. Caller must hold the lock.
+func (s *PackageStore) path(hostID string) (string, error) {
+	if hostID == "" || strings.ContainsAny(hostID, `/\`) || strings.HasPrefix(hostID, ".") {
+		return "", fmt.Errorf("invalid host ID %q", hostID)
+	}
+	return filepath.Join(s.dir, hostID+".json"), nil
+}
+
+// read loads the document of a host. Caller must hold the lock.
+func (s *PackageStore) read(hostID string) (*PackageFacts, error) {
+	path, err := s.path(hostID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no packages collected for host %s", hostID)
+		}
+		return nil, fmt.Errorf("failed to read packages of %s: %w", hostID, err)
+	}
+
+	var doc PackageFacts
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse packages of %s: %w", hostID, err)
+	}
+	return &doc, nil
+}
+
+// ===== Queries =====
+
+// packageQueryPattern matches "name", "name op version" and "name op version"
+// without spaces, e.g. "openssl<3.0.7".
+var packageQueryPattern = regexp.MustCompile(`^\s*([A-Za-z0-9][A-Za-z0-9+._:-]*?)\s*(?:(<=|>=|!=|==|=|<|>)\s*(\S+))?\s*$`)
+
+// PackageQuery selects installed packages by name and optionally version, e.g.
+// "openssl < 3.0.7" for the hosts needing a security update.
+type PackageQuery struct {
+	Name string
+	// Op is one of <, <=, =, !=, >= and >, or empty to match any version.
+	Op      string
+	Version string
+}
+
+// PackageMatch is a package of a host matching a query.
+type PackageMatch struct {
+	HostID  string  `json:"host_id"`
+	Manager string  `json:"manager"`
+	Package Package `json:"package"`
+}
+
+// ParsePackageQuery parses a query like "openssl < 3.0.7" or "openssl".
+func ParsePackageQuery(s string) (PackageQuery, error) {
+	m := packageQueryPattern.FindStringSubmatch(s)
+	if m == nil {
+		return PackageQuery{}, fmt.Errorf("invalid package query %q (expected: name [<|<=|=|!=|>=|> version])", s)
+	}
+	op := m[2]
+	if op == "==" {
+		op = "="
+	}
+	return PackageQuery{Name: m[1], Op: op, Version: m[3]}, nil
+}
+
+// String returns the query as parsed by ParsePackageQuery.
+func (q PackageQuery) String() string {
+	if q.Op == "" {
+		return q.Name
+	}
+	return q.Name + " " + q.Op + " " + q.Version
+}
+
+// Matches reports whether a package matches the query.
+func (q PackageQuery) Matches(p Package) bool {
+	if p.Name != q.Name {
+		return false
+	}
+	c := CompareVersions(p.Version, q.Version)
+	switch q.Op {
+	case "":
+		return true
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case ">=":
+		return c >= 0
+	case ">":
+		return c > 0
+	}
+	return false
+}
+
+// Match returns the packages of the documents matching the query. Hosts without
+// the package never match.
+func (q PackageQuery) Match(docs []*PackageFacts) []PackageMatch {
+	var matches []PackageMatch
+	for _, doc := range docs {
+		for _, p := range doc.Find(q.Name) {
+			if q.Matches(p) {
+				matches = append(matches, PackageMatch{HostID: doc.HostID, Manager: doc.Manager, Package: p})
+			}
+		}
+	}
+	return matches
+}
+
+// CompareVersions compares package versions the way dpkg does, which also orders
+// rpm and apk versions sensibly: [epoch:]upstream[-revision], where digits compare
+// numerically, letters sort before other characters and "~" sorts before
+// anything, even the end of the version (1.0~rc1 < 1.0).
+func CompareVersions(a, b string) int {
+	ea, ua, ra := splitVersion(a)
+	eb, ub, rb := splitVersion(b)
+	if c := compareNumeric(ea, eb); c != 0 {
+		return c
+	}
+	if c := compareVersionPart(ua, ub); c != 0 {
+		return c
+	}
+	return compareVersionPart(ra, rb)
+}
+
+// splitVersion splits a version into epoch, upstream version and revision.
+func splitVersion(v string) (epoch, upstream, revision string) {
+	upstream = v
+	if i := strings.IndexByte(upstream, ':'); i >= 0 && isDigits(upstream[:i]) {
+		epoch, upstream = upstream[:i], upstream[i+1:]
+	}
+	if i := strings.LastIndexByte(upstream, '-'); i >= 0 {
+		upstream, revision = upstream[:i], upstream[i+1:]
+	}
+	return epoch, upstream, revision
+}
+
+// compareVersionPart compares upstream versions or revisions: alternating
+// non-digit runs compared character by character and digit runs compared
+// numerically.
+func compareVersionPart(a, b string) int {
+	for a != "" || b != "" {
+		for (a != "" && !isDigit(a[0])) || (b != "" && !isDigit(b[0])) {
+			var ca, cb byte
+			if a != "" {
+				ca = a[0]
+			}
+			if b != "" {
+				cb = b[0]
+			}
+			if c := cmp.Compare(versionCharOrder(ca), versionCharOrder(cb)); c != 0 {
+				return c
+			}
+			if a != "" {
+				a = a[1:]
+			}
+			if b != "" {
+				b = b[1:]
+			}
+		}
+
+		na, nb := leadingDigits(a), leadingDigits(b)
+		a, b = a[len(na):], b[len(nb):]
+		if c := compareNumeric(na, nb); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// versionCharOrder is the sort weight of a non-digit character of a version; 0
+// stands for the end of the run.
+func versionCharOrder(c byte) int {
+	switch {
+	case c == 0 || isDigit(c):
+		return 0
+	case c == '~':
+		return -1
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		return int(c)
+	default:
+		return int(c) + 256
+	}
+}
+
+// compareNumeric compares digit strings of any length.
+func compareNumeric(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if c := cmp.Compare(len(a), len(b)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i]
+}
+
+func isDigits(s string) bool {
+	return s != "" && leadingDigits(s) == s
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,151 @@
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"3.0.2-0ubuntu1.10", "3.0.2-0ubuntu1.9", 1},
+		{"3.0.7", "3.0.10", -1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0", "1.0+deb1", -1},
+		{"1:1.0", "2.0", 1},
+		{"1.1.1k-12.el8_9", "1.1.1k-9.el8_7", 1},
+		{"1.1.1k", "1.1.1l", -1},
+		{"3.1.4-r5", "3.1.4-r5", 0},
+		{"007", "7", 0},
+	} {
+		t.Run(tc.a+" vs "+tc.b, func(t *testing.T) {
+			assert.Equal(t, tc.want, CompareVersions(tc.a, tc.b))
+			assert.Equal(t, -tc.want, CompareVersions(tc.b, tc.a))
+		})
+	}
+}
+
+func TestPackageQuery(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		q, err := ParsePackageQuery("openssl < 3.0.7")
+		require.NoError(t, err)
+		assert.Equal(t, PackageQuery{Name: "openssl", Op: "<", Version: "3.0.7"}, q)
+		assert.Equal(t, "openssl < 3.0.7", q.String())
+
+		q, err = ParsePackageQuery("libssl3>=3.0.2-0ubuntu1")
+		require.NoError(t, err)
+		assert.Equal(t, PackageQuery{Name: "libssl3", Op: ">=", Version: "3.0.2-0ubuntu1"}, q)
+
+		q, err = ParsePackageQuery("bash == 5.1")
+		require.NoError(t, err)
+		assert.Equal(t, "=", q.Op)
+
+		q, err = ParsePackageQuery("curl")
+		require.NoError(t, err)
+		assert.Equal(t, PackageQuery{Name: "curl"}, q)
+
+		for _, bad := range []string{"", "openssl <", "< 3.0", "openssl ~ 3"} {
+			_, err := ParsePackageQuery(bad)
+			assert.Error(t, err, bad)
+		}
+	})
+
+	t.Run("match", func(t *testing.T) {
+		docs := []*PackageFacts{
+			{HostID: "web01", Manager: "dpkg", Packages: []Package{{Name: "openssl", Version: "3.0.2-0ubuntu1.10", Arch: "amd64"}}},
+			{HostID: "web02", Manager: "rpm", Packages: []Package{{Name: "openssl", Version: "1:3.0.7-24.el9", Arch: "x86_64"}}},
+			{HostID: "db01", Manager: "apk", Packages: []Package{{Name: "curl", Version: "8.5.0-r0"}}},
+		}
+		q, err := ParsePackageQuery("openssl < 3.0.7")
+		require.NoError(t, err)
+		matches := q.Match(docs)
+		require.Len(t, matches, 1)
+		assert.Equal(t, "web01", matches[0].HostID)
+		assert.Equal(t, "dpkg", matches[0].Manager)
+
+		q, _ = ParsePackageQuery("openssl")
+		assert.Len(t, q.Match(docs), 2)
+	})
+}
+
+func TestParsePackages(t *testing.T) {
+	t.Run("dpkg", func(t *testing.T) {
+		manager, pkgs, err := parsePackages("dpkg\nzlib1g\t1:1.2.13.dfsg-1\tamd64\nbash\t5.2.15-2+b2\tamd64\n")
+		require.NoError(t, err)
+		assert.Equal(t, "dpkg", manager)
+		assert.Equal(t, []Package{
+			{Name: "bash", Version: "5.2.15-2+b2", Arch: "amd64"},
+			{Name: "zlib1g", Version: "1:1.2.13.dfsg-1", Arch: "amd64"},
+		}, pkgs)
+	})
+
+	t.Run("rpm", func(t *testing.T) {
+		_, pkgs, err := parsePackages("rpm\ngpg-pubkey\t(none):fd431d51-4ae0493b\t(none)\nopenssl\t1:3.0.7-24.el9\tx86_64\n")
+		require.NoError(t, err)
+		assert.Equal(t, []Package{
+			{Name: "gpg-pubkey", Version: "fd431d51-4ae0493b"},
+			{Name: "openssl", Version: "1:3.0.7-24.el9", Arch: "x86_64"},
+		}, pkgs)
+	})
+
+	t.Run("apk", func(t *testing.T) {
+		_, pkgs, err := parsePackages("apk\nca-certificates-bundle-20240226-r0 x86_64 {ca-certificates} (MPL-2.0 AND MIT) [installed]\nopenssl-3.1.4-r5 x86_64 {openssl} (Apache-2.0) [installed]\n")
+		require.NoError(t, err)
+		assert.Equal(t, []Package{
+			{Name: "ca-certificates-bundle", Version: "20240226-r0", Arch: "x86_64"},
+			{Name: "openssl", Version: "3.1.4-r5", Arch: "x86_64"},
+		}, pkgs)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, _, err := parsePackages("pacman\nbash 5.2\n")
+		assert.Error(t, err)
+		_, _, err = parsePackages("dpkg\nbash\t5.2\tamd64\nzl\n[12 bytes of output dropped]")
+		assert.ErrorContains(t, err, "max output")
+	})
+}
+
+func TestCollectPackages(t *testing.T) {
+	base, inner := setupExecutor(t)
+	runner := &scriptedRunner{fakeRunner: inner, probes: map[string]map[string]probeReply{
+		"web01": {"if command -v dpkg-query": {out: "dpkg\nopenssl\t3.0.2-0ubuntu1.10\tamd64\n"}},
+		"web02": {"if command -v dpkg-query": {out: "rpm\nopenssl\t1:3.0.7-24.el9\tx86_64\n"}},
+		"db01":  {"if command -v dpkg-query": {code: 3}},
+	}}
+	e := New(base.manager, runner)
+	store := NewPackageStore(t.TempDir())
+
+	docs, err := e.CollectPackages(context.Background(), ExecOptions{Groups: []string{"all"}}, store)
+	require.NoError(t, err)
+	require.Len(t, docs, 3)
+	for _, doc := range docs {
+		if doc.HostID == "db01" {
+			assert.Error(t, doc.Err)
+		} else {
+			assert.NoError(t, doc.Err)
+		}
+	}
+
+	stored, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.Equal(t, "web01", stored[0].HostID)
+	assert.False(t, stored[0].Collected.IsZero())
+
+	web02, err := store.Get("web02")
+	require.NoError(t, err)
+	assert.Equal(t, "rpm", web02.Manager)
+	_, err = store.Get("db01")
+	assert.Error(t, err)
+
+	q, _ := ParsePackageQuery("openssl < 3.0.7")
+	matches, err := store.Query(q)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "web01", matches[0].HostID)
+}
@@ -0,0 +1,69 @@
+package executor
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"gossher/internal/inventory"
+)
+
+// GroupPick selects the representative host of a group when a command runs on
+// one host per group, e.g. a read-only query on one replica of each cluster.
+type GroupPick string
+
+const (
+	// GroupPickFirst picks the first member in group order.
+	GroupPickFirst GroupPick = "first"
+	// GroupPickRandom picks a random member.
+	GroupPickRandom GroupPick = "random"
+	// GroupPickLatency picks the member with the lowest median round trip, then
+	// handshake, as recorded in the link statistics; members without statistics
+	// come last, in group order.
+	GroupPickLatency GroupPick = "latency"
+)
+
+// Validate checks that the pick is a known value. An empty pick disables the one
+// host per group mode.
+func (p GroupPick) Validate() error {
+	switch p {
+	case "", GroupPickFirst, GroupPickRandom, GroupPickLatency:
+		return nil
+	}
+	return fmt.Errorf("invalid group pick: %s", p)
+}
+
+// pickMember returns the representative of the members of a group. Passive
+// members were already left out by the caller.
+func (e *Executor) pickMember(members []inventory.GroupMember, pick GroupPick) string {
+	switch pick {
+	case GroupPickRandom:
+		return members[rand.IntN(len(members))].HostID
+	case GroupPickLatency:
+		best, bestLatency := members[0].HostID, time.Duration(-1)
+		for _, gm := range members {
+			latency, ok := e.latency(gm.HostID)
+			if ok && (bestLatency < 0 || latency < bestLatency) {
+				best, bestLatency = gm.HostID, latency
+			}
+		}
+		return best
+	default:
+		return members[0].HostID
+	}
+}
+
+// latency returns the median round trip of a host, or its median handshake when
+// no command round trip was recorded.
+func (e *Executor) latency(hostID string) (time.Duration, bool) {
+	stats, ok := e.manager.LinkStats(hostID)
+	switch {
+	case !ok:
+		return 0, false
+	case stats.RoundTrip.Count > 0:
+		return stats.RoundTrip.P50, true
+	case stats.Handshake.Count > 0:
+		return stats.Handshake.P50, true
+	}
+	return 0, false
+}
@@ -0,0 +1,83 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnePerGroup(t *testing.T) {
+	setup := func(t *testing.T) *Executor {
+		e, _ := setupExecutor(t)
+		for _, id := range []string{"db02", "db03"} {
+			h := inventory.NewHost(id, id, "10.0.0.2")
+			h.User = "deploy"
+			require.NoError(t, e.manager.AddHost(h))
+		}
+		db := inventory.NewGroup("db")
+		db.HostIDs = []string{"db01", "db02", "db03"}
+		require.NoError(t, e.manager.AddGroup(db))
+		return e
+	}
+
+	t.Run("first", func(t *testing.T) {
+		e := setup(t)
+		targets, err := e.ResolveTargets(ExecOptions{Groups: []string{"web", "db"}, OnePerGroup: GroupPickFirst})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web01", "db01"}, targets)
+
+		require.NoError(t, e.manager.SetGroupHostPriority("db", "db01", 0))
+		require.NoError(t, e.manager.SetGroupHostPriority("db", "db03", 3))
+		targets, err = e.ResolveTargets(ExecOptions{Groups: []string{"db"}, OnePerGroup: GroupPickFirst})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"db02"}, targets)
+		targets, err = e.ResolveTargets(ExecOptions{Groups: []string{"db"}, OnePerGroup: GroupPickFirst, ByPriority: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"db03"}, targets)
+	})
+
+	t.Run("random", func(t *testing.T) {
+		e := setup(t)
+		for range 10 {
+			targets, err := e.ResolveTargets(ExecOptions{Groups: []string{"db"}, OnePerGroup: GroupPickRandom})
+			require.NoError(t, err)
+			require.Len(t, targets, 1)
+			assert.Contains(t, []string{"db01", "db02", "db03"}, targets[0])
+		}
+	})
+
+	t.Run("lowest latency", func(t *testing.T) {
+		e := setup(t)
+		targets, err := e.ResolveTargets(ExecOptions{Groups: []string{"db"}, OnePerGroup: GroupPickLatency})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"db01"}, targets, "falls back to the group order")
+
+		e.manager.RecordRoundTrip("db01", 80*time.Millisecond)
+		e.manager.RecordRoundTrip("db03", 20*time.Millisecond)
+		e.manager.RecordHandshake("db02", 50*time.Millisecond)
+		targets, err = e.ResolveTargets(ExecOptions{Groups: []string{"db"}, OnePerGroup: GroupPickLatency})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"db03"}, targets)
+	})
+
+	t.Run("runs once per group", func(t *testing.T) {
+		e := setup(t)
+		results, err := e.Exec(context.Background(), ExecOptions{
+			Command:     "SELECT 1",
+			Groups:      []string{"web", "db"},
+			OnePerGroup: GroupPickFirst,
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, "web01", results[0].HostID)
+		assert.Equal(t, "db01", results[1].HostID)
+
+		_, err = e.Exec(context.Background(), ExecOptions{Command: "id", Groups: []string{"db"}, OnePerGroup: "fastest"})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,217 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/redact"
+	"gossher/internal/term"
+	"gossher/internal/transport"
+)
+
+// DefaultCheckTimeout bounds connectivity checks during dry runs without ExecOptions.Timeout.
+const DefaultCheckTimeout = 5 * time.Second
+
+// Checker is implemented by runners that can test a connection without running a
+// command. Dry runs fall back to a TCP dial of the first hop otherwise.
+type Checker interface {
+	Check(ctx context.Context, conn *inventory.ResolvedConnection) error
+}
+
+// SudoStatus describes whether a command can use sudo on a host.
+type SudoStatus string
+
+const (
+	SudoNotRequired      SudoStatus = ""
+	SudoPasswordless     SudoStatus = "passwordless"
+	SudoPasswordRequired SudoStatus = "password-required"
+	SudoUnknown          SudoStatus = "unknown"
+)
+
+// Plan describes what an execution would do without running anything.
+type Plan struct {
+	Command string `yaml:"command" json:"command"`
+	// Targets is the resolved, de-duplicated host list in execution order.
+	Targets    []string                     `yaml:"targets" json:"targets"`
+	Hosts      []HostPlan                   `yaml:"hosts" json:"hosts"`
+	Violations []inventory.CommandViolation `yaml:"violations,omitempty" json:"violations,omitempty"`
+	// Blocked is set when the command policy would stop the run.
+	Blocked bool `yaml:"blocked" json:"blocked"`
+}
+
+// HostPlan is the part of a Plan for one host.
+type HostPlan struct {
+	HostID      string   `yaml:"host_id" json:"host_id"`
+	Destination string   `yaml:"destination" json:"destination"`
+	Jumps       []string `yaml:"jumps,omitempty" json:"jumps,omitempty"`
+	Command     string   `yaml:"command" json:"command"`
+
+	// Checked is set when connectivity was tested; Reachable holds the outcome.
+	Checked   bool       `yaml:"checked" json:"checked"`
+	Reachable bool       `yaml:"reachable" json:"reachable"`
+	Sudo      SudoStatus `yaml:"sudo,omitempty" json:"sudo,omitempty"`
+
+	Error string `yaml:"error,omitempty" json:"error,omitempty"`
+}
+
+// OK reports whether the host is expected to run the command.
+func (p *HostPlan) OK() bool {
+	return p.Error == "" && (!p.Checked || p.Reachable) && p.Sudo != SudoPasswordRequired
+}
+
+// Plan resolves targets, renders the command for each host and, unless
+// opts.SkipChecks is set, checks connectivity and sudo access.
+func (e *Executor) Plan(ctx context.Context, opts ExecOptions) (*Plan, error) {
+	targets, err := e.ResolveTargets(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	violations, err := e.violations(targets, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		Command:    opts.Command,
+		Targets:    targets,
+		Violations: violations,
+		Blocked:    len(violations) > 0 && !opts.Force,
+	}
+
+	plan.Hosts = forEachHost(ctx, targets, e.concurrency(opts.Concurrency), func(ctx context.Context, hostID string) HostPlan {
+		return e.planHost(ctx, hostID, opts)
+	}, func(hostID string, err error) HostPlan {
+		return HostPlan{HostID: hostID, Error: err.Error()}
+	})
+
+	return plan, nil
+}
+
+// planHost plans the command on one host.
+func (e *Executor) planHost(ctx context.Context, hostID string, opts ExecOptions) HostPlan {
+	p := HostPlan{HostID: hostID}
+
+	conn, err := e.manager.ResolveConnection(hostID)
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	p.Destination = conn.HostPort()
+	if conn.User != "" {
+		p.Destination = conn.User + "@" + p.Destination
+	}
+	if conn.Local {
+		p.Destination = string(inventory.ConnectionLocal)
+	}
+	for _, j := range conn.Jumps {
+		p.Jumps = append(p.Jumps, j.HostID)
+	}
+
+	command, err := e.renderCommand(opts.Command, hostID, opts.Inputs)
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	p.Command = redact.String(command)
+
+	if needsSudo(command) && conn.User != "root" {
+		p.Sudo = SudoUnknown
+	}
+	if opts.SkipChecks {
+		return p
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCheckTimeout
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	p.Checked = true
+	if err := e.checkConnection(checkCtx, conn); err != nil {
+		p.Error = fmt.Sprintf("unreachable: %v", err)
+		return p
+	}
+	p.Reachable = true
+
+	if p.Sudo == SudoUnknown {
+		if err := conn.ResolveSecrets(); err == nil {
+			var discard bytes.Buffer
+			code, err := e.runnerFor(conn).Run(checkCtx, conn, "sudo -n true", &discard, &discard)
+			switch {
+			case err != nil:
+				// Leave unknown
+			case code == 0:
+				p.Sudo = SudoPasswordless
+			default:
+				p.Sudo = SudoPasswordRequired
+			}
+		}
+	}
+	return p
+}
+
+// checkConnection tests whether a host can be reached.
+func (e *Executor) checkConnection(ctx context.Context, conn *inventory.ResolvedConnection) error {
+	if conn.Local {
+		return nil
+	}
+	if c, ok := e.runner.(Checker); ok {
+		return c.Check(ctx, conn)
+	}
+
+	c, err := transport.DialFirstHop(ctx, conn, nil)
+	if err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+// JSON renders the plan as indented JSON.
+func (p *Plan) JSON() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// WriteText prints what would run where.
+func (p *Plan) WriteText(w io.Writer) error {
+	tw := term.NewTable(w)
+
+	fmt.Fprintf(tw, "HOST\tDESTINATION\tSTATUS\tCOMMAND\n")
+	for _, h := range p.Hosts {
+		status := "ok"
+		switch {
+		case h.Error != "":
+			status = h.Error
+		case !h.Checked:
+			status = "not checked"
+		case h.Sudo == SudoPasswordRequired:
+			status = "sudo needs password"
+		}
+		dest := h.Destination
+		for i := len(h.Jumps) - 1; i >= 0; i-- {
+			dest += " via " + h.Jumps[i]
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", h.HostID, dest, status, h.Command)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(p.Violations) > 0 {
+		fmt.Fprintln(w)
+		for _, v := range p.Violations {
+			fmt.Fprintln(w, v.String())
+		}
+		if p.Blocked {
+			fmt.Fprintln(w, "The command would be blocked; use --force to run it anyway.")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkingRunner adds connectivity checks to fakeRunner and answers sudo probes.
+type checkingRunner struct {
+	*fakeRunner
+	down         map[string]bool
+	sudoPassword map[string]bool
+}
+
+func (r *checkingRunner) Check(ctx context.Context, conn *inventory.ResolvedConnection) error {
+	if r.down[conn.HostID] {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (r *checkingRunner) Run(ctx context.Context, conn *inventory.ResolvedConnection, command string, stdout, stderr io.Writer) (int, error) {
+	if command == "sudo -n true" {
+		if r.sudoPassword[conn.HostID] {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return r.fakeRunner.Run(ctx, conn, command, stdout, stderr)
+}
+
+func TestPlan(t *testing.T) {
+	newExecutor := func(t *testing.T) (*Executor, *checkingRunner) {
+		base, inner := setupExecutor(t)
+		runner := &checkingRunner{fakeRunner: inner, down: map[string]bool{}, sudoPassword: map[string]bool{}}
+		return New(base.manager, runner), runner
+	}
+
+	t.Run("renders templates per host", func(t *testing.T) {
+		e, runner := newExecutor(t)
+		g, _ := e.manager.GetGroup("web")
+		g.SetVar("service", "nginx")
+		require.NoError(t, e.manager.UpdateGroup(g))
+
+		plan, err := e.Plan(context.Background(), ExecOptions{
+			Command:    "systemctl restart {{.Vars.service}} # {{.HostID}}",
+			Groups:     []string{"web"},
+			SkipChecks: true,
+		})
+		require.NoError(t, err)
+		require.Len(t, plan.Hosts, 2)
+		assert.Equal(t, "systemctl restart nginx # web01", plan.Hosts[0].Command)
+		assert.Equal(t, "deploy@10.0.0.1:22", plan.Hosts[0].Destination)
+		assert.False(t, plan.Hosts[0].Checked)
+		assert.Empty(t, runner.ran, "dry runs never execute")
+	})
+
+	t.Run("missing vars are reported per host", func(t *testing.T) {
+		e, _ := newExecutor(t)
+
+		plan, err := e.Plan(context.Background(), ExecOptions{
+			Command:    "echo {{.Vars.missing}}",
+			HostIDs:    []string{"web01"},
+			SkipChecks: true,
+		})
+		require.NoError(t, err)
+		assert.Contains(t, plan.Hosts[0].Error, "missing")
+		assert.False(t, plan.Hosts[0].OK())
+	})
+
+	t.Run("checks connectivity and sudo", func(t *testing.T) {
+		e, runner := newExecutor(t)
+		runner.down["web02"] = true
+		runner.sudoPassword["db01"] = true
+
+		plan, err := e.Plan(context.Background(), ExecOptions{Command: "sudo apt update", Groups: []string{"all"}})
+		require.NoError(t, err)
+
+		byHost := map[string]HostPlan{}
+		for _, h := range plan.Hosts {
+			byHost[h.HostID] = h
+		}
+		assert.True(t, byHost["web01"].Reachable)
+		assert.Equal(t, SudoPasswordless, byHost["web01"].Sudo)
+		assert.False(t, byHost["web02"].Reachable)
+		assert.Contains(t, byHost["web02"].Error, "unreachable")
+		db := byHost["db01"]
+		assert.Equal(t, SudoPasswordRequired, db.Sudo)
+		assert.False(t, db.OK())
+		assert.Empty(t, runner.ran)
+	})
+
+	t.Run("exec dry run returns plans", func(t *testing.T) {
+		e, runner := newExecutor(t)
+
+		results, err := e.Exec(context.Background(), ExecOptions{Command: "uptime", HostIDs: []string{"web01"}, DryRun: true})
+		require.NoError(t, err)
+		require.NotNil(t, results[0].Plan)
+		assert.Equal(t, "uptime", results[0].Plan.Command)
+		assert.Empty(t, results[0].Stdout)
+		assert.Empty(t, runner.ran)
+	})
+
+	t.Run("policy violations are part of the plan", func(t *testing.T) {
+		e, _ := newExecutor(t)
+		e.SetCommandPolicy(&inventory.CommandPolicy{Rules: []inventory.CommandRule{
+			{Name: "no-reboot", Deny: []string{`\breboot\b`}},
+		}})
+
+		plan, err := e.Plan(context.Background(), ExecOptions{Command: "{{.Vars.action}}", HostIDs: []string{"web01"}, SkipChecks: true})
+		require.NoError(t, err)
+		assert.False(t, plan.Blocked, "unrenderable commands are checked raw")
+
+		h, _ := e.manager.GetHost("web01")
+		h.SetVar("action", "reboot")
+		require.NoError(t, e.manager.UpdateHost(h))
+
+		plan, err = e.Plan(context.Background(), ExecOptions{Command: "{{.Vars.action}}", HostIDs: []string{"web01"}, SkipChecks: true})
+		require.NoError(t, err)
+		assert.True(t, plan.Blocked, "rendered commands are checked")
+
+		_, err = e.Exec(context.Background(), ExecOptions{Command: "{{.Vars.action}}", HostIDs: []string{"web01"}, DryRun: true, SkipChecks: true})
+		assert.ErrorIs(t, err, ErrCommandBlocked)
+
+		var buf bytes.Buffer
+		require.NoError(t, plan.WriteText(&buf))
+		assert.Contains(t, buf.String(), "web01")
+		assert.Contains(t, buf.String(), "--force")
+
+		data, err := plan.JSON()
+		require.NoError(t, err)
+		var decoded map[string]any
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, true, decoded["blocked"])
+		assert.True(t, strings.Contains(string(data), `"host_id": "web01"`))
+	})
+}
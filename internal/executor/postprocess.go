@@ -0,0 +1,271 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gossher/internal/inventory"
+	"gossher/internal/script"
+)
+
+// ErrThresholdExceeded is wrapped by the error of hosts whose values are out of bounds.
+var ErrThresholdExceeded = errors.New("threshold exceeded")
+
+// Processor is one step of an output extraction. It receives the output text or the
+// value of the previous step.
+type Processor interface {
+	Process(value any) (any, error)
+}
+
+// ProcessorFunc adapts a function to the Processor interface.
+type ProcessorFunc func(value any) (any, error)
+
+// Process implements Processor.
+func (f ProcessorFunc) Process(value any) (any, error) {
+	return f(value)
+}
+
+var (
+	processors   = map[string]Processor{}
+	processorsMu sync.RWMutex
+)
+
+// RegisterProcessor makes a processor available to output specs by name, replacing
+// any processor registered under the same name.
+func RegisterProcessor(name string, p Processor) {
+	processorsMu.Lock()
+	defer processorsMu.Unlock()
+	processors[name] = p
+}
+
+// GetProcessor returns the processor registered under name.
+func GetProcessor(name string) (Processor, bool) {
+	processorsMu.RLock()
+	defer processorsMu.RUnlock()
+	p, ok := processors[name]
+	return p, ok
+}
+
+// Extractor derives a named value from a result by running its steps in order.
+type Extractor struct {
+	Name string
+	// Stderr reads standard error instead of standard output.
+	Stderr bool
+	Steps  []Processor
+}
+
+// CompileOutput builds the extractor described by an output spec.
+func CompileOutput(spec inventory.OutputSpec) (Extractor, error) {
+	return compileOutput(spec, GetProcessor)
+}
+
+// compileOutput builds an extractor, resolving processor names with lookup.
+func compileOutput(spec inventory.OutputSpec, lookup func(name string) (Processor, bool)) (Extractor, error) {
+	if err := spec.Validate(); err != nil {
+		return Extractor{}, err
+	}
+
+	x := Extractor{Name: spec.Name, Stderr: spec.Source == "stderr"}
+	if spec.JSONField != "" {
+		x.Steps = append(x.Steps, JSONField(spec.JSONField))
+	}
+	if spec.Regex != "" {
+		x.Steps = append(x.Steps, RegexExtract(regexp.MustCompile(spec.Regex)))
+	}
+	if spec.Processor != "" {
+		p, ok := lookup(spec.Processor)
+		if !ok {
+			return Extractor{}, fmt.Errorf("output %s: unknown processor %s", spec.Name, spec.Processor)
+		}
+		x.Steps = append(x.Steps, p)
+	}
+	if spec.Numeric || spec.Threshold != nil {
+		x.Steps = append(x.Steps, ProcessorFunc(toNumber))
+	}
+	if spec.Threshold != nil {
+		x.Steps = append(x.Steps, CheckThreshold(*spec.Threshold))
+	}
+	return x, nil
+}
+
+// CompileOutputs builds extractors for all specs.
+func CompileOutputs(specs []inventory.OutputSpec) ([]Extractor, error) {
+	return compileOutputs(specs, GetProcessor)
+}
+
+// compileOutputs builds extractors for all specs, resolving processor names with lookup.
+func compileOutputs(specs []inventory.OutputSpec, lookup func(name string) (Processor, bool)) ([]Extractor, error) {
+	extractors := make([]Extractor, 0, len(specs))
+	for _, spec := range specs {
+		x, err := compileOutput(spec, lookup)
+		if err != nil {
+			return nil, err
+		}
+		extractors = append(extractors, x)
+	}
+	return extractors, nil
+}
+
+// lookupProcessor resolves a processor name to a registered processor or, failing
+// that, to the transform_<name> script hook of the inventory.
+func (e *Executor) lookupProcessor(name string) (Processor, bool) {
+	if p, ok := GetProcessor(name); ok {
+		return p, true
+	}
+	scripts := e.manager.Scripts()
+	if !scripts.Has(inventory.TransformPrefix + name) {
+		return nil, false
+	}
+	return ScriptTransform(scripts, inventory.TransformPrefix+name), true
+}
+
+// ScriptTransform returns a processor calling a script function with the value.
+func ScriptTransform(p *script.Program, fn string) Processor {
+	return ProcessorFunc(func(value any) (any, error) {
+		arg, err := script.FromGo(value)
+		if err != nil {
+			return nil, err
+		}
+		out, err := p.Call(fn, arg)
+		if err != nil {
+			return nil, err
+		}
+		return script.ToGo(out), nil
+	})
+}
+
+// JSONField selects a field by dotted path; numeric segments index arrays.
+func JSONField(path string) Processor {
+	return ProcessorFunc(func(value any) (any, error) {
+		var data any
+		switch v := value.(type) {
+		case string:
+			if err := json.Unmarshal([]byte(v), &data); err != nil {
+				return nil, fmt.Errorf("output is not JSON: %w", err)
+			}
+		default:
+			data = v
+		}
+
+		for _, segment := range strings.Split(path, ".") {
+			switch node := data.(type) {
+			case map[string]any:
+				next, ok := node[segment]
+				if !ok {
+					return nil, fmt.Errorf("JSON field %s not found", path)
+				}
+				data = next
+			case []any:
+				i, err := strconv.Atoi(segment)
+				if err != nil || i < 0 || i >= len(node) {
+					return nil, fmt.Errorf("JSON field %s not found", path)
+				}
+				data = node[i]
+			default:
+				return nil, fmt.Errorf("JSON field %s not found", path)
+			}
+		}
+		return data, nil
+	})
+}
+
+// RegexExtract returns the first capture group of the first match, or the whole
+// match when the expression has no groups.
+func RegexExtract(re *regexp.Regexp) Processor {
+	return ProcessorFunc(func(value any) (any, error) {
+		m := re.FindStringSubmatch(fmt.Sprint(value))
+		if m == nil {
+			return nil, fmt.Errorf("no match for %s", re)
+		}
+		if len(m) > 1 {
+			return m[1], nil
+		}
+		return m[0], nil
+	})
+}
+
+// CheckThreshold passes numeric values within the bounds through unchanged.
+func CheckThreshold(t inventory.Threshold) Processor {
+	return ProcessorFunc(func(value any) (any, error) {
+		n, err := toNumber(value)
+		if err != nil {
+			return nil, err
+		}
+		f := n.(float64)
+		if t.Min != nil && f < *t.Min {
+			return f, fmt.Errorf("%w: %g is below %g", ErrThresholdExceeded, f, *t.Min)
+		}
+		if t.Max != nil && f > *t.Max {
+			return f, fmt.Errorf("%w: %g is above %g", ErrThresholdExceeded, f, *t.Max)
+		}
+		return f, nil
+	})
+}
+
+// toNumber converts strings such as "87", "87%" or "1.5" and JSON numbers to float64.
+func toNumber(value any) (any, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case string:
+		s := strings.TrimSuffix(strings.TrimSpace(v), "%")
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", v)
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("%v is not a number", value)
+}
+
+// extract runs every extractor on a result, storing values in r.Values and any
+// failures in r.Err. Values breaching a threshold are still stored.
+func extract(r *Result, extractors []Extractor) {
+	if len(extractors) == 0 {
+		return
+	}
+	r.Values = make(map[string]any, len(extractors))
+
+	var errs []error
+	for _, x := range extractors {
+		var value any = r.Stdout
+		if x.Stderr {
+			value = r.Stderr
+		}
+
+		var err error
+		for _, step := range x.Steps {
+			var next any
+			next, err = step.Process(value)
+			if next != nil {
+				value = next
+			}
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("output %s: %w", x.Name, err))
+			if !errors.Is(err, ErrThresholdExceeded) {
+				continue
+			}
+		}
+		if s, ok := value.(string); ok {
+			value = strings.TrimSpace(s)
+		}
+		r.Values[x.Name] = value
+	}
+
+	if len(errs) > 0 && r.Err == nil {
+		r.Err = errors.Join(errs...)
+	}
+}
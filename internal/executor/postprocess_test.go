@@ -0,0 +1,138 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileOutput(t *testing.T) {
+	max := 90.0
+
+	tests := []struct {
+		name    string
+		spec    inventory.OutputSpec
+		stdout  string
+		want    any
+		wantErr bool
+	}{
+		{"regex group", inventory.OutputSpec{Name: "v", Regex: `version (\S+)`}, "nginx version 1.24.0\n", "1.24.0", false},
+		{"regex whole match", inventory.OutputSpec{Name: "v", Regex: `\d+\.\d+`}, "v1.24", "1.24", false},
+		{"regex no match", inventory.OutputSpec{Name: "v", Regex: `\d+`}, "none", nil, true},
+		{"json field", inventory.OutputSpec{Name: "used", JSONField: "disks.1.used"}, `{"disks":[{"used":1},{"used":42}]}`, 42.0, false},
+		{"json missing field", inventory.OutputSpec{Name: "used", JSONField: "disks.5"}, `{"disks":[]}`, nil, true},
+		{"json invalid", inventory.OutputSpec{Name: "used", JSONField: "a"}, "not json", nil, true},
+		{"numeric percent", inventory.OutputSpec{Name: "pct", Regex: `(\d+%)`, Numeric: true}, "/dev/sda1 73% /", 73.0, false},
+		{"not numeric", inventory.OutputSpec{Name: "pct", Numeric: true}, "abc", nil, true},
+		{"threshold ok", inventory.OutputSpec{Name: "pct", Threshold: &inventory.Threshold{Max: &max}}, "73\n", 73.0, false},
+		{"threshold exceeded", inventory.OutputSpec{Name: "pct", Threshold: &inventory.Threshold{Max: &max}}, "95", 95.0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x, err := CompileOutput(tt.spec)
+			require.NoError(t, err)
+
+			r := Result{Stdout: tt.stdout}
+			extract(&r, []Extractor{x})
+			if tt.wantErr {
+				assert.Error(t, r.Err)
+			} else {
+				assert.NoError(t, r.Err)
+			}
+			assert.Equal(t, tt.want, r.Values[tt.spec.Name])
+		})
+	}
+
+	t.Run("rejects invalid spec", func(t *testing.T) {
+		_, err := CompileOutput(inventory.OutputSpec{Name: "v", Regex: "("})
+		assert.Error(t, err)
+	})
+
+	t.Run("registered processor", func(t *testing.T) {
+		RegisterProcessor("test-upper", ProcessorFunc(func(value any) (any, error) {
+			return strings.ToUpper(fmt.Sprint(value)), nil
+		}))
+
+		x, err := CompileOutput(inventory.OutputSpec{Name: "v", Regex: `version (\S+)`, Processor: "test-upper"})
+		require.NoError(t, err)
+		r := Result{Stdout: "app version 1.2-rc1"}
+		extract(&r, []Extractor{x})
+		require.NoError(t, r.Err)
+		assert.Equal(t, "1.2-RC1", r.Values["v"])
+
+		_, err = CompileOutput(inventory.OutputSpec{Name: "v", Processor: "missing"})
+		assert.ErrorContains(t, err, "unknown processor missing")
+	})
+}
+
+func TestExecSavedOutputs(t *testing.T) {
+	base, inner := setupExecutor(t)
+	runner := &scriptedRunner{fakeRunner: inner, probes: map[string]map[string]probeReply{
+		"web01": {"df": {out: "Use%\n 45%\n"}},
+		"web02": {"df": {out: "Use%\n 97%\n"}},
+	}}
+	e := New(base.manager, runner)
+
+	max := 90.0
+	cmd := inventory.NewSavedCommand("disk", "Disk usage", "df --output=pcent /")
+	cmd.Outputs = []inventory.OutputSpec{{
+		Name:      "used_percent",
+		Regex:     `(\d+)%`,
+		Threshold: &inventory.Threshold{Max: &max},
+	}}
+	require.NoError(t, e.manager.AddCommand(cmd))
+
+	results, err := e.ExecSaved(context.Background(), "disk", ExecOptions{Groups: []string{"web"}})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	assert.True(t, results[0].OK())
+	assert.Equal(t, 45.0, results[0].Values["used_percent"])
+
+	assert.True(t, results[1].Failed())
+	assert.ErrorIs(t, results[1].Err, ErrThresholdExceeded)
+	assert.Equal(t, 97.0, results[1].Values["used_percent"])
+}
+
+func TestExecSavedScriptTransform(t *testing.T) {
+	base, inner := setupExecutor(t)
+	dir := filepath.Join(base.manager.GetDataDir(), inventory.ScriptsDir)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "transforms.star"), []byte(`
+def transform_mounts(text):
+    return [line.split()[1] for line in text.splitlines() if line.startswith("/dev/")]
+`), 0644))
+	require.NoError(t, base.manager.Load())
+
+	runner := &scriptedRunner{fakeRunner: inner, probes: map[string]map[string]probeReply{
+		"web01": {"mount": {out: "/dev/sda1 / ext4\ntmpfs /run tmpfs\n/dev/sdb1 /data xfs\n"}},
+	}}
+	e := New(base.manager, runner)
+
+	cmd := inventory.NewSavedCommand("mounts", "Mounts", "mount")
+	cmd.Outputs = []inventory.OutputSpec{{Name: "mounts", Processor: "mounts"}}
+	require.NoError(t, e.manager.AddCommand(cmd))
+
+	t.Run("transform output", func(t *testing.T) {
+		results, err := e.ExecSaved(context.Background(), "mounts", ExecOptions{HostIDs: []string{"web01"}})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, []any{"/", "/data"}, results[0].Values["mounts"])
+	})
+
+	t.Run("unknown processor", func(t *testing.T) {
+		cmd.Outputs[0].Processor = "nope"
+		require.NoError(t, e.manager.UpdateCommand(cmd))
+		_, err := e.ExecSaved(context.Background(), "mounts", ExecOptions{HostIDs: []string{"web01"}})
+		assert.ErrorContains(t, err, "unknown processor nope")
+	})
+}
@@ -0,0 +1,202 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gossher/internal/inventory"
+	"gossher/internal/redact"
+)
+
+// ErrPreflightFailed wraps the reason a host did not pass its pre-flight checks.
+var ErrPreflightFailed = errors.New("preflight check failed")
+
+// ExecSaved runs a saved command with its inputs, pre-flight checks, output extractors
+// and snapshot. Targets and the other options are taken from opts; its Command and
+// Preflight fields are replaced, and Snapshot when the command has one.
+func (e *Executor) ExecSaved(ctx context.Context, commandID string, opts ExecOptions) ([]Result, error) {
+	cmd, ok := e.manager.GetCommand(commandID)
+	if !ok {
+		return nil, fmt.Errorf("command %s not found", commandID)
+	}
+
+	extractors, err := compileOutputs(cmd.Outputs, e.lookupProcessor)
+	if err != nil {
+		return nil, err
+	}
+	inputs, err := inventory.ResolveInputs(cmd.Inputs, opts.Inputs, opts.Prompt)
+	if err != nil {
+		return nil, fmt.Errorf("command %s: %w", commandID, err)
+	}
+	redact.Add(inventory.SecretInputs(cmd.Inputs, inputs)...)
+
+	opts.Command = cmd.Command
+	opts.Preflight = cmd.Preflight
+	opts.OnPreflightFailure = cmd.FailureAction()
+	opts.CommandTimeouts = cmd.Timeouts
+	opts.Inputs = inputs
+	opts.Extractors = append(extractors, opts.Extractors...)
+	if cmd.Snapshot != nil {
+		opts.Snapshot = cmd.Snapshot
+	}
+	return e.Exec(ctx, opts)
+}
+
+// runPreflight evaluates all checks on a host and returns the first failure.
+func (e *Executor) runPreflight(ctx context.Context, conn *inventory.ResolvedConnection, checks []inventory.Preflight) error {
+	h, ok := e.manager.GetHost(conn.HostID)
+	if !ok {
+		return fmt.Errorf("host %s not found", conn.HostID)
+	}
+
+	for _, c := range checks {
+		if c.MinFreeDisk != "" {
+			if err := e.checkFreeDisk(ctx, conn, c.Path, c.MinFreeDisk); err != nil {
+				return err
+			}
+		}
+		if len(c.OSFamily) > 0 {
+			if err := e.checkOSFamily(ctx, conn, h, c.OSFamily); err != nil {
+				return err
+			}
+		}
+		if c.Service != "" {
+			svc := inventory.ShellQuote(c.Service)
+			probe := fmt.Sprintf("systemctl cat -- %s >/dev/null 2>&1 || test -x /etc/init.d/%s", svc, svc)
+			if code, _, err := e.probe(ctx, conn, probe); err != nil {
+				return err
+			} else if code != 0 {
+				return fmt.Errorf("%w: service %s does not exist", ErrPreflightFailed, c.Service)
+			}
+		}
+		if c.Command != "" {
+			if code, _, err := e.probe(ctx, conn, c.Command); err != nil {
+				return err
+			} else if code != 0 {
+				return fmt.Errorf("%w: %q exited with status %d", ErrPreflightFailed, c.Command, code)
+			}
+		}
+	}
+	return nil
+}
+
+// checkFreeDisk compares the available space on path with the minimum.
+func (e *Executor) checkFreeDisk(ctx context.Context, conn *inventory.ResolvedConnection, path, min string) error {
+	if path == "" {
+		path = "/"
+	}
+	need, err := inventory.ParseSize(min)
+	if err != nil {
+		return err
+	}
+
+	code, out, err := e.probe(ctx, conn, "df -Pk -- "+inventory.ShellQuote(path))
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("%w: cannot determine free space on %s", ErrPreflightFailed, path)
+	}
+	free, err := parseDFAvailable(out)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPreflightFailed, err)
+	}
+	if free < need {
+		return fmt.Errorf("%w: %s free on %s, need %s", ErrPreflightFailed, formatSize(free), path, min)
+	}
+	return nil
+}
+
+// checkOSFamily matches the host's OS family against the accepted ones, preferring
+// gathered facts over asking the host.
+func (e *Executor) checkOSFamily(ctx context.Context, conn *inventory.ResolvedConnection, h *inventory.Host, accepted []string) error {
+	var families []string
+	if family := h.Facts["os_family"]; family != "" {
+		families = []string{family}
+	} else {
+		code, out, err := e.probe(ctx, conn, "cat /etc/os-release")
+		if err != nil {
+			return err
+		}
+		if code != 0 {
+			return fmt.Errorf("%w: cannot determine OS family", ErrPreflightFailed)
+		}
+		families = parseOSRelease(out)
+	}
+
+	for _, f := range families {
+		for _, a := range accepted {
+			if strings.EqualFold(f, a) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: OS family %s is not one of %s", ErrPreflightFailed,
+		strings.Join(families, "/"), strings.Join(accepted, ", "))
+}
+
+// probe runs a check command and returns its exit code and standard output.
+func (e *Executor) probe(ctx context.Context, conn *inventory.ResolvedConnection, command string) (int, string, error) {
+	var stdout, stderr bytes.Buffer
+	code, err := e.runnerFor(conn).Run(ctx, conn, command, &stdout, &stderr)
+	if err != nil {
+		return code, "", fmt.Errorf("preflight: %w", err)
+	}
+	return code, stdout.String(), nil
+}
+
+// parseDFAvailable returns the available bytes from POSIX "df -Pk" output.
+func parseDFAvailable(out string) (int64, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 2 {
+		return 0, fmt.Errorf("unexpected df output")
+	}
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 4 {
+		return 0, fmt.Errorf("unexpected df output")
+	}
+	kb, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected df output: %w", err)
+	}
+	return kb * 1024, nil
+}
+
+// parseOSRelease returns ID followed by the ID_LIKE entries of /etc/os-release.
+func parseOSRelease(out string) []string {
+	var id string
+	var like []string
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "ID":
+			id = value
+		case "ID_LIKE":
+			like = strings.Fields(value)
+		}
+	}
+	if id == "" {
+		return like
+	}
+	return append([]string{id}, like...)
+}
+
+// formatSize renders bytes with a binary unit, e.g. "1.5G".
+func formatSize(n int64) string {
+	const units = "KMGT"
+	size := float64(n)
+	unit := ""
+	for i := 0; size >= 1024 && i < len(units); i++ {
+		size /= 1024
+		unit = string(units[i])
+	}
+	return strconv.FormatFloat(size, 'f', 1, 64) + unit
+}
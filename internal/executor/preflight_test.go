@@ -0,0 +1,162 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedRunner answers probes per host by command prefix and runs everything
+// else through fakeRunner.
+type scriptedRunner struct {
+	*fakeRunner
+	probes map[string]map[string]probeReply
+}
+
+type probeReply struct {
+	code int
+	out  string
+}
+
+func (r *scriptedRunner) Run(ctx context.Context, conn *inventory.ResolvedConnection, command string, stdout, stderr io.Writer) (int, error) {
+	for prefix, reply := range r.probes[conn.HostID] {
+		if strings.HasPrefix(command, prefix) {
+			fmt.Fprint(stdout, reply.out)
+			return reply.code, nil
+		}
+	}
+	return r.fakeRunner.Run(ctx, conn, command, stdout, stderr)
+}
+
+const dfOutput = `Filesystem     1024-blocks     Used Available Capacity Mounted on
+/dev/sda1         41152736 30000000  %d      73%% /
+`
+
+func TestPreflight(t *testing.T) {
+	setup := func(t *testing.T) (*Executor, *scriptedRunner) {
+		base, inner := setupExecutor(t)
+		runner := &scriptedRunner{fakeRunner: inner, probes: map[string]map[string]probeReply{
+			"web01": {
+				"df -Pk":              {out: fmt.Sprintf(dfOutput, 20*1024*1024)},
+				"cat /etc/os-release": {out: "NAME=\"Ubuntu\"\nID=ubuntu\nID_LIKE=debian\n"},
+				"systemctl cat":       {code: 0},
+			},
+			"web02": {
+				"df -Pk":              {out: fmt.Sprintf(dfOutput, 512*1024)},
+				"cat /etc/os-release": {out: "ID=\"rocky\"\nID_LIKE=\"rhel centos fedora\"\n"},
+				"systemctl cat":       {code: 1},
+			},
+		}}
+		return New(base.manager, runner), runner
+	}
+
+	t.Run("skips hosts failing checks", func(t *testing.T) {
+		e, runner := setup(t)
+
+		cmd := inventory.NewSavedCommand("upgrade", "Upgrade", "apt-get upgrade -y")
+		cmd.Preflight = []inventory.Preflight{
+			{MinFreeDisk: "1G"},
+			{OSFamily: []string{"debian"}},
+			{Service: "nginx"},
+		}
+		require.NoError(t, e.manager.AddCommand(cmd))
+
+		results, err := e.ExecSaved(context.Background(), "upgrade", ExecOptions{Groups: []string{"web"}})
+		require.NoError(t, err)
+
+		assert.True(t, results[0].OK())
+		assert.True(t, results[1].Skipped)
+		assert.False(t, results[1].Failed())
+		assert.ErrorIs(t, results[1].Err, ErrPreflightFailed)
+		assert.Contains(t, results[1].Err.Error(), "512.0M free on /, need 1G")
+		assert.Equal(t, []string{"web01"}, runner.ran)
+	})
+
+	t.Run("fail action marks hosts failed", func(t *testing.T) {
+		e, _ := setup(t)
+
+		results, err := e.Exec(context.Background(), ExecOptions{
+			Command:            "systemctl reload nginx",
+			Groups:             []string{"web"},
+			Preflight:          []inventory.Preflight{{Service: "nginx"}},
+			OnPreflightFailure: inventory.PreflightFail,
+		})
+		require.NoError(t, err)
+		assert.True(t, results[0].OK())
+		assert.True(t, results[1].Failed())
+		assert.Contains(t, results[1].Err.Error(), "service nginx does not exist")
+	})
+
+	t.Run("OS family from facts", func(t *testing.T) {
+		e, _ := setup(t)
+		h, _ := e.manager.GetHost("web02")
+		h.Facts = map[string]string{"os_family": "Debian"}
+		require.NoError(t, e.manager.UpdateHost(h))
+
+		results, err := e.Exec(context.Background(), ExecOptions{
+			Command:   "true",
+			HostIDs:   []string{"web02"},
+			Preflight: []inventory.Preflight{{OSFamily: []string{"debian"}}},
+		})
+		require.NoError(t, err)
+		assert.True(t, results[0].OK())
+	})
+
+	t.Run("rhel family via ID_LIKE", func(t *testing.T) {
+		e, _ := setup(t)
+
+		results, err := e.Exec(context.Background(), ExecOptions{
+			Command:   "true",
+			HostIDs:   []string{"web02"},
+			Preflight: []inventory.Preflight{{OSFamily: []string{"rhel"}}},
+		})
+		require.NoError(t, err)
+		assert.True(t, results[0].OK())
+	})
+
+	t.Run("unknown saved command", func(t *testing.T) {
+		e, _ := setup(t)
+		_, err := e.ExecSaved(context.Background(), "missing", ExecOptions{HostIDs: []string{"web01"}})
+		assert.Error(t, err)
+	})
+}
+
+func TestExecSavedInputs(t *testing.T) {
+	e, _ := setupExecutor(t)
+	replicas := "2"
+	cmd := inventory.NewSavedCommand("deploy", "deploy", "deploy --version {{.Inputs.version}} --replicas {{.Inputs.replicas}} --token {{.Inputs.token}}")
+	cmd.Inputs = []inventory.Input{
+		{Name: "version"},
+		{Name: "replicas", Type: inventory.InputInt, Default: &replicas},
+		{Name: "token", Secret: true},
+	}
+	require.NoError(t, e.manager.AddCommand(cmd))
+
+	t.Run("passed and prompted values", func(t *testing.T) {
+		results, err := e.ExecSaved(context.Background(), "deploy", ExecOptions{
+			HostIDs: []string{"web01"},
+			Inputs:  map[string]string{"version": "1.4"},
+			Prompt: func(in inventory.Input) (string, error) {
+				if in.Secret {
+					return "tok-8f2a9c", nil
+				}
+				return "", nil
+			},
+		})
+		require.NoError(t, err)
+		assert.Contains(t, results[0].Stdout, "deploy --version 1.4 --replicas 2 --token ")
+		assert.NotContains(t, results[0].Stdout, "tok-8f2a9c", "secret inputs are redacted")
+	})
+
+	t.Run("missing inputs fail before running", func(t *testing.T) {
+		_, err := e.ExecSaved(context.Background(), "deploy", ExecOptions{HostIDs: []string{"web01"}, Inputs: map[string]string{"version": "1.4"}})
+		assert.ErrorIs(t, err, inventory.ErrMissingInput)
+	})
+}
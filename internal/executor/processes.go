@@ -0,0 +1,213 @@
+package executor
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultProcessInterval is how often WatchProcesses refreshes by default.
+const DefaultProcessInterval = 2 * time.Second
+
+// processCommand lists all processes with procps ps. The user column is widened
+// so that long user names are not cut.
+const processCommand = "ps -eo pid=,ppid=,user:32=,pcpu=,pmem=,rss=,stat=,etimes=,args="
+
+// signals are the signals Kill sends.
+var signals = []string{"TERM", "KILL", "HUP", "INT", "QUIT", "USR1", "USR2", "STOP", "CONT"}
+
+// Process is a process of a host.
+type Process struct {
+	PID  int    `json:"pid"`
+	PPID int    `json:"ppid"`
+	User string `json:"user"`
+	// CPU and Mem are percentages, as reported by ps.
+	CPU float64 `json:"cpu"`
+	Mem float64 `json:"mem"`
+	// RSS is the resident memory in bytes.
+	RSS   int64  `json:"rss"`
+	State string `json:"state"`
+	// Elapsed is how long the process has been running.
+	Elapsed time.Duration `json:"elapsed"`
+	Command string        `json:"command"`
+}
+
+// HostProcesses is the process list of a host.
+type HostProcesses struct {
+	HostID    string    `json:"host_id"`
+	Collected time.Time `json:"collected"`
+	Processes []Process `json:"processes"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Top returns the first n processes by a sort key, all of them for n <= 0.
+func (h *HostProcesses) Top(by ProcessSort, n int) []Process {
+	top := slices.Clone(h.Processes)
+	SortProcesses(top, by)
+	if n > 0 && n < len(top) {
+		top = top[:n]
+	}
+	return top
+}
+
+// ProcessSort is a sort key of process lists.
+type ProcessSort string
+
+const (
+	// SortByCPU and SortByMem sort by descending usage, SortByTime by descending
+	// elapsed time, the others ascending.
+	SortByCPU     ProcessSort = "cpu"
+	SortByMem     ProcessSort = "mem"
+	SortByTime    ProcessSort = "time"
+	SortByPID     ProcessSort = "pid"
+	SortByUser    ProcessSort = "user"
+	SortByCommand ProcessSort = "command"
+)
+
+// SortProcesses sorts processes in place by a key, ties by PID. Unknown keys sort
+// by PID.
+func SortProcesses(ps []Process, by ProcessSort) {
+	slices.SortFunc(ps, func(a, b Process) int {
+		var c int
+		switch by {
+		case SortByCPU:
+			c = cmp.Compare(b.CPU, a.CPU)
+		case SortByMem:
+			c = cmp.Or(cmp.Compare(b.RSS, a.RSS), cmp.Compare(b.Mem, a.Mem))
+		case SortByTime:
+			c = cmp.Compare(b.Elapsed, a.Elapsed)
+		case SortByUser:
+			c = strings.Compare(a.User, b.User)
+		case SortByCommand:
+			c = strings.Compare(a.Command, b.Command)
+		}
+		return cmp.Or(c, cmp.Compare(a.PID, b.PID))
+	})
+}
+
+// Processes lists the processes of the selected hosts with ps. The hosts need
+// procps ps, as on most Linux distributions.
+func (e *Executor) Processes(ctx context.Context, opts ExecOptions) ([]HostProcesses, error) {
+	opts.Command = processCommand
+	results, err := e.Exec(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]HostProcesses, len(results))
+	for i, r := range results {
+		h := HostProcesses{HostID: r.HostID, Collected: r.Started.UTC(), Processes: []Process{}}
+		switch {
+		case r.Err != nil:
+			h.Error = r.Err.Error()
+		case r.ExitCode != 0:
+			h.Error = fmt.Sprintf("ps exited with status %d: %s", r.ExitCode, strings.TrimSpace(r.Stderr))
+		default:
+			h.Processes = parseProcesses(r.Stdout)
+		}
+		hosts[i] = h
+	}
+	return hosts, nil
+}
+
+// WatchProcesses lists the processes of the selected hosts every interval
+// (default DefaultProcessInterval) and passes each refresh to fn, until ctx is
+// done.
+func (e *Executor) WatchProcesses(ctx context.Context, opts ExecOptions, interval time.Duration, fn func([]HostProcesses)) error {
+	if interval <= 0 {
+		interval = DefaultProcessInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		hosts, err := e.Processes(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		fn(hosts)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// parseProcesses parses the output of processCommand. Lines that do not parse,
+// e.g. of processes that exited while ps ran, are skipped.
+func parseProcesses(out string) []Process {
+	ps := []Process{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 9 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		cpu, err3 := strconv.ParseFloat(fields[3], 64)
+		mem, err4 := strconv.ParseFloat(fields[4], 64)
+		rss, err5 := strconv.ParseInt(fields[5], 10, 64)
+		elapsed, err6 := strconv.ParseInt(fields[7], 10, 64)
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil || err5 != nil || err6 != nil {
+			continue
+		}
+		ps = append(ps, Process{
+			PID:     pid,
+			PPID:    ppid,
+			User:    fields[2],
+			CPU:     cpu,
+			Mem:     mem,
+			RSS:     rss * 1024,
+			State:   fields[6],
+			Elapsed: time.Duration(elapsed) * time.Second,
+			Command: strings.Join(fields[8:], " "),
+		})
+	}
+	return ps
+}
+
+// KillOptions configures Kill.
+type KillOptions struct {
+	// Signal is the signal name without "SIG" (default TERM).
+	Signal string
+	// Sudo sends the signal with "sudo -n", for processes of other users.
+	Sudo bool
+}
+
+// Kill sends a signal to processes of a host by PID. It runs through Exec, so the
+// command policy applies.
+func (e *Executor) Kill(ctx context.Context, hostID string, pids []int, opts KillOptions) (*Result, error) {
+	signal := strings.TrimPrefix(strings.ToUpper(cmp.Or(opts.Signal, "TERM")), "SIG")
+	if !slices.Contains(signals, signal) {
+		return nil, fmt.Errorf("unsupported signal %s (supported: %s)", opts.Signal, strings.Join(signals, ", "))
+	}
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("no process selected")
+	}
+	args := make([]string, len(pids))
+	for i, pid := range pids {
+		if pid <= 1 {
+			return nil, fmt.Errorf("invalid PID %d", pid)
+		}
+		args[i] = strconv.Itoa(pid)
+	}
+
+	command := "kill -s " + signal + " -- " + strings.Join(args, " ")
+	if opts.Sudo {
+		command = "sudo -n " + command
+	}
+	results, err := e.Exec(ctx, ExecOptions{Command: command, HostIDs: []string{hostID}})
+	if err != nil {
+		return nil, err
+	}
+	return &results[0], nil
+}
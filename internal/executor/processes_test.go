@@ -0,0 +1,98 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const psOutput = `      1       0 root                              0.0  0.1 12345 Ss   864000 /sbin/init splash
+    812       1 postgres                          12.5  8.2 690000 Ss  86400 postgres: checkpointer
+   4242     812 www-data                          55.0  1.0 20480 R+       60 nginx: worker process
+   4243       1 root                               0.0  0.0     0 Z        5 [defunct]
+garbage line
+`
+
+func TestParseProcesses(t *testing.T) {
+	ps := parseProcesses(psOutput)
+	require.Len(t, ps, 4)
+	assert.Equal(t, Process{
+		PID: 812, PPID: 1, User: "postgres", CPU: 12.5, Mem: 8.2, RSS: 690000 * 1024,
+		State: "Ss", Elapsed: 24 * time.Hour, Command: "postgres: checkpointer",
+	}, ps[1])
+
+	h := HostProcesses{Processes: ps}
+	top := h.Top(SortByCPU, 2)
+	assert.Equal(t, []int{4242, 812}, []int{top[0].PID, top[1].PID})
+	assert.Equal(t, 812, h.Top(SortByMem, 1)[0].PID)
+	assert.Equal(t, 1, h.Top(SortByTime, 1)[0].PID)
+	assert.Equal(t, []int{812, 1, 4243, 4242}, pids(h.Top(SortByUser, 0)))
+	assert.Equal(t, 1, h.Processes[0].PID, "Top does not reorder the list")
+}
+
+func pids(ps []Process) []int {
+	ids := make([]int, len(ps))
+	for i, p := range ps {
+		ids[i] = p.PID
+	}
+	return ids
+}
+
+func TestProcesses(t *testing.T) {
+	setup := func(t *testing.T) (*Executor, *scriptedRunner) {
+		base, inner := setupExecutor(t)
+		runner := &scriptedRunner{fakeRunner: inner, probes: map[string]map[string]probeReply{
+			"web01": {"ps -eo": {out: psOutput}},
+			"web02": {"ps -eo": {code: 1}},
+		}}
+		return New(base.manager, runner), runner
+	}
+
+	t.Run("list a group", func(t *testing.T) {
+		e, _ := setup(t)
+		hosts, err := e.Processes(context.Background(), ExecOptions{Groups: []string{"web"}})
+		require.NoError(t, err)
+		require.Len(t, hosts, 2)
+		assert.Len(t, hosts[0].Processes, 4)
+		assert.NotEmpty(t, hosts[1].Error)
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		e, _ := setup(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		refreshes := 0
+		err := e.WatchProcesses(ctx, ExecOptions{HostIDs: []string{"web01"}}, time.Millisecond, func(hosts []HostProcesses) {
+			refreshes++
+			if refreshes == 3 {
+				cancel()
+			}
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, refreshes)
+	})
+
+	t.Run("kill", func(t *testing.T) {
+		e, runner := setup(t)
+		r, err := e.Kill(context.Background(), "web01", []int{4242, 4243}, KillOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "deploy@web01: kill -s TERM -- 4242 4243", r.Stdout)
+
+		r, err = e.Kill(context.Background(), "web01", []int{812}, KillOptions{Signal: "sigkill", Sudo: true})
+		require.NoError(t, err)
+		assert.Equal(t, "deploy@web01: sudo -n kill -s KILL -- 812", r.Stdout)
+
+		ran := len(runner.ran)
+		for _, bad := range []struct {
+			pids   []int
+			signal string
+		}{{nil, ""}, {[]int{1}, ""}, {[]int{-5}, ""}, {[]int{42}, "WINCH; reboot"}} {
+			_, err := e.Kill(context.Background(), "web01", bad.pids, KillOptions{Signal: bad.signal})
+			assert.Error(t, err)
+		}
+		assert.Equal(t, ran, len(runner.ran), "invalid kills run nothing")
+	})
+}
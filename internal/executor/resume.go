@@ -0,0 +1,85 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/redact"
+)
+
+// ResumeOptions configures Resume.
+type ResumeOptions struct {
+	// HistoryDir holds the run, as given in ExecOptions.HistoryDir.
+	HistoryDir string
+	// RetryFailed also reruns hosts that completed with a failure.
+	RetryFailed bool
+
+	// Concurrency and Timeout apply as in ExecOptions.
+	Concurrency int
+	Timeout     time.Duration
+
+	// Inputs override the inputs kept in the run and supply its secret inputs,
+	// which are not kept; Prompt is asked for secret inputs missing from them.
+	Inputs map[string]string
+	Prompt inventory.InputPrompt
+}
+
+// Resume continues a logged run that was interrupted (cancelled or crashed), running
+// its command only on the hosts that did not complete and, with RetryFailed, on the
+// hosts that failed. Results are recorded in the same run; output extractors of the
+// original execution are not kept, and its secret inputs must be given again (see
+// ResumeOptions.Inputs). A run with nothing left to do returns no results.
+func (e *Executor) Resume(ctx context.Context, runID string, opts ResumeOptions) ([]Result, error) {
+	log, err := openRunLog(opts.HistoryDir, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := log.index.Pending(opts.RetryFailed)
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	secrets := make([]inventory.Input, len(log.index.SecretInputs))
+	for i, name := range log.index.SecretInputs {
+		secrets[i] = inventory.Input{Name: name, Secret: true}
+	}
+	values := maps.Clone(log.index.Inputs)
+	if values == nil {
+		values = map[string]string{}
+	}
+	maps.Copy(values, opts.Inputs)
+	inputs, err := inventory.ResolveInputs(secrets, values, opts.Prompt)
+	if err != nil {
+		return nil, fmt.Errorf("run %s: %w", runID, err)
+	}
+	redact.Add(inventory.SecretInputs(secrets, inputs)...)
+
+	execOpts := ExecOptions{
+		Command:            log.index.Command,
+		HostIDs:            log.index.HostIDs,
+		Groups:             log.index.Groups,
+		Target:             log.index.Target,
+		Force:              log.index.Force,
+		Preflight:          log.index.Preflight,
+		OnPreflightFailure: log.index.OnPreflightFailure,
+		Snapshot:           log.index.Snapshot,
+		Inputs:             inputs,
+		Concurrency:        opts.Concurrency,
+		Timeout:            opts.Timeout,
+		HistoryDir:         opts.HistoryDir,
+	}
+	if err := e.checkPolicy(pending, execOpts); err != nil {
+		return nil, err
+	}
+
+	log.index.Finished = time.Time{}
+	log.index.Resumed = append(log.index.Resumed, time.Now().UTC())
+	if err := log.writeIndex(); err != nil {
+		return nil, err
+	}
+	return e.execTargets(ctx, pending, execOpts, log)
+}
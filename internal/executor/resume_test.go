@@ -0,0 +1,150 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// interruptingRunner cancels the run when it reaches a host, like Ctrl-C would.
+type interruptingRunner struct {
+	*fakeRunner
+	cancel context.CancelFunc
+	on     string
+}
+
+func (r *interruptingRunner) Run(ctx context.Context, conn *inventory.ResolvedConnection, command string, stdout, stderr io.Writer) (int, error) {
+	if conn.HostID == r.on {
+		r.cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+	return r.fakeRunner.Run(ctx, conn, command, stdout, stderr)
+}
+
+func TestResume(t *testing.T) {
+	base, inner := setupExecutor(t)
+	history := filepath.Join(t.TempDir(), HistoryDir)
+	inner.fail["web01"] = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e := New(base.manager, &interruptingRunner{fakeRunner: inner, cancel: cancel, on: "db01"})
+
+	results, err := e.Exec(ctx, ExecOptions{
+		Command:     "apt-get upgrade -y",
+		HostIDs:     []string{"web01", "web02", "db01"},
+		Concurrency: 1,
+		HistoryDir:  history,
+	})
+	require.NoError(t, err)
+	runID := results[0].RunID
+
+	index, err := ReadRunIndex(history, runID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web01", "web02", "db01"}, index.Targets)
+	entry, ok := index.Entry("db01")
+	require.True(t, ok)
+	assert.True(t, entry.Interrupted)
+	assert.Contains(t, index.Pending(false), "db01")
+
+	t.Run("runs only on hosts that did not complete", func(t *testing.T) {
+		e := New(base.manager, inner)
+		pending := index.Pending(false)
+		inner.ran = nil
+
+		results, err := e.Resume(context.Background(), runID, ResumeOptions{HistoryDir: history})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, pending, inner.ran)
+		for _, r := range results {
+			assert.Equal(t, runID, r.RunID)
+		}
+
+		index, err := ReadRunIndex(history, runID)
+		require.NoError(t, err)
+		assert.Len(t, index.Hosts, 3)
+		assert.Len(t, index.Resumed, 1)
+		assert.False(t, index.Finished.IsZero())
+		assert.Empty(t, index.Pending(false))
+		assert.Equal(t, []string{"web01"}, index.Pending(true))
+
+		results, err = e.Resume(context.Background(), runID, ResumeOptions{HistoryDir: history})
+		require.NoError(t, err)
+		assert.Empty(t, results, "nothing left to do")
+	})
+
+	t.Run("retries failed hosts", func(t *testing.T) {
+		e := New(base.manager, inner)
+		delete(inner.fail, "web01")
+		inner.ran = nil
+
+		results, err := e.Resume(context.Background(), runID, ResumeOptions{HistoryDir: history, RetryFailed: true})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.True(t, results[0].OK())
+		assert.Equal(t, []string{"web01"}, inner.ran)
+
+		index, err := ReadRunIndex(history, runID)
+		require.NoError(t, err)
+		assert.Empty(t, index.Pending(true))
+	})
+
+	t.Run("crashed runs", func(t *testing.T) {
+		index := &RunIndex{Targets: []string{"a", "b", "c"}, Hosts: []RunEntry{{HostID: "a"}, {HostID: "c", ExitCode: 1}}}
+		assert.Equal(t, []string{"b"}, index.Pending(false), "hosts without an entry never finished")
+		assert.Equal(t, []string{"b", "c"}, index.Pending(true))
+	})
+
+	t.Run("templated commands", func(t *testing.T) {
+		cmd := inventory.NewSavedCommand("release", "Release", "release {{.Inputs.version}} --token {{.Inputs.token}}")
+		cmd.Inputs = []inventory.Input{{Name: "version"}, {Name: "token", Secret: true}}
+		require.NoError(t, base.manager.AddCommand(cmd))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		e := New(base.manager, &interruptingRunner{fakeRunner: inner, cancel: cancel, on: "web02"})
+		results, err := e.ExecSaved(ctx, "release", ExecOptions{
+			HostIDs:     []string{"web02", "db01"},
+			Inputs:      map[string]string{"version": "1.4.2", "token": "tok-3f9a1c"},
+			Concurrency: 1,
+			HistoryDir:  history,
+		})
+		require.NoError(t, err)
+		runID := results[0].RunID
+
+		index, err := ReadRunIndex(history, runID)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"version": "1.4.2"}, index.Inputs)
+		assert.Equal(t, []string{"token"}, index.SecretInputs, "secret inputs are not kept")
+
+		e = New(base.manager, inner)
+		_, err = e.Resume(context.Background(), runID, ResumeOptions{HistoryDir: history})
+		assert.ErrorIs(t, err, inventory.ErrMissingInput)
+
+		prompted := ""
+		results, err = e.Resume(context.Background(), runID, ResumeOptions{
+			HistoryDir: history,
+			Prompt: func(in inventory.Input) (string, error) {
+				prompted = in.Name
+				return "tok-3f9a1c", nil
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "token", prompted)
+		require.Len(t, results, 2)
+		for _, r := range results {
+			require.NoError(t, r.Err)
+			assert.Equal(t, "deploy@"+r.HostID+": release 1.4.2 --token [REDACTED]", r.Stdout)
+		}
+	})
+
+	t.Run("unknown runs", func(t *testing.T) {
+		_, err := e.Resume(context.Background(), "missing", ResumeOptions{HistoryDir: history})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,222 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/redact"
+)
+
+// Path kinds of a snapshot.
+const (
+	PathFile    = "file"
+	PathDir     = "dir"
+	PathOther   = "other"
+	PathMissing = "missing"
+)
+
+// PathState is the state of a snapshot path: its kind and, for files and
+// directories, the sha256 of its content. A directory's checksum covers the names
+// and contents of all files below it.
+type PathState struct {
+	Kind     string `yaml:"kind" json:"kind"`
+	Checksum string `yaml:"checksum,omitempty" json:"checksum,omitempty"`
+}
+
+func (s PathState) String() string {
+	if s.Checksum == "" {
+		return s.Kind
+	}
+	return s.Kind + " " + s.Checksum
+}
+
+// HostSnapshot is the state captured on a host.
+type HostSnapshot struct {
+	HostID   string               `yaml:"host" json:"host"`
+	Taken    time.Time            `yaml:"taken" json:"taken"`
+	Paths    map[string]PathState `yaml:"paths,omitempty" json:"paths,omitempty"`
+	Services map[string]string    `yaml:"services,omitempty" json:"services,omitempty"`
+}
+
+// SnapshotChange is a path or service whose state differs between the snapshots
+// taken before and after an operation.
+type SnapshotChange struct {
+	// Path or Service names what changed.
+	Path    string `yaml:"path,omitempty" json:"path,omitempty"`
+	Service string `yaml:"service,omitempty" json:"service,omitempty"`
+	Before  string `yaml:"before" json:"before"`
+	After   string `yaml:"after" json:"after"`
+}
+
+// String describes the change with a hint at how to roll it back.
+func (c SnapshotChange) String() string {
+	if c.Service != "" {
+		hint := ""
+		switch {
+		case c.Before == "active" && c.After != "active":
+			hint = " (restart it to roll back)"
+		case c.Before != "active" && c.After == "active":
+			hint = " (stop it to roll back)"
+		}
+		return fmt.Sprintf("service %s: %s -> %s%s", c.Service, c.Before, c.After, hint)
+	}
+	before, _, _ := strings.Cut(c.Before, " ")
+	after, _, _ := strings.Cut(c.After, " ")
+	switch {
+	case before == PathMissing:
+		return fmt.Sprintf("%s: created (%s; remove it to roll back)", c.Path, after)
+	case after == PathMissing:
+		return fmt.Sprintf("%s: removed (was %s; restore it from a backup to roll back)", c.Path, before)
+	case before != after:
+		return fmt.Sprintf("%s: %s replaced by %s", c.Path, before, after)
+	}
+	return fmt.Sprintf("%s: %s modified (restore it from a backup to roll back)", c.Path, before)
+}
+
+// SnapshotDiff is what changed on a host during an operation. Error is set when
+// a snapshot could not be taken; the operation itself is not affected.
+type SnapshotDiff struct {
+	HostID  string           `yaml:"host" json:"host"`
+	Before  *HostSnapshot    `yaml:"before,omitempty" json:"before,omitempty"`
+	After   *HostSnapshot    `yaml:"after,omitempty" json:"after,omitempty"`
+	Changes []SnapshotChange `yaml:"changes,omitempty" json:"changes,omitempty"`
+	Error   string           `yaml:"error,omitempty" json:"error,omitempty"`
+}
+
+// Changed reports whether anything changed.
+func (d *SnapshotDiff) Changed() bool {
+	return len(d.Changes) > 0
+}
+
+// snapshotScript prints a "#path" line per path with its kind and checksum and a
+// "#service" line per service with its state.
+func snapshotScript(spec *inventory.Snapshot) string {
+	var b strings.Builder
+	for _, p := range spec.Paths {
+		q := inventory.ShellQuote(p)
+		fmt.Fprintf(&b, "if [ -d %[1]s ]; then h=$(cd %[1]s && find . -type f -exec sha256sum {} + 2>/dev/null | LC_ALL=C sort -k2 | sha256sum | cut -d' ' -f1); printf '#path\\t%%s\\tdir\\t%%s\\n' %[1]s \"$h\"; "+
+			"elif [ -f %[1]s ]; then h=$(sha256sum < %[1]s 2>/dev/null | cut -d' ' -f1); printf '#path\\t%%s\\tfile\\t%%s\\n' %[1]s \"${h:-unreadable}\"; "+
+			"elif [ -e %[1]s ] || [ -L %[1]s ]; then printf '#path\\t%%s\\tother\\t\\n' %[1]s; "+
+			"else printf '#path\\t%%s\\tmissing\\t\\n' %[1]s; fi\n", q)
+	}
+	for _, s := range spec.Services {
+		fmt.Fprintf(&b, "st=$(systemctl is-active -- %[1]s 2>/dev/null); printf '#service\\t%%s\\t%%s\\n' %[1]s \"${st:-unknown}\"\n", inventory.ShellQuote(s))
+	}
+	b.WriteString("true")
+	return b.String()
+}
+
+// takeSnapshot captures the state selected by spec on a host.
+func (e *Executor) takeSnapshot(ctx context.Context, conn *inventory.ResolvedConnection, spec *inventory.Snapshot) (*HostSnapshot, error) {
+	out, err := e.step(ctx, conn, snapshotScript(spec), 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to take snapshot: %w", redact.Error(err))
+	}
+	return parseSnapshot(out, conn.HostID), nil
+}
+
+// parseSnapshot parses the output of snapshotScript.
+func parseSnapshot(out, hostID string) *HostSnapshot {
+	s := &HostSnapshot{HostID: hostID, Taken: time.Now().UTC(), Paths: map[string]PathState{}, Services: map[string]string{}}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		switch {
+		case fields[0] == "#path" && len(fields) == 4:
+			s.Paths[fields[1]] = PathState{Kind: fields[2], Checksum: fields[3]}
+		case fields[0] == "#service" && len(fields) == 3:
+			s.Services[fields[1]] = fields[2]
+		}
+	}
+	return s
+}
+
+// diffSnapshots compares two snapshots of a host, paths first, each sorted by
+// name.
+func diffSnapshots(before, after *HostSnapshot) *SnapshotDiff {
+	d := &SnapshotDiff{HostID: before.HostID, Before: before, After: after}
+	paths := map[string]bool{}
+	for p := range before.Paths {
+		paths[p] = true
+	}
+	for p := range after.Paths {
+		paths[p] = true
+	}
+	for _, p := range sortedKeys(paths) {
+		b, a := before.Paths[p], after.Paths[p]
+		if b != a {
+			d.Changes = append(d.Changes, SnapshotChange{Path: p, Before: b.String(), After: a.String()})
+		}
+	}
+
+	services := map[string]bool{}
+	for s := range before.Services {
+		services[s] = true
+	}
+	for s := range after.Services {
+		services[s] = true
+	}
+	for _, s := range sortedKeys(services) {
+		if b, a := before.Services[s], after.Services[s]; b != a {
+			d.Changes = append(d.Changes, SnapshotChange{Service: s, Before: b, After: a})
+		}
+	}
+	return d
+}
+
+// snapshotHosts takes a snapshot on each host, at most concurrency at a time, and
+// returns them in target order in the Before field of a diff, or with its Error
+// for hosts that could not be captured.
+func (e *Executor) snapshotHosts(ctx context.Context, targets []string, spec *inventory.Snapshot, concurrency int) []SnapshotDiff {
+	snaps := make([]SnapshotDiff, len(targets))
+	var mu sync.Mutex
+	hosts := forEachHost(ctx, targets, concurrency, func(ctx context.Context, hostID string) StepHost {
+		conn, err := e.manager.ResolveConnection(hostID)
+		if err == nil {
+			err = conn.ResolveSecrets()
+		}
+		if err != nil {
+			return StepHost{HostID: hostID, Error: redact.Error(err).Error()}
+		}
+		redact.Default().AddConnection(conn)
+		e.applyTimeouts(conn, ExecOptions{})
+		snap, err := e.takeSnapshot(ctx, conn, spec)
+		if err != nil {
+			return StepHost{HostID: hostID, Error: err.Error()}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for i, id := range targets {
+			if id == hostID {
+				snaps[i].Before = snap
+			}
+		}
+		return StepHost{HostID: hostID, OK: true}
+	}, canceledStep)
+	for i, h := range hosts {
+		snaps[i].HostID = h.HostID
+		snaps[i].Error = h.Error
+	}
+	return snaps
+}
+
+// compareSnapshots diffs the snapshots taken by snapshotHosts on the same targets
+// before and after an operation.
+func compareSnapshots(before, after []SnapshotDiff) []SnapshotDiff {
+	diffs := make([]SnapshotDiff, len(before))
+	for i, b := range before {
+		a := after[i]
+		switch {
+		case b.Error != "":
+			diffs[i] = SnapshotDiff{HostID: b.HostID, Error: "before: " + b.Error}
+		case a.Error != "":
+			diffs[i] = SnapshotDiff{HostID: b.HostID, Before: b.Before, Error: "after: " + a.Error}
+		default:
+			diffs[i] = *diffSnapshots(b.Before, a.Before)
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,150 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	before := parseSnapshot("#path\t/etc/app.conf\tfile\taaa\n#path\t/etc/app.d\tdir\tbbb\n#path\t/srv/new\tmissing\t\n"+
+		"#service\tnginx\tactive\n#service\tcron\tactive\nnoise\n", "web01")
+	after := parseSnapshot("#path\t/etc/app.conf\tfile\tccc\n#path\t/etc/app.d\tmissing\t\n#path\t/srv/new\tfile\tddd\n"+
+		"#service\tnginx\tfailed\n#service\tcron\tactive\n", "web01")
+	assert.Equal(t, PathState{Kind: PathFile, Checksum: "aaa"}, before.Paths["/etc/app.conf"])
+	assert.Len(t, before.Services, 2)
+
+	d := diffSnapshots(before, after)
+	require.True(t, d.Changed())
+	assert.Equal(t, "web01", d.HostID)
+	assert.Equal(t, []SnapshotChange{
+		{Path: "/etc/app.conf", Before: "file aaa", After: "file ccc"},
+		{Path: "/etc/app.d", Before: "dir bbb", After: "missing"},
+		{Path: "/srv/new", Before: "missing", After: "file ddd"},
+		{Service: "nginx", Before: "active", After: "failed"},
+	}, d.Changes)
+
+	var hints []string
+	for _, c := range d.Changes {
+		hints = append(hints, c.String())
+	}
+	assert.Equal(t, []string{
+		"/etc/app.conf: file modified (restore it from a backup to roll back)",
+		"/etc/app.d: removed (was dir; restore it from a backup to roll back)",
+		"/srv/new: created (file; remove it to roll back)",
+		"service nginx: active -> failed (restart it to roll back)",
+	}, hints)
+
+	assert.False(t, diffSnapshots(before, before).Changed())
+}
+
+func TestSnapshot(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (*Executor, string, *inventory.Snapshot) {
+		e, _ := setupExecutor(t)
+		require.NoError(t, e.manager.AddHost(inventory.NewLocalHost("build", "build")))
+		dir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "conf.d"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "conf.d", "a.conf"), []byte("a"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "app.conf"), []byte("v1"), 0o644))
+		spec := &inventory.Snapshot{Paths: []string{
+			filepath.Join(dir, "app.conf"), filepath.Join(dir, "conf.d"),
+			filepath.Join(dir, "same"), filepath.Join(dir, "new"),
+		}}
+		return e, dir, spec
+	}
+	changes := func(t *testing.T, d *SnapshotDiff) []string {
+		require.NotNil(t, d)
+		require.Empty(t, d.Error)
+		var paths []string
+		for _, c := range d.Changes {
+			paths = append(paths, c.Path)
+		}
+		return paths
+	}
+
+	t.Run("records what a command changed", func(t *testing.T) {
+		e, dir, spec := setup(t)
+		history := t.TempDir()
+		results, err := e.Exec(ctx, ExecOptions{
+			HostIDs:    []string{"build"},
+			Command:    "cd " + dir + " && echo v2 > app.conf && echo b > conf.d/b.conf && touch new",
+			Snapshot:   spec,
+			HistoryDir: history,
+		})
+		require.NoError(t, err)
+		r := results[0]
+		require.True(t, r.OK(), r.Stderr)
+		assert.Equal(t, []string{filepath.Join(dir, "app.conf"), filepath.Join(dir, "conf.d"), filepath.Join(dir, "new")}, changes(t, r.Snapshot))
+		assert.Equal(t, "dir", r.Snapshot.Before.Paths[filepath.Join(dir, "conf.d")].Kind)
+		assert.Equal(t, PathMissing, r.Snapshot.Before.Paths[filepath.Join(dir, "same")].Kind)
+
+		index, err := ReadRunIndex(history, r.RunID)
+		require.NoError(t, err)
+		assert.Equal(t, spec, index.Snapshot)
+		entry, ok := index.Entry("build")
+		require.True(t, ok)
+		require.NotNil(t, entry.Snapshot)
+		assert.Equal(t, r.Snapshot.Changes, entry.Snapshot.Changes)
+	})
+
+	t.Run("uses the snapshot of saved commands", func(t *testing.T) {
+		e, dir, spec := setup(t)
+		cmd := inventory.NewSavedCommand("bump", "Bump", "echo v3 > "+inventory.ShellQuote(filepath.Join(dir, "app.conf")))
+		cmd.Snapshot = spec
+		require.NoError(t, e.manager.AddCommand(cmd))
+
+		results, err := e.ExecSaved(ctx, "bump", ExecOptions{HostIDs: []string{"build"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(dir, "app.conf")}, changes(t, results[0].Snapshot))
+
+		results, err = e.Exec(ctx, ExecOptions{HostIDs: []string{"build"}, Command: "true"})
+		require.NoError(t, err)
+		assert.Nil(t, results[0].Snapshot, "no snapshot unless asked for")
+	})
+
+	t.Run("keeps the command result when the snapshot fails", func(t *testing.T) {
+		e, runner := setupExecutor(t)
+		runner.fail["web01"] = 3
+		results, err := e.Exec(ctx, ExecOptions{HostIDs: []string{"web01"}, Command: "true", Snapshot: &inventory.Snapshot{Services: []string{"nginx"}}})
+		require.NoError(t, err)
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, 3, results[0].ExitCode)
+		require.NotNil(t, results[0].Snapshot)
+		assert.Contains(t, results[0].Snapshot.Error, "before: failed to take snapshot")
+		assert.False(t, results[0].Snapshot.Changed())
+	})
+
+	t.Run("compares the hosts of a workflow before and after it", func(t *testing.T) {
+		e, dir, spec := setup(t)
+		w := inventory.NewWorkflow("release", "release")
+		w.Target = "host:build"
+		w.Snapshot = spec
+		w.AddStep(inventory.WorkflowStep{Name: "write", Kind: inventory.StepExec, Command: "echo v2 > " + filepath.Join(dir, "app.conf")})
+		w.AddStep(inventory.WorkflowStep{Name: "clean", Kind: inventory.StepExec, Command: "rm -r " + filepath.Join(dir, "conf.d")})
+		require.NoError(t, e.manager.AddWorkflow(w))
+
+		history := t.TempDir()
+		run, err := e.RunWorkflow(ctx, "release", WorkflowOptions{HistoryDir: history})
+		require.NoError(t, err)
+		require.Equal(t, StepOK, run.Status)
+		require.Len(t, run.Snapshots, 1)
+		assert.Equal(t, []string{filepath.Join(dir, "app.conf"), filepath.Join(dir, "conf.d")}, changes(t, &run.Snapshots[0]))
+		assert.Equal(t, "missing", run.Snapshots[0].Changes[1].After)
+
+		saved, err := ReadWorkflowRun(history, run.ID)
+		require.NoError(t, err)
+		assert.Equal(t, run.Snapshots[0].Changes, saved.Snapshots[0].Changes)
+	})
+}
@@ -0,0 +1,213 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/progress"
+	"gossher/internal/term"
+)
+
+// SimulatedRunner is an in-process fake SSH target for load tests: every command
+// waits for Latency plus up to Jitter, writes OutputSize bytes to stdout and fails
+// with exit code 1 at FailureRate.
+type SimulatedRunner struct {
+	Latency     time.Duration
+	Jitter      time.Duration
+	OutputSize  int
+	FailureRate float64
+}
+
+// Run simulates the command on conn.
+func (r *SimulatedRunner) Run(ctx context.Context, conn *inventory.ResolvedConnection, command string, stdout, stderr io.Writer) (int, error) {
+	delay := r.Latency
+	if r.Jitter > 0 {
+		delay += rand.N(r.Jitter)
+	}
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return -1, ctx.Err()
+		}
+	}
+
+	if r.FailureRate > 0 && rand.Float64() < r.FailureRate {
+		fmt.Fprintf(stderr, "%s: simulated failure", conn.HostID)
+		return 1, nil
+	}
+	if r.OutputSize > 0 {
+		line := fmt.Sprintf("%s: %s\n", conn.HostID, command)
+		out := strings.Repeat(line, r.OutputSize/len(line)+1)[:r.OutputSize]
+		if _, err := io.WriteString(stdout, out); err != nil {
+			return -1, err
+		}
+	}
+	return 0, nil
+}
+
+// StressOptions describes a load test of the executor against simulated hosts.
+type StressOptions struct {
+	// Hosts is the number of simulated hosts (default 100).
+	Hosts int
+	// Concurrency lists the concurrency levels to measure, one round each
+	// (default DefaultConcurrency).
+	Concurrency []int
+	// Command is the command run on every host (default "true").
+	Command string
+	// Runner simulates the hosts (default a SimulatedRunner with 50ms latency and
+	// 20ms jitter).
+	Runner Runner
+	// Tuning applies the other executor knobs, e.g. MaxOutput, to every round.
+	Tuning inventory.ExecutorTuning
+}
+
+// StressResult holds the measurements of one round of a load test.
+type StressResult struct {
+	Hosts       int
+	Concurrency int
+	Duration    time.Duration
+	// Throughput is the number of hosts finished per second.
+	Throughput float64
+	// P50, P95 and Max are the durations of the individual host runs.
+	P50 time.Duration
+	P95 time.Duration
+	Max time.Duration
+	// Failed counts the hosts the command failed on.
+	Failed int
+	// Allocated is the memory allocated during the round, in bytes.
+	Allocated uint64
+}
+
+// Stress measures the throughput, latency and memory use of the executor running a
+// command on many simulated hosts at each of the given concurrency levels. The
+// hosts live in a temporary inventory that is removed afterwards.
+func Stress(ctx context.Context, opts StressOptions) ([]StressResult, error) {
+	if opts.Hosts <= 0 {
+		opts.Hosts = 100
+	}
+	if len(opts.Concurrency) == 0 {
+		opts.Concurrency = []int{DefaultConcurrency}
+	}
+	if opts.Command == "" {
+		opts.Command = "true"
+	}
+	if opts.Runner == nil {
+		opts.Runner = &SimulatedRunner{Latency: 50 * time.Millisecond, Jitter: 20 * time.Millisecond}
+	}
+	if err := opts.Tuning.Validate(); err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "gossher-stress-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stress inventory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	m, err := stressInventory(dir, opts.Hosts)
+	if err != nil {
+		return nil, err
+	}
+	e := New(m, opts.Runner)
+	e.SetTuning(opts.Tuning)
+
+	results := make([]StressResult, 0, len(opts.Concurrency))
+	for _, c := range opts.Concurrency {
+		if c <= 0 {
+			return results, fmt.Errorf("invalid stress concurrency: %d", c)
+		}
+		r, err := stressRound(ctx, e, opts.Command, c)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// stressInventory creates an inventory of n hosts in a group named "stress".
+func stressInventory(dir string, n int) (*inventory.Manager, error) {
+	m := inventory.NewManager(dir)
+	if err := m.Load(); err != nil {
+		return nil, err
+	}
+
+	g := inventory.NewGroup("stress")
+	for i := range n {
+		id := fmt.Sprintf("sim%04d", i)
+		h := inventory.NewHost(id, id, fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff))
+		h.User = "stress"
+		if err := m.AddHost(h); err != nil {
+			return nil, err
+		}
+		g.AddHost(id)
+	}
+	if err := m.AddGroup(g); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// stressRound runs the command once on all simulated hosts.
+func stressRound(ctx context.Context, e *Executor, command string, concurrency int) (StressResult, error) {
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	start := time.Now()
+	results, err := e.Exec(ctx, ExecOptions{Command: command, Groups: []string{"stress"}, Concurrency: concurrency})
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		return StressResult{}, err
+	}
+
+	r := StressResult{
+		Hosts:       len(results),
+		Concurrency: concurrency,
+		Duration:    elapsed,
+		Allocated:   after.TotalAlloc - before.TotalAlloc,
+	}
+	if elapsed > 0 {
+		r.Throughput = float64(len(results)) / elapsed.Seconds()
+	}
+
+	durations := make([]time.Duration, 0, len(results))
+	for _, res := range results {
+		if !res.OK() {
+			r.Failed++
+		}
+		durations = append(durations, res.Duration)
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	if n := len(durations); n > 0 {
+		r.P50 = durations[(n-1)*50/100]
+		r.P95 = durations[(n-1)*95/100]
+		r.Max = durations[n-1]
+	}
+	return r, nil
+}
+
+// WriteStressReport writes the results of a load test as a table.
+func WriteStressReport(w io.Writer, results []StressResult) error {
+	tw := term.NewTable(w)
+	fmt.Fprintln(tw, "HOSTS\tCONCURRENCY\tDURATION\tHOSTS/S\tP50\tP95\tMAX\tFAILED\tALLOCATED")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%.1f\t%s\t%s\t%s\t%d\t%s\n",
+			r.Hosts, r.Concurrency, r.Duration.Round(time.Millisecond), r.Throughput,
+			r.P50.Round(time.Millisecond), r.P95.Round(time.Millisecond), r.Max.Round(time.Millisecond),
+			r.Failed, progress.FormatBytes(int64(r.Allocated)))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,100 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTuning(t *testing.T) {
+	t.Run("concurrency falls back to the tuned value", func(t *testing.T) {
+		e, _ := setupExecutor(t)
+		assert.Equal(t, DefaultConcurrency, e.concurrency(0))
+
+		e.SetTuning(inventory.ExecutorTuning{Concurrency: 3})
+		assert.Equal(t, 3, e.concurrency(0))
+		assert.Equal(t, 7, e.concurrency(7))
+	})
+
+	t.Run("max output drops the rest", func(t *testing.T) {
+		e, _ := setupExecutor(t)
+		e.SetTuning(inventory.ExecutorTuning{MaxOutput: 5})
+
+		results, err := e.Exec(context.Background(), ExecOptions{Command: "uptime", HostIDs: []string{"web01"}})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "deplo\n[15 bytes of output dropped]", results[0].Stdout)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		assert.NoError(t, inventory.ExecutorTuning{Concurrency: 50, MaxOutput: 1 << 20}.Validate())
+		assert.Error(t, inventory.ExecutorTuning{Concurrency: -1}.Validate())
+		assert.Error(t, inventory.ExecutorTuning{MaxOutput: -1}.Validate())
+	})
+}
+
+func TestStress(t *testing.T) {
+	t.Run("measures every concurrency level", func(t *testing.T) {
+		runner := &SimulatedRunner{Latency: time.Millisecond, OutputSize: 100}
+		results, err := Stress(context.Background(), StressOptions{Hosts: 40, Concurrency: []int{4, 40}, Runner: runner})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+
+		for _, r := range results {
+			assert.Equal(t, 40, r.Hosts)
+			assert.Zero(t, r.Failed)
+			assert.Positive(t, r.Throughput)
+			assert.LessOrEqual(t, r.P50, r.P95)
+			assert.LessOrEqual(t, r.P95, r.Max)
+		}
+		assert.Equal(t, 4, results[0].Concurrency)
+		assert.Equal(t, 40, results[1].Concurrency)
+
+		var buf bytes.Buffer
+		require.NoError(t, WriteStressReport(&buf, results))
+		assert.Contains(t, buf.String(), "HOSTS/S")
+	})
+
+	t.Run("counts failures", func(t *testing.T) {
+		runner := &SimulatedRunner{FailureRate: 1}
+		results, err := Stress(context.Background(), StressOptions{Hosts: 10, Runner: runner})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, 10, results[0].Failed)
+	})
+
+	t.Run("rejects invalid concurrency", func(t *testing.T) {
+		_, err := Stress(context.Background(), StressOptions{Hosts: 1, Concurrency: []int{0}})
+		assert.Error(t, err)
+	})
+}
+
+// BenchmarkExec runs a command on hundreds of simulated hosts at several pool sizes,
+// e.g. go test -bench Exec -benchmem ./internal/executor.
+func BenchmarkExec(b *testing.B) {
+	for _, hosts := range []int{100, 500} {
+		m, err := stressInventory(b.TempDir(), hosts)
+		require.NoError(b, err)
+
+		for _, concurrency := range []int{10, 50, 200} {
+			b.Run(fmt.Sprintf("hosts=%d/concurrency=%d", hosts, concurrency), func(b *testing.B) {
+				e := New(m, &SimulatedRunner{Latency: time.Millisecond, OutputSize: 1024})
+				opts := ExecOptions{Command: "uptime", Groups: []string{"stress"}, Concurrency: concurrency}
+				b.ReportAllocs()
+				for b.Loop() {
+					if _, err := e.Exec(context.Background(), opts); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(hosts*b.N)/b.Elapsed().Seconds(), "hosts/s")
+			})
+		}
+	}
+}
@@ -0,0 +1,444 @@
+package executor
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/redact"
+)
+
+// SyncMethod is how Sync transfers a directory.
+type SyncMethod string
+
+const (
+	// SyncAuto uses rsync when it is installed on both sides and the host can be
+	// reached with ssh, and uploads otherwise or when rsync fails.
+	SyncAuto SyncMethod = "auto"
+	// SyncRsync runs the local rsync over ssh, which only sends the changed parts of
+	// changed files. ssh runs with BatchMode, so only key authentication works.
+	SyncRsync SyncMethod = "rsync"
+	// SyncUpload compares file hashes with sha256sum on the host and uploads the
+	// changed files with the runner.
+	SyncUpload SyncMethod = "upload"
+)
+
+// rsyncStatPatterns read the counters of "rsync --stats".
+var (
+	rsyncFilesPattern   = regexp.MustCompile(`(?m)^Number of (?:regular )?files transferred: ([\d,.]+)`)
+	rsyncDeletedPattern = regexp.MustCompile(`(?m)^Number of deleted files: ([\d,.]+)`)
+	rsyncBytesPattern   = regexp.MustCompile(`(?m)^Total transferred file size: ([\d,.]+)`)
+)
+
+// SyncOptions describes a directory sync from this machine to hosts.
+type SyncOptions struct {
+	// HostIDs, Groups and Target select the hosts as in ExecOptions.
+	HostIDs []string `json:"host_ids,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+	Target  string   `json:"target,omitempty"`
+
+	// Source is the local directory whose content is synced into the remote
+	// directory Destination, which is created if needed.
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	// Delete removes remote files that are not in Source. Directories left empty
+	// by uploads are kept.
+	Delete bool `json:"delete,omitempty"`
+
+	// Method is how files are transferred (default SyncAuto). Rsync is the local
+	// rsync binary (default "rsync" from PATH).
+	Method SyncMethod `json:"method,omitempty"`
+	Rsync  string     `json:"rsync,omitempty"`
+
+	// Concurrency limits parallel hosts (default DefaultConcurrency) and Timeout
+	// each host's sync.
+	Concurrency int           `json:"concurrency,omitempty"`
+	Timeout     time.Duration `json:"timeout,omitempty"`
+}
+
+// validate checks that the options are complete.
+func (o *SyncOptions) validate() error {
+	if o.Source == "" || o.Destination == "" {
+		return fmt.Errorf("source and destination are required")
+	}
+	switch o.Method {
+	case "", SyncAuto, SyncRsync, SyncUpload:
+		return nil
+	}
+	return fmt.Errorf("invalid sync method: %s", o.Method)
+}
+
+// SyncResult is the outcome of a sync on one host.
+type SyncResult struct {
+	HostID string
+	// Method is how the files were transferred: rsync or upload.
+	Method SyncMethod
+	// Files and Bytes count the transferred files, Deleted the removed ones.
+	Files   int
+	Bytes   int64
+	Deleted int
+	// RsyncError is why an automatic sync did not use rsync.
+	RsyncError string
+	Err        error
+	Duration   time.Duration
+}
+
+// OK reports whether the sync succeeded.
+func (r *SyncResult) OK() bool {
+	return r.Err == nil
+}
+
+// localFile is a file of the synced directory.
+type localFile struct {
+	path string
+	mode os.FileMode
+	size int64
+	hash string
+}
+
+// Sync makes a remote directory of each selected host match a local directory.
+// With rsync available on both sides, only the changes are sent; otherwise the
+// changed files are found by hash and uploaded whole. See SyncMethod.
+func (e *Executor) Sync(ctx context.Context, opts SyncOptions) ([]SyncResult, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	opts.Method = cmp.Or(opts.Method, SyncAuto)
+	opts.Source = inventory.ExpandPath(opts.Source)
+	if info, err := os.Stat(opts.Source); err != nil {
+		return nil, fmt.Errorf("failed to read source: %w", err)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("source %s is not a directory", opts.Source)
+	}
+
+	targets, err := e.ResolveTargets(ExecOptions{HostIDs: opts.HostIDs, Groups: opts.Groups, Target: opts.Target})
+	if err != nil {
+		return nil, err
+	}
+
+	// The local files are read once for all hosts, and only when an upload needs
+	// them.
+	var files []localFile
+	var filesErr error
+	var filesOnce sync.Once
+	listFiles := func() ([]localFile, error) {
+		filesOnce.Do(func() { files, filesErr = listLocalFiles(opts.Source) })
+		return files, filesErr
+	}
+
+	return forEachHost(ctx, targets, e.concurrency(opts.Concurrency), func(ctx context.Context, hostID string) SyncResult {
+		return e.syncHost(ctx, hostID, opts, listFiles)
+	}, func(hostID string, err error) SyncResult {
+		return SyncResult{HostID: hostID, Err: err}
+	}), nil
+}
+
+// syncHost syncs the directory to one host.
+func (e *Executor) syncHost(ctx context.Context, hostID string, opts SyncOptions, listFiles func() ([]localFile, error)) (r SyncResult) {
+	started := time.Now()
+	r.HostID = hostID
+	defer func() { r.Duration = time.Since(started) }()
+
+	conn, err := e.manager.ResolveConnection(hostID)
+	if err == nil {
+		err = conn.ResolveSecrets()
+	}
+	if err != nil {
+		r.Err = redact.Error(err)
+		return r
+	}
+	redact.Default().AddConnection(conn)
+	e.applyTimeouts(conn, ExecOptions{Timeout: opts.Timeout})
+	if conn.Timeouts.Command > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conn.Timeouts.Command)
+		defer cancel()
+	}
+
+	if opts.Method != SyncUpload {
+		err := e.syncRsync(ctx, conn, opts, &r)
+		if err == nil {
+			r.Method = SyncRsync
+			return r
+		}
+		if opts.Method == SyncRsync || ctx.Err() != nil {
+			r.Err = fmt.Errorf("host %s: %w", hostID, err)
+			return r
+		}
+		r.RsyncError = err.Error()
+	}
+
+	r.Method = SyncUpload
+	files, err := listFiles()
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	if err := e.syncUpload(ctx, conn, opts, files, &r); err != nil {
+		r.Err = fmt.Errorf("host %s: %w", hostID, err)
+	}
+	return r
+}
+
+// syncRsync runs rsync over ssh, or locally for local hosts.
+func (e *Executor) syncRsync(ctx context.Context, conn *inventory.ResolvedConnection, opts SyncOptions, r *SyncResult) error {
+	if conn.Relay != nil || conn.GatewayURL != "" || len(conn.Knock) > 0 {
+		return fmt.Errorf("rsync cannot reach host %s through a relay, gateway or port knocking", conn.HostID)
+	}
+	bin, err := exec.LookPath(cmp.Or(opts.Rsync, "rsync"))
+	if err != nil {
+		return fmt.Errorf("rsync is not installed locally: %w", err)
+	}
+
+	args := []string{"-az", "--stats"}
+	if opts.Delete {
+		args = append(args, "--delete")
+	}
+	dest := strings.TrimSuffix(opts.Destination, "/") + "/"
+	if conn.Local {
+		dest = inventory.ExpandPath(dest)
+	} else {
+		if _, err := e.step(ctx, conn, "command -v rsync", 0); err != nil {
+			return fmt.Errorf("rsync is not installed on the host: %w", err)
+		}
+		ssh, cleanup, err := rsyncShell(conn)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		args = append(args, "-e", ssh, "--rsync-path", "mkdir -p "+inventory.ShellQuote(dest)+" && rsync")
+		dest = conn.RemotePath(dest)
+	}
+	args = append(args, "--", filepath.Clean(opts.Source)+string(filepath.Separator), dest)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		msg := redact.String(strings.TrimSpace(stderr.String()))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("rsync failed: %s", msg)
+	}
+	r.Files, r.Deleted, r.Bytes = parseRsyncStats(stdout.String())
+	return nil
+}
+
+// rsyncShell returns the ssh command rsync connects with, and a cleanup removing
+// the temporary file of a key fetched from a secret store.
+func rsyncShell(conn *inventory.ResolvedConnection) (string, func(), error) {
+	c := *conn
+	cleanup := func() {}
+	var extra []string
+	if c.PrivateKey != "" {
+		f, err := os.CreateTemp("", "gossher-rsync-key-*")
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to write key: %w", err)
+		}
+		key := c.PrivateKey
+		if !strings.HasSuffix(key, "\n") {
+			key += "\n"
+		}
+		_, err = f.WriteString(key)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return "", nil, fmt.Errorf("failed to write key: %w", err)
+		}
+		c.KeyPath = f.Name()
+		cleanup = func() { os.Remove(f.Name()) }
+		extra = append(extra, "-o", "IdentitiesOnly=yes")
+	}
+
+	args := []string{"ssh", "-o", "BatchMode=yes"}
+	for _, a := range append(c.SSHArgs(), extra...) {
+		args = append(args, inventory.ShellQuote(a))
+	}
+	return strings.Join(args, " "), cleanup, nil
+}
+
+// parseRsyncStats reads the transferred and deleted files and transferred bytes
+// from the output of "rsync --stats".
+func parseRsyncStats(out string) (files, deleted int, size int64) {
+	number := func(re *regexp.Regexp) int64 {
+		m := re.FindStringSubmatch(out)
+		if m == nil {
+			return 0
+		}
+		n, _ := strconv.ParseInt(strings.NewReplacer(",", "", ".", "").Replace(m[1]), 10, 64)
+		return n
+	}
+	return int(number(rsyncFilesPattern)), int(number(rsyncDeletedPattern)), number(rsyncBytesPattern)
+}
+
+// syncUpload uploads the files whose hash differs on the host and deletes the
+// remote files missing locally when asked to.
+func (e *Executor) syncUpload(ctx context.Context, conn *inventory.ResolvedConnection, opts SyncOptions, files []localFile, r *SyncResult) error {
+	uploader, ok := e.runnerFor(conn).(Uploader)
+	if !ok {
+		return ErrNoUploader
+	}
+
+	dest := strings.TrimSuffix(opts.Destination, "/")
+	if dest == "" {
+		dest = "/"
+	}
+	quoted := inventory.ShellQuote(dest)
+	out, err := e.step(ctx, conn, "mkdir -p -- "+quoted+" && cd -- "+quoted+" && find . -type f -exec sha256sum -- {} +", 0)
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", dest, err)
+	}
+	remote := parseRemoteHashes(out)
+
+	var changed []localFile
+	dirs := map[string]bool{}
+	for _, f := range files {
+		if remote[f.path] == f.hash {
+			continue
+		}
+		changed = append(changed, f)
+		if dir := path.Dir(f.path); dir != "." {
+			dirs[path.Join(dest, dir)] = true
+		}
+	}
+	if len(dirs) > 0 {
+		command := "mkdir -p --"
+		for _, dir := range sortedKeys(dirs) {
+			command += " " + inventory.ShellQuote(dir)
+		}
+		if _, err := e.step(ctx, conn, command, 0); err != nil {
+			return fmt.Errorf("failed to create directories: %w", err)
+		}
+	}
+
+	for _, f := range changed {
+		src, err := os.Open(filepath.Join(opts.Source, filepath.FromSlash(f.path)))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", f.path, err)
+		}
+		err = uploader.Upload(ctx, conn, src, path.Join(dest, f.path), f.mode)
+		src.Close()
+		if err != nil {
+			return redact.Error(err)
+		}
+		r.Files++
+		r.Bytes += f.size
+	}
+
+	if !opts.Delete {
+		return nil
+	}
+	local := make(map[string]bool, len(files))
+	for _, f := range files {
+		local[f.path] = true
+	}
+	var stale []string
+	for p := range remote {
+		if !local[p] {
+			stale = append(stale, p)
+		}
+	}
+	sort.Strings(stale)
+	for len(stale) > 0 {
+		batch := stale[:min(len(stale), 100)]
+		stale = stale[len(batch):]
+		command := "rm -f --"
+		for _, p := range batch {
+			command += " " + inventory.ShellQuote(path.Join(dest, p))
+		}
+		if _, err := e.step(ctx, conn, command, 0); err != nil {
+			return fmt.Errorf("failed to delete files: %w", err)
+		}
+		r.Deleted += len(batch)
+	}
+	return nil
+}
+
+// listLocalFiles hashes the regular files below dir, with slash-separated paths
+// relative to it. Symbolic links and other special files are skipped.
+func listLocalFiles(dir string) ([]localFile, error) {
+	var files []localFile
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, localFile{
+			path: filepath.ToSlash(rel),
+			mode: info.Mode().Perm(),
+			size: info.Size(),
+			hash: hex.EncodeToString(h.Sum(nil)),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source: %w", err)
+	}
+	return files, nil
+}
+
+// parseRemoteHashes parses sha256sum output of paths starting with "./" into
+// hashes by relative path. Lines of escaped names, starting with a backslash, are
+// skipped, so those files are uploaded again and never deleted.
+func parseRemoteHashes(out string) map[string]string {
+	hashes := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		hash, p, ok := strings.Cut(line, "  ./")
+		if !ok || len(hash) != sha256.Size*2 {
+			continue
+		}
+		hashes[p] = hash
+	}
+	return hashes
+}
+
+// sortedKeys returns the keys of a set in order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,160 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rsyncStats = `
+Number of files: 12 (reg: 10, dir: 2)
+Number of created files: 1 (reg: 1)
+Number of deleted files: 2 (reg: 2)
+Number of regular files transferred: 3
+Total file size: 1,234,567 bytes
+Total transferred file size: 12,345 bytes
+Literal data: 100 bytes
+`
+
+func TestParseRsyncStats(t *testing.T) {
+	files, deleted, size := parseRsyncStats(rsyncStats)
+	assert.Equal(t, 3, files)
+	assert.Equal(t, 2, deleted)
+	assert.Equal(t, int64(12345), size)
+
+	files, deleted, size = parseRsyncStats("Number of files transferred: 7\n")
+	assert.Equal(t, 7, files, "rsync before 3.1 does not say regular")
+	assert.Zero(t, deleted)
+	assert.Zero(t, size)
+}
+
+func TestRsyncShell(t *testing.T) {
+	conn := &inventory.ResolvedConnection{Port: 2222, Timeouts: inventory.Timeouts{Connect: 5 * time.Second}}
+	shell, cleanup, err := rsyncShell(conn)
+	require.NoError(t, err)
+	defer cleanup()
+	assert.Equal(t, "ssh -o BatchMode=yes -p 2222 -o ConnectTimeout=5", shell)
+}
+
+func TestSync(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	ctx := context.Background()
+
+	writeTree := func(t *testing.T, files map[string]string) string {
+		dir := t.TempDir()
+		for name, content := range files {
+			p := filepath.Join(dir, filepath.FromSlash(name))
+			require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+			require.NoError(t, os.WriteFile(p, []byte(content), 0o640))
+		}
+		return dir
+	}
+
+	t.Run("uploads changed files and deletes stale ones", func(t *testing.T) {
+		e, _ := setupExecutor(t)
+		require.NoError(t, e.manager.AddHost(inventory.NewLocalHost("build", "build")))
+		src := writeTree(t, map[string]string{"index.html": "home", "assets/app.js": "js", "same.txt": "same"})
+		dest := writeTree(t, map[string]string{"same.txt": "same", "index.html": "old", "stale/old.css": "css"})
+
+		opts := SyncOptions{HostIDs: []string{"build"}, Source: src, Destination: dest, Delete: true, Method: SyncUpload}
+		results, err := e.Sync(ctx, opts)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		r := results[0]
+		require.NoError(t, r.Err)
+		assert.Equal(t, SyncUpload, r.Method)
+		assert.Equal(t, 2, r.Files)
+		assert.Equal(t, int64(len("home")+len("js")), r.Bytes)
+		assert.Equal(t, 1, r.Deleted)
+
+		data, err := os.ReadFile(filepath.Join(dest, "assets", "app.js"))
+		require.NoError(t, err)
+		assert.Equal(t, "js", string(data))
+		info, err := os.Stat(filepath.Join(dest, "assets", "app.js"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+		assert.NoFileExists(t, filepath.Join(dest, "stale", "old.css"))
+
+		results, err = e.Sync(ctx, opts)
+		require.NoError(t, err)
+		assert.Zero(t, results[0].Files, "an unchanged tree uploads nothing")
+	})
+
+	t.Run("falls back to uploading without rsync", func(t *testing.T) {
+		e, _ := setupExecutor(t)
+		require.NoError(t, e.manager.AddHost(inventory.NewLocalHost("build", "build")))
+		src := writeTree(t, map[string]string{"a.txt": "a"})
+		dest := filepath.Join(t.TempDir(), "new")
+
+		results, err := e.Sync(ctx, SyncOptions{HostIDs: []string{"build"}, Source: src, Destination: dest, Rsync: "/nonexistent/rsync"})
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+		assert.Equal(t, SyncUpload, results[0].Method)
+		assert.Contains(t, results[0].RsyncError, "not installed locally")
+		assert.FileExists(t, filepath.Join(dest, "a.txt"))
+	})
+
+	t.Run("runs rsync over ssh", func(t *testing.T) {
+		base, inner := setupExecutor(t)
+		runner := &scriptedRunner{fakeRunner: inner, probes: map[string]map[string]probeReply{
+			"web01": {"command -v rsync": {out: "/usr/bin/rsync\n"}},
+			"web02": {"command -v rsync": {code: 1}},
+		}}
+		e := New(base.manager, runner)
+		h, ok := e.manager.GetHost("web01")
+		require.True(t, ok)
+		h.Port = 2222
+		require.NoError(t, e.manager.UpdateHost(h))
+
+		bin := t.TempDir()
+		argsFile := filepath.Join(bin, "args")
+		script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + argsFile + "\ncat <<'EOF'\n" + rsyncStats + "EOF\n"
+		require.NoError(t, os.WriteFile(filepath.Join(bin, "rsync"), []byte(script), 0o755))
+		src := writeTree(t, map[string]string{"a.txt": "a"})
+
+		results, err := e.Sync(ctx, SyncOptions{
+			HostIDs: []string{"web01", "web02"}, Source: src, Destination: "/srv/app",
+			Delete: true, Method: SyncRsync, Rsync: filepath.Join(bin, "rsync"),
+		})
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+		assert.Equal(t, SyncRsync, results[0].Method)
+		assert.Equal(t, 3, results[0].Files)
+		assert.Equal(t, 2, results[0].Deleted)
+
+		args, err := os.ReadFile(argsFile)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"-az", "--stats", "--delete",
+			"-e", "ssh -o BatchMode=yes -p 2222",
+			"--rsync-path", "mkdir -p /srv/app/ && rsync",
+			"--", src + "/", "deploy@10.0.0.1:/srv/app/",
+		}, strings.Split(strings.TrimSpace(string(args)), "\n"))
+
+		require.Error(t, results[1].Err, "rsync was required")
+		assert.Contains(t, results[1].Err.Error(), "not installed on the host")
+	})
+
+	t.Run("validates options", func(t *testing.T) {
+		e, _ := setupExecutor(t)
+		_, err := e.Sync(ctx, SyncOptions{HostIDs: []string{"web01"}, Source: t.TempDir()})
+		assert.Error(t, err)
+		_, err = e.Sync(ctx, SyncOptions{HostIDs: []string{"web01"}, Source: t.TempDir(), Destination: "/srv", Method: "ftp"})
+		assert.Error(t, err)
+		file := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(file, nil, 0o600))
+		_, err = e.Sync(ctx, SyncOptions{HostIDs: []string{"web01"}, Source: file, Destination: "/srv"})
+		assert.Error(t, err)
+	})
+}
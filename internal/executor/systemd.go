@@ -0,0 +1,181 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gossher/internal/inventory"
+)
+
+// DefaultLogLines is the number of journal lines Service.Logs returns by default.
+const DefaultLogLines = 100
+
+// unitProperties are the properties of "systemctl show" read into UnitStatus.
+var unitProperties = []string{
+	"Id", "Description", "LoadState", "ActiveState", "SubState",
+	"UnitFileState", "MainPID", "ActiveEnterTimestamp", "NRestarts",
+}
+
+// systemdTimeLayout is the layout of timestamps in "systemctl show" output.
+const systemdTimeLayout = "Mon 2006-01-02 15:04:05 MST"
+
+// Service manages a systemd unit on the hosts selected by ExecOptions. The
+// commands go through Exec, so the command policy, concurrency, timeouts and
+// history of the options apply; their Command is ignored.
+type Service struct {
+	e    *Executor
+	Unit string
+}
+
+// Service returns the helpers of a systemd unit, e.g. "nginx" or "nginx.service".
+func (e *Executor) Service(unit string) *Service {
+	return &Service{e: e, Unit: unit}
+}
+
+// Start starts the unit.
+func (s *Service) Start(ctx context.Context, opts ExecOptions) ([]Result, error) {
+	return s.exec(ctx, opts, serviceCommand("start", s.Unit))
+}
+
+// Stop stops the unit.
+func (s *Service) Stop(ctx context.Context, opts ExecOptions) ([]Result, error) {
+	return s.exec(ctx, opts, serviceCommand("stop", s.Unit))
+}
+
+// Restart restarts the unit.
+func (s *Service) Restart(ctx context.Context, opts ExecOptions) ([]Result, error) {
+	return s.exec(ctx, opts, serviceCommand("restart", s.Unit))
+}
+
+// Logs returns the last lines of the unit's journal, DefaultLogLines when lines is
+// zero, in the Stdout of each result.
+func (s *Service) Logs(ctx context.Context, opts ExecOptions, lines int) ([]Result, error) {
+	if lines <= 0 {
+		lines = DefaultLogLines
+	}
+	return s.exec(ctx, opts, fmt.Sprintf("journalctl --no-pager --output=short-iso -n %d -u %s", lines, inventory.ShellQuote(s.Unit)))
+}
+
+// Status reads the state of the unit on each host from "systemctl show".
+func (s *Service) Status(ctx context.Context, opts ExecOptions) ([]UnitStatus, error) {
+	command := "systemctl show --no-pager --property=" + strings.Join(unitProperties, ",") + " -- " + inventory.ShellQuote(s.Unit)
+	results, err := s.exec(ctx, opts, command)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]UnitStatus, len(results))
+	for i, r := range results {
+		st := UnitStatus{HostID: r.HostID, Unit: s.Unit}
+		switch {
+		case r.Err != nil:
+			st.Err = r.Err
+		case r.ExitCode != 0:
+			st.Err = fmt.Errorf("systemctl show exited with status %d: %s", r.ExitCode, strings.TrimSpace(r.Stderr))
+		default:
+			st.parse(r.Stdout)
+		}
+		statuses[i] = st
+	}
+	return statuses, nil
+}
+
+// exec runs a systemctl or journalctl command on the selected hosts.
+func (s *Service) exec(ctx context.Context, opts ExecOptions, command string) ([]Result, error) {
+	if strings.TrimSpace(s.Unit) == "" {
+		return nil, fmt.Errorf("unit is required")
+	}
+	opts.Command = command
+	return s.e.Exec(ctx, opts)
+}
+
+// serviceCommand returns the systemctl command applying an action to a unit.
+func serviceCommand(action, unit string) string {
+	return "systemctl " + action + " -- " + inventory.ShellQuote(unit)
+}
+
+// UnitStatus is the state of a systemd unit on a host.
+type UnitStatus struct {
+	HostID      string `json:"host_id"`
+	Unit        string `json:"unit"`
+	Description string `json:"description,omitempty"`
+	// LoadState is "loaded", or "not-found" for units missing on the host.
+	LoadState string `json:"load_state,omitempty"`
+	// ActiveState and SubState are e.g. "active" and "running", or "failed".
+	ActiveState string `json:"active_state,omitempty"`
+	SubState    string `json:"sub_state,omitempty"`
+	// UnitFileState is e.g. "enabled" or "disabled".
+	UnitFileState string `json:"unit_file_state,omitempty"`
+	MainPID       int    `json:"main_pid,omitempty"`
+	// Since is when the unit last became active.
+	Since    time.Time `json:"since,omitempty"`
+	Restarts int       `json:"restarts,omitempty"`
+	// Err is set when the state could not be read.
+	Err error `json:"-"`
+}
+
+// Active reports whether the unit is active.
+func (u *UnitStatus) Active() bool {
+	return u.ActiveState == "active"
+}
+
+// Failed reports whether the unit failed.
+func (u *UnitStatus) Failed() bool {
+	return u.ActiveState == "failed"
+}
+
+// Found reports whether the unit exists on the host.
+func (u *UnitStatus) Found() bool {
+	return u.Err == nil && u.LoadState != "" && u.LoadState != "not-found"
+}
+
+// String summarizes the state like "systemctl status", e.g. "active (running)".
+func (u *UnitStatus) String() string {
+	switch {
+	case u.Err != nil:
+		return "error: " + u.Err.Error()
+	case !u.Found():
+		return "not found"
+	case u.SubState != "":
+		return u.ActiveState + " (" + u.SubState + ")"
+	default:
+		return u.ActiveState
+	}
+}
+
+// parse reads "systemctl show" output of KEY=VALUE lines.
+func (u *UnitStatus) parse(out string) {
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(strings.TrimRight(line, "\r"), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Id":
+			if value != "" {
+				u.Unit = value
+			}
+		case "Description":
+			u.Description = value
+		case "LoadState":
+			u.LoadState = value
+		case "ActiveState":
+			u.ActiveState = value
+		case "SubState":
+			u.SubState = value
+		case "UnitFileState":
+			u.UnitFileState = value
+		case "MainPID":
+			u.MainPID, _ = strconv.Atoi(value)
+		case "NRestarts":
+			u.Restarts, _ = strconv.Atoi(value)
+		case "ActiveEnterTimestamp":
+			if t, err := time.Parse(systemdTimeLayout, value); err == nil {
+				u.Since = t
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const showRunning = `Id=nginx.service
+Description=A high performance web server
+LoadState=loaded
+ActiveState=active
+SubState=running
+UnitFileState=enabled
+MainPID=1234
+ActiveEnterTimestamp=Tue 2024-05-14 10:02:03 UTC
+NRestarts=2
+`
+
+const showMissing = `Id=nginx.service
+Description=nginx.service
+LoadState=not-found
+ActiveState=inactive
+SubState=dead
+UnitFileState=
+MainPID=0
+ActiveEnterTimestamp=
+NRestarts=0
+`
+
+func TestService(t *testing.T) {
+	setup := func(t *testing.T) (*Executor, *scriptedRunner) {
+		base, inner := setupExecutor(t)
+		runner := &scriptedRunner{fakeRunner: inner, probes: map[string]map[string]probeReply{
+			"web01": {"systemctl show": {out: showRunning}},
+			"web02": {"systemctl show": {out: showMissing}},
+			"db01":  {"systemctl show": {code: 1}},
+		}}
+		return New(base.manager, runner), runner
+	}
+
+	t.Run("status of a group", func(t *testing.T) {
+		e, _ := setup(t)
+		statuses, err := e.Service("nginx").Status(context.Background(), ExecOptions{Groups: []string{"all"}})
+		require.NoError(t, err)
+		require.Len(t, statuses, 3)
+
+		byHost := map[string]UnitStatus{}
+		for _, st := range statuses {
+			byHost[st.HostID] = st
+		}
+
+		running := byHost["web01"]
+		assert.Equal(t, "nginx.service", running.Unit)
+		assert.True(t, running.Active())
+		assert.True(t, running.Found())
+		assert.Equal(t, "enabled", running.UnitFileState)
+		assert.Equal(t, 1234, running.MainPID)
+		assert.Equal(t, 2, running.Restarts)
+		assert.True(t, running.Since.Equal(time.Date(2024, 5, 14, 10, 2, 3, 0, time.UTC)))
+		assert.Equal(t, "active (running)", running.String())
+
+		missing := byHost["web02"]
+		assert.False(t, missing.Found())
+		assert.True(t, missing.Since.IsZero())
+		assert.Equal(t, "not found", missing.String())
+
+		failed := byHost["db01"]
+		assert.Error(t, failed.Err)
+		assert.Contains(t, failed.String(), "error:")
+	})
+
+	t.Run("actions and logs", func(t *testing.T) {
+		e, _ := setup(t)
+		svc := e.Service("nginx")
+
+		results, err := svc.Restart(context.Background(), ExecOptions{HostIDs: []string{"web01"}})
+		require.NoError(t, err)
+		assert.Equal(t, "deploy@web01: systemctl restart -- nginx", results[0].Stdout)
+
+		results, err = svc.Stop(context.Background(), ExecOptions{HostIDs: []string{"web01"}})
+		require.NoError(t, err)
+		assert.Equal(t, "deploy@web01: systemctl stop -- nginx", results[0].Stdout)
+
+		results, err = svc.Logs(context.Background(), ExecOptions{HostIDs: []string{"web01"}}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "deploy@web01: journalctl --no-pager --output=short-iso -n 100 -u nginx", results[0].Stdout)
+
+		_, err = e.Service(" ").Start(context.Background(), ExecOptions{HostIDs: []string{"web01"}})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,79 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// TemplateData is available to command templates as ".", e.g. "ping -c1 {{.Address}}",
+// "systemctl restart {{.Vars.service}}" or "deploy {{.Inputs.version}}".
+type TemplateData struct {
+	HostID  string
+	Name    string
+	Address string
+	Port    int
+	User    string
+	Tags    []string
+	Vars    map[string]string
+	Inputs  map[string]string
+}
+
+// renderCommand renders command as a text/template for a host. Commands without
+// template actions are returned unchanged; missing vars and inputs are errors.
+func (e *Executor) renderCommand(command, hostID string, inputs map[string]string) (string, error) {
+	if !strings.Contains(command, "{{") {
+		return command, nil
+	}
+
+	data, err := e.templateData(hostID, inputs)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New("command").Option("missingkey=error").Parse(command)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse command template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("host %s: failed to render command: %w", hostID, err)
+	}
+	return buf.String(), nil
+}
+
+// templateData returns the template data of a host.
+func (e *Executor) templateData(hostID string, inputs map[string]string) (TemplateData, error) {
+	h, ok := e.manager.GetHost(hostID)
+	if !ok {
+		return TemplateData{}, fmt.Errorf("host %s not found", hostID)
+	}
+	vars, err := e.manager.ResolveVars(hostID)
+	if err != nil {
+		return TemplateData{}, err
+	}
+	conn, err := e.manager.ResolveConnection(hostID)
+	if err != nil {
+		return TemplateData{}, err
+	}
+
+	return TemplateData{
+		HostID:  h.ID,
+		Name:    h.Name,
+		Address: h.Address,
+		Port:    h.Port,
+		User:    conn.User,
+		Tags:    append([]string(nil), h.Tags...),
+		Vars:    vars,
+		Inputs:  inputs,
+	}, nil
+}
+
+var sudoPattern = regexp.MustCompile(`(^|[;&|(\s])sudo(\s|$)`)
+
+// needsSudo reports whether a command invokes sudo.
+func needsSudo(command string) bool {
+	return sudoPattern.MatchString(command)
+}
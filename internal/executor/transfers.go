@@ -0,0 +1,583 @@
+package executor
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/redact"
+)
+
+// TransfersDir is the directory under the data dir holding the transfer queue.
+const TransfersDir = "transfers"
+
+// TransferState is the state of a queued transfer.
+type TransferState string
+
+const (
+	TransferQueued   TransferState = "queued"
+	TransferRunning  TransferState = "running"
+	TransferDone     TransferState = "done"
+	TransferFailed   TransferState = "failed"
+	TransferCanceled TransferState = "canceled"
+)
+
+// Finished reports whether a transfer in this state will not run anymore.
+func (s TransferState) Finished() bool {
+	return s == TransferDone || s == TransferFailed || s == TransferCanceled
+}
+
+// TransferKind is what a queued transfer does.
+type TransferKind string
+
+const (
+	// TransferCopy copies a file from one host to another; see HostCopy.
+	TransferCopy TransferKind = "copy"
+	// TransferUpload copies a local file to a host.
+	TransferUpload TransferKind = "upload"
+	// TransferDownload copies a file of a host to a local file.
+	TransferDownload TransferKind = "download"
+	// TransferSync syncs a local directory to hosts; see Sync.
+	TransferSync TransferKind = "sync"
+)
+
+// ErrTransferNotFound is returned for unknown transfer IDs.
+var ErrTransferNotFound = errors.New("transfer not found")
+
+// TransferJob is the work of a queued transfer: HostCopyOptions, UploadOptions,
+// DownloadOptions or SyncOptions.
+type TransferJob interface {
+	// check validates the job before it is queued.
+	check(e *Executor) error
+	// run performs the transfer.
+	run(ctx context.Context, e *Executor) (*TransferResult, error)
+	// store records the job and its kind in a transfer.
+	store(t *Transfer)
+}
+
+// Transfer is a job in the transfer queue. The options of its Kind are set: the
+// embedded HostCopyOptions for copies, which are the default kind, or Upload,
+// Download or Sync.
+type Transfer struct {
+	ID   string       `json:"id"`
+	Kind TransferKind `json:"kind,omitempty"`
+	HostCopyOptions
+	Upload   *UploadOptions   `json:"upload,omitempty"`
+	Download *DownloadOptions `json:"download,omitempty"`
+	Sync     *SyncOptions     `json:"sync,omitempty"`
+
+	State    TransferState   `json:"state"`
+	Created  time.Time       `json:"created"`
+	Started  time.Time       `json:"started,omitzero"`
+	Finished time.Time       `json:"finished,omitzero"`
+	Result   *TransferResult `json:"result,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// TransferResult reports a finished transfer. A sync that failed on some hosts
+// reports what it transferred to the others.
+type TransferResult struct {
+	// Via and DirectError report how a copy moved the file; see HostCopyResult.
+	Via         HostCopyVia `json:"via,omitempty"`
+	DirectError string      `json:"direct_error,omitempty"`
+	// Files counts the transferred files and Deleted the files a sync removed.
+	Files   int `json:"files,omitempty"`
+	Deleted int `json:"deleted,omitempty"`
+	// Bytes is the size of the transferred data; direct copies are not counted.
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Job returns the work of the transfer.
+func (t *Transfer) Job() (TransferJob, error) {
+	var job TransferJob
+	switch t.Kind {
+	case "", TransferCopy:
+		return t.HostCopyOptions, nil
+	case TransferUpload:
+		if t.Upload != nil {
+			job = *t.Upload
+		}
+	case TransferDownload:
+		if t.Download != nil {
+			job = *t.Download
+		}
+	case TransferSync:
+		if t.Sync != nil {
+			job = *t.Sync
+		}
+	default:
+		return nil, fmt.Errorf("invalid transfer kind: %s", t.Kind)
+	}
+	if job == nil {
+		return nil, fmt.Errorf("%s transfer without %s options", t.Kind, t.Kind)
+	}
+	return job, nil
+}
+
+// before reports whether t was queued before u.
+func (t *Transfer) before(u *Transfer) bool {
+	if !t.Created.Equal(u.Created) {
+		return t.Created.Before(u.Created)
+	}
+	return t.ID < u.ID
+}
+
+// TransferQueue runs queued transfers in the background, in the order they were
+// queued, a few at a time. The queue is stored under
+// dataDir/transfers, so that it survives restarts: transfers that were running
+// when the process stopped are queued again.
+type TransferQueue struct {
+	executor *Executor
+	dir      string
+	// Parallel is how many transfers run at the same time (default 1).
+	Parallel int
+
+	mu        sync.Mutex
+	transfers map[string]*Transfer
+	cancels   map[string]context.CancelFunc
+	running   int
+	wake      chan struct{}
+}
+
+// NewTransferQueue loads the transfer queue under dataDir/transfers. Transfers
+// only start once Run is called.
+func NewTransferQueue(e *Executor, dataDir string) (*TransferQueue, error) {
+	q := &TransferQueue{
+		executor:  e,
+		dir:       filepath.Join(dataDir, TransfersDir),
+		transfers: make(map[string]*Transfer),
+		cancels:   make(map[string]context.CancelFunc),
+		wake:      make(chan struct{}, 1),
+	}
+
+	entries, err := os.ReadDir(q.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read transfers: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(q.dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read transfer %s: %w", entry.Name(), err)
+		}
+		var t Transfer
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, fmt.Errorf("failed to parse transfer %s: %w", entry.Name(), err)
+		}
+		if t.State == TransferRunning {
+			t.State, t.Started = TransferQueued, time.Time{}
+		}
+		q.transfers[t.ID] = &t
+	}
+	return q, nil
+}
+
+// Enqueue adds a job to the queue and returns its transfer.
+func (q *TransferQueue) Enqueue(job TransferJob) (*Transfer, error) {
+	if err := job.check(q.executor); err != nil {
+		return nil, err
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate transfer ID: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t := &Transfer{ID: id, State: TransferQueued, Created: time.Now().UTC()}
+	job.store(t)
+	if err := q.write(t); err != nil {
+		return nil, err
+	}
+	q.transfers[id] = t
+	q.notify()
+	copied := *t
+	return &copied, nil
+}
+
+// Get returns a transfer by ID.
+func (q *TransferQueue) Get(id string) (*Transfer, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t, ok := q.transfers[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTransferNotFound, id)
+	}
+	copied := *t
+	return &copied, nil
+}
+
+// List returns the transfers in the order they were queued.
+func (q *TransferQueue) List() []Transfer {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	list := make([]Transfer, 0, len(q.transfers))
+	for _, t := range q.transfers {
+		list = append(list, *t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].before(&list[j]) })
+	return list
+}
+
+// Cancel cancels a queued or running transfer. A destination file partially
+// written by a running transfer is left as is.
+func (q *TransferQueue) Cancel(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t, ok := q.transfers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTransferNotFound, id)
+	}
+	if t.State.Finished() {
+		return fmt.Errorf("transfer %s is already %s", id, t.State)
+	}
+	if cancel, ok := q.cancels[id]; ok {
+		cancel()
+	}
+	t.State, t.Finished = TransferCanceled, time.Now().UTC()
+	return q.write(t)
+}
+
+// Run starts the queued transfers until ctx is done, then waits for the running
+// ones to stop. Transfers interrupted this way stay queued for the next Run.
+func (q *TransferQueue) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		for q.startNext(ctx, &wg) {
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-q.wake:
+		}
+	}
+}
+
+// startNext starts the oldest queued transfer if a slot is free and reports
+// whether it did.
+func (q *TransferQueue) startNext(ctx context.Context, wg *sync.WaitGroup) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if ctx.Err() != nil || q.running >= cmp.Or(q.Parallel, 1) {
+		return false
+	}
+	var next *Transfer
+	for _, t := range q.transfers {
+		if t.State == TransferQueued && (next == nil || t.before(next)) {
+			next = t
+		}
+	}
+	if next == nil {
+		return false
+	}
+	job, err := next.Job()
+	if err != nil {
+		next.State, next.Finished, next.Error = TransferFailed, time.Now().UTC(), err.Error()
+		_ = q.write(next)
+		return true
+	}
+
+	next.State, next.Started = TransferRunning, time.Now().UTC()
+	// A failed write is not fatal: the transfer is recorded again when it ends.
+	_ = q.write(next)
+	tctx, cancel := context.WithCancel(ctx)
+	q.cancels[next.ID] = cancel
+	q.running++
+
+	wg.Add(1)
+	go func(t *Transfer) {
+		defer wg.Done()
+		defer cancel()
+		result, err := job.run(tctx, q.executor)
+		q.finish(ctx, t, result, err)
+	}(next)
+	return true
+}
+
+// finish records the outcome of a transfer and wakes Run for the next one.
+func (q *TransferQueue) finish(ctx context.Context, t *Transfer, result *TransferResult, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.cancels, t.ID)
+	q.running--
+	defer q.notify()
+
+	switch {
+	case t.State == TransferCanceled:
+		// Cancel already recorded it.
+		return
+	case err == nil:
+		t.State, t.Finished, t.Result = TransferDone, time.Now().UTC(), result
+	case ctx.Err() != nil:
+		t.State, t.Started = TransferQueued, time.Time{}
+	default:
+		t.State, t.Finished, t.Result, t.Error = TransferFailed, time.Now().UTC(), result, err.Error()
+	}
+	_ = q.write(t)
+}
+
+// notify wakes Run without blocking. Caller must hold the lock.
+func (q *TransferQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// write stores a transfer. Caller must hold the lock.
+func (q *TransferQueue) write(t *Transfer) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal transfer: %w", err)
+	}
+	if err := os.MkdirAll(q.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create transfers directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(q.dir, t.ID+".json"), data, 0600); err != nil {
+		return fmt.Errorf("failed to write transfer %s: %w", t.ID, err)
+	}
+	return nil
+}
+
+// ===== Jobs =====
+
+// UploadOptions describes the upload of a local file to a host.
+type UploadOptions struct {
+	HostID string `json:"host"`
+	// Source is the local file, Destination the remote path.
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	// Mode is the mode of the remote file (default DefaultDeployMode).
+	Mode os.FileMode `json:"mode,omitempty"`
+}
+
+// DownloadOptions describes the download of a file of a host to a local file.
+type DownloadOptions struct {
+	HostID string `json:"host"`
+	// Source is the remote file, Destination the local path. The local file is
+	// only replaced once the download is complete and is private to the user.
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+func (o HostCopyOptions) check(e *Executor) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+	return checkHosts(e, o.SourceHost, o.DestHost)
+}
+
+func (o HostCopyOptions) run(ctx context.Context, e *Executor) (*TransferResult, error) {
+	r, err := e.HostCopy(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	return &TransferResult{Via: r.Via, DirectError: r.DirectError, Files: 1, Bytes: r.Bytes, Duration: r.Duration}, nil
+}
+
+func (o HostCopyOptions) store(t *Transfer) {
+	t.Kind, t.HostCopyOptions = TransferCopy, o
+}
+
+func (o UploadOptions) check(e *Executor) error {
+	if o.HostID == "" || o.Source == "" || o.Destination == "" {
+		return fmt.Errorf("host, source and destination are required")
+	}
+	if info, err := os.Stat(inventory.ExpandPath(o.Source)); err != nil {
+		return fmt.Errorf("failed to read source: %w", err)
+	} else if !info.Mode().IsRegular() {
+		return fmt.Errorf("source %s is not a file", o.Source)
+	}
+	return checkHosts(e, o.HostID)
+}
+
+func (o UploadOptions) run(ctx context.Context, e *Executor) (*TransferResult, error) {
+	started := time.Now()
+	f, err := os.Open(inventory.ExpandPath(o.Source))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source: %w", err)
+	}
+	defer f.Close()
+
+	conn, err := e.copyConnection(o.HostID)
+	if err != nil {
+		return nil, err
+	}
+	uploader, ok := e.runnerFor(conn).(Uploader)
+	if !ok {
+		return nil, fmt.Errorf("host %s: %w", o.HostID, ErrNoUploader)
+	}
+	counter := &countingPipe{r: f}
+	if err := uploader.Upload(ctx, conn, counter, o.Destination, cmp.Or(o.Mode, DefaultDeployMode)); err != nil {
+		return nil, fmt.Errorf("host %s: failed to write %s: %w", o.HostID, o.Destination, redact.Error(err))
+	}
+	return &TransferResult{Files: 1, Bytes: counter.n.Load(), Duration: time.Since(started)}, nil
+}
+
+func (o UploadOptions) store(t *Transfer) {
+	t.Kind, t.Upload = TransferUpload, &o
+}
+
+func (o DownloadOptions) check(e *Executor) error {
+	if o.HostID == "" || o.Source == "" || o.Destination == "" {
+		return fmt.Errorf("host, source and destination are required")
+	}
+	return checkHosts(e, o.HostID)
+}
+
+func (o DownloadOptions) run(ctx context.Context, e *Executor) (*TransferResult, error) {
+	started := time.Now()
+	conn, err := e.copyConnection(o.HostID)
+	if err != nil {
+		return nil, err
+	}
+
+	dest := inventory.ExpandPath(o.Destination)
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory of %s: %w", dest, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := e.download(ctx, conn, o.Source, tmp); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("host %s: failed to read %s: %w", o.HostID, o.Source, redact.Error(err))
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err == nil {
+		err = tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", dest, err)
+	}
+	return &TransferResult{Files: 1, Bytes: size, Duration: time.Since(started)}, nil
+}
+
+func (o DownloadOptions) store(t *Transfer) {
+	t.Kind, t.Download = TransferDownload, &o
+}
+
+func (o SyncOptions) check(e *Executor) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+	_, err := e.ResolveTargets(ExecOptions{HostIDs: o.HostIDs, Groups: o.Groups, Target: o.Target})
+	return err
+}
+
+// run syncs to all hosts and fails when any of them failed.
+func (o SyncOptions) run(ctx context.Context, e *Executor) (*TransferResult, error) {
+	started := time.Now()
+	results, err := e.Sync(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	r := &TransferResult{}
+	var failed []string
+	for _, h := range results {
+		r.Files += h.Files
+		r.Deleted += h.Deleted
+		r.Bytes += h.Bytes
+		if h.Err != nil {
+			failed = append(failed, redact.Error(h.Err).Error())
+		}
+	}
+	r.Duration = time.Since(started)
+	if len(failed) > 0 {
+		return r, fmt.Errorf("sync failed on %d of %d hosts: %s", len(failed), len(results), strings.Join(failed, "; "))
+	}
+	return r, nil
+}
+
+func (o SyncOptions) store(t *Transfer) {
+	t.Kind, t.Sync = TransferSync, &o
+}
+
+// checkHosts checks that the hosts of a job exist.
+func checkHosts(e *Executor, hostIDs ...string) error {
+	for _, id := range hostIDs {
+		if _, ok := e.manager.GetHost(id); !ok {
+			return fmt.Errorf("host %s not found", id)
+		}
+	}
+	return nil
+}
+
+// ===== API =====
+
+// ServeHTTP serves the transfer queue for the daemon API: GET /v1/transfers lists
+// the transfers, POST /v1/transfers queues the job in the body, GET
+// /v1/transfers/{id} returns a transfer and DELETE /v1/transfers/{id} cancels it.
+// The body of a copy holds its HostCopyOptions; other jobs set the kind and the
+// options under its name, e.g. {"kind": "upload", "upload": {...}}.
+func (q *TransferQueue) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, isTransfer := strings.CutPrefix(r.URL.Path, "/v1/transfers/")
+	switch {
+	case r.URL.Path == "/v1/transfers" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, q.List())
+	case r.URL.Path == "/v1/transfers" && r.Method == http.MethodPost:
+		var req Transfer
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request: " + err.Error()})
+			return
+		}
+		job, err := req.Job()
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		t, err := q.Enqueue(job)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, t)
+	case isTransfer && id != "" && r.Method == http.MethodGet:
+		t, err := q.Get(id)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, t)
+	case isTransfer && id != "" && r.Method == http.MethodDelete:
+		err := q.Cancel(id)
+		switch {
+		case errors.Is(err, ErrTransferNotFound):
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		case err != nil:
+			writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case r.URL.Path == "/v1/transfers" || isTransfer:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
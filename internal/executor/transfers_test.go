@@ -0,0 +1,310 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingRunner blocks every command until it is canceled.
+type blockingRunner struct {
+	*fakeRunner
+	started chan string
+}
+
+func (r *blockingRunner) Run(ctx context.Context, conn *inventory.ResolvedConnection, command string, stdout, stderr io.Writer) (int, error) {
+	r.started <- conn.HostID
+	<-ctx.Done()
+	return -1, ctx.Err()
+}
+
+func TestTransferQueue(t *testing.T) {
+	runQueue := func(t *testing.T, q *TransferQueue) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- q.Run(ctx) }()
+		t.Cleanup(func() {
+			cancel()
+			require.NoError(t, <-done)
+		})
+	}
+
+	t.Run("runs transfers in order and keeps them across restarts", func(t *testing.T) {
+		e, _ := setupExecutor(t)
+		require.NoError(t, e.manager.AddHost(inventory.NewLocalHost("build", "build")))
+		dataDir, dir := t.TempDir(), t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "a"), []byte("first"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "b"), []byte("second"), 0o644))
+
+		q, err := NewTransferQueue(e, dataDir)
+		require.NoError(t, err)
+		first, err := q.Enqueue(HostCopyOptions{SourceHost: "build", SourcePath: filepath.Join(dir, "a"), DestHost: "build", DestPath: filepath.Join(dir, "a.copy")})
+		require.NoError(t, err)
+		second, err := q.Enqueue(HostCopyOptions{SourceHost: "build", SourcePath: filepath.Join(dir, "missing"), DestHost: "build", DestPath: filepath.Join(dir, "b.copy")})
+		require.NoError(t, err)
+		assert.Equal(t, TransferQueued, first.State)
+
+		runQueue(t, q)
+		require.Eventually(t, func() bool {
+			list := q.List()
+			return list[0].State.Finished() && list[1].State.Finished()
+		}, 5*time.Second, 10*time.Millisecond)
+
+		copied, err := os.ReadFile(filepath.Join(dir, "a.copy"))
+		require.NoError(t, err)
+		assert.Equal(t, "first", string(copied))
+
+		reloaded, err := NewTransferQueue(e, dataDir)
+		require.NoError(t, err)
+		list := reloaded.List()
+		require.Len(t, list, 2)
+		assert.Equal(t, first.ID, list[0].ID)
+		assert.Equal(t, TransferDone, list[0].State)
+		assert.Equal(t, int64(len("first")), list[0].Result.Bytes)
+		assert.Equal(t, second.ID, list[1].ID)
+		assert.Equal(t, TransferFailed, list[1].State)
+		assert.Contains(t, list[1].Error, "failed to read")
+	})
+
+	t.Run("queues interrupted transfers again", func(t *testing.T) {
+		e, _ := setupExecutor(t)
+		dataDir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dataDir, TransfersDir), 0o700))
+		data, err := json.Marshal(Transfer{
+			ID:              "20240101-000000-abcdef",
+			HostCopyOptions: HostCopyOptions{SourceHost: "web01", SourcePath: "/a", DestHost: "web02", DestPath: "/b"},
+			State:           TransferRunning,
+			Started:         time.Now(),
+		})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dataDir, TransfersDir, "20240101-000000-abcdef.json"), data, 0o600))
+
+		q, err := NewTransferQueue(e, dataDir)
+		require.NoError(t, err)
+		tr, err := q.Get("20240101-000000-abcdef")
+		require.NoError(t, err)
+		assert.Equal(t, TransferQueued, tr.State)
+		assert.True(t, tr.Started.IsZero())
+	})
+
+	t.Run("cancels queued and running transfers", func(t *testing.T) {
+		base, inner := setupExecutor(t)
+		runner := &blockingRunner{fakeRunner: inner, started: make(chan string, 1)}
+		e := New(base.manager, runner)
+		q, err := NewTransferQueue(e, t.TempDir())
+		require.NoError(t, err)
+
+		running, err := q.Enqueue(HostCopyOptions{SourceHost: "web01", SourcePath: "/a", DestHost: "web02", DestPath: "/a", Via: HostCopyDirect})
+		require.NoError(t, err)
+		queued, err := q.Enqueue(HostCopyOptions{SourceHost: "web01", SourcePath: "/b", DestHost: "web02", DestPath: "/b", Via: HostCopyDirect})
+		require.NoError(t, err)
+
+		runQueue(t, q)
+		assert.Equal(t, "web01", <-runner.started)
+		tr, err := q.Get(running.ID)
+		require.NoError(t, err)
+		assert.Equal(t, TransferRunning, tr.State)
+
+		require.NoError(t, q.Cancel(queued.ID))
+		require.NoError(t, q.Cancel(running.ID))
+		tr, err = q.Get(queued.ID)
+		require.NoError(t, err)
+		assert.Equal(t, TransferCanceled, tr.State)
+		assert.Error(t, q.Cancel(queued.ID), "finished transfers cannot be canceled")
+		assert.ErrorIs(t, q.Cancel("nope"), ErrTransferNotFound)
+
+		// The canceled copy stops and its slot stays free.
+		require.Eventually(t, func() bool {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			return q.running == 0
+		}, 5*time.Second, 10*time.Millisecond)
+		tr, err = q.Get(running.ID)
+		require.NoError(t, err)
+		assert.Equal(t, TransferCanceled, tr.State)
+		assert.Empty(t, runner.started)
+	})
+
+	t.Run("rejects unknown hosts", func(t *testing.T) {
+		e, _ := setupExecutor(t)
+		q, err := NewTransferQueue(e, t.TempDir())
+		require.NoError(t, err)
+		_, err = q.Enqueue(HostCopyOptions{SourceHost: "web01", SourcePath: "/a", DestHost: "nope", DestPath: "/b"})
+		assert.Error(t, err)
+		assert.Empty(t, q.List())
+	})
+
+	// runJob queues a job on a local host "build" and waits until it finished.
+	runJob := func(t *testing.T, job TransferJob) Transfer {
+		e, _ := setupExecutor(t)
+		require.NoError(t, e.manager.AddHost(inventory.NewLocalHost("build", "build")))
+		q, err := NewTransferQueue(e, t.TempDir())
+		require.NoError(t, err)
+		queued, err := q.Enqueue(job)
+		require.NoError(t, err)
+		runQueue(t, q)
+		require.Eventually(t, func() bool {
+			tr, err := q.Get(queued.ID)
+			return err == nil && tr.State.Finished()
+		}, 5*time.Second, 10*time.Millisecond)
+
+		// The job survives a restart.
+		reloaded, err := NewTransferQueue(e, filepath.Dir(q.dir))
+		require.NoError(t, err)
+		tr, err := reloaded.Get(queued.ID)
+		require.NoError(t, err)
+		reloadedJob, err := tr.Job()
+		require.NoError(t, err)
+		assert.Equal(t, job, reloadedJob)
+		return *tr
+	}
+
+	t.Run("uploads files", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "app.tar"), []byte("payload"), 0o644))
+		tr := runJob(t, UploadOptions{HostID: "build", Source: filepath.Join(dir, "app.tar"), Destination: filepath.Join(dir, "app.tar.new")})
+		require.Equal(t, TransferDone, tr.State, tr.Error)
+		assert.Equal(t, TransferUpload, tr.Kind)
+		assert.Equal(t, int64(len("payload")), tr.Result.Bytes)
+
+		uploaded, err := os.ReadFile(filepath.Join(dir, "app.tar.new"))
+		require.NoError(t, err)
+		assert.Equal(t, "payload", string(uploaded))
+	})
+
+	t.Run("downloads files", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "app.log"), []byte("log line"), 0o644))
+		tr := runJob(t, DownloadOptions{HostID: "build", Source: filepath.Join(dir, "app.log"), Destination: filepath.Join(dir, "logs", "app.log")})
+		require.Equal(t, TransferDone, tr.State, tr.Error)
+		assert.Equal(t, TransferDownload, tr.Kind)
+		assert.Equal(t, int64(len("log line")), tr.Result.Bytes)
+
+		downloaded, err := os.ReadFile(filepath.Join(dir, "logs", "app.log"))
+		require.NoError(t, err)
+		assert.Equal(t, "log line", string(downloaded))
+
+		tr = runJob(t, DownloadOptions{HostID: "build", Source: filepath.Join(dir, "missing"), Destination: filepath.Join(dir, "logs", "app.log")})
+		assert.Equal(t, TransferFailed, tr.State)
+		downloaded, err = os.ReadFile(filepath.Join(dir, "logs", "app.log"))
+		require.NoError(t, err)
+		assert.Equal(t, "log line", string(downloaded), "failed downloads keep the local file")
+		entries, err := os.ReadDir(filepath.Join(dir, "logs"))
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "no temporary file is left behind")
+	})
+
+	t.Run("syncs directories", func(t *testing.T) {
+		src, dest := t.TempDir(), t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(src, "index.html"), []byte("<h1>hi</h1>"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dest, "stale.html"), []byte("old"), 0o644))
+		tr := runJob(t, SyncOptions{HostIDs: []string{"build"}, Source: src, Destination: dest, Delete: true, Method: SyncUpload})
+		require.Equal(t, TransferDone, tr.State, tr.Error)
+		assert.Equal(t, TransferSync, tr.Kind)
+		assert.Equal(t, 1, tr.Result.Files)
+		assert.Equal(t, 1, tr.Result.Deleted)
+
+		synced, err := os.ReadFile(filepath.Join(dest, "index.html"))
+		require.NoError(t, err)
+		assert.Equal(t, "<h1>hi</h1>", string(synced))
+		assert.NoFileExists(t, filepath.Join(dest, "stale.html"))
+	})
+
+	t.Run("validates jobs", func(t *testing.T) {
+		e, _ := setupExecutor(t)
+		q, err := NewTransferQueue(e, t.TempDir())
+		require.NoError(t, err)
+		_, err = q.Enqueue(UploadOptions{HostID: "web01", Source: filepath.Join(t.TempDir(), "missing"), Destination: "/srv/a"})
+		assert.ErrorContains(t, err, "failed to read source")
+		_, err = q.Enqueue(DownloadOptions{HostID: "nope", Source: "/a", Destination: "a"})
+		assert.ErrorContains(t, err, "host nope not found")
+		_, err = q.Enqueue(SyncOptions{HostIDs: []string{"web01"}, Source: t.TempDir()})
+		assert.ErrorContains(t, err, "source and destination are required")
+		assert.Empty(t, q.List())
+
+		_, err = (&Transfer{Kind: TransferUpload}).Job()
+		assert.ErrorContains(t, err, "without upload options")
+		_, err = (&Transfer{Kind: "fax"}).Job()
+		assert.ErrorContains(t, err, "invalid transfer kind")
+	})
+
+	t.Run("serves the daemon API", func(t *testing.T) {
+		e, _ := setupExecutor(t)
+		q, err := NewTransferQueue(e, t.TempDir())
+		require.NoError(t, err)
+		srv := httptest.NewServer(q)
+		defer srv.Close()
+
+		resp, err := http.Post(srv.URL+"/v1/transfers", "application/json",
+			strings.NewReader(`{"source_host":"web01","source_path":"/a","dest_host":"web02","dest_path":"/b","via":"relay"}`))
+		require.NoError(t, err)
+		var created Transfer
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+		resp.Body.Close()
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		assert.Equal(t, HostCopyRelay, created.Via)
+
+		resp, err = http.Get(srv.URL + "/v1/transfers")
+		require.NoError(t, err)
+		var list []Transfer
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+		resp.Body.Close()
+		require.Len(t, list, 1)
+		assert.Equal(t, created.ID, list[0].ID)
+
+		req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/v1/transfers/"+created.ID, nil)
+		resp, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		resp, err = http.Get(srv.URL + "/v1/transfers/" + created.ID)
+		require.NoError(t, err)
+		var got Transfer
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+		resp.Body.Close()
+		assert.Equal(t, TransferCanceled, got.State)
+
+		resp, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+		resp, err = http.Get(srv.URL + "/v1/transfers/nope")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, err = http.Post(srv.URL+"/v1/transfers", "application/json", strings.NewReader(`{"source_host":"web01"}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, err = http.Post(srv.URL+"/v1/transfers", "application/json",
+			strings.NewReader(`{"kind":"download","download":{"host":"web01","source":"/var/log/app.log","destination":"app.log"}}`))
+		require.NoError(t, err)
+		created = Transfer{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+		resp.Body.Close()
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		assert.Equal(t, TransferDownload, created.Kind)
+		assert.Equal(t, &DownloadOptions{HostID: "web01", Source: "/var/log/app.log", Destination: "app.log"}, created.Download)
+
+		resp, err = http.Post(srv.URL+"/v1/transfers", "application/json", strings.NewReader(`{"kind":"upload"}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
@@ -0,0 +1,322 @@
+package executor
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/redact"
+)
+
+const (
+	// DefaultTrashDir is the remote trash directory of hosts without their own,
+	// below the home directory of the connecting user.
+	DefaultTrashDir = ".gossher-trash"
+	// DefaultTrashRetention is how long trashed files are kept before a purge
+	// removes them.
+	DefaultTrashRetention = 7 * 24 * time.Hour
+)
+
+// TrashOptions selects the hosts of trash operations and the purge policy.
+type TrashOptions struct {
+	// HostIDs, Groups and Target select the hosts as in ExecOptions.
+	HostIDs []string
+	Groups  []string
+	Target  string
+
+	// Retention is how long trash entries are kept (default
+	// DefaultTrashRetention). Trash purges older entries after moving files.
+	Retention time.Duration
+	// Sudo runs the trash commands with "sudo -n", for files of other users; a
+	// relative trash directory is then below the HOME set by sudo, usually root's.
+	Sudo bool
+	// Concurrency limits parallel hosts (default DefaultConcurrency).
+	Concurrency int
+}
+
+// TrashEntry is one trash operation on a host: the files it moved, kept together
+// until restored or purged.
+type TrashEntry struct {
+	HostID string `json:"host_id"`
+	ID     string `json:"id"`
+	// Deleted is when the files were trashed.
+	Deleted time.Time `json:"deleted"`
+	Paths   []string  `json:"paths"`
+	// Size is the disk usage of the entry in bytes.
+	Size int64 `json:"size"`
+}
+
+// TrashResult is the outcome of a trash operation on one host.
+type TrashResult struct {
+	HostID string
+	// Entry holds the trashed files; nil when none of the paths existed.
+	Entry *TrashEntry
+	// Missing are the paths that did not exist on the host.
+	Missing []string
+	// Purged counts the entries removed by the retention policy.
+	Purged   int
+	Err      error
+	Duration time.Duration
+}
+
+// Trash deletes files and directories on the selected hosts by moving them into
+// the hosts' trash directories (see inventory.Host.TrashDir), from where
+// RestoreTrash puts them back, then purges the entries older than the retention.
+// Paths must be absolute. The commands go through the command policy.
+func (e *Executor) Trash(ctx context.Context, paths []string, opts TrashOptions) ([]TrashResult, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no path to delete")
+	}
+	cleaned := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if !strings.HasPrefix(p, "/") || strings.ContainsAny(p, "\n\r") {
+			return nil, fmt.Errorf("invalid path %q: paths must be absolute", p)
+		}
+		p = path.Clean(p)
+		if p == "/" {
+			return nil, fmt.Errorf("refusing to delete /")
+		}
+		cleaned = append(cleaned, p)
+	}
+
+	return e.eachTrashHost(ctx, opts, func(ctx context.Context, conn *inventory.ResolvedConnection, trash string, r *TrashResult) error {
+		id, err := newID()
+		if err != nil {
+			return fmt.Errorf("failed to generate trash ID: %w", err)
+		}
+		entry := trash + "/" + id
+		var script strings.Builder
+		script.WriteString("set -e; umask 077; mkdir -p -- " + entry + "/files\n")
+		for _, p := range cleaned {
+			quoted := inventory.ShellQuote(p)
+			fmt.Fprintf(&script, "if [ -e %[1]s ] || [ -L %[1]s ]; then mkdir -p -- %[2]s/files%[3]s && mv -- %[1]s %[2]s/files%[1]s && printf '%%s\\n' %[1]s >> %[2]s/manifest; else printf '#missing\\t%%s\\n' %[1]s; fi\n",
+				quoted, entry, inventory.ShellQuote(path.Dir(p)))
+		}
+		fmt.Fprintf(&script, "[ -s %[1]s/manifest ] || rm -rf -- %[1]s", entry)
+
+		out, err := e.trashStep(ctx, conn, script.String(), opts)
+		if err != nil {
+			return fmt.Errorf("failed to trash files: %w", err)
+		}
+		var moved []string
+		missing := map[string]bool{}
+		for _, line := range strings.Split(out, "\n") {
+			if p, ok := strings.CutPrefix(line, "#missing\t"); ok {
+				r.Missing = append(r.Missing, p)
+				missing[p] = true
+			}
+		}
+		for _, p := range cleaned {
+			if !missing[p] {
+				moved = append(moved, p)
+			}
+		}
+		if len(moved) > 0 {
+			deleted, _ := trashTime(id)
+			r.Entry = &TrashEntry{HostID: conn.HostID, ID: id, Deleted: deleted, Paths: moved}
+		}
+
+		r.Purged, err = e.purgeTrash(ctx, conn, trash, opts)
+		return err
+	})
+}
+
+// PurgeTrash removes the trash entries older than the retention on the selected
+// hosts, reporting the number removed per host in TrashResult.Purged.
+func (e *Executor) PurgeTrash(ctx context.Context, opts TrashOptions) ([]TrashResult, error) {
+	return e.eachTrashHost(ctx, opts, func(ctx context.Context, conn *inventory.ResolvedConnection, trash string, r *TrashResult) error {
+		var err error
+		r.Purged, err = e.purgeTrash(ctx, conn, trash, opts)
+		return err
+	})
+}
+
+// ListTrash returns the trash entries of the selected hosts, oldest first per
+// host. The error joins the failures of hosts whose trash could not be read.
+func (e *Executor) ListTrash(ctx context.Context, opts TrashOptions) ([]TrashEntry, error) {
+	var mu sync.Mutex
+	var entries []TrashEntry
+	results, err := e.eachTrashHost(ctx, opts, func(ctx context.Context, conn *inventory.ResolvedConnection, trash string, r *TrashResult) error {
+		script := "[ -d " + trash + " ] || exit 0; for d in " + trash + "/*/; do [ -f \"$d/manifest\" ] || continue; " +
+			"printf '#entry\\t%s\\t%s\\n' \"$(basename \"$d\")\" \"$(du -sk \"$d\" | cut -f1)\"; cat \"$d/manifest\"; done"
+		out, err := e.trashStep(ctx, conn, script, opts)
+		if err != nil {
+			return fmt.Errorf("failed to list trash: %w", err)
+		}
+		found := parseTrashEntries(out, conn.HostID)
+		mu.Lock()
+		entries = append(entries, found...)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].HostID != entries[j].HostID {
+			return entries[i].HostID < entries[j].HostID
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	errs := make([]error, 0, len(results))
+	for _, r := range results {
+		errs = append(errs, r.Err)
+	}
+	return entries, errors.Join(errs...)
+}
+
+// RestoreTrash moves the files of a trash entry back to where they were deleted
+// from and removes the entry. Nothing is restored when one of the paths exists
+// again.
+func (e *Executor) RestoreTrash(ctx context.Context, hostID, id string, opts TrashOptions) error {
+	if _, ok := trashTime(id); !ok {
+		return fmt.Errorf("invalid trash entry ID %q", id)
+	}
+	opts.HostIDs, opts.Groups, opts.Target = []string{hostID}, nil, ""
+	results, err := e.eachTrashHost(ctx, opts, func(ctx context.Context, conn *inventory.ResolvedConnection, trash string, r *TrashResult) error {
+		entry := trash + "/" + id
+		script := "set -e; e=" + entry + "\n" +
+			"[ -f \"$e/manifest\" ] || { echo \"no trash entry " + id + "\" >&2; exit 1; }\n" +
+			"while IFS= read -r p; do if [ -e \"$p\" ] || [ -L \"$p\" ]; then echo \"$p exists\" >&2; exit 1; fi; done < \"$e/manifest\"\n" +
+			"while IFS= read -r p; do mkdir -p -- \"$(dirname -- \"$p\")\"; mv -- \"$e/files$p\" \"$p\"; done < \"$e/manifest\"\n" +
+			"rm -rf -- \"$e\""
+		if _, err := e.trashStep(ctx, conn, script, opts); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", id, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return results[0].Err
+}
+
+// eachTrashHost resolves the selected hosts and runs fn for each with its
+// connection and quoted trash directory.
+func (e *Executor) eachTrashHost(ctx context.Context, opts TrashOptions, fn func(context.Context, *inventory.ResolvedConnection, string, *TrashResult) error) ([]TrashResult, error) {
+	targets, err := e.ResolveTargets(ExecOptions{HostIDs: opts.HostIDs, Groups: opts.Groups, Target: opts.Target})
+	if err != nil {
+		return nil, err
+	}
+
+	return forEachHost(ctx, targets, e.concurrency(opts.Concurrency), func(ctx context.Context, hostID string) (r TrashResult) {
+		started := time.Now()
+		r.HostID = hostID
+		defer func() { r.Duration = time.Since(started) }()
+
+		conn, err := e.manager.ResolveConnection(hostID)
+		if err == nil {
+			err = conn.ResolveSecrets()
+		}
+		if err != nil {
+			r.Err = redact.Error(err)
+			return r
+		}
+		redact.Default().AddConnection(conn)
+		e.applyTimeouts(conn, ExecOptions{})
+		if err := fn(ctx, conn, e.trashDir(hostID), &r); err != nil {
+			r.Err = fmt.Errorf("host %s: %w", hostID, err)
+		}
+		return r
+	}, func(hostID string, err error) TrashResult {
+		return TrashResult{HostID: hostID, Err: err}
+	}), nil
+}
+
+// trashDir returns the trash directory of a host as a shell word.
+func (e *Executor) trashDir(hostID string) string {
+	dir := DefaultTrashDir
+	if h, ok := e.manager.GetHost(hostID); ok && h.TrashDir != "" {
+		dir = h.TrashDir
+	}
+	dir = strings.TrimSuffix(dir, "/")
+	if strings.HasPrefix(dir, "/") {
+		return inventory.ShellQuote(dir)
+	}
+	return `"$HOME"/` + inventory.ShellQuote(strings.TrimPrefix(dir, "~/"))
+}
+
+// trashStep runs a trash script on a host after checking it against the command
+// policy, with sudo when asked to.
+func (e *Executor) trashStep(ctx context.Context, conn *inventory.ResolvedConnection, script string, opts TrashOptions) (string, error) {
+	command := script
+	if opts.Sudo {
+		command = "sudo -n sh -c " + inventory.ShellQuote(script)
+	}
+	if err := e.checkPolicy([]string{conn.HostID}, ExecOptions{Command: command}); err != nil {
+		return "", err
+	}
+	return e.step(ctx, conn, command, 0)
+}
+
+// purgeTrash removes the entries of a trash directory older than the retention.
+func (e *Executor) purgeTrash(ctx context.Context, conn *inventory.ResolvedConnection, trash string, opts TrashOptions) (int, error) {
+	out, err := e.trashStep(ctx, conn, "[ -d "+trash+" ] || exit 0; ls -1 -- "+trash, opts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list trash: %w", err)
+	}
+	cutoff := time.Now().Add(-cmp.Or(opts.Retention, DefaultTrashRetention))
+	var old []string
+	for _, id := range strings.Fields(out) {
+		if t, ok := trashTime(id); ok && t.Before(cutoff) {
+			old = append(old, trash+"/"+id)
+		}
+	}
+	if len(old) == 0 {
+		return 0, nil
+	}
+	if _, err := e.trashStep(ctx, conn, "rm -rf -- "+strings.Join(old, " "), opts); err != nil {
+		return 0, fmt.Errorf("failed to purge trash: %w", err)
+	}
+	return len(old), nil
+}
+
+// trashTime returns when a trash entry was created from its ID, as made by newID.
+func trashTime(id string) (time.Time, bool) {
+	stamp, suffix, ok := strings.Cut(id, "-")
+	if !ok {
+		return time.Time{}, false
+	}
+	clock, random, ok := strings.Cut(suffix, "-")
+	if !ok || random == "" || strings.ContainsAny(random, `/\. `) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("20060102150405", stamp+clock)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// parseTrashEntries parses the "#entry" listing of a trash directory.
+func parseTrashEntries(out, hostID string) []TrashEntry {
+	var entries []TrashEntry
+	var entry *TrashEntry
+	for _, line := range strings.Split(out, "\n") {
+		if rest, ok := strings.CutPrefix(line, "#entry\t"); ok {
+			id, size, _ := strings.Cut(rest, "\t")
+			deleted, ok := trashTime(id)
+			if !ok {
+				entry = nil
+				continue
+			}
+			kb, _ := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+			entries = append(entries, TrashEntry{HostID: hostID, ID: id, Deleted: deleted, Size: kb * 1024, Paths: []string{}})
+			entry = &entries[len(entries)-1]
+			continue
+		}
+		if entry != nil && line != "" {
+			entry.Paths = append(entry.Paths, line)
+		}
+	}
+	return entries
+}
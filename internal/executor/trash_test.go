@@ -0,0 +1,171 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrashTime(t *testing.T) {
+	deleted, ok := trashTime("20261016-093000-a1b2c3")
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC), deleted)
+
+	for _, id := range []string{"", "manifest", "20261016-093000", "20261016-093000-../x", "2026-10-16-x"} {
+		_, ok := trashTime(id)
+		assert.False(t, ok, id)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (*Executor, string, string) {
+		e, _ := setupExecutor(t)
+		trash, dir := t.TempDir(), t.TempDir()
+		h := inventory.NewLocalHost("build", "build")
+		h.TrashDir = trash
+		require.NoError(t, e.manager.AddHost(h))
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "cache", "tmp"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "cache", "tmp", "blob"), []byte("blob"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "app.log"), []byte("log"), 0o644))
+		return e, trash, dir
+	}
+	build := TrashOptions{HostIDs: []string{"build"}}
+
+	t.Run("moves files to the trash and restores them", func(t *testing.T) {
+		e, trash, dir := setup(t)
+		paths := []string{filepath.Join(dir, "cache"), filepath.Join(dir, "app.log"), filepath.Join(dir, "missing")}
+
+		results, err := e.Trash(ctx, paths, build)
+		require.NoError(t, err)
+		r := results[0]
+		require.NoError(t, r.Err)
+		require.NotNil(t, r.Entry)
+		assert.Equal(t, paths[:2], r.Entry.Paths)
+		assert.Equal(t, paths[2:], r.Missing)
+		assert.Zero(t, r.Purged)
+		assert.NoDirExists(t, filepath.Join(dir, "cache"))
+		assert.NoFileExists(t, filepath.Join(dir, "app.log"))
+		assert.FileExists(t, filepath.Join(trash, r.Entry.ID, "files", dir, "cache", "tmp", "blob"))
+
+		entries, err := e.ListTrash(ctx, build)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, r.Entry.ID, entries[0].ID)
+		assert.Equal(t, paths[:2], entries[0].Paths)
+		assert.Positive(t, entries[0].Size)
+
+		require.NoError(t, e.RestoreTrash(ctx, "build", r.Entry.ID, build))
+		data, err := os.ReadFile(filepath.Join(dir, "cache", "tmp", "blob"))
+		require.NoError(t, err)
+		assert.Equal(t, "blob", string(data))
+		assert.FileExists(t, filepath.Join(dir, "app.log"))
+		entries, err = e.ListTrash(ctx, build)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("moves paths with glob characters literally", func(t *testing.T) {
+		e, trash, dir := setup(t)
+		target := filepath.Join(dir, "app[1].log")
+		require.NoError(t, os.WriteFile(target, []byte("target"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "app1.log"), []byte("glob match"), 0o644))
+
+		results, err := e.Trash(ctx, []string{target}, build)
+		require.NoError(t, err)
+		r := results[0]
+		require.NoError(t, r.Err)
+		require.NotNil(t, r.Entry)
+		assert.NoFileExists(t, target)
+		assert.FileExists(t, filepath.Join(dir, "app1.log"), "the glob match is left alone")
+		assert.FileExists(t, filepath.Join(trash, r.Entry.ID, "files", target))
+
+		require.NoError(t, e.RestoreTrash(ctx, "build", r.Entry.ID, build))
+		data, err := os.ReadFile(target)
+		require.NoError(t, err)
+		assert.Equal(t, "target", string(data))
+	})
+
+	t.Run("does not restore over files created since", func(t *testing.T) {
+		e, _, dir := setup(t)
+		log := filepath.Join(dir, "app.log")
+		results, err := e.Trash(ctx, []string{log}, build)
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+		require.NoError(t, os.WriteFile(log, []byte("new"), 0o644))
+
+		err = e.RestoreTrash(ctx, "build", results[0].Entry.ID, build)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exists")
+		data, err := os.ReadFile(log)
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(data))
+
+		assert.Error(t, e.RestoreTrash(ctx, "build", "20200101-000000-ffffff", build))
+		assert.Error(t, e.RestoreTrash(ctx, "build", "../etc", build))
+	})
+
+	t.Run("purges entries older than the retention", func(t *testing.T) {
+		e, trash, dir := setup(t)
+		results, err := e.Trash(ctx, []string{filepath.Join(dir, "app.log")}, build)
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+		require.NoError(t, os.MkdirAll(filepath.Join(trash, "20200101-000000-abcdef", "files"), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(trash, "20200101-000000-abcdef", "manifest"), []byte("/old\n"), 0o600))
+		require.NoError(t, os.MkdirAll(filepath.Join(trash, "keep-me"), 0o700))
+
+		results, err = e.PurgeTrash(ctx, build)
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+		assert.Equal(t, 1, results[0].Purged)
+		assert.NoDirExists(t, filepath.Join(trash, "20200101-000000-abcdef"))
+		assert.DirExists(t, filepath.Join(trash, "keep-me"), "only trash entries are purged")
+
+		results, err = e.PurgeTrash(ctx, TrashOptions{HostIDs: []string{"build"}, Retention: time.Nanosecond})
+		require.NoError(t, err)
+		assert.Equal(t, 1, results[0].Purged)
+	})
+
+	t.Run("uses the default trash directory in the home directory", func(t *testing.T) {
+		base, inner := setupExecutor(t)
+		runner := &scpRunner{uploadingRunner: &uploadingRunner{fakeRunner: inner, uploads: map[string]string{}}}
+		e := New(base.manager, runner)
+		results, err := e.Trash(ctx, []string{"/var/log/old.log"}, TrashOptions{HostIDs: []string{"web01"}})
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+		require.Len(t, runner.commands, 2, "trash and purge listing")
+		assert.Contains(t, runner.commands[0], `mkdir -p -- "$HOME"/.gossher-trash/`)
+		assert.Contains(t, runner.commands[0], `mv -- /var/log/old.log "$HOME"/.gossher-trash/`)
+		assert.Contains(t, runner.commands[1], `ls -1 -- "$HOME"/.gossher-trash`)
+	})
+
+	t.Run("goes through the command policy", func(t *testing.T) {
+		e, _, dir := setup(t)
+		e.SetCommandPolicy(&inventory.CommandPolicy{Rules: []inventory.CommandRule{{Name: "no-mv", Deny: []string{`\bmv\b`}}}})
+		results, err := e.Trash(ctx, []string{filepath.Join(dir, "app.log")}, build)
+		require.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, ErrCommandBlocked)
+		assert.FileExists(t, filepath.Join(dir, "app.log"))
+	})
+
+	t.Run("validates paths", func(t *testing.T) {
+		e, _, _ := setup(t)
+		for _, paths := range [][]string{nil, {"relative/path"}, {"/"}, {"/srv/../"}, {"/tmp/a\nb"}} {
+			_, err := e.Trash(ctx, paths, build)
+			assert.Error(t, err, strings.Join(paths, ","))
+		}
+	})
+}
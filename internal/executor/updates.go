@@ -0,0 +1,321 @@
+package executor
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gossher/internal/term"
+)
+
+// updatesCommand prints the package manager found on the host followed by its
+// pending updates: "apt list --upgradable" for apt, or "check-update" for dnf and
+// yum followed by a "#security" line and the security updates alone. The package
+// lists are not refreshed, so the result is as recent as the host's metadata.
+const updatesCommand = `if command -v apt >/dev/null 2>&1; then echo apt; apt list --upgradable 2>/dev/null
+elif command -v dnf >/dev/null 2>&1; then echo dnf; { dnf -q check-update; [ $? -ne 1 ]; } && echo '#security' && { dnf -q check-update --security; [ $? -ne 1 ]; }
+elif command -v yum >/dev/null 2>&1; then echo yum; { yum -q check-update; [ $? -ne 1 ]; } && echo '#security' && { yum -q check-update --security; [ $? -ne 1 ]; }
+else echo 'no supported package manager (apt, dnf, yum)' >&2; exit 3; fi`
+
+// PendingUpdate is a package with a newer version available.
+type PendingUpdate struct {
+	Name string `json:"name"`
+	Arch string `json:"arch,omitempty"`
+	// Installed is the installed version, from apt or else from the package
+	// documents of the host (see CollectPackages); empty when unknown.
+	Installed  string `json:"installed,omitempty"`
+	Available  string `json:"available"`
+	Repository string `json:"repository,omitempty"`
+	// Security is set for updates from a security repository or advisory.
+	Security bool `json:"security"`
+}
+
+// HostUpdates are the pending updates of a host.
+type HostUpdates struct {
+	HostID  string          `json:"host_id"`
+	Manager string          `json:"manager,omitempty"`
+	Checked time.Time       `json:"checked"`
+	Updates []PendingUpdate `json:"updates"`
+	// Error is set when the updates could not be listed.
+	Error string `json:"error,omitempty"`
+}
+
+// SecurityCount returns the number of pending security updates.
+func (h *HostUpdates) SecurityCount() int {
+	n := 0
+	for _, u := range h.Updates {
+		if u.Security {
+			n++
+		}
+	}
+	return n
+}
+
+// GroupUpdates aggregates the pending updates of the audited hosts of a group.
+type GroupUpdates struct {
+	Group string `json:"group"`
+	// Hosts is the number of audited hosts of the group, Failed those whose
+	// updates could not be listed and Vulnerable those with security updates.
+	Hosts      int `json:"hosts"`
+	Failed     int `json:"failed"`
+	Vulnerable int `json:"vulnerable"`
+	Updates    int `json:"updates"`
+	Security   int `json:"security"`
+}
+
+// UpdateReport is the pending updates of hosts, for compliance reviews.
+type UpdateReport struct {
+	Generated time.Time      `json:"generated"`
+	Hosts     []HostUpdates  `json:"hosts"`
+	Groups    []GroupUpdates `json:"groups"`
+}
+
+// AuditUpdates lists the pending updates of the selected hosts with apt, dnf or
+// yum and aggregates them per inventory group. Installed versions missing from
+// dnf and yum output are looked up in packages unless it is nil.
+func (e *Executor) AuditUpdates(ctx context.Context, opts ExecOptions, packages *PackageStore) (*UpdateReport, error) {
+	opts.Command = updatesCommand
+	results, err := e.Exec(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &UpdateReport{Generated: time.Now().UTC()}
+	for _, r := range results {
+		h := HostUpdates{HostID: r.HostID, Checked: r.Started.UTC(), Updates: []PendingUpdate{}}
+		switch {
+		case r.Err != nil:
+			h.Error = r.Err.Error()
+		case r.ExitCode != 0:
+			h.Error = fmt.Sprintf("listing updates exited with status %d: %s", r.ExitCode, strings.TrimSpace(r.Stderr))
+		default:
+			manager, updates, err := parseUpdates(r.Stdout)
+			if err != nil {
+				h.Error = err.Error()
+				break
+			}
+			h.Manager, h.Updates = manager, updates
+			if packages != nil {
+				fillInstalled(h.Updates, packages, r.HostID)
+			}
+		}
+		report.Hosts = append(report.Hosts, h)
+	}
+	sort.Slice(report.Hosts, func(i, j int) bool { return report.Hosts[i].HostID < report.Hosts[j].HostID })
+	report.Groups = e.groupUpdates(report.Hosts)
+	return report, nil
+}
+
+// fillInstalled sets the installed versions of updates from the package document
+// of a host.
+func fillInstalled(updates []PendingUpdate, packages *PackageStore, hostID string) {
+	doc, err := packages.Get(hostID)
+	if err != nil {
+		return
+	}
+	for i, u := range updates {
+		if u.Installed != "" {
+			continue
+		}
+		for _, p := range doc.Find(u.Name) {
+			if u.Arch == "" || p.Arch == "" || p.Arch == u.Arch {
+				updates[i].Installed = p.Version
+				break
+			}
+		}
+	}
+}
+
+// groupUpdates aggregates host updates over the inventory groups containing at
+// least one of the hosts.
+func (e *Executor) groupUpdates(hosts []HostUpdates) []GroupUpdates {
+	byHost := make(map[string]*HostUpdates, len(hosts))
+	for i := range hosts {
+		byHost[hosts[i].HostID] = &hosts[i]
+	}
+
+	var groups []GroupUpdates
+	for _, g := range e.manager.ListGroups() {
+		members, err := e.manager.ResolveGroupHosts(g.Name)
+		if err != nil {
+			continue
+		}
+		agg := GroupUpdates{Group: g.Name}
+		for _, id := range members {
+			h, ok := byHost[id]
+			if !ok {
+				continue
+			}
+			agg.Hosts++
+			if h.Error != "" {
+				agg.Failed++
+				continue
+			}
+			security := h.SecurityCount()
+			agg.Updates += len(h.Updates)
+			agg.Security += security
+			if security > 0 {
+				agg.Vulnerable++
+			}
+		}
+		if agg.Hosts > 0 {
+			groups = append(groups, agg)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Group < groups[j].Group })
+	return groups
+}
+
+// parseUpdates parses the output of updatesCommand.
+func parseUpdates(out string) (string, []PendingUpdate, error) {
+	manager, list, _ := strings.Cut(out, "\n")
+	manager = strings.TrimSpace(manager)
+	switch manager {
+	case "apt":
+		return manager, parseAptUpgradable(list), nil
+	case "dnf", "yum":
+		all, security, _ := strings.Cut(list, "#security\n")
+		updates := parseCheckUpdate(all)
+		secure := make(map[string]bool)
+		for _, u := range parseCheckUpdate(security) {
+			secure[u.Name+"."+u.Arch+"-"+u.Available] = true
+		}
+		for i, u := range updates {
+			updates[i].Security = secure[u.Name+"."+u.Arch+"-"+u.Available]
+		}
+		return manager, updates, nil
+	}
+	return "", nil, fmt.Errorf("unsupported package manager %q", manager)
+}
+
+// parseAptUpgradable parses "apt list --upgradable" lines like
+// "openssl/jammy-updates,jammy-security 3.0.2-0ubuntu1.12 amd64 [upgradable from: 3.0.2-0ubuntu1.10]".
+func parseAptUpgradable(out string) []PendingUpdate {
+	updates := []PendingUpdate{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || !strings.Contains(fields[0], "/") {
+			continue
+		}
+		name, repos, _ := strings.Cut(fields[0], "/")
+		u := PendingUpdate{Name: name, Available: fields[1], Arch: fields[2], Repository: repos}
+		if _, from, ok := strings.Cut(line, "upgradable from: "); ok {
+			u.Installed = strings.TrimSuffix(strings.TrimSpace(from), "]")
+		}
+		for _, repo := range strings.Split(repos, ",") {
+			if strings.HasSuffix(repo, "-security") {
+				u.Security = true
+			}
+		}
+		updates = append(updates, u)
+	}
+	return updates
+}
+
+// parseCheckUpdate parses "dnf/yum check-update" lines like
+// "openssl.x86_64    1:3.0.7-25.el9    baseos". Long package names wrap the rest
+// of their line to the next one; the obsoleted packages section is ignored.
+func parseCheckUpdate(out string) []PendingUpdate {
+	updates := []PendingUpdate{}
+	var pending []string
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "Obsoleting Packages") {
+			break
+		}
+		fields := append(pending, strings.Fields(line)...)
+		pending = nil
+		if len(fields) == 1 && strings.Contains(fields[0], ".") {
+			pending = fields
+			continue
+		}
+		if len(fields) != 3 {
+			continue
+		}
+		dot := strings.LastIndexByte(fields[0], '.')
+		if dot <= 0 {
+			continue
+		}
+		updates = append(updates, PendingUpdate{
+			Name:       fields[0][:dot],
+			Arch:       fields[0][dot+1:],
+			Available:  fields[1],
+			Repository: fields[2],
+		})
+	}
+	return updates
+}
+
+// ===== Export =====
+
+// JSON encodes the report for machine consumption.
+func (r *UpdateReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// WriteCSV writes one row per pending update, plus one row without a package for
+// hosts without updates or whose updates could not be listed, so that every
+// audited host appears in the export.
+func (r *UpdateReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"host", "manager", "checked", "package", "arch", "installed", "available", "repository", "security", "error"})
+	for _, h := range r.Hosts {
+		checked := h.Checked.Format(time.RFC3339)
+		if len(h.Updates) == 0 {
+			cw.Write([]string{h.HostID, h.Manager, checked, "", "", "", "", "", "", h.Error})
+			continue
+		}
+		for _, u := range h.Updates {
+			cw.Write([]string{h.HostID, h.Manager, checked, u.Name, u.Arch, u.Installed, u.Available, u.Repository, strconv.FormatBool(u.Security), ""})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteText prints the pending updates per host and per group.
+func (r *UpdateReport) WriteText(w io.Writer) error {
+	tw := term.NewTable(w)
+	fmt.Fprintln(tw, "HOST\tMANAGER\tUPDATES\tSECURITY")
+	for _, h := range r.Hosts {
+		if h.Error != "" {
+			fmt.Fprintf(tw, "%s\t%s\t-\t-\n", h.HostID, h.Manager)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\n", h.HostID, h.Manager, len(h.Updates), h.SecurityCount())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.Groups) > 0 {
+		fmt.Fprintln(w)
+		tw = term.NewTable(w)
+		fmt.Fprintln(tw, "GROUP\tHOSTS\tVULNERABLE\tFAILED\tUPDATES\tSECURITY")
+		for _, g := range r.Groups {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\n", g.Group, g.Hosts, g.Vulnerable, g.Failed, g.Updates, g.Security)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	var failures []string
+	for _, h := range r.Hosts {
+		if h.Error != "" {
+			failures = append(failures, h.HostID+": "+h.Error)
+		}
+	}
+	if len(failures) > 0 {
+		fmt.Fprintln(w)
+		for _, f := range failures {
+			fmt.Fprintln(w, f)
+		}
+	}
+	return nil
+}
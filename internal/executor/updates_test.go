@@ -0,0 +1,110 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const aptUpgradable = `apt
+Listing...
+openssl/jammy-updates,jammy-security 3.0.2-0ubuntu1.12 amd64 [upgradable from: 3.0.2-0ubuntu1.10]
+tzdata/jammy-updates 2024a-0ubuntu0.22.04 all [upgradable from: 2023c-0ubuntu0.22.04.2]
+`
+
+const dnfCheckUpdate = `dnf
+
+openssl.x86_64                     1:3.0.7-25.el9_3              baseos
+python3-a-very-long-package-name-for-wrapping.noarch
+                                   2.1-3.el9                     appstream
+tzdata.noarch                      2024a-1.el9                   baseos
+Obsoleting Packages
+grub2-tools.x86_64                 1:2.06-70.el9                 baseos
+#security
+
+openssl.x86_64                     1:3.0.7-25.el9_3              baseos
+`
+
+func TestParseUpdates(t *testing.T) {
+	t.Run("apt", func(t *testing.T) {
+		manager, updates, err := parseUpdates(aptUpgradable)
+		require.NoError(t, err)
+		assert.Equal(t, "apt", manager)
+		assert.Equal(t, []PendingUpdate{
+			{Name: "openssl", Arch: "amd64", Installed: "3.0.2-0ubuntu1.10", Available: "3.0.2-0ubuntu1.12", Repository: "jammy-updates,jammy-security", Security: true},
+			{Name: "tzdata", Arch: "all", Installed: "2023c-0ubuntu0.22.04.2", Available: "2024a-0ubuntu0.22.04", Repository: "jammy-updates"},
+		}, updates)
+	})
+
+	t.Run("dnf", func(t *testing.T) {
+		manager, updates, err := parseUpdates(dnfCheckUpdate)
+		require.NoError(t, err)
+		assert.Equal(t, "dnf", manager)
+		assert.Equal(t, []PendingUpdate{
+			{Name: "openssl", Arch: "x86_64", Available: "1:3.0.7-25.el9_3", Repository: "baseos", Security: true},
+			{Name: "python3-a-very-long-package-name-for-wrapping", Arch: "noarch", Available: "2.1-3.el9", Repository: "appstream"},
+			{Name: "tzdata", Arch: "noarch", Available: "2024a-1.el9", Repository: "baseos"},
+		}, updates)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		_, _, err := parseUpdates("zypper\n")
+		assert.Error(t, err)
+	})
+}
+
+func TestAuditUpdates(t *testing.T) {
+	base, inner := setupExecutor(t)
+	runner := &scriptedRunner{fakeRunner: inner, probes: map[string]map[string]probeReply{
+		"web01": {"if command -v apt": {out: aptUpgradable}},
+		"web02": {"if command -v apt": {out: "apt\nListing...\n"}},
+		"db01":  {"if command -v apt": {out: dnfCheckUpdate}},
+	}}
+	e := New(base.manager, runner)
+
+	store := NewPackageStore(t.TempDir())
+	require.NoError(t, store.Save(&PackageFacts{HostID: "db01", Manager: "rpm", Packages: []Package{
+		{Name: "openssl", Version: "1:3.0.7-24.el9", Arch: "x86_64"},
+	}}))
+
+	report, err := e.AuditUpdates(context.Background(), ExecOptions{Groups: []string{"all"}}, store)
+	require.NoError(t, err)
+	require.Len(t, report.Hosts, 3)
+
+	db01 := report.Hosts[0]
+	assert.Equal(t, "db01", db01.HostID)
+	assert.Equal(t, 1, db01.SecurityCount())
+	assert.Equal(t, "1:3.0.7-24.el9", db01.Updates[0].Installed)
+
+	assert.Equal(t, []GroupUpdates{
+		{Group: "all", Hosts: 3, Vulnerable: 2, Updates: 5, Security: 2},
+		{Group: "web", Hosts: 2, Vulnerable: 1, Updates: 2, Security: 1},
+	}, report.Groups)
+
+	t.Run("exports", func(t *testing.T) {
+		data, err := report.JSON()
+		require.NoError(t, err)
+		var decoded UpdateReport
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Len(t, decoded.Hosts, 3)
+
+		var buf bytes.Buffer
+		require.NoError(t, report.WriteCSV(&buf))
+		rows, err := csv.NewReader(&buf).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 1+3+2+1)
+		assert.Equal(t, "host", rows[0][0])
+		assert.Equal(t, []string{"db01", "dnf"}, rows[1][:2])
+		assert.Equal(t, "web02", rows[6][0])
+		assert.Equal(t, "", rows[6][3], "hosts without updates are listed")
+
+		buf.Reset()
+		require.NoError(t, report.WriteText(&buf))
+		assert.Contains(t, buf.String(), "VULNERABLE")
+	})
+}
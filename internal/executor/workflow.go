@@ -0,0 +1,487 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/progress"
+	"gossher/internal/redact"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WorkflowRunFile is the record of a workflow run inside its history directory.
+const WorkflowRunFile = "workflow.yaml"
+
+// ErrNoUploader is returned by transfer steps when the runner cannot copy files.
+var ErrNoUploader = errors.New("runner cannot transfer files")
+
+// Uploader is implemented by runners that can copy files to hosts.
+type Uploader interface {
+	Upload(ctx context.Context, conn *inventory.ResolvedConnection, src io.Reader, remotePath string, mode os.FileMode) error
+}
+
+// StepStatus is the outcome of a workflow step, or of a whole run.
+type StepStatus string
+
+const (
+	StepOK      StepStatus = "ok"
+	StepFailed  StepStatus = "failed"
+	StepSkipped StepStatus = "skipped"
+)
+
+// WorkflowOptions configures RunWorkflow.
+type WorkflowOptions struct {
+	// Concurrency limits parallel hosts of each step (default DefaultConcurrency).
+	Concurrency int
+
+	// Inputs are the values of the workflow's inputs; Prompt is asked for missing
+	// ones before the first step, and later for inputs of saved commands.
+	Inputs map[string]string
+	Prompt inventory.InputPrompt
+
+	// HistoryDir, when set, keeps the run record in HistoryDir/<run ID>/WorkflowRunFile,
+	// rewritten after every step, and the artifacts of the steps in ArtifactsDir next
+	// to it. Workflows with artifacts need it. Use filepath.Join(dataDir, HistoryDir).
+	HistoryDir string
+
+	// Progress receives the progress of every step; transfer steps count bytes,
+	// the others hosts.
+	Progress progress.Reporter
+}
+
+// WorkflowRun is the structured record of a workflow run.
+type WorkflowRun struct {
+	ID         string `yaml:"id" json:"id"`
+	WorkflowID string `yaml:"workflow" json:"workflow"`
+	// Inputs holds the resolved inputs, except secret ones.
+	Inputs   map[string]string `yaml:"inputs,omitempty" json:"inputs,omitempty"`
+	Status   StepStatus        `yaml:"status" json:"status"`
+	Started  time.Time         `yaml:"started" json:"started"`
+	Finished time.Time         `yaml:"finished,omitempty" json:"finished,omitempty"`
+	Steps    []StepRecord      `yaml:"steps" json:"steps"`
+	// Snapshots is what changed on each host of the run, for workflows with a
+	// snapshot. Until the run finishes they only hold the state before it.
+	Snapshots []SnapshotDiff `yaml:"snapshots,omitempty" json:"snapshots,omitempty"`
+}
+
+// StepRecord is the outcome of one workflow step.
+type StepRecord struct {
+	Name     string             `yaml:"name" json:"name"`
+	Kind     inventory.StepKind `yaml:"kind" json:"kind"`
+	Status   StepStatus         `yaml:"status" json:"status"`
+	Error    string             `yaml:"error,omitempty" json:"error,omitempty"`
+	Started  time.Time          `yaml:"started,omitempty" json:"started,omitempty"`
+	Duration time.Duration      `yaml:"duration" json:"duration"`
+	Hosts    []StepHost         `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+	// Artifacts lists the files fetched after the step, per host and path.
+	Artifacts []Artifact `yaml:"artifacts,omitempty" json:"artifacts,omitempty"`
+
+	OnFailure inventory.StepFailure `yaml:"on_failure" json:"on_failure"`
+}
+
+// StepHost is the outcome of a workflow step on one host.
+type StepHost struct {
+	HostID   string        `yaml:"host" json:"host"`
+	OK       bool          `yaml:"ok" json:"ok"`
+	ExitCode int           `yaml:"exit_code" json:"exit_code"`
+	Error    string        `yaml:"error,omitempty" json:"error,omitempty"`
+	Duration time.Duration `yaml:"duration" json:"duration"`
+	// Snapshot is what changed on the host, for exec steps of saved commands with
+	// a snapshot.
+	Snapshot *SnapshotDiff `yaml:"snapshot,omitempty" json:"snapshot,omitempty"`
+}
+
+// Succeeded reports whether every step succeeded.
+func (r *WorkflowRun) Succeeded() bool {
+	return r.Status == StepOK
+}
+
+// Step returns the record of a step.
+func (r *WorkflowRun) Step(name string) (StepRecord, bool) {
+	for _, s := range r.Steps {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return StepRecord{}, false
+}
+
+// RunWorkflow runs the steps of a workflow in order. A failing step stops the run
+// unless it is marked StepContinue; later steps are then recorded as skipped. The
+// snapshot of the workflow is taken on the hosts of all steps before the first
+// step and after the last one, and compared in WorkflowRun.Snapshots. Step
+// failures are reported in the record, the error is for problems that prevent the
+// run or keep its record from being written.
+func (e *Executor) RunWorkflow(ctx context.Context, workflowID string, opts WorkflowOptions) (*WorkflowRun, error) {
+	w, ok := e.manager.GetWorkflow(workflowID)
+	if !ok {
+		return nil, fmt.Errorf("workflow %s not found", workflowID)
+	}
+
+	if opts.HistoryDir == "" {
+		for _, s := range w.Steps {
+			if len(s.Artifacts) > 0 {
+				return nil, fmt.Errorf("workflow %s: step %s collects artifacts but no history directory is set", w.ID, s.Name)
+			}
+		}
+	}
+
+	inputs, err := inventory.ResolveInputs(w.Inputs, opts.Inputs, opts.Prompt)
+	if err != nil {
+		return nil, fmt.Errorf("workflow %s: %w", w.ID, err)
+	}
+	redact.Add(inventory.SecretInputs(w.Inputs, inputs)...)
+	opts.Inputs = inputs
+
+	id, err := newID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate run ID: %w", err)
+	}
+	run := &WorkflowRun{ID: id, WorkflowID: w.ID, Status: StepOK, Started: time.Now().UTC()}
+	for _, in := range w.Inputs {
+		if !in.Secret {
+			if run.Inputs == nil {
+				run.Inputs = map[string]string{}
+			}
+			run.Inputs[in.Name] = inputs[in.Name]
+		}
+	}
+
+	save := func() error { return nil }
+	dir := ""
+	if opts.HistoryDir != "" {
+		dir = filepath.Join(opts.HistoryDir, id)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create run directory: %w", err)
+		}
+		save = func() error { return writeWorkflowRun(dir, run) }
+	}
+	var snapshotTargets []string
+	if w.Snapshot != nil {
+		snapshotTargets = e.workflowTargets(w)
+		run.Snapshots = e.snapshotHosts(ctx, snapshotTargets, w.Snapshot, e.concurrency(opts.Concurrency))
+	}
+	if err := save(); err != nil {
+		return nil, err
+	}
+
+	aborted := false
+	for i := range w.Steps {
+		s := &w.Steps[i]
+		rec := StepRecord{Name: s.Name, Kind: s.Kind, OnFailure: s.FailureAction(), Status: StepSkipped}
+		if !aborted {
+			rec = e.runStep(ctx, w, s, opts)
+			if len(s.Artifacts) > 0 {
+				rec.Artifacts = e.collectArtifacts(ctx, dir, s, rec.Hosts, e.concurrency(opts.Concurrency))
+			}
+			if rec.Status == StepFailed {
+				run.Status = StepFailed
+				aborted = rec.OnFailure == inventory.StepAbort || ctx.Err() != nil
+			}
+		}
+		run.Steps = append(run.Steps, rec)
+		if err := save(); err != nil {
+			return run, err
+		}
+	}
+
+	if w.Snapshot != nil {
+		after := e.snapshotHosts(ctx, snapshotTargets, w.Snapshot, e.concurrency(opts.Concurrency))
+		run.Snapshots = compareSnapshots(run.Snapshots, after)
+	}
+	run.Finished = time.Now().UTC()
+	return run, save()
+}
+
+// workflowTargets returns the hosts of all steps of a workflow, in the order they
+// first appear. Steps whose target does not resolve are left out; they fail when run.
+func (e *Executor) workflowTargets(w *inventory.Workflow) []string {
+	var targets []string
+	seen := map[string]bool{}
+	for i := range w.Steps {
+		s := &w.Steps[i]
+		if s.Kind == inventory.StepWait && s.Until == "" {
+			continue
+		}
+		ids, err := e.ResolveTargets(ExecOptions{Target: w.StepTarget(s)})
+		if err != nil {
+			continue
+		}
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				targets = append(targets, id)
+			}
+		}
+	}
+	return targets
+}
+
+// runStep runs one step and records its outcome.
+func (e *Executor) runStep(ctx context.Context, w *inventory.Workflow, s *inventory.WorkflowStep, opts WorkflowOptions) (rec StepRecord) {
+	rec = StepRecord{Name: s.Name, Kind: s.Kind, OnFailure: s.FailureAction(), Started: time.Now().UTC()}
+	defer func() {
+		rec.Duration = time.Since(rec.Started)
+		rec.Status = StepOK
+		if rec.Error != "" {
+			rec.Status = StepFailed
+		}
+		for _, h := range rec.Hosts {
+			if !h.OK {
+				rec.Status = StepFailed
+			}
+		}
+	}()
+
+	if s.Kind == inventory.StepWait && s.Until == "" {
+		select {
+		case <-time.After(s.Duration):
+		case <-ctx.Done():
+			rec.Error = ctx.Err().Error()
+		}
+		return rec
+	}
+
+	target := w.StepTarget(s)
+	if s.Kind == inventory.StepExec {
+		results, err := e.execStep(ctx, s, target, opts)
+		if err != nil {
+			rec.Error = err.Error()
+			return rec
+		}
+		for i := range results {
+			rec.Hosts = append(rec.Hosts, stepHost(&results[i]))
+		}
+		return rec
+	}
+
+	targets, err := e.ResolveTargets(ExecOptions{Target: target})
+	if err != nil {
+		rec.Error = err.Error()
+		return rec
+	}
+
+	title := fmt.Sprintf("%s: %s", s.Kind, s.Name)
+	var task *progress.Task
+	var each func(ctx context.Context, hostID string) StepHost
+	switch s.Kind {
+	case inventory.StepTransfer:
+		data, err := os.ReadFile(inventory.ExpandPath(s.Source))
+		if err != nil {
+			rec.Error = fmt.Sprintf("failed to read %s: %v", s.Source, err)
+			return rec
+		}
+		task = progress.Start(opts.Progress, title, progress.UnitBytes, int64(len(data)*len(targets)))
+		each = func(ctx context.Context, hostID string) StepHost {
+			return e.uploadHost(ctx, hostID, data, s, task)
+		}
+	case inventory.StepWait:
+		each = func(ctx context.Context, hostID string) StepHost {
+			return e.waitHost(ctx, hostID, s, opts.Inputs)
+		}
+	case inventory.StepCheck:
+		c, ok := e.manager.GetCheck(s.CheckID)
+		if !ok {
+			rec.Error = fmt.Sprintf("check %s not found", s.CheckID)
+			return rec
+		}
+		each = func(ctx context.Context, hostID string) StepHost {
+			started := time.Now()
+			cr := e.runCheck(ctx, hostID, c, s.Timeout)
+			return StepHost{HostID: hostID, OK: cr.Status == CheckPass, ExitCode: cr.ExitCode, Error: cr.Message, Duration: time.Since(started)}
+		}
+	default:
+		rec.Error = fmt.Sprintf("invalid kind: %s", s.Kind)
+		return rec
+	}
+
+	if task == nil {
+		task = progress.Start(opts.Progress, title, progress.UnitHosts, int64(len(targets)))
+	}
+	rec.Hosts = forEachHost(ctx, targets, e.concurrency(opts.Concurrency), func(ctx context.Context, hostID string) StepHost {
+		sh := each(ctx, hostID)
+		if sh.OK {
+			task.Host(hostID, progress.HostOK, nil)
+		} else {
+			task.Host(hostID, progress.HostFailed, errors.New(sh.Error))
+		}
+		return sh
+	}, canceledStep)
+	task.Finish(ctx.Err())
+	return rec
+}
+
+// execStep runs the command of an exec step.
+func (e *Executor) execStep(ctx context.Context, s *inventory.WorkflowStep, target string, opts WorkflowOptions) ([]Result, error) {
+	execOpts := ExecOptions{
+		Command:     s.Command,
+		Target:      target,
+		Concurrency: opts.Concurrency,
+		Timeout:     s.Timeout,
+		Inputs:      opts.Inputs,
+		Prompt:      opts.Prompt,
+		Progress:    opts.Progress,
+	}
+	if s.CommandID != "" {
+		return e.ExecSaved(ctx, s.CommandID, execOpts)
+	}
+	return e.Exec(ctx, execOpts)
+}
+
+// uploadHost copies the data of a transfer step to one host, adding the bytes sent
+// to task.
+func (e *Executor) uploadHost(ctx context.Context, hostID string, data []byte, s *inventory.WorkflowStep, task *progress.Task) StepHost {
+	started := time.Now()
+	sh := StepHost{HostID: hostID, ExitCode: -1}
+	defer func() { sh.Duration = time.Since(started) }()
+
+	conn, err := e.manager.ResolveConnection(hostID)
+	if err == nil {
+		err = conn.ResolveSecrets()
+	}
+	if err != nil {
+		sh.Error = redact.Error(err).Error()
+		return sh
+	}
+	redact.Default().AddConnection(conn)
+	e.applyTimeouts(conn, ExecOptions{Timeout: s.Timeout})
+
+	uploader, ok := e.runnerFor(conn).(Uploader)
+	if !ok {
+		sh.Error = ErrNoUploader.Error()
+		return sh
+	}
+	if conn.Timeouts.Command > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conn.Timeouts.Command)
+		defer cancel()
+	}
+	if err := uploader.Upload(ctx, conn, task.Reader(bytes.NewReader(data)), s.Destination, s.FileMode()); err != nil {
+		sh.Error = redact.Error(err).Error()
+		return sh
+	}
+	sh.OK, sh.ExitCode = true, 0
+	return sh
+}
+
+// waitHost polls the Until command of a wait step on one host until it succeeds.
+func (e *Executor) waitHost(ctx context.Context, hostID string, s *inventory.WorkflowStep, inputs map[string]string) StepHost {
+	started := time.Now()
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	for {
+		r := e.runHost(ctx, hostID, ExecOptions{Command: s.Until, Inputs: inputs})
+		if r.OK() || ctx.Err() != nil {
+			sh := stepHost(&r)
+			if !r.OK() {
+				sh.Error = fmt.Sprintf("condition not met before timeout: %v", ctx.Err())
+			}
+			sh.Duration = time.Since(started)
+			return sh
+		}
+
+		select {
+		case <-time.After(s.WaitInterval()):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// stepHost converts an execution result.
+func stepHost(r *Result) StepHost {
+	sh := StepHost{HostID: r.HostID, OK: r.OK(), ExitCode: r.ExitCode, Duration: r.Duration, Snapshot: r.Snapshot}
+	if r.Err != nil {
+		sh.Error = r.Err.Error()
+	}
+	return sh
+}
+
+// forEachHost calls fn for every target on at most concurrency workers and returns
+// the outcomes in target order. Workers take the targets in order, so with a
+// concurrency of 1 the hosts run one after the other in target order; targets not
+// started when ctx is done get the outcome of canceled instead.
+func forEachHost[T any](ctx context.Context, targets []string, concurrency int, fn func(ctx context.Context, hostID string) T, canceled func(hostID string, err error) T) []T {
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	results := make([]T, len(targets))
+	next := make(chan int, len(targets))
+	for i := range targets {
+		next <- i
+	}
+	close(next)
+
+	var wg sync.WaitGroup
+	for range min(concurrency, len(targets)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := ctx.Err(); err != nil {
+					results[i] = canceled(targets[i], err)
+					continue
+				}
+				results[i] = fn(ctx, targets[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// canceledStep is the outcome of a step on a host that was not started.
+func canceledStep(hostID string, err error) StepHost {
+	return StepHost{HostID: hostID, ExitCode: -1, Error: err.Error()}
+}
+
+// writeWorkflowRun replaces the record of a workflow run.
+func writeWorkflowRun(dir string, run *WorkflowRun) error {
+	data, err := yaml.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to marshal workflow run: %w", err)
+	}
+	tmp := filepath.Join(dir, "."+WorkflowRunFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write workflow run: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, WorkflowRunFile)); err != nil {
+		return fmt.Errorf("failed to write workflow run: %w", err)
+	}
+	return nil
+}
+
+// ReadWorkflowRun loads the record of a workflow run.
+func ReadWorkflowRun(historyDir, runID string) (*WorkflowRun, error) {
+	if runID == "" || strings.ContainsAny(runID, `/\`) || strings.HasPrefix(runID, ".") {
+		return nil, fmt.Errorf("invalid run ID %q", runID)
+	}
+
+	data, err := os.ReadFile(filepath.Join(historyDir, runID, WorkflowRunFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("workflow run %s not found", runID)
+		}
+		return nil, fmt.Errorf("failed to read workflow run %s: %w", runID, err)
+	}
+
+	var run WorkflowRun
+	if err := yaml.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to parse workflow run %s: %w", runID, err)
+	}
+	return &run, nil
+}
@@ -0,0 +1,298 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// uploadingRunner adds file uploads to fakeRunner.
+type uploadingRunner struct {
+	*fakeRunner
+	mu      sync.Mutex
+	uploads map[string]string
+}
+
+func (r *uploadingRunner) Upload(ctx context.Context, conn *inventory.ResolvedConnection, src io.Reader, remotePath string, mode os.FileMode) error {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.uploads[conn.HostID+":"+remotePath] = string(data)
+	return nil
+}
+
+func TestRunWorkflow(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+
+	newExecutor := func(t *testing.T, onFailure inventory.StepFailure) (*Executor, *uploadingRunner, string) {
+		base, inner := setupExecutor(t)
+		runner := &uploadingRunner{fakeRunner: inner, uploads: map[string]string{}}
+		e := New(base.manager, runner)
+		require.NoError(t, e.manager.AddHost(inventory.NewLocalHost("build", "build")))
+
+		check := inventory.NewCheck("app-status", "app status", "systemctl status app")
+		check.ExpectRegex = "status app"
+		require.NoError(t, e.manager.AddCheck(check))
+
+		dir := t.TempDir()
+		w := inventory.NewWorkflow("deploy", "Deploy")
+		w.Target = "group:web"
+		w.AddStep(inventory.WorkflowStep{Name: "build", Kind: inventory.StepExec, Target: "build",
+			Command: "printf artifact > " + filepath.Join(dir, "app.txt")})
+		w.AddStep(inventory.WorkflowStep{Name: "upload", Kind: inventory.StepTransfer,
+			Source: filepath.Join(dir, "app.txt"), Destination: "/srv/app.txt"})
+		w.AddStep(inventory.WorkflowStep{Name: "settle", Kind: inventory.StepWait, Duration: time.Millisecond})
+		w.AddStep(inventory.WorkflowStep{Name: "restart", Kind: inventory.StepExec, Command: "systemctl restart app", OnFailure: onFailure})
+		w.AddStep(inventory.WorkflowStep{Name: "verify", Kind: inventory.StepCheck, CheckID: "app-status"})
+		require.NoError(t, e.manager.AddWorkflow(w))
+		return e, runner, dir
+	}
+
+	t.Run("runs every step", func(t *testing.T) {
+		e, runner, _ := newExecutor(t, "")
+		history := t.TempDir()
+
+		run, err := e.RunWorkflow(context.Background(), "deploy", WorkflowOptions{HistoryDir: history})
+		require.NoError(t, err)
+		assert.True(t, run.Succeeded())
+		require.Len(t, run.Steps, 5)
+		for _, s := range run.Steps {
+			assert.Equal(t, StepOK, s.Status, s.Name)
+		}
+		assert.Equal(t, "artifact", runner.uploads["web01:/srv/app.txt"])
+		assert.Equal(t, "artifact", runner.uploads["web02:/srv/app.txt"])
+
+		verify, ok := run.Step("verify")
+		require.True(t, ok)
+		require.Len(t, verify.Hosts, 2)
+		assert.Equal(t, "web01", verify.Hosts[0].HostID)
+
+		stored, err := ReadWorkflowRun(history, run.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "deploy", stored.WorkflowID)
+		assert.Equal(t, StepOK, stored.Status)
+		assert.Len(t, stored.Steps, 5)
+		assert.False(t, stored.Finished.IsZero())
+	})
+
+	t.Run("failed steps abort the run", func(t *testing.T) {
+		e, runner, _ := newExecutor(t, "")
+		runner.fail["web02"] = 1
+
+		run, err := e.RunWorkflow(context.Background(), "deploy", WorkflowOptions{})
+		require.NoError(t, err)
+		assert.False(t, run.Succeeded())
+
+		restart, _ := run.Step("restart")
+		assert.Equal(t, StepFailed, restart.Status)
+		assert.True(t, restart.Hosts[0].OK)
+		assert.False(t, restart.Hosts[1].OK)
+
+		verify, _ := run.Step("verify")
+		assert.Equal(t, StepSkipped, verify.Status)
+		assert.Empty(t, verify.Hosts)
+	})
+
+	t.Run("continue runs later steps", func(t *testing.T) {
+		e, runner, _ := newExecutor(t, inventory.StepContinue)
+		runner.fail["web02"] = 1
+
+		run, err := e.RunWorkflow(context.Background(), "deploy", WorkflowOptions{})
+		require.NoError(t, err)
+		assert.False(t, run.Succeeded())
+
+		verify, _ := run.Step("verify")
+		assert.Equal(t, StepFailed, verify.Status, "web02 still fails the check")
+		assert.True(t, verify.Hosts[0].OK)
+	})
+
+	t.Run("transfers need an uploader", func(t *testing.T) {
+		e, _, _ := newExecutor(t, "")
+		e.runner = e.runner.(*uploadingRunner).fakeRunner
+
+		run, err := e.RunWorkflow(context.Background(), "deploy", WorkflowOptions{})
+		require.NoError(t, err)
+		upload, _ := run.Step("upload")
+		assert.Equal(t, StepFailed, upload.Status)
+		assert.Equal(t, ErrNoUploader.Error(), upload.Hosts[0].Error)
+	})
+
+	t.Run("wait until a condition holds", func(t *testing.T) {
+		e, _, dir := newExecutor(t, "")
+		marker := filepath.Join(dir, "ready")
+
+		w := inventory.NewWorkflow("ready", "Ready")
+		w.Target = "build"
+		w.AddStep(inventory.WorkflowStep{Name: "mark", Kind: inventory.StepExec, Command: "(sleep 0.05; touch " + marker + ") &"})
+		w.AddStep(inventory.WorkflowStep{Name: "ready", Kind: inventory.StepWait, Until: "test -f " + marker, Interval: 10 * time.Millisecond, Timeout: 5 * time.Second})
+		w.AddStep(inventory.WorkflowStep{Name: "never", Kind: inventory.StepWait, Until: "false", Interval: 10 * time.Millisecond, Timeout: 50 * time.Millisecond})
+		require.NoError(t, e.manager.AddWorkflow(w))
+
+		run, err := e.RunWorkflow(context.Background(), "ready", WorkflowOptions{})
+		require.NoError(t, err)
+		ready, _ := run.Step("ready")
+		assert.Equal(t, StepOK, ready.Status)
+		never, _ := run.Step("never")
+		assert.Equal(t, StepFailed, never.Status)
+		assert.Contains(t, never.Hosts[0].Error, "condition not met")
+	})
+
+	t.Run("unknown workflow", func(t *testing.T) {
+		e, _, _ := newExecutor(t, "")
+		_, err := e.RunWorkflow(context.Background(), "missing", WorkflowOptions{})
+		assert.Error(t, err)
+	})
+}
+
+func TestRunWorkflowInputs(t *testing.T) {
+	e, runner := setupExecutor(t)
+	w := inventory.NewWorkflow("release", "Release")
+	w.Target = "group:web"
+	w.Inputs = []inventory.Input{{Name: "version"}, {Name: "password", Secret: true}}
+	w.AddStep(inventory.WorkflowStep{Name: "install", Kind: inventory.StepExec, Command: "install {{.Inputs.version}}"})
+	require.NoError(t, e.manager.AddWorkflow(w))
+
+	_, err := e.RunWorkflow(context.Background(), "release", WorkflowOptions{})
+	assert.ErrorIs(t, err, inventory.ErrMissingInput)
+	assert.Empty(t, runner.ran, "inputs are resolved before the first step")
+
+	run, err := e.RunWorkflow(context.Background(), "release", WorkflowOptions{
+		Inputs: map[string]string{"version": "2.0", "password": "pw-71c4"},
+	})
+	require.NoError(t, err)
+	assert.True(t, run.Succeeded())
+	assert.Equal(t, map[string]string{"version": "2.0"}, run.Inputs, "secret inputs are not recorded")
+	assert.Len(t, runner.ran, 2)
+}
+
+func TestWorkflowArtifacts(t *testing.T) {
+	e, runner := setupExecutor(t)
+	require.NoError(t, e.manager.AddHost(inventory.NewLocalHost("build", "build")))
+	runner.fail["web02"] = 1
+
+	report := filepath.Join(t.TempDir(), "report.txt")
+	require.NoError(t, os.WriteFile(report, []byte("all green"), 0644))
+
+	w := inventory.NewWorkflow("collect", "Collect")
+	w.AddStep(inventory.WorkflowStep{Name: "test", Kind: inventory.StepExec, Target: "build", Command: "true",
+		Artifacts: []string{report, "/nonexistent/core"}})
+	w.AddStep(inventory.WorkflowStep{Name: "logs", Kind: inventory.StepExec, Target: "group:web", Command: "tail app.log",
+		Artifacts: []string{"/var/log/app.log"}, OnFailure: inventory.StepContinue})
+	require.NoError(t, e.manager.AddWorkflow(w))
+
+	t.Run("needs a history directory", func(t *testing.T) {
+		_, err := e.RunWorkflow(context.Background(), "collect", WorkflowOptions{})
+		assert.Error(t, err)
+	})
+
+	history := t.TempDir()
+	run, err := e.RunWorkflow(context.Background(), "collect", WorkflowOptions{HistoryDir: history})
+	require.NoError(t, err)
+	runDir := filepath.Join(history, run.ID)
+
+	t.Run("local files", func(t *testing.T) {
+		step, _ := run.Step("test")
+		assert.Equal(t, StepOK, step.Status, "missing artifacts do not fail the step")
+		require.Len(t, step.Artifacts, 2)
+
+		got := step.Artifacts[0]
+		assert.Empty(t, got.Error)
+		assert.EqualValues(t, len("all green"), got.Size)
+		data, err := os.ReadFile(filepath.Join(runDir, got.File))
+		require.NoError(t, err)
+		assert.Equal(t, "all green", string(data))
+
+		assert.NotEmpty(t, step.Artifacts[1].Error)
+		assert.Empty(t, step.Artifacts[1].File)
+	})
+
+	t.Run("fetched from failed hosts too", func(t *testing.T) {
+		step, _ := run.Step("logs")
+		assert.Equal(t, StepFailed, step.Status)
+		require.Len(t, step.Artifacts, 2)
+
+		assert.Equal(t, "artifacts/logs/web01/var_log_app.log", step.Artifacts[0].File)
+		data, err := os.ReadFile(filepath.Join(runDir, step.Artifacts[0].File))
+		require.NoError(t, err)
+		assert.Equal(t, "deploy@web01: cat -- /var/log/app.log", string(data))
+
+		assert.Equal(t, "web02", step.Artifacts[1].HostID)
+		assert.Contains(t, step.Artifacts[1].Error, "failed on web02")
+	})
+
+	t.Run("listed in the stored record", func(t *testing.T) {
+		stored, err := ReadWorkflowRun(history, run.ID)
+		require.NoError(t, err)
+		step, _ := stored.Step("logs")
+		assert.Len(t, step.Artifacts, 2)
+	})
+}
+
+func TestArtifactName(t *testing.T) {
+	assert.Equal(t, "var_log_app.log", artifactName("/var/log/app.log"))
+	assert.Equal(t, "_.bashrc", artifactName(".bashrc"))
+	assert.Equal(t, "C__dumps_core.dmp", artifactName(`C:\dumps\core.dmp`))
+	assert.Equal(t, "_", artifactName("/"))
+}
+
+func TestForEachHost(t *testing.T) {
+	targets := []string{"a", "b", "c", "d", "e"}
+	canceled := func(hostID string, err error) string { return hostID + ": " + err.Error() }
+
+	t.Run("starts targets in order", func(t *testing.T) {
+		var mu sync.Mutex
+		var started []string
+		got := forEachHost(context.Background(), targets, 1, func(ctx context.Context, hostID string) string {
+			mu.Lock()
+			started = append(started, hostID)
+			mu.Unlock()
+			return hostID + ": ok"
+		}, canceled)
+		assert.Equal(t, targets, started)
+		assert.Equal(t, []string{"a: ok", "b: ok", "c: ok", "d: ok", "e: ok"}, got)
+	})
+
+	t.Run("skips targets once canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		got := forEachHost(ctx, targets, 1, func(ctx context.Context, hostID string) string {
+			if hostID == "b" {
+				cancel()
+			}
+			return hostID + ": ok"
+		}, canceled)
+		assert.Equal(t, []string{"a: ok", "b: ok", "c: context canceled", "d: context canceled", "e: context canceled"}, got)
+	})
+
+	t.Run("limits concurrency", func(t *testing.T) {
+		var mu sync.Mutex
+		running, peak := 0, 0
+		forEachHost(context.Background(), targets, 2, func(ctx context.Context, hostID string) string {
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return ""
+		}, canceled)
+		assert.LessOrEqual(t, peak, 2)
+	})
+}
@@ -0,0 +1,216 @@
+// Package i18n translates user-facing strings: error messages and the labels,
+// prompts and summaries of the front ends. Messages are looked up by key in the
+// selected language and fall back to English, then to the key itself, so a
+// missing translation never hides a message.
+//
+// Messages are fmt format strings; the arguments of T are applied with Sprintf.
+// Built-in keys are grouped by a dotted prefix ("error.", "status.", "ui.").
+// Plugins register their strings under "plugin.<name>." so they cannot collide
+// with built-in keys or each other.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLanguage is the language messages fall back to.
+const DefaultLanguage = "en"
+
+// Catalog holds the messages of every language and the selected language.
+type Catalog struct {
+	mu       sync.RWMutex
+	lang     string
+	messages map[string]map[string]string
+}
+
+// NewCatalog creates an empty catalog using DefaultLanguage.
+func NewCatalog() *Catalog {
+	return &Catalog{
+		lang:     DefaultLanguage,
+		messages: make(map[string]map[string]string),
+	}
+}
+
+// Default is the catalog used by the package-level functions. It holds the
+// built-in en and ko messages.
+var Default = newDefault()
+
+func newDefault() *Catalog {
+	c := NewCatalog()
+	for lang, messages := range builtin {
+		if err := c.Register(lang, messages); err != nil {
+			panic(err)
+		}
+	}
+	return c
+}
+
+// Normalize reduces a language tag or locale to the base language catalogs are
+// keyed by, e.g. "ko_KR.UTF-8" and "ko-KR" become "ko".
+func Normalize(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, ".@"); i >= 0 {
+		lang = lang[:i]
+	}
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// Register adds messages of a language to the catalog, replacing messages with
+// the same key.
+func (c *Catalog) Register(lang string, messages map[string]string) error {
+	lang = Normalize(lang)
+	if lang == "" {
+		return fmt.Errorf("language cannot be empty")
+	}
+	for key := range messages {
+		if key == "" {
+			return fmt.Errorf("%s: message key cannot be empty", lang)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	catalog, ok := c.messages[lang]
+	if !ok {
+		catalog = make(map[string]string, len(messages))
+		c.messages[lang] = catalog
+	}
+	for key, msg := range messages {
+		catalog[key] = msg
+	}
+	return nil
+}
+
+// SetLanguage selects the language of translated messages. The language must have
+// registered messages.
+func (c *Catalog) SetLanguage(lang string) error {
+	base := Normalize(lang)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.messages[base]; !ok && base != DefaultLanguage {
+		return fmt.Errorf("unsupported language: %q", lang)
+	}
+	c.lang = base
+	return nil
+}
+
+// Language returns the selected language.
+func (c *Catalog) Language() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lang
+}
+
+// Languages returns the languages with registered messages, sorted.
+func (c *Catalog) Languages() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	langs := make([]string, 0, len(c.messages))
+	for lang := range c.messages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// T translates a message to the selected language and formats it with args.
+func (c *Catalog) T(key string, args ...any) string {
+	return c.Translate(c.Language(), key, args...)
+}
+
+// Translate translates a message to the given language and formats it with args.
+func (c *Catalog) Translate(lang, key string, args ...any) string {
+	c.mu.RLock()
+	msg, ok := c.messages[Normalize(lang)][key]
+	if !ok {
+		msg, ok = c.messages[DefaultLanguage][key]
+	}
+	c.mu.RUnlock()
+
+	if !ok {
+		msg = key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// ===== Errors =====
+
+// Error is an error with a translatable message. Error() renders it in the selected
+// language of Default; Translate renders it in any language, e.g. English for logs.
+type Error struct {
+	Key  string
+	Args []any
+	// Err is the underlying error, if any.
+	Err error
+}
+
+// Errorf returns an Error for a message key. If the last argument is an error, it
+// is also returned by Unwrap.
+func Errorf(key string, args ...any) *Error {
+	e := &Error{Key: key, Args: args}
+	if len(args) > 0 {
+		if err, ok := args[len(args)-1].(error); ok {
+			e.Err = err
+		}
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	return Default.T(e.Key, e.Args...)
+}
+
+// Translate renders the error message in the given language.
+func (e *Error) Translate(lang string) string {
+	return Default.Translate(lang, e.Key, e.Args...)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ===== Default catalog =====
+
+// Register adds messages of a language to the Default catalog.
+func Register(lang string, messages map[string]string) error {
+	return Default.Register(lang, messages)
+}
+
+// SetLanguage selects the language of the Default catalog, e.g. from
+// inventory.GetLanguage at startup.
+func SetLanguage(lang string) error {
+	return Default.SetLanguage(lang)
+}
+
+// Language returns the selected language of the Default catalog.
+func Language() string {
+	return Default.Language()
+}
+
+// Languages returns the languages of the Default catalog.
+func Languages() []string {
+	return Default.Languages()
+}
+
+// T translates a message with the Default catalog.
+func T(key string, args ...any) string {
+	return Default.T(key, args...)
+}
+
+// PluginKey returns the key a plugin registers a message under.
+func PluginKey(plugin, key string) string {
+	return "plugin." + plugin + "." + key
+}
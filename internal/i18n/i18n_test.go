@@ -0,0 +1,87 @@
+package i18n
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalog(t *testing.T) {
+	c := NewCatalog()
+	require.NoError(t, c.Register("en", map[string]string{"greet": "Hello %s", "bye": "Bye"}))
+	require.NoError(t, c.Register("ko_KR.UTF-8", map[string]string{"greet": "안녕하세요 %s"}))
+
+	t.Run("translate with fallback", func(t *testing.T) {
+		assert.Equal(t, "Hello web01", c.T("greet", "web01"))
+
+		require.NoError(t, c.SetLanguage("ko-KR"))
+		assert.Equal(t, "ko", c.Language())
+		assert.Equal(t, "안녕하세요 web01", c.T("greet", "web01"))
+		assert.Equal(t, "Bye", c.T("bye"), "missing translations fall back to English")
+		assert.Equal(t, "missing.key", c.T("missing.key"))
+	})
+
+	t.Run("languages", func(t *testing.T) {
+		assert.Equal(t, []string{"en", "ko"}, c.Languages())
+		assert.ErrorContains(t, c.SetLanguage("fr"), `unsupported language: "fr"`)
+		assert.Equal(t, "ko", c.Language())
+		assert.Error(t, c.Register("", map[string]string{"x": "y"}))
+	})
+}
+
+func TestNormalize(t *testing.T) {
+	for in, want := range map[string]string{
+		"en":          "en",
+		"ko-KR":       "ko",
+		"ko_KR.UTF-8": "ko",
+		"EN_us@euro":  "en",
+		"":            "",
+	} {
+		assert.Equal(t, want, Normalize(in), in)
+	}
+}
+
+func TestBuiltinMessages(t *testing.T) {
+	t.Run("every translation has an English message", func(t *testing.T) {
+		for lang, messages := range builtin {
+			for key := range messages {
+				_, ok := builtin[DefaultLanguage][key]
+				assert.True(t, ok, "%s: %s has no English message", lang, key)
+			}
+		}
+	})
+
+	t.Run("translations keep the format verbs", func(t *testing.T) {
+		for key, en := range builtin[DefaultLanguage] {
+			ko, ok := builtin["ko"][key]
+			require.True(t, ok, key)
+			assert.Equal(t, verbs(en), verbs(ko), key)
+		}
+	})
+}
+
+func TestError(t *testing.T) {
+	t.Cleanup(func() { Default.SetLanguage(DefaultLanguage) })
+
+	cause := errors.New("connection refused")
+	err := Errorf("error.connect_failed", "web01", cause)
+	assert.Equal(t, "failed to connect to web01: connection refused", err.Error())
+	assert.ErrorIs(t, err, cause)
+
+	require.NoError(t, SetLanguage("ko"))
+	assert.Equal(t, "web01에 연결하지 못했습니다: connection refused", err.Error())
+	assert.Equal(t, "failed to connect to web01: connection refused", err.Translate("en"))
+}
+
+// verbs counts the format verbs of a message, e.g. "%s: %v" has 2.
+func verbs(msg string) int {
+	n := 0
+	for i := 0; i < len(msg)-1; i++ {
+		if msg[i] == '%' && msg[i+1] != '%' {
+			n++
+		}
+	}
+	return n
+}
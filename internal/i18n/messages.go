@@ -0,0 +1,99 @@
+package i18n
+
+// builtin holds the messages shipped with gossher. Every key must exist in "en";
+// other languages may lag behind and fall back to English.
+var builtin = map[string]map[string]string{
+	"en": {
+		// Errors
+		"error.config_not_loaded":    "config not loaded",
+		"error.host_not_found":       "host %s not found",
+		"error.host_exists":          "host %s already exists",
+		"error.group_not_found":      "group %s not found",
+		"error.group_exists":         "group %s already exists",
+		"error.credential_not_found": "credential %s not found",
+		"error.jump_host_in_use":     "host %s is still used as jump host by %s",
+		"error.connect_failed":       "failed to connect to %s: %v",
+		"error.auth_failed":          "authentication to %s failed: %v",
+		"error.host_key_mismatch":    "host key of %s does not match the pinned key",
+		"error.timeout":              "%s timed out after %s",
+		"error.command_failed":       "command failed on %s with exit code %d",
+		"error.transfer_failed":      "failed to transfer %s: %v",
+		"error.permission_denied":    "permission denied: %s",
+		"error.invalid_value":        "invalid %s: %s",
+
+		// Host status
+		"status.online":     "Online",
+		"status.offline":    "Offline",
+		"status.connecting": "Connecting",
+		"status.unknown":    "Unknown",
+
+		// Front-end strings
+		"ui.hosts":            "Hosts",
+		"ui.groups":           "Groups",
+		"ui.credentials":      "Credentials",
+		"ui.commands":         "Commands",
+		"ui.search":           "Search",
+		"ui.no_results":       "No results",
+		"ui.yes":              "Yes",
+		"ui.no":               "No",
+		"ui.cancel":           "Cancel",
+		"ui.confirm":          "Are you sure?",
+		"ui.confirm_remove":   "Remove %s %s?",
+		"ui.password_prompt":  "Password for %s: ",
+		"ui.passphrase":       "Passphrase for %s: ",
+		"ui.connecting":       "Connecting to %s...",
+		"ui.connected":        "Connected to %s",
+		"ui.disconnected":     "Disconnected from %s",
+		"ui.run_summary":      "%d succeeded, %d failed, %d skipped",
+		"ui.transfer_summary": "%d files, %s transferred",
+		"ui.quit_hint":        "Press q to quit",
+		"ui.help":             "Help",
+	},
+
+	"ko": {
+		// Errors
+		"error.config_not_loaded":    "설정이 로드되지 않았습니다",
+		"error.host_not_found":       "호스트 %s을(를) 찾을 수 없습니다",
+		"error.host_exists":          "호스트 %s이(가) 이미 존재합니다",
+		"error.group_not_found":      "그룹 %s을(를) 찾을 수 없습니다",
+		"error.group_exists":         "그룹 %s이(가) 이미 존재합니다",
+		"error.credential_not_found": "자격 증명 %s을(를) 찾을 수 없습니다",
+		"error.jump_host_in_use":     "호스트 %s은(는) 아직 %s의 점프 호스트로 사용 중입니다",
+		"error.connect_failed":       "%s에 연결하지 못했습니다: %v",
+		"error.auth_failed":          "%s 인증에 실패했습니다: %v",
+		"error.host_key_mismatch":    "%s의 호스트 키가 고정된 키와 일치하지 않습니다",
+		"error.timeout":              "%s이(가) %s 후 시간 초과되었습니다",
+		"error.command_failed":       "%s에서 명령이 종료 코드 %d(으)로 실패했습니다",
+		"error.transfer_failed":      "%s 전송에 실패했습니다: %v",
+		"error.permission_denied":    "권한이 없습니다: %s",
+		"error.invalid_value":        "잘못된 %s: %s",
+
+		// Host status
+		"status.online":     "온라인",
+		"status.offline":    "오프라인",
+		"status.connecting": "연결 중",
+		"status.unknown":    "알 수 없음",
+
+		// Front-end strings
+		"ui.hosts":            "호스트",
+		"ui.groups":           "그룹",
+		"ui.credentials":      "자격 증명",
+		"ui.commands":         "명령",
+		"ui.search":           "검색",
+		"ui.no_results":       "결과 없음",
+		"ui.yes":              "예",
+		"ui.no":               "아니요",
+		"ui.cancel":           "취소",
+		"ui.confirm":          "계속하시겠습니까?",
+		"ui.confirm_remove":   "%s %s을(를) 삭제하시겠습니까?",
+		"ui.password_prompt":  "%s의 비밀번호: ",
+		"ui.passphrase":       "%s의 패스프레이즈: ",
+		"ui.connecting":       "%s에 연결하는 중...",
+		"ui.connected":        "%s에 연결되었습니다",
+		"ui.disconnected":     "%s와(과)의 연결이 끊어졌습니다",
+		"ui.run_summary":      "성공 %d, 실패 %d, 건너뜀 %d",
+		"ui.transfer_summary": "파일 %d개, %s 전송됨",
+		"ui.quit_hint":        "종료하려면 q를 누르세요",
+		"ui.help":             "도움말",
+	},
+}
@@ -0,0 +1,186 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AttachmentsDir is the folder inside the data directory that holds the files
+// attached to hosts, as attachments/<host id>/<name>.
+const AttachmentsDir = "attachments"
+
+// MaxAttachmentSize limits the size of a single attachment. Attachments are meant
+// for small documents such as diagrams, invoices or kickstart files.
+const MaxAttachmentSize = 10 << 20
+
+// Attachment describes a file attached to a host.
+type Attachment struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	// Path is the absolute path of the file, e.g. to open it in another program.
+	Path string
+}
+
+// attachmentDir returns the attachment folder of a host. Caller must hold the lock.
+func (m *Manager) attachmentDir(hostID string) string {
+	return filepath.Join(m.dataDir, AttachmentsDir, hostID)
+}
+
+// checkAttachment verifies that the host exists and the name is a plain file name.
+// Caller must hold the lock.
+func (m *Manager) checkAttachment(hostID, name string) error {
+	if _, ok := m.hosts[hostID]; !ok {
+		return fmt.Errorf("host %s not found", hostID)
+	}
+	if name == "" || name != filepath.Base(name) || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid attachment name: %q", name)
+	}
+	return nil
+}
+
+// AttachFile copies a file to a host's attachments under name, or under the file's
+// own name when name is empty, replacing an attachment with the same name.
+func (m *Manager) AttachFile(hostID, src, name string) (*Attachment, error) {
+	src = ExpandPath(src)
+	if name == "" {
+		name = filepath.Base(src)
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open attachment: %w", err)
+	}
+	defer f.Close()
+	return m.AddAttachment(hostID, name, f)
+}
+
+// AddAttachment stores the content of r as a host attachment, replacing an
+// attachment with the same name. Content larger than MaxAttachmentSize is rejected.
+func (m *Manager) AddAttachment(hostID, name string, r io.Reader) (*Attachment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkAttachment(hostID, name); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(r, MaxAttachmentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if len(data) > MaxAttachmentSize {
+		return nil, fmt.Errorf("attachment %s is larger than %d MiB", name, MaxAttachmentSize>>20)
+	}
+
+	dir := m.attachmentDir(hostID)
+	if err := os.MkdirAll(dir, m.perms.DirMode()); err != nil {
+		return nil, fmt.Errorf("failed to create attachments directory: %w", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, m.perms.FileMode()); err != nil {
+		return nil, fmt.Errorf("failed to write attachment: %w", err)
+	}
+	return statAttachment(path)
+}
+
+// ListAttachments returns the attachments of a host sorted by name.
+func (m *Manager) ListAttachments(hostID string) ([]Attachment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.hosts[hostID]; !ok {
+		return nil, fmt.Errorf("host %s not found", hostID)
+	}
+	entries, err := os.ReadDir(m.attachmentDir(hostID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+
+	var attachments []Attachment
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		a, err := statAttachment(filepath.Join(m.attachmentDir(hostID), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *a)
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].Name < attachments[j].Name })
+	return attachments, nil
+}
+
+// OpenAttachment opens a host attachment for reading. The caller closes it.
+func (m *Manager) OpenAttachment(hostID, name string) (*os.File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := m.checkAttachment(hostID, name); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(m.attachmentDir(hostID), name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("host %s has no attachment %s", hostID, name)
+	}
+	return f, err
+}
+
+// RemoveAttachment deletes a host attachment.
+func (m *Manager) RemoveAttachment(hostID, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkAttachment(hostID, name); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(m.attachmentDir(hostID), name))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("host %s has no attachment %s", hostID, name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove attachment: %w", err)
+	}
+	// Drop the host's folder once it is empty; failing to do so is harmless.
+	os.Remove(m.attachmentDir(hostID))
+	return nil
+}
+
+// moveAttachments follows the rename of a host. Caller must hold the lock.
+func (m *Manager) moveAttachments(oldID, newID string) error {
+	err := os.Rename(m.attachmentDir(oldID), m.attachmentDir(newID))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to move attachments: %w", err)
+	}
+	return nil
+}
+
+// removeAttachments deletes the attachments of a removed host. Caller must hold
+// the lock.
+func (m *Manager) removeAttachments(hostID string) error {
+	if err := os.RemoveAll(m.attachmentDir(hostID)); err != nil {
+		return fmt.Errorf("failed to remove attachments: %w", err)
+	}
+	return nil
+}
+
+func statAttachment(path string) (*Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	return &Attachment{Name: info.Name(), Size: info.Size(), ModTime: info.ModTime(), Path: abs}, nil
+}
@@ -0,0 +1,89 @@
+package inventory
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachments(t *testing.T) {
+	m, dir := setupTestManager(t)
+	require.NoError(t, m.Load())
+	h := NewHost("web01", "web01", "10.0.0.1")
+	h.User = "deploy"
+	require.NoError(t, m.AddHost(h))
+
+	src := filepath.Join(t.TempDir(), "ks.cfg")
+	require.NoError(t, os.WriteFile(src, []byte("install\nreboot\n"), 0644))
+
+	t.Run("attach and list", func(t *testing.T) {
+		a, err := m.AttachFile("web01", src, "")
+		require.NoError(t, err)
+		assert.Equal(t, "ks.cfg", a.Name)
+		assert.Equal(t, int64(15), a.Size)
+		assert.Equal(t, filepath.Join(dir, AttachmentsDir, "web01", "ks.cfg"), a.Path)
+
+		_, err = m.AddAttachment("web01", "rack.svg", strings.NewReader("<svg/>"))
+		require.NoError(t, err)
+
+		list, err := m.ListAttachments("web01")
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		assert.Equal(t, "ks.cfg", list[0].Name)
+		assert.Equal(t, "rack.svg", list[1].Name)
+
+		require.NoError(t, m.Load(), "attachments are not data files")
+	})
+
+	t.Run("open", func(t *testing.T) {
+		f, err := m.OpenAttachment("web01", "ks.cfg")
+		require.NoError(t, err)
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, "install\nreboot\n", string(data))
+
+		_, err = m.OpenAttachment("web01", "missing.pdf")
+		assert.ErrorContains(t, err, "no attachment missing.pdf")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, name := range []string{"../escape", ".hidden", "a/b", ""} {
+			_, err := m.AddAttachment("web01", name, strings.NewReader("x"))
+			assert.ErrorContains(t, err, "invalid attachment name", name)
+		}
+		_, err := m.AddAttachment("nope", "x.txt", strings.NewReader("x"))
+		assert.ErrorContains(t, err, "host nope not found")
+
+		_, err = m.AddAttachment("web01", "big.iso", io.LimitReader(zeros{}, MaxAttachmentSize+1))
+		assert.ErrorContains(t, err, "larger than 10 MiB")
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		require.NoError(t, m.RemoveAttachment("web01", "rack.svg"))
+		assert.Error(t, m.RemoveAttachment("web01", "rack.svg"))
+	})
+
+	t.Run("follows renames and removals", func(t *testing.T) {
+		require.NoError(t, m.RenameHost("web01", "web-01"))
+		list, err := m.ListAttachments("web-01")
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+
+		require.NoError(t, m.RemoveHost("web-01"))
+		assert.NoDirExists(t, filepath.Join(dir, AttachmentsDir, "web-01"))
+	})
+}
+
+// zeros is an endless reader of zero bytes.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
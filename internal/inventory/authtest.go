@@ -0,0 +1,200 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"gossher/internal/term"
+)
+
+// DefaultAuthConcurrency is the number of logins TestAllHosts attempts at the same time.
+const DefaultAuthConcurrency = 10
+
+// ErrNoAuthenticator is returned by credential tests when no Authenticator is set.
+var ErrNoAuthenticator = errors.New("no authenticator configured")
+
+// Authenticator attempts to log in on a connection without opening a shell or
+// running a command. A nil error means the server accepted the credentials.
+type Authenticator interface {
+	Authenticate(ctx context.Context, conn *ResolvedConnection) error
+}
+
+// SetAuthenticator sets the Authenticator used by TestCredential and TestAllHosts.
+func (m *Manager) SetAuthenticator(a Authenticator) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.authenticator = a
+}
+
+// CredentialTest is the outcome of logging in to a host with a credential.
+type CredentialTest struct {
+	HostID       string        `yaml:"host" json:"host"`
+	CredentialID string        `yaml:"credential" json:"credential"`
+	OK           bool          `yaml:"ok" json:"ok"`
+	Error        string        `yaml:"error,omitempty" json:"error,omitempty"`
+	Duration     time.Duration `yaml:"duration" json:"duration"`
+}
+
+// TestCredential attempts to log in to a host with a credential instead of the host's
+// own authentication; address, port and jump hosts are those of the host. Login
+// failures are reported in the result, the error is for unknown IDs.
+func (m *Manager) TestCredential(ctx context.Context, credID, hostID string) (CredentialTest, error) {
+	m.mu.RLock()
+	auth := m.authenticator
+	conn, err := m.credentialConnection(credID, hostID)
+	m.mu.RUnlock()
+
+	if err != nil {
+		return CredentialTest{}, err
+	}
+	if auth == nil {
+		return CredentialTest{}, ErrNoAuthenticator
+	}
+	return testLogin(ctx, auth, conn, credID), nil
+}
+
+// TestAllHosts attempts to log in to every host with every credential, e.g. after a
+// key rotation, and returns a host × credential matrix.
+func (m *Manager) TestAllHosts(ctx context.Context) (*CredentialMatrix, error) {
+	m.mu.RLock()
+	auth := m.authenticator
+	matrix := &CredentialMatrix{Hosts: sortedKeys(m.hosts), Credentials: sortedKeys(m.credentials)}
+
+	type attempt struct {
+		credID string
+		conn   *ResolvedConnection
+	}
+	var attempts []attempt
+	var resolveErrs []CredentialTest
+	for _, hostID := range matrix.Hosts {
+		for _, credID := range matrix.Credentials {
+			conn, err := m.credentialConnection(credID, hostID)
+			if err != nil {
+				resolveErrs = append(resolveErrs, CredentialTest{HostID: hostID, CredentialID: credID, Error: err.Error()})
+				continue
+			}
+			attempts = append(attempts, attempt{credID, conn})
+		}
+	}
+	m.mu.RUnlock()
+
+	if auth == nil {
+		return nil, ErrNoAuthenticator
+	}
+
+	results := make([]CredentialTest, len(attempts))
+	sem := make(chan struct{}, DefaultAuthConcurrency)
+	var wg sync.WaitGroup
+	for i, a := range attempts {
+		wg.Add(1)
+		go func(i int, a attempt) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i] = CredentialTest{HostID: a.conn.HostID, CredentialID: a.credID, Error: ctx.Err().Error()}
+				return
+			}
+			results[i] = testLogin(ctx, auth, a.conn, a.credID)
+		}(i, a)
+	}
+	wg.Wait()
+
+	matrix.Results = append(results, resolveErrs...)
+	return matrix, nil
+}
+
+// credentialConnection resolves a host's connection with the authentication of a
+// credential. Caller must hold the lock.
+func (m *Manager) credentialConnection(credID, hostID string) (*ResolvedConnection, error) {
+	cred, ok := m.credentials[credID]
+	if !ok {
+		return nil, fmt.Errorf("credential %s not found", credID)
+	}
+	conn, err := m.resolveConnection(hostID, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	conn.User = cred.User
+	conn.KeyPath = cred.KeyPath
+	conn.Password = cred.Password
+	conn.Passphrase = cred.Passphrase
+	conn.PassphraseFile = cred.PassphraseFile
+	conn.Provider = cred.Provider
+	conn.ItemID = cred.ItemID
+	return conn, nil
+}
+
+// testLogin resolves the secrets of a connection and attempts to log in.
+func testLogin(ctx context.Context, auth Authenticator, conn *ResolvedConnection, credID string) CredentialTest {
+	t := CredentialTest{HostID: conn.HostID, CredentialID: credID}
+	started := time.Now()
+	defer func() { t.Duration = time.Since(started) }()
+
+	err := conn.ResolveSecrets()
+	if err == nil {
+		err = auth.Authenticate(ctx, conn)
+	}
+	if err != nil {
+		t.Error = err.Error()
+		return t
+	}
+	t.OK = true
+	return t
+}
+
+// CredentialMatrix holds the outcome of logging in to hosts with credentials.
+type CredentialMatrix struct {
+	Hosts       []string         `yaml:"hosts" json:"hosts"`
+	Credentials []string         `yaml:"credentials" json:"credentials"`
+	Results     []CredentialTest `yaml:"results" json:"results"`
+}
+
+// Get returns the result of a credential on a host.
+func (m *CredentialMatrix) Get(hostID, credID string) (CredentialTest, bool) {
+	for _, r := range m.Results {
+		if r.HostID == hostID && r.CredentialID == credID {
+			return r, true
+		}
+	}
+	return CredentialTest{}, false
+}
+
+// Working returns the credentials that logged in to a host, sorted by ID.
+func (m *CredentialMatrix) Working(hostID string) []string {
+	var ids []string
+	for _, credID := range m.Credentials {
+		if r, ok := m.Get(hostID, credID); ok && r.OK {
+			ids = append(ids, credID)
+		}
+	}
+	return ids
+}
+
+// WriteText prints one row per host and one column per credential.
+func (m *CredentialMatrix) WriteText(w io.Writer) error {
+	tw := term.NewTable(w)
+
+	fmt.Fprintf(tw, "HOST\t%s\n", strings.Join(m.Credentials, "\t"))
+	for _, hostID := range m.Hosts {
+		cells := make([]string, len(m.Credentials))
+		for i, credID := range m.Credentials {
+			cells[i] = "-"
+			if r, ok := m.Get(hostID, credID); ok {
+				cells[i] = "FAIL"
+				if r.OK {
+					cells[i] = "OK"
+				}
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", hostID, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
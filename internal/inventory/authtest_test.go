@@ -0,0 +1,69 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// passwordAuthenticator accepts the passwords listed per host.
+type passwordAuthenticator map[string]string
+
+func (a passwordAuthenticator) Authenticate(ctx context.Context, conn *ResolvedConnection) error {
+	if want, ok := a[conn.HostID]; ok && conn.Password == want {
+		return nil
+	}
+	return errors.New("permission denied")
+}
+
+func TestCredentialTests(t *testing.T) {
+	m, _ := setupTestManager(t)
+	for id, password := range map[string]string{"old": "winter23", "new": "summer24"} {
+		c := NewCredential(id, id, "ops")
+		c.Password = password
+		require.NoError(t, m.AddCredential(c))
+	}
+	for _, id := range []string{"web1", "web2"} {
+		require.NoError(t, m.AddHost(NewHostWithCredential(id, id, "10.0.0.1", "old")))
+	}
+
+	_, err := m.TestCredential(context.Background(), "new", "web1")
+	assert.ErrorIs(t, err, ErrNoAuthenticator)
+
+	m.SetAuthenticator(passwordAuthenticator{"web1": "summer24", "web2": "winter23"})
+
+	t.Run("single credential", func(t *testing.T) {
+		res, err := m.TestCredential(context.Background(), "new", "web1")
+		require.NoError(t, err)
+		assert.True(t, res.OK)
+		assert.Equal(t, "web1", res.HostID)
+
+		res, err = m.TestCredential(context.Background(), "new", "web2")
+		require.NoError(t, err)
+		assert.False(t, res.OK)
+		assert.Equal(t, "permission denied", res.Error)
+
+		_, err = m.TestCredential(context.Background(), "missing", "web1")
+		assert.Error(t, err)
+		_, err = m.TestCredential(context.Background(), "new", "missing")
+		assert.Error(t, err)
+	})
+
+	t.Run("matrix", func(t *testing.T) {
+		matrix, err := m.TestAllHosts(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web1", "web2"}, matrix.Hosts)
+		assert.Equal(t, []string{"new", "old"}, matrix.Credentials)
+		assert.Len(t, matrix.Results, 4)
+		assert.Equal(t, []string{"new"}, matrix.Working("web1"))
+		assert.Equal(t, []string{"old"}, matrix.Working("web2"))
+
+		var buf bytes.Buffer
+		require.NoError(t, matrix.WriteText(&buf))
+		assert.Equal(t, "HOST  new   old\nweb1  OK    FAIL\nweb2  FAIL  OK\n", buf.String())
+	})
+}
@@ -0,0 +1,221 @@
+package inventory
+
+import (
+	"fmt"
+	"time"
+)
+
+// HostBundle is a self-contained copy of a host that can be imported into another inventory.
+type HostBundle struct {
+	Host       *Host       `yaml:"host" json:"host"`
+	Credential *Credential `yaml:"credential,omitempty" json:"credential,omitempty"`
+	// Groups lists the groups the host directly belongs to in the source inventory.
+	Groups []string `yaml:"groups,omitempty" json:"groups,omitempty"`
+}
+
+// ExportOptions controls what ExportHost includes.
+type ExportOptions struct {
+	// IncludeCredential bundles the credential referenced by the host.
+	IncludeCredential bool
+	// Anonymize strips passwords, passphrases and runtime history from the bundle.
+	Anonymize bool
+}
+
+// ImportOptions controls how ImportHost merges a bundle.
+type ImportOptions struct {
+	// Overwrite replaces an existing host with the same ID instead of failing.
+	Overwrite bool
+}
+
+// ImportResult reports what ImportHost did.
+type ImportResult struct {
+	HostID string `json:"host_id"`
+	// CredentialID is the credential the imported host uses, if any.
+	CredentialID string `json:"credential_id,omitempty"`
+	// CredentialCreated is set when the bundled credential was added to the target.
+	CredentialCreated bool `json:"credential_created,omitempty"`
+	// CredentialInlined is set when the credential could not be imported (e.g. it was
+	// anonymized) and the host falls back to inline user authentication.
+	CredentialInlined bool `json:"credential_inlined,omitempty"`
+	// JumpHostDropped is set when the host's jump host does not exist in the target.
+	JumpHostDropped bool `json:"jump_host_dropped,omitempty"`
+	// RelayHostDropped is set when the host's relay host does not exist in the target.
+	RelayHostDropped bool `json:"relay_host_dropped,omitempty"`
+	// JoinedGroups are the groups the host was added to in the target.
+	JoinedGroups []string `json:"joined_groups,omitempty"`
+	// MissingGroups are source groups that do not exist in the target.
+	MissingGroups []string `json:"missing_groups,omitempty"`
+}
+
+// ExportHost builds a bundle for a host.
+func (m *Manager) ExportHost(id string, opts ExportOptions) (*HostBundle, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	h, ok := m.hosts[id]
+	if !ok {
+		return nil, fmt.Errorf("host %s not found", id)
+	}
+
+	b := &HostBundle{Host: h.Clone().(*Host)}
+
+	if opts.IncludeCredential && h.CredentialID != "" {
+		if c, ok := m.credentials[h.CredentialID]; ok {
+			b.Credential = c.Clone().(*Credential)
+		}
+	}
+
+	for _, name := range sortedKeys(m.groups) {
+		if m.groups[name].HasHost(id) {
+			b.Groups = append(b.Groups, name)
+		}
+	}
+
+	if opts.Anonymize {
+		b.anonymize()
+	}
+	return b, nil
+}
+
+// anonymize removes secrets and runtime history from the bundle.
+func (b *HostBundle) anonymize() {
+	b.Host.Password = ""
+	b.Host.Facts = nil
+	b.Host.FactsUpdated = time.Time{}
+	b.Host.LastConnected = time.Time{}
+
+	if b.Credential != nil {
+		b.Credential.Password = ""
+		b.Credential.Passphrase = ""
+	}
+}
+
+// ImportHost adds a bundled host to the inventory, reusing or creating its credential
+// and joining every bundled group that exists under the same name.
+func (m *Manager) ImportHost(b *HostBundle, opts ImportOptions) (*ImportResult, error) {
+	if b == nil || b.Host == nil {
+		return nil, fmt.Errorf("bundle has no host")
+	}
+
+	h := b.Host.Clone().(*Host)
+	result := &ImportResult{HostID: h.ID}
+
+	if h.CredentialID != "" {
+		if _, exists := m.GetCredential(h.CredentialID); exists {
+			result.CredentialID = h.CredentialID
+		} else if b.Credential != nil && b.Credential.Validate() == nil {
+			c := b.Credential.Clone().(*Credential)
+			if err := m.AddCredential(c); err != nil {
+				return nil, err
+			}
+			h.CredentialID = c.ID
+			result.CredentialID = c.ID
+			result.CredentialCreated = true
+		} else {
+			if b.Credential == nil || b.Credential.User == "" {
+				return nil, fmt.Errorf("host %s: credential %s not found in target and not bundled", h.ID, h.CredentialID)
+			}
+			h.CredentialID = ""
+			h.User = b.Credential.User
+			h.KeyPath = b.Credential.KeyPath
+			result.CredentialInlined = true
+		}
+	}
+
+	if h.JumpHostID != "" {
+		if _, ok := m.GetHost(h.JumpHostID); !ok {
+			h.JumpHostID = ""
+			result.JumpHostDropped = true
+		}
+	}
+	if h.RelayHostID != "" {
+		if _, ok := m.GetHost(h.RelayHostID); !ok {
+			h.RelayHostID = ""
+			result.RelayHostDropped = true
+		}
+	}
+
+	_, exists := m.GetHost(h.ID)
+	switch {
+	case exists && !opts.Overwrite:
+		return nil, fmt.Errorf("host %s already exists", h.ID)
+	case exists:
+		if err := m.UpdateHost(h); err != nil {
+			return nil, err
+		}
+	default:
+		if err := m.AddHost(h); err != nil {
+			return nil, err
+		}
+	}
+	result.HostID = h.ID
+
+	for _, name := range b.Groups {
+		g, ok := m.GetGroup(name)
+		if !ok {
+			result.MissingGroups = append(result.MissingGroups, name)
+			continue
+		}
+		if !g.HasHost(h.ID) {
+			g.AddHost(h.ID)
+			if err := m.UpdateGroup(g); err != nil {
+				return result, err
+			}
+		}
+		result.JoinedGroups = append(result.JoinedGroups, name)
+	}
+
+	return result, nil
+}
+
+// MoveOptions controls MoveHost.
+type MoveOptions struct {
+	ExportOptions
+	ImportOptions
+	// KeepSource copies the host instead of removing it from the source inventory.
+	KeepSource bool
+}
+
+// MoveHost exports a host from src, imports it into dst and, unless KeepSource is set,
+// removes it from src. The credential is always offered to dst; it stays in src
+// because other hosts may still use it.
+func MoveHost(src, dst *Manager, hostID string, opts MoveOptions) (*ImportResult, error) {
+	if src == dst {
+		return nil, fmt.Errorf("source and target inventories are the same")
+	}
+
+	opts.IncludeCredential = true
+	b, err := src.ExportHost(hostID, opts.ExportOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := dst.ImportHost(b, opts.ImportOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.KeepSource {
+		if err := src.RemoveHost(hostID); err != nil {
+			return result, fmt.Errorf("host imported but not removed from source: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// MoveHostBetweenProfiles is MoveHost for two configured profiles.
+func MoveHostBetweenProfiles(fromProfile, toProfile, hostID string, opts MoveOptions) (*ImportResult, error) {
+	if fromProfile == toProfile {
+		return nil, fmt.Errorf("source and target profile are the same")
+	}
+
+	src, err := OpenProfile(fromProfile)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := OpenProfile(toProfile)
+	if err != nil {
+		return nil, err
+	}
+	return MoveHost(src, dst, hostID, opts)
+}
@@ -0,0 +1,95 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupBundleSource(t *testing.T) *Manager {
+	src, _ := setupTestManager(t)
+
+	cred := NewCredential("deploy", "Deploy", "deploy")
+	cred.KeyPath = "/keys/deploy"
+	cred.Passphrase = "secret"
+	require.NoError(t, src.AddCredential(cred))
+
+	h := NewHostWithCredential("web1", "web1", "10.0.0.1", "deploy")
+	h.Facts = map[string]string{"os": "linux"}
+	require.NoError(t, src.AddHost(h))
+
+	for _, name := range []string{"web", "prod"} {
+		g := NewGroup(name)
+		g.AddHost("web1")
+		require.NoError(t, src.AddGroup(g))
+	}
+	return src
+}
+
+func TestExportHost(t *testing.T) {
+	src := setupBundleSource(t)
+
+	b, err := src.ExportHost("web1", ExportOptions{IncludeCredential: true, Anonymize: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"prod", "web"}, b.Groups)
+	require.NotNil(t, b.Credential)
+	assert.Empty(t, b.Credential.Passphrase)
+	assert.Equal(t, "/keys/deploy", b.Credential.KeyPath)
+	assert.Nil(t, b.Host.Facts)
+
+	_, err = src.ExportHost("missing", ExportOptions{})
+	assert.Error(t, err)
+}
+
+func TestMoveHost(t *testing.T) {
+	src := setupBundleSource(t)
+	dst, _ := setupTestManager(t)
+	require.NoError(t, dst.AddGroup(NewGroup("web")))
+
+	result, err := MoveHost(src, dst, "web1", MoveOptions{})
+	require.NoError(t, err)
+
+	assert.True(t, result.CredentialCreated)
+	assert.Equal(t, []string{"web"}, result.JoinedGroups)
+	assert.Equal(t, []string{"prod"}, result.MissingGroups)
+
+	_, ok := src.GetHost("web1")
+	assert.False(t, ok)
+	_, ok = src.GetCredential("deploy")
+	assert.True(t, ok, "credential stays in source")
+
+	h, ok := dst.GetHost("web1")
+	require.True(t, ok)
+	assert.Equal(t, "deploy", h.CredentialID)
+	g, _ := dst.GetGroup("web")
+	assert.Equal(t, []string{"web1"}, g.HostIDs)
+
+	t.Run("moving into existing host fails without overwrite", func(t *testing.T) {
+		again := setupBundleSource(t)
+		_, err := MoveHost(again, dst, "web1", MoveOptions{KeepSource: true})
+		assert.Error(t, err)
+
+		_, err = MoveHost(again, dst, "web1", MoveOptions{KeepSource: true, ImportOptions: ImportOptions{Overwrite: true}})
+		assert.NoError(t, err)
+	})
+}
+
+func TestImportAnonymizedPasswordCredential(t *testing.T) {
+	src, _ := setupTestManager(t)
+	cred := NewCredential("admin", "Admin", "root")
+	cred.Password = "secret"
+	require.NoError(t, src.AddCredential(cred))
+	require.NoError(t, src.AddHost(NewHostWithCredential("db", "db", "10.0.0.5", "admin")))
+
+	dst, _ := setupTestManager(t)
+	result, err := MoveHost(src, dst, "db", MoveOptions{ExportOptions: ExportOptions{Anonymize: true}})
+	require.NoError(t, err)
+
+	assert.True(t, result.CredentialInlined)
+	h, _ := dst.GetHost("db")
+	assert.Equal(t, "root", h.User)
+	assert.Empty(t, h.CredentialID)
+	assert.Empty(t, h.Password)
+}
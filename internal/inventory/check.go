@@ -0,0 +1,299 @@
+package inventory
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Ensure Check implements the interfaces
+var (
+	_ Entity = (*Check)(nil)
+)
+
+// Check is a compliance assertion: a command whose exit status and output must
+// match the expectations on every host it is attached to.
+type Check struct {
+	Type        DocumentType `yaml:"type"`
+	ID          string       `yaml:"id"`
+	Name        string       `yaml:"name"`
+	Description string       `yaml:"description,omitempty"`
+
+	Command string `yaml:"command"`
+
+	// ExpectExit is the required exit status (default 0).
+	ExpectExit *int `yaml:"expect_exit,omitempty"`
+	// ExpectRegex must match standard output; RejectRegex must not.
+	ExpectRegex string `yaml:"expect_regex,omitempty"`
+	RejectRegex string `yaml:"reject_regex,omitempty"`
+
+	// HostIDs and Groups attach the check; group members include nested groups.
+	HostIDs []string `yaml:"hosts,omitempty"`
+	Groups  []string `yaml:"groups,omitempty"`
+}
+
+// NewCheck creates a new Check.
+func NewCheck(id, name, command string) *Check {
+	return &Check{
+		Type:    TypeCheck,
+		ID:      id,
+		Name:    name,
+		Command: command,
+	}
+}
+
+// GetID Identifiable interface implementation
+func (c *Check) GetID() string {
+	return c.ID
+}
+
+// GetName Nameable interface implementation
+func (c *Check) GetName() string {
+	return c.Name
+}
+
+func (c *Check) SetName(name string) {
+	c.Name = name
+}
+
+// GetDescription Describable interface implementation
+func (c *Check) GetDescription() string {
+	return c.Description
+}
+
+func (c *Check) SetDescription(desc string) {
+	c.Description = desc
+}
+
+// Validate checks if the Check has valid configuration.
+func (c *Check) Validate() error {
+	if c.ID == "" {
+		return fmt.Errorf("check ID cannot be empty")
+	}
+	if c.Name == "" {
+		return fmt.Errorf("check %s: name cannot be empty", c.ID)
+	}
+	if strings.TrimSpace(c.Command) == "" {
+		return fmt.Errorf("check %s: command cannot be empty", c.ID)
+	}
+	for _, re := range []string{c.ExpectRegex, c.RejectRegex} {
+		if re == "" {
+			continue
+		}
+		if _, err := regexp.Compile(re); err != nil {
+			return fmt.Errorf("check %s: invalid regex: %w", c.ID, err)
+		}
+	}
+	return nil
+}
+
+// Clone creates a deep copy of the Check.
+func (c *Check) Clone() interface{} {
+	clone := *c
+	if c.ExpectExit != nil {
+		v := *c.ExpectExit
+		clone.ExpectExit = &v
+	}
+	clone.HostIDs = append([]string(nil), c.HostIDs...)
+	clone.Groups = append([]string(nil), c.Groups...)
+	return &clone
+}
+
+// ExpectedExit returns the required exit status.
+func (c *Check) ExpectedExit() int {
+	if c.ExpectExit == nil {
+		return 0
+	}
+	return *c.ExpectExit
+}
+
+// Evaluate compares a command outcome with the expectations and returns why it
+// does not comply, or an empty string when it passes.
+func (c *Check) Evaluate(exitCode int, stdout string) string {
+	if want := c.ExpectedExit(); exitCode != want {
+		return fmt.Sprintf("exit status %d, expected %d", exitCode, want)
+	}
+	if c.ExpectRegex != "" {
+		if ok, _ := regexp.MatchString(c.ExpectRegex, stdout); !ok {
+			return fmt.Sprintf("output does not match %q", c.ExpectRegex)
+		}
+	}
+	if c.RejectRegex != "" {
+		if ok, _ := regexp.MatchString(c.RejectRegex, stdout); ok {
+			return fmt.Sprintf("output matches %q", c.RejectRegex)
+		}
+	}
+	return ""
+}
+
+// ===== Manager =====
+
+// GetCheck returns a copy of the check with the given ID.
+func (m *Manager) GetCheck(id string) (*Check, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, ok := m.checks[id]
+	if !ok {
+		return nil, false
+	}
+	return c.Clone().(*Check), true
+}
+
+// ListChecks returns copies of all checks sorted by ID.
+func (m *Manager) ListChecks() []*Check {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	checks := make([]*Check, 0, len(m.checks))
+	for _, id := range sortedKeys(m.checks) {
+		checks = append(checks, m.checks[id].Clone().(*Check))
+	}
+	return checks
+}
+
+// AddCheck validates and stores a new check. The ID policy may rewrite c.ID.
+func (m *Manager) AddCheck(c *Check) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, err := m.checkNewID(TypeCheck, c.ID)
+	if err != nil {
+		return err
+	}
+	c.ID = id
+
+	if _, exists := m.checks[c.ID]; exists {
+		return fmt.Errorf("check %s already exists", c.ID)
+	}
+	if err := m.checkCheckRefs(c); err != nil {
+		return err
+	}
+
+	stored := c.Clone().(*Check)
+	stored.Type = TypeCheck
+	return m.store(stored)
+}
+
+// UpdateCheck replaces an existing check and rewrites its file.
+func (m *Manager) UpdateCheck(c *Check) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.checks[c.ID]; !exists {
+		return fmt.Errorf("check %s not found", c.ID)
+	}
+	if err := m.checkCheckRefs(c); err != nil {
+		return err
+	}
+
+	stored := c.Clone().(*Check)
+	stored.Type = TypeCheck
+	m.checks[c.ID] = stored
+	return m.saveFile(m.sources[entityKey{TypeCheck, c.ID}])
+}
+
+// RemoveCheck deletes a check.
+func (m *Manager) RemoveCheck(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.checks[id]; !exists {
+		return fmt.Errorf("check %s not found", id)
+	}
+	if wid := m.workflowUsing(TypeCheck, id); wid != "" {
+		return fmt.Errorf("check %s is used by workflow %s", id, wid)
+	}
+	return m.saveFile(m.unregister(entityKey{TypeCheck, id}))
+}
+
+// ChecksFor returns copies of the checks attached to a host, directly or through
+// one of its groups, sorted by ID.
+func (m *Manager) ChecksFor(hostID string) []*Check {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	groups := map[string]bool{}
+	for _, name := range m.hostGroups(hostID) {
+		groups[name] = true
+	}
+
+	var checks []*Check
+	for _, id := range sortedKeys(m.checks) {
+		c := m.checks[id]
+		if containsString(c.HostIDs, hostID) || containsAny(c.Groups, groups) {
+			checks = append(checks, c.Clone().(*Check))
+		}
+	}
+	return checks
+}
+
+// checkCheckRefs verifies that the hosts and groups a check is attached to exist.
+// Caller must hold the lock.
+func (m *Manager) checkCheckRefs(c *Check) error {
+	for _, id := range c.HostIDs {
+		if _, ok := m.hosts[id]; !ok {
+			return fmt.Errorf("check %s: host %s not found", c.ID, id)
+		}
+	}
+	for _, name := range c.Groups {
+		if _, ok := m.groups[name]; !ok {
+			return fmt.Errorf("check %s: group %s not found", c.ID, name)
+		}
+	}
+	return nil
+}
+
+// updateCheckRefs renames (or, with an empty newID, drops) a host or group in every
+// check attached to it. Caller must hold the lock.
+func (m *Manager) updateCheckRefs(kind DocumentType, oldID, newID string, dirty map[string]bool) {
+	for _, c := range m.checks {
+		refs := &c.HostIDs
+		if kind == TypeGroup {
+			refs = &c.Groups
+		}
+
+		kept := (*refs)[:0]
+		changed := false
+		for _, id := range *refs {
+			if id != oldID {
+				kept = append(kept, id)
+				continue
+			}
+			changed = true
+			if newID != "" {
+				kept = append(kept, newID)
+			}
+		}
+		*refs = kept
+		if changed {
+			dirty[m.sources[keyOf(c)]] = true
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(list []string, set map[string]bool) bool {
+	for _, v := range list {
+		if set[v] {
+			return true
+		}
+	}
+	return false
+}
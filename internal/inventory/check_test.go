@@ -0,0 +1,102 @@
+package inventory
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecks(t *testing.T) {
+	setup := func(t *testing.T) (*Manager, string) {
+		m, dir := setupTestManager(t)
+		for _, id := range []string{"web1", "web2", "db1"} {
+			h := NewHost(id, id, "10.0.0.1")
+			h.User = "root"
+			require.NoError(t, m.AddHost(h))
+		}
+		web := NewGroup("web")
+		web.AddHost("web1")
+		web.AddHost("web2")
+		require.NoError(t, m.AddGroup(web))
+		return m, dir
+	}
+
+	t.Run("attach to hosts and groups", func(t *testing.T) {
+		m, dir := setup(t)
+
+		ntp := NewCheck("ntp", "NTP running", "systemctl is-active chronyd")
+		ntp.Groups = []string{"web"}
+		require.NoError(t, m.AddCheck(ntp))
+		assert.FileExists(t, filepath.Join(dir, "check-ntp.yaml"))
+
+		ssh := NewCheck("ssh-root", "No root login", "sshd -T")
+		ssh.ExpectRegex = `permitrootlogin no`
+		ssh.HostIDs = []string{"db1", "web1"}
+		require.NoError(t, m.AddCheck(ssh))
+
+		ids := func(checks []*Check) []string {
+			var out []string
+			for _, c := range checks {
+				out = append(out, c.ID)
+			}
+			return out
+		}
+		assert.Equal(t, []string{"ntp", "ssh-root"}, ids(m.ChecksFor("web1")))
+		assert.Equal(t, []string{"ntp"}, ids(m.ChecksFor("web2")))
+		assert.Equal(t, []string{"ssh-root"}, ids(m.ChecksFor("db1")))
+
+		reloaded := NewManager(dir)
+		require.NoError(t, reloaded.Load())
+		got, ok := reloaded.GetCheck("ssh-root")
+		require.True(t, ok)
+		assert.Equal(t, `permitrootlogin no`, got.ExpectRegex)
+	})
+
+	t.Run("references follow renames and removals", func(t *testing.T) {
+		m, _ := setup(t)
+
+		c := NewCheck("uptime", "Uptime", "uptime")
+		c.HostIDs = []string{"web1", "db1"}
+		c.Groups = []string{"web"}
+		require.NoError(t, m.AddCheck(c))
+
+		require.NoError(t, m.RenameHost("web1", "web01"))
+		require.NoError(t, m.RemoveHost("db1"))
+		require.NoError(t, m.RemoveGroup("web"))
+
+		got, _ := m.GetCheck("uptime")
+		assert.Equal(t, []string{"web01"}, got.HostIDs)
+		assert.Empty(t, got.Groups)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		m, _ := setup(t)
+
+		bad := NewCheck("bad", "Bad", "true")
+		bad.HostIDs = []string{"missing"}
+		assert.Error(t, m.AddCheck(bad))
+
+		bad.HostIDs = nil
+		bad.ExpectRegex = "("
+		assert.Error(t, m.AddCheck(bad))
+
+		assert.Error(t, m.AddCheck(NewCheck("empty", "Empty", "")))
+	})
+}
+
+func TestCheckEvaluate(t *testing.T) {
+	three := 3
+	c := NewCheck("c", "C", "true")
+	c.ExpectRegex = `active`
+	c.RejectRegex = `inactive`
+
+	assert.Empty(t, c.Evaluate(0, "active\n"))
+	assert.Contains(t, c.Evaluate(1, "active"), "exit status 1, expected 0")
+	assert.Contains(t, c.Evaluate(0, "failed"), "does not match")
+	assert.Contains(t, c.Evaluate(0, "inactive"), "matches")
+
+	c.ExpectExit = &three
+	assert.Empty(t, c.Evaluate(3, "active"))
+}
@@ -0,0 +1,97 @@
+package inventory
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// AddressQuery matches host addresses against a comma-separated list of networks
+// in CIDR notation ("10.0.0.0/16,fd00::/8"); a plain IP address matches itself.
+// Addresses are compared as parsed IPs, so "10.0.0.0/16" does not match 10.0.100.1
+// by string prefix accident but does match "10.0.255.7". Hosts whose address is a
+// DNS name never match; names are not resolved.
+type AddressQuery struct {
+	prefixes []netip.Prefix
+}
+
+// ParseAddressQuery parses a list of networks such as "10.0.0.0/16,192.168.1.5".
+func ParseAddressQuery(query string) (*AddressQuery, error) {
+	q := &AddressQuery{}
+	for _, part := range strings.Split(query, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		p, err := parseAddressPrefix(part)
+		if err != nil {
+			return nil, err
+		}
+		q.prefixes = append(q.prefixes, p)
+	}
+	if len(q.prefixes) == 0 {
+		return nil, fmt.Errorf("empty address query")
+	}
+	return q, nil
+}
+
+// parseAddressPrefix parses a CIDR network or a single IP address.
+func parseAddressPrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid network %q: %w", s, err)
+		}
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid address %q: %w", s, err)
+	}
+	addr = addr.Unmap().WithZone("")
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Matches reports whether the host's address lies in any of the networks.
+func (q *AddressQuery) Matches(h *Host) bool {
+	addr, ok := h.IPAddress()
+	if !ok {
+		return false
+	}
+	for _, p := range q.prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPAddress returns the host's address as an IP, unmapping IPv4-mapped IPv6
+// addresses and dropping zones. It reports false for DNS names and local hosts.
+func (h *Host) IPAddress() (netip.Addr, bool) {
+	if h.IsLocal() {
+		return netip.Addr{}, false
+	}
+	addr, err := netip.ParseAddr(strings.Trim(h.Address, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// FindHostsByCIDR returns copies of the hosts whose address lies in one of the
+// networks of an address query (see AddressQuery), sorted by ID.
+func (m *Manager) FindHostsByCIDR(cidr string) ([]*Host, error) {
+	q, err := ParseAddressQuery(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []*Host
+	for _, h := range m.ListHosts() {
+		if q.Matches(h) {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts, nil
+}
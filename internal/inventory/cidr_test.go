@@ -0,0 +1,94 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAddressQuery(t *testing.T) {
+	for _, query := range []string{"10.0.0.0/16", "10.0.0.1", "fd00::/8, 192.168.1.0/24", "::ffff:10.0.0.1"} {
+		t.Run(query, func(t *testing.T) {
+			_, err := ParseAddressQuery(query)
+			assert.NoError(t, err)
+		})
+	}
+	for _, query := range []string{"", ",", "10.0.0.0/33", "10.0", "web01", "10.0.0.0/16,web01"} {
+		t.Run("invalid "+query, func(t *testing.T) {
+			_, err := ParseAddressQuery(query)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestFindHostsByCIDR(t *testing.T) {
+	m, dir := setupTestManager(t)
+	writeTestFile(t, dir, "hosts.yaml", `type: host
+id: a
+name: a
+address: 10.0.1.5
+user: deploy
+---
+type: host
+id: b
+name: b
+address: 10.0.100.1
+user: deploy
+---
+type: host
+id: c
+name: c
+address: 10.1.0.1
+user: deploy
+---
+type: host
+id: d
+name: d
+address: "::ffff:10.0.2.2"
+user: deploy
+---
+type: host
+id: e
+name: e
+address: 10.0.example.com
+user: deploy
+---
+type: host
+id: f
+name: f
+address: fd00::1
+user: deploy
+`)
+	require.NoError(t, m.Load())
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"10.0.0.0/16", []string{"a", "b", "d"}},
+		{"10.0.1.0/24", []string{"a"}},
+		{"10.0.0.0/8", []string{"a", "b", "c", "d"}},
+		{"10.0.1.5", []string{"a"}},
+		{"10.0.1.77/16", []string{"a", "b", "d"}},
+		{"fd00::/8", []string{"f"}},
+		{"10.1.0.0/16, fd00::/8", []string{"c", "f"}},
+		{"192.168.0.0/16", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			hosts, err := m.FindHostsByCIDR(tt.query)
+			require.NoError(t, err)
+			var ids []string
+			for _, h := range hosts {
+				ids = append(ids, h.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+
+	t.Run("rejects string prefixes", func(t *testing.T) {
+		_, err := m.FindHostsByCIDR("10.0.")
+		assert.Error(t, err)
+	})
+}
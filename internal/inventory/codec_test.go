@@ -0,0 +1,83 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// base64Codec stands in for real encryption in tests.
+type base64Codec struct{}
+
+func (base64Codec) Extension() string { return ".b64" }
+
+func (base64Codec) Encode(plain []byte) ([]byte, error) {
+	return []byte(base64.StdEncoding.EncodeToString(plain)), nil
+}
+
+func (base64Codec) Decode(data []byte) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+}
+
+func TestCredentialCodec(t *testing.T) {
+	m, dir := setupTestManager(t)
+	m.SetCredentialCodec(base64Codec{})
+
+	cred := NewCredential("admin", "Admin", "root")
+	cred.Password = "secret"
+	require.NoError(t, m.AddCredential(cred))
+
+	path := filepath.Join(dir, "credential-admin.yaml.b64")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	t.Run("reload decodes", func(t *testing.T) {
+		reloaded := NewManager(dir)
+		reloaded.RegisterCodec(base64Codec{})
+		require.NoError(t, reloaded.Load())
+
+		c, ok := reloaded.GetCredential("admin")
+		require.True(t, ok)
+		assert.Equal(t, "secret", c.Password)
+	})
+
+	t.Run("encoded files are ignored without codec", func(t *testing.T) {
+		plain := NewManager(dir)
+		require.NoError(t, plain.Load())
+		assert.Empty(t, plain.ListCredentials())
+	})
+
+	t.Run("rename keeps extension", func(t *testing.T) {
+		require.NoError(t, m.RenameCredential("admin", "root"))
+		assert.FileExists(t, filepath.Join(dir, "credential-root.yaml.b64"))
+		assert.NoFileExists(t, path)
+	})
+}
+
+func TestEncryptCredentials(t *testing.T) {
+	m, dir := setupTestManager(t)
+	writeTestFile(t, dir, "credential-a.yaml", "type: credential\nid: a\nname: a\nuser: u\npassword: p\n")
+	writeTestFile(t, dir, "shared.yaml", "type: credential\nid: b\nname: b\nuser: u\npassword: p\n---\ntype: group\nname: g\n")
+	require.NoError(t, m.Load())
+
+	_, err := m.EncryptCredentials()
+	assert.Error(t, err, "requires a codec")
+
+	m.SetCredentialCodec(base64Codec{})
+	skipped, err := m.EncryptCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, skipped)
+
+	assert.NoFileExists(t, filepath.Join(dir, "credential-a.yaml"))
+	assert.FileExists(t, filepath.Join(dir, "credential-a.yaml.b64"))
+}
@@ -0,0 +1,164 @@
+package inventory
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CommandPolicy restricts which commands may be run on which hosts.
+type CommandPolicy struct {
+	Rules []CommandRule `yaml:"rules,omitempty"`
+}
+
+// CommandRule blocks commands matching Deny and, when Allow is set, every command
+// not matching Allow. A rule without Groups and Tags applies to all hosts.
+type CommandRule struct {
+	Name string `yaml:"name"`
+
+	// Groups and Tags select the hosts the rule applies to. Group membership
+	// includes nested child groups.
+	Groups []string `yaml:"groups,omitempty"`
+	Tags   []string `yaml:"tags,omitempty"`
+
+	// Deny and Allow are regular expressions matched against the whole command.
+	Deny  []string `yaml:"deny,omitempty"`
+	Allow []string `yaml:"allow,omitempty"`
+}
+
+// DefaultDenyPatterns are commonly destructive commands, suitable as a starting
+// point for a deny list.
+var DefaultDenyPatterns = []string{
+	`\brm\s+(-\S+\s+)*/\*?(\s|$)`,
+	`\bmkfs(\.\w+)?\b`,
+	`\bdd\s+.*\bof=/dev/`,
+	`:\(\)\s*\{\s*:\|:&\s*\};:`,
+	`\b(shutdown|reboot|halt|poweroff)\b`,
+	`>\s*/dev/[sh]d[a-z]\b`,
+}
+
+// CommandViolation describes a command blocked by a rule on one host.
+type CommandViolation struct {
+	HostID  string `yaml:"host_id" json:"host_id"`
+	Rule    string `yaml:"rule" json:"rule"`
+	Pattern string `yaml:"pattern,omitempty" json:"pattern,omitempty"` // empty when the command matched none of the allowed patterns
+}
+
+func (v CommandViolation) String() string {
+	if v.Pattern == "" {
+		return fmt.Sprintf("host %s: command not allowed by rule %s", v.HostID, v.Rule)
+	}
+	return fmt.Sprintf("host %s: command denied by rule %s (%s)", v.HostID, v.Rule, v.Pattern)
+}
+
+// IsEmpty reports whether the policy has no rules.
+func (p *CommandPolicy) IsEmpty() bool {
+	return p == nil || len(p.Rules) == 0
+}
+
+// Validate checks that every rule is named and all patterns compile.
+func (p *CommandPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	for i, r := range p.Rules {
+		if r.Name == "" {
+			return fmt.Errorf("command rule %d: name cannot be empty", i)
+		}
+		for _, pattern := range append(append([]string(nil), r.Deny...), r.Allow...) {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("command rule %s: invalid pattern %q: %w", r.Name, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// appliesTo reports whether the rule selects a host in the given groups.
+func (r *CommandRule) appliesTo(h *Host, groups []string) bool {
+	if len(r.Groups) == 0 && len(r.Tags) == 0 {
+		return true
+	}
+	for _, g := range r.Groups {
+		for _, name := range groups {
+			if g == name {
+				return true
+			}
+		}
+	}
+	for _, tag := range r.Tags {
+		if h.HasTag(NormalizeTag(tag)) {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns the violations of a command on a host. Invalid patterns never match;
+// Validate reports them.
+func (r *CommandRule) check(h *Host, command string) []CommandViolation {
+	command = strings.TrimSpace(command)
+
+	var violations []CommandViolation
+	for _, pattern := range r.Deny {
+		if re, err := regexp.Compile(pattern); err == nil && re.MatchString(command) {
+			violations = append(violations, CommandViolation{HostID: h.ID, Rule: r.Name, Pattern: pattern})
+		}
+	}
+
+	if len(r.Allow) > 0 {
+		allowed := false
+		for _, pattern := range r.Allow {
+			if re, err := regexp.Compile(pattern); err == nil && re.MatchString(command) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			violations = append(violations, CommandViolation{HostID: h.ID, Rule: r.Name})
+		}
+	}
+	return violations
+}
+
+func (p CommandPolicy) clone() CommandPolicy {
+	c := CommandPolicy{Rules: make([]CommandRule, len(p.Rules))}
+	for i, r := range p.Rules {
+		c.Rules[i] = CommandRule{
+			Name:   r.Name,
+			Groups: append([]string(nil), r.Groups...),
+			Tags:   append([]string(nil), r.Tags...),
+			Deny:   append([]string(nil), r.Deny...),
+			Allow:  append([]string(nil), r.Allow...),
+		}
+	}
+	if len(c.Rules) == 0 {
+		c.Rules = nil
+	}
+	return c
+}
+
+// CheckCommand returns the violations of running command on the given hosts.
+func (m *Manager) CheckCommand(p *CommandPolicy, hostIDs []string, command string) ([]CommandViolation, error) {
+	if p.IsEmpty() {
+		return nil, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var violations []CommandViolation
+	for _, id := range hostIDs {
+		h, ok := m.hosts[id]
+		if !ok {
+			return nil, fmt.Errorf("host %s not found", id)
+		}
+		groups := m.hostGroups(id)
+		for i := range p.Rules {
+			if p.Rules[i].appliesTo(h, groups) {
+				violations = append(violations, p.Rules[i].check(h, command)...)
+			}
+		}
+	}
+	return violations, nil
+}
@@ -4,7 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sync"
+	"time"
+
+	"gossher/internal/i18n"
+	"gossher/internal/term"
 
 	"gopkg.in/yaml.v3"
 )
@@ -18,6 +23,70 @@ type Config struct {
 	DefaultSSHPort int          `yaml:"default_ssh_port"`
 	SSHTimeout     int          `yaml:"ssh_timeout"`
 
+	// Timeouts are the global defaults of command executions; see Timeouts for the
+	// precedence of the levels. An unset connect timeout falls back to SSHTimeout.
+	Timeouts Timeouts `yaml:"timeouts,omitempty"`
+
+	// IdleTimeout locks the application and closes idle sessions and tunnels after
+	// this many seconds without activity. Zero disables auto-lock.
+	IdleTimeout int `yaml:"idle_timeout,omitempty"`
+
+	// TunnelIdleTimeout closes port forwards and SOCKS proxies that carried no
+	// traffic for this many seconds, independently of IdleTimeout. Zero keeps them open.
+	TunnelIdleTimeout int `yaml:"tunnel_idle_timeout,omitempty"`
+
+	// TransparentProxy allows transparent tunnels, which route whole subnets
+	// through a host by changing the local firewall rules and need root privileges.
+	// They carry TCP and DNS only: there is no tun device, so other UDP and ICMP
+	// are not proxied.
+	TransparentProxy bool `yaml:"transparent_proxy,omitempty"`
+
+	// StrictYAML rejects unknown keys in config and data files instead of ignoring them.
+	StrictYAML bool `yaml:"strict_yaml,omitempty"`
+
+	// PlainOutput is the accessibility mode for screen readers: no colors, padding,
+	// spinners or full-screen interface (see package term).
+	PlainOutput bool `yaml:"plain_output,omitempty"`
+
+	// Permissions sets the modes of the data directory and of the files written to it.
+	Permissions FilePermissions `yaml:"permissions,omitempty"`
+
+	TagPolicy TagPolicy `yaml:"tag_policy,omitempty"`
+	IDPolicy  IDPolicy  `yaml:"id_policy,omitempty"`
+
+	// InlineAuthPolicy allows, warns about or forbids hosts with their own key path
+	// or password instead of a credential.
+	InlineAuthPolicy InlineAuthPolicy `yaml:"inline_auth_policy,omitempty"`
+
+	// CommandPolicy blocks dangerous commands in the executor unless forced.
+	CommandPolicy CommandPolicy `yaml:"command_policy,omitempty"`
+
+	// Executor tunes the concurrency and memory use of command executions.
+	Executor ExecutorTuning `yaml:"executor,omitempty"`
+
+	// Telemetry opts in to anonymous usage statistics; off unless enabled here.
+	Telemetry TelemetryConfig `yaml:"telemetry,omitempty"`
+
+	// GPGRecipients encrypts credentials of the default profile for these key IDs.
+	GPGRecipients []string `yaml:"gpg_recipients,omitempty"`
+
+	// Lifecycle marks hosts stale and retired when they are not reached for a while;
+	// see Manager.UpdateLifecycle.
+	Lifecycle LifecyclePolicy `yaml:"lifecycle,omitempty"`
+
+	// Discovery lists the cloud accounts the default profile imports hosts from.
+	Discovery []DiscoverySource `yaml:"discovery,omitempty"`
+
+	// Profiles maps additional profile names to their settings.
+	Profiles map[string]Profile `yaml:"profiles,omitempty"`
+
+	// Networks defines the networks, such as VPNs, that hosts and groups name in
+	// requires_network.
+	Networks map[string]NetworkRequirement `yaml:"networks,omitempty"`
+
+	// Netbox imports devices and virtual machines from a Netbox instance.
+	Netbox NetboxConfig `yaml:"netbox,omitempty"`
+
 	// Runtime - not saved
 	BaseDir    string `yaml:"-"`
 	ConfigPath string `yaml:"-"`
@@ -40,6 +109,7 @@ func Default() *Config {
 		Language:       "en",
 		DefaultSSHPort: 22,
 		SSHTimeout:     30,
+		IDPolicy:       IDPolicyNormalize,
 		BaseDir:        baseDir,
 		ConfigPath:     filepath.Join(baseDir, "config.yaml"),
 	}
@@ -60,7 +130,7 @@ func Load() error {
 	var cfg *Config
 
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		if err := os.MkdirAll(baseDir, 0755); err != nil {
+		if err := os.MkdirAll(baseDir, DefaultDirMode); err != nil {
 			return fmt.Errorf("failed to create base directory: %w", err)
 		}
 
@@ -73,12 +143,8 @@ func Load() error {
 		if err != nil {
 			return fmt.Errorf("failed to read config: %w", err)
 		}
-
-		cfg = &Config{
-			BaseDir: baseDir,
-		}
-		if err := yaml.Unmarshal(data, cfg); err != nil {
-			return fmt.Errorf("failed to parse config: %w", err)
+		if cfg, err = parseConfig(configPath, baseDir, data); err != nil {
+			return err
 		}
 	}
 
@@ -86,12 +152,32 @@ func Load() error {
 	globalConfig = cfg
 	configMutex.Unlock()
 
+	// An unsupported language keeps messages in English.
+	i18n.SetLanguage(cfg.Language)
+	term.SetPlain(cfg.PlainOutput)
 	return nil
 }
 
+// parseConfig decodes the content of the config file at path.
+func parseConfig(path, baseDir string, data []byte) (*Config, error) {
+	cfg := &Config{
+		BaseDir:    baseDir,
+		ConfigPath: path,
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", NewParseError(path, data, 0, err))
+	}
+	if cfg.StrictYAML {
+		if err := StrictUnmarshal(data, &Config{}); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %w", NewParseError(path, data, 0, err))
+		}
+	}
+	return cfg, nil
+}
+
 // saveConfig saves the configuration to file.
 func saveConfig(cfg *Config) error {
-	if err := os.MkdirAll(cfg.BaseDir, 0755); err != nil {
+	if err := os.MkdirAll(cfg.BaseDir, cfg.Permissions.DirMode()); err != nil {
 		return fmt.Errorf("failed to create base directory: %w", err)
 	}
 
@@ -100,7 +186,18 @@ func saveConfig(cfg *Config) error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(cfg.ConfigPath, data, 0644); err != nil {
+	// Replace the file in one step so Watch never reads it half-written; a
+	// symlinked config.yaml is replaced at its target.
+	path := cfg.ConfigPath
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, data, cfg.Permissions.FileMode()); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
 		return fmt.Errorf("failed to write config: %w", err)
 	}
 
@@ -130,16 +227,21 @@ func GetDataDir() string {
 	if globalConfig == nil {
 		panic("Config not loaded. Call config.MustLoad() at application startup.")
 	}
+	return globalConfig.dataDir()
+}
 
-	if globalConfig.DataDir == "" {
-		return globalConfig.BaseDir
+// dataDir resolves the data directory against the base directory.
+func (c *Config) dataDir() string {
+	if c.DataDir == "" {
+		return c.BaseDir
 	}
 
-	if !filepath.IsAbs(globalConfig.DataDir) {
-		return filepath.Join(globalConfig.BaseDir, globalConfig.DataDir)
+	dataDir := ExpandPath(c.DataDir)
+	if !filepath.IsAbs(dataDir) {
+		return filepath.Join(c.BaseDir, dataDir)
 	}
 
-	return globalConfig.DataDir
+	return dataDir
 }
 
 // GetTheme returns the configured theme.
@@ -186,6 +288,197 @@ func GetSSHTimeout() int {
 	return globalConfig.SSHTimeout
 }
 
+// GetTimeouts returns the global execution timeouts, with the connect timeout
+// defaulting to the SSH timeout.
+func GetTimeouts() Timeouts {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+	t := globalConfig.Timeouts
+	if t.Connect == 0 {
+		t.Connect = time.Duration(globalConfig.SSHTimeout) * time.Second
+	}
+	return t
+}
+
+// GetIdleTimeout returns the auto-lock timeout in seconds, 0 when disabled.
+func GetIdleTimeout() int {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+	return globalConfig.IdleTimeout
+}
+
+// GetTunnelIdleTimeout returns the tunnel inactivity timeout in seconds, 0 when disabled.
+func GetTunnelIdleTimeout() int {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+	return globalConfig.TunnelIdleTimeout
+}
+
+// GetTransparentProxy reports whether transparent tunnels are allowed.
+func GetTransparentProxy() bool {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+	return globalConfig.TransparentProxy
+}
+
+// GetStrictYAML reports whether unknown keys in YAML files are rejected.
+func GetStrictYAML() bool {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+	return globalConfig.StrictYAML
+}
+
+// GetPlainOutput reports whether the plain accessibility output mode is on.
+func GetPlainOutput() bool {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+	return globalConfig.PlainOutput
+}
+
+// GetPermissions returns the configured file permissions.
+func GetPermissions() FilePermissions {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+	return globalConfig.Permissions
+}
+
+// GetTagPolicy returns a copy of the configured tag policy.
+func GetTagPolicy() TagPolicy {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+	return globalConfig.TagPolicy.clone()
+}
+
+// GetIDPolicy returns the configured ID normalization policy.
+func GetIDPolicy() IDPolicy {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+	if globalConfig.IDPolicy == "" {
+		return IDPolicyNone
+	}
+	return globalConfig.IDPolicy
+}
+
+// GetInlineAuthPolicy returns the configured inline authentication policy.
+func GetInlineAuthPolicy() InlineAuthPolicy {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+	if globalConfig.InlineAuthPolicy == "" {
+		return InlineAuthAllow
+	}
+	return globalConfig.InlineAuthPolicy
+}
+
+// GetCommandPolicy returns a copy of the configured command policy.
+func GetCommandPolicy() CommandPolicy {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+	return globalConfig.CommandPolicy.clone()
+}
+
+// GetLifecyclePolicy returns the host lifecycle policy.
+func GetLifecyclePolicy() LifecyclePolicy {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+	return globalConfig.Lifecycle
+}
+
+// GetExecutorTuning returns the executor performance knobs.
+func GetExecutorTuning() ExecutorTuning {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+	return globalConfig.Executor
+}
+
+// GetTelemetry returns the telemetry settings.
+func GetTelemetry() TelemetryConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+	return globalConfig.Telemetry
+}
+
+// GetNetbox returns a copy of the Netbox settings.
+func GetNetbox() NetboxConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+	return globalConfig.Netbox.clone()
+}
+
+// GetNetworks returns a copy of the network definitions.
+func GetNetworks() map[string]NetworkRequirement {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+	networks := make(map[string]NetworkRequirement, len(globalConfig.Networks))
+	for name, n := range globalConfig.Networks {
+		networks[name] = n
+	}
+	return networks
+}
+
 // ===== Setters =====
 
 // SetDataDir updates the data directory and saves the config.
@@ -214,8 +507,13 @@ func SetTheme(theme string) error {
 	return Save()
 }
 
-// SetLanguage updates the language and saves the config.
+// SetLanguage selects the language of translated messages and saves the config.
+// The language must be one of i18n.Languages.
 func SetLanguage(lang string) error {
+	if err := i18n.SetLanguage(lang); err != nil {
+		return err
+	}
+
 	configMutex.Lock()
 	if globalConfig == nil {
 		configMutex.Unlock()
@@ -261,6 +559,234 @@ func SetSSHTimeout(timeout int) error {
 	return Save()
 }
 
+// SetTimeouts updates the global execution timeouts and saves the config.
+func SetTimeouts(t Timeouts) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+	globalConfig.Timeouts = t
+	configMutex.Unlock()
+
+	return Save()
+}
+
+// SetIdleTimeout updates the auto-lock timeout and saves the config. Zero disables it.
+func SetIdleTimeout(timeout int) error {
+	if timeout < 0 {
+		return fmt.Errorf("invalid idle timeout: %d", timeout)
+	}
+
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+	globalConfig.IdleTimeout = timeout
+	configMutex.Unlock()
+
+	return Save()
+}
+
+// SetTunnelIdleTimeout updates the tunnel inactivity timeout and saves the config.
+// Zero disables it.
+func SetTunnelIdleTimeout(timeout int) error {
+	if timeout < 0 {
+		return fmt.Errorf("invalid tunnel idle timeout: %d", timeout)
+	}
+
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+	globalConfig.TunnelIdleTimeout = timeout
+	configMutex.Unlock()
+
+	return Save()
+}
+
+// SetTransparentProxy allows or forbids transparent tunnels and saves the config.
+func SetTransparentProxy(on bool) error {
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+	globalConfig.TransparentProxy = on
+	configMutex.Unlock()
+
+	return Save()
+}
+
+// SetStrictYAML enables or disables strict YAML decoding and saves the config.
+func SetStrictYAML(strict bool) error {
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+	globalConfig.StrictYAML = strict
+	configMutex.Unlock()
+
+	return Save()
+}
+
+// SetPlainOutput switches the plain accessibility output mode on or off and saves
+// the config.
+func SetPlainOutput(on bool) error {
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+	globalConfig.PlainOutput = on
+	configMutex.Unlock()
+
+	term.SetPlain(on)
+	return Save()
+}
+
+// SetPermissions validates and updates the file permissions and saves the config.
+func SetPermissions(p FilePermissions) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+	globalConfig.Permissions = p
+	configMutex.Unlock()
+
+	return Save()
+}
+
+// SetTagPolicy validates and updates the tag policy and saves the config.
+func SetTagPolicy(policy TagPolicy) error {
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+	globalConfig.TagPolicy = policy.clone()
+	configMutex.Unlock()
+
+	return Save()
+}
+
+// SetIDPolicy updates the ID normalization policy and saves the config.
+func SetIDPolicy(policy IDPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+	globalConfig.IDPolicy = policy
+	configMutex.Unlock()
+
+	return Save()
+}
+
+// SetInlineAuthPolicy updates the inline authentication policy and saves the config.
+func SetInlineAuthPolicy(policy InlineAuthPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+	globalConfig.InlineAuthPolicy = policy
+	configMutex.Unlock()
+
+	return Save()
+}
+
+// SetCommandPolicy validates and updates the command policy and saves the config.
+func SetCommandPolicy(policy CommandPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+	globalConfig.CommandPolicy = policy.clone()
+	configMutex.Unlock()
+
+	return Save()
+}
+
+// SetNetbox validates and updates the Netbox settings and saves the config.
+func SetNetbox(c NetboxConfig) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+	globalConfig.Netbox = c.clone()
+	configMutex.Unlock()
+
+	return Save()
+}
+
+// SetNetworks replaces the network definitions and saves the config.
+func SetNetworks(networks map[string]NetworkRequirement) error {
+	if err := validateNetworks(networks); err != nil {
+		return err
+	}
+
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+	globalConfig.Networks = make(map[string]NetworkRequirement, len(networks))
+	for name, n := range networks {
+		globalConfig.Networks[name] = n
+	}
+	configMutex.Unlock()
+
+	return Save()
+}
+
+// SetTelemetry updates the telemetry settings and saves the config.
+func SetTelemetry(c TelemetryConfig) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+	globalConfig.Telemetry = c
+	configMutex.Unlock()
+
+	return Save()
+}
+
 // ===== Batch Update =====
 
 // Update allows updating multiple fields atomically.
@@ -278,6 +804,8 @@ func Update(fn func(*ConfigEditor) error) error {
 		return err
 	}
 
+	i18n.SetLanguage(globalConfig.Language)
+	term.SetPlain(globalConfig.PlainOutput)
 	return saveConfig(globalConfig)
 }
 
@@ -319,15 +847,157 @@ func (e *ConfigEditor) SetSSHTimeout(timeout int) error {
 	return nil
 }
 
+// SetTimeouts sets the global execution timeouts.
+func (e *ConfigEditor) SetTimeouts(t Timeouts) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	e.cfg.Timeouts = t
+	return nil
+}
+
+// SetIdleTimeout sets the auto-lock timeout.
+func (e *ConfigEditor) SetIdleTimeout(timeout int) error {
+	if timeout < 0 {
+		return fmt.Errorf("invalid idle timeout: %d", timeout)
+	}
+	e.cfg.IdleTimeout = timeout
+	return nil
+}
+
+// SetTunnelIdleTimeout sets the tunnel inactivity timeout.
+func (e *ConfigEditor) SetTunnelIdleTimeout(timeout int) error {
+	if timeout < 0 {
+		return fmt.Errorf("invalid tunnel idle timeout: %d", timeout)
+	}
+	e.cfg.TunnelIdleTimeout = timeout
+	return nil
+}
+
+// SetTransparentProxy allows or forbids transparent tunnels.
+func (e *ConfigEditor) SetTransparentProxy(on bool) {
+	e.cfg.TransparentProxy = on
+}
+
+// SetStrictYAML enables or disables strict YAML decoding.
+func (e *ConfigEditor) SetStrictYAML(strict bool) {
+	e.cfg.StrictYAML = strict
+}
+
+// SetPlainOutput switches the plain accessibility output mode on or off.
+func (e *ConfigEditor) SetPlainOutput(on bool) {
+	e.cfg.PlainOutput = on
+}
+
+// SetPermissions sets the file permissions.
+func (e *ConfigEditor) SetPermissions(p FilePermissions) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	e.cfg.Permissions = p
+	return nil
+}
+
+// SetTagPolicy sets the tag policy.
+func (e *ConfigEditor) SetTagPolicy(policy TagPolicy) {
+	e.cfg.TagPolicy = policy.clone()
+}
+
+// SetIDPolicy sets the ID normalization policy.
+func (e *ConfigEditor) SetIDPolicy(policy IDPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	e.cfg.IDPolicy = policy
+	return nil
+}
+
+// SetInlineAuthPolicy sets the inline authentication policy.
+func (e *ConfigEditor) SetInlineAuthPolicy(policy InlineAuthPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	e.cfg.InlineAuthPolicy = policy
+	return nil
+}
+
+// SetCommandPolicy sets the command policy.
+func (e *ConfigEditor) SetCommandPolicy(policy CommandPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	e.cfg.CommandPolicy = policy.clone()
+	return nil
+}
+
+// SetLifecyclePolicy sets the host lifecycle policy.
+func (e *ConfigEditor) SetLifecyclePolicy(p LifecyclePolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	e.cfg.Lifecycle = p
+	return nil
+}
+
+// SetExecutorTuning sets the executor performance knobs.
+func (e *ConfigEditor) SetExecutorTuning(t ExecutorTuning) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	e.cfg.Executor = t
+	return nil
+}
+
+// SetNetbox sets the Netbox settings.
+func (e *ConfigEditor) SetNetbox(c NetboxConfig) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	e.cfg.Netbox = c.clone()
+	return nil
+}
+
+// SetNetworks replaces the network definitions.
+func (e *ConfigEditor) SetNetworks(networks map[string]NetworkRequirement) error {
+	if err := validateNetworks(networks); err != nil {
+		return err
+	}
+	e.cfg.Networks = make(map[string]NetworkRequirement, len(networks))
+	for name, n := range networks {
+		e.cfg.Networks[name] = n
+	}
+	return nil
+}
+
+// SetTelemetry sets the telemetry settings.
+func (e *ConfigEditor) SetTelemetry(c TelemetryConfig) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	e.cfg.Telemetry = c
+	return nil
+}
+
 // ===== Helper Functions =====
 
-// defaultBaseDir returns the default base directory (~/.gossher).
+// defaultBaseDir returns the default base directory: ~/.gossher, or %APPDATA%\Gossher
+// on Windows unless %USERPROFILE%\.gossher already exists.
 func defaultBaseDir() string {
 	homeDir, err := os.UserHomeDir()
+	legacy := filepath.Join(homeDir, ".gossher")
+
+	if runtime.GOOS == "windows" {
+		if _, statErr := os.Stat(legacy); err != nil || statErr != nil {
+			if configDir, err := os.UserConfigDir(); err == nil {
+				return filepath.Join(configDir, "Gossher")
+			}
+		}
+	}
+
 	if err != nil {
 		return ".gossher"
 	}
-	return filepath.Join(homeDir, ".gossher")
+	return legacy
 }
 
 // ConfigSnapshot represents a read-only snapshot of configuration.
@@ -337,6 +1007,9 @@ type ConfigSnapshot struct {
 	Language       string
 	DefaultSSHPort int
 	SSHTimeout     int
+	IdleTimeout    int
+	StrictYAML     bool
+	PlainOutput    bool
 }
 
 // GetSnapshot returns a read-only copy of the current configuration.
@@ -354,5 +1027,27 @@ func GetSnapshot() ConfigSnapshot {
 		Language:       globalConfig.Language,
 		DefaultSSHPort: globalConfig.DefaultSSHPort,
 		SSHTimeout:     globalConfig.SSHTimeout,
+		IdleTimeout:    globalConfig.IdleTimeout,
+		StrictYAML:     globalConfig.StrictYAML,
+		PlainOutput:    globalConfig.PlainOutput,
 	}
 }
+
+// SanitizedConfig returns a copy of the loaded configuration with secrets, such as
+// the Netbox token, masked, e.g. for bug reports.
+func SanitizedConfig() *Config {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+	c := cloneConfig(globalConfig)
+	if c.Netbox.Token != "" {
+		c.Netbox.Token = sanitizedMask
+	}
+	return c
+}
+
+// sanitizedMask replaces secrets in SanitizedConfig; it matches redact.Mask.
+const sanitizedMask = "[REDACTED]"
@@ -0,0 +1,215 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gossher/internal/i18n"
+	"gossher/internal/term"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigWatchInterval is how often Watch checks config.yaml for changes.
+const DefaultConfigWatchInterval = time.Second
+
+// ConfigChange describes a change of the configuration.
+type ConfigChange struct {
+	// Old and New are copies of the configuration before and after the change.
+	Old *Config
+	New *Config
+	// Fields lists the YAML keys of the changed settings, e.g. "theme" or "timeouts".
+	Fields []string
+}
+
+// Changed reports whether the setting with the given YAML key changed.
+func (c ConfigChange) Changed(field string) bool {
+	for _, f := range c.Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Config change subscribers
+var (
+	watchMu   sync.Mutex
+	watchNext int
+	watchSubs = map[int]func(ConfigChange){}
+)
+
+// SubscribeConfig registers a function called after the configuration was reloaded
+// with changed settings, e.g. to switch the theme or pass new timeouts to the
+// executor. The returned function removes the subscription.
+func SubscribeConfig(fn func(ConfigChange)) (unsubscribe func()) {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+
+	id := watchNext
+	watchNext++
+	watchSubs[id] = fn
+	return func() {
+		watchMu.Lock()
+		defer watchMu.Unlock()
+		delete(watchSubs, id)
+	}
+}
+
+// notifyConfig calls the subscribers in subscription order.
+func notifyConfig(c ConfigChange) {
+	watchMu.Lock()
+	ids := make([]int, 0, len(watchSubs))
+	for id := range watchSubs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	subs := make([]func(ConfigChange), 0, len(ids))
+	for _, id := range ids {
+		subs = append(subs, watchSubs[id])
+	}
+	watchMu.Unlock()
+
+	for _, fn := range subs {
+		fn(c)
+	}
+}
+
+// Watch reloads config.yaml whenever it changes on disk, checking every interval
+// (default DefaultConfigWatchInterval), and notifies the SubscribeConfig
+// subscribers of changed settings until ctx is done. Changes saved by this process
+// are picked up the same way. A file that fails to parse leaves the configuration
+// unchanged and is reported to onError, which may be nil.
+func Watch(ctx context.Context, interval time.Duration, onError func(error)) error {
+	if interval <= 0 {
+		interval = DefaultConfigWatchInterval
+	}
+
+	configMutex.RLock()
+	if globalConfig == nil {
+		configMutex.RUnlock()
+		return fmt.Errorf("config not loaded")
+	}
+	path, baseDir := globalConfig.ConfigPath, globalConfig.BaseDir
+	configMutex.RUnlock()
+
+	// The last configuration read from disk; changes are reported against it so
+	// saves of this process are noticed too.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	prev, err := parseConfig(path, baseDir, data)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		current, err := os.ReadFile(path)
+		if err != nil || bytes.Equal(current, data) {
+			// A missing file is usually mid-rewrite by an editor; wait for it.
+			continue
+		}
+		data = current
+
+		cfg, err := parseConfig(path, baseDir, data)
+		if err != nil {
+			if onError != nil {
+				onError(err)
+			}
+			continue
+		}
+		change := applyConfig(prev, cfg)
+		if len(change.Fields) > 0 {
+			notifyConfig(change)
+		}
+		prev = change.New
+	}
+}
+
+// Reload re-reads config.yaml and notifies the subscribers of changed settings.
+func Reload() error {
+	configMutex.RLock()
+	if globalConfig == nil {
+		configMutex.RUnlock()
+		return fmt.Errorf("config not loaded")
+	}
+	path, baseDir := globalConfig.ConfigPath, globalConfig.BaseDir
+	configMutex.RUnlock()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	cfg, err := parseConfig(path, baseDir, data)
+	if err != nil {
+		return err
+	}
+
+	configMutex.RLock()
+	old := cloneConfig(globalConfig)
+	configMutex.RUnlock()
+
+	if change := applyConfig(old, cfg); len(change.Fields) > 0 {
+		notifyConfig(change)
+	}
+	return nil
+}
+
+// applyConfig makes cfg the global configuration and returns its changes from old.
+func applyConfig(old, cfg *Config) ConfigChange {
+	configMutex.Lock()
+	globalConfig = cfg
+	configMutex.Unlock()
+
+	i18n.SetLanguage(cfg.Language)
+	term.SetPlain(cfg.PlainOutput)
+
+	return ConfigChange{Old: old, New: cloneConfig(cfg), Fields: configChanges(old, cfg)}
+}
+
+// configChanges returns the YAML keys of the settings that differ.
+func configChanges(old, cfg *Config) []string {
+	var fields []string
+	ov, nv := reflect.ValueOf(old).Elem(), reflect.ValueOf(cfg).Elem()
+	t := ov.Type()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if !reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// cloneConfig returns a deep copy of a configuration. The copy goes through YAML,
+// like the configuration read from disk it is compared with.
+func cloneConfig(cfg *Config) *Config {
+	c := &Config{BaseDir: cfg.BaseDir, ConfigPath: cfg.ConfigPath}
+	data, err := yaml.Marshal(cfg)
+	if err == nil {
+		err = yaml.Unmarshal(data, c)
+	}
+	if err != nil {
+		panic(fmt.Sprintf("failed to copy config: %v", err))
+	}
+	return c
+}
@@ -0,0 +1,148 @@
+package inventory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gossher/internal/i18n"
+	"gossher/internal/term"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestConfig loads a default config from a temporary home directory.
+func setupTestConfig(t *testing.T) string {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	require.NoError(t, Load())
+	t.Cleanup(func() {
+		configMutex.Lock()
+		globalConfig = nil
+		configMutex.Unlock()
+		i18n.SetLanguage(i18n.DefaultLanguage)
+		term.SetPlain(false)
+	})
+	return filepath.Join(home, ".gossher", "config.yaml")
+}
+
+// editConfig rewrites the config file with a string replacement.
+func editConfig(t *testing.T, path, old, new string) {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), old)
+	require.NoError(t, os.WriteFile(path, []byte(strings.Replace(string(data), old, new, 1)), 0o600))
+}
+
+func TestConfigReload(t *testing.T) {
+	t.Run("notifies changed settings", func(t *testing.T) {
+		path := setupTestConfig(t)
+		var changes []ConfigChange
+		unsubscribe := SubscribeConfig(func(c ConfigChange) { changes = append(changes, c) })
+		defer unsubscribe()
+
+		editConfig(t, path, "theme: light", "theme: dark")
+		editConfig(t, path, "ssh_timeout: 30", "ssh_timeout: 5")
+		require.NoError(t, Reload())
+
+		require.Len(t, changes, 1)
+		assert.Equal(t, []string{"theme", "ssh_timeout"}, changes[0].Fields)
+		assert.True(t, changes[0].Changed("theme"))
+		assert.False(t, changes[0].Changed("language"))
+		assert.Equal(t, "light", changes[0].Old.Theme)
+		assert.Equal(t, "dark", changes[0].New.Theme)
+		assert.Equal(t, "dark", GetTheme())
+		assert.Equal(t, 5, GetSSHTimeout())
+	})
+
+	t.Run("unchanged file", func(t *testing.T) {
+		setupTestConfig(t)
+		called := false
+		defer SubscribeConfig(func(ConfigChange) { called = true })()
+
+		require.NoError(t, Reload())
+		assert.False(t, called)
+	})
+
+	t.Run("invalid file keeps the config", func(t *testing.T) {
+		path := setupTestConfig(t)
+		require.NoError(t, os.WriteFile(path, []byte("theme: [\n"), 0o600))
+		assert.Error(t, Reload())
+		assert.Equal(t, "light", GetTheme())
+	})
+
+	t.Run("saves a config loaded from disk", func(t *testing.T) {
+		path := setupTestConfig(t)
+		require.NoError(t, Load())
+		require.NoError(t, SetTheme("dark"))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "theme: dark")
+	})
+}
+
+func TestConfigWatch(t *testing.T) {
+	path := setupTestConfig(t)
+
+	var mu sync.Mutex
+	var fields [][]string
+	defer SubscribeConfig(func(c ConfigChange) {
+		mu.Lock()
+		defer mu.Unlock()
+		fields = append(fields, c.Fields)
+	})()
+	seen := func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([][]string(nil), fields...)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 10)
+	done := make(chan error, 1)
+	go func() { done <- Watch(ctx, 5*time.Millisecond, func(err error) { errs <- err }) }()
+	time.Sleep(20 * time.Millisecond)
+
+	t.Run("edits on disk", func(t *testing.T) {
+		editConfig(t, path, "language: en", "language: ko")
+		require.Eventually(t, func() bool { return len(seen()) == 1 }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, []string{"language"}, seen()[0])
+		assert.Equal(t, "ko", GetLanguage())
+	})
+
+	t.Run("saves of this process", func(t *testing.T) {
+		require.NoError(t, SetIdleTimeout(300))
+		require.Eventually(t, func() bool { return len(seen()) == 2 }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, []string{"idle_timeout"}, seen()[1])
+	})
+
+	t.Run("parse errors", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("theme: [\n"), 0o600))
+		select {
+		case err := <-errs:
+			assert.Contains(t, err.Error(), "failed to parse config")
+		case <-time.After(time.Second):
+			t.Fatal("parse error not reported")
+		}
+		assert.Equal(t, 300, GetIdleTimeout())
+	})
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestSanitizedConfig(t *testing.T) {
+	setupTestConfig(t)
+	require.NoError(t, SetNetbox(NetboxConfig{URL: "https://netbox.example.com", Token: "0123456789abcdef"}))
+
+	cfg := SanitizedConfig()
+	assert.Equal(t, "[REDACTED]", cfg.Netbox.Token)
+	assert.Equal(t, "https://netbox.example.com", cfg.Netbox.URL)
+	assert.Equal(t, "0123456789abcdef", GetNetbox().Token)
+}
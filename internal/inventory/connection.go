@@ -0,0 +1,316 @@
+package inventory
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResolvedConnection is the effective connection configuration of a host after
+// merging its credential, inline authentication and jump host chain.
+type ResolvedConnection struct {
+	HostID  string
+	Address string
+	Port    int
+
+	// KeyPath and PassphraseFile are kept as written in the inventory; ResolveSecrets
+	// expands ~ and environment variables in them and resolves relative paths, such as
+	// imported keys, against DataDir.
+	User           string
+	KeyPath        string
+	Password       string
+	Passphrase     string
+	PassphraseFile string
+
+	// PrivateKey is a PEM-encoded key fetched by ResolveSecrets.
+	PrivateKey string
+	// Provider and ItemID reference the password manager item of the credential.
+	Provider string
+	ItemID   string
+
+	// Jumps lists the jump hosts to traverse, outermost first.
+	Jumps []*ResolvedConnection
+
+	// Relay is the connection of the relay host whose gossher-agent reaches this
+	// host; Jumps are then traversed from the relay.
+	Relay *ResolvedConnection
+
+	// DataDir is the data directory of the inventory the host belongs to.
+	DataDir string
+
+	// Networks are those the host requires, checked before connecting.
+	Networks []RequiredNetwork
+
+	// Knock is the port knocking sequence to send to Address before connecting.
+	Knock []KnockStep
+
+	// GatewayURL is the WebSocket gateway the connection is tunneled through when
+	// this is the first hop; see GatewayTarget.
+	GatewayURL string
+
+	// Local is set for hosts with ConnectionLocal; commands run on this machine.
+	Local bool
+
+	// HostKeys are the pinned host keys; when set, SSH clients reject a server
+	// presenting any other key.
+	HostKeys []HostKey
+
+	// Timeouts are those set on the host; the executor replaces them with the
+	// effective timeouts of a run. Runners limit establishing the connection, jump
+	// hosts included, to Timeouts.Connect; ssh command lines get it as the
+	// ConnectTimeout option (see SSHArgs).
+	Timeouts Timeouts
+}
+
+// ResolveConnection computes the effective connection settings for a host.
+// Inline authentication fields override those of the referenced credential.
+func (m *Manager) ResolveConnection(hostID string) (*ResolvedConnection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.resolveConnection(hostID, map[string]bool{})
+}
+
+func (m *Manager) resolveConnection(hostID string, visiting map[string]bool) (*ResolvedConnection, error) {
+	if visiting[hostID] {
+		return nil, fmt.Errorf("host %s: jump host loop detected", hostID)
+	}
+	visiting[hostID] = true
+
+	h, ok := m.hosts[hostID]
+	if !ok {
+		return nil, fmt.Errorf("host %s not found", hostID)
+	}
+
+	c := &ResolvedConnection{
+		HostID:     h.ID,
+		Address:    h.Address,
+		Port:       h.Port,
+		DataDir:    m.dataDir,
+		Local:      h.IsLocal(),
+		Knock:      append([]KnockStep(nil), h.Knock...),
+		GatewayURL: h.GatewayURL,
+		HostKeys:   append([]HostKey(nil), h.HostKeys...),
+		Networks:   m.resolveNetworks(h.ID),
+		Timeouts: Timeouts{
+			Connect: h.Timeouts.Connect,
+			Command: h.Timeouts.Command,
+		},
+	}
+
+	if h.CredentialID != "" {
+		cred, ok := m.credentials[h.CredentialID]
+		if !ok {
+			return nil, fmt.Errorf("host %s: credential %s not found", h.ID, h.CredentialID)
+		}
+		c.User = cred.User
+		c.KeyPath = cred.KeyPath
+		c.Password = cred.Password
+		c.Passphrase = cred.Passphrase
+		c.PassphraseFile = cred.PassphraseFile
+		c.Provider = cred.Provider
+		c.ItemID = cred.ItemID
+	}
+
+	if h.User != "" {
+		c.User = h.User
+	}
+	if h.KeyPath != "" {
+		c.KeyPath = h.KeyPath
+	}
+	if h.Password != "" {
+		c.Password = h.Password
+	}
+
+	if h.RelayHostID != "" {
+		relay, err := m.resolveConnection(h.RelayHostID, maps.Clone(visiting))
+		if err != nil {
+			return nil, fmt.Errorf("host %s: %w", h.ID, err)
+		}
+		if relay.Local {
+			return nil, fmt.Errorf("host %s: local host %s cannot be a relay host", h.ID, relay.HostID)
+		}
+		c.Relay = relay
+	}
+
+	if h.JumpHostID != "" {
+		jump, err := m.resolveConnection(h.JumpHostID, visiting)
+		if err != nil {
+			return nil, fmt.Errorf("host %s: %w", h.ID, err)
+		}
+		if jump.Local {
+			return nil, fmt.Errorf("host %s: local host %s cannot be a jump host", h.ID, jump.HostID)
+		}
+		c.Jumps = append(jump.Jumps, jump)
+		jump.Jumps = nil
+	}
+
+	return c, nil
+}
+
+// ===== Connection strings =====
+
+// ConnectionFormat selects the kind of connection string to render.
+type ConnectionFormat string
+
+const (
+	// FormatSSHCommand renders a complete ssh command line.
+	FormatSSHCommand ConnectionFormat = "ssh"
+	// FormatSCPTemplate renders an scp command with LOCAL_PATH / REMOTE_PATH placeholders.
+	FormatSCPTemplate ConnectionFormat = "scp"
+	// FormatAddress renders "address:port".
+	FormatAddress ConnectionFormat = "address"
+)
+
+// HostPort returns "address:port", bracketing IPv6 literals.
+func (c *ResolvedConnection) HostPort() string {
+	addr := c.Address
+	if strings.Contains(addr, ":") && !strings.HasPrefix(addr, "[") {
+		addr = "[" + addr + "]"
+	}
+	return addr + ":" + strconv.Itoa(c.Port)
+}
+
+// Destination returns "user@address" (or just the address without a user).
+func (c *ResolvedConnection) Destination() string {
+	if c.User == "" {
+		return c.Address
+	}
+	return c.User + "@" + c.Address
+}
+
+// JumpSpec returns the -J argument of ssh for the jump chain, with IPv6
+// addresses in brackets.
+func (c *ResolvedConnection) JumpSpec() string {
+	specs := make([]string, 0, len(c.Jumps))
+	for _, j := range c.Jumps {
+		spec := j.Address
+		if strings.Contains(spec, ":") {
+			spec = "[" + spec + "]"
+		}
+		if j.User != "" {
+			spec = j.User + "@" + spec
+		}
+		specs = append(specs, spec+":"+strconv.Itoa(j.Port))
+	}
+	return strings.Join(specs, ",")
+}
+
+// SSHArgs returns the OpenSSH options equivalent to this connection's port,
+// connect timeout, key and jump hosts, unquoted.
+func (c *ResolvedConnection) SSHArgs() []string {
+	var args []string
+	if c.Port != 0 && c.Port != 22 {
+		args = append(args, "-p", strconv.Itoa(c.Port))
+	}
+	args = append(args, c.ConnectTimeoutOption()...)
+	if c.KeyPath != "" {
+		args = append(args, "-i", c.KeyPath)
+	}
+	if len(c.Jumps) > 0 {
+		args = append(args, "-J", c.JumpSpec())
+	}
+	return args
+}
+
+// ConnectTimeoutOption returns the ssh options limiting the connection to
+// Timeouts.Connect, in whole seconds rounded up, or nil without a limit.
+func (c *ResolvedConnection) ConnectTimeoutOption() []string {
+	if c.Timeouts.Connect <= 0 {
+		return nil
+	}
+	secs := int64((c.Timeouts.Connect + time.Second - 1) / time.Second)
+	return []string{"-o", "ConnectTimeout=" + strconv.FormatInt(secs, 10)}
+}
+
+// SSHCommand renders an OpenSSH command line equivalent to this connection.
+func (c *ResolvedConnection) SSHCommand() string {
+	args := []string{"ssh"}
+	for _, a := range c.SSHArgs() {
+		args = append(args, ShellQuote(a))
+	}
+	args = append(args, ShellQuote(c.Destination()))
+	return strings.Join(args, " ")
+}
+
+// RemotePath returns the scp and rsync form of a path on the host,
+// "user@address:path", bracketing IPv6 literals.
+func (c *ResolvedConnection) RemotePath(path string) string {
+	dest := c.Address
+	if strings.Contains(dest, ":") {
+		dest = "[" + dest + "]"
+	}
+	if c.User != "" {
+		dest = c.User + "@" + dest
+	}
+	return dest + ":" + path
+}
+
+// SCPTemplate renders an scp upload command with placeholders for the paths.
+func (c *ResolvedConnection) SCPTemplate() string {
+	args := []string{"scp"}
+	if c.Port != 0 && c.Port != 22 {
+		args = append(args, "-P", strconv.Itoa(c.Port))
+	}
+	if c.KeyPath != "" {
+		args = append(args, "-i", ShellQuote(c.KeyPath))
+	}
+	if len(c.Jumps) > 0 {
+		args = append(args, "-J", ShellQuote(c.JumpSpec()))
+	}
+
+	dest := c.Address
+	if strings.Contains(dest, ":") {
+		dest = "[" + dest + "]"
+	}
+	if c.User != "" {
+		dest = c.User + "@" + dest
+	}
+	args = append(args, "LOCAL_PATH", ShellQuote(dest)+":REMOTE_PATH")
+	return strings.Join(args, " ")
+}
+
+// Format renders the connection in the requested format.
+func (c *ResolvedConnection) Format(f ConnectionFormat) (string, error) {
+	if c.Local {
+		return "", fmt.Errorf("host %s is local and has no connection string", c.HostID)
+	}
+	switch f {
+	case FormatSSHCommand:
+		return c.SSHCommand(), nil
+	case FormatSCPTemplate:
+		return c.SCPTemplate(), nil
+	case FormatAddress:
+		return c.HostPort(), nil
+	}
+	return "", fmt.Errorf("unknown connection format: %s", f)
+}
+
+// ConnectionString resolves a host and renders its connection string.
+func (m *Manager) ConnectionString(hostID string, f ConnectionFormat) (string, error) {
+	c, err := m.ResolveConnection(hostID)
+	if err != nil {
+		return "", err
+	}
+	return c.Format(f)
+}
+
+// ShellQuote quotes s for POSIX shells when it contains characters that need it.
+func ShellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("@%+=:,./_-", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
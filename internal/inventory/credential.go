@@ -19,6 +19,14 @@ type Credential struct {
 	Password string `yaml:"password,omitempty"`
 
 	Passphrase string `yaml:"passphrase,omitempty"`
+	// PassphraseFile names a file holding the key passphrase, read at connect time.
+	// Like KeyPath it may start with ~ and reference environment variables.
+	PassphraseFile string `yaml:"passphrase_file,omitempty"`
+
+	// Provider names an external password manager ("op", "bw", "rbw") holding the
+	// secrets under ItemID. They are fetched at connect time and never written here.
+	Provider string `yaml:"provider,omitempty"`
+	ItemID   string `yaml:"item_id,omitempty"`
 }
 
 // CredentialType represents the authentication method.
@@ -74,9 +82,22 @@ func (c *Credential) Validate() error {
 		return fmt.Errorf("credential %s: user cannot be empty", c.ID)
 	}
 
+	if c.Provider != "" {
+		if c.ItemID == "" {
+			return fmt.Errorf("credential %s: provider %s requires item_id", c.ID, c.Provider)
+		}
+		return nil
+	}
+	if c.ItemID != "" {
+		return fmt.Errorf("credential %s: item_id requires a provider", c.ID)
+	}
+
 	if c.KeyPath == "" && c.Password == "" {
 		return fmt.Errorf("credential %s: must have either key_path or password", c.ID)
 	}
+	if c.Passphrase != "" && c.PassphraseFile != "" {
+		return fmt.Errorf("credential %s: passphrase and passphrase_file are mutually exclusive", c.ID)
+	}
 
 	return nil
 }
@@ -87,6 +108,11 @@ func (c *Credential) Clone() interface{} {
 	return &clone
 }
 
+// ExpandedKeyPath returns the key path with ~ and environment variables expanded.
+func (c *Credential) ExpandedKeyPath() string {
+	return ExpandPath(c.KeyPath)
+}
+
 // getCredentialType returns the authentication type of this credential.
 func (c *Credential) getCredentialType() CredentialType {
 	if c.KeyPath != "" {
@@ -0,0 +1,89 @@
+package inventory
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ContentDecoder decrypts files whose content, rather than their extension, marks them
+// as encrypted (e.g. sops-encrypted YAML). Such files are read-only for gossher.
+type ContentDecoder interface {
+	// Name identifies the decoder in messages ("sops").
+	Name() string
+	// Detect reports whether data is encrypted in this decoder's format.
+	Detect(data []byte) bool
+	// DecodeFile returns the plaintext of the file at path whose raw content is data.
+	DecodeFile(path string, data []byte) ([]byte, error)
+}
+
+var (
+	contentDecoders   []ContentDecoder
+	contentDecodersMu sync.RWMutex
+)
+
+// RegisterContentDecoder adds a decoder consulted by every Manager and by storage reads,
+// replacing any decoder registered under the same name.
+func RegisterContentDecoder(d ContentDecoder) {
+	contentDecodersMu.Lock()
+	defer contentDecodersMu.Unlock()
+
+	for i, existing := range contentDecoders {
+		if existing.Name() == d.Name() {
+			contentDecoders[i] = d
+			return
+		}
+	}
+	contentDecoders = append(contentDecoders, d)
+}
+
+// DecodeContent decrypts data with the first registered decoder that recognizes it.
+// It returns the name of that decoder, or "" when data is not encrypted.
+func DecodeContent(path string, data []byte) ([]byte, string, error) {
+	contentDecodersMu.RLock()
+	defer contentDecodersMu.RUnlock()
+
+	for _, d := range contentDecoders {
+		if !d.Detect(data) {
+			continue
+		}
+		plain, err := d.DecodeFile(path, data)
+		if err != nil {
+			return nil, d.Name(), fmt.Errorf("failed to decrypt %s with %s: %w", path, d.Name(), err)
+		}
+		return plain, d.Name(), nil
+	}
+	return data, "", nil
+}
+
+// DetectContent returns the name of the decoder that recognizes data as encrypted,
+// or "" for plain content.
+func DetectContent(data []byte) string {
+	contentDecodersMu.RLock()
+	defer contentDecodersMu.RUnlock()
+
+	for _, d := range contentDecoders {
+		if d.Detect(data) {
+			return d.Name()
+		}
+	}
+	return ""
+}
+
+// IsReadOnly reports whether a data file was decrypted externally and must be edited
+// with its own tool, and names that tool.
+func (m *Manager) IsReadOnly(filename string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tool, ok := m.readOnly[filename]
+	return tool, ok
+}
+
+// checkWritable refuses to rewrite externally encrypted files, which would otherwise
+// be replaced with plaintext. Caller must hold the lock.
+func (m *Manager) checkWritable(filename string) error {
+	if tool, ok := m.readOnly[filename]; ok {
+		return fmt.Errorf("file %s is encrypted with %s; edit it with %s instead", filename, tool, tool)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package inventory
+
+import "fmt"
+
+// Discovery source types.
+const (
+	DiscoveryGCE   = "gce"
+	DiscoveryAzure = "azure"
+)
+
+// AddressPreference selects which address of a cloud instance hosts connect to.
+type AddressPreference string
+
+const (
+	// AddressPrivate prefers the private address (default).
+	AddressPrivate AddressPreference = "private"
+	// AddressPublic prefers the public address.
+	AddressPublic AddressPreference = "public"
+)
+
+// DiscoverySource configures a cloud account whose instances a profile imports as
+// hosts (see package discovery).
+type DiscoverySource struct {
+	// Type is DiscoveryGCE or DiscoveryAzure.
+	Type string `yaml:"type"`
+
+	// Project and, optionally, Zones select the GCE instances.
+	Project string   `yaml:"project,omitempty"`
+	Zones   []string `yaml:"zones,omitempty"`
+
+	// Subscription and, optionally, ResourceGroups select the Azure VMs.
+	Subscription   string   `yaml:"subscription,omitempty"`
+	ResourceGroups []string `yaml:"resource_groups,omitempty"`
+
+	// TokenEnv names an environment variable holding an access token; without it
+	// the token is requested from gcloud or az.
+	TokenEnv string `yaml:"token_env,omitempty"`
+
+	// Address selects the public or private address (default private).
+	Address AddressPreference `yaml:"address,omitempty"`
+	// Labels limits the labels mapped to tags to these keys; empty maps all labels.
+	Labels []string `yaml:"labels,omitempty"`
+	// User is the login of imported hosts.
+	User string `yaml:"user,omitempty"`
+}
+
+// Validate checks the type, the account fields it requires and the address preference.
+func (s DiscoverySource) Validate() error {
+	switch s.Type {
+	case DiscoveryGCE:
+		if s.Project == "" {
+			return fmt.Errorf("gce discovery: project is required")
+		}
+	case DiscoveryAzure:
+		if s.Subscription == "" {
+			return fmt.Errorf("azure discovery: subscription is required")
+		}
+	default:
+		return fmt.Errorf("invalid discovery type: %q", s.Type)
+	}
+
+	switch s.Address {
+	case "", AddressPrivate, AddressPublic:
+	default:
+		return fmt.Errorf("%s discovery: invalid address preference: %s", s.Type, s.Address)
+	}
+	return nil
+}
+
+func (s DiscoverySource) clone() DiscoverySource {
+	clone := s
+	clone.Zones = append([]string(nil), s.Zones...)
+	clone.ResourceGroups = append([]string(nil), s.ResourceGroups...)
+	clone.Labels = append([]string(nil), s.Labels...)
+	return clone
+}
+
+func cloneDiscoverySources(sources []DiscoverySource) []DiscoverySource {
+	if sources == nil {
+		return nil
+	}
+	clones := make([]DiscoverySource, len(sources))
+	for i, s := range sources {
+		clones[i] = s.clone()
+	}
+	return clones
+}
@@ -0,0 +1,32 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscoverySourceValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     DiscoverySource
+		wantErr string
+	}{
+		{"gce", DiscoverySource{Type: DiscoveryGCE, Project: "p", Address: AddressPublic}, ""},
+		{"azure", DiscoverySource{Type: DiscoveryAzure, Subscription: "s"}, ""},
+		{"gce without project", DiscoverySource{Type: DiscoveryGCE}, "project is required"},
+		{"azure without subscription", DiscoverySource{Type: DiscoveryAzure}, "subscription is required"},
+		{"unknown type", DiscoverySource{Type: "ec2"}, "invalid discovery type"},
+		{"bad address", DiscoverySource{Type: DiscoveryGCE, Project: "p", Address: "ipv6"}, "invalid address preference"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.src.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
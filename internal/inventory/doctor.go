@@ -0,0 +1,275 @@
+package inventory
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+
+	"gossher/internal/i18n"
+	"gossher/internal/term"
+)
+
+// Doctor check names.
+const (
+	DoctorConfig      = "config"
+	DoctorDataDir     = "data-dir"
+	DoctorPort        = "port"
+	DoctorTimeout     = "timeout"
+	DoctorLanguage    = "language"
+	DoctorTheme       = "theme"
+	DoctorPolicy      = "policy"
+	DoctorProfile     = "profile"
+	DoctorEntity      = "entity"
+	DoctorReference   = "reference"
+	DoctorKeyFile     = "key-file"
+	DoctorPermissions = "permissions"
+)
+
+// DoctorFinding is a problem of the configuration or the inventory found by Doctor.
+type DoctorFinding struct {
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	// Subject is what the finding is about, e.g. "config" or "host web1".
+	Subject string `json:"subject"`
+	Message string `json:"message"`
+}
+
+func (f DoctorFinding) String() string {
+	return fmt.Sprintf("%s: %s: %s (%s)", f.Severity, f.Subject, f.Message, f.Check)
+}
+
+// DoctorFailed reports whether any finding is an error.
+func DoctorFailed(findings []DoctorFinding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// doctorReport collects findings.
+type doctorReport []DoctorFinding
+
+func (r *doctorReport) add(check string, severity Severity, subject, format string, args ...any) {
+	*r = append(*r, DoctorFinding{Check: check, Severity: severity, Subject: subject, Message: fmt.Sprintf(format, args...)})
+}
+
+// addErr adds err as an error finding unless it is nil.
+func (r *doctorReport) addErr(check, subject string, err error) {
+	if err != nil {
+		r.add(check, SeverityError, subject, "%v", err)
+	}
+}
+
+// ValidateConfig checks a configuration on its own: the data directory exists and
+// is writable, numbers are in range, the language is supported and the policies
+// are valid.
+func ValidateConfig(cfg *Config) []DoctorFinding {
+	var r doctorReport
+
+	checkDataDir(&r, "config", cfg.dataDir())
+
+	if cfg.DefaultSSHPort <= 0 || cfg.DefaultSSHPort > 65535 {
+		r.add(DoctorPort, SeverityError, "config", "default_ssh_port %d is out of range 1-65535", cfg.DefaultSSHPort)
+	}
+	if cfg.SSHTimeout <= 0 {
+		r.add(DoctorTimeout, SeverityError, "config", "ssh_timeout must be positive, got %d", cfg.SSHTimeout)
+	}
+	if cfg.IdleTimeout < 0 {
+		r.add(DoctorTimeout, SeverityError, "config", "idle_timeout cannot be negative, got %d", cfg.IdleTimeout)
+	}
+	if cfg.TunnelIdleTimeout < 0 {
+		r.add(DoctorTimeout, SeverityError, "config", "tunnel_idle_timeout cannot be negative, got %d", cfg.TunnelIdleTimeout)
+	}
+	r.addErr(DoctorTimeout, "config", cfg.Timeouts.Validate())
+
+	if lang := i18n.Normalize(cfg.Language); lang != "" && !slices.Contains(i18n.Languages(), lang) {
+		r.add(DoctorLanguage, SeverityWarning, "config", "language %q is not supported (supported: %v); messages are shown in %s",
+			cfg.Language, i18n.Languages(), i18n.DefaultLanguage)
+	}
+
+	r.addErr(DoctorPermissions, "config", cfg.Permissions.Validate())
+	r.addErr(DoctorPolicy, "config", cfg.IDPolicy.Validate())
+	r.addErr(DoctorPolicy, "config", cfg.InlineAuthPolicy.Validate())
+	r.addErr(DoctorPolicy, "config", cfg.CommandPolicy.Validate())
+	r.addErr(DoctorPolicy, "config", cfg.Lifecycle.Validate())
+	r.addErr(DoctorConfig, "config", cfg.Executor.Validate())
+	r.addErr(DoctorConfig, "config", cfg.Netbox.Validate())
+	r.addErr(DoctorConfig, "config", cfg.Telemetry.Validate())
+	r.addErr(DoctorConfig, "config", validateNetworks(cfg.Networks))
+	for _, s := range cfg.Discovery {
+		r.addErr(DoctorConfig, "config", s.Validate())
+	}
+
+	for _, name := range sortedKeys(cfg.Profiles) {
+		p := cfg.Profiles[name]
+		subject := "profile " + name
+		if p.DataDir == "" {
+			r.add(DoctorProfile, SeverityError, subject, "profile has no data_dir")
+		} else {
+			profileCfg := &Config{BaseDir: cfg.BaseDir, DataDir: p.DataDir}
+			checkDataDir(&r, subject, profileCfg.dataDir())
+		}
+		for _, s := range p.Discovery {
+			r.addErr(DoctorProfile, subject, s.Validate())
+		}
+	}
+
+	return r
+}
+
+// checkDataDir reports a data directory that is missing, not a directory or not
+// writable.
+func checkDataDir(r *doctorReport, subject, dir string) {
+	info, err := os.Stat(dir)
+	switch {
+	case os.IsNotExist(err):
+		r.add(DoctorDataDir, SeverityError, subject, "data directory %s does not exist", dir)
+		return
+	case err != nil:
+		r.add(DoctorDataDir, SeverityError, subject, "data directory %s: %v", dir, err)
+		return
+	case !info.IsDir():
+		r.add(DoctorDataDir, SeverityError, subject, "data directory %s is not a directory", dir)
+		return
+	}
+
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		r.add(DoctorDataDir, SeverityError, subject, "data directory %s is not writable: %v", dir, err)
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// Doctor checks the loaded configuration and the inventory together: everything
+// ValidateConfig checks, every entity, the references between entities, the
+// configured theme, key files of credentials and hosts, and file permissions.
+// Findings are sorted by severity, errors first.
+func (m *Manager) Doctor() []DoctorFinding {
+	var r doctorReport
+
+	configMutex.RLock()
+	var cfg *Config
+	if globalConfig != nil {
+		cfg = cloneConfig(globalConfig)
+	}
+	configMutex.RUnlock()
+
+	if cfg == nil {
+		r.add(DoctorConfig, SeverityError, "config", "config not loaded")
+	} else {
+		r = append(r, ValidateConfig(cfg)...)
+		if cfg.Theme != "" {
+			if _, ok := m.GetTheme(cfg.Theme); !ok {
+				r.add(DoctorTheme, SeverityWarning, "config", "theme %q not found; the %s theme is used", cfg.Theme, DefaultThemeID)
+			}
+		}
+	}
+
+	m.mu.RLock()
+	m.doctorEntities(&r)
+	m.mu.RUnlock()
+
+	if issues, err := m.CheckPermissions(); err != nil {
+		r.addErr(DoctorPermissions, "data directory", err)
+	} else {
+		for _, issue := range issues {
+			r.add(DoctorPermissions, SeverityWarning, issue.Path, "mode %#o grants more than %#o; run Harden to fix it", issue.Mode, issue.Want)
+		}
+	}
+
+	rank := map[Severity]int{SeverityError: 0, SeverityWarning: 1, SeverityInfo: 2}
+	sort.SliceStable(r, func(i, j int) bool { return rank[r[i].Severity] < rank[r[j].Severity] })
+	return r
+}
+
+// doctorEntities validates the entities and their references. Caller must hold the
+// read lock.
+func (m *Manager) doctorEntities(r *doctorReport) {
+	for _, id := range sortedKeys(m.hosts) {
+		h := m.hosts[id]
+		subject := "host " + id
+		r.addErr(DoctorEntity, subject, h.Validate())
+		r.addErr(DoctorReference, subject, m.checkHostRefs(h))
+		checkKeyFile(r, subject, h.KeyPath, m.dataDir)
+		for _, name := range h.Groups {
+			if _, ok := m.groups[name]; !ok {
+				r.add(DoctorReference, SeverityWarning, subject, "declared group %s not found", name)
+			}
+		}
+		if h.HasInlineAuth() && (m.inlineAuth == InlineAuthWarn || m.inlineAuth == InlineAuthForbid) {
+			r.add(DoctorPolicy, SeverityWarning, subject, "inline key_path or password; run MigrateInlineAuth to move it to a credential")
+		}
+	}
+
+	for _, name := range sortedKeys(m.groups) {
+		g := m.groups[name]
+		subject := "group " + name
+		r.addErr(DoctorEntity, subject, g.Validate())
+		for _, id := range g.HostIDs {
+			if _, ok := m.hosts[id]; !ok {
+				r.add(DoctorReference, SeverityWarning, subject, "member host %s not found", id)
+			}
+		}
+		for _, child := range g.ChildGroupNames {
+			if _, ok := m.groups[child]; !ok {
+				r.add(DoctorReference, SeverityWarning, subject, "child group %s not found", child)
+			}
+		}
+		if _, err := m.resolveGroupHosts(name); err != nil {
+			r.addErr(DoctorReference, subject, err)
+		}
+	}
+
+	for _, id := range sortedKeys(m.credentials) {
+		c := m.credentials[id]
+		subject := "credential " + id
+		r.addErr(DoctorEntity, subject, c.Validate())
+		checkKeyFile(r, subject, c.KeyPath, m.dataDir)
+	}
+
+	for _, id := range sortedKeys(m.commands) {
+		r.addErr(DoctorEntity, "command "+id, m.commands[id].Validate())
+	}
+	for _, id := range sortedKeys(m.checks) {
+		r.addErr(DoctorEntity, "check "+id, m.checks[id].Validate())
+	}
+	for _, id := range sortedKeys(m.workflows) {
+		r.addErr(DoctorEntity, "workflow "+id, m.workflows[id].Validate())
+	}
+	for _, id := range sortedKeys(m.themes) {
+		r.addErr(DoctorEntity, "theme "+id, m.themes[id].Validate())
+	}
+	for _, id := range sortedKeys(m.sessionSets) {
+		s := m.sessionSets[id]
+		subject := "session set " + id
+		r.addErr(DoctorEntity, subject, s.Validate())
+		r.addErr(DoctorReference, subject, m.checkSessionSetRefs(s))
+	}
+}
+
+// checkKeyFile reports a private key file that does not exist.
+func checkKeyFile(r *doctorReport, subject, keyPath, dataDir string) {
+	if keyPath == "" {
+		return
+	}
+	p := resolvePath(keyPath, dataDir)
+	if _, err := os.Stat(p); err != nil {
+		r.add(DoctorKeyFile, SeverityWarning, subject, "key file %s: %v", keyPath, err)
+	}
+}
+
+// WriteDoctorFindings renders findings as an aligned table.
+func WriteDoctorFindings(w io.Writer, findings []DoctorFinding) error {
+	tw := term.NewTable(w)
+	fmt.Fprintf(tw, "SEVERITY\tSUBJECT\tCHECK\tMESSAGE\n")
+	for _, f := range findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Severity, f.Subject, f.Check, f.Message)
+	}
+	return tw.Flush()
+}
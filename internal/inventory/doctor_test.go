@@ -0,0 +1,188 @@
+package inventory
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// findingsOf returns the findings of a check.
+func findingsOf(findings []DoctorFinding, check string) []DoctorFinding {
+	var out []DoctorFinding
+	for _, f := range findings {
+		if f.Check == check {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func TestValidateConfig(t *testing.T) {
+	valid := func(t *testing.T) *Config {
+		cfg := Default()
+		cfg.BaseDir = t.TempDir()
+		cfg.DataDir = ""
+		return cfg
+	}
+
+	t.Run("default config", func(t *testing.T) {
+		assert.Empty(t, ValidateConfig(valid(t)))
+	})
+
+	t.Run("out of range values", func(t *testing.T) {
+		cfg := valid(t)
+		cfg.DefaultSSHPort = 70000
+		cfg.SSHTimeout = 0
+		cfg.IdleTimeout = -1
+		cfg.Executor.Concurrency = -1
+
+		findings := ValidateConfig(cfg)
+		require.Len(t, findingsOf(findings, DoctorPort), 1)
+		assert.Contains(t, findingsOf(findings, DoctorPort)[0].Message, "70000")
+		assert.Len(t, findingsOf(findings, DoctorTimeout), 2)
+		assert.Len(t, findingsOf(findings, DoctorConfig), 1)
+		assert.True(t, DoctorFailed(findings))
+	})
+
+	t.Run("unsupported language", func(t *testing.T) {
+		cfg := valid(t)
+		cfg.Language = "xx"
+		findings := ValidateConfig(cfg)
+		require.Len(t, findings, 1)
+		assert.Equal(t, DoctorLanguage, findings[0].Check)
+		assert.Equal(t, SeverityWarning, findings[0].Severity)
+		assert.False(t, DoctorFailed(findings))
+
+		cfg.Language = "ko_KR.UTF-8"
+		assert.Empty(t, ValidateConfig(cfg))
+	})
+
+	t.Run("data directory", func(t *testing.T) {
+		cfg := valid(t)
+		cfg.DataDir = "missing"
+		findings := findingsOf(ValidateConfig(cfg), DoctorDataDir)
+		require.Len(t, findings, 1)
+		assert.Contains(t, findings[0].Message, "does not exist")
+
+		file := filepath.Join(cfg.BaseDir, "file")
+		require.NoError(t, os.WriteFile(file, nil, 0o600))
+		cfg.DataDir = file
+		findings = findingsOf(ValidateConfig(cfg), DoctorDataDir)
+		require.Len(t, findings, 1)
+		assert.Contains(t, findings[0].Message, "not a directory")
+
+		if runtime.GOOS != "windows" && os.Getuid() != 0 {
+			readOnly := filepath.Join(cfg.BaseDir, "ro")
+			require.NoError(t, os.Mkdir(readOnly, 0o500))
+			cfg.DataDir = readOnly
+			findings = findingsOf(ValidateConfig(cfg), DoctorDataDir)
+			require.Len(t, findings, 1)
+			assert.Contains(t, findings[0].Message, "not writable")
+		}
+	})
+
+	t.Run("telemetry needs an endpoint when enabled", func(t *testing.T) {
+		cfg := valid(t)
+		cfg.Telemetry = TelemetryConfig{Endpoint: "not a url"}
+		assert.Empty(t, ValidateConfig(cfg))
+
+		cfg.Telemetry.Enabled = true
+		findings := findingsOf(ValidateConfig(cfg), DoctorConfig)
+		require.Len(t, findings, 1)
+		assert.Contains(t, findings[0].Message, "telemetry endpoint")
+	})
+
+	t.Run("networks need a probe or an interface", func(t *testing.T) {
+		cfg := valid(t)
+		cfg.Networks = map[string]NetworkRequirement{"corp": {Message: "connect to corp VPN first"}}
+		findings := findingsOf(ValidateConfig(cfg), DoctorConfig)
+		require.Len(t, findings, 1)
+		assert.Contains(t, findings[0].Message, "network corp")
+	})
+
+	t.Run("profiles", func(t *testing.T) {
+		cfg := valid(t)
+		cfg.Profiles = map[string]Profile{"work": {}, "home": {DataDir: "home"}}
+		findings := ValidateConfig(cfg)
+		require.Len(t, findings, 2)
+		assert.Equal(t, "profile home", findings[0].Subject)
+		assert.Equal(t, DoctorDataDir, findings[0].Check)
+		assert.Equal(t, "profile work", findings[1].Subject)
+		assert.Equal(t, DoctorProfile, findings[1].Check)
+	})
+}
+
+func TestDoctor(t *testing.T) {
+	t.Run("config not loaded", func(t *testing.T) {
+		m, _ := setupTestManager(t)
+		findings := m.Doctor()
+		require.NotEmpty(t, findings)
+		assert.Equal(t, "config not loaded", findings[0].Message)
+	})
+
+	t.Run("healthy inventory", func(t *testing.T) {
+		setupTestConfig(t)
+		m := NewManager(GetDataDir())
+		require.NoError(t, m.Load())
+		h := NewHost("web1", "web1", "10.0.0.1")
+		h.User = "deploy"
+		require.NoError(t, m.AddHost(h))
+
+		findings := m.Doctor()
+		assert.Empty(t, findings)
+	})
+
+	t.Run("inventory problems", func(t *testing.T) {
+		setupTestConfig(t)
+		require.NoError(t, SetTheme("solarized"))
+		dir := GetDataDir()
+		writeTestFile(t, dir, "inventory.yaml", `type: credential
+id: admin
+name: admin
+user: root
+key_path: keys/missing
+---
+type: host
+id: web1
+name: web1
+address: 10.0.0.1
+credential_id: ghost
+---
+type: group
+name: web
+host_ids: [web1, web9]
+child_groups: [nope]
+`)
+		m := NewManager(dir)
+		require.NoError(t, m.Load())
+
+		findings := m.Doctor()
+		assert.True(t, DoctorFailed(findings))
+		assert.Equal(t, SeverityError, findings[0].Severity)
+
+		refs := findingsOf(findings, DoctorReference)
+		require.Len(t, refs, 3)
+		assert.Equal(t, "host web1", refs[0].Subject)
+		assert.Contains(t, refs[0].Message, "credential ghost not found")
+		assert.Contains(t, refs[1].Message, "member host web9 not found")
+		assert.Contains(t, refs[2].Message, "child group nope not found")
+
+		keys := findingsOf(findings, DoctorKeyFile)
+		require.Len(t, keys, 1)
+		assert.Equal(t, "credential admin", keys[0].Subject)
+
+		themes := findingsOf(findings, DoctorTheme)
+		require.Len(t, themes, 1)
+		assert.Contains(t, themes[0].Message, "solarized")
+
+		var buf bytes.Buffer
+		require.NoError(t, WriteDoctorFindings(&buf, findings))
+		assert.Contains(t, buf.String(), "SEVERITY")
+		assert.Contains(t, buf.String(), "credential ghost not found")
+	})
+}
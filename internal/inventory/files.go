@@ -0,0 +1,130 @@
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileOf returns the data file an entity is stored in, relative to the data directory.
+func (m *Manager) FileOf(kind DocumentType, id string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	filename, ok := m.sources[entityKey{kind, id}]
+	return filename, ok
+}
+
+// FileEntities returns the entities of a data file in document order as "type/id".
+func (m *Manager) FileEntities(filename string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := m.files[filename]
+	refs := make([]string, len(keys))
+	for i, k := range keys {
+		refs[i] = string(k.Type) + "/" + k.ID
+	}
+	return refs
+}
+
+// MoveToFile moves an entity into another data file, appending it as a new document.
+// The target may be a new file or any file the inventory was loaded from; files the
+// previous location leaves empty are removed. Either all changes are written or none are.
+func (m *Manager) MoveToFile(kind DocumentType, id, filename string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.moveToFile([]entityKey{{kind, id}}, filename)
+}
+
+// CollocateGroup keeps a group and the hosts it lists directly in one file, e.g.
+// "web.yaml" holding the web group followed by its hosts. Hosts of child groups stay
+// where they are.
+func (m *Manager) CollocateGroup(name, filename string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.groups[name]
+	if !ok {
+		return fmt.Errorf("group %s not found", name)
+	}
+
+	keys := []entityKey{{TypeGroup, name}}
+	for _, id := range g.HostIDs {
+		if _, ok := m.hosts[id]; ok {
+			keys = append(keys, entityKey{TypeHost, id})
+		}
+	}
+	return m.moveToFile(keys, filename)
+}
+
+// moveToFile moves entities into filename in the given order. Caller must hold the lock.
+func (m *Manager) moveToFile(keys []entityKey, filename string) error {
+	if err := m.checkTargetFile(filename); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if _, ok := m.sources[key]; !ok {
+			return fmt.Errorf("%s %s not found", key.Type, key.ID)
+		}
+		if err := m.checkWritable(m.sources[key]); err != nil {
+			return err
+		}
+	}
+
+	snap := m.snapshot()
+	dirty := map[string]bool{}
+	for _, key := range keys {
+		old := m.sources[key]
+		if old == filename {
+			continue
+		}
+		m.unregisterFile(key, old)
+		m.files[filename] = append(m.files[filename], key)
+		m.sources[key] = filename
+		dirty[old] = true
+		dirty[filename] = true
+	}
+	if len(dirty) == 0 {
+		return nil
+	}
+
+	if err := m.saveFilesAtomic(dirty); err != nil {
+		m.restore(snap)
+		return fmt.Errorf("failed to move entities to %s: %w", filename, err)
+	}
+	return nil
+}
+
+// checkTargetFile rejects names outside the data directory and existing files that
+// do not hold inventory entities (e.g. config.yaml). Caller must hold the lock.
+func (m *Manager) checkTargetFile(filename string) error {
+	if filename == "" || filename != filepath.Base(filename) || strings.HasPrefix(filename, ".") {
+		return fmt.Errorf("invalid data file name %q", filename)
+	}
+	if !m.isDataFile(filename) {
+		return fmt.Errorf("data file %s must have a .yaml, .yml, .json or .toml extension", filename)
+	}
+	if err := m.checkWritable(filename); err != nil {
+		return err
+	}
+	if _, tracked := m.files[filename]; !tracked {
+		if _, err := os.Stat(filepath.Join(m.dataDir, filename)); err == nil {
+			return fmt.Errorf("file %s exists but holds no inventory entities", filename)
+		}
+	}
+	return nil
+}
+
+// unregisterFile removes key from the document list of filename. Caller must hold the lock.
+func (m *Manager) unregisterFile(key entityKey, filename string) {
+	keys := m.files[filename]
+	for i, k := range keys {
+		if k == key {
+			m.files[filename] = append(keys[:i:i], keys[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package inventory
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFiles(t *testing.T) {
+	setup := func(t *testing.T) (*Manager, string) {
+		m, dir := setupTestManager(t)
+		for _, id := range []string{"web1", "web2", "db1"} {
+			h := NewHost(id, id, "10.0.0.1")
+			h.User = "root"
+			require.NoError(t, m.AddHost(h))
+		}
+		g := NewGroup("web")
+		g.AddHost("web1")
+		g.AddHost("web2")
+		require.NoError(t, m.AddGroup(g))
+		return m, dir
+	}
+
+	t.Run("collocate a group with its hosts", func(t *testing.T) {
+		m, dir := setup(t)
+
+		require.NoError(t, m.CollocateGroup("web", "web.yaml"))
+		assert.Equal(t, []string{"group/web", "host/web1", "host/web2"}, m.FileEntities("web.yaml"))
+		assert.NoFileExists(t, filepath.Join(dir, "host-web1.yaml"))
+		assert.NoFileExists(t, filepath.Join(dir, "group-web.yaml"))
+		assert.FileExists(t, filepath.Join(dir, "host-db1.yaml"))
+
+		// Updates keep the shared file
+		h, _ := m.GetHost("web2")
+		h.Description = "second"
+		require.NoError(t, m.UpdateHost(h))
+		file, _ := m.FileOf(TypeHost, "web2")
+		assert.Equal(t, "web.yaml", file)
+
+		reloaded := NewManager(dir)
+		require.NoError(t, reloaded.Load())
+		assert.Equal(t, []string{"group/web", "host/web1", "host/web2"}, reloaded.FileEntities("web.yaml"))
+		got, _ := reloaded.GetHost("web2")
+		assert.Equal(t, "second", got.Description)
+	})
+
+	t.Run("move a single entity", func(t *testing.T) {
+		m, dir := setup(t)
+		require.NoError(t, m.CollocateGroup("web", "web.yaml"))
+
+		require.NoError(t, m.MoveToFile(TypeHost, "db1", "web.yaml"))
+		require.NoError(t, m.MoveToFile(TypeHost, "web1", "host-web1.yaml"))
+		assert.Equal(t, []string{"group/web", "host/web2", "host/db1"}, m.FileEntities("web.yaml"))
+		assert.FileExists(t, filepath.Join(dir, "host-web1.yaml"))
+		assert.NoFileExists(t, filepath.Join(dir, "host-db1.yaml"))
+	})
+
+	t.Run("rejects unsafe targets", func(t *testing.T) {
+		m, dir := setup(t)
+		writeTestFile(t, dir, "config.yaml", "type: config\ntheme: dark\n")
+		require.NoError(t, m.Load())
+
+		assert.Error(t, m.MoveToFile(TypeHost, "web1", "config.yaml"))
+		assert.Error(t, m.MoveToFile(TypeHost, "web1", "../web.yaml"))
+		assert.Error(t, m.MoveToFile(TypeHost, "web1", "web.txt"))
+		assert.Error(t, m.MoveToFile(TypeHost, "missing", "web.yaml"))
+		assert.Error(t, m.CollocateGroup("missing", "web.yaml"))
+	})
+}
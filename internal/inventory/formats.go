@@ -0,0 +1,132 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the syntax of a data file, detected by extension.
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+	FormatTOML Format = "toml"
+)
+
+// tomlDocumentsKey holds the documents of multi-document TOML files, which have no
+// document separator of their own.
+const tomlDocumentsKey = "documents"
+
+// FormatOf returns the format of a data file by its extension.
+func FormatOf(filename string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return FormatYAML, true
+	case ".json":
+		return FormatJSON, true
+	case ".toml":
+		return FormatTOML, true
+	}
+	return "", false
+}
+
+// ToYAML converts the content of a data file to a YAML stream with one document per
+// entity. YAML content is returned unchanged. A JSON file holds one object or an
+// array of objects; a TOML file holds one table or a "documents" array of tables.
+func ToYAML(filename string, data []byte) ([]byte, error) {
+	format, _ := FormatOf(filename)
+
+	var docs []any
+	switch format {
+	case FormatJSON:
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON %s: %w", filename, err)
+		}
+		if list, ok := v.([]any); ok {
+			docs = list
+		} else {
+			docs = []any{v}
+		}
+
+	case FormatTOML:
+		var v map[string]any
+		if err := toml.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("failed to parse TOML %s: %w", filename, err)
+		}
+		if list, ok := v[tomlDocumentsKey].([]map[string]any); ok && len(v) == 1 {
+			for _, doc := range list {
+				docs = append(docs, doc)
+			}
+		} else {
+			docs = []any{v}
+		}
+
+	default:
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return nil, fmt.Errorf("failed to convert %s: %w", filename, err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to convert %s: %w", filename, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// FromYAML converts a YAML stream to the format of filename; see ToYAML for the layout.
+func FromYAML(filename string, data []byte) ([]byte, error) {
+	format, _ := FormatOf(filename)
+	if format != FormatJSON && format != FormatTOML {
+		return data, nil
+	}
+
+	var docs []map[string]any
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc map[string]any
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to convert %s: %w", filename, err)
+		}
+		docs = append(docs, doc)
+	}
+
+	var v any = docs
+	if len(docs) == 1 {
+		v = docs[0]
+	}
+
+	if format == FormatJSON {
+		out, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode JSON %s: %w", filename, err)
+		}
+		return append(out, '\n'), nil
+	}
+
+	if len(docs) > 1 {
+		v = map[string]any{tomlDocumentsKey: docs}
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode TOML %s: %w", filename, err)
+	}
+	return buf.Bytes(), nil
+}
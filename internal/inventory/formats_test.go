@@ -0,0 +1,122 @@
+package inventory
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatOf(t *testing.T) {
+	cases := map[string]Format{
+		"hosts.yaml": FormatYAML,
+		"hosts.YML":  FormatYAML,
+		"hosts.json": FormatJSON,
+		"hosts.toml": FormatTOML,
+	}
+	for name, want := range cases {
+		got, ok := FormatOf(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, want, got, name)
+	}
+
+	_, ok := FormatOf("hosts.txt")
+	assert.False(t, ok)
+}
+
+func TestConvertFormats(t *testing.T) {
+	stream := []byte("type: group\nname: web\n---\ntype: host\nid: web1\nport: 2222\n")
+
+	for _, name := range []string{"web.json", "web.toml"} {
+		t.Run(name, func(t *testing.T) {
+			out, err := FromYAML(name, stream)
+			require.NoError(t, err)
+
+			back, err := ToYAML(name, out)
+			require.NoError(t, err)
+			entities, _, err := parseEntities(name, back, true)
+			require.NoError(t, err)
+			require.Len(t, entities, 2)
+			assert.Equal(t, "web", entities[0].(*Group).Name)
+			assert.Equal(t, 2222, entities[1].(*Host).Port)
+		})
+	}
+
+	t.Run("single JSON document is an object", func(t *testing.T) {
+		out, err := FromYAML("web1.json", []byte("type: host\nid: web1\n"))
+		require.NoError(t, err)
+		var v map[string]any
+		require.NoError(t, json.Unmarshal(out, &v))
+		assert.Equal(t, "web1", v["id"])
+	})
+
+	t.Run("YAML passes through", func(t *testing.T) {
+		out, err := ToYAML("web.yaml", stream)
+		require.NoError(t, err)
+		assert.Equal(t, stream, out)
+	})
+
+	t.Run("syntax errors", func(t *testing.T) {
+		_, err := ToYAML("web.json", []byte(`{"type": `))
+		assert.Error(t, err)
+		_, err = ToYAML("web.toml", []byte(`type = `))
+		assert.Error(t, err)
+	})
+}
+
+func TestManagerFormats(t *testing.T) {
+	m, dir := setupTestManager(t)
+	writeTestFile(t, dir, "web.json", `[
+  {"type": "group", "name": "web", "host_ids": ["web1"]},
+  {"type": "host", "id": "web1", "name": "web1", "address": "10.0.0.1", "user": "deploy", "port": 22}
+]`)
+	writeTestFile(t, dir, "db.toml", `type = "host"
+id = "db1"
+name = "db1"
+address = "10.0.0.5"
+user = "postgres"
+port = 5432
+`)
+	require.NoError(t, m.Load())
+
+	db, ok := m.GetHost("db1")
+	require.True(t, ok)
+	assert.Equal(t, 5432, db.Port)
+	g, ok := m.GetGroup("web")
+	require.True(t, ok)
+	assert.Equal(t, []string{"web1"}, g.HostIDs)
+
+	t.Run("updates keep the file format", func(t *testing.T) {
+		web, _ := m.GetHost("web1")
+		web.Address = "10.0.0.9"
+		require.NoError(t, m.UpdateHost(web))
+
+		data, err := os.ReadFile(filepath.Join(dir, "web.json"))
+		require.NoError(t, err)
+		var docs []map[string]any
+		require.NoError(t, json.Unmarshal(data, &docs))
+		require.Len(t, docs, 2)
+		assert.Equal(t, "10.0.0.9", docs[1]["address"])
+
+		db.Port = 5433
+		require.NoError(t, m.UpdateHost(db))
+		data, err = os.ReadFile(filepath.Join(dir, "db.toml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "port = 5433")
+
+		reloaded := NewManager(dir)
+		require.NoError(t, reloaded.Load())
+		got, _ := reloaded.GetHost("web1")
+		assert.Equal(t, "10.0.0.9", got.Address)
+	})
+
+	t.Run("entities can move into JSON files", func(t *testing.T) {
+		require.NoError(t, m.MoveToFile(TypeHost, "db1", "web.json"))
+		file, _ := m.FileOf(TypeHost, "db1")
+		assert.Equal(t, "web.json", file)
+		assert.NoFileExists(t, filepath.Join(dir, "db.toml"))
+	})
+}
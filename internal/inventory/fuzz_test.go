@@ -0,0 +1,85 @@
+package inventory
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlSeeds are documents exercising the separators, scalars and entity types the
+// fuzzers start from.
+var yamlSeeds = []string{
+	"type: host\nid: web1\nname: web1\naddress: 10.0.0.1\nport: 22\n",
+	"type: group\nname: web\nhost_ids: [web1]\n---\ntype: host\nid: web1\nname: web1\naddress: 10.0.0.1\n",
+	"type: host\nid: web1\nnotes: |\n  ---\n  not a separator\n---\ntype: group\nname: web\n",
+	"# comment\n--- # first\ntype: credential\nid: admin\nname: Admin\nuser: root\n...\n--- {type: group, name: db}\n",
+	"type: command\nid: up\nname: up\ncommand: \"echo ---\"\n---\n---\n",
+	"type: config\ndata_dir: /tmp\n",
+	"type: theme\nid: t\nname: t\npalette: {foreground: \"#fff\"}\n",
+	"type: workflow\nid: w\nname: w\nsteps:\n  - name: s\n    kind: exec\n    command: x\n",
+	"type: host\nid: [\n",
+	"a: \"x\n---\ny\"\n",
+	"--- |\n  text\n--- >\n  folded\n",
+	"%YAML 1.2\n---\ntype: group\nname: web\n",
+	"",
+}
+
+func FuzzSplitYAMLDocuments(f *testing.F) {
+	for _, seed := range yamlSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		docs, err := splitYAMLDocumentLines(data)
+		if err != nil {
+			return
+		}
+		last := 0
+		for _, doc := range docs {
+			if doc.line <= last {
+				t.Fatalf("document lines out of order: %d after %d", doc.line, last)
+			}
+			last = doc.line
+
+			// Every document of a valid stream is valid on its own.
+			var node yaml.Node
+			if err := yaml.Unmarshal(doc.data, &node); err != nil {
+				t.Fatalf("document at line %d does not parse: %v\n%q", doc.line, err, doc.data)
+			}
+		}
+	})
+}
+
+func FuzzLoadEntity(f *testing.F) {
+	for _, seed := range yamlSeeds {
+		f.Add([]byte(seed), false)
+		f.Add([]byte(seed), true)
+	}
+	f.Fuzz(func(t *testing.T, data []byte, strict bool) {
+		e, err := loadEntity(data, strict)
+		if err == nil && e != nil {
+			// Loaded entities can be validated and saved again.
+			_ = e.Validate()
+			if _, err := yaml.Marshal(e); err != nil {
+				t.Fatalf("loaded entity does not marshal: %v", err)
+			}
+		}
+	})
+}
+
+func FuzzParseEntities(f *testing.F) {
+	for _, seed := range yamlSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		entities, nodes, err := parseEntities("fuzz.yaml", data, false)
+		if err != nil {
+			if _, ok := err.(*ParseError); !ok {
+				t.Fatalf("error is not a *ParseError: %T %v", err, err)
+			}
+			return
+		}
+		if len(entities) != len(nodes) {
+			t.Fatalf("%d entities but %d nodes", len(entities), len(nodes))
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package inventory
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Placeholders of gateway URLs, replaced with the address and port of the host.
+const (
+	GatewayHostPlaceholder = "{host}"
+	GatewayPortPlaceholder = "{port}"
+)
+
+// ValidateGatewayURL checks a WebSocket gateway URL: ws, wss, http or https with a
+// host. http and https are the same as ws and wss, for gateways documented with
+// their https address.
+func ValidateGatewayURL(raw string) error {
+	u, err := url.Parse(strings.NewReplacer(GatewayHostPlaceholder, "host", GatewayPortPlaceholder, "22").Replace(raw))
+	if err != nil {
+		return fmt.Errorf("invalid gateway URL: %w", err)
+	}
+	switch u.Scheme {
+	case "ws", "wss", "http", "https":
+	default:
+		return fmt.Errorf("invalid gateway URL %s: scheme must be ws, wss, http or https", redactURL(u))
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid gateway URL %s: missing host", redactURL(u))
+	}
+	return nil
+}
+
+// redactURL returns u without the password of its user info.
+func redactURL(u *url.URL) string {
+	if _, ok := u.User.Password(); ok {
+		return u.Redacted()
+	}
+	return u.String()
+}
+
+// GatewayTarget returns the gateway URL of the connection with the placeholders
+// replaced by its address and port, or "" without a gateway. The address is
+// query-escaped, which also keeps IPv6 literals intact in paths.
+func (c *ResolvedConnection) GatewayTarget() string {
+	if c.GatewayURL == "" {
+		return ""
+	}
+	return strings.NewReplacer(
+		GatewayHostPlaceholder, url.QueryEscape(c.Address),
+		GatewayPortPlaceholder, strconv.Itoa(c.Port),
+	).Replace(c.GatewayURL)
+}
@@ -12,13 +12,24 @@ var (
 
 // Group represents a collection of hosts that can be managed together.
 type Group struct {
-	Type        DocumentType      `yaml:"type"`
-	Name        string            `yaml:"name"`
-	Description string            `yaml:"description,omitempty"`
-	HostIDs     []string          `yaml:"host_ids"`
-	Vars        map[string]string `yaml:"vars,omitempty"`
+	Type        DocumentType `yaml:"type"`
+	Name        string       `yaml:"name"`
+	Description string       `yaml:"description,omitempty"`
+	Notes       string       `yaml:"notes,omitempty"`
+	HostIDs     []string     `yaml:"host_ids"`
+	// Order is the order the hosts are resolved in: manual (the order of
+	// HostIDs, default), name or address.
+	Order GroupOrder `yaml:"order,omitempty"`
+	// Priorities rank member hosts, e.g. primaries above standbys; unset members
+	// have DefaultPriority and priority 0 marks passive members.
+	Priorities map[string]int    `yaml:"priorities,omitempty"`
+	Vars       map[string]string `yaml:"vars,omitempty"`
 
 	ChildGroupNames []string `yaml:"child_groups,omitempty"`
+
+	// RequiresNetwork names networks the hosts of the group, including those of
+	// child groups, can only be reached through (see NetworkRequirement).
+	RequiresNetwork []string `yaml:"requires_network,omitempty"`
 }
 
 // NewGroup creates a new Group with basic information.
@@ -60,7 +71,10 @@ func (g *Group) Validate() error {
 	if g.Name == "" {
 		return fmt.Errorf("group name cannot be empty")
 	}
-	return nil
+	if err := g.Order.Validate(); err != nil {
+		return fmt.Errorf("group %s: %w", g.Name, err)
+	}
+	return g.validatePriorities()
 }
 
 // Clone creates a deep copy of the Group.
@@ -74,6 +88,15 @@ func (g *Group) Clone() interface{} {
 	for k, v := range g.Vars {
 		clone.Vars[k] = v
 	}
+	if g.RequiresNetwork != nil {
+		clone.RequiresNetwork = append([]string(nil), g.RequiresNetwork...)
+	}
+	if g.Priorities != nil {
+		clone.Priorities = make(map[string]int, len(g.Priorities))
+		for id, p := range g.Priorities {
+			clone.Priorities[id] = p
+		}
+	}
 	return &clone
 }
 
@@ -106,6 +129,7 @@ func (g *Group) RemoveHost(hostID string) {
 	for i, id := range g.HostIDs {
 		if id == hostID {
 			g.HostIDs = append(g.HostIDs[:i], g.HostIDs[i+1:]...)
+			delete(g.Priorities, hostID)
 			return
 		}
 	}
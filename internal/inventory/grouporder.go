@@ -0,0 +1,125 @@
+package inventory
+
+import (
+	"cmp"
+	"fmt"
+	"net/netip"
+	"slices"
+)
+
+// GroupOrder is the order in which the hosts of a group are resolved, which rolling
+// operations and listings follow.
+type GroupOrder string
+
+const (
+	// GroupOrderManual keeps the order of host_ids (the default).
+	GroupOrderManual GroupOrder = "manual"
+	// GroupOrderName sorts hosts by name, then ID.
+	GroupOrderName GroupOrder = "name"
+	// GroupOrderAddress sorts hosts by IP address; hostnames follow in
+	// alphabetical order.
+	GroupOrderAddress GroupOrder = "address"
+)
+
+// Validate checks that the order is a known value. An empty order is treated as
+// GroupOrderManual.
+func (o GroupOrder) Validate() error {
+	switch o {
+	case "", GroupOrderManual, GroupOrderName, GroupOrderAddress:
+		return nil
+	}
+	return fmt.Errorf("invalid group order: %s", o)
+}
+
+// SortHosts sorts hosts in place by the order. The manual order leaves them as
+// they are; the sort is stable.
+func SortHosts(hosts []*Host, order GroupOrder) {
+	switch order {
+	case GroupOrderName:
+		slices.SortStableFunc(hosts, func(a, b *Host) int {
+			return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+		})
+	case GroupOrderAddress:
+		slices.SortStableFunc(hosts, func(a, b *Host) int {
+			return cmp.Or(compareAddresses(a.Address, b.Address), cmp.Compare(a.ID, b.ID))
+		})
+	}
+}
+
+// compareAddresses orders IP addresses numerically, IPv4 before IPv6, before
+// hostnames, which are compared as strings.
+func compareAddresses(a, b string) int {
+	ipA, errA := netip.ParseAddr(a)
+	ipB, errB := netip.ParseAddr(b)
+	switch {
+	case errA == nil && errB == nil:
+		return ipA.Compare(ipB)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return cmp.Compare(a, b)
+}
+
+// MoveHost moves a member of the group to position index of host_ids, clamped to
+// the list. It reports whether the group contains the host.
+func (g *Group) MoveHost(hostID string, index int) bool {
+	i := slices.Index(g.HostIDs, hostID)
+	if i < 0 {
+		return false
+	}
+	g.HostIDs = slices.Delete(g.HostIDs, i, i+1)
+	index = max(0, min(index, len(g.HostIDs)))
+	g.HostIDs = slices.Insert(g.HostIDs, index, hostID)
+	return true
+}
+
+// ===== Manager integration =====
+
+// orderedMembers returns the direct members of a group in its order. Members that
+// are not in the inventory keep their place relative to each other at the end.
+// Caller must hold the lock.
+func (m *Manager) orderedMembers(g *Group) []string {
+	if g.Order == "" || g.Order == GroupOrderManual {
+		return g.HostIDs
+	}
+	return m.sortHostIDs(g.HostIDs, g.Order)
+}
+
+// sortHostIDs returns a sorted copy of host IDs. Caller must hold the lock.
+func (m *Manager) sortHostIDs(ids []string, order GroupOrder) []string {
+	hosts := make([]*Host, 0, len(ids))
+	var unknown []string
+	for _, id := range ids {
+		if h, ok := m.hosts[id]; ok {
+			hosts = append(hosts, h)
+		} else {
+			unknown = append(unknown, id)
+		}
+	}
+	SortHosts(hosts, order)
+
+	sorted := make([]string, 0, len(ids))
+	for _, h := range hosts {
+		sorted = append(sorted, h.ID)
+	}
+	return append(sorted, unknown...)
+}
+
+// MoveGroupHost moves a host of a group to position index of its host_ids and
+// saves the group. Groups not in the manual order keep resolving in their sorted
+// order until it is switched to manual.
+func (m *Manager) MoveGroupHost(group, hostID string, index int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.groups[group]
+	if !ok {
+		return fmt.Errorf("group %s not found", group)
+	}
+	if !g.MoveHost(hostID, index) {
+		return fmt.Errorf("group %s: host %s is not a member", group, hostID)
+	}
+	return m.saveFile(m.sources[keyOf(g)])
+}
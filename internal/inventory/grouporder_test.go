@@ -0,0 +1,84 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupOrder(t *testing.T) {
+	setup := func(t *testing.T) *Manager {
+		m, _ := setupTestManager(t)
+		for _, h := range []struct{ id, name, address string }{
+			{"web3", "alpha", "10.0.0.10"},
+			{"web1", "charlie", "10.0.0.9"},
+			{"web2", "bravo", "web2.example.com"},
+			{"db1", "delta", "10.0.1.1"},
+		} {
+			host := NewHost(h.id, h.name, h.address)
+			host.User = "deploy"
+			require.NoError(t, m.AddHost(host))
+		}
+		db := NewGroup("db")
+		db.HostIDs = []string{"db1"}
+		require.NoError(t, m.AddGroup(db))
+		web := NewGroup("web")
+		web.HostIDs = []string{"web2", "web3", "web1", "gone"}
+		web.ChildGroupNames = []string{"db"}
+		require.NoError(t, m.AddGroup(web))
+		return m
+	}
+
+	t.Run("manual order is kept", func(t *testing.T) {
+		m := setup(t)
+		ids, err := m.ResolveGroupHosts("web")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web2", "web3", "web1", "gone", "db1"}, ids)
+
+		reloaded := NewManager(m.GetDataDir())
+		require.NoError(t, reloaded.Load())
+		ids, err = reloaded.ResolveGroupHosts("web")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web2", "web3", "web1", "gone", "db1"}, ids)
+	})
+
+	t.Run("sort policies", func(t *testing.T) {
+		m := setup(t)
+		web, _ := m.GetGroup("web")
+		web.Order = GroupOrderName
+		require.NoError(t, m.UpdateGroup(web))
+		ids, err := m.ResolveGroupHosts("web")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web3", "web2", "web1", "db1", "gone"}, ids)
+
+		web.Order = GroupOrderAddress
+		require.NoError(t, m.UpdateGroup(web))
+		ids, err = m.ResolveGroupHosts("web")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web1", "web3", "db1", "web2", "gone"}, ids)
+
+		ids, err = m.ResolveTargetSpec("group:web")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web1", "web3", "db1", "web2", "gone"}, ids)
+
+		web.Order = "random"
+		assert.EqualError(t, m.UpdateGroup(web), "group web: invalid group order: random")
+	})
+
+	t.Run("move hosts", func(t *testing.T) {
+		m := setup(t)
+		require.NoError(t, m.MoveGroupHost("web", "web1", 0))
+		require.NoError(t, m.MoveGroupHost("web", "web2", 99))
+		g, _ := m.GetGroup("web")
+		assert.Equal(t, []string{"web1", "web3", "gone", "web2"}, g.HostIDs)
+
+		assert.EqualError(t, m.MoveGroupHost("web", "db1", 0), "group web: host db1 is not a member")
+		assert.EqualError(t, m.MoveGroupHost("nope", "db1", 0), "group nope not found")
+
+		reloaded := NewManager(m.GetDataDir())
+		require.NoError(t, reloaded.Load())
+		g, _ = reloaded.GetGroup("web")
+		assert.Equal(t, []string{"web1", "web3", "gone", "web2"}, g.HostIDs)
+	})
+}
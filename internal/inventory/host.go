@@ -19,6 +19,12 @@ type Host struct {
 	ID          string `yaml:"id"`
 	Name        string `yaml:"name"`
 	Description string `yaml:"description,omitempty"`
+	// Notes holds free-form notes, e.g. a short runbook snippet; longer documents
+	// go to a markdown runbook (see Manager.WriteRunbook).
+	Notes string `yaml:"notes,omitempty"`
+
+	// Connection selects how the host is reached (default ssh)
+	Connection HostConnection `yaml:"connection,omitempty"`
 
 	// SSH connection information
 	Address string `yaml:"address"`
@@ -32,15 +38,75 @@ type Host struct {
 	KeyPath  string `yaml:"key_path,omitempty"`
 	Password string `yaml:"password,omitempty"`
 
+	// JumpHostID routes connections through another inventory host (ProxyJump)
+	JumpHostID string `yaml:"jump_host_id,omitempty"`
+
+	// RelayHostID reaches the host through the gossher-agent on another inventory
+	// host, for segments this machine cannot reach (see package relay)
+	RelayHostID string `yaml:"relay_host_id,omitempty"`
+
+	// Relations link the host to the hosts it depends on or replicates
+	Relations []Relation `yaml:"relations,omitempty"`
+
+	// Pinned host keys; connections are rejected if the server presents another key
+	HostKeys []HostKey `yaml:"host_keys,omitempty"`
+
+	// RequiresNetwork names networks (see NetworkRequirement) checked before connecting
+	RequiresNetwork []string `yaml:"requires_network,omitempty"`
+
+	// Knock is a port knocking sequence sent before each connection (see KnockStep)
+	Knock []KnockStep `yaml:"knock,omitempty"`
+
+	// GatewayURL tunnels the SSH connection through a WebSocket gateway, for networks
+	// that only allow HTTPS egress; {host} and {port} are replaced with the address
+	// and port of the host
+	GatewayURL string `yaml:"gateway_url,omitempty"`
+
+	// Timeouts override the global connect and command timeouts for this host
+	Timeouts Timeouts `yaml:"timeouts,omitempty"`
+
+	// TrashDir is where remote deletes move files on this host instead of removing
+	// them; relative paths are below the home directory of the connecting user
+	// (default executor.DefaultTrashDir)
+	TrashDir string `yaml:"trash_dir,omitempty"`
+
+	// Groups declares groups the host belongs to, in addition to the members
+	// listed by the groups themselves; both sides are kept in sync when hosts and
+	// groups are stored
+	Groups []string `yaml:"groups,omitempty"`
+
 	// Classification and metadata
 	Tags []string          `yaml:"tags,omitempty"`
 	Vars map[string]string `yaml:"vars,omitempty"`
 
+	// Lifecycle tracks whether the host is still in use (default active); see
+	// Manager.UpdateLifecycle. StaleSince is when it became stale or retired.
+	Lifecycle  Lifecycle `yaml:"lifecycle,omitempty"`
+	StaleSince time.Time `yaml:"stale_since,omitempty"`
+	// Source names the discovery provider that imported the host.
+	Source string `yaml:"source,omitempty"`
+
+	// Facts gathered from the host and connection history
+	Facts         map[string]string `yaml:"facts,omitempty"`
+	FactsUpdated  time.Time         `yaml:"facts_updated,omitempty"`
+	LastConnected time.Time         `yaml:"last_connected,omitempty"`
+
 	// Runtime state (not saved to YAML)
 	Status       HostStatus `yaml:"-"`
 	LastPingTime time.Time  `yaml:"-"`
 }
 
+// HostConnection selects how commands reach a host.
+type HostConnection string
+
+const (
+	// ConnectionSSH runs commands over SSH.
+	ConnectionSSH HostConnection = "ssh"
+	// ConnectionLocal runs commands on this machine, e.g. to build an artifact before
+	// deploying it; address, port and authentication are not used.
+	ConnectionLocal HostConnection = "local"
+)
+
 // HostStatus represents the current state of a host.
 type HostStatus int
 
@@ -78,6 +144,19 @@ func NewHost(id, name, address string) *Host {
 	}
 }
 
+// NewLocalHost creates a pseudo-host that runs commands on this machine.
+func NewLocalHost(id, name string) *Host {
+	h := NewHost(id, name, "localhost")
+	h.Connection = ConnectionLocal
+	h.Port = 0
+	return h
+}
+
+// IsLocal reports whether the host runs commands on this machine.
+func (h *Host) IsLocal() bool {
+	return h.Connection == ConnectionLocal
+}
+
 // NewHostWithCredential creates a new Host using a credential reference.
 func NewHostWithCredential(id, name, address, credentialID string) *Host {
 	h := NewHost(id, name, address)
@@ -116,6 +195,36 @@ func (h *Host) Validate() error {
 	if h.Name == "" {
 		return fmt.Errorf("host %s: name cannot be empty", h.ID)
 	}
+	if err := h.Lifecycle.Validate(); err != nil {
+		return fmt.Errorf("host %s: %w", h.ID, err)
+	}
+	if err := h.validateRelations(); err != nil {
+		return err
+	}
+	if err := h.validateGroups(); err != nil {
+		return err
+	}
+
+	switch h.Connection {
+	case "", ConnectionSSH:
+	case ConnectionLocal:
+		if h.JumpHostID != "" {
+			return fmt.Errorf("host %s: local hosts cannot use a jump host", h.ID)
+		}
+		if h.RelayHostID != "" {
+			return fmt.Errorf("host %s: local hosts cannot use a relay host", h.ID)
+		}
+		if h.GatewayURL != "" {
+			return fmt.Errorf("host %s: local hosts cannot use a gateway", h.ID)
+		}
+		if err := h.Timeouts.Validate(); err != nil {
+			return fmt.Errorf("host %s: %w", h.ID, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("host %s: invalid connection: %s", h.ID, h.Connection)
+	}
+
 	if h.Address == "" {
 		return fmt.Errorf("host %s: address cannot be empty", h.ID)
 	}
@@ -130,6 +239,37 @@ func (h *Host) Validate() error {
 		return fmt.Errorf("host %s: must have either credential_id or user", h.ID)
 	}
 
+	if h.JumpHostID == h.ID {
+		return fmt.Errorf("host %s: cannot use itself as jump host", h.ID)
+	}
+	if h.RelayHostID == h.ID {
+		return fmt.Errorf("host %s: cannot use itself as relay host", h.ID)
+	}
+
+	if err := ValidateKnock(h.Knock); err != nil {
+		return fmt.Errorf("host %s: %w", h.ID, err)
+	}
+
+	if h.GatewayURL != "" {
+		if err := ValidateGatewayURL(h.GatewayURL); err != nil {
+			return fmt.Errorf("host %s: %w", h.ID, err)
+		}
+		// The gateway is the first hop; behind a jump or relay host it is not used.
+		if h.JumpHostID != "" || h.RelayHostID != "" {
+			return fmt.Errorf("host %s: hosts behind a jump or relay host cannot use a gateway", h.ID)
+		}
+		if len(h.Knock) > 0 {
+			return fmt.Errorf("host %s: hosts reached through a gateway cannot use port knocking", h.ID)
+		}
+	}
+
+	if err := h.Timeouts.Validate(); err != nil {
+		return fmt.Errorf("host %s: %w", h.ID, err)
+	}
+	if h.Timeouts.Total != 0 {
+		return fmt.Errorf("host %s: total timeout applies to whole runs and cannot be set per host", h.ID)
+	}
+
 	return nil
 }
 
@@ -142,6 +282,30 @@ func (h *Host) Clone() interface{} {
 	for k, v := range h.Vars {
 		clone.Vars[k] = v
 	}
+	if h.Facts != nil {
+		clone.Facts = make(map[string]string, len(h.Facts))
+		for k, v := range h.Facts {
+			clone.Facts[k] = v
+		}
+	}
+	if h.Relations != nil {
+		clone.Relations = make([]Relation, len(h.Relations))
+		copy(clone.Relations, h.Relations)
+	}
+	if h.HostKeys != nil {
+		clone.HostKeys = make([]HostKey, len(h.HostKeys))
+		copy(clone.HostKeys, h.HostKeys)
+	}
+	if h.RequiresNetwork != nil {
+		clone.RequiresNetwork = append([]string(nil), h.RequiresNetwork...)
+	}
+	if h.Groups != nil {
+		clone.Groups = append([]string(nil), h.Groups...)
+	}
+	if h.Knock != nil {
+		clone.Knock = make([]KnockStep, len(h.Knock))
+		copy(clone.Knock, h.Knock)
+	}
 	return &clone
 }
 
@@ -200,3 +364,8 @@ func (h *Host) SSHAddress() string {
 func (h *Host) UsesCredential() bool {
 	return h.CredentialID != ""
 }
+
+// ExpandedKeyPath returns the inline key path with ~ and environment variables expanded.
+func (h *Host) ExpandedKeyPath() string {
+	return ExpandPath(h.KeyPath)
+}
@@ -0,0 +1,109 @@
+package inventory
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Hosts may declare their groups with `groups: [web, prod]`, which is more natural
+// for hosts written by discovery than editing every group. Declarations and the
+// host_ids of groups are reconciled by these rules:
+//
+//   - A host is a member of a group when the group lists it or the host declares
+//     the group; on load, declared members missing from host_ids are added.
+//   - Adding or updating a host adds it to its declared groups, creating groups
+//     that do not exist, and removes it from groups it no longer declares.
+//   - Removing a host from a group's host_ids, or removing the group, also drops
+//     the group from the host's declaration, so neither side re-adds it.
+//   - Hosts listed by a group without declaring it keep their declaration as is.
+
+// validateGroups checks the declared groups of a host.
+func (h *Host) validateGroups() error {
+	for i, name := range h.Groups {
+		if name == "" {
+			return fmt.Errorf("host %s: group name cannot be empty", h.ID)
+		}
+		if slices.Contains(h.Groups[:i], name) {
+			return fmt.Errorf("host %s: duplicate group %s", h.ID, name)
+		}
+	}
+	return nil
+}
+
+// reconcileDeclaredGroups adds loaded hosts to the existing groups they declare.
+// Groups are not rewritten until they are saved. Caller must hold the lock.
+func (m *Manager) reconcileDeclaredGroups() {
+	for _, id := range sortedKeys(m.hosts) {
+		for _, name := range m.hosts[id].Groups {
+			if g, ok := m.groups[name]; ok && !g.HasHost(id) {
+				g.AddHost(id)
+			}
+		}
+	}
+}
+
+// syncDeclaredGroups applies the group declaration of a host being stored: it
+// joins the declared groups, creating missing ones, and leaves the groups old
+// declared but h no longer does. old is nil for new hosts. Files to save are
+// recorded in dirty. Caller must hold the lock.
+func (m *Manager) syncDeclaredGroups(h, old *Host, dirty map[string]bool) error {
+	for i, name := range h.Groups {
+		g, ok := m.groups[name]
+		if !ok {
+			id, err := m.checkNewID(TypeGroup, name)
+			if err != nil {
+				return fmt.Errorf("host %s: %w", h.ID, err)
+			}
+			h.Groups[i] = id
+			if g, ok = m.groups[id]; !ok {
+				g = NewGroup(id)
+				if err := m.store(g); err != nil {
+					return err
+				}
+			}
+		}
+		if !g.HasHost(h.ID) {
+			g.AddHost(h.ID)
+			dirty[m.sources[keyOf(g)]] = true
+		}
+	}
+
+	if old == nil {
+		return nil
+	}
+	for _, name := range old.Groups {
+		if slices.Contains(h.Groups, name) {
+			continue
+		}
+		if g, ok := m.groups[name]; ok && g.HasHost(h.ID) {
+			g.RemoveHost(h.ID)
+			dirty[m.sources[keyOf(g)]] = true
+		}
+	}
+	return nil
+}
+
+// dropDeclaredGroup removes group from the declaration of the given hosts, or of
+// every host if hostIDs is nil. Caller must hold the lock.
+func (m *Manager) dropDeclaredGroup(group string, hostIDs []string, dirty map[string]bool) {
+	for id, h := range m.hosts {
+		if hostIDs != nil && !slices.Contains(hostIDs, id) {
+			continue
+		}
+		if i := slices.Index(h.Groups, group); i >= 0 {
+			h.Groups = slices.Delete(h.Groups, i, i+1)
+			dirty[m.sources[keyOf(h)]] = true
+		}
+	}
+}
+
+// renameDeclaredGroup rewrites the group declarations of hosts after a group was
+// renamed. Caller must hold the lock.
+func (m *Manager) renameDeclaredGroup(oldName, newName string, dirty map[string]bool) {
+	for _, h := range m.hosts {
+		if i := slices.Index(h.Groups, oldName); i >= 0 {
+			h.Groups[i] = newName
+			dirty[m.sources[keyOf(h)]] = true
+		}
+	}
+}
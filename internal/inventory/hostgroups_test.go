@@ -0,0 +1,113 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeclaredGroups(t *testing.T) {
+	newHost := func(id string, groups ...string) *Host {
+		h := NewHost(id, id, "10.0.0.1")
+		h.User = "deploy"
+		h.Groups = groups
+		return h
+	}
+
+	t.Run("load adds declared members", func(t *testing.T) {
+		m, dir := setupTestManager(t)
+		writeTestFile(t, dir, "inventory.yaml", `type: group
+name: web
+host_ids: [web1]
+---
+type: host
+id: web2
+name: web2
+address: 10.0.0.2
+port: 22
+user: deploy
+groups: [web, missing]
+`)
+		require.NoError(t, m.Load())
+		g, _ := m.GetGroup("web")
+		assert.Equal(t, []string{"web1", "web2"}, g.HostIDs)
+		assert.Contains(t, m.HostGroups("web2"), "web")
+
+		var findings []string
+		for _, f := range m.Doctor() {
+			if f.Subject == "host web2" {
+				findings = append(findings, f.Message)
+			}
+		}
+		assert.Contains(t, findings, "declared group missing not found")
+	})
+
+	t.Run("adding a host joins and creates groups", func(t *testing.T) {
+		m, _ := setupTestManager(t)
+		require.NoError(t, m.AddGroup(NewGroup("web")))
+		require.NoError(t, m.AddHost(newHost("web1", "web", "prod")))
+
+		web, _ := m.GetGroup("web")
+		assert.Equal(t, []string{"web1"}, web.HostIDs)
+		prod, ok := m.GetGroup("prod")
+		require.True(t, ok)
+		assert.Equal(t, []string{"web1"}, prod.HostIDs)
+
+		reloaded := NewManager(m.GetDataDir())
+		require.NoError(t, reloaded.Load())
+		prod, ok = reloaded.GetGroup("prod")
+		require.True(t, ok)
+		assert.Equal(t, []string{"web1"}, prod.HostIDs)
+	})
+
+	t.Run("updating a host leaves undeclared groups", func(t *testing.T) {
+		m, _ := setupTestManager(t)
+		ops := NewGroup("ops")
+		ops.AddHost("web1")
+		require.NoError(t, m.AddGroup(ops))
+		require.NoError(t, m.AddHost(newHost("web1", "web", "prod")))
+
+		h, _ := m.GetHost("web1")
+		h.Groups = []string{"web"}
+		require.NoError(t, m.UpdateHost(h))
+
+		prod, _ := m.GetGroup("prod")
+		assert.Empty(t, prod.HostIDs)
+		ops, _ = m.GetGroup("ops")
+		assert.Equal(t, []string{"web1"}, ops.HostIDs, "membership listed by the group is kept")
+	})
+
+	t.Run("group side removal drops the declaration", func(t *testing.T) {
+		m, _ := setupTestManager(t)
+		require.NoError(t, m.AddHost(newHost("web1", "web", "prod")))
+
+		web, _ := m.GetGroup("web")
+		web.RemoveHost("web1")
+		require.NoError(t, m.UpdateGroup(web))
+		h, _ := m.GetHost("web1")
+		assert.Equal(t, []string{"prod"}, h.Groups)
+
+		require.NoError(t, m.RemoveGroup("prod"))
+		h, _ = m.GetHost("web1")
+		assert.Empty(t, h.Groups)
+
+		reloaded := NewManager(m.GetDataDir())
+		require.NoError(t, reloaded.Load())
+		web, _ = reloaded.GetGroup("web")
+		assert.Empty(t, web.HostIDs)
+	})
+
+	t.Run("renaming a group rewrites declarations", func(t *testing.T) {
+		m, _ := setupTestManager(t)
+		require.NoError(t, m.AddHost(newHost("web1", "web")))
+		require.NoError(t, m.RenameGroup("web", "frontend"))
+		h, _ := m.GetHost("web1")
+		assert.Equal(t, []string{"frontend"}, h.Groups)
+	})
+
+	t.Run("rejects invalid declarations", func(t *testing.T) {
+		assert.EqualError(t, newHost("web1", "web", "web").Validate(), "host web1: duplicate group web")
+		assert.EqualError(t, newHost("web1", "").Validate(), "host web1: group name cannot be empty")
+	})
+}
@@ -0,0 +1,291 @@
+package inventory
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IDPolicy controls how entity IDs (and group names) are checked when entities are created.
+type IDPolicy string
+
+const (
+	// IDPolicyNone accepts IDs as given.
+	IDPolicyNone IDPolicy = "none"
+	// IDPolicyNormalize rewrites IDs to their normalized form ("Web 1" -> "web-1").
+	IDPolicyNormalize IDPolicy = "normalize"
+	// IDPolicyStrict rejects IDs that are not already normalized.
+	IDPolicyStrict IDPolicy = "strict"
+)
+
+// Validate checks that the policy is a known value. An empty policy is treated as IDPolicyNone.
+func (p IDPolicy) Validate() error {
+	switch p {
+	case "", IDPolicyNone, IDPolicyNormalize, IDPolicyStrict:
+		return nil
+	}
+	return fmt.Errorf("invalid id policy: %s", p)
+}
+
+// NormalizeID converts an ID to lowercase slug form: letters, digits, '.', '_' and '-' only,
+// with every other run of characters collapsed into a single '-'.
+func NormalizeID(id string) string {
+	var b strings.Builder
+	pendingDash := false
+
+	for _, r := range strings.ToLower(strings.TrimSpace(id)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '_':
+			if pendingDash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingDash = false
+			b.WriteRune(r)
+		default:
+			pendingDash = true
+		}
+	}
+
+	return b.String()
+}
+
+// IsNormalizedID reports whether an ID is already in normalized form.
+func IsNormalizedID(id string) bool {
+	return id != "" && NormalizeID(id) == id
+}
+
+// applyIDPolicy returns the ID to store for a new entity under the given policy.
+func applyIDPolicy(policy IDPolicy, kind DocumentType, id string) (string, error) {
+	switch policy {
+	case IDPolicyNormalize:
+		normalized := NormalizeID(id)
+		if normalized == "" {
+			return "", fmt.Errorf("%s ID %q has no valid characters", kind, id)
+		}
+		return normalized, nil
+	case IDPolicyStrict:
+		if !IsNormalizedID(id) {
+			return "", fmt.Errorf("%s ID %q is not normalized (expected %q)", kind, id, NormalizeID(id))
+		}
+	}
+	return id, nil
+}
+
+// ===== Manager integration =====
+
+// SetIDPolicy sets the policy applied when new entities are added.
+func (m *Manager) SetIDPolicy(p IDPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.idPolicy = p
+	return nil
+}
+
+// checkNewID applies the ID policy and rejects IDs that collide with existing ones
+// after normalization (e.g. "Web-1" vs "web-1"). Caller must hold the lock.
+func (m *Manager) checkNewID(kind DocumentType, id string) (string, error) {
+	id, err := applyIDPolicy(m.idPolicy, kind, id)
+	if err != nil {
+		return "", err
+	}
+	if m.idPolicy == "" || m.idPolicy == IDPolicyNone {
+		return id, nil
+	}
+
+	normalized := NormalizeID(id)
+	for key := range m.sources {
+		if key.Type == kind && NormalizeID(key.ID) == normalized {
+			return "", fmt.Errorf("%s %s conflicts with existing %s %s", kind, id, kind, key.ID)
+		}
+	}
+	return id, nil
+}
+
+// ===== Migration =====
+
+// IDRename describes a single rename performed (or planned) by NormalizeIDs.
+type IDRename struct {
+	Type  DocumentType `json:"type"`
+	OldID string       `json:"old_id"`
+	NewID string       `json:"new_id"`
+	// Conflict is set when the rename was skipped because the new ID is already taken.
+	Conflict string `json:"conflict,omitempty"`
+}
+
+// NormalizeIDs renames every entity whose ID is not normalized, fixing all references
+// and moving per-entity files. With dryRun set it only reports the planned renames.
+func (m *Manager) NormalizeIDs(dryRun bool) ([]IDRename, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var renames []IDRename
+	dirty := map[string]bool{}
+	snap := m.snapshot()
+
+	for _, kind := range []DocumentType{TypeCredential, TypeGroup, TypeHost} {
+		claimed := map[string]string{}
+		for key := range m.sources {
+			if key.Type == kind && IsNormalizedID(key.ID) {
+				claimed[key.ID] = key.ID
+			}
+		}
+
+		for _, oldID := range m.idsOf(kind) {
+			newID := NormalizeID(oldID)
+			if newID == oldID {
+				continue
+			}
+
+			r := IDRename{Type: kind, OldID: oldID, NewID: newID}
+			switch owner, taken := claimed[newID]; {
+			case newID == "":
+				r.Conflict = "ID has no valid characters"
+			case taken:
+				r.Conflict = fmt.Sprintf("%s %s already exists", kind, owner)
+			default:
+				claimed[newID] = oldID
+				if !dryRun {
+					m.renameLocked(entityKey{kind, oldID}, newID, dirty)
+				}
+			}
+			renames = append(renames, r)
+		}
+	}
+
+	if dryRun {
+		return renames, nil
+	}
+	if err := m.saveFilesAtomic(dirty); err != nil {
+		m.restore(snap)
+		return nil, err
+	}
+	return renames, nil
+}
+
+// idsOf returns the sorted IDs of all entities of a type. Caller must hold the lock.
+func (m *Manager) idsOf(kind DocumentType) []string {
+	switch kind {
+	case TypeHost:
+		return sortedKeys(m.hosts)
+	case TypeGroup:
+		return sortedKeys(m.groups)
+	case TypeCredential:
+		return sortedKeys(m.credentials)
+	case TypeCommand:
+		return sortedKeys(m.commands)
+	case TypeCheck:
+		return sortedKeys(m.checks)
+	case TypeWorkflow:
+		return sortedKeys(m.workflows)
+	case TypeTheme:
+		return sortedKeys(m.themes)
+	case TypeSessionSet:
+		return sortedKeys(m.sessionSets)
+	}
+	return nil
+}
+
+// renameLocked changes an entity's ID, rewrites every reference to it and records the files
+// that need saving in dirty. The entity keeps its position in multi-document files; entities
+// stored in their default per-entity file move to the file named after the new ID.
+// Caller must hold the lock and ensure newID is free.
+func (m *Manager) renameLocked(oldKey entityKey, newID string, dirty map[string]bool) {
+	newKey := entityKey{oldKey.Type, newID}
+
+	switch oldKey.Type {
+	case TypeHost:
+		h := m.hosts[oldKey.ID]
+		delete(m.hosts, oldKey.ID)
+		h.ID = newID
+		m.hosts[newID] = h
+
+		for _, g := range m.groups {
+			for i, id := range g.HostIDs {
+				if id == oldKey.ID {
+					g.HostIDs[i] = newID
+					dirty[m.sources[keyOf(g)]] = true
+				}
+			}
+			if p, ok := g.Priorities[oldKey.ID]; ok {
+				delete(g.Priorities, oldKey.ID)
+				g.Priorities[newID] = p
+			}
+		}
+		m.updateCheckRefs(TypeHost, oldKey.ID, newID, dirty)
+		m.updateRelationRefs(oldKey.ID, newID, dirty)
+		m.updateSessionSetRefs(oldKey.ID, newID, dirty)
+		for _, other := range m.hosts {
+			if other.JumpHostID == oldKey.ID {
+				other.JumpHostID = newID
+				dirty[m.sources[keyOf(other)]] = true
+			}
+			if other.RelayHostID == oldKey.ID {
+				other.RelayHostID = newID
+				dirty[m.sources[keyOf(other)]] = true
+			}
+		}
+
+	case TypeGroup:
+		g := m.groups[oldKey.ID]
+		delete(m.groups, oldKey.ID)
+		g.Name = newID
+		m.groups[newID] = g
+
+		for _, other := range m.groups {
+			for i, name := range other.ChildGroupNames {
+				if name == oldKey.ID {
+					other.ChildGroupNames[i] = newID
+					dirty[m.sources[keyOf(other)]] = true
+				}
+			}
+		}
+		m.updateCheckRefs(TypeGroup, oldKey.ID, newID, dirty)
+		m.renameDeclaredGroup(oldKey.ID, newID, dirty)
+
+	case TypeCredential:
+		c := m.credentials[oldKey.ID]
+		delete(m.credentials, oldKey.ID)
+		c.ID = newID
+		m.credentials[newID] = c
+
+		for _, h := range m.hosts {
+			if h.CredentialID == oldKey.ID {
+				h.CredentialID = newID
+				dirty[m.sources[keyOf(h)]] = true
+			}
+		}
+	}
+
+	filename := m.sources[oldKey]
+	delete(m.sources, oldKey)
+	if node, ok := m.nodes[oldKey]; ok {
+		delete(m.nodes, oldKey)
+		m.nodes[newKey] = node
+	}
+
+	keys := m.files[filename]
+	if ext, own := ownFilename(filename, oldKey); own && len(keys) == 1 {
+		newFilename := defaultFilename(newKey) + ext
+		if indent, ok := m.indents[filename]; ok {
+			m.indents[newFilename] = indent
+		}
+		delete(m.files, filename)
+		m.files[newFilename] = append(m.files[newFilename], newKey)
+		m.sources[newKey] = newFilename
+		// The old file is now empty and gets removed on save.
+		dirty[filename] = true
+		dirty[newFilename] = true
+		return
+	}
+
+	for i, k := range keys {
+		if k == oldKey {
+			keys[i] = newKey
+		}
+	}
+	m.sources[newKey] = filename
+	dirty[filename] = true
+}
@@ -0,0 +1,116 @@
+package inventory
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeID(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"web-1", "web-1"},
+		{"Web-1", "web-1"},
+		{"  Web Server 01 ", "web-server-01"},
+		{"db_primary.eu", "db_primary.eu"},
+		{"a//b", "a-b"},
+		{"--x--", "x"},
+		{"***", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			assert.Equal(t, tt.want, NormalizeID(tt.in))
+		})
+	}
+}
+
+func TestIDPolicyOnCreate(t *testing.T) {
+	t.Run("normalize rewrites ID and rejects case duplicates", func(t *testing.T) {
+		m, _ := setupTestManager(t)
+
+		h := NewHost("Web-1", "web", "10.0.0.1")
+		h.User = "root"
+		require.NoError(t, m.AddHost(h))
+		assert.Equal(t, "web-1", h.ID)
+
+		_, ok := m.GetHost("web-1")
+		assert.True(t, ok)
+
+		dup := NewHost("WEB-1", "web", "10.0.0.2")
+		dup.User = "root"
+		assert.Error(t, m.AddHost(dup))
+	})
+
+	t.Run("strict rejects non-normalized IDs", func(t *testing.T) {
+		m, _ := setupTestManager(t)
+		require.NoError(t, m.SetIDPolicy(IDPolicyStrict))
+
+		assert.Error(t, m.AddGroup(NewGroup("Web Servers")))
+		assert.NoError(t, m.AddGroup(NewGroup("web-servers")))
+	})
+
+	t.Run("none keeps IDs as given", func(t *testing.T) {
+		m, _ := setupTestManager(t)
+		require.NoError(t, m.SetIDPolicy(IDPolicyNone))
+
+		require.NoError(t, m.AddGroup(NewGroup("Web")))
+		_, ok := m.GetGroup("Web")
+		assert.True(t, ok)
+	})
+
+	assert.Error(t, IDPolicy("bogus").Validate())
+}
+
+func TestNormalizeIDsMigration(t *testing.T) {
+	m, dir := setupTestManager(t)
+	writeTestFile(t, dir, "host-Web-1.yaml", "type: host\nid: Web-1\nname: web\naddress: 10.0.0.1\nport: 22\ncredential_id: Admin\n")
+	writeTestFile(t, dir, "host-web-2.yaml", "type: host\nid: web-2\nname: web\naddress: 10.0.0.2\nport: 22\nuser: root\n")
+	writeTestFile(t, dir, "host-WEB-2.yaml", "type: host\nid: WEB-2\nname: web\naddress: 10.0.0.3\nport: 22\nuser: root\n")
+	writeTestFile(t, dir, "cred.yaml", "type: credential\nid: Admin\nname: admin\nuser: root\npassword: x\n")
+	writeTestFile(t, dir, "groups.yaml", "type: group\nname: Web\nhost_ids: [Web-1, web-2]\n---\ntype: group\nname: all\nchild_groups: [Web]\n")
+	require.NoError(t, m.Load())
+
+	t.Run("dry run reports without changing", func(t *testing.T) {
+		renames, err := m.NormalizeIDs(true)
+		require.NoError(t, err)
+		assert.Len(t, renames, 4)
+
+		_, ok := m.GetHost("Web-1")
+		assert.True(t, ok)
+	})
+
+	renames, err := m.NormalizeIDs(false)
+	require.NoError(t, err)
+
+	var conflicts int
+	for _, r := range renames {
+		if r.Conflict != "" {
+			conflicts++
+			assert.Equal(t, "WEB-2", r.OldID)
+		}
+	}
+	assert.Equal(t, 1, conflicts)
+
+	reloaded := NewManager(dir)
+	require.NoError(t, reloaded.Load())
+
+	h, ok := reloaded.GetHost("web-1")
+	require.True(t, ok)
+	assert.Equal(t, "admin", h.CredentialID)
+	assert.FileExists(t, filepath.Join(dir, "host-web-1.yaml"))
+	assert.NoFileExists(t, filepath.Join(dir, "host-Web-1.yaml"))
+
+	g, ok := reloaded.GetGroup("web")
+	require.True(t, ok)
+	assert.Equal(t, []string{"web-1", "web-2"}, g.HostIDs)
+
+	all, _ := reloaded.GetGroup("all")
+	assert.Equal(t, []string{"web"}, all.ChildGroupNames)
+
+	_, ok = reloaded.GetCredential("admin")
+	assert.True(t, ok)
+}
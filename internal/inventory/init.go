@@ -0,0 +1,285 @@
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+
+	"gossher/internal/i18n"
+	"gossher/internal/term"
+)
+
+// InitStep is a step of the first-run initialization.
+type InitStep string
+
+const (
+	// InitDataDir creates the data directory and writes config.yaml.
+	InitDataDir InitStep = "data-dir"
+	// InitDefaults sets the default SSH port, the theme and the language.
+	InitDefaults InitStep = "defaults"
+	// InitSSHConfig imports the hosts of ~/.ssh/config.
+	InitSSHConfig InitStep = "ssh-config"
+	// InitKnownHosts pins the host keys of ~/.ssh/known_hosts.
+	InitKnownHosts InitStep = "known-hosts"
+	// InitCredential creates the first credential.
+	InitCredential InitStep = "credential"
+)
+
+// InitResult is what the initialization did.
+type InitResult struct {
+	DataDir    string            `json:"data_dir"`
+	SSHConfig  *SSHConfigImport  `json:"ssh_config,omitempty"`
+	KnownHosts *KnownHostsImport `json:"known_hosts,omitempty"`
+	// CredentialID is the ID of the created credential, if any.
+	CredentialID string `json:"credential_id,omitempty"`
+}
+
+// NeedsInit reports whether config.yaml does not exist yet, i.e. gossher runs for
+// the first time. Call it before Load, which creates a default config.yaml.
+func NeedsInit() bool {
+	_, err := os.Stat(filepath.Join(defaultBaseDir(), "config.yaml"))
+	return os.IsNotExist(err)
+}
+
+// InitWizard drives the first-run initialization as a sequence of steps, each asking
+// a few Inputs and then applied. A TUI shows one screen per step with Inputs and
+// calls Apply; a CLI calls Run with a prompt.
+type InitWizard struct {
+	// SSHConfigPath and KnownHostsPath are the files offered for import (default
+	// DefaultSSHConfigPath and DefaultKnownHostsPath).
+	SSHConfigPath  string
+	KnownHostsPath string
+
+	cfg     *Config
+	manager *Manager
+	result  InitResult
+}
+
+// NewInitWizard creates a wizard starting from the default configuration, or the
+// current config.yaml when it exists, so running it again only changes the answers.
+func NewInitWizard() (*InitWizard, error) {
+	cfg := Default()
+	if data, err := os.ReadFile(cfg.ConfigPath); err == nil {
+		if cfg, err = parseConfig(cfg.ConfigPath, cfg.BaseDir, data); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	return &InitWizard{
+		SSHConfigPath:  DefaultSSHConfigPath(),
+		KnownHostsPath: DefaultKnownHostsPath(),
+		cfg:            cfg,
+	}, nil
+}
+
+// Steps returns the steps in order. The import steps are only offered when their
+// file exists.
+func (w *InitWizard) Steps() []InitStep {
+	steps := []InitStep{InitDataDir, InitDefaults}
+	if fileExists(w.SSHConfigPath) {
+		steps = append(steps, InitSSHConfig)
+	}
+	if fileExists(w.KnownHostsPath) {
+		steps = append(steps, InitKnownHosts)
+	}
+	return append(steps, InitCredential)
+}
+
+// Inputs returns the questions of a step with the current values as defaults.
+func (w *InitWizard) Inputs(step InitStep) []Input {
+	switch step {
+	case InitDataDir:
+		return []Input{
+			{Name: "data_dir", Description: "Directory holding the inventory", Default: initDefault(w.cfg.DataDir)},
+		}
+
+	case InitDefaults:
+		themes := make([]string, 0)
+		for _, t := range BuiltinThemes() {
+			themes = append(themes, t.ID)
+		}
+		theme := w.cfg.Theme
+		if theme == "" {
+			theme = DefaultThemeID
+		}
+		language := i18n.Normalize(w.cfg.Language)
+		if language == "" {
+			language = i18n.DefaultLanguage
+		}
+		return []Input{
+			{Name: "default_ssh_port", Description: "Default SSH port", Type: InputInt, Default: initDefault(strconv.Itoa(w.cfg.DefaultSSHPort))},
+			{Name: "theme", Description: "Color theme", Choices: themes, Default: initDefault(theme)},
+			{Name: "language", Description: "Language of messages", Choices: i18n.Languages(), Default: initDefault(language)},
+		}
+
+	case InitSSHConfig:
+		return []Input{
+			{Name: "import_ssh_config", Description: "Import the hosts of " + w.SSHConfigPath, Type: InputBool, Default: initDefault("true")},
+		}
+
+	case InitKnownHosts:
+		return []Input{
+			{Name: "import_known_hosts", Description: "Pin the host keys of " + w.KnownHostsPath, Type: InputBool, Default: initDefault("true")},
+		}
+
+	case InitCredential:
+		login := ""
+		if u, err := user.Current(); err == nil {
+			login = u.Username
+		}
+		return []Input{
+			{Name: "create_credential", Description: "Create a credential shared by hosts", Type: InputBool, Default: initDefault("true")},
+			{Name: "credential_id", Description: "Credential ID", Default: initDefault("default")},
+			{Name: "user", Description: "Login user", Default: initDefault(login)},
+			{Name: "key_path", Description: "Private key file (empty for password authentication)", Default: initDefault(defaultKeyPath())},
+			{Name: "password", Description: "Password (empty for key authentication)", Secret: true, Default: initDefault("")},
+		}
+	}
+	return nil
+}
+
+// Apply performs a step with the values of its Inputs; use ResolveInputs to check
+// and complete them. The data directory step must be applied first.
+func (w *InitWizard) Apply(step InitStep, values map[string]string) error {
+	if step != InitDataDir && w.manager == nil {
+		return fmt.Errorf("init: %s step applied before %s", step, InitDataDir)
+	}
+
+	switch step {
+	case InitDataDir:
+		return w.applyDataDir(values["data_dir"])
+
+	case InitDefaults:
+		port, err := strconv.Atoi(values["default_ssh_port"])
+		if err != nil {
+			return fmt.Errorf("invalid port: %q", values["default_ssh_port"])
+		}
+		return Update(func(e *ConfigEditor) error {
+			if err := e.SetDefaultSSHPort(port); err != nil {
+				return err
+			}
+			e.SetTheme(values["theme"])
+			e.SetLanguage(values["language"])
+			return nil
+		})
+
+	case InitSSHConfig:
+		if ok, _ := strconv.ParseBool(values["import_ssh_config"]); !ok {
+			return nil
+		}
+		result, err := w.manager.ImportSSHConfig(w.SSHConfigPath)
+		w.result.SSHConfig = result
+		return err
+
+	case InitKnownHosts:
+		if ok, _ := strconv.ParseBool(values["import_known_hosts"]); !ok {
+			return nil
+		}
+		result, err := w.manager.ImportKnownHosts(w.KnownHostsPath)
+		w.result.KnownHosts = result
+		return err
+
+	case InitCredential:
+		if ok, _ := strconv.ParseBool(values["create_credential"]); !ok {
+			return nil
+		}
+		id := values["credential_id"]
+		c := NewCredential(id, id, values["user"])
+		c.KeyPath = values["key_path"]
+		c.Password = values["password"]
+		if err := w.manager.AddCredential(c); err != nil {
+			return err
+		}
+		w.result.CredentialID = c.ID
+		return nil
+	}
+	return fmt.Errorf("unknown init step: %s", step)
+}
+
+// applyDataDir creates the data directory, saves config.yaml and makes it the
+// loaded configuration.
+func (w *InitWizard) applyDataDir(dir string) error {
+	if dir == "" {
+		dir = w.cfg.BaseDir
+	}
+	w.cfg.DataDir = dir
+	dataDir := w.cfg.dataDir()
+	if err := os.MkdirAll(dataDir, w.cfg.Permissions.DirMode()); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := saveConfig(w.cfg); err != nil {
+		return err
+	}
+
+	configMutex.Lock()
+	globalConfig = w.cfg
+	configMutex.Unlock()
+	i18n.SetLanguage(w.cfg.Language)
+	term.SetPlain(w.cfg.PlainOutput)
+
+	m := NewManager(dataDir)
+	if err := m.SetIDPolicy(w.cfg.IDPolicy); err != nil {
+		return err
+	}
+	if err := m.Load(); err != nil {
+		return err
+	}
+	w.manager = m
+	w.result.DataDir = dataDir
+	return nil
+}
+
+// Run asks the Inputs of every step with prompt and applies them. A nil prompt
+// accepts all defaults.
+func (w *InitWizard) Run(prompt InputPrompt) (*InitResult, error) {
+	for _, step := range w.Steps() {
+		values, err := ResolveInputs(w.Inputs(step), nil, prompt)
+		if err != nil {
+			return nil, fmt.Errorf("init %s: %w", step, err)
+		}
+		if err := w.Apply(step, values); err != nil {
+			return nil, fmt.Errorf("init %s: %w", step, err)
+		}
+	}
+	return w.Result(), nil
+}
+
+// Manager returns the manager of the data directory, nil before that step.
+func (w *InitWizard) Manager() *Manager {
+	return w.manager
+}
+
+// Result returns what the applied steps did.
+func (w *InitWizard) Result() *InitResult {
+	result := w.result
+	return &result
+}
+
+// initDefault returns a pointer for Input.Default.
+func initDefault(value string) *string {
+	return &value
+}
+
+// defaultKeyPath returns the first usual private key found in ~/.ssh, as a ~ path.
+func defaultKeyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		if fileExists(filepath.Join(home, ".ssh", name)) {
+			return "~/.ssh/" + name
+		}
+	}
+	return ""
+}
+
+// fileExists reports whether path is an existing regular file.
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,118 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gossher/internal/i18n"
+	"gossher/internal/term"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestHome points HOME to a temporary directory with an ~/.ssh directory and
+// resets the global configuration after the test.
+func setupTestHome(t *testing.T) string {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	require.NoError(t, os.Mkdir(filepath.Join(home, ".ssh"), 0o700))
+	t.Cleanup(func() {
+		configMutex.Lock()
+		globalConfig = nil
+		configMutex.Unlock()
+		i18n.SetLanguage(i18n.DefaultLanguage)
+		term.SetPlain(false)
+	})
+	return home
+}
+
+func TestInitWizard(t *testing.T) {
+	t.Run("runs every step with the answers", func(t *testing.T) {
+		home := setupTestHome(t)
+		require.NoError(t, os.WriteFile(filepath.Join(home, ".ssh", "config"), []byte("Host web01\n  HostName 10.0.0.1\n  User deploy\n"), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), []byte("10.0.0.1 ssh-ed25519 "+testHostKey+"\n"), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(home, ".ssh", "id_ed25519"), []byte("key"), 0o600))
+		assert.True(t, NeedsInit())
+
+		w, err := NewInitWizard()
+		require.NoError(t, err)
+		assert.Equal(t, []InitStep{InitDataDir, InitDefaults, InitSSHConfig, InitKnownHosts, InitCredential}, w.Steps())
+
+		answers := map[string]string{
+			"data_dir":         filepath.Join(home, "inventory"),
+			"default_ssh_port": "2222",
+			"theme":            "dark",
+			"credential_id":    "admin",
+		}
+		var asked []string
+		result, err := w.Run(func(in Input) (string, error) {
+			asked = append(asked, in.Name)
+			return answers[in.Name], nil
+		})
+		require.NoError(t, err)
+		assert.Contains(t, asked, "password")
+		assert.False(t, NeedsInit())
+
+		assert.Equal(t, filepath.Join(home, "inventory"), result.DataDir)
+		assert.Equal(t, filepath.Join(home, "inventory"), GetDataDir())
+		assert.Equal(t, 2222, GetDefaultSSHPort())
+		assert.Equal(t, "dark", GetTheme())
+
+		require.NotNil(t, result.SSHConfig)
+		assert.Equal(t, []string{"web01"}, result.SSHConfig.Added)
+		require.NotNil(t, result.KnownHosts)
+		assert.Equal(t, map[string]int{"web01": 1}, result.KnownHosts.Added)
+
+		assert.Equal(t, "admin", result.CredentialID)
+		cred, ok := w.Manager().GetCredential("admin")
+		require.True(t, ok)
+		assert.Equal(t, "~/.ssh/id_ed25519", cred.KeyPath)
+
+		// Everything was written to the data directory.
+		m := NewManager(GetDataDir())
+		require.NoError(t, m.Load())
+		_, ok = m.GetHost("web01")
+		assert.True(t, ok)
+	})
+
+	t.Run("accepts the defaults without a prompt", func(t *testing.T) {
+		home := setupTestHome(t)
+
+		w, err := NewInitWizard()
+		require.NoError(t, err)
+		assert.Equal(t, []InitStep{InitDataDir, InitDefaults, InitCredential}, w.Steps())
+
+		// Without a key there is nothing to authenticate with.
+		_, err = w.Run(nil)
+		assert.ErrorContains(t, err, "init credential")
+
+		values, err := ResolveInputs(w.Inputs(InitCredential), map[string]string{"create_credential": "no"}, nil)
+		require.NoError(t, err)
+		require.NoError(t, w.Apply(InitCredential, values))
+		assert.Empty(t, w.Result().CredentialID)
+		assert.Equal(t, filepath.Join(home, ".gossher"), w.Result().DataDir)
+		assert.Equal(t, 22, GetDefaultSSHPort())
+	})
+
+	t.Run("requires the data directory first", func(t *testing.T) {
+		setupTestHome(t)
+
+		w, err := NewInitWizard()
+		require.NoError(t, err)
+		assert.Error(t, w.Apply(InitDefaults, map[string]string{"default_ssh_port": "22"}))
+	})
+
+	t.Run("starts from an existing config", func(t *testing.T) {
+		setupTestHome(t)
+		require.NoError(t, Load())
+		require.NoError(t, SetDefaultSSHPort(2200))
+
+		w, err := NewInitWizard()
+		require.NoError(t, err)
+		inputs := w.Inputs(InitDefaults)
+		require.Len(t, inputs, 3)
+		assert.Equal(t, "2200", *inputs[0].Default)
+	})
+}
@@ -0,0 +1,194 @@
+package inventory
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// InlineAuthPolicy controls whether hosts may carry their own key path or password
+// instead of referencing a credential.
+type InlineAuthPolicy string
+
+const (
+	// InlineAuthAllow accepts inline authentication (the default).
+	InlineAuthAllow InlineAuthPolicy = "allow"
+	// InlineAuthWarn accepts it but reports every such host in Doctor.
+	InlineAuthWarn InlineAuthPolicy = "warn"
+	// InlineAuthForbid rejects hosts that add or change inline authentication;
+	// existing ones are reported until MigrateInlineAuth moved them to credentials.
+	InlineAuthForbid InlineAuthPolicy = "forbid"
+)
+
+// Validate checks that the policy is a known value. An empty policy is treated as
+// InlineAuthAllow.
+func (p InlineAuthPolicy) Validate() error {
+	switch p {
+	case "", InlineAuthAllow, InlineAuthWarn, InlineAuthForbid:
+		return nil
+	}
+	return fmt.Errorf("invalid inline auth policy: %s", p)
+}
+
+// HasInlineAuth reports whether the host carries its own key path or password.
+// An inline user alone, e.g. for agent authentication, does not count.
+func (h *Host) HasInlineAuth() bool {
+	return !h.IsLocal() && (h.KeyPath != "" || h.Password != "")
+}
+
+// sameInlineAuth reports whether two hosts have the same inline authentication.
+func sameInlineAuth(a, b *Host) bool {
+	return a.User == b.User && a.KeyPath == b.KeyPath && a.Password == b.Password
+}
+
+// ===== Manager integration =====
+
+// SetInlineAuthPolicy sets the policy enforced when hosts are added or updated.
+func (m *Manager) SetInlineAuthPolicy(p InlineAuthPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inlineAuth = p
+	return nil
+}
+
+// checkInlineAuth rejects new or changed inline authentication under the forbid
+// policy; old is the stored host, nil for new hosts. Caller must hold the lock.
+func (m *Manager) checkInlineAuth(h, old *Host) error {
+	if m.inlineAuth != InlineAuthForbid || !h.HasInlineAuth() {
+		return nil
+	}
+	if old != nil && sameInlineAuth(h, old) {
+		return nil
+	}
+	return fmt.Errorf("host %s: inline key_path and password are forbidden by the inline auth policy; use a credential", h.ID)
+}
+
+// ===== Migration =====
+
+// InlineAuthMove is a host moved to a credential by MigrateInlineAuth.
+type InlineAuthMove struct {
+	HostID       string `json:"host_id"`
+	CredentialID string `json:"credential_id"`
+	// Created is set when the credential was created for the migration, rather
+	// than matched to an existing one.
+	Created bool `json:"created,omitempty"`
+}
+
+// InlineAuthSkip is a host MigrateInlineAuth could not move.
+type InlineAuthSkip struct {
+	HostID string `json:"host_id"`
+	Reason string `json:"reason"`
+}
+
+// InlineAuthMigration reports what MigrateInlineAuth did, or would do.
+type InlineAuthMigration struct {
+	Moved   []InlineAuthMove `json:"moved,omitempty"`
+	Skipped []InlineAuthSkip `json:"skipped,omitempty"`
+}
+
+// MigrateInlineAuth moves the inline authentication of hosts to credentials: hosts
+// with the same user, key path and password share a credential, an existing
+// credential with exactly these values is reused, and the hosts are rewritten to
+// reference it by credential_id. With dryRun nothing is changed.
+func (m *Manager) MigrateInlineAuth(dryRun bool) (*InlineAuthMigration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := &InlineAuthMigration{}
+	matched := make(map[Credential]string)
+	for _, id := range sortedKeys(m.credentials) {
+		c := m.credentials[id]
+		if c.Provider == "" && c.Passphrase == "" && c.PassphraseFile == "" {
+			matched[Credential{User: c.User, KeyPath: c.KeyPath, Password: c.Password}] = id
+		}
+	}
+	created := make(map[string]*Credential)
+	files := make(map[string]bool)
+
+	for _, id := range sortedKeys(m.hosts) {
+		h := m.hosts[id]
+		if !h.HasInlineAuth() {
+			continue
+		}
+
+		want := Credential{User: h.User, KeyPath: h.KeyPath, Password: h.Password}
+		if h.CredentialID != "" {
+			// Inline fields override the referenced credential.
+			if c, ok := m.credentials[h.CredentialID]; ok {
+				want.User = cmp.Or(want.User, c.User)
+				if want.KeyPath == "" && want.Password == "" {
+					want.KeyPath, want.Password = c.KeyPath, c.Password
+				}
+			}
+		}
+		if want.User == "" {
+			result.Skipped = append(result.Skipped, InlineAuthSkip{HostID: id, Reason: "no user"})
+			continue
+		}
+
+		move := InlineAuthMove{HostID: id}
+		if credID, ok := matched[want]; ok {
+			move.CredentialID = credID
+			move.Created = created[credID] != nil
+		} else {
+			c := NewCredential(m.uniqueCredentialID(inlineCredentialID(want), created), "", want.User)
+			c.Name = fmt.Sprintf("%s (migrated from %s)", want.User, id)
+			c.KeyPath, c.Password = want.KeyPath, want.Password
+			created[c.ID] = c
+			matched[want] = c.ID
+			move.CredentialID, move.Created = c.ID, true
+		}
+		result.Moved = append(result.Moved, move)
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	for _, id := range sortedKeys(created) {
+		if err := m.store(created[id]); err != nil {
+			return nil, err
+		}
+	}
+	for _, move := range result.Moved {
+		h := m.hosts[move.HostID].Clone().(*Host)
+		h.CredentialID = move.CredentialID
+		h.User, h.KeyPath, h.Password = "", "", ""
+		m.hosts[h.ID] = h
+		files[m.sources[entityKey{TypeHost, h.ID}]] = true
+	}
+	if err := m.saveFiles(files); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// inlineCredentialID proposes the ID of a credential created for inline auth:
+// the user and the key file name, or the user alone for passwords.
+func inlineCredentialID(c Credential) string {
+	if c.KeyPath == "" {
+		return NormalizeID(c.User)
+	}
+	key := strings.TrimSuffix(filepath.Base(c.KeyPath), filepath.Ext(c.KeyPath))
+	return NormalizeID(c.User + "-" + key)
+}
+
+// uniqueCredentialID appends a number to id until no credential, stored or about
+// to be created, uses it. Caller must hold the lock.
+func (m *Manager) uniqueCredentialID(id string, pending map[string]*Credential) string {
+	taken := func(id string) bool {
+		_, stored := m.credentials[id]
+		return stored || pending[id] != nil
+	}
+	candidate := id
+	for n := 2; taken(candidate); n++ {
+		candidate = id + "-" + strconv.Itoa(n)
+	}
+	return candidate
+}
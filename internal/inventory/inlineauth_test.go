@@ -0,0 +1,111 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupInlineAuth(t *testing.T) *Manager {
+	m, _ := setupTestManager(t)
+
+	ops := NewCredential("ops", "Ops", "ops")
+	ops.KeyPath = "~/.ssh/ops"
+	require.NoError(t, m.AddCredential(ops))
+
+	add := func(id string, edit func(h *Host)) {
+		h := NewHost(id, id, "10.0.0."+id[len(id)-1:])
+		edit(h)
+		require.NoError(t, m.AddHost(h))
+	}
+	add("web1", func(h *Host) { h.User, h.KeyPath = "deploy", "~/.ssh/deploy.pem" })
+	add("web2", func(h *Host) { h.User, h.KeyPath = "deploy", "~/.ssh/deploy.pem" })
+	add("db1", func(h *Host) { h.User, h.KeyPath = "ops", "~/.ssh/ops" })
+	add("nas1", func(h *Host) { h.User, h.Password = "admin", "hunter22" })
+	add("app1", func(h *Host) { h.CredentialID, h.Password = "ops", "override" })
+	add("ci1", func(h *Host) { h.User = "ci" })
+	return m
+}
+
+func TestMigrateInlineAuth(t *testing.T) {
+	t.Run("dry run", func(t *testing.T) {
+		m := setupInlineAuth(t)
+		result, err := m.MigrateInlineAuth(true)
+		require.NoError(t, err)
+		assert.Equal(t, []InlineAuthMove{
+			{HostID: "app1", CredentialID: "ops-2", Created: true},
+			{HostID: "db1", CredentialID: "ops"},
+			{HostID: "nas1", CredentialID: "admin", Created: true},
+			{HostID: "web1", CredentialID: "deploy-deploy", Created: true},
+			{HostID: "web2", CredentialID: "deploy-deploy", Created: true},
+		}, result.Moved)
+		assert.Empty(t, result.Skipped)
+
+		h, _ := m.GetHost("web1")
+		assert.Equal(t, "~/.ssh/deploy.pem", h.KeyPath)
+		assert.Len(t, m.ListCredentials(), 1)
+	})
+
+	t.Run("rewrites hosts", func(t *testing.T) {
+		m := setupInlineAuth(t)
+		_, err := m.MigrateInlineAuth(false)
+		require.NoError(t, err)
+
+		for id, credID := range map[string]string{"web1": "deploy-deploy", "web2": "deploy-deploy", "db1": "ops", "nas1": "admin", "app1": "ops-2"} {
+			h, _ := m.GetHost(id)
+			assert.Equal(t, credID, h.CredentialID, id)
+			assert.False(t, h.HasInlineAuth(), id)
+			assert.Empty(t, h.User, id)
+		}
+		ci, _ := m.GetHost("ci1")
+		assert.Equal(t, "ci", ci.User)
+
+		nas, ok := m.GetCredential("admin")
+		require.True(t, ok)
+		assert.Equal(t, "hunter22", nas.Password)
+		app, _ := m.GetCredential("ops-2")
+		assert.Equal(t, "ops", app.User)
+		assert.Equal(t, "override", app.Password)
+
+		// The rewrite is saved.
+		reloaded := NewManager(m.GetDataDir())
+		require.NoError(t, reloaded.Load())
+		h, _ := reloaded.GetHost("web2")
+		assert.Equal(t, "deploy-deploy", h.CredentialID)
+		c, ok := reloaded.GetCredential("deploy-deploy")
+		require.True(t, ok)
+		assert.Equal(t, "~/.ssh/deploy.pem", c.KeyPath)
+
+		result, err := m.MigrateInlineAuth(false)
+		require.NoError(t, err)
+		assert.Empty(t, result.Moved)
+	})
+}
+
+func TestInlineAuthPolicy(t *testing.T) {
+	assert.Error(t, InlineAuthPolicy("sometimes").Validate())
+
+	m := setupInlineAuth(t)
+	require.NoError(t, m.SetInlineAuthPolicy(InlineAuthForbid))
+
+	h := NewHost("web3", "web3", "10.0.0.3")
+	h.User, h.KeyPath = "deploy", "~/.ssh/deploy.pem"
+	assert.ErrorContains(t, m.AddHost(h), "forbidden by the inline auth policy")
+	h.User, h.KeyPath = "deploy", ""
+	assert.NoError(t, m.AddHost(h), "an inline user alone is allowed")
+
+	web1, _ := m.GetHost("web1")
+	web1.Tags = []string{"prod"}
+	assert.NoError(t, m.UpdateHost(web1), "unchanged inline auth is kept")
+	web1.KeyPath = "~/.ssh/other"
+	assert.Error(t, m.UpdateHost(web1))
+
+	var warned []string
+	for _, f := range m.Doctor() {
+		if f.Check == DoctorPolicy && f.Subject != "config" {
+			warned = append(warned, f.Subject)
+		}
+	}
+	assert.ElementsMatch(t, []string{"host app1", "host db1", "host nas1", "host web1", "host web2"}, warned)
+}
@@ -0,0 +1,185 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrMissingInput is returned when a required input has no value and cannot be prompted for.
+var ErrMissingInput = errors.New("missing input")
+
+// InputType is the type of a runtime input.
+type InputType string
+
+const (
+	InputString InputType = "string"
+	InputInt    InputType = "int"
+	InputBool   InputType = "bool"
+)
+
+var inputNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Input declares a value a saved command or workflow needs at run time. Templates
+// refer to it as {{.Inputs.name}}. Inputs without a default are required.
+type Input struct {
+	Name        string    `yaml:"name"`
+	Description string    `yaml:"description,omitempty"`
+	Type        InputType `yaml:"type,omitempty"`
+	Default     *string   `yaml:"default,omitempty"`
+	// Choices restricts the value to a list.
+	Choices []string `yaml:"choices,omitempty"`
+	// Secret inputs are not echoed when prompted for and are redacted from output.
+	Secret bool `yaml:"secret,omitempty"`
+}
+
+// InputPrompt asks for the value of an input. Returning an empty string selects
+// the default, if any.
+type InputPrompt func(in Input) (string, error)
+
+// Required reports whether the input has no default.
+func (in *Input) Required() bool {
+	return in.Default == nil
+}
+
+// ValueType returns the declared type, InputString by default.
+func (in *Input) ValueType() InputType {
+	if in.Type == "" {
+		return InputString
+	}
+	return in.Type
+}
+
+// Validate checks the declaration and its default.
+func (in *Input) Validate() error {
+	if !inputNamePattern.MatchString(in.Name) {
+		return fmt.Errorf("invalid input name %q", in.Name)
+	}
+	switch in.ValueType() {
+	case InputString, InputInt, InputBool:
+	default:
+		return fmt.Errorf("input %s: invalid type: %s", in.Name, in.Type)
+	}
+	for _, c := range in.Choices {
+		if _, err := in.Parse(c); err != nil {
+			return err
+		}
+	}
+	if in.Default != nil {
+		if _, err := in.Parse(*in.Default); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parse checks a value against the type and choices of the input and returns it in
+// canonical form ("true"/"false" for booleans).
+func (in *Input) Parse(value string) (string, error) {
+	switch in.ValueType() {
+	case InputInt:
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("input %s: %q is not an integer", in.Name, value)
+		}
+		value = strconv.FormatInt(n, 10)
+	case InputBool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			switch strings.ToLower(strings.TrimSpace(value)) {
+			case "yes", "y", "on":
+				b = true
+			case "no", "n", "off":
+				b = false
+			default:
+				return "", fmt.Errorf("input %s: %q is not a boolean", in.Name, value)
+			}
+		}
+		value = strconv.FormatBool(b)
+	}
+
+	if len(in.Choices) > 0 && !containsString(in.Choices, value) {
+		return "", fmt.Errorf("input %s: %q is not one of %s", in.Name, value, strings.Join(in.Choices, ", "))
+	}
+	return value, nil
+}
+
+// validateInputs checks a list of declarations for errors and duplicate names.
+func validateInputs(inputs []Input) error {
+	names := map[string]bool{}
+	for i := range inputs {
+		if err := inputs[i].Validate(); err != nil {
+			return err
+		}
+		if names[inputs[i].Name] {
+			return fmt.Errorf("duplicate input %s", inputs[i].Name)
+		}
+		names[inputs[i].Name] = true
+	}
+	return nil
+}
+
+// ResolveInputs returns the values of the declared inputs: values passed in take
+// precedence, then prompt is asked for the rest and finally defaults apply. With a
+// nil prompt, required inputs without a value fail with ErrMissingInput. Values of
+// undeclared inputs are passed through unchanged.
+func ResolveInputs(inputs []Input, values map[string]string, prompt InputPrompt) (map[string]string, error) {
+	resolved := make(map[string]string, len(values)+len(inputs))
+	for k, v := range values {
+		resolved[k] = v
+	}
+
+	for _, in := range inputs {
+		value, ok := values[in.Name]
+		if !ok && prompt != nil {
+			answer, err := prompt(in)
+			if err != nil {
+				return nil, fmt.Errorf("input %s: %w", in.Name, err)
+			}
+			value, ok = answer, answer != ""
+		}
+		if !ok {
+			if in.Required() {
+				return nil, fmt.Errorf("input %s: %w", in.Name, ErrMissingInput)
+			}
+			value = *in.Default
+		}
+
+		parsed, err := in.Parse(value)
+		if err != nil {
+			return nil, err
+		}
+		resolved[in.Name] = parsed
+	}
+	return resolved, nil
+}
+
+// SecretInputs returns the values of the secret inputs.
+func SecretInputs(inputs []Input, values map[string]string) []string {
+	var secrets []string
+	for _, in := range inputs {
+		if v, ok := values[in.Name]; ok && in.Secret && v != "" {
+			secrets = append(secrets, v)
+		}
+	}
+	return secrets
+}
+
+// cloneInputs returns a deep copy of input declarations.
+func cloneInputs(inputs []Input) []Input {
+	if inputs == nil {
+		return nil
+	}
+	clone := make([]Input, len(inputs))
+	for i, in := range inputs {
+		if in.Default != nil {
+			d := *in.Default
+			in.Default = &d
+		}
+		in.Choices = append([]string(nil), in.Choices...)
+		clone[i] = in
+	}
+	return clone
+}
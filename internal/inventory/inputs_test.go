@@ -0,0 +1,94 @@
+package inventory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestInputParse(t *testing.T) {
+	tests := []struct {
+		in      Input
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{Input{Name: "v"}, "1.2.3", "1.2.3", false},
+		{Input{Name: "n", Type: InputInt}, " 42 ", "42", false},
+		{Input{Name: "n", Type: InputInt}, "many", "", true},
+		{Input{Name: "b", Type: InputBool}, "yes", "true", false},
+		{Input{Name: "b", Type: InputBool}, "0", "false", false},
+		{Input{Name: "b", Type: InputBool}, "maybe", "", true},
+		{Input{Name: "env", Choices: []string{"staging", "prod"}}, "prod", "prod", false},
+		{Input{Name: "env", Choices: []string{"staging", "prod"}}, "qa", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in.Name+"="+tt.value, func(t *testing.T) {
+			got, err := tt.in.Parse(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestInputValidate(t *testing.T) {
+	assert.NoError(t, validateInputs([]Input{{Name: "version"}, {Name: "dry_run", Type: InputBool, Default: strPtr("no")}}))
+	assert.Error(t, validateInputs([]Input{{Name: "my-version"}}))
+	assert.Error(t, validateInputs([]Input{{Name: "n", Type: "float"}}))
+	assert.Error(t, validateInputs([]Input{{Name: "n", Type: InputInt, Default: strPtr("x")}}))
+	assert.Error(t, validateInputs([]Input{{Name: "v"}, {Name: "v"}}))
+
+	c := NewSavedCommand("deploy", "deploy", "deploy {{.Inputs.version}}")
+	c.Inputs = []Input{{Name: "version"}, {Name: "version"}}
+	assert.Error(t, c.Validate())
+}
+
+func TestResolveInputs(t *testing.T) {
+	inputs := []Input{
+		{Name: "version"},
+		{Name: "replicas", Type: InputInt, Default: strPtr("2")},
+		{Name: "token", Secret: true},
+	}
+
+	t.Run("values, prompts and defaults", func(t *testing.T) {
+		var asked []string
+		prompt := func(in Input) (string, error) {
+			asked = append(asked, in.Name)
+			if in.Name == "token" {
+				return "s3cret", nil
+			}
+			return "", nil
+		}
+
+		values, err := ResolveInputs(inputs, map[string]string{"version": "1.4", "extra": "x"}, prompt)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"version": "1.4", "replicas": "2", "token": "s3cret", "extra": "x"}, values)
+		assert.Equal(t, []string{"replicas", "token"}, asked)
+		assert.Equal(t, []string{"s3cret"}, SecretInputs(inputs, values))
+	})
+
+	t.Run("missing required inputs", func(t *testing.T) {
+		_, err := ResolveInputs(inputs, map[string]string{"version": "1.4"}, nil)
+		assert.ErrorIs(t, err, ErrMissingInput)
+
+		_, err = ResolveInputs(inputs, nil, func(Input) (string, error) { return "", nil })
+		assert.ErrorIs(t, err, ErrMissingInput)
+	})
+
+	t.Run("prompt errors and invalid values", func(t *testing.T) {
+		cancelled := errors.New("cancelled")
+		_, err := ResolveInputs(inputs, nil, func(Input) (string, error) { return "", cancelled })
+		assert.ErrorIs(t, err, cancelled)
+
+		_, err = ResolveInputs(inputs, map[string]string{"version": "1", "replicas": "lots", "token": "t"}, nil)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,136 @@
+package inventory
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// KeysDir is the folder inside the data directory that holds imported private keys.
+// Keys in it are referenced relative to the data directory ("keys/deploy"), so a
+// profile keeps working when it is copied to another machine.
+const KeysDir = "keys"
+
+// ImportKey copies a private key (and its ".pub" file, if any) into KeysDir under
+// name, or under the key's own file name when name is empty, and returns the path
+// to store in KeyPath. Importing the same key twice is a no-op; a different key
+// with the same name is an error.
+func (m *Manager) ImportKey(src, name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.importKey(src, name)
+}
+
+// ImportCredentialKey imports the key of a credential into KeysDir and rewrites its
+// KeyPath to the relative path. Keys already inside KeysDir are left alone.
+func (m *Manager) ImportCredentialKey(credID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.credentials[credID]
+	if !ok {
+		return fmt.Errorf("credential %s not found", credID)
+	}
+	if c.KeyPath == "" {
+		return fmt.Errorf("credential %s has no key_path", credID)
+	}
+	if isImportedKey(c.KeyPath) {
+		return nil
+	}
+
+	rel, err := m.importKey(c.KeyPath, credID)
+	if err != nil {
+		return fmt.Errorf("credential %s: %w", credID, err)
+	}
+	c.KeyPath = rel
+	return m.saveFile(m.sources[entityKey{TypeCredential, credID}])
+}
+
+// PublicKey returns the public key of a credential's key, read from the ".pub" file
+// next to it, in authorized_keys format.
+func (m *Manager) PublicKey(credID string) (string, error) {
+	m.mu.RLock()
+	c, ok := m.credentials[credID]
+	var keyPath string
+	if ok {
+		keyPath = resolvePath(c.KeyPath, m.dataDir)
+	}
+	m.mu.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("credential %s not found", credID)
+	}
+	if keyPath == "" {
+		return "", fmt.Errorf("credential %s has no key_path", credID)
+	}
+
+	data, err := os.ReadFile(keyPath + ".pub")
+	if err != nil {
+		return "", fmt.Errorf("credential %s: failed to read public key: %w", credID, err)
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" || strings.Contains(key, "\n") {
+		return "", fmt.Errorf("credential %s: %s.pub does not hold a single public key", credID, keyPath)
+	}
+	return key, nil
+}
+
+// importKey implements ImportKey. Caller must hold the lock.
+func (m *Manager) importKey(src, name string) (string, error) {
+	src = ExpandPath(src)
+	if name == "" {
+		name = filepath.Base(src)
+	}
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid key name: %s", name)
+	}
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to read key: %w", err)
+	}
+
+	dir := filepath.Join(m.dataDir, KeysDir)
+	if err := os.MkdirAll(dir, m.perms.DirMode()); err != nil {
+		return "", fmt.Errorf("failed to create keys directory: %w", err)
+	}
+
+	dst := filepath.Join(dir, name)
+	if err := copyKeyFile(dst, data, m.perms.SecretMode()); err != nil {
+		return "", err
+	}
+	if pub, err := os.ReadFile(src + ".pub"); err == nil {
+		if err := copyKeyFile(dst+".pub", pub, m.perms.FileMode()); err != nil {
+			return "", err
+		}
+	}
+
+	return path.Join(KeysDir, name), nil
+}
+
+// copyKeyFile writes a key unless an identical one is already there.
+func copyKeyFile(dst string, data []byte, mode os.FileMode) error {
+	existing, err := os.ReadFile(dst)
+	if err == nil {
+		if !bytes.Equal(existing, data) {
+			return fmt.Errorf("a different key is already stored as %s", dst)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", dst, err)
+	}
+
+	if err := os.WriteFile(dst, data, mode); err != nil {
+		return fmt.Errorf("failed to write key %s: %w", dst, err)
+	}
+	return nil
+}
+
+// isImportedKey reports whether a key path points into KeysDir.
+func isImportedKey(keyPath string) bool {
+	return strings.HasPrefix(filepath.ToSlash(keyPath), KeysDir+"/")
+}
@@ -0,0 +1,85 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportKeys(t *testing.T) {
+	src := t.TempDir()
+	keyPath := filepath.Join(src, "id_ed25519")
+	require.NoError(t, os.WriteFile(keyPath, []byte("PRIVATE"), 0644))
+	require.NoError(t, os.WriteFile(keyPath+".pub", []byte("ssh-ed25519 AAAA deploy"), 0644))
+
+	m, dir := setupTestManager(t)
+	cred := NewCredential("deploy", "Deploy", "deploy")
+	cred.KeyPath = keyPath
+	require.NoError(t, m.AddCredential(cred))
+	require.NoError(t, m.AddHost(NewHostWithCredential("web", "web", "10.0.0.1", "deploy")))
+
+	require.NoError(t, m.ImportCredentialKey("deploy"))
+	got, _ := m.GetCredential("deploy")
+	assert.Equal(t, "keys/deploy", got.KeyPath)
+	assert.Equal(t, DefaultSecretMode, fileMode(t, filepath.Join(dir, "keys", "deploy")))
+	assert.FileExists(t, filepath.Join(dir, "keys", "deploy.pub"))
+
+	issues, err := m.CheckPermissions()
+	require.NoError(t, err)
+	for _, issue := range issues {
+		assert.NotContains(t, issue.Path, "keys", "imported keys are stored with the configured modes")
+	}
+
+	pub, err := m.PublicKey("deploy")
+	require.NoError(t, err)
+	assert.Equal(t, "ssh-ed25519 AAAA deploy", pub)
+
+	t.Run("relative keys resolve against the data dir", func(t *testing.T) {
+		moved := filepath.Join(t.TempDir(), "profile")
+		require.NoError(t, os.Rename(dir, moved))
+
+		other := NewManager(moved)
+		require.NoError(t, other.Load())
+		c, err := other.ResolveConnection("web")
+		require.NoError(t, err)
+		require.NoError(t, c.ResolveSecrets())
+		assert.Equal(t, filepath.Join(moved, "keys", "deploy"), c.KeyPath)
+
+		require.NoError(t, other.ImportCredentialKey("deploy"), "imported keys are not imported again")
+	})
+
+	t.Run("name conflicts", func(t *testing.T) {
+		m, _ := setupTestManager(t)
+		rel, err := m.ImportKey(keyPath, "")
+		require.NoError(t, err)
+		assert.Equal(t, "keys/id_ed25519", rel)
+
+		_, err = m.ImportKey(keyPath, "")
+		assert.NoError(t, err, "the same key can be imported twice")
+
+		other := filepath.Join(src, "other")
+		require.NoError(t, os.WriteFile(other, []byte("OTHER"), 0600))
+		_, err = m.ImportKey(other, "id_ed25519")
+		assert.Error(t, err)
+
+		_, err = m.ImportKey(other, "../escape")
+		assert.Error(t, err)
+		_, err = m.ImportKey(filepath.Join(src, "missing"), "")
+		assert.Error(t, err)
+	})
+
+	t.Run("credentials without keys", func(t *testing.T) {
+		m, _ := setupTestManager(t)
+		assert.Error(t, m.ImportCredentialKey("missing"))
+
+		pw := NewCredential("pw", "Password", "root")
+		pw.Password = "secret"
+		require.NoError(t, m.AddCredential(pw))
+		assert.Error(t, m.ImportCredentialKey("pw"))
+		_, err := m.PublicKey("pw")
+		assert.Error(t, err)
+	})
+}
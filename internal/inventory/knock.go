@@ -0,0 +1,48 @@
+package inventory
+
+import (
+	"fmt"
+	"time"
+)
+
+// Knock protocols.
+const (
+	KnockTCP = "tcp"
+	KnockUDP = "udp"
+)
+
+// KnockStep is one knock of a port knocking sequence: a TCP connection attempt or
+// a UDP datagram sent to Port of the host before connecting.
+type KnockStep struct {
+	Port int `yaml:"port"`
+	// Protocol is tcp (default) or udp.
+	Protocol string `yaml:"protocol,omitempty"`
+	// Delay is the pause after the knock, before the next knock or the connection.
+	Delay time.Duration `yaml:"delay,omitempty"`
+}
+
+// KnockProtocol returns the protocol of the step, tcp when unset.
+func (s KnockStep) KnockProtocol() string {
+	if s.Protocol == "" {
+		return KnockTCP
+	}
+	return s.Protocol
+}
+
+// ValidateKnock checks the ports, protocols and delays of a knock sequence.
+func ValidateKnock(steps []KnockStep) error {
+	for i, s := range steps {
+		if s.Port <= 0 || s.Port > 65535 {
+			return fmt.Errorf("knock %d: invalid port %d", i+1, s.Port)
+		}
+		switch s.Protocol {
+		case "", KnockTCP, KnockUDP:
+		default:
+			return fmt.Errorf("knock %d: invalid protocol %q", i+1, s.Protocol)
+		}
+		if s.Delay < 0 {
+			return fmt.Errorf("knock %d: invalid delay %s", i+1, s.Delay)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package inventory
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// HostKey is a pinned SSH host key fingerprint.
+type HostKey struct {
+	KeyType     string `yaml:"key_type"`
+	Fingerprint string `yaml:"fingerprint"` // "SHA256:<base64>" as printed by ssh-keygen -l
+}
+
+// HasHostKey reports whether the fingerprint is pinned for this host.
+func (h *Host) HasHostKey(fingerprint string) bool {
+	for _, k := range h.HostKeys {
+		if k.Fingerprint == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// AddHostKey pins a host key (prevents duplicates). It reports whether the key was added.
+func (h *Host) AddHostKey(k HostKey) bool {
+	if h.HasHostKey(k.Fingerprint) {
+		return false
+	}
+	h.HostKeys = append(h.HostKeys, k)
+	return true
+}
+
+// RemoveHostKey unpins a host key. It reports whether the key was pinned.
+func (h *Host) RemoveHostKey(fingerprint string) bool {
+	for i, k := range h.HostKeys {
+		if k.Fingerprint == fingerprint {
+			h.HostKeys = append(h.HostKeys[:i], h.HostKeys[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// FingerprintSHA256 returns the OpenSSH SHA256 fingerprint of a wire-format public key.
+func FingerprintSHA256(keyBlob []byte) string {
+	sum := sha256.Sum256(keyBlob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// ===== known_hosts parsing =====
+
+// KnownHostEntry is a single key line of a known_hosts file.
+type KnownHostEntry struct {
+	Line        int
+	Patterns    []string
+	KeyType     string
+	Fingerprint string
+	// Revoked marks @revoked lines: the key must never be accepted.
+	Revoked bool
+}
+
+// ParseKnownHosts reads OpenSSH known_hosts content. @cert-authority lines are
+// skipped because they do not pin a concrete host key.
+func ParseKnownHosts(r io.Reader) ([]KnownHostEntry, error) {
+	var entries []KnownHostEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		revoked := fields[0] == "@revoked"
+		if revoked {
+			fields = fields[1:]
+		} else if strings.HasPrefix(fields[0], "@") {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("known_hosts line %d: expected hosts, key type and key", lineNo)
+		}
+
+		blob, err := base64.StdEncoding.DecodeString(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("known_hosts line %d: invalid key encoding: %w", lineNo, err)
+		}
+
+		entries = append(entries, KnownHostEntry{
+			Line:        lineNo,
+			Patterns:    strings.Split(fields[0], ","),
+			KeyType:     fields[1],
+			Fingerprint: FingerprintSHA256(blob),
+			Revoked:     revoked,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read known_hosts: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Matches reports whether the entry applies to the given address and port, following
+// OpenSSH rules: "[host]:port" for non-default ports, hashed names, wildcards and negation.
+func (e KnownHostEntry) Matches(address string, port int) bool {
+	name := address
+	if port != 0 && port != 22 {
+		name = "[" + address + "]:" + strconv.Itoa(port)
+	}
+
+	matched := false
+	for _, pattern := range e.Patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+
+		if matchKnownHostPattern(pattern, name) {
+			if negate {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
+
+func matchKnownHostPattern(pattern, name string) bool {
+	if strings.HasPrefix(pattern, "|1|") {
+		parts := strings.Split(pattern[3:], "|")
+		if len(parts) != 2 {
+			return false
+		}
+		salt, err := base64.StdEncoding.DecodeString(parts[0])
+		if err != nil {
+			return false
+		}
+		want, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha1.New, salt)
+		mac.Write([]byte(name))
+		return hmac.Equal(mac.Sum(nil), want)
+	}
+
+	return matchWildcard(strings.ToLower(pattern), strings.ToLower(name))
+}
+
+// matchWildcard matches name against an OpenSSH pattern, where "*" matches any
+// run of characters and "?" any single character. Everything else, brackets
+// included, matches itself.
+func matchWildcard(pattern, name string) bool {
+	// Backtrack to the last "*" on a mismatch, like OpenSSH's match_pattern.
+	p, n := 0, 0
+	star, next := -1, 0
+	for n < len(name) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == name[n]):
+			p++
+			n++
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, n
+			p++
+		case star >= 0:
+			next++
+			p, n = star+1, next
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// ===== Import =====
+
+// KnownHostsImport reports the outcome of ImportKnownHosts.
+type KnownHostsImport struct {
+	// Added maps host IDs to the number of newly pinned keys.
+	Added map[string]int `json:"added"`
+	// Unmatched counts known_hosts entries that matched no inventory host.
+	Unmatched int `json:"unmatched"`
+	// Revoked maps host IDs to the number of keys unpinned because they are
+	// @revoked.
+	Revoked map[string]int `json:"revoked,omitempty"`
+}
+
+// DefaultKnownHostsPath returns ~/.ssh/known_hosts.
+func DefaultKnownHostsPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".ssh", "known_hosts")
+	}
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
+
+// ImportKnownHosts reads a known_hosts file (DefaultKnownHostsPath when empty) and pins
+// every key whose entry matches a host's address and port. Keys marked @revoked are
+// never pinned, and are unpinned from every host that has them.
+func (m *Manager) ImportKnownHosts(knownHostsPath string) (*KnownHostsImport, error) {
+	if knownHostsPath == "" {
+		knownHostsPath = DefaultKnownHostsPath()
+	}
+
+	f, err := os.Open(knownHostsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open known_hosts: %w", err)
+	}
+	defer f.Close()
+
+	entries, err := ParseKnownHosts(f)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := &KnownHostsImport{Added: make(map[string]int), Revoked: make(map[string]int)}
+	dirty := map[string]bool{}
+
+	revoked := map[string]bool{}
+	for _, e := range entries {
+		if e.Revoked {
+			revoked[e.Fingerprint] = true
+		}
+	}
+	for _, id := range sortedKeys(m.hosts) {
+		h := m.hosts[id]
+		for fingerprint := range revoked {
+			if h.RemoveHostKey(fingerprint) {
+				result.Revoked[id]++
+				dirty[m.sources[keyOf(h)]] = true
+			}
+		}
+	}
+
+	for _, e := range entries {
+		if revoked[e.Fingerprint] {
+			continue
+		}
+		matched := false
+		for _, id := range sortedKeys(m.hosts) {
+			h := m.hosts[id]
+			if !e.Matches(h.Address, h.Port) {
+				continue
+			}
+			matched = true
+			if h.AddHostKey(HostKey{KeyType: e.KeyType, Fingerprint: e.Fingerprint}) {
+				result.Added[id]++
+				dirty[m.sources[keyOf(h)]] = true
+			}
+		}
+		if !matched {
+			result.Unmatched++
+		}
+	}
+
+	return result, m.saveFiles(dirty)
+}
@@ -0,0 +1,134 @@
+package inventory
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testHostKey         = "AAAAC3NzaC1lZDI1NTE5AAAAIJtzCVg1PQgVjfoTWsrEfsfMVo/4sVNQ7gHMJqYX1q1a"
+	testHostFingerprint = "SHA256:HkYZY0/baiNkAkbefx1ollydKc07K+W3O/eXJWj3Uyc"
+)
+
+func hashedHostname(name string) string {
+	salt := []byte("0123456789abcdefghij")
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(name))
+	return "|1|" + base64.StdEncoding.EncodeToString(salt) + "|" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseKnownHosts(t *testing.T) {
+	content := strings.Join([]string{
+		"# comment",
+		"web.example.com,10.0.0.1 ssh-ed25519 " + testHostKey,
+		"@revoked * ssh-ed25519 " + testHostKey,
+		"",
+		"[10.0.0.2]:2222 ssh-ed25519 " + testHostKey + " comment",
+	}, "\n")
+
+	entries, err := ParseKnownHosts(strings.NewReader(content))
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, testHostFingerprint, entries[0].Fingerprint)
+	assert.Equal(t, "ssh-ed25519", entries[0].KeyType)
+	assert.False(t, entries[0].Revoked)
+	assert.True(t, entries[1].Revoked)
+	assert.Equal(t, []string{"*"}, entries[1].Patterns)
+	assert.Equal(t, testHostFingerprint, entries[1].Fingerprint)
+	assert.Equal(t, 5, entries[2].Line)
+
+	_, err = ParseKnownHosts(strings.NewReader("host ssh-ed25519 not-base64!"))
+	assert.Error(t, err)
+}
+
+func TestKnownHostEntryMatches(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		address  string
+		port     int
+		want     bool
+	}{
+		{[]string{"10.0.0.1"}, "10.0.0.1", 22, true},
+		{[]string{"10.0.0.1"}, "10.0.0.1", 2222, false},
+		{[]string{"[10.0.0.1]:2222"}, "10.0.0.1", 2222, true},
+		{[]string{"*.example.com"}, "Web.Example.com", 22, true},
+		{[]string{"*.example.com", "!db.example.com"}, "db.example.com", 22, false},
+		{[]string{"[*.example.com]:2222"}, "db.example.com", 2222, true},
+		{[]string{"[*.example.com]:2222"}, "db.example.com", 22, false},
+		{[]string{"[10.0.0.?]:22*"}, "10.0.0.7", 2222, true},
+		{[]string{"[10.0.0.?]:22*"}, "10.0.0.17", 2222, false},
+		{[]string{"[db*"}, "db.example.com", 2222, true},
+		{[]string{"*"}, "anything", 22, true},
+		{[]string{hashedHostname("10.0.0.9")}, "10.0.0.9", 22, true},
+		{[]string{hashedHostname("10.0.0.9")}, "10.0.0.8", 22, false},
+	}
+
+	for _, tt := range tests {
+		e := KnownHostEntry{Patterns: tt.patterns}
+		assert.Equal(t, tt.want, e.Matches(tt.address, tt.port), "%v vs %s:%d", tt.patterns, tt.address, tt.port)
+	}
+}
+
+func TestImportKnownHosts(t *testing.T) {
+	m, dir := setupTestManager(t)
+
+	for id, addr := range map[string]string{"web": "10.0.0.1", "other": "10.9.9.9"} {
+		h := NewHost(id, id, addr)
+		h.User = "root"
+		require.NoError(t, m.AddHost(h))
+	}
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	writeTestFile(t, filepath.Dir(knownHosts), "known_hosts",
+		hashedHostname("10.0.0.1")+" ssh-ed25519 "+testHostKey+"\n"+
+			"unknown.example.com ssh-ed25519 "+testHostKey+"\n")
+
+	result, err := m.ImportKnownHosts(knownHosts)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"web": 1}, result.Added)
+	assert.Equal(t, 1, result.Unmatched)
+
+	again, err := m.ImportKnownHosts(knownHosts)
+	require.NoError(t, err)
+	assert.Empty(t, again.Added, "keys are not pinned twice")
+
+	reloaded := NewManager(dir)
+	require.NoError(t, reloaded.Load())
+	h, _ := reloaded.GetHost("web")
+	require.Len(t, h.HostKeys, 1)
+	assert.True(t, h.HasHostKey(testHostFingerprint))
+}
+
+func TestImportKnownHostsRevoked(t *testing.T) {
+	m, _ := setupTestManager(t)
+	h := NewHost("web", "web", "10.0.0.1")
+	h.User = "root"
+	h.AddHostKey(HostKey{KeyType: "ssh-ed25519", Fingerprint: testHostFingerprint})
+	require.NoError(t, m.AddHost(h))
+	other := NewHost("db", "db", "10.0.0.2")
+	other.User = "root"
+	require.NoError(t, m.AddHost(other))
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	writeTestFile(t, filepath.Dir(knownHosts), "known_hosts",
+		"10.0.0.2 ssh-ed25519 "+testHostKey+"\n"+
+			"@revoked * ssh-ed25519 "+testHostKey+"\n")
+
+	result, err := m.ImportKnownHosts(knownHosts)
+	require.NoError(t, err)
+	assert.Empty(t, result.Added, "revoked keys are not pinned")
+	assert.Equal(t, map[string]int{"web": 1}, result.Revoked)
+
+	h, _ = m.GetHost("web")
+	assert.Empty(t, h.HostKeys, "revoked keys are unpinned")
+	other, _ = m.GetHost("db")
+	assert.Empty(t, other.HostKeys)
+}
@@ -0,0 +1,212 @@
+package inventory
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Lifecycle is the state of a host in the inventory.
+type Lifecycle string
+
+const (
+	// LifecycleActive hosts are in use; an empty lifecycle means active.
+	LifecycleActive Lifecycle = "active"
+	// LifecycleStale hosts were not seen for a while, by discovery or by pings.
+	LifecycleStale Lifecycle = "stale"
+	// LifecycleRetired hosts stayed stale and are purged by PurgeRetired.
+	LifecycleRetired Lifecycle = "retired"
+)
+
+// Validate checks that the lifecycle is known.
+func (l Lifecycle) Validate() error {
+	switch l {
+	case "", LifecycleActive, LifecycleStale, LifecycleRetired:
+		return nil
+	}
+	return fmt.Errorf("invalid lifecycle: %s", l)
+}
+
+// State returns the lifecycle of the host, LifecycleActive when unset.
+func (h *Host) State() Lifecycle {
+	if h.Lifecycle == "" {
+		return LifecycleActive
+	}
+	return h.Lifecycle
+}
+
+// SetState moves the host to a lifecycle state, recording when it left the
+// active state.
+func (h *Host) SetState(state Lifecycle, now time.Time) {
+	switch {
+	case state == LifecycleActive:
+		h.Lifecycle = ""
+		h.StaleSince = time.Time{}
+	case h.State() == LifecycleActive || h.StaleSince.IsZero():
+		h.Lifecycle = state
+		h.StaleSince = now
+	default:
+		h.Lifecycle = state
+	}
+}
+
+// ArchiveDir is the subdirectory of the data directory receiving purged hosts.
+const ArchiveDir = "archive"
+
+// LifecyclePolicy drives the automatic lifecycle transitions of UpdateLifecycle.
+type LifecyclePolicy struct {
+	// StaleAfter marks active hosts stale when they were last connected longer
+	// ago; zero disables the transition. Hosts never connected are left alone.
+	StaleAfter time.Duration `yaml:"stale_after,omitempty"`
+	// RetireAfter retires hosts that stayed stale this long; zero disables it.
+	RetireAfter time.Duration `yaml:"retire_after,omitempty"`
+}
+
+// Validate checks that no duration is negative.
+func (p LifecyclePolicy) Validate() error {
+	if p.StaleAfter < 0 || p.RetireAfter < 0 {
+		return fmt.Errorf("invalid lifecycle policy: durations cannot be negative")
+	}
+	return nil
+}
+
+// LifecycleResult lists the hosts whose state changed by ID.
+type LifecycleResult struct {
+	Reactivated []string
+	Stale       []string
+	Retired     []string
+}
+
+// UpdateLifecycle applies the transitions of a policy at now. Hosts currently
+// online (see SetHostStatus) count as connected at now: stale ones become active
+// again and their LastConnected is recorded. Only the files of hosts whose state
+// changed are rewritten; the LastConnected of hosts staying online is saved once
+// the saved one is half way to StaleAfter.
+func (m *Manager) UpdateLifecycle(policy LifecyclePolicy, now time.Time) (*LifecycleResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := &LifecycleResult{}
+	dirty := map[string]bool{}
+	for _, id := range sortedKeys(m.hosts) {
+		h := m.hosts[id]
+		if h.IsLocal() {
+			continue
+		}
+		changed := false
+
+		if h.Status == HostStatusOnline {
+			if policy.StaleAfter > 0 && now.Sub(h.LastConnected) > policy.StaleAfter/2 {
+				changed = true
+			}
+			h.LastConnected = now
+			if h.State() != LifecycleActive {
+				h.SetState(LifecycleActive, now)
+				result.Reactivated = append(result.Reactivated, id)
+				changed = true
+			}
+		}
+
+		switch h.State() {
+		case LifecycleActive:
+			if policy.StaleAfter > 0 && !h.LastConnected.IsZero() && now.Sub(h.LastConnected) > policy.StaleAfter {
+				h.SetState(LifecycleStale, now)
+				result.Stale = append(result.Stale, id)
+				changed = true
+			}
+		case LifecycleStale:
+			if policy.RetireAfter > 0 && now.Sub(h.StaleSince) > policy.RetireAfter {
+				h.SetState(LifecycleRetired, now)
+				result.Retired = append(result.Retired, id)
+				changed = true
+			}
+		}
+
+		if changed {
+			dirty[m.sources[entityKey{TypeHost, id}]] = true
+		}
+	}
+	return result, m.saveFiles(dirty)
+}
+
+// PurgeRetired archives the retired hosts to a file in ArchiveDir and removes them
+// from the inventory and their groups. Hosts still used as jump hosts are kept
+// and reported in the joined error. It returns the purged IDs and the archive
+// path, empty when nothing was purged.
+func (m *Manager) PurgeRetired(now time.Time) ([]string, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var retired []*Host
+	mode := m.perms.FileMode()
+	for _, id := range sortedKeys(m.hosts) {
+		if h := m.hosts[id]; h.State() == LifecycleRetired {
+			retired = append(retired, h)
+			if holdsSecret(h) {
+				mode = m.perms.SecretMode()
+			}
+		}
+	}
+	if len(retired) == 0 {
+		return nil, "", nil
+	}
+
+	dir := filepath.Join(m.dataDir, ArchiveDir)
+	if err := os.MkdirAll(dir, m.perms.DirMode()); err != nil {
+		return nil, "", fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	path := filepath.Join(dir, "hosts-"+now.UTC().Format("20060102-150405")+".yaml")
+
+	// The archive is written before the hosts are removed so that none is lost,
+	// and rewritten afterwards if some could not be removed.
+	if err := writeArchive(path, retired, mode); err != nil {
+		return nil, "", err
+	}
+
+	var purged []string
+	var kept []*Host
+	var errs []error
+	for _, h := range retired {
+		if err := m.removeHost(h.ID); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		purged = append(purged, h.ID)
+		kept = append(kept, h)
+	}
+
+	switch {
+	case len(kept) == 0:
+		os.Remove(path)
+		path = ""
+	case len(kept) < len(retired):
+		if err := writeArchive(path, kept, mode); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return purged, path, errors.Join(errs...)
+}
+
+// writeArchive writes hosts to path as a multi-document YAML file.
+func writeArchive(path string, hosts []*Host, mode os.FileMode) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	for _, h := range hosts {
+		if err := enc.Encode(h); err != nil {
+			return fmt.Errorf("failed to encode host %s: %w", h.ID, err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), mode); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
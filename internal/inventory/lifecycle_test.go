@@ -0,0 +1,150 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestUpdateLifecycle(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	m, dir := setupTestManager(t)
+	require.NoError(t, m.Load())
+	add := func(id string, lastConnected time.Time, state Lifecycle, staleSince time.Time) {
+		h := NewHost(id, id, "10.0.0.1")
+		h.User = "deploy"
+		h.LastConnected = lastConnected
+		h.Lifecycle = state
+		h.StaleSince = staleSince
+		require.NoError(t, m.AddHost(h))
+	}
+	add("fresh", now.Add(-day), "", time.Time{})
+	add("silent", now.Add(-10*day), "", time.Time{})
+	add("never", time.Time{}, "", time.Time{})
+	add("forgotten", now.Add(-60*day), LifecycleStale, now.Add(-31*day))
+	add("back", now.Add(-60*day), LifecycleStale, now.Add(-40*day))
+	require.NoError(t, m.SetHostStatus("back", HostStatusOnline))
+
+	result, err := m.UpdateLifecycle(LifecyclePolicy{StaleAfter: 7 * day, RetireAfter: 30 * day}, now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"back"}, result.Reactivated)
+	assert.Equal(t, []string{"silent"}, result.Stale)
+	assert.Equal(t, []string{"forgotten"}, result.Retired)
+
+	require.NoError(t, m.Load())
+	state := func(id string) Lifecycle {
+		h, ok := m.GetHost(id)
+		require.True(t, ok)
+		return h.State()
+	}
+	assert.Equal(t, LifecycleActive, state("fresh"))
+	assert.Equal(t, LifecycleStale, state("silent"))
+	assert.Equal(t, LifecycleActive, state("never"), "hosts never connected are left alone")
+	assert.Equal(t, LifecycleRetired, state("forgotten"))
+	assert.Equal(t, LifecycleActive, state("back"))
+
+	h, _ := m.GetHost("silent")
+	assert.True(t, h.StaleSince.Equal(now))
+	h, _ = m.GetHost("back")
+	assert.True(t, h.LastConnected.Equal(now), "online hosts record the contact")
+	assert.True(t, h.StaleSince.IsZero())
+	h, _ = m.GetHost("forgotten")
+	assert.True(t, h.StaleSince.Equal(now.Add(-31*day)), "retiring keeps the stale time")
+
+	t.Run("hosts staying online are not rewritten", func(t *testing.T) {
+		require.NoError(t, m.SetHostStatus("fresh", HostStatusOnline))
+		path := filepath.Join(dir, "host-fresh.yaml")
+		before, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		result, err := m.UpdateLifecycle(LifecyclePolicy{StaleAfter: 7 * day}, now.Add(time.Hour))
+		require.NoError(t, err)
+		assert.Empty(t, result.Stale)
+		after, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, string(before), string(after))
+
+		_, err = m.UpdateLifecycle(LifecyclePolicy{StaleAfter: 7 * day}, now.Add(4*day))
+		require.NoError(t, err)
+		after, err = os.ReadFile(path)
+		require.NoError(t, err)
+		assert.NotEqual(t, string(before), string(after), "old contacts are saved before they matter")
+	})
+
+	t.Run("invalid lifecycle", func(t *testing.T) {
+		h := NewHost("bad", "bad", "10.0.0.9")
+		h.User = "deploy"
+		h.Lifecycle = "gone"
+		assert.ErrorContains(t, m.AddHost(h), "invalid lifecycle")
+	})
+}
+
+func TestPurgeRetired(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	m, dir := setupTestManager(t)
+	require.NoError(t, m.Load())
+
+	for _, id := range []string{"keep", "old1", "old2", "jump"} {
+		h := NewHost(id, id, "10.0.0.1")
+		h.User = "deploy"
+		if id != "keep" {
+			h.Lifecycle = LifecycleRetired
+		}
+		if id == "old1" {
+			h.Password = "hunter22"
+		}
+		require.NoError(t, m.AddHost(h))
+	}
+	keep, _ := m.GetHost("keep")
+	keep.JumpHostID = "jump"
+	require.NoError(t, m.UpdateHost(keep))
+	require.NoError(t, m.AddGroup(&Group{Type: TypeGroup, Name: "old", HostIDs: []string{"old1", "keep"}}))
+
+	purged, path, err := m.PurgeRetired(now)
+	assert.ErrorContains(t, err, "still used as jump host")
+	assert.Equal(t, []string{"old1", "old2"}, purged)
+	assert.Equal(t, filepath.Join(dir, ArchiveDir, "hosts-20260301-120000.yaml"), path)
+
+	_, ok := m.GetHost("old1")
+	assert.False(t, ok)
+	g, _ := m.GetGroup("old")
+	assert.Equal(t, []string{"keep"}, g.HostIDs)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultSecretMode, info.Mode().Perm(), "archived passwords stay private")
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var ids []string
+	dec := yaml.NewDecoder(f)
+	for {
+		var h Host
+		if dec.Decode(&h) != nil {
+			break
+		}
+		ids = append(ids, h.ID)
+	}
+	assert.Equal(t, []string{"old1", "old2"}, ids, "hosts that were kept are not archived")
+
+	require.NoError(t, m.Load())
+	_, ok = m.GetHost("old2")
+	assert.False(t, ok, "the archive is not loaded")
+
+	t.Run("nothing to purge", func(t *testing.T) {
+		m, _ := setupTestManager(t)
+		require.NoError(t, m.Load())
+		purged, path, err := m.PurgeRetired(now)
+		require.NoError(t, err)
+		assert.Empty(t, purged)
+		assert.Empty(t, path)
+	})
+}
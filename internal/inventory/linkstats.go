@@ -0,0 +1,228 @@
+package inventory
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"gossher/internal/progress"
+)
+
+// DefaultLinkSamples is the number of recent samples kept per host and metric.
+const DefaultLinkSamples = 100
+
+const (
+	// degradedFactor is how many times the window's median a latest latency (or
+	// how many times smaller a latest throughput) must be for the link to count
+	// as degraded.
+	degradedFactor = 2
+	// degradedMinSamples is the number of samples a metric needs before it is judged.
+	degradedMinSamples = 5
+)
+
+// DurationStats summarizes the recent samples of a latency.
+type DurationStats struct {
+	Count int           `json:"count"`
+	Last  time.Duration `json:"last"`
+	Min   time.Duration `json:"min"`
+	Avg   time.Duration `json:"avg"`
+	P50   time.Duration `json:"p50"`
+	P95   time.Duration `json:"p95"`
+	Max   time.Duration `json:"max"`
+}
+
+// ThroughputStats summarizes the recent transfers; rates are in bytes per second.
+type ThroughputStats struct {
+	Count int     `json:"count"`
+	Bytes int64   `json:"bytes"`
+	Last  float64 `json:"last"`
+	Min   float64 `json:"min"`
+	Avg   float64 `json:"avg"`
+	P50   float64 `json:"p50"`
+}
+
+// LinkStats are the rolling connection statistics of a host: how long the
+// connection handshake takes, the round trip of commands and the throughput of
+// file transfers over the last DefaultLinkSamples of each.
+type LinkStats struct {
+	HostID     string          `json:"host_id"`
+	Handshake  DurationStats   `json:"handshake"`
+	RoundTrip  DurationStats   `json:"round_trip"`
+	Throughput ThroughputStats `json:"throughput"`
+	Updated    time.Time       `json:"updated"`
+}
+
+// Degraded describes the metrics whose latest sample is much worse than the
+// window's median, which points at a degraded link. It is empty for healthy links
+// and for metrics with too few samples to judge.
+func (s LinkStats) Degraded() []string {
+	var reasons []string
+	for _, d := range []struct {
+		name  string
+		stats DurationStats
+	}{{"handshake", s.Handshake}, {"round trip", s.RoundTrip}} {
+		if d.stats.Count >= degradedMinSamples && d.stats.P50 > 0 && d.stats.Last > degradedFactor*d.stats.P50 {
+			reasons = append(reasons, fmt.Sprintf("%s %s is %.1fx the median %s",
+				d.name, d.stats.Last, float64(d.stats.Last)/float64(d.stats.P50), d.stats.P50))
+		}
+	}
+	if t := s.Throughput; t.Count >= degradedMinSamples && t.Last > 0 && t.Last*degradedFactor < t.P50 {
+		reasons = append(reasons, fmt.Sprintf("throughput %s/s is %.1fx below the median %s/s",
+			progress.FormatBytes(int64(t.Last)), t.P50/t.Last, progress.FormatBytes(int64(t.P50))))
+	}
+	return reasons
+}
+
+// linkSamples holds the rolling windows of a host. Caller must hold the lock of
+// the Manager.
+type linkSamples struct {
+	handshake  []time.Duration
+	roundTrip  []time.Duration
+	throughput []float64
+	bytes      int64
+	updated    time.Time
+}
+
+// push appends v to a window, dropping the oldest sample when it is full.
+func push[T any](window []T, v T) []T {
+	if len(window) == DefaultLinkSamples {
+		window = append(window[:0], window[1:]...)
+	}
+	return append(window, v)
+}
+
+// ===== Manager integration =====
+
+// RecordHandshake records the time it took to connect and authenticate to a host.
+func (m *Manager) RecordHandshake(hostID string, d time.Duration) {
+	m.recordLink(hostID, func(s *linkSamples) { s.handshake = push(s.handshake, d) })
+}
+
+// RecordRoundTrip records the time a command took from sending it to its exit.
+func (m *Manager) RecordRoundTrip(hostID string, d time.Duration) {
+	m.recordLink(hostID, func(s *linkSamples) { s.roundTrip = push(s.roundTrip, d) })
+}
+
+// RecordTransfer records a file transfer of n bytes that took d.
+func (m *Manager) RecordTransfer(hostID string, n int64, d time.Duration) {
+	if n <= 0 || d <= 0 {
+		return
+	}
+	m.recordLink(hostID, func(s *linkSamples) {
+		s.throughput = push(s.throughput, float64(n)/d.Seconds())
+		s.bytes += n
+	})
+}
+
+// recordLink updates the samples of a known host; unknown hosts are ignored.
+func (m *Manager) recordLink(hostID string, update func(*linkSamples)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.hosts[hostID]; !ok {
+		return
+	}
+	if m.links == nil {
+		m.links = make(map[string]*linkSamples)
+	}
+	s := m.links[hostID]
+	if s == nil {
+		s = &linkSamples{}
+		m.links[hostID] = s
+	}
+	update(s)
+	s.updated = time.Now()
+}
+
+// LinkStats returns the connection statistics of a host, or false when nothing
+// was recorded for it.
+func (m *Manager) LinkStats(hostID string) (LinkStats, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.links[hostID]
+	if !ok || m.hosts[hostID] == nil {
+		return LinkStats{}, false
+	}
+	return s.summary(hostID), true
+}
+
+// ListLinkStats returns the connection statistics of every host with samples,
+// sorted by host ID.
+func (m *Manager) ListLinkStats() []LinkStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var stats []LinkStats
+	for _, id := range sortedKeys(m.links) {
+		if m.hosts[id] != nil {
+			stats = append(stats, m.links[id].summary(id))
+		}
+	}
+	return stats
+}
+
+// ResetLinkStats drops the samples of a host, e.g. after its network was fixed.
+func (m *Manager) ResetLinkStats(hostID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.links, hostID)
+}
+
+// summary computes the statistics of the windows.
+func (s *linkSamples) summary(hostID string) LinkStats {
+	stats := LinkStats{
+		HostID:    hostID,
+		Handshake: summarizeDurations(s.handshake),
+		RoundTrip: summarizeDurations(s.roundTrip),
+		Updated:   s.updated,
+	}
+
+	if n := len(s.throughput); n > 0 {
+		sorted := slices.Clone(s.throughput)
+		slices.Sort(sorted)
+		var sum float64
+		for _, v := range sorted {
+			sum += v
+		}
+		stats.Throughput = ThroughputStats{
+			Count: n,
+			Bytes: s.bytes,
+			Last:  s.throughput[n-1],
+			Min:   sorted[0],
+			Avg:   sum / float64(n),
+			P50:   sorted[percentileIndex(n, 50)],
+		}
+	}
+	return stats
+}
+
+// summarizeDurations computes the statistics of a latency window.
+func summarizeDurations(window []time.Duration) DurationStats {
+	n := len(window)
+	if n == 0 {
+		return DurationStats{}
+	}
+	sorted := slices.Clone(window)
+	slices.Sort(sorted)
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+	return DurationStats{
+		Count: n,
+		Last:  window[n-1],
+		Min:   sorted[0],
+		Avg:   sum / time.Duration(n),
+		P50:   sorted[percentileIndex(n, 50)],
+		P95:   sorted[percentileIndex(n, 95)],
+		Max:   sorted[n-1],
+	}
+}
+
+// percentileIndex returns the index of the p-th percentile (nearest rank) of n
+// sorted samples.
+func percentileIndex(n, p int) int {
+	i := (n*p+99)/100 - 1
+	return max(i, 0)
+}
@@ -0,0 +1,82 @@
+package inventory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkStats(t *testing.T) {
+	setup := func(t *testing.T) *Manager {
+		m, _ := setupTestManager(t)
+		h := NewHost("web01", "web01", "10.0.0.1")
+		h.User = "deploy"
+		require.NoError(t, m.AddHost(h))
+		return m
+	}
+
+	t.Run("summarizes the samples", func(t *testing.T) {
+		m := setup(t)
+		for _, ms := range []int{10, 30, 20, 40, 50} {
+			m.RecordRoundTrip("web01", time.Duration(ms)*time.Millisecond)
+		}
+		m.RecordHandshake("web01", 100*time.Millisecond)
+		m.RecordTransfer("web01", 2048, time.Second)
+		m.RecordTransfer("web01", 4096, time.Second)
+
+		s, ok := m.LinkStats("web01")
+		require.True(t, ok)
+		assert.Equal(t, DurationStats{
+			Count: 5,
+			Last:  50 * time.Millisecond,
+			Min:   10 * time.Millisecond,
+			Avg:   30 * time.Millisecond,
+			P50:   30 * time.Millisecond,
+			P95:   50 * time.Millisecond,
+			Max:   50 * time.Millisecond,
+		}, s.RoundTrip)
+		assert.Equal(t, 1, s.Handshake.Count)
+		assert.Equal(t, ThroughputStats{Count: 2, Bytes: 6144, Last: 4096, Min: 2048, Avg: 3072, P50: 2048}, s.Throughput)
+		assert.Empty(t, s.Degraded())
+		assert.Equal(t, []LinkStats{s}, m.ListLinkStats())
+	})
+
+	t.Run("keeps a rolling window", func(t *testing.T) {
+		m := setup(t)
+		for i := 1; i <= DefaultLinkSamples+10; i++ {
+			m.RecordHandshake("web01", time.Duration(i)*time.Millisecond)
+		}
+		s, _ := m.LinkStats("web01")
+		assert.Equal(t, DefaultLinkSamples, s.Handshake.Count)
+		assert.Equal(t, 11*time.Millisecond, s.Handshake.Min)
+	})
+
+	t.Run("flags degraded links", func(t *testing.T) {
+		m := setup(t)
+		for range 10 {
+			m.RecordRoundTrip("web01", 20*time.Millisecond)
+			m.RecordTransfer("web01", 10<<20, time.Second)
+		}
+		m.RecordRoundTrip("web01", 200*time.Millisecond)
+		m.RecordTransfer("web01", 1<<20, time.Second)
+
+		s, _ := m.LinkStats("web01")
+		assert.Equal(t, []string{
+			"round trip 200ms is 10.0x the median 20ms",
+			"throughput 1.0 MiB/s is 10.0x below the median 10.0 MiB/s",
+		}, s.Degraded())
+	})
+
+	t.Run("ignores unknown hosts and forgets removed ones", func(t *testing.T) {
+		m := setup(t)
+		m.RecordHandshake("db01", time.Millisecond)
+		_, ok := m.LinkStats("db01")
+		assert.False(t, ok)
+
+		m.RecordHandshake("web01", time.Millisecond)
+		require.NoError(t, m.RemoveHost("web01"))
+		assert.Empty(t, m.ListLinkStats())
+	})
+}
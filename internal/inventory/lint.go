@@ -0,0 +1,142 @@
+package inventory
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"gossher/internal/term"
+)
+
+// DefaultMaxGroupHosts is the group size above which Lint suggests splitting a group.
+const DefaultMaxGroupHosts = 50
+
+// Severity ranks lint findings.
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	// SeverityError is used by Doctor for problems that break features.
+	SeverityError Severity = "error"
+)
+
+// Lint rule names, usable in LintOptions.Disabled.
+const (
+	RuleMissingDescription = "missing-description"
+	RuleUntaggedHost       = "untagged-host"
+	RuleInlinePassword     = "inline-password"
+	RuleUndocumentedPort   = "undocumented-port"
+	RuleLargeGroup         = "large-group"
+)
+
+// LintFinding is a style or consistency problem of one entity.
+type LintFinding struct {
+	Rule     string       `json:"rule"`
+	Severity Severity     `json:"severity"`
+	Type     DocumentType `json:"type"`
+	ID       string       `json:"id"`
+	Message  string       `json:"message"`
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s: %s %s: %s (%s)", f.Severity, f.Type, f.ID, f.Message, f.Rule)
+}
+
+// LintOptions tunes the lint rules.
+type LintOptions struct {
+	// MaxGroupHosts is the largest acceptable group, counting nested members
+	// (default DefaultMaxGroupHosts).
+	MaxGroupHosts int
+	// Disabled lists rules to skip.
+	Disabled []string
+}
+
+// Lint checks the inventory against the style rules with default options.
+func (m *Manager) Lint() []LintFinding {
+	return m.LintWithOptions(LintOptions{})
+}
+
+// LintWithOptions checks the inventory against the style rules. Findings are sorted
+// by type and ID.
+func (m *Manager) LintWithOptions(opts LintOptions) []LintFinding {
+	if opts.MaxGroupHosts <= 0 {
+		opts.MaxGroupHosts = DefaultMaxGroupHosts
+	}
+	disabled := map[string]bool{}
+	for _, rule := range opts.Disabled {
+		disabled[rule] = true
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	findings := []LintFinding{}
+	report := func(rule string, severity Severity, kind DocumentType, id, format string, args ...any) {
+		if disabled[rule] {
+			return
+		}
+		findings = append(findings, LintFinding{
+			Rule:     rule,
+			Severity: severity,
+			Type:     kind,
+			ID:       id,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, id := range sortedKeys(m.hosts) {
+		h := m.hosts[id]
+		if h.Description == "" {
+			report(RuleMissingDescription, SeverityInfo, TypeHost, id, "host has no description")
+		}
+		if len(h.Tags) == 0 {
+			report(RuleUntaggedHost, SeverityInfo, TypeHost, id, "host has no tags")
+		}
+		if h.Password != "" {
+			report(RuleInlinePassword, SeverityWarning, TypeHost, id,
+				"password is stored inline; move it to a credential")
+		}
+		// A nonstandard port should be explained, e.g. "sshd on 2222 behind NAT"
+		if h.Port != 0 && h.Port != 22 && h.Description == "" {
+			report(RuleUndocumentedPort, SeverityWarning, TypeHost, id,
+				"port %d is nonstandard but not explained in the description", h.Port)
+		}
+	}
+
+	for _, name := range sortedKeys(m.groups) {
+		g := m.groups[name]
+		if g.Description == "" {
+			report(RuleMissingDescription, SeverityInfo, TypeGroup, name, "group has no description")
+		}
+		ids, _ := m.resolveGroupHosts(name)
+		if len(ids) > opts.MaxGroupHosts {
+			report(RuleLargeGroup, SeverityWarning, TypeGroup, name,
+				"group has %d hosts (more than %d); consider splitting it", len(ids), opts.MaxGroupHosts)
+		}
+	}
+
+	for _, id := range sortedKeys(m.credentials) {
+		if m.credentials[id].Description == "" {
+			report(RuleMissingDescription, SeverityInfo, TypeCredential, id, "credential has no description")
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Type != findings[j].Type {
+			return findings[i].Type < findings[j].Type
+		}
+		return findings[i].ID < findings[j].ID
+	})
+	return findings
+}
+
+// WriteLintFindings renders findings as an aligned table.
+func WriteLintFindings(w io.Writer, findings []LintFinding) error {
+	tw := term.NewTable(w)
+	fmt.Fprintf(tw, "SEVERITY\tTYPE\tID\tRULE\tMESSAGE\n")
+	for _, f := range findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", f.Severity, f.Type, f.ID, f.Rule, f.Message)
+	}
+	return tw.Flush()
+}
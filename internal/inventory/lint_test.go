@@ -0,0 +1,78 @@
+package inventory
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	setup := func(t *testing.T) *Manager {
+		m, _ := setupTestManager(t)
+
+		clean := NewHost("web1", "web1", "10.0.0.1")
+		clean.User = "deploy"
+		clean.Description = "Frontend"
+		clean.Port = 2222
+		clean.AddTag("env:prod")
+		require.NoError(t, m.AddHost(clean))
+
+		sloppy := NewHost("db1", "db1", "10.0.0.2")
+		sloppy.User = "root"
+		sloppy.Password = "hunter22"
+		sloppy.Port = 2200
+		require.NoError(t, m.AddHost(sloppy))
+
+		g := NewGroup("all")
+		g.Description = "Everything"
+		g.AddHost("web1")
+		g.AddHost("db1")
+		require.NoError(t, m.AddGroup(g))
+		return m
+	}
+
+	rules := func(findings []LintFinding, id string) []string {
+		var out []string
+		for _, f := range findings {
+			if f.ID == id {
+				out = append(out, f.Rule)
+			}
+		}
+		return out
+	}
+
+	t.Run("reports style problems", func(t *testing.T) {
+		m := setup(t)
+		findings := m.Lint()
+
+		assert.Empty(t, rules(findings, "web1"))
+		assert.Empty(t, rules(findings, "all"))
+		assert.ElementsMatch(t, []string{
+			RuleMissingDescription, RuleUntaggedHost, RuleInlinePassword, RuleUndocumentedPort,
+		}, rules(findings, "db1"))
+
+		for _, f := range findings {
+			if f.Rule == RuleInlinePassword {
+				assert.Equal(t, SeverityWarning, f.Severity)
+				assert.NotContains(t, f.String(), "hunter22")
+			}
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, WriteLintFindings(&buf, findings))
+		assert.Contains(t, buf.String(), "inline-password")
+	})
+
+	t.Run("options", func(t *testing.T) {
+		m := setup(t)
+		findings := m.LintWithOptions(LintOptions{
+			MaxGroupHosts: 1,
+			Disabled:      []string{RuleMissingDescription, RuleUntaggedHost},
+		})
+
+		assert.Equal(t, []string{RuleLargeGroup}, rules(findings, "all"))
+		assert.ElementsMatch(t, []string{RuleInlinePassword, RuleUndocumentedPort}, rules(findings, "db1"))
+	})
+}
@@ -0,0 +1,1140 @@
+package inventory
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gossher/internal/events"
+	"gossher/internal/script"
+
+	"gopkg.in/yaml.v3"
+)
+
+// entityKey identifies an entity across document types.
+type entityKey struct {
+	Type DocumentType
+	ID   string
+}
+
+// Manager holds the inventory loaded from a data directory and keeps it in sync with the files on disk.
+type Manager struct {
+	dataDir string
+	mu      sync.RWMutex
+
+	hosts       map[string]*Host
+	groups      map[string]*Group
+	credentials map[string]*Credential
+	commands    map[string]*SavedCommand
+	checks      map[string]*Check
+	workflows   map[string]*Workflow
+	themes      map[string]*Theme
+	sessionSets map[string]*SessionSet
+
+	// sources maps each entity to the file (relative to dataDir) it is stored in,
+	// files keeps the document order of every file so it can be rewritten faithfully.
+	sources map[entityKey]string
+	files   map[string][]entityKey
+
+	tagPolicy  *TagPolicy
+	idPolicy   IDPolicy
+	inlineAuth InlineAuthPolicy
+	strict     bool
+	perms      FilePermissions
+
+	// networks are the definitions requires_network refers to.
+	networks map[string]NetworkRequirement
+
+	// links holds the rolling connection statistics of hosts (not saved).
+	links map[string]*linkSamples
+
+	// authenticator logs in to hosts for credential tests.
+	authenticator Authenticator
+
+	// bus receives host status changes.
+	bus *events.Bus
+
+	// scripts holds the hooks loaded from ScriptsDir.
+	scripts *script.Program
+
+	// codecs decode files by extension; credentialCodec encodes new credential files.
+	codecs          map[string]FileCodec
+	credentialCodec FileCodec
+
+	// readOnly holds files decrypted by a ContentDecoder, keyed to the decoder name.
+	readOnly map[string]string
+
+	// nodes keeps the parsed document of every loaded entity and indents the
+	// indentation of every loaded file, so rewrites keep comments and formatting.
+	nodes   map[entityKey]*yaml.Node
+	indents map[string]int
+}
+
+// NewManager creates an empty Manager bound to the given data directory.
+func NewManager(dataDir string) *Manager {
+	return &Manager{
+		dataDir:     dataDir,
+		hosts:       make(map[string]*Host),
+		groups:      make(map[string]*Group),
+		credentials: make(map[string]*Credential),
+		commands:    make(map[string]*SavedCommand),
+		checks:      make(map[string]*Check),
+		workflows:   make(map[string]*Workflow),
+		themes:      make(map[string]*Theme),
+		sessionSets: make(map[string]*SessionSet),
+		sources:     make(map[entityKey]string),
+		files:       make(map[string][]entityKey),
+		readOnly:    make(map[string]string),
+		nodes:       make(map[entityKey]*yaml.Node),
+		indents:     make(map[string]int),
+		idPolicy:    IDPolicyNormalize,
+		bus:         events.Default(),
+	}
+}
+
+// GetDataDir returns the directory the Manager reads from and writes to.
+func (m *Manager) GetDataDir() string {
+	return m.dataDir
+}
+
+// ===== Loading =====
+
+// Load reads every YAML file in the data directory, replacing the current in-memory inventory.
+func (m *Manager) Load() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.hosts = make(map[string]*Host)
+	m.groups = make(map[string]*Group)
+	m.credentials = make(map[string]*Credential)
+	m.commands = make(map[string]*SavedCommand)
+	m.checks = make(map[string]*Check)
+	m.workflows = make(map[string]*Workflow)
+	m.themes = make(map[string]*Theme)
+	m.sessionSets = make(map[string]*SessionSet)
+	m.sources = make(map[entityKey]string)
+	m.files = make(map[string][]entityKey)
+	m.readOnly = make(map[string]string)
+	m.nodes = make(map[entityKey]*yaml.Node)
+	m.indents = make(map[string]int)
+	m.scripts = nil
+
+	entries, err := os.ReadDir(m.dataDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to list data directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !m.isDataFile(entry.Name()) {
+			continue
+		}
+
+		entities, nodes, err := m.loadFile(entry.Name())
+		if err != nil {
+			return err
+		}
+
+		for i, e := range entities {
+			if err := m.register(e, entry.Name()); err != nil {
+				return fmt.Errorf("%s: %w", entry.Name(), err)
+			}
+			m.nodes[keyOf(e)] = nodes[i]
+		}
+	}
+
+	m.reconcileDeclaredGroups()
+	return m.loadScripts()
+}
+
+// register adds a loaded entity to the in-memory maps. Caller must hold the lock.
+func (m *Manager) register(e Entity, filename string) error {
+	key := keyOf(e)
+	if _, exists := m.sources[key]; exists {
+		return fmt.Errorf("duplicate %s ID: %s", key.Type, key.ID)
+	}
+
+	switch v := e.(type) {
+	case *Host:
+		m.hosts[v.ID] = v
+	case *Group:
+		m.groups[v.Name] = v
+	case *Credential:
+		m.credentials[v.ID] = v
+	case *SavedCommand:
+		m.commands[v.ID] = v
+	case *Check:
+		m.checks[v.ID] = v
+	case *Workflow:
+		m.workflows[v.ID] = v
+	case *Theme:
+		m.themes[v.ID] = v
+	case *SessionSet:
+		m.sessionSets[v.ID] = v
+	default:
+		return fmt.Errorf("unsupported entity: %T", e)
+	}
+
+	m.sources[key] = filename
+	m.files[filename] = append(m.files[filename], key)
+	return nil
+}
+
+// unregister removes an entity from the in-memory maps and returns the file it lived in.
+// Caller must hold the lock.
+func (m *Manager) unregister(key entityKey) string {
+	switch key.Type {
+	case TypeHost:
+		delete(m.hosts, key.ID)
+	case TypeGroup:
+		delete(m.groups, key.ID)
+	case TypeCredential:
+		delete(m.credentials, key.ID)
+	case TypeCommand:
+		delete(m.commands, key.ID)
+	case TypeCheck:
+		delete(m.checks, key.ID)
+	case TypeWorkflow:
+		delete(m.workflows, key.ID)
+	case TypeTheme:
+		delete(m.themes, key.ID)
+	case TypeSessionSet:
+		delete(m.sessionSets, key.ID)
+	}
+
+	filename := m.sources[key]
+	delete(m.sources, key)
+	delete(m.nodes, key)
+
+	keys := m.files[filename]
+	for i, k := range keys {
+		if k == key {
+			m.files[filename] = append(keys[:i], keys[i+1:]...)
+			break
+		}
+	}
+	return filename
+}
+
+// loadFile loads a data file, decoding it first when a codec handles its extension.
+// It returns the entities with their parsed documents. Caller must hold the lock.
+func (m *Manager) loadFile(filename string) ([]Entity, []*yaml.Node, error) {
+	path := filepath.Join(m.dataDir, filename)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	if codec, ok := m.codecFor(filename); ok {
+		data, err = codec.Decode(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode file %s: %w", path, err)
+		}
+	} else {
+		var decoder string
+		data, decoder, err = DecodeContent(path, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		if decoder != "" {
+			m.readOnly[filename] = decoder
+		}
+	}
+
+	data, err = ToYAML(filename, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.indents[filename] = detectIndent(data)
+	return parseEntities(path, data, m.strict)
+}
+
+// loadEntitiesFromFile reads a (possibly multi-document) YAML file and returns its entities.
+// Documents of types the Manager does not own (e.g. config) are skipped.
+func loadEntitiesFromFile(path string) ([]Entity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	entities, _, err := parseEntities(path, data, false)
+	return entities, err
+}
+
+// parseEntities decodes every document in data and returns the entities with their
+// document nodes; path is only used in error messages. With strict, unknown keys
+// are an error.
+func parseEntities(path string, data []byte, strict bool) ([]Entity, []*yaml.Node, error) {
+	docs, err := splitYAMLDocumentLines(data)
+	if err != nil {
+		return nil, nil, NewParseError(path, data, 0, err)
+	}
+
+	var entities []Entity
+	var nodes []*yaml.Node
+	for _, doc := range docs {
+		e, err := loadEntity(doc.data, strict)
+		if err != nil {
+			return nil, nil, NewParseError(path, data, doc.line-1, err)
+		}
+		if e == nil {
+			continue
+		}
+
+		var node yaml.Node
+		if err := yaml.Unmarshal(doc.data, &node); err != nil {
+			return nil, nil, NewParseError(path, data, doc.line-1, err)
+		}
+		entities = append(entities, e)
+		nodes = append(nodes, &node)
+	}
+
+	return entities, nodes, nil
+}
+
+// splitYAMLDocuments splits raw YAML into its documents.
+func splitYAMLDocuments(data []byte) ([][]byte, error) {
+	docs, err := splitYAMLDocumentLines(data)
+	if err != nil {
+		return nil, err
+	}
+	raw := make([][]byte, len(docs))
+	for i, doc := range docs {
+		raw[i] = doc.data
+	}
+	return raw, nil
+}
+
+// yamlDocument is one document of a file and the line it starts at.
+type yamlDocument struct {
+	data []byte
+	line int
+}
+
+// splitYAMLDocumentLines is splitYAMLDocuments keeping each document's first line,
+// so errors can be reported relative to the file. The yaml decoder finds the
+// documents, so "---" inside scalars and markers followed by comments or content
+// are handled like the YAML spec says. Empty documents are dropped.
+func splitYAMLDocumentLines(data []byte) ([]yamlDocument, error) {
+	// The decoder reports the line of each document's "---", or of its first
+	// content for an implicit first document; a document ends where the next begins.
+	type bound struct {
+		line  int
+		empty bool
+	}
+	var bounds []bound
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node yaml.Node
+		if err := dec.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		line := node.Line
+		if len(bounds) == 0 {
+			line = 1
+		}
+		bounds = append(bounds, bound{line: line, empty: emptyDocument(&node)})
+	}
+
+	lineStarts := []int{0}
+	for i, b := range data {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	offset := func(line int) int {
+		if line < 1 || line > len(lineStarts) {
+			return len(data)
+		}
+		return lineStarts[line-1]
+	}
+
+	var docs []yamlDocument
+	for i, b := range bounds {
+		if b.empty {
+			continue
+		}
+		line := b.line
+		from, to := offset(line), len(data)
+		if i+1 < len(bounds) {
+			to = max(offset(bounds[i+1].line), from)
+		}
+		// Drop a bare "---" line; one with content like "--- |" starts the document.
+		if marker, rest, ok := bytes.Cut(data[from:to], []byte("\n")); ok && bareMarker(marker) {
+			from, line = to-len(rest), line+1
+		}
+		docs = append(docs, yamlDocument{data: data[from:to], line: line})
+	}
+	return docs, nil
+}
+
+// bareMarker reports whether a line is a "---" document marker without content.
+func bareMarker(line []byte) bool {
+	rest, ok := bytes.CutPrefix(bytes.TrimRight(line, " \t\r"), []byte("---"))
+	if !ok {
+		return false
+	}
+	if len(rest) == 0 {
+		return true
+	}
+	// A comment must be separated from the marker by whitespace.
+	comment := bytes.TrimLeft(rest, " \t")
+	return len(comment) < len(rest) && comment[0] == '#'
+}
+
+// emptyDocument reports whether a document has no content, e.g. between two "---".
+func emptyDocument(node *yaml.Node) bool {
+	if len(node.Content) == 0 {
+		return true
+	}
+	c := node.Content[0]
+	return c.Kind == yaml.ScalarNode && c.Tag == "!!null"
+}
+
+// loadEntity decodes a single YAML document into the entity matching its type field.
+// It returns nil without error for document types that are not inventory entities.
+func loadEntity(doc []byte, strict bool) (Entity, error) {
+	var typeDoc struct {
+		Type DocumentType `yaml:"type"`
+	}
+	if err := yaml.Unmarshal(doc, &typeDoc); err != nil {
+		return nil, fmt.Errorf("failed to extract type: %w", err)
+	}
+
+	var e Entity
+	switch typeDoc.Type {
+	case TypeHost:
+		e = &Host{}
+	case TypeGroup:
+		e = &Group{}
+	case TypeCredential:
+		e = &Credential{}
+	case TypeCommand:
+		e = &SavedCommand{}
+	case TypeCheck:
+		e = &Check{}
+	case TypeWorkflow:
+		e = &Workflow{}
+	case TypeTheme:
+		e = &Theme{}
+	case TypeSessionSet:
+		e = &SessionSet{}
+	case TypeConfig:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown document type: %s", typeDoc.Type)
+	}
+
+	if err := unmarshal(doc, e, strict); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
+	}
+
+	return e, nil
+}
+
+// ===== Saving =====
+
+// saveFile rewrites a data file with all entities currently assigned to it.
+// The file is removed once it no longer holds any entity. Caller must hold the lock.
+func (m *Manager) saveFile(filename string) error {
+	if err := m.checkWritable(filename); err != nil {
+		return err
+	}
+
+	path := filepath.Join(m.dataDir, filename)
+	keys := m.files[filename]
+
+	if len(keys) == 0 {
+		delete(m.files, filename)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete file %s: %w", path, err)
+		}
+		return nil
+	}
+
+	data, err := m.renderFile(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.dataDir, m.perms.DirMode()); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	mode := m.fileMode(filename)
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+	// WriteFile keeps the mode of existing files, e.g. a host file that gained a password.
+	if err := os.Chmod(path, mode); err != nil {
+		return fmt.Errorf("failed to change permissions of %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// renderFile marshals all entities assigned to a file as a multi-document YAML stream,
+// encoded with the file's codec if it has one. Documents loaded from the file keep
+// their comments, key order and indentation. Caller must hold the lock.
+func (m *Manager) renderFile(filename string) ([]byte, error) {
+	docs := make([]*yaml.Node, 0, len(m.files[filename]))
+	for _, key := range m.files[filename] {
+		node, ok := m.nodes[key]
+		if !ok {
+			node = &yaml.Node{Kind: yaml.DocumentNode}
+		}
+		if err := mergeValue(node, m.lookup(key)); err != nil {
+			return nil, fmt.Errorf("failed to marshal %s %s: %w", key.Type, key.ID, err)
+		}
+		m.nodes[key] = node
+		docs = append(docs, node)
+	}
+
+	indent, ok := m.indents[filename]
+	if !ok {
+		indent = defaultIndent
+	}
+	data, err := encodeDocuments(docs, indent)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = FromYAML(filename, data); err != nil {
+		return nil, err
+	}
+
+	if codec, ok := m.codecFor(filename); ok {
+		encoded, err := codec.Encode(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode file %s: %w", filename, err)
+		}
+		return encoded, nil
+	}
+	return data, nil
+}
+
+// fileMode returns the permissions for a data file. Encoded files, imported private
+// keys and files holding secrets are private. Caller must hold the lock.
+func (m *Manager) fileMode(filename string) os.FileMode {
+	if _, ok := m.codecFor(filename); ok {
+		return m.perms.SecretMode()
+	}
+	if _, ok := m.readOnly[filename]; ok {
+		return m.perms.SecretMode()
+	}
+	if isImportedKey(filename) && !strings.HasSuffix(filename, ".pub") {
+		return m.perms.SecretMode()
+	}
+	for _, key := range m.files[filename] {
+		if holdsSecret(m.lookup(key)) {
+			return m.perms.SecretMode()
+		}
+	}
+	return m.perms.FileMode()
+}
+
+// store registers a new entity under its default filename and writes it. Caller must hold the lock.
+func (m *Manager) store(e Entity) error {
+	key := keyOf(e)
+	filename := m.filenameFor(key)
+	if err := m.register(e, filename); err != nil {
+		return err
+	}
+	return m.saveFile(filename)
+}
+
+// lookup returns the in-memory entity for a key. Caller must hold the lock.
+func (m *Manager) lookup(key entityKey) Entity {
+	switch key.Type {
+	case TypeHost:
+		if h, ok := m.hosts[key.ID]; ok {
+			return h
+		}
+	case TypeGroup:
+		if g, ok := m.groups[key.ID]; ok {
+			return g
+		}
+	case TypeCredential:
+		if c, ok := m.credentials[key.ID]; ok {
+			return c
+		}
+	case TypeCommand:
+		if c, ok := m.commands[key.ID]; ok {
+			return c
+		}
+	case TypeCheck:
+		if c, ok := m.checks[key.ID]; ok {
+			return c
+		}
+	case TypeWorkflow:
+		if w, ok := m.workflows[key.ID]; ok {
+			return w
+		}
+	case TypeTheme:
+		if t, ok := m.themes[key.ID]; ok {
+			return t
+		}
+	case TypeSessionSet:
+		if s, ok := m.sessionSets[key.ID]; ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// ===== Hosts =====
+
+// GetHost returns a copy of the host with the given ID.
+func (m *Manager) GetHost(id string) (*Host, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	h, ok := m.hosts[id]
+	if !ok {
+		return nil, false
+	}
+	return h.Clone().(*Host), true
+}
+
+// ListHosts returns copies of all hosts sorted by ID.
+func (m *Manager) ListHosts() []*Host {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hosts := make([]*Host, 0, len(m.hosts))
+	for _, id := range sortedKeys(m.hosts) {
+		hosts = append(hosts, m.hosts[id].Clone().(*Host))
+	}
+	return hosts
+}
+
+// AddHost validates and stores a new host. The ID policy may rewrite h.ID.
+func (m *Manager) AddHost(h *Host) error {
+	if err := h.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, err := m.checkNewID(TypeHost, h.ID)
+	if err != nil {
+		return err
+	}
+	h.ID = id
+
+	if _, exists := m.hosts[h.ID]; exists {
+		return fmt.Errorf("host %s already exists", h.ID)
+	}
+	if err := m.checkHostRefs(h); err != nil {
+		return err
+	}
+	if err := m.checkInlineAuth(h, nil); err != nil {
+		return err
+	}
+
+	stored := h.Clone().(*Host)
+	stored.Type = TypeHost
+	if err := m.validateHostTags(stored); err != nil {
+		return err
+	}
+	dirty := map[string]bool{}
+	if err := m.syncDeclaredGroups(stored, nil, dirty); err != nil {
+		return err
+	}
+	if err := m.store(stored); err != nil {
+		return err
+	}
+	return m.saveFiles(dirty)
+}
+
+// UpdateHost replaces an existing host and rewrites its file.
+func (m *Manager) UpdateHost(h *Host) error {
+	if err := h.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.hosts[h.ID]; !exists {
+		return fmt.Errorf("host %s not found", h.ID)
+	}
+	if err := m.checkHostRefs(h); err != nil {
+		return err
+	}
+	if err := m.checkInlineAuth(h, m.hosts[h.ID]); err != nil {
+		return err
+	}
+
+	stored := h.Clone().(*Host)
+	stored.Type = TypeHost
+	if err := m.validateHostTags(stored); err != nil {
+		return err
+	}
+	dirty := map[string]bool{m.sources[entityKey{TypeHost, h.ID}]: true}
+	if err := m.syncDeclaredGroups(stored, m.hosts[h.ID], dirty); err != nil {
+		return err
+	}
+	m.hosts[h.ID] = stored
+	return m.saveFiles(dirty)
+}
+
+// checkHostRefs verifies that the credential, jump host, relay host and related
+// hosts a host refers to exist.
+// Caller must hold the lock.
+func (m *Manager) checkHostRefs(h *Host) error {
+	if h.CredentialID != "" {
+		if _, ok := m.credentials[h.CredentialID]; !ok {
+			return fmt.Errorf("host %s: credential %s not found", h.ID, h.CredentialID)
+		}
+	}
+	if h.JumpHostID != "" {
+		jump, ok := m.hosts[h.JumpHostID]
+		if !ok {
+			return fmt.Errorf("host %s: jump host %s not found", h.ID, h.JumpHostID)
+		}
+		if jump.IsLocal() {
+			return fmt.Errorf("host %s: local host %s cannot be a jump host", h.ID, h.JumpHostID)
+		}
+	}
+	if h.RelayHostID != "" {
+		relay, ok := m.hosts[h.RelayHostID]
+		if !ok {
+			return fmt.Errorf("host %s: relay host %s not found", h.ID, h.RelayHostID)
+		}
+		if relay.IsLocal() {
+			return fmt.Errorf("host %s: local host %s cannot be a relay host", h.ID, h.RelayHostID)
+		}
+	}
+	return m.checkRelations(h)
+}
+
+// RemoveHost deletes a host and drops it from every group and relation that
+// references it.
+func (m *Manager) RemoveHost(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.removeHost(id)
+}
+
+// removeHost removes a host. Caller must hold the lock.
+func (m *Manager) removeHost(id string) error {
+	if _, exists := m.hosts[id]; !exists {
+		return fmt.Errorf("host %s not found", id)
+	}
+
+	for _, otherID := range sortedKeys(m.hosts) {
+		if m.hosts[otherID].JumpHostID == id {
+			return fmt.Errorf("host %s is still used as jump host by %s", id, otherID)
+		}
+		if m.hosts[otherID].RelayHostID == id {
+			return fmt.Errorf("host %s is still used as relay host by %s", id, otherID)
+		}
+	}
+
+	dirty := map[string]bool{}
+	for _, g := range m.groups {
+		if g.HasHost(id) {
+			g.RemoveHost(id)
+			dirty[m.sources[keyOf(g)]] = true
+		}
+	}
+	m.updateCheckRefs(TypeHost, id, "", dirty)
+	m.updateRelationRefs(id, "", dirty)
+	m.updateSessionSetRefs(id, "", dirty)
+
+	dirty[m.unregister(entityKey{TypeHost, id})] = true
+	delete(m.links, id)
+	if err := m.saveFiles(dirty); err != nil {
+		return err
+	}
+	if err := m.removeRunbook(TypeHost, id); err != nil {
+		return err
+	}
+	return m.removeAttachments(id)
+}
+
+// ===== Groups =====
+
+// GetGroup returns a copy of the group with the given name.
+func (m *Manager) GetGroup(name string) (*Group, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.groups[name]
+	if !ok {
+		return nil, false
+	}
+	return g.Clone().(*Group), true
+}
+
+// ListGroups returns copies of all groups sorted by name.
+func (m *Manager) ListGroups() []*Group {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	groups := make([]*Group, 0, len(m.groups))
+	for _, name := range sortedKeys(m.groups) {
+		groups = append(groups, m.groups[name].Clone().(*Group))
+	}
+	return groups
+}
+
+// AddGroup validates and stores a new group. The ID policy may rewrite g.Name.
+func (m *Manager) AddGroup(g *Group) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name, err := m.checkNewID(TypeGroup, g.Name)
+	if err != nil {
+		return err
+	}
+	g.Name = name
+
+	if _, exists := m.groups[g.Name]; exists {
+		return fmt.Errorf("group %s already exists", g.Name)
+	}
+
+	stored := g.Clone().(*Group)
+	stored.Type = TypeGroup
+	return m.store(stored)
+}
+
+// UpdateGroup replaces an existing group and rewrites its file.
+func (m *Manager) UpdateGroup(g *Group) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, exists := m.groups[g.Name]
+	if !exists {
+		return fmt.Errorf("group %s not found", g.Name)
+	}
+
+	stored := g.Clone().(*Group)
+	stored.Type = TypeGroup
+	dirty := map[string]bool{m.sources[entityKey{TypeGroup, g.Name}]: true}
+	var dropped []string
+	for _, id := range old.HostIDs {
+		if !stored.HasHost(id) {
+			dropped = append(dropped, id)
+		}
+	}
+	if dropped != nil {
+		m.dropDeclaredGroup(g.Name, dropped, dirty)
+	}
+	m.groups[g.Name] = stored
+	return m.saveFiles(dirty)
+}
+
+// RemoveGroup deletes a group and drops it from every parent group and from the
+// groups declared by hosts.
+func (m *Manager) RemoveGroup(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.groups[name]; !exists {
+		return fmt.Errorf("group %s not found", name)
+	}
+
+	dirty := map[string]bool{}
+	for _, g := range m.groups {
+		if g.HasChildGroup(name) {
+			g.RemoveChildGroup(name)
+			dirty[m.sources[keyOf(g)]] = true
+		}
+	}
+	m.updateCheckRefs(TypeGroup, name, "", dirty)
+	m.dropDeclaredGroup(name, nil, dirty)
+
+	dirty[m.unregister(entityKey{TypeGroup, name})] = true
+	if err := m.saveFiles(dirty); err != nil {
+		return err
+	}
+	return m.removeRunbook(TypeGroup, name)
+}
+
+// ResolveGroupHosts returns the IDs of all hosts in a group, including those of nested child groups,
+// in the order of the group: its own hosts first, then those of its child groups, unless the
+// group sorts them.
+func (m *Manager) ResolveGroupHosts(name string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.resolveGroupHosts(name)
+}
+
+// resolveGroupHosts is ResolveGroupHosts without locking. Caller must hold the lock.
+func (m *Manager) resolveGroupHosts(name string) ([]string, error) {
+	if _, ok := m.groups[name]; !ok {
+		return nil, fmt.Errorf("group %s not found", name)
+	}
+
+	var hostIDs []string
+	seenHosts := map[string]bool{}
+	visited := map[string]bool{}
+
+	var walk func(groupName string)
+	walk = func(groupName string) {
+		if visited[groupName] {
+			return
+		}
+		visited[groupName] = true
+
+		g, ok := m.groups[groupName]
+		if !ok {
+			return
+		}
+		for _, id := range m.orderedMembers(g) {
+			if !seenHosts[id] {
+				seenHosts[id] = true
+				hostIDs = append(hostIDs, id)
+			}
+		}
+		for _, child := range g.ChildGroupNames {
+			walk(child)
+		}
+	}
+	walk(name)
+
+	// Hosts of child groups follow the order of the requested group too.
+	if order := m.groups[name].Order; order != "" && order != GroupOrderManual {
+		hostIDs = m.sortHostIDs(hostIDs, order)
+	}
+	return hostIDs, nil
+}
+
+// HostGroups returns the sorted names of all groups containing a host, directly or
+// through nested child groups.
+func (m *Manager) HostGroups(hostID string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.hostGroups(hostID)
+}
+
+// ResolveVars returns the effective variables of a host: the vars of every group
+// containing it, applied in group name order, overridden by the host's own vars.
+func (m *Manager) ResolveVars(hostID string) (map[string]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	h, ok := m.hosts[hostID]
+	if !ok {
+		return nil, fmt.Errorf("host %s not found", hostID)
+	}
+
+	dynamic, err := m.scriptVars(h)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := m.groupVars(m.hostGroups(hostID))
+	for k, v := range dynamic {
+		vars[k] = v
+	}
+	for k, v := range h.Vars {
+		vars[k] = v
+	}
+	return vars, nil
+}
+
+// groupVars merges the vars of the given groups, later groups overriding earlier
+// ones. Caller must hold the lock.
+func (m *Manager) groupVars(groups []string) map[string]string {
+	vars := make(map[string]string)
+	for _, name := range groups {
+		for k, v := range m.groups[name].Vars {
+			vars[k] = v
+		}
+	}
+	return vars
+}
+
+// staticVars merges the vars of the given groups of a host and its own vars.
+// Caller must hold the lock.
+func (m *Manager) staticVars(h *Host, groups []string) map[string]string {
+	vars := m.groupVars(groups)
+	for k, v := range h.Vars {
+		vars[k] = v
+	}
+	return vars
+}
+
+// hostGroups is HostGroups without locking. Caller must hold the lock.
+func (m *Manager) hostGroups(hostID string) []string {
+	var names []string
+	for _, name := range sortedKeys(m.groups) {
+		ids, _ := m.resolveGroupHosts(name)
+		for _, id := range ids {
+			if id == hostID {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// ===== Credentials =====
+
+// GetCredential returns a copy of the credential with the given ID.
+func (m *Manager) GetCredential(id string) (*Credential, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, ok := m.credentials[id]
+	if !ok {
+		return nil, false
+	}
+	return c.Clone().(*Credential), true
+}
+
+// ListCredentials returns copies of all credentials sorted by ID.
+func (m *Manager) ListCredentials() []*Credential {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	creds := make([]*Credential, 0, len(m.credentials))
+	for _, id := range sortedKeys(m.credentials) {
+		creds = append(creds, m.credentials[id].Clone().(*Credential))
+	}
+	return creds
+}
+
+// AddCredential validates and stores a new credential. The ID policy may rewrite c.ID.
+func (m *Manager) AddCredential(c *Credential) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, err := m.checkNewID(TypeCredential, c.ID)
+	if err != nil {
+		return err
+	}
+	c.ID = id
+
+	if _, exists := m.credentials[c.ID]; exists {
+		return fmt.Errorf("credential %s already exists", c.ID)
+	}
+
+	stored := c.Clone().(*Credential)
+	stored.Type = TypeCredential
+	return m.store(stored)
+}
+
+// UpdateCredential replaces an existing credential and rewrites its file.
+func (m *Manager) UpdateCredential(c *Credential) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.credentials[c.ID]; !exists {
+		return fmt.Errorf("credential %s not found", c.ID)
+	}
+
+	stored := c.Clone().(*Credential)
+	stored.Type = TypeCredential
+	m.credentials[c.ID] = stored
+	return m.saveFile(m.sources[entityKey{TypeCredential, c.ID}])
+}
+
+// RemoveCredential deletes a credential that is no longer referenced by any host.
+func (m *Manager) RemoveCredential(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.credentials[id]; !exists {
+		return fmt.Errorf("credential %s not found", id)
+	}
+	for _, hostID := range sortedKeys(m.hosts) {
+		if m.hosts[hostID].CredentialID == id {
+			return fmt.Errorf("credential %s is still used by host %s", id, hostID)
+		}
+	}
+
+	return m.saveFile(m.unregister(entityKey{TypeCredential, id}))
+}
+
+// ===== Helper Functions =====
+
+// saveFiles rewrites every file in the set in a stable order. Caller must hold the lock.
+func (m *Manager) saveFiles(files map[string]bool) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := m.saveFile(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func keyOf(e Entity) entityKey {
+	switch e.(type) {
+	case *Host:
+		return entityKey{TypeHost, e.GetID()}
+	case *Group:
+		return entityKey{TypeGroup, e.GetID()}
+	case *Credential:
+		return entityKey{TypeCredential, e.GetID()}
+	case *SavedCommand:
+		return entityKey{TypeCommand, e.GetID()}
+	case *Check:
+		return entityKey{TypeCheck, e.GetID()}
+	case *Workflow:
+		return entityKey{TypeWorkflow, e.GetID()}
+	case *Theme:
+		return entityKey{TypeTheme, e.GetID()}
+	case *SessionSet:
+		return entityKey{TypeSessionSet, e.GetID()}
+	}
+	return entityKey{ID: e.GetID()}
+}
+
+// defaultFilename returns the file a newly created entity is stored in, e.g. "host-web1.yaml".
+func defaultFilename(key entityKey) string {
+	return fmt.Sprintf("%s-%s.yaml", key.Type, key.ID)
+}
+
+func isYAMLFile(filename string) bool {
+	ext := filepath.Ext(filename)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
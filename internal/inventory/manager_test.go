@@ -0,0 +1,277 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestManager(t *testing.T) (*Manager, string) {
+	tmpDir := t.TempDir()
+	return NewManager(tmpDir), tmpDir
+}
+
+func writeTestFile(t *testing.T, dir, name, content string) {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
+
+func TestManagerLoad(t *testing.T) {
+	t.Run("load multi-document file", func(t *testing.T) {
+		m, dir := setupTestManager(t)
+		writeTestFile(t, dir, "web.yaml", `type: group
+name: web
+host_ids: [web1]
+---
+type: host
+id: web1
+name: web1
+address: 10.0.0.1
+port: 22
+user: root
+`)
+		writeTestFile(t, dir, "config.yaml", "type: config\ntheme: dark\n")
+
+		require.NoError(t, m.Load())
+
+		h, ok := m.GetHost("web1")
+		require.True(t, ok)
+		assert.Equal(t, "10.0.0.1", h.Address)
+
+		g, ok := m.GetGroup("web")
+		require.True(t, ok)
+		assert.Equal(t, []string{"web1"}, g.HostIDs)
+	})
+
+	t.Run("duplicate IDs fail", func(t *testing.T) {
+		m, dir := setupTestManager(t)
+		host := "type: host\nid: a\nname: a\naddress: x\nport: 22\nuser: u\n"
+		writeTestFile(t, dir, "a.yaml", host)
+		writeTestFile(t, dir, "b.yaml", host)
+
+		err := m.Load()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "duplicate")
+	})
+
+	t.Run("unknown type fails", func(t *testing.T) {
+		m, dir := setupTestManager(t)
+		writeTestFile(t, dir, "x.yaml", "type: unknown\n")
+
+		assert.Error(t, m.Load())
+	})
+
+	t.Run("missing directory is empty inventory", func(t *testing.T) {
+		m := NewManager(filepath.Join(t.TempDir(), "missing"))
+		require.NoError(t, m.Load())
+		assert.Empty(t, m.ListHosts())
+	})
+}
+
+func TestSplitYAMLDocuments(t *testing.T) {
+	docs, err := splitYAMLDocuments([]byte("a: 1\n---\nb: 2\n---\n\n"))
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	assert.Equal(t, "a: 1\n", string(docs[0]))
+	assert.Equal(t, "b: 2\n", string(docs[1]))
+
+	t.Run("separators inside scalars", func(t *testing.T) {
+		docs, err := splitYAMLDocumentLines([]byte("type: host\nnotes: |\n  before\n  ---\n  after\n---\ntype: group\n"))
+		require.NoError(t, err)
+		require.Len(t, docs, 2)
+		assert.Equal(t, "type: host\nnotes: |\n  before\n  ---\n  after\n", string(docs[0].data))
+		assert.Equal(t, 7, docs[1].line)
+	})
+
+	t.Run("markers with comments and content", func(t *testing.T) {
+		docs, err := splitYAMLDocumentLines([]byte("# hosts\n--- # first\na: 1\n...\n--- {b: 2}\n---\n---\n"))
+		require.NoError(t, err)
+		require.Len(t, docs, 2)
+		assert.Equal(t, "# hosts\n", string(docs[0].data[:8]))
+		assert.Equal(t, 1, docs[0].line)
+		assert.Equal(t, "--- {b: 2}\n", string(docs[1].data))
+		assert.Equal(t, 5, docs[1].line)
+	})
+
+	t.Run("comments only", func(t *testing.T) {
+		docs, err := splitYAMLDocumentLines([]byte("# nothing here\n"))
+		require.NoError(t, err)
+		assert.Empty(t, docs)
+	})
+
+	t.Run("syntax errors", func(t *testing.T) {
+		_, err := splitYAMLDocumentLines([]byte("a: 1\n---\nb: \"x\n---\ny\"\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestManagerCRUD(t *testing.T) {
+	m, dir := setupTestManager(t)
+
+	cred := NewCredential("admin", "Admin", "root")
+	cred.KeyPath = "~/.ssh/id_ed25519"
+	require.NoError(t, m.AddCredential(cred))
+
+	host := NewHostWithCredential("web1", "web1", "10.0.0.1", "admin")
+	require.NoError(t, m.AddHost(host))
+	assert.FileExists(t, filepath.Join(dir, "host-web1.yaml"))
+
+	t.Run("add duplicate host fails", func(t *testing.T) {
+		assert.Error(t, m.AddHost(host))
+	})
+
+	t.Run("add host with unknown credential fails", func(t *testing.T) {
+		assert.Error(t, m.AddHost(NewHostWithCredential("web2", "web2", "10.0.0.2", "missing")))
+	})
+
+	t.Run("update host", func(t *testing.T) {
+		h, _ := m.GetHost("web1")
+		h.Address = "10.0.0.9"
+		require.NoError(t, m.UpdateHost(h))
+
+		reloaded := NewManager(dir)
+		require.NoError(t, reloaded.Load())
+		got, ok := reloaded.GetHost("web1")
+		require.True(t, ok)
+		assert.Equal(t, "10.0.0.9", got.Address)
+	})
+
+	t.Run("credential in use cannot be removed", func(t *testing.T) {
+		assert.Error(t, m.RemoveCredential("admin"))
+	})
+
+	t.Run("remove host updates groups", func(t *testing.T) {
+		g := NewGroup("web")
+		g.AddHost("web1")
+		require.NoError(t, m.AddGroup(g))
+
+		require.NoError(t, m.RemoveHost("web1"))
+		assert.NoFileExists(t, filepath.Join(dir, "host-web1.yaml"))
+
+		got, _ := m.GetGroup("web")
+		assert.Empty(t, got.HostIDs)
+	})
+
+	t.Run("returned entities are copies", func(t *testing.T) {
+		c, _ := m.GetCredential("admin")
+		c.User = "changed"
+		again, _ := m.GetCredential("admin")
+		assert.Equal(t, "root", again.User)
+	})
+}
+
+func TestResolveGroupHosts(t *testing.T) {
+	m, _ := setupTestManager(t)
+
+	parent := NewGroup("all")
+	parent.AddHost("a")
+	parent.AddChildGroup("child")
+	child := NewGroup("child")
+	child.AddHost("b")
+	child.AddHost("a")
+	child.AddChildGroup("all") // cycle
+
+	require.NoError(t, m.AddGroup(parent))
+	require.NoError(t, m.AddGroup(child))
+
+	ids, err := m.ResolveGroupHosts("all")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, ids)
+
+	_, err = m.ResolveGroupHosts("missing")
+	assert.Error(t, err)
+}
+
+func TestResolveVars(t *testing.T) {
+	m, _ := setupTestManager(t)
+
+	h := NewHost("web1", "web1", "10.0.0.1")
+	h.User = "root"
+	h.SetVar("port", "8443")
+	require.NoError(t, m.AddHost(h))
+
+	all := NewGroup("all")
+	all.SetVar("env", "staging")
+	all.SetVar("port", "80")
+	all.AddChildGroup("web")
+	web := NewGroup("web")
+	web.SetVar("env", "prod")
+	web.AddHost("web1")
+	require.NoError(t, m.AddGroup(all))
+	require.NoError(t, m.AddGroup(web))
+
+	assert.Equal(t, []string{"all", "web"}, m.HostGroups("web1"))
+
+	vars, err := m.ResolveVars("web1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod", "port": "8443"}, vars)
+
+	_, err = m.ResolveVars("missing")
+	assert.Error(t, err)
+}
+
+func TestStats(t *testing.T) {
+	m, _ := setupTestManager(t)
+	now := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	key := NewCredential("key", "Key", "deploy")
+	key.KeyPath = "/keys/deploy"
+	pass := NewCredential("pass", "Pass", "admin")
+	pass.Password = "secret"
+	require.NoError(t, m.AddCredential(key))
+	require.NoError(t, m.AddCredential(pass))
+
+	web1 := NewHostWithCredential("web1", "web1", "10.0.0.1", "key")
+	web1.AddTag("prod")
+	web1.LastConnected = now.Add(-time.Hour)
+	web1.FactsUpdated = now.Add(-time.Hour)
+	web2 := NewHostWithCredential("web2", "web2", "10.0.0.2", "key")
+	web2.AddTag("prod")
+	web2.FactsUpdated = now.Add(-60 * 24 * time.Hour)
+	db := NewHost("db", "db", "10.0.1.1")
+	db.User = "root"
+	db.Password = "inline"
+
+	for _, h := range []*Host{web1, web2, db} {
+		require.NoError(t, m.AddHost(h))
+	}
+
+	g := NewGroup("web")
+	g.AddHost("web1")
+	g.AddHost("web2")
+	require.NoError(t, m.AddGroup(g))
+
+	s := m.StatsWithOptions(StatsOptions{Now: now})
+
+	assert.Equal(t, 3, s.TotalHosts)
+	assert.Equal(t, 2, s.HostsPerGroup["web"])
+	assert.Equal(t, 2, s.HostsPerTag["prod"])
+	assert.Equal(t, 2, s.HostsPerCredential["key"])
+	assert.Equal(t, 2, s.AuthMethods[AuthMethodCredentialKey])
+	assert.Equal(t, 1, s.AuthMethods[AuthMethodInlinePassword])
+	assert.Equal(t, []string{"db"}, s.UngroupedHosts)
+	assert.Equal(t, []string{"db", "web2"}, s.NeverConnected)
+	assert.Equal(t, []string{"db", "web2"}, s.StaleFacts)
+	assert.Equal(t, []string{"pass"}, s.UnusedCredentials)
+
+	t.Run("render JSON", func(t *testing.T) {
+		data, err := s.JSON()
+		require.NoError(t, err)
+
+		var decoded map[string]any
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.EqualValues(t, 3, decoded["total_hosts"])
+	})
+
+	t.Run("render table", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, s.WriteTable(&buf))
+		assert.Contains(t, buf.String(), "credential/key")
+		assert.Contains(t, buf.String(), "Never connected (2)")
+	})
+}
@@ -0,0 +1,85 @@
+package inventory
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+)
+
+// NetboxConfig connects the inventory to a Netbox instance (see package netbox).
+// Devices and virtual machines with a primary IP are imported as hosts; the status
+// and facts of imported hosts can be written back to custom fields.
+type NetboxConfig struct {
+	// URL is the base URL of the instance, e.g. https://netbox.example.com.
+	URL string `yaml:"url,omitempty"`
+	// Token authenticates API requests. TokenEnv names an environment variable
+	// holding the token instead, keeping it out of the config file.
+	Token    string `yaml:"token,omitempty"`
+	TokenEnv string `yaml:"token_env,omitempty"`
+
+	// User is the login of imported hosts.
+	User string `yaml:"user,omitempty"`
+	// Filters are query parameters narrowing the device and VM lists, e.g.
+	// site: ams1 or tag: managed.
+	Filters map[string]string `yaml:"filters,omitempty"`
+	// SkipDevices and SkipVirtualMachines leave out one kind of object.
+	SkipDevices         bool `yaml:"skip_devices,omitempty"`
+	SkipVirtualMachines bool `yaml:"skip_virtual_machines,omitempty"`
+
+	// SyncInterval syncs periodically in the background; zero syncs on demand only.
+	SyncInterval time.Duration `yaml:"sync_interval,omitempty"`
+
+	// StatusField names the custom field receiving the host status on push.
+	StatusField string `yaml:"status_field,omitempty"`
+	// FactFields maps fact names to the custom fields receiving them on push.
+	FactFields map[string]string `yaml:"fact_fields,omitempty"`
+}
+
+// Enabled reports whether an instance is configured.
+func (c NetboxConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+// Validate checks the URL and intervals of an enabled configuration.
+func (c NetboxConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid netbox url %q", c.URL)
+	}
+	if c.SkipDevices && c.SkipVirtualMachines {
+		return fmt.Errorf("netbox: both devices and virtual machines are skipped")
+	}
+	if c.SyncInterval < 0 {
+		return fmt.Errorf("invalid netbox sync interval: %s", c.SyncInterval)
+	}
+	return nil
+}
+
+// APIToken returns the token, read from TokenEnv when it is set.
+func (c NetboxConfig) APIToken() string {
+	if c.TokenEnv != "" {
+		return os.Getenv(c.TokenEnv)
+	}
+	return c.Token
+}
+
+func (c NetboxConfig) clone() NetboxConfig {
+	clone := c
+	if c.Filters != nil {
+		clone.Filters = make(map[string]string, len(c.Filters))
+		for k, v := range c.Filters {
+			clone.Filters[k] = v
+		}
+	}
+	if c.FactFields != nil {
+		clone.FactFields = make(map[string]string, len(c.FactFields))
+		for k, v := range c.FactFields {
+			clone.FactFields[k] = v
+		}
+	}
+	return clone
+}
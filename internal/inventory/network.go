@@ -0,0 +1,112 @@
+package inventory
+
+import (
+	"fmt"
+	"net"
+	"slices"
+)
+
+// NetworkRequirement describes a network, such as a VPN, that hosts are only
+// reachable through. Hosts and groups list the networks they need in
+// requires_network; before connecting, the probe and the interface of each are
+// checked (see transport.NetworkChecker) and a failure reports Message.
+type NetworkRequirement struct {
+	// Probe is an address:port that accepts TCP connections while the network is up.
+	Probe string `yaml:"probe,omitempty"`
+	// Interface names a network interface that is up while the network is, e.g. "tun0".
+	Interface string `yaml:"interface,omitempty"`
+	// Message tells the user what to do when the network is down, e.g.
+	// "connect to corp VPN first".
+	Message string `yaml:"message,omitempty"`
+	// Up is an optional shell command that brings the network up. It runs when a
+	// check fails, after which the network is checked again.
+	Up string `yaml:"up,omitempty"`
+}
+
+// Validate checks that the requirement has a valid probe or an interface.
+func (n NetworkRequirement) Validate() error {
+	if n.Probe == "" && n.Interface == "" {
+		return fmt.Errorf("network needs a probe or an interface")
+	}
+	if n.Probe != "" {
+		if _, _, err := net.SplitHostPort(n.Probe); err != nil {
+			return fmt.Errorf("invalid network probe %q: %w", n.Probe, err)
+		}
+	}
+	return nil
+}
+
+// RequiredNetwork is a named requirement of a resolved connection.
+type RequiredNetwork struct {
+	Name string
+	NetworkRequirement
+	// Undefined is set when no network of that name is configured.
+	Undefined bool
+}
+
+// validateNetworks validates a map of network definitions.
+func validateNetworks(networks map[string]NetworkRequirement) error {
+	for _, name := range sortedKeys(networks) {
+		if name == "" {
+			return fmt.Errorf("network name cannot be empty")
+		}
+		if err := networks[name].Validate(); err != nil {
+			return fmt.Errorf("network %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ===== Manager integration =====
+
+// SetNetworks sets the network definitions that requires_network refers to,
+// usually GetNetworks().
+func (m *Manager) SetNetworks(networks map[string]NetworkRequirement) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.networks = make(map[string]NetworkRequirement, len(networks))
+	for name, n := range networks {
+		m.networks[name] = n
+	}
+}
+
+// RequiredNetworks returns the names of the networks a host requires, its own and
+// those of the groups containing it, directly or through child groups, sorted.
+func (m *Manager) RequiredNetworks(hostID string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.requiredNetworks(hostID)
+}
+
+// requiredNetworks implements RequiredNetworks. Caller must hold the lock.
+func (m *Manager) requiredNetworks(hostID string) []string {
+	h, ok := m.hosts[hostID]
+	if !ok {
+		return nil
+	}
+
+	names := append([]string(nil), h.RequiresNetwork...)
+	for _, name := range sortedKeys(m.groups) {
+		g := m.groups[name]
+		if len(g.RequiresNetwork) == 0 {
+			continue
+		}
+		ids, err := m.resolveGroupHosts(name)
+		if err == nil && slices.Contains(ids, hostID) {
+			names = append(names, g.RequiresNetwork...)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// resolveNetworks returns the requirements of a host. Caller must hold the lock.
+func (m *Manager) resolveNetworks(hostID string) []RequiredNetwork {
+	var networks []RequiredNetwork
+	for _, name := range m.requiredNetworks(hostID) {
+		n, ok := m.networks[name]
+		networks = append(networks, RequiredNetwork{Name: name, NetworkRequirement: n, Undefined: !ok})
+	}
+	return networks
+}
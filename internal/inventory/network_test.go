@@ -0,0 +1,71 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequiredNetworks(t *testing.T) {
+	m, dir := setupTestManager(t)
+	writeTestFile(t, dir, "hosts.yaml", `type: host
+id: web01
+name: web01
+address: 10.1.0.5
+user: deploy
+requires_network: [corp]
+---
+type: host
+id: db01
+name: db01
+address: 10.2.0.5
+user: deploy
+jump_host_id: web01
+---
+type: host
+id: lab01
+name: lab01
+address: 192.168.1.5
+user: deploy
+---
+type: group
+name: data
+host_ids: []
+child_groups: [databases]
+requires_network: [datacenter, corp]
+---
+type: group
+name: databases
+host_ids: [db01]
+`)
+	require.NoError(t, m.Load())
+	m.SetNetworks(map[string]NetworkRequirement{
+		"corp": {Probe: "10.1.0.1:443", Message: "connect to corp VPN first"},
+	})
+
+	t.Run("collects the networks of the host and its groups", func(t *testing.T) {
+		assert.Equal(t, []string{"corp"}, m.RequiredNetworks("web01"))
+		assert.Equal(t, []string{"corp", "datacenter"}, m.RequiredNetworks("db01"))
+		assert.Empty(t, m.RequiredNetworks("lab01"))
+	})
+
+	t.Run("resolves the definitions with the connection", func(t *testing.T) {
+		c, err := m.ResolveConnection("db01")
+		require.NoError(t, err)
+		require.Len(t, c.Networks, 2)
+		assert.Equal(t, "corp", c.Networks[0].Name)
+		assert.Equal(t, "connect to corp VPN first", c.Networks[0].Message)
+		assert.True(t, c.Networks[1].Undefined)
+
+		require.Len(t, c.Jumps, 1)
+		assert.Equal(t, []RequiredNetwork{{Name: "corp", NetworkRequirement: NetworkRequirement{Probe: "10.1.0.1:443", Message: "connect to corp VPN first"}}}, c.Jumps[0].Networks)
+	})
+}
+
+func TestNetworkRequirementValidate(t *testing.T) {
+	assert.NoError(t, NetworkRequirement{Probe: "10.1.0.1:443"}.Validate())
+	assert.NoError(t, NetworkRequirement{Interface: "tun0"}.Validate())
+	assert.Error(t, NetworkRequirement{Message: "connect first"}.Validate())
+	assert.Error(t, NetworkRequirement{Probe: "10.1.0.1"}.Validate())
+}
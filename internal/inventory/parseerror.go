@@ -0,0 +1,128 @@
+package inventory
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// snippetContext is the number of lines shown before and after the offending line.
+const snippetContext = 1
+
+// ParseError locates a YAML problem in a file so hand-edited files can be fixed
+// quickly. Line and Column are 1-based; zero means unknown.
+type ParseError struct {
+	Path    string
+	Line    int
+	Column  int
+	Msg     string
+	Snippet string
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Path)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ":%d", e.Line)
+		if e.Column > 0 {
+			fmt.Fprintf(&b, ":%d", e.Column)
+		}
+	}
+	b.WriteString(": ")
+	b.WriteString(e.Msg)
+	if e.Snippet != "" {
+		b.WriteString("\n")
+		b.WriteString(e.Snippet)
+	}
+	return b.String()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	// errorLinePattern matches the "line N: message" part of yaml.v3 errors.
+	errorLinePattern = regexp.MustCompile(`line (\d+): ([^\n]*)`)
+	// unmarshalValuePattern extracts the offending value of yaml.v3 type errors.
+	unmarshalValuePattern = regexp.MustCompile("cannot unmarshal !!\\w+ `([^`]*)`")
+)
+
+// NewParseError wraps a YAML decoding error of data read from path. lineOffset is
+// the line the decoded document starts at within the file, minus one, for errors
+// of documents split out of multi-document files. Errors without a position are
+// still wrapped so they carry the path.
+func NewParseError(path string, data []byte, lineOffset int, err error) error {
+	if err == nil {
+		return nil
+	}
+	lines := strings.Split(string(data), "\n")
+	pe := &ParseError{Path: path, Msg: err.Error(), Err: err}
+
+	var unknown *UnknownFieldsError
+	if errors.As(err, &unknown) && len(unknown.Fields) > 0 {
+		for i := range unknown.Fields {
+			unknown.Fields[i].Line += lineOffset
+		}
+		first := unknown.Fields[0]
+		pe.Line = first.Line
+		pe.Msg = unknown.Error()
+		pe.Column = columnOf(lines, first.Line, first.Field)
+	} else if m := errorLinePattern.FindStringSubmatch(err.Error()); m != nil {
+		fmt.Sscan(m[1], &pe.Line)
+		pe.Line += lineOffset
+		pe.Msg = m[2]
+		if v := unmarshalValuePattern.FindStringSubmatch(m[2]); v != nil {
+			pe.Column = columnOf(lines, pe.Line, v[1])
+		} else {
+			pe.Column = columnOf(lines, pe.Line, "")
+		}
+	}
+
+	if pe.Line > 0 && pe.Line <= len(lines) {
+		pe.Snippet = snippet(lines, pe.Line, pe.Column)
+	}
+	return pe
+}
+
+// columnOf returns the 1-based column of text on a line, or of its first
+// non-blank character when text is empty or not found.
+func columnOf(lines []string, line int, text string) int {
+	if line < 1 || line > len(lines) {
+		return 0
+	}
+	l := lines[line-1]
+	if text != "" {
+		if i := strings.Index(l, text); i >= 0 {
+			return i + 1
+		}
+	}
+	return len(l) - len(strings.TrimLeft(l, " \t")) + 1
+}
+
+// snippet renders the lines around line with a marker and a caret under column.
+func snippet(lines []string, line, column int) string {
+	first := max(line-snippetContext, 1)
+	last := min(line+snippetContext, len(lines))
+	// A trailing newline leaves an empty last element that is not a real line
+	if last == len(lines) && last > line && lines[last-1] == "" {
+		last--
+	}
+	width := len(fmt.Sprint(last))
+
+	var b bytes.Buffer
+	for n := first; n <= last; n++ {
+		marker := " "
+		if n == line {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%s %*d | %s\n", marker, width, n, strings.TrimRight(lines[n-1], "\r"))
+		if n == line && column > 0 {
+			fmt.Fprintf(&b, "  %*s | %s^\n", width, "", strings.Repeat(" ", column-1))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
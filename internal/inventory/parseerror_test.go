@@ -0,0 +1,58 @@
+package inventory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseError(t *testing.T) {
+	t.Run("syntax error with snippet", func(t *testing.T) {
+		m, dir := setupTestManager(t)
+		writeTestFile(t, dir, "web.yaml", "type: host\nid: web1\naddress: : 10.0.0.1\nport: 22\n")
+
+		err := m.Load()
+		var pe *ParseError
+		require.True(t, errors.As(err, &pe))
+		assert.Equal(t, 3, pe.Line)
+		assert.Equal(t, 1, pe.Column)
+		assert.Equal(t, "mapping values are not allowed in this context", pe.Msg)
+		assert.Equal(t, "  2 | id: web1\n> 3 | address: : 10.0.0.1\n    | ^\n  4 | port: 22", pe.Snippet)
+		assert.Contains(t, err.Error(), "web.yaml:3:1: mapping values")
+	})
+
+	t.Run("type error in later document", func(t *testing.T) {
+		m, dir := setupTestManager(t)
+		writeTestFile(t, dir, "hosts.yaml", "type: host\nid: web1\nname: web1\n---\ntype: host\nid: web2\nport: twenty\n")
+
+		err := m.Load()
+		var pe *ParseError
+		require.True(t, errors.As(err, &pe))
+		assert.Equal(t, 7, pe.Line)
+		assert.Equal(t, 7, pe.Column, "points at the value")
+		assert.Contains(t, pe.Snippet, "> 7 | port: twenty")
+	})
+
+	t.Run("unknown fields in strict mode", func(t *testing.T) {
+		m, dir := setupTestManager(t)
+		m.SetStrictDecoding(true)
+		writeTestFile(t, dir, "hosts.yaml", "type: group\nname: web\n---\ntype: host\nid: web1\n  \nadress: x\n")
+
+		err := m.Load()
+		var pe *ParseError
+		require.True(t, errors.As(err, &pe))
+		assert.Equal(t, 7, pe.Line)
+		assert.Equal(t, "unknown fields: adress (line 7)", pe.Msg)
+
+		var unknown *UnknownFieldsError
+		require.True(t, errors.As(err, &unknown))
+		assert.Equal(t, 7, unknown.Fields[0].Line)
+	})
+
+	t.Run("errors without position keep the path", func(t *testing.T) {
+		err := NewParseError("x.yaml", nil, 0, errors.New("boom"))
+		assert.Equal(t, "x.yaml: boom", err.Error())
+	})
+}
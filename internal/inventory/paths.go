@@ -0,0 +1,90 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExpandPath expands a leading "~" to the user's home directory (%USERPROFILE% on
+// Windows) and environment variable references written as $VAR, ${VAR} or %VAR%.
+// References to unset variables are left as written.
+func ExpandPath(path string) string {
+	return expandPath(path, os.LookupEnv, os.UserHomeDir)
+}
+
+// resolvePath expands a path from the inventory and resolves it against dataDir when
+// it is relative.
+func resolvePath(p, dataDir string) string {
+	if p == "" {
+		return ""
+	}
+	p = ExpandPath(p)
+	if !filepath.IsAbs(p) && dataDir != "" {
+		p = filepath.Join(dataDir, filepath.FromSlash(p))
+	}
+	return p
+}
+
+// expandPath implements ExpandPath with injectable lookups.
+func expandPath(path string, lookup func(string) (string, bool), home func() (string, error)) string {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		if dir, err := home(); err == nil {
+			// Both separators are accepted after "~" so paths stay portable.
+			rest := strings.TrimLeft(path[1:], `/\`)
+			path = filepath.Join(dir, filepath.FromSlash(strings.ReplaceAll(rest, `\`, "/")))
+		}
+	}
+	return expandEnv(path, lookup)
+}
+
+// expandEnv replaces $VAR, ${VAR} and %VAR% with the values of set variables.
+func expandEnv(s string, lookup func(string) (string, bool)) string {
+	if !strings.ContainsAny(s, "$%") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		name, width := "", 0
+		switch s[i] {
+		case '$':
+			if i+1 < len(s) && s[i+1] == '{' {
+				if end := strings.IndexByte(s[i+2:], '}'); end > 0 {
+					name, width = s[i+2:i+2+end], end+3
+				}
+			} else {
+				n := envNameLen(s[i+1:])
+				name, width = s[i+1:i+1+n], n+1
+			}
+		case '%':
+			if end := strings.IndexByte(s[i+1:], '%'); end > 0 && envNameLen(s[i+1:i+1+end]) == end {
+				name, width = s[i+1:i+1+end], end+2
+			}
+		}
+
+		if name != "" {
+			if value, ok := lookup(name); ok {
+				b.WriteString(value)
+				i += width
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	return b.String()
+}
+
+// envNameLen returns the length of the variable name at the start of s.
+func envNameLen(s string) int {
+	n := 0
+	for n < len(s) {
+		c := s[n]
+		if c != '_' && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(n > 0 && c >= '0' && c <= '9') {
+			break
+		}
+		n++
+	}
+	return n
+}
@@ -0,0 +1,53 @@
+package inventory
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandPath(t *testing.T) {
+	env := map[string]string{"USERPROFILE": `C:\Users\kim`, "KEYS": "/srv/keys", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	home := func() (string, error) { return "/home/kim", nil }
+
+	cases := map[string]string{
+		"~":                         "/home/kim",
+		"~/.ssh/id_ed25519":         filepath.Join("/home/kim", ".ssh", "id_ed25519"),
+		`~\.ssh\id_ed25519`:         filepath.Join("/home/kim", ".ssh", "id_ed25519"),
+		"$KEYS/deploy":              "/srv/keys/deploy",
+		"${KEYS}_old/deploy":        "/srv/keys_old/deploy",
+		`%USERPROFILE%\.ssh\id_rsa`: `C:\Users\kim\.ssh\id_rsa`,
+		"$EMPTY/id":                 "/id",
+		"$UNSET/id":                 "$UNSET/id",
+		"%UNSET%/id":                "%UNSET%/id",
+		"100%/$":                    "100%/$",
+		"/etc/ssh/ssh_host_rsa_key": "/etc/ssh/ssh_host_rsa_key",
+		"keys/~deploy":              "keys/~deploy",
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, expandPath(in, lookup, home), in)
+	}
+
+	t.Run("unknown home keeps the tilde", func(t *testing.T) {
+		noHome := func() (string, error) { return "", errors.New("no home") }
+		assert.Equal(t, "~/.ssh/id", expandPath("~/.ssh/id", lookup, noHome))
+	})
+
+	t.Run("key paths", func(t *testing.T) {
+		t.Setenv("GOSSHER_TEST_KEYS", "/opt/keys")
+		h := NewHost("web1", "web1", "10.0.0.1")
+		h.KeyPath = "$GOSSHER_TEST_KEYS/web1"
+		assert.Equal(t, "/opt/keys/web1", h.ExpandedKeyPath())
+
+		c := NewCredential("deploy", "Deploy", "deploy")
+		c.KeyPath = "%GOSSHER_TEST_KEYS%/deploy"
+		assert.Equal(t, "/opt/keys/deploy", c.ExpandedKeyPath())
+		assert.Equal(t, "%GOSSHER_TEST_KEYS%/deploy", c.KeyPath, "the stored path is unchanged")
+	})
+}
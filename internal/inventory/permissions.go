@@ -0,0 +1,189 @@
+package inventory
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+)
+
+// Default permissions of the data directory and the files in it.
+const (
+	DefaultDirMode    os.FileMode = 0700
+	DefaultFileMode   os.FileMode = 0644
+	DefaultSecretMode os.FileMode = 0600
+)
+
+// FilePermissions configures the modes used for the data directory and its files,
+// written as octal strings such as "0600". Empty fields use the defaults.
+type FilePermissions struct {
+	// Dir applies to the data directory and its subdirectories.
+	Dir string `yaml:"dir,omitempty"`
+	// File applies to files without secrets (hosts, groups, commands).
+	File string `yaml:"file,omitempty"`
+	// Secret applies to files holding credentials, inline passwords or encrypted content.
+	Secret string `yaml:"secret,omitempty"`
+}
+
+// Validate checks that every mode is a valid octal permission.
+func (p FilePermissions) Validate() error {
+	for name, s := range map[string]string{"dir": p.Dir, "file": p.File, "secret": p.Secret} {
+		if _, err := parseMode(s, 0); err != nil {
+			return fmt.Errorf("invalid %s permissions: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// DirMode returns the mode for directories.
+func (p FilePermissions) DirMode() os.FileMode {
+	m, _ := parseMode(p.Dir, DefaultDirMode)
+	return m
+}
+
+// FileMode returns the mode for files without secrets.
+func (p FilePermissions) FileMode() os.FileMode {
+	m, _ := parseMode(p.File, DefaultFileMode)
+	return m
+}
+
+// SecretMode returns the mode for files holding secrets.
+func (p FilePermissions) SecretMode() os.FileMode {
+	m, _ := parseMode(p.Secret, DefaultSecretMode)
+	return m
+}
+
+// ModeFor returns the mode for a file holding the given values: SecretMode when
+// any of them holds a secret, FileMode otherwise.
+func (p FilePermissions) ModeFor(values ...any) os.FileMode {
+	for _, v := range values {
+		if holdsSecret(v) {
+			return p.SecretMode()
+		}
+	}
+	return p.FileMode()
+}
+
+// parseMode parses an octal mode, returning def for an empty string.
+func parseMode(s string, def os.FileMode) (os.FileMode, error) {
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || n > 0777 {
+		return def, fmt.Errorf("%q is not an octal mode", s)
+	}
+	return os.FileMode(n), nil
+}
+
+// holdsSecret reports whether a value carries secrets worth protecting on disk.
+func holdsSecret(v any) bool {
+	switch e := v.(type) {
+	case *Credential:
+		return true
+	case *Host:
+		return e.Password != ""
+	}
+	return false
+}
+
+// PermissionIssue reports a file or directory that grants more access than configured.
+type PermissionIssue struct {
+	Path string
+	Mode os.FileMode
+	Want os.FileMode
+}
+
+// String renders the issue as "path: mode 0644, want 0600".
+func (i PermissionIssue) String() string {
+	return fmt.Sprintf("%s: mode %#o, want %#o", i.Path, i.Mode, i.Want)
+}
+
+// ===== Manager integration =====
+
+// SetPermissions sets the modes used when writing files and checking permissions.
+func (m *Manager) SetPermissions(p FilePermissions) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.perms = p
+	return nil
+}
+
+// CheckPermissions reports the data directory, its subdirectories and files that
+// grant access beyond the configured modes, e.g. a credential file that is group or
+// world readable. Nothing is changed.
+func (m *Manager) CheckPermissions() ([]PermissionIssue, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.walkPermissions(false)
+}
+
+// Harden restricts every directory and file in the data directory to the configured
+// modes and returns what it changed. Files that grant less access are left alone.
+func (m *Manager) Harden() ([]PermissionIssue, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.walkPermissions(true)
+}
+
+// walkPermissions checks the data directory tree and optionally fixes it. Windows
+// has no group or world bits (access is governed by ACLs), so nothing is reported there.
+// Caller must hold the lock.
+func (m *Manager) walkPermissions(fix bool) ([]PermissionIssue, error) {
+	if runtime.GOOS == "windows" {
+		return nil, nil
+	}
+
+	var issues []PermissionIssue
+
+	err := filepath.WalkDir(m.dataDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == m.dataDir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		want := m.perms.DirMode()
+		if !d.IsDir() {
+			rel, err := filepath.Rel(m.dataDir, path)
+			if err != nil {
+				return err
+			}
+			want = m.fileMode(filepath.ToSlash(rel))
+		}
+
+		mode := info.Mode().Perm()
+		if mode&^want == 0 {
+			return nil
+		}
+		if fix {
+			if err := os.Chmod(path, mode&want); err != nil {
+				return fmt.Errorf("failed to change permissions of %s: %w", path, err)
+			}
+		}
+		issues = append(issues, PermissionIssue{Path: path, Mode: mode, Want: want})
+		return nil
+	})
+	if err != nil {
+		return issues, err
+	}
+
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Path < issues[j].Path })
+	return issues, nil
+}
@@ -0,0 +1,91 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fileMode(t *testing.T, path string) os.FileMode {
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	return info.Mode().Perm()
+}
+
+func TestFilePermissions(t *testing.T) {
+	var p FilePermissions
+	assert.Equal(t, DefaultDirMode, p.DirMode())
+	assert.Equal(t, DefaultSecretMode, p.ModeFor(NewHost("h", "h", "10.0.0.1"), NewCredential("c", "c", "root")))
+	assert.Equal(t, DefaultFileMode, p.ModeFor(NewHost("h", "h", "10.0.0.1")))
+
+	p = FilePermissions{File: "0640", Secret: "400"}
+	require.NoError(t, p.Validate())
+	assert.Equal(t, os.FileMode(0640), p.FileMode())
+	assert.Equal(t, os.FileMode(0400), p.SecretMode())
+
+	assert.Error(t, FilePermissions{Dir: "0800"}.Validate())
+	assert.Error(t, FilePermissions{File: "rw-r--r--"}.Validate())
+	assert.Error(t, FilePermissions{Secret: "01777"}.Validate())
+}
+
+func TestManagerPermissions(t *testing.T) {
+	cred := NewCredential("deploy", "Deploy", "deploy")
+	cred.Password = "secret"
+
+	t.Run("credentials are written private", func(t *testing.T) {
+		m, dir := setupTestManager(t)
+		require.NoError(t, m.AddCredential(cred))
+		require.NoError(t, m.AddHost(NewHostWithCredential("web1", "web1", "10.0.0.1", "deploy")))
+
+		assert.Equal(t, DefaultSecretMode, fileMode(t, filepath.Join(dir, "credential-deploy.yaml")))
+		assert.Equal(t, DefaultFileMode, fileMode(t, filepath.Join(dir, "host-web1.yaml")))
+
+		h, _ := m.GetHost("web1")
+		h.Password = "hunter2"
+		require.NoError(t, m.UpdateHost(h))
+		assert.Equal(t, DefaultSecretMode, fileMode(t, filepath.Join(dir, "host-web1.yaml")),
+			"existing files are tightened when they gain a secret")
+	})
+
+	t.Run("configured modes", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "data")
+		m := NewManager(dir)
+		require.NoError(t, m.SetPermissions(FilePermissions{Dir: "0750", Secret: "0400"}))
+		require.NoError(t, m.AddCredential(cred))
+
+		assert.Equal(t, os.FileMode(0750), fileMode(t, dir))
+		assert.Equal(t, os.FileMode(0400), fileMode(t, filepath.Join(dir, "credential-deploy.yaml")))
+		assert.Error(t, m.SetPermissions(FilePermissions{Dir: "x"}))
+	})
+
+	t.Run("check and harden", func(t *testing.T) {
+		m, dir := setupTestManager(t)
+		require.NoError(t, os.Chmod(dir, 0755))
+		writeTestFile(t, dir, "creds.yaml", "type: credential\nid: deploy\nname: Deploy\nuser: deploy\npassword: secret\n")
+		writeTestFile(t, dir, "web1.yaml", "type: host\nid: web1\nname: web1\naddress: 10.0.0.1\nuser: deploy\n")
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "keys"), 0777))
+		require.NoError(t, m.Load())
+
+		issues, err := m.CheckPermissions()
+		require.NoError(t, err)
+		require.Len(t, issues, 3)
+		assert.Equal(t, PermissionIssue{Path: filepath.Join(dir, "creds.yaml"), Mode: 0644, Want: 0600}, issues[1])
+		assert.Equal(t, filepath.Join(dir, "creds.yaml")+": mode 0644, want 0600", issues[1].String())
+		assert.Equal(t, os.FileMode(0644), fileMode(t, filepath.Join(dir, "creds.yaml")), "checking changes nothing")
+
+		fixed, err := m.Harden()
+		require.NoError(t, err)
+		assert.Equal(t, issues, fixed)
+		assert.Equal(t, os.FileMode(0700), fileMode(t, dir))
+		assert.Equal(t, os.FileMode(0700), fileMode(t, filepath.Join(dir, "keys")))
+		assert.Equal(t, os.FileMode(0600), fileMode(t, filepath.Join(dir, "creds.yaml")))
+		assert.Equal(t, os.FileMode(0644), fileMode(t, filepath.Join(dir, "web1.yaml")))
+
+		issues, err = m.CheckPermissions()
+		require.NoError(t, err)
+		assert.Empty(t, issues)
+	})
+}
@@ -0,0 +1,124 @@
+package inventory
+
+import (
+	"fmt"
+	"slices"
+)
+
+// DefaultPriority is the priority of group members without one. Members with
+// priority 0 are passive, e.g. the standby of a primary/standby pair, and are
+// left out of executions on the group unless asked for.
+const DefaultPriority = 1
+
+// GroupMember is a host of a resolved group with its priority.
+type GroupMember struct {
+	HostID   string `json:"host_id"`
+	Priority int    `json:"priority"`
+}
+
+// Passive reports whether the member has priority 0.
+func (gm GroupMember) Passive() bool {
+	return gm.Priority == 0
+}
+
+// Priority returns the priority of a member host in the group, DefaultPriority
+// when the group does not set one.
+func (g *Group) Priority(hostID string) int {
+	if p, ok := g.Priorities[hostID]; ok {
+		return p
+	}
+	return DefaultPriority
+}
+
+// validatePriorities checks that priorities are not negative and belong to members.
+func (g *Group) validatePriorities() error {
+	for _, id := range sortedKeys(g.Priorities) {
+		if g.Priorities[id] < 0 {
+			return fmt.Errorf("group %s: priority of %s cannot be negative", g.Name, id)
+		}
+		if !g.HasHost(id) {
+			return fmt.Errorf("group %s: priority set for %s, which is not a member", g.Name, id)
+		}
+	}
+	return nil
+}
+
+// ResolveGroupMembers returns the hosts of a group in the order of
+// ResolveGroupHosts with their priority: the one set by the group, else by the
+// first nested child group listing the host that sets one, else DefaultPriority.
+func (m *Manager) ResolveGroupMembers(name string) ([]GroupMember, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids, err := m.resolveGroupHosts(name)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]GroupMember, len(ids))
+	for i, id := range ids {
+		p, ok := m.memberPriority(name, id, map[string]bool{})
+		if !ok {
+			p = DefaultPriority
+		}
+		members[i] = GroupMember{HostID: id, Priority: p}
+	}
+	return members, nil
+}
+
+// memberPriority looks up the priority a group or its nested child groups set for
+// a host. Caller must hold the lock.
+func (m *Manager) memberPriority(group, hostID string, visited map[string]bool) (int, bool) {
+	if visited[group] {
+		return 0, false
+	}
+	visited[group] = true
+
+	g, ok := m.groups[group]
+	if !ok {
+		return 0, false
+	}
+	if p, ok := g.Priorities[hostID]; ok {
+		return p, true
+	}
+	for _, child := range g.ChildGroupNames {
+		if p, ok := m.memberPriority(child, hostID, visited); ok {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+// SortMembersByPriority sorts members by descending priority, keeping the group
+// order among equal priorities.
+func SortMembersByPriority(members []GroupMember) {
+	slices.SortStableFunc(members, func(a, b GroupMember) int {
+		return b.Priority - a.Priority
+	})
+}
+
+// SetGroupHostPriority sets the priority of a member host of a group; priority
+// DefaultPriority removes the explicit setting.
+func (m *Manager) SetGroupHostPriority(group, hostID string, priority int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.groups[group]
+	if !ok {
+		return fmt.Errorf("group %s not found", group)
+	}
+	if !g.HasHost(hostID) {
+		return fmt.Errorf("group %s: host %s is not a member", group, hostID)
+	}
+	if priority < 0 {
+		return fmt.Errorf("group %s: priority of %s cannot be negative", group, hostID)
+	}
+	if priority == DefaultPriority {
+		delete(g.Priorities, hostID)
+	} else {
+		if g.Priorities == nil {
+			g.Priorities = make(map[string]int)
+		}
+		g.Priorities[hostID] = priority
+	}
+	return m.saveFile(m.sources[keyOf(g)])
+}
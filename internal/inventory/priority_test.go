@@ -0,0 +1,89 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupPriorities(t *testing.T) {
+	setup := func(t *testing.T) *Manager {
+		m, _ := setupTestManager(t)
+		for _, id := range []string{"db1", "db2", "web1", "web2"} {
+			h := NewHost(id, id, "10.0.0.1")
+			h.User = "deploy"
+			require.NoError(t, m.AddHost(h))
+		}
+		db := NewGroup("db")
+		db.HostIDs = []string{"db2", "db1"}
+		db.Priorities = map[string]int{"db1": 10, "db2": 0}
+		require.NoError(t, m.AddGroup(db))
+		all := NewGroup("all")
+		all.HostIDs = []string{"web1", "web2"}
+		all.ChildGroupNames = []string{"db"}
+		require.NoError(t, m.AddGroup(all))
+		return m
+	}
+
+	t.Run("members carry their priority", func(t *testing.T) {
+		m := setup(t)
+		members, err := m.ResolveGroupMembers("all")
+		require.NoError(t, err)
+		assert.Equal(t, []GroupMember{
+			{HostID: "web1", Priority: DefaultPriority},
+			{HostID: "web2", Priority: DefaultPriority},
+			{HostID: "db2", Priority: 0},
+			{HostID: "db1", Priority: 10},
+		}, members)
+		assert.True(t, members[2].Passive())
+
+		SortMembersByPriority(members)
+		assert.Equal(t, []string{"db1", "web1", "web2", "db2"},
+			[]string{members[0].HostID, members[1].HostID, members[2].HostID, members[3].HostID})
+	})
+
+	t.Run("parent overrides child and survives reload", func(t *testing.T) {
+		m := setup(t)
+		all, _ := m.GetGroup("all")
+		all.HostIDs = append(all.HostIDs, "db2")
+		require.NoError(t, m.UpdateGroup(all))
+		require.NoError(t, m.SetGroupHostPriority("all", "db2", 5))
+
+		reloaded := NewManager(m.GetDataDir())
+		require.NoError(t, reloaded.Load())
+		members, err := reloaded.ResolveGroupMembers("all")
+		require.NoError(t, err)
+		for _, gm := range members {
+			if gm.HostID == "db2" {
+				assert.Equal(t, 5, gm.Priority)
+			}
+		}
+
+		require.NoError(t, m.SetGroupHostPriority("all", "db2", DefaultPriority))
+		all, _ = m.GetGroup("all")
+		assert.NotContains(t, all.Priorities, "db2")
+	})
+
+	t.Run("invalid priorities are rejected", func(t *testing.T) {
+		m := setup(t)
+		assert.Error(t, m.SetGroupHostPriority("db", "db1", -1))
+		assert.Error(t, m.SetGroupHostPriority("db", "web1", 2))
+		assert.Error(t, m.SetGroupHostPriority("nope", "db1", 2))
+
+		g := NewGroup("bad")
+		g.HostIDs = []string{"web1"}
+		g.Priorities = map[string]int{"web2": 1}
+		assert.Error(t, m.AddGroup(g))
+	})
+
+	t.Run("follows host renames and removals", func(t *testing.T) {
+		m := setup(t)
+		require.NoError(t, m.RenameHost("db1", "db-primary"))
+		db, _ := m.GetGroup("db")
+		assert.Equal(t, map[string]int{"db-primary": 10, "db2": 0}, db.Priorities)
+
+		db.RemoveHost("db2")
+		assert.Equal(t, map[string]int{"db-primary": 10}, db.Priorities)
+	})
+}
@@ -0,0 +1,232 @@
+package inventory
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// DefaultProfile is the name of the profile backed by Config.DataDir.
+const DefaultProfile = "default"
+
+// Profile is a named inventory living in its own data directory.
+type Profile struct {
+	DataDir string `yaml:"data_dir"`
+	// GPGRecipients encrypts the profile's credentials for these key IDs.
+	GPGRecipients []string `yaml:"gpg_recipients,omitempty"`
+	// Discovery lists the cloud accounts the profile imports hosts from.
+	Discovery []DiscoverySource `yaml:"discovery,omitempty"`
+}
+
+// ===== Profiles =====
+
+// ListProfiles returns the names of all configured profiles, the default profile first.
+func ListProfiles() []string {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		panic("Config not loaded")
+	}
+
+	names := make([]string, 0, len(globalConfig.Profiles))
+	for name := range globalConfig.Profiles {
+		if name != DefaultProfile {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return append([]string{DefaultProfile}, names...)
+}
+
+// GetProfile returns a copy of a profile's settings, with DataDir resolved to an absolute path.
+func GetProfile(name string) (Profile, error) {
+	if name == "" || name == DefaultProfile {
+		dir := GetDataDir()
+
+		configMutex.RLock()
+		defer configMutex.RUnlock()
+		return Profile{
+			DataDir:       dir,
+			GPGRecipients: append([]string(nil), globalConfig.GPGRecipients...),
+			Discovery:     cloneDiscoverySources(globalConfig.Discovery),
+		}, nil
+	}
+
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if globalConfig == nil {
+		return Profile{}, fmt.Errorf("config not loaded")
+	}
+
+	p, ok := globalConfig.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile %s not found", name)
+	}
+	if !filepath.IsAbs(p.DataDir) {
+		p.DataDir = filepath.Join(globalConfig.BaseDir, p.DataDir)
+	}
+	p.GPGRecipients = append([]string(nil), p.GPGRecipients...)
+	p.Discovery = cloneDiscoverySources(p.Discovery)
+	return p, nil
+}
+
+// GetProfileDir returns the data directory of a profile.
+func GetProfileDir(name string) (string, error) {
+	p, err := GetProfile(name)
+	if err != nil {
+		return "", err
+	}
+	return p.DataDir, nil
+}
+
+// SetProfile adds or updates a profile and saves the config.
+func SetProfile(name string, p Profile) error {
+	if name == "" || name == DefaultProfile {
+		return fmt.Errorf("invalid profile name: %q", name)
+	}
+	if p.DataDir == "" {
+		return fmt.Errorf("profile %s: data directory cannot be empty", name)
+	}
+	for _, src := range p.Discovery {
+		if err := src.Validate(); err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+	if globalConfig.Profiles == nil {
+		globalConfig.Profiles = make(map[string]Profile)
+	}
+	p.GPGRecipients = append([]string(nil), p.GPGRecipients...)
+	p.Discovery = cloneDiscoverySources(p.Discovery)
+	globalConfig.Profiles[name] = p
+	configMutex.Unlock()
+
+	return Save()
+}
+
+// SetGPGRecipients updates the GPG recipients of a profile and saves the config.
+func SetGPGRecipients(profile string, recipients []string) error {
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+
+	recipients = append([]string(nil), recipients...)
+	if profile == "" || profile == DefaultProfile {
+		globalConfig.GPGRecipients = recipients
+	} else {
+		p, ok := globalConfig.Profiles[profile]
+		if !ok {
+			configMutex.Unlock()
+			return fmt.Errorf("profile %s not found", profile)
+		}
+		p.GPGRecipients = recipients
+		globalConfig.Profiles[profile] = p
+	}
+	configMutex.Unlock()
+
+	return Save()
+}
+
+// SetDiscoverySources validates and updates the discovery sources of a profile and
+// saves the config.
+func SetDiscoverySources(profile string, sources []DiscoverySource) error {
+	for _, src := range sources {
+		if err := src.Validate(); err != nil {
+			return err
+		}
+	}
+
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+
+	sources = cloneDiscoverySources(sources)
+	if profile == "" || profile == DefaultProfile {
+		globalConfig.Discovery = sources
+	} else {
+		p, ok := globalConfig.Profiles[profile]
+		if !ok {
+			configMutex.Unlock()
+			return fmt.Errorf("profile %s not found", profile)
+		}
+		p.Discovery = sources
+		globalConfig.Profiles[profile] = p
+	}
+	configMutex.Unlock()
+
+	return Save()
+}
+
+// RemoveProfile removes a profile from the config. Its data directory is left untouched.
+func RemoveProfile(name string) error {
+	configMutex.Lock()
+	if globalConfig == nil {
+		configMutex.Unlock()
+		return fmt.Errorf("config not loaded")
+	}
+	if _, ok := globalConfig.Profiles[name]; !ok {
+		configMutex.Unlock()
+		return fmt.Errorf("profile %s not found", name)
+	}
+	delete(globalConfig.Profiles, name)
+	configMutex.Unlock()
+
+	return Save()
+}
+
+// CodecProvider returns the codecs a profile's Manager needs: readers are registered for
+// loading, credential (if non-nil) encodes new credential files.
+type CodecProvider func(p Profile) (credential FileCodec, readers []FileCodec)
+
+var (
+	codecProviders   []CodecProvider
+	codecProvidersMu sync.RWMutex
+)
+
+// RegisterCodecProvider lets packages implementing encryption hook into OpenProfile
+// without inventory depending on them.
+func RegisterCodecProvider(fn CodecProvider) {
+	codecProvidersMu.Lock()
+	defer codecProvidersMu.Unlock()
+	codecProviders = append(codecProviders, fn)
+}
+
+// OpenProfile creates a Manager for a profile and loads its inventory.
+func OpenProfile(name string) (*Manager, error) {
+	p, err := GetProfile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewManager(p.DataDir)
+
+	codecProvidersMu.RLock()
+	for _, provide := range codecProviders {
+		credential, readers := provide(p)
+		for _, c := range readers {
+			m.RegisterCodec(c)
+		}
+		if credential != nil {
+			m.SetCredentialCodec(credential)
+		}
+	}
+	codecProvidersMu.RUnlock()
+
+	if err := m.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load profile %s: %w", name, err)
+	}
+	return m, nil
+}
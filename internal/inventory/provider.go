@@ -0,0 +1,104 @@
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// CredentialProvider fetches secrets from an external password manager.
+type CredentialProvider interface {
+	// Name is the value of Credential.Provider that selects this provider ("op").
+	Name() string
+	// Fetch returns the secrets stored in the item with the given ID.
+	Fetch(itemID string) (*ProviderSecret, error)
+}
+
+// ProviderSecret holds the secrets of a password manager item. Empty fields are
+// not present in the item.
+type ProviderSecret struct {
+	Password   string
+	PrivateKey string
+	Passphrase string
+}
+
+var (
+	credentialProviders   = map[string]CredentialProvider{}
+	credentialProvidersMu sync.RWMutex
+)
+
+// RegisterCredentialProvider makes a provider available to every Manager, replacing
+// any provider registered under the same name.
+func RegisterCredentialProvider(p CredentialProvider) {
+	credentialProvidersMu.Lock()
+	defer credentialProvidersMu.Unlock()
+	credentialProviders[p.Name()] = p
+}
+
+// GetCredentialProvider returns the provider registered under name.
+func GetCredentialProvider(name string) (CredentialProvider, bool) {
+	credentialProvidersMu.RLock()
+	defer credentialProvidersMu.RUnlock()
+	p, ok := credentialProviders[name]
+	return p, ok
+}
+
+// ResolveSecrets prepares the connection, its jump hosts and its relay host for
+// connecting: it expands the key path, reads the passphrase file and fetches the
+// secrets of provider-backed credentials. Call it right before connecting; secrets
+// already set inline on the host are kept.
+func (c *ResolvedConnection) ResolveSecrets() error {
+	for _, jump := range c.Jumps {
+		if err := jump.ResolveSecrets(); err != nil {
+			return err
+		}
+	}
+	if c.Relay != nil {
+		if err := c.Relay.ResolveSecrets(); err != nil {
+			return err
+		}
+	}
+
+	c.KeyPath = c.localPath(c.KeyPath)
+	if c.PassphraseFile != "" {
+		c.PassphraseFile = c.localPath(c.PassphraseFile)
+		if c.Passphrase == "" {
+			data, err := os.ReadFile(c.PassphraseFile)
+			if err != nil {
+				return fmt.Errorf("host %s: failed to read passphrase file: %w", c.HostID, err)
+			}
+			c.Passphrase = strings.TrimRight(string(data), "\r\n")
+		}
+	}
+
+	if c.Provider == "" {
+		return nil
+	}
+	p, ok := GetCredentialProvider(c.Provider)
+	if !ok {
+		return fmt.Errorf("host %s: unknown credential provider %s", c.HostID, c.Provider)
+	}
+
+	secret, err := p.Fetch(c.ItemID)
+	if err != nil {
+		return fmt.Errorf("host %s: failed to fetch item %s from %s: %w", c.HostID, c.ItemID, c.Provider, err)
+	}
+
+	if c.Password == "" {
+		c.Password = secret.Password
+	}
+	if c.PrivateKey == "" && c.KeyPath == "" {
+		c.PrivateKey = secret.PrivateKey
+	}
+	if c.Passphrase == "" {
+		c.Passphrase = secret.Passphrase
+	}
+	return nil
+}
+
+// localPath expands a path from the inventory and resolves it against DataDir when
+// it is relative.
+func (c *ResolvedConnection) localPath(p string) string {
+	return resolvePath(p, c.DataDir)
+}
@@ -0,0 +1,239 @@
+package inventory
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// RelationType is the kind of a relation between two hosts.
+type RelationType string
+
+const (
+	// RelationDependsOn marks a host that needs another one to work, e.g. an
+	// application server and its database.
+	RelationDependsOn RelationType = "depends-on"
+	// RelationReplicaOf marks a host that replicates another one, e.g. a database
+	// replica and its primary.
+	RelationReplicaOf RelationType = "replica-of"
+)
+
+// Validate checks that the relation type is known.
+func (t RelationType) Validate() error {
+	switch t {
+	case RelationDependsOn, RelationReplicaOf:
+		return nil
+	}
+	return fmt.Errorf("invalid relation type: %q", t)
+}
+
+// Relation links a host to another inventory host.
+type Relation struct {
+	Type RelationType `yaml:"type"`
+	Host string       `yaml:"host"`
+}
+
+// validateRelations checks the relations of a host on their own; the targets are
+// checked by the Manager.
+func (h *Host) validateRelations() error {
+	seen := make(map[Relation]bool, len(h.Relations))
+	for _, r := range h.Relations {
+		if err := r.Type.Validate(); err != nil {
+			return fmt.Errorf("host %s: %w", h.ID, err)
+		}
+		if r.Host == "" {
+			return fmt.Errorf("host %s: %s relation without host", h.ID, r.Type)
+		}
+		if r.Host == h.ID {
+			return fmt.Errorf("host %s: cannot be %s itself", h.ID, r.Type)
+		}
+		if seen[r] {
+			return fmt.Errorf("host %s: duplicate relation %s %s", h.ID, r.Type, r.Host)
+		}
+		seen[r] = true
+	}
+	return nil
+}
+
+// checkRelations verifies that the hosts h relates to exist and that the relations
+// do not form a cycle. Caller must hold the lock.
+func (m *Manager) checkRelations(h *Host) error {
+	for _, r := range h.Relations {
+		if _, ok := m.hosts[r.Host]; !ok {
+			return fmt.Errorf("host %s: %s host %s not found", h.ID, r.Type, r.Host)
+		}
+	}
+
+	// Walk the relations of the targets, using h in place of its stored version.
+	relationsOf := func(id string) []Relation {
+		if id == h.ID {
+			return h.Relations
+		}
+		return m.hosts[id].Relations
+	}
+	visited := map[string]bool{}
+	var walk func(id string, path []string) error
+	walk = func(id string, path []string) error {
+		for _, r := range relationsOf(id) {
+			if r.Host == h.ID {
+				return fmt.Errorf("host %s: relation cycle %v", h.ID, append(path, r.Host))
+			}
+			if visited[r.Host] || m.hosts[r.Host] == nil {
+				continue
+			}
+			visited[r.Host] = true
+			if err := walk(r.Host, append(path, r.Host)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(h.ID, []string{h.ID})
+}
+
+// updateRelationRefs points the relations to oldID at newID, or drops them if
+// newID is empty. Caller must hold the lock.
+func (m *Manager) updateRelationRefs(oldID, newID string, dirty map[string]bool) {
+	for _, h := range m.hosts {
+		kept := h.Relations[:0]
+		changed := false
+		for _, r := range h.Relations {
+			if r.Host != oldID {
+				kept = append(kept, r)
+				continue
+			}
+			changed = true
+			if newID != "" {
+				r.Host = newID
+				kept = append(kept, r)
+			}
+		}
+		if len(kept) == 0 {
+			kept = nil
+		}
+		h.Relations = kept
+		if changed {
+			dirty[m.sources[keyOf(h)]] = true
+		}
+	}
+}
+
+// ===== Graph =====
+
+// Edge is a relation from one host to another.
+type Edge struct {
+	From string
+	To   string
+	Type RelationType
+}
+
+// RelationGraph is a snapshot of the relations between hosts.
+type RelationGraph struct {
+	// Edges holds every relation sorted by source, target and type.
+	Edges []Edge
+	names map[string]string
+}
+
+// RelationGraph returns the relations between the hosts of the inventory.
+func (m *Manager) RelationGraph() *RelationGraph {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g := &RelationGraph{names: make(map[string]string, len(m.hosts))}
+	for _, id := range sortedKeys(m.hosts) {
+		h := m.hosts[id]
+		g.names[id] = h.Name
+		for _, r := range h.Relations {
+			g.Edges = append(g.Edges, Edge{From: id, To: r.Host, Type: r.Type})
+		}
+	}
+	sort.SliceStable(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Type < b.Type
+	})
+	return g
+}
+
+// Neighbors returns the relations from and to a host.
+func (g *RelationGraph) Neighbors(id string) []Edge {
+	var edges []Edge
+	for _, e := range g.Edges {
+		if e.From == id || e.To == id {
+			edges = append(edges, e)
+		}
+	}
+	return edges
+}
+
+// Dependents returns the hosts that relate to a host directly or through other
+// hosts, i.e. the hosts affected when it goes down, sorted by ID.
+func (g *RelationGraph) Dependents(id string) []string {
+	return g.reach(id, func(e Edge) (string, string) { return e.To, e.From })
+}
+
+// Dependencies returns the hosts a host relates to directly or through other hosts,
+// sorted by ID.
+func (g *RelationGraph) Dependencies(id string) []string {
+	return g.reach(id, func(e Edge) (string, string) { return e.From, e.To })
+}
+
+// reach returns the hosts reachable from id following the edges in the direction
+// given by ends, which returns the near and far end of an edge.
+func (g *RelationGraph) reach(id string, ends func(Edge) (string, string)) []string {
+	seen := map[string]bool{id: true}
+	queue := []string{id}
+	var found []string
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, e := range g.Edges {
+			near, far := ends(e)
+			if near != current || seen[far] {
+				continue
+			}
+			seen[far] = true
+			found = append(found, far)
+			queue = append(queue, far)
+		}
+	}
+	sort.Strings(found)
+	return found
+}
+
+// WriteDOT renders the graph in the Graphviz DOT language, e.g. for
+// "dot -Tsvg". Only hosts with relations are drawn; replica-of edges are dashed.
+func (g *RelationGraph) WriteDOT(w io.Writer) error {
+	nodes := map[string]bool{}
+	for _, e := range g.Edges {
+		nodes[e.From] = true
+		nodes[e.To] = true
+	}
+
+	if _, err := fmt.Fprintln(w, "digraph relations {"); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "  node [shape=box];")
+	for _, id := range sortedKeys(nodes) {
+		label := id
+		if name := g.names[id]; name != "" && name != id {
+			label = name + "\n" + id
+		}
+		fmt.Fprintf(w, "  %s [label=%s];\n", strconv.Quote(id), strconv.Quote(label))
+	}
+	for _, e := range g.Edges {
+		style := ""
+		if e.Type == RelationReplicaOf {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(w, "  %s -> %s [label=%s%s];\n", strconv.Quote(e.From), strconv.Quote(e.To), strconv.Quote(string(e.Type)), style)
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
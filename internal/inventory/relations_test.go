@@ -0,0 +1,75 @@
+package inventory
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelations(t *testing.T) {
+	m, _ := setupTestManager(t)
+	require.NoError(t, m.Load())
+
+	add := func(id string, relations ...Relation) error {
+		h := NewHost(id, id, "10.0.0.1")
+		h.User = "deploy"
+		h.Relations = relations
+		return m.AddHost(h)
+	}
+	require.NoError(t, add("db1"))
+	require.NoError(t, add("db2", Relation{RelationReplicaOf, "db1"}))
+	require.NoError(t, add("app1", Relation{RelationDependsOn, "db1"}))
+	require.NoError(t, add("lb", Relation{RelationDependsOn, "app1"}))
+
+	t.Run("validation", func(t *testing.T) {
+		assert.ErrorContains(t, add("x", Relation{"uses", "db1"}), `invalid relation type: "uses"`)
+		assert.ErrorContains(t, add("x", Relation{RelationDependsOn, "x"}), "cannot be depends-on itself")
+		assert.ErrorContains(t, add("x", Relation{RelationDependsOn, "nope"}), "depends-on host nope not found")
+		assert.ErrorContains(t, add("x", Relation{RelationDependsOn, "db1"}, Relation{RelationDependsOn, "db1"}), "duplicate relation")
+
+		db1, _ := m.GetHost("db1")
+		db1.Relations = []Relation{{RelationDependsOn, "lb"}}
+		assert.ErrorContains(t, m.UpdateHost(db1), "relation cycle [db1 lb app1 db1]")
+	})
+
+	t.Run("graph queries", func(t *testing.T) {
+		g := m.RelationGraph()
+		assert.Equal(t, []Edge{
+			{From: "app1", To: "db1", Type: RelationDependsOn},
+			{From: "db2", To: "db1", Type: RelationReplicaOf},
+		}, g.Neighbors("db1"))
+		assert.Equal(t, []string{"app1", "db2", "lb"}, g.Dependents("db1"))
+		assert.Equal(t, []string{"app1", "db1"}, g.Dependencies("lb"))
+		assert.Empty(t, g.Dependents("lb"))
+	})
+
+	t.Run("dot export", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, m.RelationGraph().WriteDOT(&buf))
+		assert.Equal(t, `digraph relations {
+  node [shape=box];
+  "app1" [label="app1"];
+  "db1" [label="db1"];
+  "db2" [label="db2"];
+  "lb" [label="lb"];
+  "app1" -> "db1" [label="depends-on"];
+  "db2" -> "db1" [label="replica-of", style=dashed];
+  "lb" -> "app1" [label="depends-on"];
+}
+`, buf.String())
+	})
+
+	t.Run("follows renames and removals", func(t *testing.T) {
+		require.NoError(t, m.RenameHost("db1", "db-primary"))
+		require.NoError(t, m.Load())
+		app, _ := m.GetHost("app1")
+		assert.Equal(t, []Relation{{RelationDependsOn, "db-primary"}}, app.Relations)
+
+		require.NoError(t, m.RemoveHost("db-primary"))
+		app, _ = m.GetHost("app1")
+		assert.Empty(t, app.Relations)
+		assert.Equal(t, []string{"lb"}, m.RelationGraph().Dependents("app1"))
+	})
+}
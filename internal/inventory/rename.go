@@ -0,0 +1,216 @@
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// RenameHost changes a host's ID, updates every group that references it and
+// moves its file. Either all changes are written or none are.
+func (m *Manager) RenameHost(oldID, newID string) error {
+	return m.rename(TypeHost, oldID, newID)
+}
+
+// RenameGroup changes a group's name, updates every parent group that references it
+// and moves its file. Either all changes are written or none are.
+func (m *Manager) RenameGroup(oldName, newName string) error {
+	return m.rename(TypeGroup, oldName, newName)
+}
+
+// RenameCredential changes a credential's ID, updates every host that uses it
+// and moves its file. Either all changes are written or none are.
+func (m *Manager) RenameCredential(oldID, newID string) error {
+	return m.rename(TypeCredential, oldID, newID)
+}
+
+func (m *Manager) rename(kind DocumentType, oldID, newID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldKey := entityKey{kind, oldID}
+	if _, exists := m.sources[oldKey]; !exists {
+		return fmt.Errorf("%s %s not found", kind, oldID)
+	}
+
+	newID, err := applyIDPolicy(m.idPolicy, kind, newID)
+	if err != nil {
+		return err
+	}
+	if newID == "" {
+		return fmt.Errorf("%s ID cannot be empty", kind)
+	}
+	if newID == oldID {
+		return nil
+	}
+	if _, exists := m.sources[entityKey{kind, newID}]; exists {
+		return fmt.Errorf("%s %s already exists", kind, newID)
+	}
+
+	snap := m.snapshot()
+	dirty := map[string]bool{}
+	m.renameLocked(oldKey, newID, dirty)
+
+	if err := m.saveFilesAtomic(dirty); err != nil {
+		m.restore(snap)
+		return fmt.Errorf("failed to rename %s %s: %w", kind, oldID, err)
+	}
+	if kind == TypeHost || kind == TypeGroup {
+		if err := m.moveRunbook(kind, oldID, newID); err != nil {
+			return err
+		}
+	}
+	if kind == TypeHost {
+		return m.moveAttachments(oldID, newID)
+	}
+	return nil
+}
+
+// ===== Transactions =====
+
+// managerState is a deep copy of the Manager's in-memory inventory used for rollback.
+type managerState struct {
+	hosts       map[string]*Host
+	groups      map[string]*Group
+	credentials map[string]*Credential
+	commands    map[string]*SavedCommand
+	checks      map[string]*Check
+	workflows   map[string]*Workflow
+	themes      map[string]*Theme
+	sessionSets map[string]*SessionSet
+	sources     map[entityKey]string
+	files       map[string][]entityKey
+}
+
+// snapshot captures the in-memory inventory. Caller must hold the lock.
+func (m *Manager) snapshot() managerState {
+	s := managerState{
+		hosts:       make(map[string]*Host, len(m.hosts)),
+		groups:      make(map[string]*Group, len(m.groups)),
+		credentials: make(map[string]*Credential, len(m.credentials)),
+		commands:    make(map[string]*SavedCommand, len(m.commands)),
+		checks:      make(map[string]*Check, len(m.checks)),
+		workflows:   make(map[string]*Workflow, len(m.workflows)),
+		themes:      make(map[string]*Theme, len(m.themes)),
+		sessionSets: make(map[string]*SessionSet, len(m.sessionSets)),
+		sources:     make(map[entityKey]string, len(m.sources)),
+		files:       make(map[string][]entityKey, len(m.files)),
+	}
+	for id, h := range m.hosts {
+		s.hosts[id] = h.Clone().(*Host)
+	}
+	for name, g := range m.groups {
+		s.groups[name] = g.Clone().(*Group)
+	}
+	for id, c := range m.credentials {
+		s.credentials[id] = c.Clone().(*Credential)
+	}
+	for id, c := range m.commands {
+		s.commands[id] = c.Clone().(*SavedCommand)
+	}
+	for id, c := range m.checks {
+		s.checks[id] = c.Clone().(*Check)
+	}
+	for id, w := range m.workflows {
+		s.workflows[id] = w.Clone().(*Workflow)
+	}
+	for id, t := range m.themes {
+		s.themes[id] = t.Clone().(*Theme)
+	}
+	for id, ss := range m.sessionSets {
+		s.sessionSets[id] = ss.Clone().(*SessionSet)
+	}
+	for k, v := range m.sources {
+		s.sources[k] = v
+	}
+	for k, v := range m.files {
+		s.files[k] = append([]entityKey(nil), v...)
+	}
+	return s
+}
+
+// restore replaces the in-memory inventory with a snapshot. Caller must hold the lock.
+func (m *Manager) restore(s managerState) {
+	m.hosts = s.hosts
+	m.groups = s.groups
+	m.credentials = s.credentials
+	m.commands = s.commands
+	m.checks = s.checks
+	m.workflows = s.workflows
+	m.themes = s.themes
+	m.sessionSets = s.sessionSets
+	m.sources = s.sources
+	m.files = s.files
+}
+
+// saveFilesAtomic writes every dirty file to a temporary sibling first and only moves
+// them into place once all of them were written, so a failure leaves the data
+// directory untouched. Files that no longer hold entities are removed last.
+// Caller must hold the lock.
+func (m *Manager) saveFilesAtomic(files map[string]bool) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if err := os.MkdirAll(m.dataDir, m.perms.DirMode()); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	for _, name := range names {
+		if err := m.checkWritable(name); err != nil {
+			return err
+		}
+	}
+
+	type pending struct{ tmp, path string }
+	var writes []pending
+	var removals []string
+
+	cleanup := func() {
+		for _, p := range writes {
+			os.Remove(p.tmp)
+		}
+	}
+
+	for _, name := range names {
+		path := filepath.Join(m.dataDir, name)
+		if len(m.files[name]) == 0 {
+			removals = append(removals, path)
+			continue
+		}
+
+		data, err := m.renderFile(name)
+		if err != nil {
+			cleanup()
+			return err
+		}
+
+		tmp := filepath.Join(m.dataDir, "."+name+".tmp")
+		if err := os.WriteFile(tmp, data, m.fileMode(name)); err != nil {
+			cleanup()
+			return fmt.Errorf("failed to write file %s: %w", tmp, err)
+		}
+		writes = append(writes, pending{tmp: tmp, path: path})
+	}
+
+	for i, p := range writes {
+		if err := os.Rename(p.tmp, p.path); err != nil {
+			for _, rest := range writes[i:] {
+				os.Remove(rest.tmp)
+			}
+			return fmt.Errorf("failed to replace file %s: %w", p.path, err)
+		}
+	}
+
+	for _, path := range removals {
+		delete(m.files, filepath.Base(path))
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete file %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenameHost(t *testing.T) {
+	m, dir := setupTestManager(t)
+
+	h := NewHost("web1", "web1", "10.0.0.1")
+	h.User = "root"
+	require.NoError(t, m.AddHost(h))
+	other := NewHost("web2", "web2", "10.0.0.2")
+	other.User = "root"
+	require.NoError(t, m.AddHost(other))
+
+	g := NewGroup("web")
+	g.AddHost("web1")
+	g.AddHost("web2")
+	require.NoError(t, m.AddGroup(g))
+
+	t.Run("rename updates references and files", func(t *testing.T) {
+		require.NoError(t, m.RenameHost("web1", "web-01"))
+
+		assert.NoFileExists(t, filepath.Join(dir, "host-web1.yaml"))
+		assert.FileExists(t, filepath.Join(dir, "host-web-01.yaml"))
+
+		reloaded := NewManager(dir)
+		require.NoError(t, reloaded.Load())
+		_, ok := reloaded.GetHost("web-01")
+		assert.True(t, ok)
+		grp, _ := reloaded.GetGroup("web")
+		assert.Equal(t, []string{"web-01", "web2"}, grp.HostIDs)
+	})
+
+	t.Run("rename to existing ID fails", func(t *testing.T) {
+		assert.Error(t, m.RenameHost("web-01", "web2"))
+	})
+
+	t.Run("rename missing host fails", func(t *testing.T) {
+		assert.Error(t, m.RenameHost("nope", "x"))
+	})
+
+	t.Run("failed write rolls back", func(t *testing.T) {
+		if os.Getuid() == 0 {
+			t.Skip("permissions are not enforced for root")
+		}
+		require.NoError(t, os.Chmod(dir, 0555))
+		defer os.Chmod(dir, 0755)
+
+		assert.Error(t, m.RenameHost("web2", "web-02"))
+		_, ok := m.GetHost("web2")
+		assert.True(t, ok)
+		grp, _ := m.GetGroup("web")
+		assert.Contains(t, grp.HostIDs, "web2")
+	})
+}
+
+func TestRenameGroup(t *testing.T) {
+	m, dir := setupTestManager(t)
+	writeTestFile(t, dir, "groups.yaml", "type: group\nname: web\n---\ntype: group\nname: all\nchild_groups: [web]\n")
+	require.NoError(t, m.Load())
+
+	require.NoError(t, m.RenameGroup("web", "frontend"))
+
+	// Entities in multi-document files stay in place.
+	reloaded := NewManager(dir)
+	require.NoError(t, reloaded.Load())
+	all, ok := reloaded.GetGroup("all")
+	require.True(t, ok)
+	assert.Equal(t, []string{"frontend"}, all.ChildGroupNames)
+	_, ok = reloaded.GetGroup("frontend")
+	assert.True(t, ok)
+	assert.NoFileExists(t, filepath.Join(dir, "group-frontend.yaml"))
+}
@@ -0,0 +1,181 @@
+package inventory
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultIndent matches the indentation of yaml.Marshal.
+const defaultIndent = 4
+
+// MergeYAML re-renders the documents of original with the given values while keeping
+// its comments, key order, quoting and indentation. Document i of original is
+// updated to values[i]; extra values are appended as new documents and surplus
+// documents are dropped.
+func MergeYAML(original []byte, values ...any) ([]byte, error) {
+	docs, err := decodeDocumentNodes(original)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]*yaml.Node, len(values))
+	for i, v := range values {
+		if i < len(docs) {
+			if err := mergeValue(docs[i], v); err != nil {
+				return nil, err
+			}
+			nodes[i] = docs[i]
+			continue
+		}
+		doc, err := newDocumentNode(v)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = doc
+	}
+	return encodeDocuments(nodes, detectIndent(original))
+}
+
+// decodeDocumentNodes parses every document of data into a document node.
+func decodeDocumentNodes(data []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node yaml.Node
+		if err := dec.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				return docs, nil
+			}
+			return nil, err
+		}
+		docs = append(docs, &node)
+	}
+}
+
+// newDocumentNode encodes v as a document node.
+func newDocumentNode(v any) (*yaml.Node, error) {
+	var value yaml.Node
+	if err := value.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode %T: %w", v, err)
+	}
+	return &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&value}}, nil
+}
+
+// mergeValue updates a document node in place so it represents v.
+func mergeValue(doc *yaml.Node, v any) error {
+	var value yaml.Node
+	if err := value.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode %T: %w", v, err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		*doc = yaml.Node{Kind: yaml.DocumentNode, HeadComment: doc.HeadComment, Content: []*yaml.Node{&value}}
+		return nil
+	}
+	mergeNode(doc.Content[0], &value)
+	return nil
+}
+
+// mergeNode copies the data of src into dst, keeping the comments, key order and
+// scalar styles of dst wherever the structure still matches.
+func mergeNode(dst, src *yaml.Node) {
+	if dst.Kind != src.Kind {
+		head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+		*dst = *src
+		dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+		return
+	}
+
+	switch dst.Kind {
+	case yaml.MappingNode:
+		values := make(map[string]*yaml.Node, len(src.Content)/2)
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			values[src.Content[i].Value] = src.Content[i+1]
+		}
+
+		// Existing keys keep their position; new keys follow in struct order
+		var content []*yaml.Node
+		kept := map[string]bool{}
+		for i := 0; i+1 < len(dst.Content); i += 2 {
+			key := dst.Content[i]
+			v, ok := values[key.Value]
+			if !ok {
+				continue
+			}
+			mergeNode(dst.Content[i+1], v)
+			content = append(content, key, dst.Content[i+1])
+			kept[key.Value] = true
+		}
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			if !kept[src.Content[i].Value] {
+				content = append(content, src.Content[i], src.Content[i+1])
+			}
+		}
+		dst.Content = content
+
+	case yaml.SequenceNode:
+		for i, item := range src.Content {
+			if i < len(dst.Content) {
+				mergeNode(dst.Content[i], item)
+			} else {
+				dst.Content = append(dst.Content, item)
+			}
+		}
+		dst.Content = dst.Content[:len(src.Content)]
+
+	case yaml.ScalarNode:
+		if dst.Value == src.Value && dst.Tag == src.Tag {
+			return
+		}
+		dst.Value, dst.Tag = src.Value, src.Tag
+		// Keep the user's quoting unless the new value needs a specific style
+		if src.Style != 0 || (dst.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 && !strings.Contains(src.Value, "\n")) {
+			dst.Style = src.Style
+		}
+
+	default:
+		head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+		*dst = *src
+		dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+	}
+}
+
+// encodeDocuments renders document nodes as a multi-document stream.
+func encodeDocuments(docs []*yaml.Node, indent int) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(indent)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return nil, fmt.Errorf("failed to encode YAML: %w", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// detectIndent guesses the indentation width of a YAML file from its smallest
+// indentation, falling back to defaultIndent.
+func detectIndent(data []byte) int {
+	indent := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		n := len(line) - len(trimmed)
+		if n == 0 || trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if indent == 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent < 2 || indent > 9 {
+		return defaultIndent
+	}
+	return indent
+}
@@ -0,0 +1,104 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	t.Run("updates keep comments, key order and indentation", func(t *testing.T) {
+		m, dir := setupTestManager(t)
+		writeTestFile(t, dir, "web.yaml", `# Frontend hosts, maintained by hand
+type: host
+id: web1
+name: web1
+user: deploy # shared deploy account
+address: 10.0.0.1
+port: 2222 # sshd moved off 22
+tags:
+  - env:prod
+`)
+		require.NoError(t, m.Load())
+
+		h, _ := m.GetHost("web1")
+		h.Address = "10.0.0.9"
+		h.AddTag("role:web")
+		h.Description = "Frontend"
+		require.NoError(t, m.UpdateHost(h))
+
+		data, err := os.ReadFile(filepath.Join(dir, "web.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, `# Frontend hosts, maintained by hand
+type: host
+id: web1
+name: web1
+user: deploy # shared deploy account
+address: 10.0.0.9
+port: 2222 # sshd moved off 22
+tags:
+  - env:prod
+  - role:web
+description: Frontend
+`, string(data))
+	})
+
+	t.Run("comments follow renames within shared files", func(t *testing.T) {
+		m, dir := setupTestManager(t)
+		writeTestFile(t, dir, "hosts.yaml", `type: host
+id: a
+name: a
+user: root # keep
+address: 10.0.0.1
+---
+# second host
+type: host
+id: b
+name: b
+user: root
+address: 10.0.0.2
+`)
+		require.NoError(t, m.Load())
+		require.NoError(t, m.RenameHost("b", "c"))
+
+		data, err := os.ReadFile(filepath.Join(dir, "hosts.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "user: root # keep")
+		assert.Contains(t, string(data), "# second host\ntype: host\nid: c\n")
+	})
+}
+
+func TestMergeYAML(t *testing.T) {
+	original := []byte(`# header
+name: 'web' # quoted
+hosts:
+    - a
+    - b
+old: gone
+`)
+	value := struct {
+		Name  string   `yaml:"name"`
+		Hosts []string `yaml:"hosts"`
+		New   string   `yaml:"new"`
+	}{Name: "web2", Hosts: []string{"a"}, New: "yes"}
+
+	merged, err := MergeYAML(original, value, value)
+	require.NoError(t, err)
+	assert.Equal(t, `# header
+name: 'web2' # quoted
+hosts:
+    - a
+new: "yes"
+---
+name: web2
+hosts:
+    - a
+new: "yes"
+`, string(merged))
+
+	_, err = MergeYAML([]byte("a: : b"), value)
+	assert.Error(t, err)
+}
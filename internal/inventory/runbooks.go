@@ -0,0 +1,138 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RunbooksDir is the subdirectory of the data directory holding the markdown
+// runbooks of hosts and groups, as runbooks/<kind>/<id>.md.
+const RunbooksDir = "runbooks"
+
+// Note is a note or runbook shown for a host.
+type Note struct {
+	// Kind and ID name the host or group the note belongs to.
+	Kind DocumentType
+	ID   string
+	// Runbook is true for markdown runbooks and false for the Notes field.
+	Runbook bool
+	Text    string
+}
+
+// runbookPath returns the runbook file of a host or group. Caller must hold the lock.
+func (m *Manager) runbookPath(kind DocumentType, id string) string {
+	return filepath.Join(m.dataDir, RunbooksDir, string(kind), id+".md")
+}
+
+// checkRunbookOwner verifies that a host or group exists. Caller must hold the lock.
+func (m *Manager) checkRunbookOwner(kind DocumentType, id string) error {
+	if kind != TypeHost && kind != TypeGroup {
+		return fmt.Errorf("runbooks belong to hosts and groups, not %s", kind)
+	}
+	if _, ok := m.sources[entityKey{kind, id}]; !ok {
+		return fmt.Errorf("%s %s not found", kind, id)
+	}
+	return nil
+}
+
+// ReadRunbook returns the markdown runbook of a host or group, empty if it has none.
+func (m *Manager) ReadRunbook(kind DocumentType, id string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := m.checkRunbookOwner(kind, id); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(m.runbookPath(kind, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read runbook: %w", err)
+	}
+	return string(data), nil
+}
+
+// WriteRunbook replaces the markdown runbook of a host or group; empty text
+// removes it.
+func (m *Manager) WriteRunbook(kind DocumentType, id, text string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkRunbookOwner(kind, id); err != nil {
+		return err
+	}
+	path := m.runbookPath(kind, id)
+	if text == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove runbook: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), m.perms.DirMode()); err != nil {
+		return fmt.Errorf("failed to create runbooks directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(text), m.perms.FileMode()); err != nil {
+		return fmt.Errorf("failed to write runbook: %w", err)
+	}
+	return nil
+}
+
+// HostNotes returns the notes and runbooks relevant to a host: its own, then those
+// of the groups it belongs to, directly or through child groups.
+func (m *Manager) HostNotes(id string) ([]Note, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	h, ok := m.hosts[id]
+	if !ok {
+		return nil, fmt.Errorf("host %s not found", id)
+	}
+
+	var notes []Note
+	add := func(kind DocumentType, id, inline string) error {
+		if inline != "" {
+			notes = append(notes, Note{Kind: kind, ID: id, Text: inline})
+		}
+		data, err := os.ReadFile(m.runbookPath(kind, id))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to read runbook: %w", err)
+		}
+		if len(data) > 0 {
+			notes = append(notes, Note{Kind: kind, ID: id, Runbook: true, Text: string(data)})
+		}
+		return nil
+	}
+
+	if err := add(TypeHost, id, h.Notes); err != nil {
+		return nil, err
+	}
+	for _, name := range m.hostGroups(id) {
+		if err := add(TypeGroup, name, m.groups[name].Notes); err != nil {
+			return nil, err
+		}
+	}
+	return notes, nil
+}
+
+// moveRunbook follows a rename of a host or group. Caller must hold the lock.
+func (m *Manager) moveRunbook(kind DocumentType, oldID, newID string) error {
+	err := os.Rename(m.runbookPath(kind, oldID), m.runbookPath(kind, newID))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to move runbook: %w", err)
+	}
+	return nil
+}
+
+// removeRunbook deletes the runbook of a removed host or group. Caller must hold
+// the lock.
+func (m *Manager) removeRunbook(kind DocumentType, id string) error {
+	err := os.Remove(m.runbookPath(kind, id))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove runbook: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunbooks(t *testing.T) {
+	m, dir := setupTestManager(t)
+	writeTestFile(t, dir, "hosts.yaml", `type: group
+name: web
+host_ids: [web01]
+notes: Deploys go through the canary first.
+---
+type: group
+name: all
+host_ids: []
+child_groups: [web]
+---
+type: host
+id: web01
+name: web01
+address: 10.0.0.1
+port: 22
+user: deploy
+notes: Disk fills up with logs, see runbook.
+`)
+	require.NoError(t, m.Load())
+
+	t.Run("read and write", func(t *testing.T) {
+		text, err := m.ReadRunbook(TypeHost, "web01")
+		require.NoError(t, err)
+		assert.Empty(t, text)
+
+		require.NoError(t, m.WriteRunbook(TypeHost, "web01", "# web01\n\nRun `logrotate -f`.\n"))
+		text, err = m.ReadRunbook(TypeHost, "web01")
+		require.NoError(t, err)
+		assert.Equal(t, "# web01\n\nRun `logrotate -f`.\n", text)
+		assert.FileExists(t, filepath.Join(dir, RunbooksDir, "host", "web01.md"))
+
+		require.NoError(t, m.Load(), "runbooks are not data files")
+	})
+
+	t.Run("unknown owner", func(t *testing.T) {
+		assert.ErrorContains(t, m.WriteRunbook(TypeHost, "nope", "x"), "host nope not found")
+		_, err := m.ReadRunbook(TypeCredential, "web01")
+		assert.ErrorContains(t, err, "hosts and groups")
+	})
+
+	t.Run("host notes", func(t *testing.T) {
+		require.NoError(t, m.WriteRunbook(TypeGroup, "all", "Escalate to #ops.\n"))
+
+		notes, err := m.HostNotes("web01")
+		require.NoError(t, err)
+		assert.Equal(t, []Note{
+			{Kind: TypeHost, ID: "web01", Text: "Disk fills up with logs, see runbook."},
+			{Kind: TypeHost, ID: "web01", Runbook: true, Text: "# web01\n\nRun `logrotate -f`.\n"},
+			{Kind: TypeGroup, ID: "all", Runbook: true, Text: "Escalate to #ops.\n"},
+			{Kind: TypeGroup, ID: "web", Text: "Deploys go through the canary first."},
+		}, notes)
+	})
+
+	t.Run("follows renames and removals", func(t *testing.T) {
+		require.NoError(t, m.RenameHost("web01", "web-01"))
+		text, err := m.ReadRunbook(TypeHost, "web-01")
+		require.NoError(t, err)
+		assert.Contains(t, text, "logrotate")
+
+		require.NoError(t, m.RemoveHost("web-01"))
+		_, err = os.Stat(filepath.Join(dir, RunbooksDir, "host", "web-01.md"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		require.NoError(t, m.WriteRunbook(TypeGroup, "all", ""))
+		_, err = os.Stat(filepath.Join(dir, RunbooksDir, "group", "all.md"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
@@ -0,0 +1,375 @@
+package inventory
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Ensure SavedCommand implements the interfaces
+var (
+	_ Entity = (*SavedCommand)(nil)
+)
+
+// PreflightAction decides what happens to hosts failing a pre-flight check.
+type PreflightAction string
+
+const (
+	// PreflightSkip leaves failing hosts out of the run (default).
+	PreflightSkip PreflightAction = "skip"
+	// PreflightFail reports failing hosts as failed.
+	PreflightFail PreflightAction = "fail"
+)
+
+// SavedCommand is a named, reusable command with optional pre-flight checks.
+type SavedCommand struct {
+	Type        DocumentType `yaml:"type"`
+	ID          string       `yaml:"id"`
+	Name        string       `yaml:"name"`
+	Description string       `yaml:"description,omitempty"`
+
+	Command string `yaml:"command"`
+
+	// Inputs are asked for at run time and available as {{.Inputs.name}}.
+	Inputs []Input `yaml:"inputs,omitempty"`
+
+	// Preflight checks are evaluated on each target before Command runs.
+	Preflight          []Preflight     `yaml:"preflight,omitempty"`
+	OnPreflightFailure PreflightAction `yaml:"on_preflight_failure,omitempty"`
+
+	// Outputs extract structured values from the command's output.
+	Outputs []OutputSpec `yaml:"outputs,omitempty"`
+
+	// Snapshot is captured on each target before and after the command.
+	Snapshot *Snapshot `yaml:"snapshot,omitempty"`
+
+	// Timeouts override those of the hosts and the config when the command runs.
+	Timeouts Timeouts `yaml:"timeouts,omitempty"`
+}
+
+// Preflight is a single assertion about a target. Every field that is set must hold.
+type Preflight struct {
+	// MinFreeDisk is the minimum free space on Path (default "/"), e.g. "500M" or "10G".
+	MinFreeDisk string `yaml:"min_free_disk,omitempty"`
+	Path        string `yaml:"path,omitempty"`
+
+	// OSFamily lists accepted OS families ("debian", "rhel"), matched against the
+	// os_family fact or /etc/os-release.
+	OSFamily []string `yaml:"os_family,omitempty"`
+
+	// Service must exist as a systemd unit or SysV service.
+	Service string `yaml:"service,omitempty"`
+
+	// Command must exit with status 0.
+	Command string `yaml:"command,omitempty"`
+}
+
+// OutputSpec extracts a named value from command output: the JSON field is selected
+// first, then the regex is applied, then the value is compared with the threshold.
+type OutputSpec struct {
+	Name string `yaml:"name"`
+	// Source is "stdout" (default) or "stderr".
+	Source string `yaml:"source,omitempty"`
+
+	// JSONField selects a field of JSON output by dotted path, e.g. "disks.0.used".
+	JSONField string `yaml:"json_field,omitempty"`
+	// Regex extracts its first capture group, or the whole match without groups.
+	Regex string `yaml:"regex,omitempty"`
+	// Processor names a registered processor, e.g. one provided by a plugin, or a
+	// transform_<name> script hook, that transforms the value after JSONField and Regex.
+	Processor string `yaml:"processor,omitempty"`
+	// Numeric converts the value to a number; implied by Threshold.
+	Numeric bool `yaml:"numeric,omitempty"`
+
+	Threshold *Threshold `yaml:"threshold,omitempty"`
+}
+
+// Threshold bounds a numeric value; a value outside [Min, Max] fails the host.
+type Threshold struct {
+	Min *float64 `yaml:"min,omitempty"`
+	Max *float64 `yaml:"max,omitempty"`
+}
+
+// Validate checks that the spec is well-formed.
+func (o *OutputSpec) Validate() error {
+	if o.Name == "" {
+		return fmt.Errorf("output name cannot be empty")
+	}
+	switch o.Source {
+	case "", "stdout", "stderr":
+	default:
+		return fmt.Errorf("output %s: invalid source: %s", o.Name, o.Source)
+	}
+	if o.Regex != "" {
+		if _, err := regexp.Compile(o.Regex); err != nil {
+			return fmt.Errorf("output %s: invalid regex: %w", o.Name, err)
+		}
+	}
+	if t := o.Threshold; t != nil {
+		if t.Min == nil && t.Max == nil {
+			return fmt.Errorf("output %s: threshold needs min or max", o.Name)
+		}
+		if t.Min != nil && t.Max != nil && *t.Min > *t.Max {
+			return fmt.Errorf("output %s: threshold min is above max", o.Name)
+		}
+	}
+	return nil
+}
+
+// clone returns a deep copy of the spec.
+func (o OutputSpec) clone() OutputSpec {
+	if o.Threshold != nil {
+		t := Threshold{}
+		if o.Threshold.Min != nil {
+			v := *o.Threshold.Min
+			t.Min = &v
+		}
+		if o.Threshold.Max != nil {
+			v := *o.Threshold.Max
+			t.Max = &v
+		}
+		o.Threshold = &t
+	}
+	return o
+}
+
+// NewSavedCommand creates a new SavedCommand.
+func NewSavedCommand(id, name, command string) *SavedCommand {
+	return &SavedCommand{
+		Type:    TypeCommand,
+		ID:      id,
+		Name:    name,
+		Command: command,
+	}
+}
+
+// GetID Identifiable interface implementation
+func (c *SavedCommand) GetID() string {
+	return c.ID
+}
+
+// GetName Nameable interface implementation
+func (c *SavedCommand) GetName() string {
+	return c.Name
+}
+
+func (c *SavedCommand) SetName(name string) {
+	c.Name = name
+}
+
+// GetDescription Describable interface implementation
+func (c *SavedCommand) GetDescription() string {
+	return c.Description
+}
+
+func (c *SavedCommand) SetDescription(desc string) {
+	c.Description = desc
+}
+
+// Validate checks if the SavedCommand has valid configuration.
+func (c *SavedCommand) Validate() error {
+	if c.ID == "" {
+		return fmt.Errorf("command ID cannot be empty")
+	}
+	if c.Name == "" {
+		return fmt.Errorf("command %s: name cannot be empty", c.ID)
+	}
+	if strings.TrimSpace(c.Command) == "" {
+		return fmt.Errorf("command %s: command cannot be empty", c.ID)
+	}
+
+	switch c.OnPreflightFailure {
+	case "", PreflightSkip, PreflightFail:
+	default:
+		return fmt.Errorf("command %s: invalid on_preflight_failure: %s", c.ID, c.OnPreflightFailure)
+	}
+
+	if err := c.Timeouts.Validate(); err != nil {
+		return fmt.Errorf("command %s: %w", c.ID, err)
+	}
+	if err := validateInputs(c.Inputs); err != nil {
+		return fmt.Errorf("command %s: %w", c.ID, err)
+	}
+
+	for i, p := range c.Preflight {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("command %s: preflight %d: %w", c.ID, i+1, err)
+		}
+	}
+	if c.Snapshot != nil {
+		if err := c.Snapshot.Validate(); err != nil {
+			return fmt.Errorf("command %s: %w", c.ID, err)
+		}
+	}
+
+	names := map[string]bool{}
+	for _, o := range c.Outputs {
+		if err := o.Validate(); err != nil {
+			return fmt.Errorf("command %s: %w", c.ID, err)
+		}
+		if names[o.Name] {
+			return fmt.Errorf("command %s: duplicate output %s", c.ID, o.Name)
+		}
+		names[o.Name] = true
+	}
+	return nil
+}
+
+// Clone creates a deep copy of the SavedCommand.
+func (c *SavedCommand) Clone() interface{} {
+	clone := *c
+	clone.Preflight = make([]Preflight, len(c.Preflight))
+	for i, p := range c.Preflight {
+		p.OSFamily = append([]string(nil), p.OSFamily...)
+		clone.Preflight[i] = p
+	}
+	if len(c.Preflight) == 0 {
+		clone.Preflight = nil
+	}
+	clone.Inputs = cloneInputs(c.Inputs)
+	clone.Snapshot = c.Snapshot.Clone()
+	clone.Outputs = nil
+	for _, o := range c.Outputs {
+		clone.Outputs = append(clone.Outputs, o.clone())
+	}
+	return &clone
+}
+
+// FailureAction returns the configured pre-flight failure action.
+func (c *SavedCommand) FailureAction() PreflightAction {
+	if c.OnPreflightFailure == "" {
+		return PreflightSkip
+	}
+	return c.OnPreflightFailure
+}
+
+// Validate checks that the assertion is well-formed.
+func (p *Preflight) Validate() error {
+	if p.MinFreeDisk == "" && len(p.OSFamily) == 0 && p.Service == "" && p.Command == "" {
+		return fmt.Errorf("empty preflight check")
+	}
+	if p.MinFreeDisk != "" {
+		if _, err := ParseSize(p.MinFreeDisk); err != nil {
+			return err
+		}
+	}
+	if p.Path != "" && p.MinFreeDisk == "" {
+		return fmt.Errorf("path requires min_free_disk")
+	}
+	return nil
+}
+
+// ParseSize parses a size such as "512", "500K", "10G" or "1.5T" into bytes,
+// using binary (1024) multiples.
+func ParseSize(s string) (int64, error) {
+	raw := strings.TrimSpace(s)
+	str := strings.ToUpper(raw)
+	str = strings.TrimSuffix(strings.TrimSuffix(str, "B"), "I")
+
+	multiplier := int64(1)
+	if str != "" {
+		switch str[len(str)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			str = str[:len(str)-1]
+		}
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %q", raw)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// ===== Manager =====
+
+// GetCommand returns a copy of the saved command with the given ID.
+func (m *Manager) GetCommand(id string) (*SavedCommand, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, ok := m.commands[id]
+	if !ok {
+		return nil, false
+	}
+	return c.Clone().(*SavedCommand), true
+}
+
+// ListCommands returns copies of all saved commands sorted by ID.
+func (m *Manager) ListCommands() []*SavedCommand {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cmds := make([]*SavedCommand, 0, len(m.commands))
+	for _, id := range sortedKeys(m.commands) {
+		cmds = append(cmds, m.commands[id].Clone().(*SavedCommand))
+	}
+	return cmds
+}
+
+// AddCommand validates and stores a new saved command. The ID policy may rewrite c.ID.
+func (m *Manager) AddCommand(c *SavedCommand) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, err := m.checkNewID(TypeCommand, c.ID)
+	if err != nil {
+		return err
+	}
+	c.ID = id
+
+	if _, exists := m.commands[c.ID]; exists {
+		return fmt.Errorf("command %s already exists", c.ID)
+	}
+
+	stored := c.Clone().(*SavedCommand)
+	stored.Type = TypeCommand
+	return m.store(stored)
+}
+
+// UpdateCommand replaces an existing saved command and rewrites its file.
+func (m *Manager) UpdateCommand(c *SavedCommand) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.commands[c.ID]; !exists {
+		return fmt.Errorf("command %s not found", c.ID)
+	}
+
+	stored := c.Clone().(*SavedCommand)
+	stored.Type = TypeCommand
+	m.commands[c.ID] = stored
+	return m.saveFile(m.sources[entityKey{TypeCommand, c.ID}])
+}
+
+// RemoveCommand deletes a saved command.
+func (m *Manager) RemoveCommand(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.commands[id]; !exists {
+		return fmt.Errorf("command %s not found", id)
+	}
+	if wid := m.workflowUsing(TypeCommand, id); wid != "" {
+		return fmt.Errorf("command %s is used by workflow %s", id, wid)
+	}
+	return m.saveFile(m.unregister(entityKey{TypeCommand, id}))
+}
@@ -0,0 +1,80 @@
+package inventory
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavedCommands(t *testing.T) {
+	m, dir := setupTestManager(t)
+
+	cmd := NewSavedCommand("disk-usage", "Disk usage", "df -h")
+	cmd.Preflight = []Preflight{{MinFreeDisk: "1G", Path: "/var"}, {OSFamily: []string{"debian"}}}
+	require.NoError(t, m.AddCommand(cmd))
+	assert.FileExists(t, filepath.Join(dir, "command-disk-usage.yaml"))
+
+	reloaded := NewManager(dir)
+	require.NoError(t, reloaded.Load())
+	got, ok := reloaded.GetCommand("disk-usage")
+	require.True(t, ok)
+	assert.Equal(t, "df -h", got.Command)
+	assert.Equal(t, "/var", got.Preflight[0].Path)
+	assert.Equal(t, PreflightSkip, got.FailureAction())
+
+	got.Preflight[1].OSFamily[0] = "rhel"
+	again, _ := reloaded.GetCommand("disk-usage")
+	assert.Equal(t, "debian", again.Preflight[1].OSFamily[0], "returned commands are copies")
+
+	t.Run("validation", func(t *testing.T) {
+		bad := NewSavedCommand("bad", "Bad", "true")
+		bad.Preflight = []Preflight{{}}
+		assert.Error(t, m.AddCommand(bad))
+
+		bad.Preflight = []Preflight{{MinFreeDisk: "lots"}}
+		assert.Error(t, m.AddCommand(bad))
+
+		bad.Preflight = nil
+		bad.OnPreflightFailure = "ignore"
+		assert.Error(t, m.AddCommand(bad))
+
+		assert.Error(t, m.AddCommand(NewSavedCommand("empty", "Empty", " ")))
+
+		bad.OnPreflightFailure = ""
+		bad.Outputs = []OutputSpec{{Name: "v", Regex: "("}}
+		assert.Error(t, m.AddCommand(bad))
+
+		bad.Outputs = []OutputSpec{{Name: "v"}, {Name: "v"}}
+		assert.Error(t, m.AddCommand(bad))
+
+		bad.Outputs = []OutputSpec{{Name: "v", Threshold: &Threshold{}}}
+		assert.Error(t, m.AddCommand(bad))
+	})
+
+	require.NoError(t, m.RemoveCommand("disk-usage"))
+	assert.NoFileExists(t, filepath.Join(dir, "command-disk-usage.yaml"))
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"512":  512,
+		"1K":   1024,
+		"500M": 500 << 20,
+		"10G":  10 << 30,
+		"10GB": 10 << 30,
+		"1GiB": 1 << 30,
+		"1.5T": 3 << 39,
+	}
+	for in, want := range cases {
+		got, err := ParseSize(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"", "G", "-1G", "ten"} {
+		_, err := ParseSize(in)
+		assert.Error(t, err, in)
+	}
+}
@@ -0,0 +1,111 @@
+package inventory
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"gossher/internal/script"
+)
+
+// ScriptsDir is the subdirectory of the data directory holding Starlark hooks
+// (*.star files, see package script).
+const ScriptsDir = "scripts"
+
+// Hooks are script functions recognized by their name prefix. Each receives a
+// host as a struct with the fields id, name, description, address, port, user,
+// local, tags, groups and vars (group and host vars merged).
+const (
+	// FilterPrefix functions, filter_<name>(host), return whether a host matches
+	// the "filter:<name>" selector of target specs.
+	FilterPrefix = "filter_"
+	// VarsPrefix functions, vars_<name>(host), return a dict of dynamic vars. They
+	// override group vars; vars set on the host override them.
+	VarsPrefix = "vars_"
+	// TransformPrefix functions, transform_<name>(value), transform extracted output
+	// values as the processor <name> of output specs.
+	TransformPrefix = "transform_"
+)
+
+// Scripts returns the hooks loaded from ScriptsDir, or nil before Load. The
+// program is immutable and safe for concurrent use.
+func (m *Manager) Scripts() *script.Program {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.scripts
+}
+
+// loadScripts loads the hooks of the data directory. Caller must hold the lock.
+func (m *Manager) loadScripts() error {
+	p, err := script.LoadDir(filepath.Join(m.dataDir, ScriptsDir))
+	if err != nil {
+		return fmt.Errorf("failed to load scripts: %w", err)
+	}
+	m.scripts = p
+	return nil
+}
+
+// hostValue returns the script value of a host. Caller must hold the lock.
+func (m *Manager) hostValue(h *Host) (script.Value, error) {
+	groups := m.hostGroups(h.ID)
+	host, err := script.NewStruct("host", map[string]any{
+		"id":          h.ID,
+		"name":        h.Name,
+		"description": h.Description,
+		"address":     h.Address,
+		"port":        h.Port,
+		"user":        h.User,
+		"local":       h.IsLocal(),
+		"tags":        append([]string{}, h.Tags...),
+		"groups":      append([]string{}, groups...),
+		"vars":        m.staticVars(h, groups),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("host %s: %w", h.ID, err)
+	}
+	return host, nil
+}
+
+// matchesFilter calls the filter_<name> hook for a host. Caller must hold the lock.
+func (m *Manager) matchesFilter(name string, h *Host) (bool, error) {
+	host, err := m.hostValue(h)
+	if err != nil {
+		return false, err
+	}
+	v, err := m.scripts.Call(FilterPrefix+name, host)
+	if err != nil {
+		return false, fmt.Errorf("host %s: %w", h.ID, err)
+	}
+	return script.Truth(v), nil
+}
+
+// scriptVars returns the vars computed by the vars_ hooks for a host, later hooks
+// overriding earlier ones. Caller must hold the lock.
+func (m *Manager) scriptVars(h *Host) (map[string]string, error) {
+	names := m.scripts.Functions(VarsPrefix)
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	host, err := m.hostValue(h)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string)
+	for _, name := range names {
+		v, err := m.scripts.Call(name, host)
+		if err != nil {
+			return nil, fmt.Errorf("host %s: %w", h.ID, err)
+		}
+		if v == script.None {
+			continue
+		}
+		d, ok := v.(*script.Dict)
+		if !ok {
+			return nil, fmt.Errorf("host %s: %s returned %s, not a dict", h.ID, name, script.TypeName(v))
+		}
+		for _, item := range d.Items() {
+			vars[script.String(item[0])] = script.String(item[1])
+		}
+	}
+	return vars, nil
+}
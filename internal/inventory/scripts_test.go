@@ -0,0 +1,133 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeScript(t *testing.T, dir, name, src string) {
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ScriptsDir), 0755))
+	writeTestFile(t, filepath.Join(dir, ScriptsDir), name, src)
+}
+
+func setupScriptManager(t *testing.T, src string) *Manager {
+	m, dir := setupTestManager(t)
+	writeTestFile(t, dir, "hosts.yaml", `type: group
+name: web
+host_ids: [web01, web02]
+vars:
+  region: eu
+  tier: frontend
+---
+type: host
+id: web01
+name: web01
+address: 10.0.0.1
+port: 22
+user: deploy
+tags: [env:prod]
+---
+type: host
+id: web02
+name: web02
+address: 10.0.0.2
+port: 2222
+user: deploy
+vars:
+  tier: edge
+`)
+	writeScript(t, dir, "hooks.star", src)
+	require.NoError(t, m.Load())
+	return m
+}
+
+func TestScriptFilters(t *testing.T) {
+	m := setupScriptManager(t, `
+def filter_custom_port(host):
+    return host.port != 22
+
+def filter_prod_web(host):
+    return "web" in host.groups and "env:prod" in host.tags
+
+def filter_broken(host):
+    return host.missing
+`)
+
+	t.Run("select hosts", func(t *testing.T) {
+		ids, err := m.ResolveTargetSpec("filter:custom_port")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web02"}, ids)
+
+		ids, err = m.ResolveTargetSpec("group:web - filter:prod_web")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web02"}, ids)
+	})
+
+	t.Run("unknown filter", func(t *testing.T) {
+		_, err := m.ResolveTargetSpec("filter:nope")
+		assert.ErrorContains(t, err, "unknown filter nope")
+	})
+
+	t.Run("script error", func(t *testing.T) {
+		_, err := m.ResolveTargetSpec("filter:broken")
+		assert.ErrorContains(t, err, "host web01")
+	})
+}
+
+func TestScriptVars(t *testing.T) {
+	t.Run("precedence", func(t *testing.T) {
+		m := setupScriptManager(t, `
+def vars_dynamic(host):
+    return {
+        "region": "us",
+        "tier": "computed",
+        "endpoint": host.address + ":" + str(host.port),
+    }
+`)
+
+		vars, err := m.ResolveVars("web02")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"region": "us", "tier": "edge", "endpoint": "10.0.0.2:2222"}, vars)
+	})
+
+	t.Run("none adds nothing", func(t *testing.T) {
+		m := setupScriptManager(t, `
+def vars_nothing(host):
+    return None
+`)
+
+		vars, err := m.ResolveVars("web01")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"region": "eu", "tier": "frontend"}, vars)
+	})
+
+	t.Run("not a dict", func(t *testing.T) {
+		m := setupScriptManager(t, `
+def vars_wrong(host):
+    return [host.id]
+`)
+
+		_, err := m.ResolveVars("web01")
+		assert.ErrorContains(t, err, "not a dict")
+	})
+}
+
+func TestLoadScripts(t *testing.T) {
+	t.Run("no scripts directory", func(t *testing.T) {
+		m, _ := setupTestManager(t)
+		require.NoError(t, m.Load())
+		assert.False(t, m.Scripts().Has(FilterPrefix+"any"))
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		m, dir := setupTestManager(t)
+		writeScript(t, dir, "bad.star", "def filter_bad(host) return True\n")
+		err := m.Load()
+		assert.ErrorContains(t, err, "failed to load scripts")
+		assert.ErrorContains(t, err, "bad.star:1")
+	})
+}
@@ -0,0 +1,275 @@
+package inventory
+
+import (
+	"fmt"
+)
+
+// Ensure SessionSet implements the interfaces
+var (
+	_ Entity = (*SessionSet)(nil)
+)
+
+// PaneLayout arranges the panes of a session set. The layouts are those of tmux,
+// so tmux integration can apply them as they are.
+type PaneLayout string
+
+const (
+	// LayoutTiled gives every pane the same size in rows and columns (the default).
+	LayoutTiled PaneLayout = "tiled"
+	// LayoutEvenHorizontal places the panes side by side.
+	LayoutEvenHorizontal PaneLayout = "even-horizontal"
+	// LayoutEvenVertical stacks the panes.
+	LayoutEvenVertical PaneLayout = "even-vertical"
+	// LayoutMainHorizontal shows the first pane large on top, the others below.
+	LayoutMainHorizontal PaneLayout = "main-horizontal"
+	// LayoutMainVertical shows the first pane large on the left, the others right.
+	LayoutMainVertical PaneLayout = "main-vertical"
+)
+
+// SessionSet is a saved set of interactive sessions that is reopened in one
+// action, e.g. "incident-response" opening the load balancer, two app servers and
+// the database with journalctl -f pre-typed.
+type SessionSet struct {
+	Type        DocumentType `yaml:"type"`
+	ID          string       `yaml:"id"`
+	Name        string       `yaml:"name"`
+	Description string       `yaml:"description,omitempty"`
+
+	// Layout arranges the panes (default LayoutTiled).
+	Layout PaneLayout `yaml:"layout,omitempty"`
+	// Broadcast starts with keystrokes sent to all unmuted panes.
+	Broadcast bool `yaml:"broadcast,omitempty"`
+
+	Panes []SessionPane `yaml:"panes"`
+}
+
+// SessionPane is one session of a set.
+type SessionPane struct {
+	HostID string `yaml:"host_id"`
+	// Title names the pane (default the host ID); titles are unique within a set.
+	Title string `yaml:"title,omitempty"`
+	// Command is typed into the session once it is open. It is only pre-typed,
+	// waiting for Enter, unless Run is set.
+	Command string `yaml:"command,omitempty"`
+	Run     bool   `yaml:"run,omitempty"`
+	// Muted keeps the pane out of broadcast input.
+	Muted bool `yaml:"muted,omitempty"`
+}
+
+// PaneTitle returns the title of the pane, the host ID when unset.
+func (p *SessionPane) PaneTitle() string {
+	if p.Title != "" {
+		return p.Title
+	}
+	return p.HostID
+}
+
+// NewSessionSet creates a new SessionSet.
+func NewSessionSet(id, name string) *SessionSet {
+	return &SessionSet{
+		Type: TypeSessionSet,
+		ID:   id,
+		Name: name,
+	}
+}
+
+// GetID Identifiable interface implementation
+func (s *SessionSet) GetID() string {
+	return s.ID
+}
+
+// GetName Nameable interface implementation
+func (s *SessionSet) GetName() string {
+	return s.Name
+}
+
+func (s *SessionSet) SetName(name string) {
+	s.Name = name
+}
+
+// GetDescription Describable interface implementation
+func (s *SessionSet) GetDescription() string {
+	return s.Description
+}
+
+func (s *SessionSet) SetDescription(desc string) {
+	s.Description = desc
+}
+
+// AddPane appends a pane.
+func (s *SessionSet) AddPane(p SessionPane) {
+	s.Panes = append(s.Panes, p)
+}
+
+// PaneLayout returns the layout of the set, LayoutTiled when unset.
+func (s *SessionSet) PaneLayout() PaneLayout {
+	if s.Layout == "" {
+		return LayoutTiled
+	}
+	return s.Layout
+}
+
+// Validate checks if the SessionSet has valid configuration.
+func (s *SessionSet) Validate() error {
+	if s.ID == "" {
+		return fmt.Errorf("session set ID cannot be empty")
+	}
+	if s.Name == "" {
+		return fmt.Errorf("session set %s: name cannot be empty", s.ID)
+	}
+	switch s.Layout {
+	case "", LayoutTiled, LayoutEvenHorizontal, LayoutEvenVertical, LayoutMainHorizontal, LayoutMainVertical:
+	default:
+		return fmt.Errorf("session set %s: invalid layout: %s", s.ID, s.Layout)
+	}
+	if len(s.Panes) == 0 {
+		return fmt.Errorf("session set %s: must have at least one pane", s.ID)
+	}
+
+	titles := make(map[string]bool, len(s.Panes))
+	for i, p := range s.Panes {
+		if p.HostID == "" {
+			return fmt.Errorf("session set %s: pane %d has no host", s.ID, i+1)
+		}
+		if titles[p.PaneTitle()] {
+			return fmt.Errorf("session set %s: duplicate pane title %s", s.ID, p.PaneTitle())
+		}
+		titles[p.PaneTitle()] = true
+		if p.Run && p.Command == "" {
+			return fmt.Errorf("session set %s: pane %s runs no command", s.ID, p.PaneTitle())
+		}
+	}
+	return nil
+}
+
+// Clone creates a deep copy of the SessionSet.
+func (s *SessionSet) Clone() interface{} {
+	clone := *s
+	clone.Panes = append([]SessionPane(nil), s.Panes...)
+	return &clone
+}
+
+// ===== Manager =====
+
+// GetSessionSet returns a copy of the session set with the given ID.
+func (m *Manager) GetSessionSet(id string) (*SessionSet, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.sessionSets[id]
+	if !ok {
+		return nil, false
+	}
+	return s.Clone().(*SessionSet), true
+}
+
+// ListSessionSets returns copies of all session sets sorted by ID.
+func (m *Manager) ListSessionSets() []*SessionSet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sets := make([]*SessionSet, 0, len(m.sessionSets))
+	for _, id := range sortedKeys(m.sessionSets) {
+		sets = append(sets, m.sessionSets[id].Clone().(*SessionSet))
+	}
+	return sets
+}
+
+// AddSessionSet validates and stores a new session set. The ID policy may rewrite s.ID.
+func (m *Manager) AddSessionSet(s *SessionSet) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, err := m.checkNewID(TypeSessionSet, s.ID)
+	if err != nil {
+		return err
+	}
+	s.ID = id
+
+	if _, exists := m.sessionSets[s.ID]; exists {
+		return fmt.Errorf("session set %s already exists", s.ID)
+	}
+	if err := m.checkSessionSetRefs(s); err != nil {
+		return err
+	}
+
+	stored := s.Clone().(*SessionSet)
+	stored.Type = TypeSessionSet
+	return m.store(stored)
+}
+
+// UpdateSessionSet replaces an existing session set and rewrites its file.
+func (m *Manager) UpdateSessionSet(s *SessionSet) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.sessionSets[s.ID]; !exists {
+		return fmt.Errorf("session set %s not found", s.ID)
+	}
+	if err := m.checkSessionSetRefs(s); err != nil {
+		return err
+	}
+
+	stored := s.Clone().(*SessionSet)
+	stored.Type = TypeSessionSet
+	m.sessionSets[s.ID] = stored
+	return m.saveFile(m.sources[entityKey{TypeSessionSet, s.ID}])
+}
+
+// RemoveSessionSet deletes a session set.
+func (m *Manager) RemoveSessionSet(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.sessionSets[id]; !exists {
+		return fmt.Errorf("session set %s not found", id)
+	}
+	return m.saveFile(m.unregister(entityKey{TypeSessionSet, id}))
+}
+
+// checkSessionSetRefs verifies that the hosts of a session set exist and are
+// reached over SSH. Caller must hold the lock.
+func (m *Manager) checkSessionSetRefs(s *SessionSet) error {
+	for _, p := range s.Panes {
+		h, ok := m.hosts[p.HostID]
+		if !ok {
+			return fmt.Errorf("session set %s: host %s not found", s.ID, p.HostID)
+		}
+		if h.IsLocal() {
+			return fmt.Errorf("session set %s: local host %s has no session", s.ID, p.HostID)
+		}
+	}
+	return nil
+}
+
+// updateSessionSetRefs renames the host of panes, or drops the panes when newID is
+// empty. Sets left without panes are kept for the user to fix or remove. Caller
+// must hold the lock.
+func (m *Manager) updateSessionSetRefs(oldID, newID string, dirty map[string]bool) {
+	for _, s := range m.sessionSets {
+		kept := s.Panes[:0]
+		changed := false
+		for _, p := range s.Panes {
+			if p.HostID == oldID {
+				changed = true
+				if newID == "" {
+					continue
+				}
+				p.HostID = newID
+			}
+			kept = append(kept, p)
+		}
+		s.Panes = kept
+		if changed {
+			dirty[m.sources[keyOf(s)]] = true
+		}
+	}
+}
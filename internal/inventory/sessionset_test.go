@@ -0,0 +1,92 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIncidentSet() *SessionSet {
+	s := NewSessionSet("incident-response", "Incident response")
+	s.Layout = LayoutMainVertical
+	s.Broadcast = true
+	s.AddPane(SessionPane{HostID: "lb01"})
+	s.AddPane(SessionPane{HostID: "app01", Command: "journalctl -f"})
+	s.AddPane(SessionPane{HostID: "app02", Command: "journalctl -f"})
+	s.AddPane(SessionPane{HostID: "db01", Title: "db", Muted: true})
+	return s
+}
+
+func TestSessionSets(t *testing.T) {
+	m, dir := setupTestManager(t)
+	for _, id := range []string{"lb01", "app01", "app02", "db01"} {
+		h := NewHost(id, id, id+".example.com")
+		h.User = "ops"
+		require.NoError(t, m.AddHost(h))
+	}
+
+	t.Run("stores and reloads sets", func(t *testing.T) {
+		require.NoError(t, m.AddSessionSet(newIncidentSet()))
+		assert.Error(t, m.AddSessionSet(newIncidentSet()))
+
+		reloaded := NewManager(dir)
+		require.NoError(t, reloaded.Load())
+		s, ok := reloaded.GetSessionSet("incident-response")
+		require.True(t, ok)
+		assert.Equal(t, newIncidentSet(), s)
+		assert.Len(t, reloaded.ListSessionSets(), 1)
+	})
+
+	t.Run("follows renamed and removed hosts", func(t *testing.T) {
+		require.NoError(t, m.RenameHost("app02", "app03"))
+		require.NoError(t, m.RemoveHost("lb01"))
+
+		s, _ := m.GetSessionSet("incident-response")
+		hosts := make([]string, len(s.Panes))
+		for i, p := range s.Panes {
+			hosts[i] = p.HostID
+		}
+		assert.Equal(t, []string{"app01", "app03", "db01"}, hosts)
+	})
+
+	t.Run("rejects unknown and local hosts", func(t *testing.T) {
+		s := NewSessionSet("bad", "Bad")
+		s.AddPane(SessionPane{HostID: "nope"})
+		assert.ErrorContains(t, m.AddSessionSet(s), "host nope not found")
+
+		require.NoError(t, m.AddHost(NewLocalHost("build", "build")))
+		s.Panes[0].HostID = "build"
+		assert.ErrorContains(t, m.AddSessionSet(s), "local host build")
+	})
+
+	t.Run("updates and removes sets", func(t *testing.T) {
+		s, _ := m.GetSessionSet("incident-response")
+		s.Layout = LayoutTiled
+		require.NoError(t, m.UpdateSessionSet(s))
+		got, _ := m.GetSessionSet("incident-response")
+		assert.Equal(t, LayoutTiled, got.PaneLayout())
+
+		require.NoError(t, m.RemoveSessionSet("incident-response"))
+		assert.Error(t, m.RemoveSessionSet("incident-response"))
+		assert.Empty(t, m.ListSessionSets())
+	})
+}
+
+func TestSessionSetValidate(t *testing.T) {
+	assert.NoError(t, newIncidentSet().Validate())
+
+	for name, edit := range map[string]func(s *SessionSet){
+		"no ID":           func(s *SessionSet) { s.ID = "" },
+		"no name":         func(s *SessionSet) { s.Name = "" },
+		"layout":          func(s *SessionSet) { s.Layout = "grid" },
+		"no panes":        func(s *SessionSet) { s.Panes = nil },
+		"pane host":       func(s *SessionSet) { s.Panes[0].HostID = "" },
+		"duplicate title": func(s *SessionSet) { s.Panes[3].Title = "app01" },
+		"run nothing":     func(s *SessionSet) { s.Panes[0].Run = true },
+	} {
+		s := newIncidentSet()
+		edit(s)
+		assert.Error(t, s.Validate(), name)
+	}
+}
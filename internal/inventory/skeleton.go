@@ -0,0 +1,270 @@
+package inventory
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SkeletonVersion is the version of the skeleton format written by ExportSkeleton.
+const SkeletonVersion = 1
+
+// PlaceholderKind is what a placeholder of a skeleton stands for.
+type PlaceholderKind string
+
+const (
+	// PlaceholderCredential stands for a credential of the receiver.
+	PlaceholderCredential PlaceholderKind = "credential"
+	// PlaceholderKey stands for a private key file of the receiver.
+	PlaceholderKey PlaceholderKind = "key"
+)
+
+// secretVarPattern matches the names of vars holding secrets, which are left out
+// of skeletons.
+var secretVarPattern = regexp.MustCompile(`(?i)(password|passwd|passphrase|secret|token|api[_-]?key)`)
+
+// placeholderPattern matches a placeholder written into a skeleton field.
+var placeholderPattern = regexp.MustCompile(`^\{\{(credential|key):([^{}]+)\}\}$`)
+
+// Placeholder is a reference to a secret of the sender, replaced in a skeleton so
+// that the receiver maps it to their own credential or key file.
+type Placeholder struct {
+	// Name is the placeholder as written in the skeleton, e.g. "{{credential:ops}}".
+	Name string          `yaml:"name" json:"name"`
+	Kind PlaceholderKind `yaml:"kind" json:"kind"`
+	// Description says what the sender used, e.g. "credential Ops (key, user deploy)".
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	// Hosts are the hosts using the placeholder.
+	Hosts []string `yaml:"hosts" json:"hosts"`
+}
+
+// Skeleton is a shareable copy of the hosts and groups of an inventory without
+// any secret: passwords and passphrases are removed, credentials and key paths are
+// replaced with placeholders, and vars that look like secrets are dropped.
+type Skeleton struct {
+	Version      int           `yaml:"version" json:"version"`
+	Placeholders []Placeholder `yaml:"placeholders,omitempty" json:"placeholders,omitempty"`
+	Hosts        []*Host       `yaml:"hosts" json:"hosts"`
+	Groups       []*Group      `yaml:"groups,omitempty" json:"groups,omitempty"`
+}
+
+// ExportSkeleton builds a secrets-free skeleton of the inventory.
+func (m *Manager) ExportSkeleton() *Skeleton {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s := &Skeleton{Version: SkeletonVersion}
+	placeholders := make(map[string]*Placeholder)
+	use := func(kind PlaceholderKind, ref, description, hostID string) string {
+		name := "{{" + string(kind) + ":" + ref + "}}"
+		p, ok := placeholders[name]
+		if !ok {
+			p = &Placeholder{Name: name, Kind: kind, Description: description}
+			placeholders[name] = p
+		}
+		p.Hosts = append(p.Hosts, hostID)
+		return name
+	}
+
+	for _, id := range sortedKeys(m.hosts) {
+		h := m.hosts[id].Clone().(*Host)
+		h.Password = ""
+		h.Facts = nil
+		h.FactsUpdated = time.Time{}
+		h.LastConnected = time.Time{}
+		h.GatewayURL = stripURLPassword(h.GatewayURL)
+		h.Vars = publicVars(h.Vars)
+
+		if h.CredentialID != "" {
+			description := "credential " + h.CredentialID
+			if c, ok := m.credentials[h.CredentialID]; ok {
+				description = fmt.Sprintf("credential %s (%s, user %s)", c.Name, credentialKind(c), c.User)
+			}
+			h.CredentialID = use(PlaceholderCredential, h.CredentialID, description, h.ID)
+		}
+		if h.KeyPath != "" {
+			h.KeyPath = use(PlaceholderKey, h.ID, "key of host "+h.ID, h.ID)
+		}
+		s.Hosts = append(s.Hosts, h)
+	}
+
+	for _, name := range sortedKeys(m.groups) {
+		g := m.groups[name].Clone().(*Group)
+		g.Vars = publicVars(g.Vars)
+		s.Groups = append(s.Groups, g)
+	}
+
+	for _, name := range sortedKeys(placeholders) {
+		s.Placeholders = append(s.Placeholders, *placeholders[name])
+	}
+	return s
+}
+
+// credentialKind describes how a credential authenticates, without its secrets.
+func credentialKind(c *Credential) string {
+	switch {
+	case c.Provider != "":
+		return c.Provider
+	case c.KeyPath != "":
+		return "key"
+	default:
+		return "password"
+	}
+}
+
+// publicVars returns vars without those named like secrets.
+func publicVars(vars map[string]string) map[string]string {
+	public := make(map[string]string, len(vars))
+	for k, v := range vars {
+		if !secretVarPattern.MatchString(k) {
+			public[k] = v
+		}
+	}
+	return public
+}
+
+// stripURLPassword removes the password of a URL, keeping the user.
+func stripURLPassword(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	if _, ok := u.User.Password(); !ok {
+		return raw
+	}
+	u.User = url.User(u.User.Username())
+	return u.String()
+}
+
+// Marshal renders the skeleton as YAML.
+func (s *Skeleton) Marshal() ([]byte, error) {
+	return yaml.Marshal(s)
+}
+
+// ParseSkeleton parses a skeleton written by Marshal.
+func ParseSkeleton(data []byte) (*Skeleton, error) {
+	var s Skeleton
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse skeleton: %w", err)
+	}
+	if s.Version != SkeletonVersion {
+		return nil, fmt.Errorf("unsupported skeleton version %d", s.Version)
+	}
+	return &s, nil
+}
+
+// SkeletonImportOptions controls ImportSkeleton.
+type SkeletonImportOptions struct {
+	// Mapping maps placeholders to the receiver's values: a credential ID for
+	// credential placeholders, a key file path for key placeholders. A credential
+	// placeholder without mapping uses the receiver's credential of the same ID.
+	Mapping map[string]string
+	// Overwrite replaces existing hosts and groups instead of skipping them.
+	Overwrite bool
+}
+
+// SkeletonImportResult reports what ImportSkeleton did.
+type SkeletonImportResult struct {
+	AddedHosts    []string `json:"added_hosts,omitempty"`
+	UpdatedHosts  []string `json:"updated_hosts,omitempty"`
+	SkippedHosts  []string `json:"skipped_hosts,omitempty"`
+	AddedGroups   []string `json:"added_groups,omitempty"`
+	UpdatedGroups []string `json:"updated_groups,omitempty"`
+	SkippedGroups []string `json:"skipped_groups,omitempty"`
+}
+
+// ImportSkeleton adds the hosts and groups of a skeleton, replacing its
+// placeholders with the receiver's credentials and key files. Nothing is imported
+// unless every placeholder can be resolved.
+func (m *Manager) ImportSkeleton(s *Skeleton, opts SkeletonImportOptions) (*SkeletonImportResult, error) {
+	hosts := make([]*Host, 0, len(s.Hosts))
+	var unresolved []string
+	resolve := func(value string, kind PlaceholderKind) string {
+		match := placeholderPattern.FindStringSubmatch(value)
+		if match == nil || PlaceholderKind(match[1]) != kind {
+			return value
+		}
+		if mapped, ok := opts.Mapping[value]; ok && mapped != "" {
+			return mapped
+		}
+		if kind == PlaceholderCredential {
+			if _, ok := m.GetCredential(match[2]); ok {
+				return match[2]
+			}
+		}
+		unresolved = append(unresolved, value)
+		return value
+	}
+	for _, h := range s.Hosts {
+		h = h.Clone().(*Host)
+		h.Type = TypeHost
+		h.CredentialID = resolve(h.CredentialID, PlaceholderCredential)
+		h.KeyPath = resolve(h.KeyPath, PlaceholderKey)
+		if h.CredentialID != "" && !placeholderPattern.MatchString(h.CredentialID) {
+			if _, ok := m.GetCredential(h.CredentialID); !ok {
+				return nil, fmt.Errorf("host %s: credential %s not found", h.ID, h.CredentialID)
+			}
+		}
+		hosts = append(hosts, h)
+	}
+	if len(unresolved) > 0 {
+		sort.Strings(unresolved)
+		return nil, fmt.Errorf("unresolved placeholders: %s", strings.Join(slices.Compact(unresolved), ", "))
+	}
+
+	result := &SkeletonImportResult{}
+
+	// Hosts are added without their references to other hosts first, so that jump
+	// and relay hosts and relations resolve whatever the order.
+	var linked []*Host
+	for _, h := range hosts {
+		_, exists := m.GetHost(h.ID)
+		if exists && !opts.Overwrite {
+			result.SkippedHosts = append(result.SkippedHosts, h.ID)
+			continue
+		}
+		if exists {
+			result.UpdatedHosts = append(result.UpdatedHosts, h.ID)
+		} else {
+			bare := h.Clone().(*Host)
+			bare.JumpHostID, bare.RelayHostID, bare.Relations = "", "", nil
+			if err := m.AddHost(bare); err != nil {
+				return result, err
+			}
+			result.AddedHosts = append(result.AddedHosts, h.ID)
+		}
+		linked = append(linked, h)
+	}
+	for _, h := range linked {
+		if err := m.UpdateHost(h); err != nil {
+			return result, err
+		}
+	}
+
+	for _, g := range s.Groups {
+		g = g.Clone().(*Group)
+		g.Type = TypeGroup
+		_, exists := m.GetGroup(g.Name)
+		switch {
+		case exists && !opts.Overwrite:
+			result.SkippedGroups = append(result.SkippedGroups, g.Name)
+		case exists:
+			if err := m.UpdateGroup(g); err != nil {
+				return result, err
+			}
+			result.UpdatedGroups = append(result.UpdatedGroups, g.Name)
+		default:
+			if err := m.AddGroup(g); err != nil {
+				return result, err
+			}
+			result.AddedGroups = append(result.AddedGroups, g.Name)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,46 @@
+package inventory
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Snapshot selects the state captured on each target before and after a risky
+// command or workflow, so that the run record shows what changed: checksums of
+// files and directory trees, and the states of systemd services.
+type Snapshot struct {
+	// Paths are absolute paths of files or directories.
+	Paths []string `yaml:"paths,omitempty"`
+	// Services are systemd units, e.g. "nginx".
+	Services []string `yaml:"services,omitempty"`
+}
+
+// Validate checks that the snapshot captures something and that its paths are
+// absolute.
+func (s *Snapshot) Validate() error {
+	if len(s.Paths) == 0 && len(s.Services) == 0 {
+		return fmt.Errorf("snapshot needs paths or services")
+	}
+	for _, p := range s.Paths {
+		if !strings.HasPrefix(p, "/") || strings.ContainsAny(p, "\t\n\r") {
+			return fmt.Errorf("invalid snapshot path %q: paths must be absolute", p)
+		}
+	}
+	for _, name := range s.Services {
+		if name == "" || strings.ContainsAny(name, " \t\n\r/") {
+			return fmt.Errorf("invalid snapshot service %q", name)
+		}
+	}
+	return nil
+}
+
+// Clone returns a deep copy of the snapshot, nil for nil.
+func (s *Snapshot) Clone() *Snapshot {
+	if s == nil {
+		return nil
+	}
+	return &Snapshot{
+		Paths:    append([]string(nil), s.Paths...),
+		Services: append([]string(nil), s.Services...),
+	}
+}
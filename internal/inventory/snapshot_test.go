@@ -0,0 +1,52 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	m, dir := setupTestManager(t)
+
+	cmd := NewSavedCommand("upgrade", "Upgrade", "apt-get -y upgrade")
+	cmd.Snapshot = &Snapshot{Paths: []string{"/etc/nginx", "/etc/hosts"}, Services: []string{"nginx"}}
+	require.NoError(t, m.AddCommand(cmd))
+
+	reloaded := NewManager(dir)
+	require.NoError(t, reloaded.Load())
+	got, ok := reloaded.GetCommand("upgrade")
+	require.True(t, ok)
+	require.NotNil(t, got.Snapshot)
+	assert.Equal(t, []string{"/etc/nginx", "/etc/hosts"}, got.Snapshot.Paths)
+	assert.Equal(t, []string{"nginx"}, got.Snapshot.Services)
+
+	got.Snapshot.Paths[0] = "/tmp"
+	again, _ := reloaded.GetCommand("upgrade")
+	assert.Equal(t, "/etc/nginx", again.Snapshot.Paths[0], "returned commands are copies")
+
+	t.Run("validation", func(t *testing.T) {
+		for _, s := range []*Snapshot{
+			{},
+			{Paths: []string{"etc/nginx"}},
+			{Paths: []string{"/etc/a\nb"}},
+			{Services: []string{"nginx reload"}},
+			{Services: []string{""}},
+		} {
+			bad := NewSavedCommand("bad", "Bad", "true")
+			bad.Snapshot = s
+			assert.Error(t, m.AddCommand(bad), "%+v", s)
+		}
+
+		w := NewWorkflow("bad", "Bad")
+		w.Target = "host:web01"
+		w.AddStep(WorkflowStep{Name: "run", Kind: StepExec, Command: "true"})
+		w.Snapshot = &Snapshot{Paths: []string{"relative"}}
+		assert.Error(t, m.AddWorkflow(w))
+		w.Snapshot = &Snapshot{Services: []string{"nginx"}}
+		assert.NoError(t, m.AddWorkflow(w))
+	})
+
+	assert.Nil(t, (*Snapshot)(nil).Clone())
+}
@@ -0,0 +1,326 @@
+package inventory
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxSSHConfigIncludeDepth bounds nested Include directives, like OpenSSH.
+const maxSSHConfigIncludeDepth = 16
+
+// SSHConfigHost is a concrete host alias of an OpenSSH client configuration with the
+// options that apply to it.
+type SSHConfigHost struct {
+	Alias    string `json:"alias"`
+	HostName string `json:"host_name"`
+	User     string `json:"user,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	// IdentityFile is the first identity file, as written.
+	IdentityFile string `json:"identity_file,omitempty"`
+	// ProxyJump is the jump host specification, as written.
+	ProxyJump string `json:"proxy_jump,omitempty"`
+}
+
+// sshConfigBlock is a Host block; options keep the first value of each keyword.
+type sshConfigBlock struct {
+	patterns []string
+	// match is set for Match blocks, whose conditions are not evaluated.
+	match   bool
+	options map[string]string
+}
+
+// DefaultSSHConfigPath returns ~/.ssh/config.
+func DefaultSSHConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".ssh", "config")
+	}
+	return filepath.Join(home, ".ssh", "config")
+}
+
+// ParseSSHConfig reads an OpenSSH client configuration and returns its concrete host
+// aliases (those without wildcards) in order of appearance. Options are looked up
+// like ssh does: the first value from any matching Host block wins, so "Host *"
+// blocks supply defaults. Match blocks are ignored and relative Include paths are
+// resolved against ~/.ssh.
+func ParseSSHConfig(r io.Reader) ([]SSHConfigHost, error) {
+	blocks, err := parseSSHConfigBlocks(r, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []SSHConfigHost
+	seen := map[string]bool{}
+	for _, b := range blocks {
+		for _, alias := range b.patterns {
+			if seen[alias] || strings.ContainsAny(alias, "*?!") {
+				continue
+			}
+			seen[alias] = true
+			h, err := resolveSSHConfigHost(blocks, alias)
+			if err != nil {
+				return nil, err
+			}
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts, nil
+}
+
+// parseSSHConfigBlocks splits a configuration into blocks. Options before the first
+// Host line apply to every host.
+func parseSSHConfigBlocks(r io.Reader, depth int) ([]sshConfigBlock, error) {
+	blocks := []sshConfigBlock{{patterns: []string{"*"}, options: map[string]string{}}}
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		keyword, args := splitSSHConfigLine(line)
+		switch keyword {
+		case "host":
+			blocks = append(blocks, sshConfigBlock{patterns: strings.Fields(args), options: map[string]string{}})
+		case "match":
+			blocks = append(blocks, sshConfigBlock{match: true, options: map[string]string{}})
+		case "include":
+			if depth >= maxSSHConfigIncludeDepth {
+				return nil, fmt.Errorf("ssh config line %d: too many nested includes", lineNo)
+			}
+			included, err := includeSSHConfig(args, depth)
+			if err != nil {
+				return nil, fmt.Errorf("ssh config line %d: %w", lineNo, err)
+			}
+			// Options after the Include belong to the including block again.
+			current := blocks[len(blocks)-1]
+			blocks = append(append(blocks, included...), current)
+		default:
+			if args == "" {
+				return nil, fmt.Errorf("ssh config line %d: %s has no value", lineNo, keyword)
+			}
+			current := blocks[len(blocks)-1]
+			if _, ok := current.options[keyword]; !ok {
+				current.options[keyword] = args
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ssh config: %w", err)
+	}
+	return blocks, nil
+}
+
+// splitSSHConfigLine returns the lower-cased keyword and the arguments of a line.
+// Keywords are separated from their arguments by whitespace or "=".
+func splitSSHConfigLine(line string) (string, string) {
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return strings.ToLower(line), ""
+	}
+	args := strings.TrimLeft(line[i:], " \t")
+	args = strings.TrimSpace(strings.TrimPrefix(args, "="))
+	return strings.ToLower(line[:i]), strings.Trim(args, `"`)
+}
+
+// includeSSHConfig parses the files matched by the patterns of an Include directive.
+func includeSSHConfig(args string, depth int) ([]sshConfigBlock, error) {
+	var blocks []sshConfigBlock
+	for _, pattern := range strings.Fields(args) {
+		pattern = ExpandPath(pattern)
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(DefaultSSHConfigPath()), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include %q: %w", pattern, err)
+		}
+		for _, path := range matches {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open include: %w", err)
+			}
+			included, err := parseSSHConfigBlocks(f, depth+1)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			blocks = append(blocks, included...)
+		}
+	}
+	return blocks, nil
+}
+
+// resolveSSHConfigHost collects the options of alias from the blocks matching it.
+func resolveSSHConfigHost(blocks []sshConfigBlock, alias string) (SSHConfigHost, error) {
+	options := map[string]string{}
+	for _, b := range blocks {
+		if b.match || !matchSSHConfigPatterns(b.patterns, alias) {
+			continue
+		}
+		for k, v := range b.options {
+			if _, ok := options[k]; !ok {
+				options[k] = v
+			}
+		}
+	}
+
+	h := SSHConfigHost{
+		Alias:        alias,
+		HostName:     alias,
+		User:         options["user"],
+		IdentityFile: options["identityfile"],
+		ProxyJump:    options["proxyjump"],
+	}
+	if name := options["hostname"]; name != "" {
+		h.HostName = strings.ReplaceAll(name, "%h", alias)
+	}
+	if port := options["port"]; port != "" {
+		n, err := strconv.Atoi(port)
+		if err != nil || n <= 0 || n > 65535 {
+			return h, fmt.Errorf("ssh config: host %s: invalid port %q", alias, port)
+		}
+		h.Port = n
+	}
+	if strings.EqualFold(h.ProxyJump, "none") {
+		h.ProxyJump = ""
+	}
+	return h, nil
+}
+
+// matchSSHConfigPatterns reports whether alias matches a Host line: at least one
+// pattern matches and no negated one does.
+func matchSSHConfigPatterns(patterns []string, alias string) bool {
+	matched := false
+	for _, p := range patterns {
+		negate := strings.HasPrefix(p, "!")
+		if matchKnownHostPattern(strings.TrimPrefix(p, "!"), alias) {
+			if negate {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
+
+// ===== Import =====
+
+// SSHConfigImport reports the outcome of ImportSSHConfig.
+type SSHConfigImport struct {
+	// Added lists the IDs of the new hosts.
+	Added []string `json:"added"`
+	// Skipped lists aliases of hosts that already exist.
+	Skipped []string `json:"skipped"`
+	// UnresolvedJumps maps host IDs to ProxyJump specifications that name no
+	// inventory host; those hosts were added without a jump host.
+	UnresolvedJumps map[string]string `json:"unresolved_jumps,omitempty"`
+}
+
+// ImportSSHConfig adds a host for every concrete alias of an OpenSSH client
+// configuration (DefaultSSHConfigPath when empty). Hosts without a User get the
+// login of the current user, like ssh. A ProxyJump naming a single inventory host
+// becomes the jump host.
+func (m *Manager) ImportSSHConfig(sshConfigPath string) (*SSHConfigImport, error) {
+	if sshConfigPath == "" {
+		sshConfigPath = DefaultSSHConfigPath()
+	}
+
+	f, err := os.Open(sshConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ssh config: %w", err)
+	}
+	defer f.Close()
+
+	entries, err := ParseSSHConfig(f)
+	if err != nil {
+		return nil, err
+	}
+
+	login := ""
+	if u, err := user.Current(); err == nil {
+		login = u.Username
+	}
+
+	result := &SSHConfigImport{Added: []string{}, Skipped: []string{}}
+	ids := map[string]string{}
+	jumps := map[string]string{}
+	for _, e := range entries {
+		if id, ok := m.existingHostID(e.Alias); ok {
+			result.Skipped = append(result.Skipped, e.Alias)
+			ids[e.Alias] = id
+			continue
+		}
+
+		h := NewHost(e.Alias, e.Alias, e.HostName)
+		h.User = e.User
+		if h.User == "" {
+			h.User = login
+		}
+		if e.Port != 0 {
+			h.Port = e.Port
+		}
+		h.KeyPath = e.IdentityFile
+		if err := m.AddHost(h); err != nil {
+			return result, fmt.Errorf("failed to import %s: %w", e.Alias, err)
+		}
+		ids[e.Alias] = h.ID
+		result.Added = append(result.Added, h.ID)
+		if e.ProxyJump != "" {
+			jumps[h.ID] = e.ProxyJump
+		}
+	}
+
+	// Jump hosts are set once every alias exists.
+	for _, id := range sortedKeys(jumps) {
+		spec := jumps[id]
+		jumpID, ok := ids[spec]
+		if !ok {
+			if _, exists := m.GetHost(spec); exists {
+				jumpID, ok = spec, true
+			}
+		}
+		if !ok || jumpID == id {
+			if result.UnresolvedJumps == nil {
+				result.UnresolvedJumps = map[string]string{}
+			}
+			result.UnresolvedJumps[id] = spec
+			continue
+		}
+		h, _ := m.GetHost(id)
+		h.JumpHostID = jumpID
+		if err := m.UpdateHost(h); err != nil {
+			return result, fmt.Errorf("failed to set jump host of %s: %w", id, err)
+		}
+	}
+	return result, nil
+}
+
+// existingHostID returns the ID of the host an imported alias collides with: the
+// same ID or, unless the ID policy is none, the same normalized ID.
+func (m *Manager) existingHostID(alias string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.hosts[alias]; ok {
+		return alias, true
+	}
+	if m.idPolicy == "" || m.idPolicy == IDPolicyNone {
+		return "", false
+	}
+	normalized := NormalizeID(alias)
+	for _, id := range sortedKeys(m.hosts) {
+		if NormalizeID(id) == normalized {
+			return id, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,119 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSSHConfig = `# personal hosts
+User fallback
+
+Host web01 web02
+    HostName %h.example.com
+    Port 2222
+
+Host bastion
+    HostName=192.0.2.1
+    User jump
+    IdentityFile ~/.ssh/jump_key
+
+Host db01
+    HostName 10.0.0.5
+    ProxyJump bastion
+    User dba
+
+Host *.internal !skip.internal
+    Port 2200
+
+Match host db01
+    User ignored
+
+Host *
+    User everyone
+    Port 22
+`
+
+func TestParseSSHConfig(t *testing.T) {
+	t.Run("resolves aliases like ssh", func(t *testing.T) {
+		hosts, err := ParseSSHConfig(strings.NewReader(testSSHConfig))
+		require.NoError(t, err)
+		require.Len(t, hosts, 4)
+
+		assert.Equal(t, SSHConfigHost{Alias: "web01", HostName: "web01.example.com", User: "fallback", Port: 2222}, hosts[0])
+		assert.Equal(t, "web02.example.com", hosts[1].HostName)
+		assert.Equal(t, SSHConfigHost{Alias: "bastion", HostName: "192.0.2.1", User: "fallback", Port: 22, IdentityFile: "~/.ssh/jump_key"}, hosts[2])
+		assert.Equal(t, SSHConfigHost{Alias: "db01", HostName: "10.0.0.5", User: "fallback", Port: 22, ProxyJump: "bastion"}, hosts[3])
+	})
+
+	t.Run("first value of a Host block wins over later blocks", func(t *testing.T) {
+		hosts, err := ParseSSHConfig(strings.NewReader("Host a\n  User first\nHost *\n  User second\n"))
+		require.NoError(t, err)
+		require.Len(t, hosts, 1)
+		assert.Equal(t, "first", hosts[0].User)
+	})
+
+	t.Run("applies negated patterns", func(t *testing.T) {
+		hosts, err := ParseSSHConfig(strings.NewReader("Host app.internal skip.internal\nHost *.internal !skip.internal\n  Port 2200\n"))
+		require.NoError(t, err)
+		require.Len(t, hosts, 2)
+		assert.Equal(t, 2200, hosts[0].Port)
+		assert.Equal(t, 0, hosts[1].Port)
+	})
+
+	t.Run("follows includes", func(t *testing.T) {
+		dir := t.TempDir()
+		included := filepath.Join(dir, "work.conf")
+		require.NoError(t, os.WriteFile(included, []byte("Host work\n  User worker\n"), 0o600))
+
+		hosts, err := ParseSSHConfig(strings.NewReader("Host home\nInclude " + filepath.Join(dir, "*.conf") + "\n  User homer\n"))
+		require.NoError(t, err)
+		require.Len(t, hosts, 2)
+		assert.Equal(t, "homer", hosts[0].User)
+		assert.Equal(t, "worker", hosts[1].User)
+	})
+
+	t.Run("rejects invalid ports", func(t *testing.T) {
+		_, err := ParseSSHConfig(strings.NewReader("Host a\n  Port ssh\n"))
+		assert.ErrorContains(t, err, "invalid port")
+	})
+}
+
+func TestImportSSHConfig(t *testing.T) {
+	t.Run("adds hosts and their jump hosts", func(t *testing.T) {
+		m, _ := setupTestManager(t)
+		existing := NewHost("web02", "web02", "10.0.0.2")
+		existing.User = "deploy"
+		require.NoError(t, m.AddHost(existing))
+		path := filepath.Join(t.TempDir(), "config")
+		require.NoError(t, os.WriteFile(path, []byte(testSSHConfig+"\nHost app\n  ProxyJump user@gateway:2222\n"), 0o600))
+
+		result, err := m.ImportSSHConfig(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web01", "bastion", "db01", "app"}, result.Added)
+		assert.Equal(t, []string{"web02"}, result.Skipped)
+		assert.Equal(t, map[string]string{"app": "user@gateway:2222"}, result.UnresolvedJumps)
+
+		db, ok := m.GetHost("db01")
+		require.True(t, ok)
+		assert.Equal(t, "bastion", db.JumpHostID)
+		assert.Equal(t, "10.0.0.5", db.Address)
+
+		web, _ := m.GetHost("web01")
+		assert.Equal(t, 2222, web.Port)
+		assert.Equal(t, "fallback", web.User)
+
+		bastion, _ := m.GetHost("bastion")
+		assert.Equal(t, "~/.ssh/jump_key", bastion.KeyPath)
+	})
+
+	t.Run("fails on a missing file", func(t *testing.T) {
+		m, dir := setupTestManager(t)
+		_, err := m.ImportSSHConfig(filepath.Join(dir, "missing"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,211 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"gossher/internal/term"
+)
+
+// DefaultStaleFactsAge is how old gathered facts may get before a host is reported as stale.
+const DefaultStaleFactsAge = 30 * 24 * time.Hour
+
+// AuthMethod describes how a host authenticates, as reported in Stats.
+type AuthMethod string
+
+const (
+	AuthMethodCredentialKey      AuthMethod = "credential/key"
+	AuthMethodCredentialPassword AuthMethod = "credential/password"
+	AuthMethodCredentialMissing  AuthMethod = "credential/missing"
+	AuthMethodInlineKey          AuthMethod = "inline/key"
+	AuthMethodInlinePassword     AuthMethod = "inline/password"
+	AuthMethodInlineAgent        AuthMethod = "inline/agent"
+)
+
+// StatsOptions tunes how Stats classifies hosts.
+type StatsOptions struct {
+	// StaleAfter is the maximum age of gathered facts (default DefaultStaleFactsAge).
+	StaleAfter time.Duration
+	// Now overrides the reference time, mainly for tests.
+	Now time.Time
+}
+
+// Stats is a point-in-time report about the inventory, used for capacity and hygiene reviews.
+type Stats struct {
+	GeneratedAt time.Time `json:"generated_at"`
+
+	TotalHosts       int `json:"total_hosts"`
+	TotalGroups      int `json:"total_groups"`
+	TotalCredentials int `json:"total_credentials"`
+
+	HostsPerGroup      map[string]int     `json:"hosts_per_group"`
+	HostsPerTag        map[string]int     `json:"hosts_per_tag"`
+	HostsPerCredential map[string]int     `json:"hosts_per_credential"`
+	AuthMethods        map[AuthMethod]int `json:"auth_methods"`
+
+	UngroupedHosts    []string `json:"ungrouped_hosts"`
+	NeverConnected    []string `json:"never_connected"`
+	StaleFacts        []string `json:"stale_facts"`
+	UnusedCredentials []string `json:"unused_credentials"`
+}
+
+// Stats builds an inventory report with default options.
+func (m *Manager) Stats() *Stats {
+	return m.StatsWithOptions(StatsOptions{})
+}
+
+// StatsWithOptions builds an inventory report.
+func (m *Manager) StatsWithOptions(opts StatsOptions) *Stats {
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = DefaultStaleFactsAge
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s := &Stats{
+		GeneratedAt:        opts.Now,
+		TotalHosts:         len(m.hosts),
+		TotalGroups:        len(m.groups),
+		TotalCredentials:   len(m.credentials),
+		HostsPerGroup:      make(map[string]int),
+		HostsPerTag:        make(map[string]int),
+		HostsPerCredential: make(map[string]int),
+		AuthMethods:        make(map[AuthMethod]int),
+		UngroupedHosts:     []string{},
+		NeverConnected:     []string{},
+		StaleFacts:         []string{},
+		UnusedCredentials:  []string{},
+	}
+
+	grouped := map[string]bool{}
+	for _, name := range sortedKeys(m.groups) {
+		ids, _ := m.resolveGroupHosts(name)
+		s.HostsPerGroup[name] = len(ids)
+		for _, id := range ids {
+			grouped[id] = true
+		}
+	}
+
+	for _, id := range sortedKeys(m.hosts) {
+		h := m.hosts[id]
+
+		for _, tag := range h.Tags {
+			s.HostsPerTag[tag]++
+		}
+		if h.CredentialID != "" {
+			s.HostsPerCredential[h.CredentialID]++
+		}
+		s.AuthMethods[m.authMethod(h)]++
+
+		if !grouped[id] {
+			s.UngroupedHosts = append(s.UngroupedHosts, id)
+		}
+		if h.LastConnected.IsZero() {
+			s.NeverConnected = append(s.NeverConnected, id)
+		}
+		if h.FactsUpdated.IsZero() || opts.Now.Sub(h.FactsUpdated) > opts.StaleAfter {
+			s.StaleFacts = append(s.StaleFacts, id)
+		}
+	}
+
+	for _, id := range sortedKeys(m.credentials) {
+		if s.HostsPerCredential[id] == 0 {
+			s.UnusedCredentials = append(s.UnusedCredentials, id)
+		}
+	}
+
+	return s
+}
+
+// authMethod classifies the authentication used by a host. Caller must hold the lock.
+func (m *Manager) authMethod(h *Host) AuthMethod {
+	if h.UsesCredential() {
+		cred, ok := m.credentials[h.CredentialID]
+		if !ok {
+			return AuthMethodCredentialMissing
+		}
+		if cred.getCredentialType() == CredentialTypeKey {
+			return AuthMethodCredentialKey
+		}
+		return AuthMethodCredentialPassword
+	}
+
+	switch {
+	case h.KeyPath != "":
+		return AuthMethodInlineKey
+	case h.Password != "":
+		return AuthMethodInlinePassword
+	default:
+		return AuthMethodInlineAgent
+	}
+}
+
+// ===== Rendering =====
+
+// JSON renders the report as indented JSON.
+func (s *Stats) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal stats: %w", err)
+	}
+	return data, nil
+}
+
+// WriteTable renders the report as aligned plain-text tables.
+func (s *Stats) WriteTable(w io.Writer) error {
+	tw := term.NewTable(w)
+
+	fmt.Fprintf(tw, "Generated\t%s\n", s.GeneratedAt.Format(time.RFC3339))
+	fmt.Fprintf(tw, "Hosts\t%d\n", s.TotalHosts)
+	fmt.Fprintf(tw, "Groups\t%d\n", s.TotalGroups)
+	fmt.Fprintf(tw, "Credentials\t%d\n", s.TotalCredentials)
+
+	writeCountSection(tw, "GROUP", s.HostsPerGroup)
+	writeCountSection(tw, "TAG", s.HostsPerTag)
+	writeCountSection(tw, "CREDENTIAL", s.HostsPerCredential)
+
+	methods := make(map[string]int, len(s.AuthMethods))
+	for k, v := range s.AuthMethods {
+		methods[string(k)] = v
+	}
+	writeCountSection(tw, "AUTH METHOD", methods)
+
+	writeListSection(tw, "Ungrouped hosts", s.UngroupedHosts)
+	writeListSection(tw, "Never connected", s.NeverConnected)
+	writeListSection(tw, "Stale facts", s.StaleFacts)
+	writeListSection(tw, "Unused credentials", s.UnusedCredentials)
+
+	return tw.Flush()
+}
+
+func writeCountSection(w io.Writer, title string, counts map[string]int) {
+	fmt.Fprintf(w, "\n%s\tHOSTS\n", title)
+	if len(counts) == 0 {
+		fmt.Fprintln(w, "-\t0")
+		return
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%d\n", name, counts[name])
+	}
+}
+
+func writeListSection(w io.Writer, title string, items []string) {
+	fmt.Fprintf(w, "\n%s (%d)\n", title, len(items))
+	for _, item := range items {
+		fmt.Fprintf(w, "  %s\n", item)
+	}
+}
@@ -0,0 +1,41 @@
+package inventory
+
+import (
+	"fmt"
+
+	"gossher/internal/events"
+)
+
+// SetEventBus sets the bus host status changes are published to, events.Default()
+// unless changed. Nil disables publishing.
+func (m *Manager) SetEventBus(b *events.Bus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bus = b
+}
+
+// SetHostStatus records the runtime status of a host and publishes
+// events.HostStatusChanged when it differs from the previous one. The status is not
+// written to disk.
+func (m *Manager) SetHostStatus(id string, status HostStatus) error {
+	m.mu.Lock()
+	h, ok := m.hosts[id]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("host %s not found", id)
+	}
+	previous := h.Status
+	h.Status = status
+	bus := m.bus
+	m.mu.Unlock()
+
+	if previous != status {
+		bus.Publish(events.Event{
+			Type:           events.HostStatusChanged,
+			HostID:         id,
+			Status:         status.String(),
+			PreviousStatus: previous.String(),
+		})
+	}
+	return nil
+}
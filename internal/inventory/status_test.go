@@ -0,0 +1,36 @@
+package inventory
+
+import (
+	"testing"
+
+	"gossher/internal/events"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetHostStatus(t *testing.T) {
+	m, _ := setupTestManager(t)
+	require.NoError(t, m.Load())
+	require.NoError(t, m.AddHost(NewLocalHost("local", "Local")))
+
+	bus := events.NewBus()
+	m.SetEventBus(bus)
+	var got []events.Event
+	bus.Subscribe(func(e events.Event) { got = append(got, e) })
+
+	require.NoError(t, m.SetHostStatus("local", HostStatusOnline))
+	require.NoError(t, m.SetHostStatus("local", HostStatusOnline))
+	require.NoError(t, m.SetHostStatus("local", HostStatusOffline))
+	assert.Error(t, m.SetHostStatus("missing", HostStatusOnline))
+
+	h, _ := m.GetHost("local")
+	assert.Equal(t, HostStatusOffline, h.Status)
+
+	require.Len(t, got, 2, "unchanged status is not published")
+	assert.Equal(t, events.HostStatusChanged, got[0].Type)
+	assert.Equal(t, "local", got[0].HostID)
+	assert.Equal(t, "Online", got[0].Status)
+	assert.Equal(t, "Unknown", got[0].PreviousStatus)
+	assert.Equal(t, "Offline", got[1].Status)
+}
@@ -0,0 +1,88 @@
+package inventory
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnknownField is a key that the target document type does not define.
+type UnknownField struct {
+	Line  int
+	Field string
+	// Type is the Go type the key was decoded into, e.g. "inventory.Host".
+	Type string
+}
+
+// UnknownFieldsError lists every unknown key of a strictly decoded document.
+type UnknownFieldsError struct {
+	Fields []UnknownField
+}
+
+func (e *UnknownFieldsError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = fmt.Sprintf("%s (line %d)", f.Field, f.Line)
+	}
+	return "unknown fields: " + strings.Join(parts, ", ")
+}
+
+// unknownFieldPattern matches the messages yaml.v3 reports for unknown keys.
+var unknownFieldPattern = regexp.MustCompile(`^line (\d+): field (\S+) not found in type (\S+)$`)
+
+// StrictUnmarshal decodes a single YAML document like yaml.Unmarshal, but rejects keys
+// that v does not define. Unknown keys are reported together as *UnknownFieldsError.
+func StrictUnmarshal(data []byte, v any) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	err := dec.Decode(v)
+	if err == nil || errors.Is(err, io.EOF) {
+		return nil
+	}
+	return UnknownFieldsOf(err)
+}
+
+// UnknownFieldsOf converts the error of a yaml.Decoder with KnownFields enabled into
+// *UnknownFieldsError when unknown keys are its only problem. Other errors are
+// returned unchanged.
+func UnknownFieldsOf(err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+
+	unknown := &UnknownFieldsError{}
+	for _, msg := range typeErr.Errors {
+		m := unknownFieldPattern.FindStringSubmatch(msg)
+		if m == nil {
+			// Other type errors take precedence; they are reported as-is
+			return err
+		}
+		line, _ := strconv.Atoi(m[1])
+		unknown.Fields = append(unknown.Fields, UnknownField{Line: line, Field: m[2], Type: m[3]})
+	}
+	return unknown
+}
+
+// unmarshal decodes a document strictly or leniently.
+func unmarshal(data []byte, v any, strict bool) error {
+	if strict {
+		return StrictUnmarshal(data, v)
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+// SetStrictDecoding makes Load reject unknown keys in data files instead of
+// ignoring them.
+func (m *Manager) SetStrictDecoding(strict bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.strict = strict
+}
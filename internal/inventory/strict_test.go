@@ -0,0 +1,57 @@
+package inventory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictUnmarshal(t *testing.T) {
+	t.Run("lists unknown keys with lines", func(t *testing.T) {
+		var h Host
+		err := StrictUnmarshal([]byte("type: host\nid: web1\nadress: 10.0.0.1\nprot: 22\n"), &h)
+
+		var unknown *UnknownFieldsError
+		require.True(t, errors.As(err, &unknown))
+		assert.Equal(t, []UnknownField{
+			{Line: 3, Field: "adress", Type: "inventory.Host"},
+			{Line: 4, Field: "prot", Type: "inventory.Host"},
+		}, unknown.Fields)
+		assert.Equal(t, "unknown fields: adress (line 3), prot (line 4)", err.Error())
+	})
+
+	t.Run("other errors pass through", func(t *testing.T) {
+		var h Host
+		err := StrictUnmarshal([]byte("port: [1, 2]\n"), &h)
+		require.Error(t, err)
+		var unknown *UnknownFieldsError
+		assert.False(t, errors.As(err, &unknown))
+	})
+
+	t.Run("known keys decode", func(t *testing.T) {
+		var h Host
+		require.NoError(t, StrictUnmarshal([]byte("type: host\nid: web1\n"), &h))
+		assert.Equal(t, "web1", h.ID)
+		require.NoError(t, StrictUnmarshal(nil, &h))
+	})
+}
+
+func TestStrictDecoding(t *testing.T) {
+	m, dir := setupTestManager(t)
+	writeTestFile(t, dir, "web1.yaml", `type: host
+id: web1
+name: web1
+adress: 10.0.0.1
+`)
+
+	require.NoError(t, m.Load())
+	_, ok := m.GetHost("web1")
+	assert.True(t, ok, "unknown keys are ignored by default")
+
+	m.SetStrictDecoding(true)
+	err := m.Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "adress (line 4)")
+}
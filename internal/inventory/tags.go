@@ -0,0 +1,276 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TagSeparator separates namespace and value in structured tags such as "env:prod".
+const TagSeparator = ":"
+
+// Tag is a parsed host tag. Free-form tags have an empty Namespace.
+type Tag struct {
+	Namespace string
+	Value     string
+}
+
+// ParseTag splits a raw tag into namespace and value ("env:prod" -> env, prod).
+func ParseTag(raw string) Tag {
+	raw = strings.TrimSpace(raw)
+	ns, value, ok := strings.Cut(raw, TagSeparator)
+	if !ok {
+		return Tag{Value: raw}
+	}
+	return Tag{
+		Namespace: strings.ToLower(strings.TrimSpace(ns)),
+		Value:     strings.TrimSpace(value),
+	}
+}
+
+// String returns the tag in its stored form.
+func (t Tag) String() string {
+	if t.Namespace == "" {
+		return t.Value
+	}
+	return t.Namespace + TagSeparator + t.Value
+}
+
+// IsStructured reports whether the tag has a namespace.
+func (t Tag) IsStructured() bool {
+	return t.Namespace != ""
+}
+
+// NormalizeTag returns the canonical form of a raw tag (trimmed, lowercase namespace).
+func NormalizeTag(raw string) string {
+	return ParseTag(raw).String()
+}
+
+// ===== Host helpers =====
+
+// GetTagValues returns all values the host has in the given namespace.
+func (h *Host) GetTagValues(namespace string) []string {
+	namespace = strings.ToLower(namespace)
+
+	var values []string
+	for _, raw := range h.Tags {
+		if t := ParseTag(raw); t.Namespace == namespace {
+			values = append(values, t.Value)
+		}
+	}
+	return values
+}
+
+// GetTagValue returns the first value the host has in the given namespace.
+func (h *Host) GetTagValue(namespace string) (string, bool) {
+	values := h.GetTagValues(namespace)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
+
+// ===== Policy =====
+
+// TagPolicy restricts which structured tags may be used. An empty policy allows everything.
+type TagPolicy struct {
+	// Namespaces maps each allowed namespace to its allowed values. An empty list allows any value.
+	Namespaces map[string][]string `yaml:"namespaces,omitempty"`
+	// AllowFreeForm permits tags without a namespace when Namespaces is set.
+	AllowFreeForm bool `yaml:"allow_free_form,omitempty"`
+	// Exclusive namespaces may hold at most one value per host (e.g. env).
+	Exclusive []string `yaml:"exclusive,omitempty"`
+}
+
+// IsEmpty reports whether the policy imposes no restrictions.
+func (p *TagPolicy) IsEmpty() bool {
+	return p == nil || (len(p.Namespaces) == 0 && len(p.Exclusive) == 0)
+}
+
+// ValidateTag checks a single tag against the policy.
+func (p *TagPolicy) ValidateTag(raw string) error {
+	t := ParseTag(raw)
+	if t.Value == "" {
+		return fmt.Errorf("tag %q: value cannot be empty", raw)
+	}
+	if p == nil || len(p.Namespaces) == 0 {
+		return nil
+	}
+
+	if !t.IsStructured() {
+		if p.AllowFreeForm {
+			return nil
+		}
+		return fmt.Errorf("tag %q: free-form tags are not allowed, use namespace:value", raw)
+	}
+
+	allowed, ok := p.Namespaces[t.Namespace]
+	if !ok {
+		return fmt.Errorf("tag %q: unknown namespace %q (allowed: %s)", raw, t.Namespace, strings.Join(p.namespaceNames(), ", "))
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	for _, v := range allowed {
+		if v == t.Value {
+			return nil
+		}
+	}
+	return fmt.Errorf("tag %q: value %q not allowed in namespace %s (allowed: %s)", raw, t.Value, t.Namespace, strings.Join(allowed, ", "))
+}
+
+// ValidateTags checks a host's full tag list, including exclusive namespaces.
+func (p *TagPolicy) ValidateTags(tags []string) error {
+	counts := map[string]int{}
+	for _, raw := range tags {
+		if err := p.ValidateTag(raw); err != nil {
+			return err
+		}
+		counts[ParseTag(raw).Namespace]++
+	}
+
+	if p.IsEmpty() {
+		return nil
+	}
+	for _, ns := range p.Exclusive {
+		if counts[ns] > 1 {
+			return fmt.Errorf("namespace %s allows only one value per host", ns)
+		}
+	}
+
+	return nil
+}
+
+func (p TagPolicy) clone() TagPolicy {
+	c := TagPolicy{AllowFreeForm: p.AllowFreeForm}
+	if p.Namespaces != nil {
+		c.Namespaces = make(map[string][]string, len(p.Namespaces))
+		for ns, values := range p.Namespaces {
+			c.Namespaces[ns] = append([]string(nil), values...)
+		}
+	}
+	c.Exclusive = append([]string(nil), p.Exclusive...)
+	return c
+}
+
+func (p *TagPolicy) namespaceNames() []string {
+	names := make([]string, 0, len(p.Namespaces))
+	for ns := range p.Namespaces {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ===== Query =====
+
+// tagTerm is a single condition in a TagQuery.
+type tagTerm struct {
+	namespace string
+	value     string // empty means "any value" / presence check
+	negate    bool
+}
+
+// TagQuery matches hosts against a comma-separated list of conditions, all of which must hold:
+//
+//	env=prod     host has tag env:prod
+//	env!=prod    host does not have tag env:prod
+//	env          host has any tag in namespace env (or the free-form tag "env")
+//	!legacy      host has neither free-form tag legacy nor namespace legacy
+type TagQuery struct {
+	terms []tagTerm
+}
+
+// ParseTagQuery parses a query such as "env=prod,role=db".
+func ParseTagQuery(query string) (*TagQuery, error) {
+	q := &TagQuery{}
+
+	for _, part := range strings.Split(query, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		var term tagTerm
+		switch {
+		case strings.Contains(part, "!="):
+			ns, value, _ := strings.Cut(part, "!=")
+			term = tagTerm{namespace: ns, value: value, negate: true}
+		case strings.Contains(part, "="):
+			ns, value, _ := strings.Cut(part, "=")
+			term = tagTerm{namespace: ns, value: value}
+		case strings.HasPrefix(part, "!"):
+			term = tagTerm{namespace: part[1:], negate: true}
+		default:
+			term = tagTerm{namespace: part}
+		}
+
+		term.namespace = strings.ToLower(strings.TrimSpace(term.namespace))
+		term.value = strings.TrimSpace(term.value)
+		if term.namespace == "" {
+			return nil, fmt.Errorf("invalid tag query term %q", part)
+		}
+
+		q.terms = append(q.terms, term)
+	}
+
+	return q, nil
+}
+
+// Matches reports whether the host satisfies every term of the query.
+func (q *TagQuery) Matches(h *Host) bool {
+	for _, term := range q.terms {
+		if term.matches(h) == term.negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (t tagTerm) matches(h *Host) bool {
+	if t.value == "" {
+		return len(h.GetTagValues(t.namespace)) > 0 || h.HasTag(t.namespace)
+	}
+	for _, v := range h.GetTagValues(t.namespace) {
+		if v == t.value {
+			return true
+		}
+	}
+	return false
+}
+
+// ===== Manager integration =====
+
+// SetTagPolicy sets the policy enforced when hosts are added or updated. Nil disables enforcement.
+func (m *Manager) SetTagPolicy(p *TagPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tagPolicy = p
+}
+
+// FindHostsByTags returns copies of all hosts matching a tag query, sorted by ID.
+func (m *Manager) FindHostsByTags(query string) ([]*Host, error) {
+	q, err := ParseTagQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []*Host
+	for _, h := range m.ListHosts() {
+		if q.Matches(h) {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts, nil
+}
+
+// validateHostTags normalizes and checks a host's tags against the policy. Caller must hold the lock.
+func (m *Manager) validateHostTags(h *Host) error {
+	for i, raw := range h.Tags {
+		h.Tags[i] = NormalizeTag(raw)
+	}
+	if err := m.tagPolicy.ValidateTags(h.Tags); err != nil {
+		return fmt.Errorf("host %s: %w", h.ID, err)
+	}
+	return nil
+}
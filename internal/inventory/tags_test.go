@@ -0,0 +1,103 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want Tag
+	}{
+		{"env:prod", Tag{Namespace: "env", Value: "prod"}},
+		{" ENV : prod ", Tag{Namespace: "env", Value: "prod"}},
+		{"legacy", Tag{Value: "legacy"}},
+		{"url:http://x", Tag{Namespace: "url", Value: "http://x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseTag(tt.raw))
+		})
+	}
+}
+
+func TestTagPolicy(t *testing.T) {
+	policy := &TagPolicy{
+		Namespaces: map[string][]string{
+			"env":  {"prod", "staging"},
+			"role": {},
+		},
+		Exclusive: []string{"env"},
+	}
+
+	assert.NoError(t, policy.ValidateTags([]string{"env:prod", "role:anything"}))
+	assert.Error(t, policy.ValidateTag("env:qa"))
+	assert.Error(t, policy.ValidateTag("team:core"))
+	assert.Error(t, policy.ValidateTag("legacy"))
+	assert.Error(t, policy.ValidateTags([]string{"env:prod", "env:staging"}))
+
+	policy.AllowFreeForm = true
+	assert.NoError(t, policy.ValidateTag("legacy"))
+
+	var empty *TagPolicy
+	assert.NoError(t, empty.ValidateTags([]string{"anything", "x:y"}))
+	assert.Error(t, empty.ValidateTag("env:"))
+}
+
+func TestTagQuery(t *testing.T) {
+	h := NewHost("web1", "web1", "10.0.0.1")
+	h.Tags = []string{"env:prod", "role:web", "legacy"}
+
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"env=prod", true},
+		{"env=prod,role=web", true},
+		{"env=staging", false},
+		{"env!=staging", true},
+		{"env", true},
+		{"legacy", true},
+		{"!legacy", false},
+		{"ENV=prod", true},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := ParseTagQuery(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, q.Matches(h))
+		})
+	}
+
+	_, err := ParseTagQuery("=prod")
+	assert.Error(t, err)
+}
+
+func TestManagerTagPolicy(t *testing.T) {
+	m, _ := setupTestManager(t)
+	m.SetTagPolicy(&TagPolicy{Namespaces: map[string][]string{"env": {"prod"}}})
+
+	h := NewHost("web1", "web1", "10.0.0.1")
+	h.User = "root"
+	h.Tags = []string{"Env:prod"}
+	require.NoError(t, m.AddHost(h))
+
+	stored, _ := m.GetHost("web1")
+	assert.Equal(t, []string{"env:prod"}, stored.Tags)
+
+	bad := NewHost("web2", "web2", "10.0.0.2")
+	bad.User = "root"
+	bad.Tags = []string{"env:dev"}
+	assert.Error(t, m.AddHost(bad))
+
+	hosts, err := m.FindHostsByTags("env=prod")
+	require.NoError(t, err)
+	require.Len(t, hosts, 1)
+	assert.Equal(t, "web1", hosts[0].ID)
+}
@@ -0,0 +1,221 @@
+package inventory
+
+import (
+	"fmt"
+	"strings"
+)
+
+// targetTerm is a single selector of a TargetSpec with the operator joining it.
+type targetTerm struct {
+	op    byte // '+' adds, '-' removes, '&' intersects
+	kind  string
+	value string
+}
+
+// TargetSpec selects hosts by combining selectors left to right:
+//
+//	group:web      hosts of group web, including nested groups
+//	tag:env=prod   hosts matching a tag query (see TagQuery)
+//	filter:canary  hosts for which the script hook filter_canary returns true
+//	addr:10.0.0.0/16  hosts whose address lies in a network (see AddressQuery);
+//	               "addr in 10.0.0.0/16" is the same
+//	host:web03     a single host
+//	all            every host ("*" works too)
+//	web03          a host ID, or a group name when no host has that ID
+//
+// Selectors are joined by operators: "+" (or just whitespace) adds hosts, "-" removes
+// them and "&" keeps only hosts that are also selected, e.g.
+// "group:web + tag:canary - host:web03". Operators stand alone or prefix a selector
+// ("-host:web03"). A spec starting with "-" removes hosts from all hosts.
+type TargetSpec struct {
+	terms []targetTerm
+}
+
+// ParseTargetSpec parses a target spec.
+func ParseTargetSpec(spec string) (*TargetSpec, error) {
+	s := &TargetSpec{}
+	var op byte = '+'
+	pendingOp := false
+
+	for _, field := range joinAddrIn(strings.Fields(spec)) {
+		if field == "+" || field == "-" || field == "&" {
+			if pendingOp {
+				return nil, fmt.Errorf("invalid target spec %q: operator %s follows an operator", spec, field)
+			}
+			op, pendingOp = field[0], true
+			continue
+		}
+		if !pendingOp && strings.ContainsRune("+-&", rune(field[0])) {
+			op, field = field[0], field[1:]
+		}
+
+		term := targetTerm{op: op}
+		if kind, value, ok := strings.Cut(field, ":"); ok {
+			term.kind, term.value = strings.ToLower(kind), value
+		} else {
+			term.value = field
+		}
+
+		switch term.kind {
+		case "", "group", "host", "filter":
+		case "tag":
+			if _, err := ParseTagQuery(term.value); err != nil {
+				return nil, err
+			}
+		case "addr":
+			if _, err := ParseAddressQuery(term.value); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("invalid target spec %q: unknown selector %s:", spec, term.kind)
+		}
+		if term.value == "" {
+			return nil, fmt.Errorf("invalid target spec %q: empty selector", spec)
+		}
+
+		s.terms = append(s.terms, term)
+		op, pendingOp = '+', false
+	}
+
+	if pendingOp {
+		return nil, fmt.Errorf("invalid target spec %q: missing selector after operator", spec)
+	}
+	if len(s.terms) == 0 {
+		return nil, fmt.Errorf("empty target spec")
+	}
+	return s, nil
+}
+
+// joinAddrIn rewrites the words "addr in 10.0.0.0/16" of a spec to the selector
+// "addr:10.0.0.0/16", keeping an operator prefixed to "addr".
+func joinAddrIn(fields []string) []string {
+	out := make([]string, 0, len(fields))
+	for i := 0; i < len(fields); i++ {
+		word := strings.TrimLeft(fields[i], "+-&")
+		if strings.EqualFold(word, "addr") && len(fields[i])-len(word) <= 1 &&
+			i+2 < len(fields) && strings.EqualFold(fields[i+1], "in") {
+			out = append(out, fields[i][:len(fields[i])-len(word)]+"addr:"+fields[i+2])
+			i += 2
+			continue
+		}
+		out = append(out, fields[i])
+	}
+	return out
+}
+
+// ResolveTargetSpec returns the IDs of the hosts selected by a target spec, in the
+// order they were first selected and without duplicates.
+func (m *Manager) ResolveTargetSpec(spec string) ([]string, error) {
+	s, err := ParseTargetSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var selected []string
+	if s.terms[0].op == '-' {
+		selected = sortedKeys(m.hosts)
+	}
+
+	for _, term := range s.terms {
+		ids, err := m.selectTerm(term)
+		if err != nil {
+			return nil, err
+		}
+
+		switch term.op {
+		case '+':
+			seen := make(map[string]bool, len(selected))
+			for _, id := range selected {
+				seen[id] = true
+			}
+			for _, id := range ids {
+				if !seen[id] {
+					seen[id] = true
+					selected = append(selected, id)
+				}
+			}
+		case '-', '&':
+			matched := make(map[string]bool, len(ids))
+			for _, id := range ids {
+				matched[id] = true
+			}
+			kept := selected[:0]
+			for _, id := range selected {
+				if matched[id] == (term.op == '&') {
+					kept = append(kept, id)
+				}
+			}
+			selected = kept
+		}
+	}
+	return selected, nil
+}
+
+// selectTerm returns the hosts a single selector matches. Caller must hold the lock.
+func (m *Manager) selectTerm(term targetTerm) ([]string, error) {
+	switch term.kind {
+	case "group":
+		return m.resolveGroupHosts(term.value)
+
+	case "host":
+		if _, ok := m.hosts[term.value]; !ok {
+			return nil, fmt.Errorf("host %s not found", term.value)
+		}
+		return []string{term.value}, nil
+
+	case "tag":
+		q, err := ParseTagQuery(term.value)
+		if err != nil {
+			return nil, err
+		}
+		var ids []string
+		for _, id := range sortedKeys(m.hosts) {
+			if q.Matches(m.hosts[id]) {
+				ids = append(ids, id)
+			}
+		}
+		return ids, nil
+
+	case "addr":
+		q, err := ParseAddressQuery(term.value)
+		if err != nil {
+			return nil, err
+		}
+		var ids []string
+		for _, id := range sortedKeys(m.hosts) {
+			if q.Matches(m.hosts[id]) {
+				ids = append(ids, id)
+			}
+		}
+		return ids, nil
+
+	case "filter":
+		if !m.scripts.Has(FilterPrefix + term.value) {
+			return nil, fmt.Errorf("unknown filter %s: no %s%s hook in %s", term.value, FilterPrefix, term.value, ScriptsDir)
+		}
+		var ids []string
+		for _, id := range sortedKeys(m.hosts) {
+			ok, err := m.matchesFilter(term.value, m.hosts[id])
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				ids = append(ids, id)
+			}
+		}
+		return ids, nil
+	}
+
+	switch {
+	case term.value == "all" || term.value == "*":
+		return sortedKeys(m.hosts), nil
+	case m.hosts[term.value] != nil:
+		return []string{term.value}, nil
+	case m.groups[term.value] != nil:
+		return m.resolveGroupHosts(term.value)
+	}
+	return nil, fmt.Errorf("no host or group named %s", term.value)
+}
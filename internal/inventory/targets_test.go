@@ -0,0 +1,117 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTargetSpec(t *testing.T) {
+	valid := []string{
+		"group:web",
+		"group:web + tag:canary - host:web03",
+		"group:web tag:canary -host:web03",
+		"all & tag:env=prod",
+		"-db01",
+		"addr:10.0.0.0/16",
+		"group:web - addr in 10.0.0.3",
+	}
+	for _, spec := range valid {
+		t.Run(spec, func(t *testing.T) {
+			_, err := ParseTargetSpec(spec)
+			assert.NoError(t, err)
+		})
+	}
+
+	invalid := []string{
+		"",
+		"   ",
+		"rack:a1",
+		"host:",
+		"group:web + - host:web03",
+		"group:web -",
+		"group:web &",
+		"addr:10.0.0.0/33",
+		"addr in web01",
+	}
+	for _, spec := range invalid {
+		t.Run("invalid "+spec, func(t *testing.T) {
+			_, err := ParseTargetSpec(spec)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestResolveTargetSpec(t *testing.T) {
+	m, dir := setupTestManager(t)
+	writeTestFile(t, dir, "hosts.yaml", `type: host
+id: web01
+name: web01
+address: 10.0.0.1
+user: deploy
+tags: [env:prod, canary]
+---
+type: host
+id: web02
+name: web02
+address: 10.0.0.2
+user: deploy
+tags: [env:prod]
+---
+type: host
+id: web03
+name: web03
+address: 10.0.0.3
+user: deploy
+tags: [env:staging, canary]
+---
+type: host
+id: db01
+name: db01
+address: 10.0.0.4
+user: deploy
+tags: [env:prod, canary]
+---
+type: group
+name: web
+host_ids: [web01, web02, web03]
+`)
+	require.NoError(t, m.Load())
+
+	tests := []struct {
+		spec string
+		want []string
+	}{
+		{"group:web + tag:canary - host:web03", []string{"web01", "web02", "db01"}},
+		{"group:web tag:canary", []string{"web01", "web02", "web03", "db01"}},
+		{"group:web & tag:env=prod", []string{"web01", "web02"}},
+		{"group:web -web03", []string{"web01", "web02"}},
+		{"- group:web", []string{"db01"}},
+		{"web02 web", []string{"web02", "web01", "web03"}},
+		{"all & tag:!canary", []string{"web02"}},
+		{"* - tag:env=prod", []string{"web03"}},
+		{"addr:10.0.0.0/30", []string{"web01", "web02", "web03"}},
+		{"group:web & addr in 10.0.0.2,10.0.0.3", []string{"web02", "web03"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			ids, err := m.ResolveTargetSpec(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+
+	t.Run("unknown names fail", func(t *testing.T) {
+		for _, spec := range []string{"host:web09", "group:db", "web09"} {
+			_, err := m.ResolveTargetSpec(spec)
+			assert.Error(t, err, spec)
+		}
+	})
+
+	t.Run("empty selections are allowed", func(t *testing.T) {
+		ids, err := m.ResolveTargetSpec("tag:env=qa")
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+	})
+}
@@ -0,0 +1,26 @@
+package inventory
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// TelemetryConfig opts in to anonymous usage statistics (see package telemetry).
+// Nothing is sent unless Enabled is set.
+type TelemetryConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Endpoint is the URL the reports are posted to.
+	Endpoint string `yaml:"endpoint,omitempty"`
+}
+
+// Validate checks that enabled telemetry has an http(s) endpoint.
+func (c TelemetryConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	u, err := url.Parse(c.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid telemetry endpoint %q", c.Endpoint)
+	}
+	return nil
+}
@@ -0,0 +1,419 @@
+package inventory
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Ensure Theme implements the interfaces
+var (
+	_ Entity = (*Theme)(nil)
+)
+
+// Built-in theme IDs. Config.Theme selects one of them or a theme document.
+const (
+	ThemeLight        = "light"
+	ThemeDark         = "dark"
+	ThemeHighContrast = "high-contrast"
+)
+
+// DefaultThemeID is the theme used when none is configured.
+const DefaultThemeID = ThemeLight
+
+// Color is a terminal color: "#rrggbb" or "#rgb" for true color, "0"-"255" for
+// an ANSI palette index, or empty for the terminal's default.
+type Color string
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Validate checks the color syntax.
+func (c Color) Validate() error {
+	if c == "" || hexColor.MatchString(string(c)) {
+		return nil
+	}
+	if n, err := strconv.Atoi(string(c)); err == nil && n >= 0 && n <= 255 {
+		return nil
+	}
+	return fmt.Errorf("invalid color %q: want #rrggbb, #rgb or 0-255", c)
+}
+
+// BorderStyle is the line style of boxes and tables.
+type BorderStyle string
+
+const (
+	BorderNone    BorderStyle = "none"
+	BorderASCII   BorderStyle = "ascii"
+	BorderSingle  BorderStyle = "single"
+	BorderRounded BorderStyle = "rounded"
+	BorderDouble  BorderStyle = "double"
+	BorderThick   BorderStyle = "thick"
+)
+
+// Validate checks that the border style is known; empty means BorderRounded.
+func (b BorderStyle) Validate() error {
+	switch b {
+	case "", BorderNone, BorderASCII, BorderSingle, BorderRounded, BorderDouble, BorderThick:
+		return nil
+	}
+	return fmt.Errorf("invalid border style: %s", b)
+}
+
+// Palette holds the base colors of the interface.
+type Palette struct {
+	Foreground Color `yaml:"foreground,omitempty"`
+	Background Color `yaml:"background,omitempty"`
+	// Accent highlights titles, keys and the focused pane.
+	Accent Color `yaml:"accent,omitempty"`
+	// Muted is used for hints, descriptions and disabled items.
+	Muted     Color `yaml:"muted,omitempty"`
+	Selection Color `yaml:"selection,omitempty"`
+	Border    Color `yaml:"border,omitempty"`
+}
+
+// StatusColors color host states and results.
+type StatusColors struct {
+	Online     Color `yaml:"online,omitempty"`
+	Offline    Color `yaml:"offline,omitempty"`
+	Connecting Color `yaml:"connecting,omitempty"`
+	Unknown    Color `yaml:"unknown,omitempty"`
+	Success    Color `yaml:"success,omitempty"`
+	Warning    Color `yaml:"warning,omitempty"`
+	Error      Color `yaml:"error,omitempty"`
+}
+
+// Theme is the look of the terminal interface. Besides the built-in themes, themes
+// can be stored as documents in the data directory and selected by ID.
+type Theme struct {
+	Type        DocumentType `yaml:"type"`
+	ID          string       `yaml:"id"`
+	Name        string       `yaml:"name,omitempty"`
+	Description string       `yaml:"description,omitempty"`
+
+	Palette Palette      `yaml:"palette,omitempty"`
+	Status  StatusColors `yaml:"status,omitempty"`
+	Border  BorderStyle  `yaml:"border,omitempty"`
+}
+
+// GetID returns the theme ID.
+func (t *Theme) GetID() string {
+	return t.ID
+}
+
+// GetName returns the display name, or the ID if there is none.
+func (t *Theme) GetName() string {
+	if t.Name == "" {
+		return t.ID
+	}
+	return t.Name
+}
+
+func (t *Theme) SetName(name string) {
+	t.Name = name
+}
+
+// Validate checks the ID, colors and border style.
+func (t *Theme) Validate() error {
+	if t.ID == "" {
+		return fmt.Errorf("theme ID cannot be empty")
+	}
+	if err := t.Border.Validate(); err != nil {
+		return fmt.Errorf("theme %s: %w", t.ID, err)
+	}
+
+	colors := map[string]Color{
+		"palette.foreground": t.Palette.Foreground,
+		"palette.background": t.Palette.Background,
+		"palette.accent":     t.Palette.Accent,
+		"palette.muted":      t.Palette.Muted,
+		"palette.selection":  t.Palette.Selection,
+		"palette.border":     t.Palette.Border,
+		"status.online":      t.Status.Online,
+		"status.offline":     t.Status.Offline,
+		"status.connecting":  t.Status.Connecting,
+		"status.unknown":     t.Status.Unknown,
+		"status.success":     t.Status.Success,
+		"status.warning":     t.Status.Warning,
+		"status.error":       t.Status.Error,
+	}
+	for _, field := range sortedKeys(colors) {
+		if err := colors[field].Validate(); err != nil {
+			return fmt.Errorf("theme %s: %s: %w", t.ID, field, err)
+		}
+	}
+	return nil
+}
+
+// Clone creates a copy of the Theme.
+func (t *Theme) Clone() interface{} {
+	clone := *t
+	return &clone
+}
+
+// StatusColor returns the color of a host status.
+func (t *Theme) StatusColor(s HostStatus) Color {
+	switch s {
+	case HostStatusOnline:
+		return t.Status.Online
+	case HostStatusOffline:
+		return t.Status.Offline
+	case HostStatusConnecting:
+		return t.Status.Connecting
+	default:
+		return t.Status.Unknown
+	}
+}
+
+// ===== Built-in themes =====
+
+var builtinThemes = map[string]Theme{
+	ThemeLight: {
+		ID:   ThemeLight,
+		Name: "Light",
+		Palette: Palette{
+			Foreground: "#1f2328", Background: "#ffffff", Accent: "#0969da",
+			Muted: "#656d76", Selection: "#ddf4ff", Border: "#d0d7de",
+		},
+		Status: StatusColors{
+			Online: "#1a7f37", Offline: "#cf222e", Connecting: "#9a6700", Unknown: "#656d76",
+			Success: "#1a7f37", Warning: "#9a6700", Error: "#cf222e",
+		},
+		Border: BorderRounded,
+	},
+	ThemeDark: {
+		ID:   ThemeDark,
+		Name: "Dark",
+		Palette: Palette{
+			Foreground: "#e6edf3", Background: "#0d1117", Accent: "#58a6ff",
+			Muted: "#8b949e", Selection: "#1f3a5f", Border: "#30363d",
+		},
+		Status: StatusColors{
+			Online: "#3fb950", Offline: "#f85149", Connecting: "#d29922", Unknown: "#8b949e",
+			Success: "#3fb950", Warning: "#d29922", Error: "#f85149",
+		},
+		Border: BorderRounded,
+	},
+	// High contrast sticks to the 16 ANSI colors, which terminals map to the
+	// user's own accessible palette, and uses heavy borders.
+	ThemeHighContrast: {
+		ID:   ThemeHighContrast,
+		Name: "High contrast",
+		Palette: Palette{
+			Foreground: "15", Background: "0", Accent: "14",
+			Muted: "7", Selection: "11", Border: "15",
+		},
+		Status: StatusColors{
+			Online: "10", Offline: "9", Connecting: "11", Unknown: "7",
+			Success: "10", Warning: "11", Error: "9",
+		},
+		Border: BorderThick,
+	},
+}
+
+// BuiltinThemes returns copies of the built-in themes sorted by ID.
+func BuiltinThemes() []*Theme {
+	themes := make([]*Theme, 0, len(builtinThemes))
+	for _, id := range sortedKeys(builtinThemes) {
+		t := builtinThemes[id]
+		themes = append(themes, &t)
+	}
+	return themes
+}
+
+// ===== Manager =====
+
+// GetTheme returns a copy of the theme with the given ID: a theme document, or
+// otherwise a built-in theme. A document may override a built-in theme.
+func (m *Manager) GetTheme(id string) (*Theme, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if t, ok := m.themes[id]; ok {
+		return t.Clone().(*Theme), true
+	}
+	if t, ok := builtinThemes[id]; ok {
+		return &t, true
+	}
+	return nil, false
+}
+
+// ListThemes returns copies of the built-in and stored themes sorted by ID.
+func (m *Manager) ListThemes() []*Theme {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := map[string]bool{}
+	for id := range builtinThemes {
+		ids[id] = true
+	}
+	for id := range m.themes {
+		ids[id] = true
+	}
+
+	themes := make([]*Theme, 0, len(ids))
+	for _, id := range sortedKeys(ids) {
+		if t, ok := m.themes[id]; ok {
+			themes = append(themes, t.Clone().(*Theme))
+		} else {
+			t := builtinThemes[id]
+			themes = append(themes, &t)
+		}
+	}
+	return themes
+}
+
+// AddTheme validates and stores a new theme. The ID policy may rewrite t.ID.
+func (m *Manager) AddTheme(t *Theme) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, err := m.checkNewID(TypeTheme, t.ID)
+	if err != nil {
+		return err
+	}
+	t.ID = id
+
+	if _, exists := m.themes[t.ID]; exists {
+		return fmt.Errorf("theme %s already exists", t.ID)
+	}
+
+	stored := t.Clone().(*Theme)
+	stored.Type = TypeTheme
+	return m.store(stored)
+}
+
+// UpdateTheme replaces an existing theme and rewrites its file.
+func (m *Manager) UpdateTheme(t *Theme) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.themes[t.ID]; !exists {
+		return fmt.Errorf("theme %s not found", t.ID)
+	}
+
+	stored := t.Clone().(*Theme)
+	stored.Type = TypeTheme
+	m.themes[t.ID] = stored
+	return m.saveFile(m.sources[entityKey{TypeTheme, t.ID}])
+}
+
+// RemoveTheme deletes a stored theme. Built-in themes cannot be removed.
+func (m *Manager) RemoveTheme(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.themes[id]; !exists {
+		return fmt.Errorf("theme %s not found", id)
+	}
+	return m.saveFile(m.unregister(entityKey{TypeTheme, id}))
+}
+
+// ===== Live switching =====
+
+// ThemeSelector holds the active theme of a running interface and notifies
+// subscribers when it changes, so the interface can redraw without a restart.
+type ThemeSelector struct {
+	m *Manager
+
+	mu      sync.Mutex
+	current *Theme
+	next    int
+	subs    map[int]func(*Theme)
+}
+
+// NewThemeSelector creates a selector with the theme id active, e.g. the configured
+// GetTheme(). An empty id selects DefaultThemeID.
+func NewThemeSelector(m *Manager, id string) (*ThemeSelector, error) {
+	s := &ThemeSelector{m: m, subs: make(map[int]func(*Theme))}
+	t, err := s.resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	s.current = t
+	return s, nil
+}
+
+func (s *ThemeSelector) resolve(id string) (*Theme, error) {
+	if id == "" {
+		id = DefaultThemeID
+	}
+	t, ok := s.m.GetTheme(id)
+	if !ok {
+		return nil, fmt.Errorf("theme %s not found", id)
+	}
+	return t, nil
+}
+
+// Current returns a copy of the active theme.
+func (s *ThemeSelector) Current() *Theme {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current.Clone().(*Theme)
+}
+
+// Select activates another theme and notifies the subscribers. It does not save
+// the choice; call SetTheme for that.
+func (s *ThemeSelector) Select(id string) error {
+	t, err := s.resolve(id)
+	if err != nil {
+		return err
+	}
+	s.activate(t)
+	return nil
+}
+
+// Reload re-reads the active theme, e.g. after the Manager reloaded the data
+// directory, and notifies the subscribers. A removed theme falls back to
+// DefaultThemeID.
+func (s *ThemeSelector) Reload() {
+	t, err := s.resolve(s.Current().ID)
+	if err != nil {
+		t, _ = s.resolve(DefaultThemeID)
+	}
+	s.activate(t)
+}
+
+func (s *ThemeSelector) activate(t *Theme) {
+	s.mu.Lock()
+	s.current = t
+	ids := make([]int, 0, len(s.subs))
+	for id := range s.subs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	subs := make([]func(*Theme), 0, len(ids))
+	for _, id := range ids {
+		subs = append(subs, s.subs[id])
+	}
+	s.mu.Unlock()
+
+	for _, fn := range subs {
+		fn(t.Clone().(*Theme))
+	}
+}
+
+// Subscribe registers a function called with the new theme after every change. The
+// returned function removes the subscription.
+func (s *ThemeSelector) Subscribe(fn func(*Theme)) (unsubscribe func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.next
+	s.next++
+	s.subs[id] = fn
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subs, id)
+	}
+}
@@ -0,0 +1,78 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThemes(t *testing.T) {
+	m, dir := setupTestManager(t)
+	writeTestFile(t, dir, "theme-solar.yaml", `type: theme
+id: solar
+name: Solarized
+palette:
+  foreground: "#839496"
+  background: "#002b36"
+  accent: "33"
+status:
+  online: "#859900"
+border: double
+`)
+	require.NoError(t, m.Load())
+
+	t.Run("builtin and stored themes", func(t *testing.T) {
+		var ids []string
+		for _, th := range m.ListThemes() {
+			ids = append(ids, th.ID)
+		}
+		assert.Equal(t, []string{"dark", "high-contrast", "light", "solar"}, ids)
+
+		solar, ok := m.GetTheme("solar")
+		require.True(t, ok)
+		assert.Equal(t, Color("#002b36"), solar.Palette.Background)
+		assert.Equal(t, Color("#859900"), solar.StatusColor(HostStatusOnline))
+		assert.Equal(t, BorderDouble, solar.Border)
+
+		for _, th := range BuiltinThemes() {
+			assert.NoError(t, th.Validate(), th.ID)
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		for _, c := range []Color{"red", "#12345", "256", "-1"} {
+			th := &Theme{ID: "bad", Palette: Palette{Accent: c}}
+			assert.ErrorContains(t, th.Validate(), "palette.accent: invalid color", c)
+		}
+		assert.ErrorContains(t, (&Theme{ID: "bad", Border: "wavy"}).Validate(), "invalid border style")
+		assert.ErrorContains(t, m.RemoveTheme("dark"), "theme dark not found")
+	})
+
+	t.Run("live switching", func(t *testing.T) {
+		s, err := NewThemeSelector(m, "")
+		require.NoError(t, err)
+		assert.Equal(t, ThemeLight, s.Current().ID)
+
+		var seen []string
+		stop := s.Subscribe(func(th *Theme) { seen = append(seen, th.ID) })
+
+		require.NoError(t, s.Select("solar"))
+		assert.Error(t, s.Select("missing"))
+		assert.Equal(t, "solar", s.Current().ID)
+
+		solar, _ := m.GetTheme("solar")
+		solar.Palette.Accent = "#268bd2"
+		require.NoError(t, m.UpdateTheme(solar))
+		s.Reload()
+		assert.Equal(t, Color("#268bd2"), s.Current().Palette.Accent)
+
+		require.NoError(t, m.RemoveTheme("solar"))
+		s.Reload()
+		assert.Equal(t, ThemeLight, s.Current().ID, "removed themes fall back to the default")
+
+		stop()
+		require.NoError(t, s.Select("dark"))
+		assert.Equal(t, []string{"solar", "solar", "light"}, seen)
+	})
+}
@@ -0,0 +1,62 @@
+package inventory
+
+import (
+	"fmt"
+	"time"
+)
+
+// Timeouts bounds the phases of a command execution. Durations are written like
+// "10s" or "2m"; zero leaves a timeout unset so it is inherited from the next level.
+//
+// Each timeout is resolved separately, the first level that sets it wins:
+//
+//  1. the invocation (executor.ExecOptions)
+//  2. the saved command being run
+//  3. the host (Connect and Command only)
+//  4. the global config; Connect falls back to ssh_timeout
+//
+// Timeouts unset on every level do not limit the run.
+type Timeouts struct {
+	// Connect limits establishing the connection, including jump hosts.
+	Connect time.Duration `yaml:"connect,omitempty"`
+	// Command limits the run on one host, including pre-flight checks.
+	Command time.Duration `yaml:"command,omitempty"`
+	// Total limits the run over all hosts; hosts still running are cancelled.
+	Total time.Duration `yaml:"total,omitempty"`
+}
+
+// IsZero reports whether no timeout is set.
+func (t Timeouts) IsZero() bool {
+	return t == Timeouts{}
+}
+
+// Validate checks that no timeout is negative.
+func (t Timeouts) Validate() error {
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{{"connect", t.Connect}, {"command", t.Command}, {"total", t.Total}} {
+		if d.value < 0 {
+			return fmt.Errorf("invalid %s timeout: %s", d.name, d.value)
+		}
+	}
+	return nil
+}
+
+// ResolveTimeouts merges levels ordered from highest to lowest precedence: each
+// timeout is taken from the first level that sets it.
+func ResolveTimeouts(levels ...Timeouts) Timeouts {
+	var t Timeouts
+	for _, l := range levels {
+		if t.Connect == 0 {
+			t.Connect = l.Connect
+		}
+		if t.Command == 0 {
+			t.Command = l.Command
+		}
+		if t.Total == 0 {
+			t.Total = l.Total
+		}
+	}
+	return t
+}
@@ -0,0 +1,76 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveTimeouts(t *testing.T) {
+	invocation := Timeouts{Command: time.Minute}
+	saved := Timeouts{Command: 2 * time.Minute, Total: 10 * time.Minute}
+	host := Timeouts{Connect: 5 * time.Second, Command: 3 * time.Minute}
+	global := Timeouts{Connect: 30 * time.Second, Command: time.Hour, Total: time.Hour}
+
+	assert.Equal(t, Timeouts{Connect: 5 * time.Second, Command: time.Minute, Total: 10 * time.Minute},
+		ResolveTimeouts(invocation, saved, host, global))
+	assert.Equal(t, Timeouts{Connect: 30 * time.Second, Command: time.Hour, Total: time.Hour},
+		ResolveTimeouts(Timeouts{}, Timeouts{}, global))
+	assert.True(t, ResolveTimeouts().IsZero())
+}
+
+func TestTimeoutsValidate(t *testing.T) {
+	assert.NoError(t, Timeouts{}.Validate())
+	assert.NoError(t, Timeouts{Connect: time.Second, Total: time.Minute}.Validate())
+	assert.Error(t, Timeouts{Command: -time.Second}.Validate())
+
+	t.Run("hosts cannot set a total timeout", func(t *testing.T) {
+		h := NewHost("web01", "web01", "10.0.0.1")
+		h.User = "deploy"
+		h.Timeouts.Total = time.Minute
+		assert.Error(t, h.Validate())
+	})
+}
+
+func TestTimeoutsYAML(t *testing.T) {
+	m, dir := setupTestManager(t)
+	writeTestFile(t, dir, "hosts.yaml", `type: host
+id: web01
+name: web01
+address: 10.0.0.1
+port: 22
+user: deploy
+timeouts:
+  connect: 5s
+  command: 2m
+`)
+	writeTestFile(t, dir, "commands.yaml", `type: command
+id: backup
+name: backup
+command: /usr/local/bin/backup
+timeouts:
+  total: 1h
+`)
+	require.NoError(t, m.Load())
+
+	h, ok := m.GetHost("web01")
+	require.True(t, ok)
+	assert.Equal(t, Timeouts{Connect: 5 * time.Second, Command: 2 * time.Minute}, h.Timeouts)
+
+	c, ok := m.GetCommand("backup")
+	require.True(t, ok)
+	assert.Equal(t, time.Hour, c.Timeouts.Total)
+
+	conn, err := m.ResolveConnection("web01")
+	require.NoError(t, err)
+	assert.Equal(t, h.Timeouts, conn.Timeouts)
+
+	require.NoError(t, m.UpdateHost(h))
+	data, err := os.ReadFile(filepath.Join(dir, "hosts.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "connect: 5s")
+}
@@ -0,0 +1,174 @@
+package inventory
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TopologyFormat is the diagram language of a topology export.
+type TopologyFormat string
+
+const (
+	// TopologyDOT renders Graphviz DOT, e.g. for "dot -Tsvg".
+	TopologyDOT TopologyFormat = "dot"
+	// TopologyMermaid renders a Mermaid flowchart, which Markdown renderers such as
+	// GitHub's draw inline.
+	TopologyMermaid TopologyFormat = "mermaid"
+)
+
+// TopologyHost is a host of the topology.
+type TopologyHost struct {
+	ID   string
+	Name string
+	// JumpHostID is the host connections are routed through, if any.
+	JumpHostID string
+}
+
+// TopologyGroup is a group of the topology with its direct members.
+type TopologyGroup struct {
+	Name        string
+	HostIDs     []string
+	ChildGroups []string
+}
+
+// Topology is a snapshot of the groups, hosts and jump-host chains of the
+// inventory, for rendering as a diagram.
+type Topology struct {
+	Groups []TopologyGroup
+	Hosts  []TopologyHost
+}
+
+// Topology returns the current topology with groups and hosts sorted by ID.
+// Members that do not exist are left out.
+func (m *Manager) Topology() *Topology {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t := &Topology{}
+	for _, name := range sortedKeys(m.groups) {
+		g := m.groups[name]
+		tg := TopologyGroup{Name: name}
+		for _, id := range g.HostIDs {
+			if _, ok := m.hosts[id]; ok {
+				tg.HostIDs = append(tg.HostIDs, id)
+			}
+		}
+		for _, child := range g.ChildGroupNames {
+			if _, ok := m.groups[child]; ok {
+				tg.ChildGroups = append(tg.ChildGroups, child)
+			}
+		}
+		t.Groups = append(t.Groups, tg)
+	}
+	for _, id := range sortedKeys(m.hosts) {
+		h := m.hosts[id]
+		th := TopologyHost{ID: id, Name: h.Name}
+		if _, ok := m.hosts[h.JumpHostID]; ok {
+			th.JumpHostID = h.JumpHostID
+		}
+		t.Hosts = append(t.Hosts, th)
+	}
+	return t
+}
+
+// Write renders the topology in the given format.
+func (t *Topology) Write(w io.Writer, format TopologyFormat) error {
+	switch format {
+	case TopologyDOT:
+		return t.WriteDOT(w)
+	case TopologyMermaid:
+		return t.WriteMermaid(w)
+	}
+	return fmt.Errorf("unsupported topology format: %q", format)
+}
+
+// WriteDOT renders the topology in the Graphviz DOT language. Groups point to their
+// child groups and hosts; hosts point to their jump host with a dashed "via" edge.
+func (t *Topology) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph topology {\n")
+	b.WriteString("  rankdir=LR;\n")
+
+	for _, g := range t.Groups {
+		fmt.Fprintf(&b, "  %s [label=%s, shape=folder];\n", dotID("group", g.Name), strconv.Quote(g.Name))
+	}
+	for _, h := range t.Hosts {
+		fmt.Fprintf(&b, "  %s [label=%s, shape=box];\n", dotID("host", h.ID), strconv.Quote(h.label()))
+	}
+	for _, g := range t.Groups {
+		for _, child := range g.ChildGroups {
+			fmt.Fprintf(&b, "  %s -> %s;\n", dotID("group", g.Name), dotID("group", child))
+		}
+		for _, id := range g.HostIDs {
+			fmt.Fprintf(&b, "  %s -> %s;\n", dotID("group", g.Name), dotID("host", id))
+		}
+	}
+	for _, h := range t.Hosts {
+		if h.JumpHostID != "" {
+			fmt.Fprintf(&b, "  %s -> %s [label=\"via\", style=dashed];\n", dotID("host", h.ID), dotID("host", h.JumpHostID))
+		}
+	}
+
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMermaid renders the topology as a Mermaid flowchart with the same edges as
+// WriteDOT. Node IDs are generated since Mermaid restricts their characters.
+func (t *Topology) WriteMermaid(w io.Writer) error {
+	ids := map[string]string{}
+	for i, g := range t.Groups {
+		ids["group:"+g.Name] = fmt.Sprintf("g%d", i)
+	}
+	for i, h := range t.Hosts {
+		ids["host:"+h.ID] = fmt.Sprintf("h%d", i)
+	}
+
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+
+	for _, g := range t.Groups {
+		fmt.Fprintf(&b, "  %s[[\"%s\"]]\n", ids["group:"+g.Name], mermaidText(g.Name))
+	}
+	for _, h := range t.Hosts {
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids["host:"+h.ID], mermaidText(h.label()))
+	}
+	for _, g := range t.Groups {
+		for _, child := range g.ChildGroups {
+			fmt.Fprintf(&b, "  %s --> %s\n", ids["group:"+g.Name], ids["group:"+child])
+		}
+		for _, id := range g.HostIDs {
+			fmt.Fprintf(&b, "  %s --> %s\n", ids["group:"+g.Name], ids["host:"+id])
+		}
+	}
+	for _, h := range t.Hosts {
+		if h.JumpHostID != "" {
+			fmt.Fprintf(&b, "  %s -. via .-> %s\n", ids["host:"+h.ID], ids["host:"+h.JumpHostID])
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// label shows the name of a host, with the ID when they differ.
+func (h TopologyHost) label() string {
+	if h.Name == "" || h.Name == h.ID {
+		return h.ID
+	}
+	return h.Name + "\n" + h.ID
+}
+
+// dotID quotes a node ID, prefixed with its kind since groups and hosts may share
+// names.
+func dotID(kind, id string) string {
+	return strconv.Quote(kind + ":" + id)
+}
+
+// mermaidText escapes a node label for a quoted Mermaid string.
+func mermaidText(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "\n", "<br/>").Replace(s)
+}
@@ -0,0 +1,65 @@
+package inventory
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopology(t *testing.T) {
+	m, _ := setupTestManager(t)
+	require.NoError(t, m.Load())
+
+	bastion := NewHost("bastion", "bastion", "203.0.113.1")
+	bastion.User = "ops"
+	require.NoError(t, m.AddHost(bastion))
+	web := NewHost("web01", "Web \"primary\"", "10.0.0.1")
+	web.User = "ops"
+	web.JumpHostID = "bastion"
+	require.NoError(t, m.AddHost(web))
+
+	prod := NewGroup("prod")
+	prod.ChildGroupNames = []string{"web"}
+	require.NoError(t, m.AddGroup(prod))
+	webGroup := NewGroup("web")
+	webGroup.HostIDs = []string{"web01"}
+	require.NoError(t, m.AddGroup(webGroup))
+
+	topo := m.Topology()
+
+	t.Run("dot", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, topo.Write(&buf, TopologyDOT))
+		assert.Equal(t, `digraph topology {
+  rankdir=LR;
+  "group:prod" [label="prod", shape=folder];
+  "group:web" [label="web", shape=folder];
+  "host:bastion" [label="bastion", shape=box];
+  "host:web01" [label="Web \"primary\"\nweb01", shape=box];
+  "group:prod" -> "group:web";
+  "group:web" -> "host:web01";
+  "host:web01" -> "host:bastion" [label="via", style=dashed];
+}
+`, buf.String())
+	})
+
+	t.Run("mermaid", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, topo.Write(&buf, TopologyMermaid))
+		assert.Equal(t, `flowchart LR
+  g0[["prod"]]
+  g1[["web"]]
+  h0["bastion"]
+  h1["Web #quot;primary#quot;<br/>web01"]
+  g0 --> g1
+  g1 --> h1
+  h1 -. via .-> h0
+`, buf.String())
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		assert.ErrorContains(t, topo.Write(&bytes.Buffer{}, "svg"), `unsupported topology format: "svg"`)
+	})
+}
@@ -0,0 +1,25 @@
+package inventory
+
+import "fmt"
+
+// ExecutorTuning holds the performance knobs of the executor, e.g. as measured with
+// executor.Stress for a large fleet.
+type ExecutorTuning struct {
+	// Concurrency is the number of hosts a command runs on at the same time when a
+	// run does not set its own (default executor.DefaultConcurrency).
+	Concurrency int `yaml:"concurrency,omitempty"`
+	// MaxOutput limits the bytes of stdout and of stderr kept per host; the rest is
+	// dropped with a note. Zero keeps everything.
+	MaxOutput int `yaml:"max_output,omitempty"`
+}
+
+// Validate checks that the knobs are not negative.
+func (t ExecutorTuning) Validate() error {
+	if t.Concurrency < 0 {
+		return fmt.Errorf("invalid executor concurrency: %d", t.Concurrency)
+	}
+	if t.MaxOutput < 0 {
+		return fmt.Errorf("invalid executor max output: %d", t.MaxOutput)
+	}
+	return nil
+}
@@ -8,4 +8,9 @@ const (
 	TypeGroup      DocumentType = "group"
 	TypeCredential DocumentType = "credential"
 	TypeConfig     DocumentType = "config"
+	TypeCommand    DocumentType = "command"
+	TypeCheck      DocumentType = "check"
+	TypeWorkflow   DocumentType = "workflow"
+	TypeTheme      DocumentType = "theme"
+	TypeSessionSet DocumentType = "session_set"
 )
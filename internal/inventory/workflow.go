@@ -0,0 +1,405 @@
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Ensure Workflow implements the interfaces
+var (
+	_ Entity = (*Workflow)(nil)
+)
+
+// StepKind is the action of a workflow step.
+type StepKind string
+
+const (
+	// StepExec runs Command, or the saved command CommandID, on the step's targets.
+	StepExec StepKind = "exec"
+	// StepTransfer uploads the local file Source to Destination on the targets.
+	StepTransfer StepKind = "transfer"
+	// StepWait sleeps for Duration or, with Until, polls a command on the targets
+	// every Interval until it succeeds everywhere or Timeout passes.
+	StepWait StepKind = "wait"
+	// StepCheck runs the compliance check CheckID on the targets.
+	StepCheck StepKind = "check"
+)
+
+// StepFailure decides what happens to a workflow when a step fails.
+type StepFailure string
+
+const (
+	// StepAbort stops the workflow; later steps are skipped (the default).
+	StepAbort StepFailure = "abort"
+	// StepContinue runs the next step anyway; the workflow still fails.
+	StepContinue StepFailure = "continue"
+)
+
+// DefaultWaitInterval is the polling interval of wait steps with Until.
+const DefaultWaitInterval = 5 * time.Second
+
+// Workflow is an ordered list of steps run end to end, e.g. build locally, upload
+// the artifact, restart the service and check it: a lightweight alternative to
+// configuration management.
+type Workflow struct {
+	Type        DocumentType `yaml:"type"`
+	ID          string       `yaml:"id"`
+	Name        string       `yaml:"name"`
+	Description string       `yaml:"description,omitempty"`
+
+	// Target is the target spec (see TargetSpec) of steps without their own.
+	Target string `yaml:"target,omitempty"`
+
+	// Inputs are asked for before the first step and available to every step as
+	// {{.Inputs.name}}.
+	Inputs []Input `yaml:"inputs,omitempty"`
+
+	// Snapshot is captured on the targets of all steps before the first step and
+	// after the last one.
+	Snapshot *Snapshot `yaml:"snapshot,omitempty"`
+
+	Steps []WorkflowStep `yaml:"steps"`
+}
+
+// WorkflowStep is a single step of a workflow.
+type WorkflowStep struct {
+	Name string   `yaml:"name"`
+	Kind StepKind `yaml:"kind"`
+
+	// Target overrides the workflow's target spec for this step.
+	Target string `yaml:"target,omitempty"`
+	// OnFailure is StepAbort (default) or StepContinue.
+	OnFailure StepFailure `yaml:"on_failure,omitempty"`
+
+	// Exec
+	Command   string `yaml:"command,omitempty"`
+	CommandID string `yaml:"command_id,omitempty"`
+
+	// Transfer
+	Source      string `yaml:"source,omitempty"`
+	Destination string `yaml:"destination,omitempty"`
+	// Mode is the octal mode of the uploaded file (default "0644").
+	Mode string `yaml:"mode,omitempty"`
+
+	// Wait
+	Duration time.Duration `yaml:"duration,omitempty"`
+	Until    string        `yaml:"until,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty"`
+
+	// Check
+	CheckID string `yaml:"check_id,omitempty"`
+
+	// Timeout limits the step on each host; a wait step with Until gives up after it.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// Artifacts are remote paths fetched from every target after the step ran,
+	// whether it succeeded or not, e.g. logs, reports or core dumps.
+	Artifacts []string `yaml:"artifacts,omitempty"`
+}
+
+// NewWorkflow creates a new Workflow.
+func NewWorkflow(id, name string) *Workflow {
+	return &Workflow{
+		Type: TypeWorkflow,
+		ID:   id,
+		Name: name,
+	}
+}
+
+// GetID Identifiable interface implementation
+func (w *Workflow) GetID() string {
+	return w.ID
+}
+
+// GetName Nameable interface implementation
+func (w *Workflow) GetName() string {
+	return w.Name
+}
+
+func (w *Workflow) SetName(name string) {
+	w.Name = name
+}
+
+// GetDescription Describable interface implementation
+func (w *Workflow) GetDescription() string {
+	return w.Description
+}
+
+func (w *Workflow) SetDescription(desc string) {
+	w.Description = desc
+}
+
+// AddStep appends a step.
+func (w *Workflow) AddStep(s WorkflowStep) {
+	w.Steps = append(w.Steps, s)
+}
+
+// Validate checks if the Workflow has valid configuration.
+func (w *Workflow) Validate() error {
+	if w.ID == "" {
+		return fmt.Errorf("workflow ID cannot be empty")
+	}
+	if w.Name == "" {
+		return fmt.Errorf("workflow %s: name cannot be empty", w.ID)
+	}
+	if len(w.Steps) == 0 {
+		return fmt.Errorf("workflow %s: no steps", w.ID)
+	}
+	if w.Target != "" {
+		if _, err := ParseTargetSpec(w.Target); err != nil {
+			return fmt.Errorf("workflow %s: %w", w.ID, err)
+		}
+	}
+
+	if err := validateInputs(w.Inputs); err != nil {
+		return fmt.Errorf("workflow %s: %w", w.ID, err)
+	}
+	if w.Snapshot != nil {
+		if err := w.Snapshot.Validate(); err != nil {
+			return fmt.Errorf("workflow %s: %w", w.ID, err)
+		}
+	}
+
+	names := map[string]bool{}
+	for i := range w.Steps {
+		s := &w.Steps[i]
+		if err := s.validate(w.Target != ""); err != nil {
+			return fmt.Errorf("workflow %s: step %d: %w", w.ID, i+1, err)
+		}
+		if names[s.Name] {
+			return fmt.Errorf("workflow %s: duplicate step %s", w.ID, s.Name)
+		}
+		names[s.Name] = true
+	}
+	return nil
+}
+
+// validate checks a step; hasTarget reports whether the workflow sets a default target.
+func (s *WorkflowStep) validate(hasTarget bool) error {
+	if s.Name == "" {
+		return fmt.Errorf("name cannot be empty")
+	}
+
+	switch s.OnFailure {
+	case "", StepAbort, StepContinue:
+	default:
+		return fmt.Errorf("%s: invalid on_failure: %s", s.Name, s.OnFailure)
+	}
+	if s.Timeout < 0 {
+		return fmt.Errorf("%s: invalid timeout: %s", s.Name, s.Timeout)
+	}
+
+	if s.Target != "" {
+		if _, err := ParseTargetSpec(s.Target); err != nil {
+			return fmt.Errorf("%s: %w", s.Name, err)
+		}
+	}
+	needsTarget := true
+
+	switch s.Kind {
+	case StepExec:
+		if (strings.TrimSpace(s.Command) == "") == (s.CommandID == "") {
+			return fmt.Errorf("%s: exec steps need either command or command_id", s.Name)
+		}
+	case StepTransfer:
+		if s.Source == "" || s.Destination == "" {
+			return fmt.Errorf("%s: transfer steps need source and destination", s.Name)
+		}
+		if s.Mode != "" {
+			if _, err := parseMode(s.Mode, 0); err != nil {
+				return fmt.Errorf("%s: %w", s.Name, err)
+			}
+		}
+	case StepWait:
+		if (s.Duration > 0) == (strings.TrimSpace(s.Until) != "") {
+			return fmt.Errorf("%s: wait steps need either duration or until", s.Name)
+		}
+		if s.Interval < 0 || s.Duration < 0 {
+			return fmt.Errorf("%s: invalid wait interval", s.Name)
+		}
+		needsTarget = s.Until != ""
+	case StepCheck:
+		if s.CheckID == "" {
+			return fmt.Errorf("%s: check steps need check_id", s.Name)
+		}
+	default:
+		return fmt.Errorf("%s: invalid kind: %s", s.Name, s.Kind)
+	}
+
+	if needsTarget && s.Target == "" && !hasTarget {
+		return fmt.Errorf("%s: no target", s.Name)
+	}
+	for _, p := range s.Artifacts {
+		if !needsTarget {
+			return fmt.Errorf("%s: timed wait steps have no hosts to fetch artifacts from", s.Name)
+		}
+		if strings.TrimSpace(p) == "" {
+			return fmt.Errorf("%s: empty artifact path", s.Name)
+		}
+	}
+	return nil
+}
+
+// Clone creates a deep copy of the Workflow.
+func (w *Workflow) Clone() interface{} {
+	clone := *w
+	clone.Inputs = cloneInputs(w.Inputs)
+	clone.Snapshot = w.Snapshot.Clone()
+	clone.Steps = append([]WorkflowStep(nil), w.Steps...)
+	for i := range clone.Steps {
+		clone.Steps[i].Artifacts = append([]string(nil), w.Steps[i].Artifacts...)
+	}
+	return &clone
+}
+
+// StepTarget returns the target spec of a step.
+func (w *Workflow) StepTarget(s *WorkflowStep) string {
+	if s.Target != "" {
+		return s.Target
+	}
+	return w.Target
+}
+
+// FailureAction returns the configured failure action of a step.
+func (s *WorkflowStep) FailureAction() StepFailure {
+	if s.OnFailure == "" {
+		return StepAbort
+	}
+	return s.OnFailure
+}
+
+// FileMode returns the mode of the file uploaded by a transfer step.
+func (s *WorkflowStep) FileMode() os.FileMode {
+	mode, err := parseMode(s.Mode, DefaultFileMode)
+	if err != nil {
+		return DefaultFileMode
+	}
+	return mode
+}
+
+// WaitInterval returns the polling interval of a wait step.
+func (s *WorkflowStep) WaitInterval() time.Duration {
+	if s.Interval <= 0 {
+		return DefaultWaitInterval
+	}
+	return s.Interval
+}
+
+// ===== Manager =====
+
+// GetWorkflow returns a copy of the workflow with the given ID.
+func (m *Manager) GetWorkflow(id string) (*Workflow, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	w, ok := m.workflows[id]
+	if !ok {
+		return nil, false
+	}
+	return w.Clone().(*Workflow), true
+}
+
+// ListWorkflows returns copies of all workflows sorted by ID.
+func (m *Manager) ListWorkflows() []*Workflow {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	workflows := make([]*Workflow, 0, len(m.workflows))
+	for _, id := range sortedKeys(m.workflows) {
+		workflows = append(workflows, m.workflows[id].Clone().(*Workflow))
+	}
+	return workflows
+}
+
+// AddWorkflow validates and stores a new workflow. The ID policy may rewrite w.ID.
+func (m *Manager) AddWorkflow(w *Workflow) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, err := m.checkNewID(TypeWorkflow, w.ID)
+	if err != nil {
+		return err
+	}
+	w.ID = id
+
+	if _, exists := m.workflows[w.ID]; exists {
+		return fmt.Errorf("workflow %s already exists", w.ID)
+	}
+	if err := m.checkWorkflowRefs(w); err != nil {
+		return err
+	}
+
+	stored := w.Clone().(*Workflow)
+	stored.Type = TypeWorkflow
+	return m.store(stored)
+}
+
+// UpdateWorkflow replaces an existing workflow and rewrites its file.
+func (m *Manager) UpdateWorkflow(w *Workflow) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.workflows[w.ID]; !exists {
+		return fmt.Errorf("workflow %s not found", w.ID)
+	}
+	if err := m.checkWorkflowRefs(w); err != nil {
+		return err
+	}
+
+	stored := w.Clone().(*Workflow)
+	stored.Type = TypeWorkflow
+	m.workflows[w.ID] = stored
+	return m.saveFile(m.sources[entityKey{TypeWorkflow, w.ID}])
+}
+
+// RemoveWorkflow deletes a workflow.
+func (m *Manager) RemoveWorkflow(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.workflows[id]; !exists {
+		return fmt.Errorf("workflow %s not found", id)
+	}
+	return m.saveFile(m.unregister(entityKey{TypeWorkflow, id}))
+}
+
+// checkWorkflowRefs verifies that the saved commands and checks of a workflow exist.
+// Caller must hold the lock.
+func (m *Manager) checkWorkflowRefs(w *Workflow) error {
+	for _, s := range w.Steps {
+		if s.CommandID != "" {
+			if _, ok := m.commands[s.CommandID]; !ok {
+				return fmt.Errorf("workflow %s: step %s: command %s not found", w.ID, s.Name, s.CommandID)
+			}
+		}
+		if s.CheckID != "" {
+			if _, ok := m.checks[s.CheckID]; !ok {
+				return fmt.Errorf("workflow %s: step %s: check %s not found", w.ID, s.Name, s.CheckID)
+			}
+		}
+	}
+	return nil
+}
+
+// workflowUsing returns the ID of a workflow whose steps use a saved command or
+// check, or an empty string. Caller must hold the lock.
+func (m *Manager) workflowUsing(kind DocumentType, id string) string {
+	for _, wid := range sortedKeys(m.workflows) {
+		for _, s := range m.workflows[wid].Steps {
+			if kind == TypeCommand && s.CommandID == id || kind == TypeCheck && s.CheckID == id {
+				return wid
+			}
+		}
+	}
+	return ""
+}
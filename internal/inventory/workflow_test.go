@@ -0,0 +1,123 @@
+package inventory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkflowValidate(t *testing.T) {
+	valid := func() *Workflow {
+		w := NewWorkflow("deploy", "Deploy")
+		w.Target = "group:web"
+		w.AddStep(WorkflowStep{Name: "build", Kind: StepExec, Command: "make", Target: "build"})
+		w.AddStep(WorkflowStep{Name: "upload", Kind: StepTransfer, Source: "app.tar.gz", Destination: "/tmp/app.tar.gz", Mode: "0600"})
+		w.AddStep(WorkflowStep{Name: "settle", Kind: StepWait, Duration: time.Second})
+		w.AddStep(WorkflowStep{Name: "healthy", Kind: StepWait, Until: "curl -fs localhost", Timeout: time.Minute})
+		w.AddStep(WorkflowStep{Name: "verify", Kind: StepCheck, CheckID: "nginx", OnFailure: StepContinue})
+		return w
+	}
+	require.NoError(t, valid().Validate())
+
+	tests := map[string]func(w *Workflow){
+		"no steps":           func(w *Workflow) { w.Steps = nil },
+		"duplicate step":     func(w *Workflow) { w.Steps[1].Name = "build" },
+		"unknown kind":       func(w *Workflow) { w.Steps[0].Kind = "reboot" },
+		"command and id":     func(w *Workflow) { w.Steps[0].CommandID = "make" },
+		"no destination":     func(w *Workflow) { w.Steps[1].Destination = "" },
+		"invalid mode":       func(w *Workflow) { w.Steps[1].Mode = "rw" },
+		"duration and until": func(w *Workflow) { w.Steps[2].Until = "true" },
+		"no check":           func(w *Workflow) { w.Steps[4].CheckID = "" },
+		"invalid on_failure": func(w *Workflow) { w.Steps[4].OnFailure = "retry" },
+		"invalid target":     func(w *Workflow) { w.Steps[0].Target = "rack:a1" },
+		"no target":          func(w *Workflow) { w.Target = "" },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := valid()
+			mutate(w)
+			assert.Error(t, w.Validate())
+		})
+	}
+
+	t.Run("timed waits need no target", func(t *testing.T) {
+		w := NewWorkflow("pause", "Pause")
+		w.AddStep(WorkflowStep{Name: "sleep", Kind: StepWait, Duration: time.Second})
+		assert.NoError(t, w.Validate())
+	})
+}
+
+func TestManagerWorkflows(t *testing.T) {
+	m, dir := setupTestManager(t)
+	writeTestFile(t, dir, "deploy.yaml", `type: command
+id: restart
+name: restart
+command: systemctl restart app
+---
+type: workflow
+id: deploy
+name: Deploy
+target: group:web
+steps:
+  - name: restart
+    kind: exec
+    command_id: restart
+  - name: settle
+    kind: wait
+    duration: 10s
+    on_failure: continue
+`)
+	require.NoError(t, m.Load())
+
+	w, ok := m.GetWorkflow("deploy")
+	require.True(t, ok)
+	require.Len(t, w.Steps, 2)
+	assert.Equal(t, 10*time.Second, w.Steps[1].Duration)
+	assert.Equal(t, StepContinue, w.Steps[1].FailureAction())
+	assert.Equal(t, StepAbort, w.Steps[0].FailureAction())
+	assert.Equal(t, "group:web", w.StepTarget(&w.Steps[0]))
+
+	t.Run("referenced commands cannot be removed", func(t *testing.T) {
+		assert.Error(t, m.RemoveCommand("restart"))
+	})
+
+	t.Run("unknown references are rejected", func(t *testing.T) {
+		w := NewWorkflow("verify", "Verify")
+		w.AddStep(WorkflowStep{Name: "check", Kind: StepCheck, CheckID: "missing", Target: "all"})
+		assert.Error(t, m.AddWorkflow(w))
+	})
+
+	t.Run("crud", func(t *testing.T) {
+		w := NewWorkflow("pause", "Pause")
+		w.AddStep(WorkflowStep{Name: "sleep", Kind: StepWait, Duration: time.Second})
+		require.NoError(t, m.AddWorkflow(w))
+		assert.Len(t, m.ListWorkflows(), 2)
+
+		w.Steps[0].Duration = time.Minute
+		require.NoError(t, m.UpdateWorkflow(w))
+		got, _ := m.GetWorkflow("pause")
+		assert.Equal(t, time.Minute, got.Steps[0].Duration)
+
+		require.NoError(t, m.RemoveWorkflow("pause"))
+		require.NoError(t, m.RemoveWorkflow("deploy"))
+		assert.NoError(t, m.RemoveCommand("restart"))
+	})
+}
+
+func TestWorkflowArtifactsValidate(t *testing.T) {
+	w := NewWorkflow("collect", "Collect")
+	w.Target = "all"
+	w.AddStep(WorkflowStep{Name: "sleep", Kind: StepWait, Duration: time.Second, Artifacts: []string{"/tmp/x"}})
+	assert.Error(t, w.Validate())
+
+	w.Steps[0] = WorkflowStep{Name: "run", Kind: StepExec, Command: "true", Artifacts: []string{" "}}
+	assert.Error(t, w.Validate())
+
+	w.Steps[0].Artifacts = []string{"/var/log/syslog"}
+	require.NoError(t, w.Validate())
+	clone := w.Clone().(*Workflow)
+	clone.Steps[0].Artifacts[0] = "/changed"
+	assert.Equal(t, "/var/log/syslog", w.Steps[0].Artifacts[0])
+}
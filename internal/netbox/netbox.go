@@ -0,0 +1,337 @@
+// Package netbox synchronizes the inventory with a Netbox instance: devices and
+// virtual machines with a primary IP are imported as hosts, and the status and
+// facts of imported hosts can be pushed back to custom fields.
+package netbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gossher/internal/discovery"
+	"gossher/internal/inventory"
+)
+
+// Vars set on imported hosts to find their Netbox object again.
+const (
+	// VarKind holds KindDevice or KindVirtualMachine.
+	VarKind = "netbox_kind"
+	// VarID holds the numeric ID of the object.
+	VarID = "netbox_id"
+)
+
+// Object kinds, as stored in VarKind.
+const (
+	KindDevice         = "device"
+	KindVirtualMachine = "virtual_machine"
+)
+
+// DefaultTimeout limits each API request.
+const DefaultTimeout = 30 * time.Second
+
+// pageSize is the number of objects requested per page.
+const pageSize = 500
+
+// endpoints maps object kinds to their API paths.
+var endpoints = map[string]string{
+	KindDevice:         "/api/dcim/devices/",
+	KindVirtualMachine: "/api/virtualization/virtual-machines/",
+}
+
+// Ensure Client implements the interfaces
+var (
+	_ discovery.Provider = (*Client)(nil)
+)
+
+// Client talks to the REST API of a Netbox instance.
+type Client struct {
+	cfg     inventory.NetboxConfig
+	baseURL *url.URL
+	http    *http.Client
+}
+
+// New creates a client for the configured instance.
+func New(cfg inventory.NetboxConfig) (*Client, error) {
+	if !cfg.Enabled() {
+		return nil, fmt.Errorf("netbox is not configured")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse(strings.TrimSuffix(cfg.URL, "/"))
+	return &Client{cfg: cfg, baseURL: u, http: &http.Client{Timeout: DefaultTimeout}}, nil
+}
+
+// SetHTTPClient replaces the HTTP client, e.g. to trust a private CA.
+func (c *Client) SetHTTPClient(hc *http.Client) {
+	c.http = hc
+}
+
+// Name implements discovery.Provider.
+func (c *Client) Name() string {
+	return "netbox"
+}
+
+// ===== Import =====
+
+// object holds the fields of devices and virtual machines used by the import.
+type object struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Status      *struct {
+		Value string `json:"value"`
+	} `json:"status"`
+	Role       *ref `json:"role"`
+	DeviceRole *ref `json:"device_role"`
+	Site       *ref `json:"site"`
+	Platform   *ref `json:"platform"`
+	Cluster    *ref `json:"cluster"`
+	PrimaryIP  *struct {
+		Address string `json:"address"`
+	} `json:"primary_ip"`
+	Tags []ref `json:"tags"`
+}
+
+// ref is a nested reference to another object.
+type ref struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// label returns the slug of a reference, or its name for objects without slugs.
+func (r *ref) label() string {
+	if r.Slug != "" {
+		return r.Slug
+	}
+	return inventory.NormalizeID(r.Name)
+}
+
+// page is a paginated list response.
+type page struct {
+	Next    string            `json:"next"`
+	Results []json.RawMessage `json:"results"`
+}
+
+// Discover implements discovery.Provider. It lists the devices and virtual
+// machines matching the configured filters; objects without a primary IP are
+// skipped. Hosts are identified by their normalized name, or by kind and Netbox
+// ID for unnamed objects.
+func (c *Client) Discover(ctx context.Context) ([]*inventory.Host, error) {
+	var kinds []string
+	if !c.cfg.SkipDevices {
+		kinds = append(kinds, KindDevice)
+	}
+	if !c.cfg.SkipVirtualMachines {
+		kinds = append(kinds, KindVirtualMachine)
+	}
+
+	var hosts []*inventory.Host
+	seen := make(map[string]bool)
+	for _, kind := range kinds {
+		objects, err := c.list(ctx, kind)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range objects {
+			h, ok := c.host(kind, o)
+			if !ok {
+				continue
+			}
+			if seen[h.ID] {
+				h.ID = fmt.Sprintf("%s-%s", h.ID, kind)
+			}
+			seen[h.ID] = true
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts, nil
+}
+
+// list fetches all objects of a kind, following pagination.
+func (c *Client) list(ctx context.Context, kind string) ([]object, error) {
+	query := url.Values{}
+	for k, v := range c.cfg.Filters {
+		query.Set(k, v)
+	}
+	query.Set("limit", strconv.Itoa(pageSize))
+	next := c.baseURL.String() + endpoints[kind] + "?" + query.Encode()
+
+	var objects []object
+	for next != "" {
+		var p page
+		if err := c.do(ctx, http.MethodGet, next, nil, &p); err != nil {
+			return nil, fmt.Errorf("failed to list netbox %ss: %w", strings.ReplaceAll(kind, "_", " "), err)
+		}
+		for _, raw := range p.Results {
+			var o object
+			if err := json.Unmarshal(raw, &o); err != nil {
+				return nil, fmt.Errorf("failed to decode netbox %s: %w", kind, err)
+			}
+			objects = append(objects, o)
+		}
+		next = p.Next
+	}
+	return objects, nil
+}
+
+// host converts an object to a host; ok is false for objects without a primary IP.
+func (c *Client) host(kind string, o object) (*inventory.Host, bool) {
+	if o.PrimaryIP == nil || o.PrimaryIP.Address == "" {
+		return nil, false
+	}
+	address := o.PrimaryIP.Address
+	if prefix, err := netip.ParsePrefix(address); err == nil {
+		address = prefix.Addr().String()
+	}
+
+	id := inventory.NormalizeID(o.Name)
+	name := o.Name
+	if id == "" {
+		id = fmt.Sprintf("netbox-%s-%d", strings.ReplaceAll(kind, "_", "-"), o.ID)
+		name = id
+	}
+
+	h := inventory.NewHost(id, name, address)
+	h.Description = o.Description
+	h.User = c.cfg.User
+	h.Vars[VarKind] = kind
+	h.Vars[VarID] = strconv.Itoa(o.ID)
+
+	for _, t := range o.Tags {
+		h.AddTag(t.label())
+	}
+	if o.Status != nil && o.Status.Value != "" {
+		h.AddTag("status:" + o.Status.Value)
+	}
+	role := o.Role
+	if role == nil {
+		role = o.DeviceRole
+	}
+	for _, r := range []struct {
+		key string
+		ref *ref
+	}{{"site", o.Site}, {"role", role}, {"platform", o.Platform}, {"cluster", o.Cluster}} {
+		if r.ref != nil {
+			h.AddTag(r.key + ":" + r.ref.label())
+		}
+	}
+	return h, true
+}
+
+// ===== Push =====
+
+// PushResult lists the hosts whose fields were written back by ID.
+type PushResult struct {
+	Pushed  []string
+	Skipped []string
+}
+
+// Push writes the status and facts of the hosts imported from Netbox back to the
+// custom fields named by StatusField and FactFields. Hosts not imported from
+// Netbox, or without any of the values, are skipped. Failed hosts are reported
+// in the joined error; the others are still pushed.
+func (c *Client) Push(ctx context.Context, m *inventory.Manager) (*PushResult, error) {
+	if c.cfg.StatusField == "" && len(c.cfg.FactFields) == 0 {
+		return nil, fmt.Errorf("netbox: no status_field or fact_fields to push")
+	}
+
+	result := &PushResult{}
+	var errs []error
+	for _, h := range m.ListHosts() {
+		kind, id := h.Vars[VarKind], h.Vars[VarID]
+		if endpoints[kind] == "" || id == "" {
+			continue
+		}
+
+		fields := c.customFields(h)
+		if len(fields) == 0 {
+			result.Skipped = append(result.Skipped, h.ID)
+			continue
+		}
+
+		target := c.baseURL.String() + endpoints[kind] + url.PathEscape(id) + "/"
+		body := map[string]any{"custom_fields": fields}
+		if err := c.do(ctx, http.MethodPatch, target, body, nil); err != nil {
+			errs = append(errs, fmt.Errorf("failed to push host %s to netbox: %w", h.ID, err))
+			continue
+		}
+		result.Pushed = append(result.Pushed, h.ID)
+	}
+	return result, errors.Join(errs...)
+}
+
+// customFields returns the custom field values to push for a host.
+func (c *Client) customFields(h *inventory.Host) map[string]string {
+	fields := make(map[string]string)
+	if c.cfg.StatusField != "" && h.Status != inventory.HostStatusUnknown {
+		fields[c.cfg.StatusField] = strings.ToLower(h.Status.String())
+	}
+
+	facts := make([]string, 0, len(c.cfg.FactFields))
+	for fact := range c.cfg.FactFields {
+		facts = append(facts, fact)
+	}
+	sort.Strings(facts)
+	for _, fact := range facts {
+		if v, ok := h.Facts[fact]; ok {
+			fields[c.cfg.FactFields[fact]] = v
+		}
+	}
+	return fields
+}
+
+// ===== HTTP =====
+
+// do sends an API request, encoding body and decoding the response into out
+// when they are not nil.
+func (c *Client) do(ctx context.Context, method, target string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token := c.cfg.APIToken(); token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", method, req.URL.Path, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package netbox
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"gossher/internal/discovery"
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNetbox serves canned device and VM lists and records PATCH requests.
+type fakeNetbox struct {
+	mu      sync.Mutex
+	queries []string
+	patches map[string]map[string]any
+}
+
+func (f *fakeNetbox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Token secret" {
+		http.Error(w, `{"detail":"Invalid token"}`, http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPatch:
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		f.patches[r.URL.Path] = body
+		io.WriteString(w, `{}`)
+
+	case r.URL.Path == "/api/dcim/devices/" && r.URL.Query().Get("offset") == "":
+		f.queries = append(f.queries, r.URL.RawQuery)
+		io.WriteString(w, `{"count": 3, "next": "http://`+r.Host+`/api/dcim/devices/?offset=2", "results": [
+			{"id": 1, "name": "Web 01", "description": "frontend", "status": {"value": "active"},
+			 "role": {"slug": "web"}, "site": {"slug": "ams1"}, "platform": null,
+			 "primary_ip": {"address": "10.0.0.1/24"}, "tags": [{"name": "Managed", "slug": "managed"}]},
+			{"id": 2, "name": "switch01", "primary_ip": null}
+		]}`)
+
+	case r.URL.Path == "/api/dcim/devices/":
+		io.WriteString(w, `{"count": 3, "next": null, "results": [
+			{"id": 3, "name": "db01", "device_role": {"slug": "db"}, "primary_ip": {"address": "2001:db8::3/64"}}
+		]}`)
+
+	case r.URL.Path == "/api/virtualization/virtual-machines/":
+		f.queries = append(f.queries, r.URL.RawQuery)
+		io.WriteString(w, `{"count": 2, "next": null, "results": [
+			{"id": 7, "name": "", "cluster": {"name": "Prod Cluster"}, "primary_ip": {"address": "10.0.1.7/32"}},
+			{"id": 8, "name": "db01", "primary_ip": {"address": "10.0.1.8/32"}}
+		]}`)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func setupNetbox(t *testing.T, cfg inventory.NetboxConfig) (*Client, *fakeNetbox) {
+	f := &fakeNetbox{patches: map[string]map[string]any{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	cfg.URL = srv.URL + "/"
+	if cfg.Token == "" {
+		cfg.Token = "secret"
+	}
+	c, err := New(cfg)
+	require.NoError(t, err)
+	return c, f
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(inventory.NetboxConfig{})
+	assert.ErrorContains(t, err, "not configured")
+
+	_, err = New(inventory.NetboxConfig{URL: "netbox.example.com"})
+	assert.ErrorContains(t, err, "invalid netbox url")
+
+	_, err = New(inventory.NetboxConfig{URL: "https://netbox.example.com", SkipDevices: true, SkipVirtualMachines: true})
+	assert.Error(t, err)
+}
+
+func TestDiscover(t *testing.T) {
+	t.Run("devices and virtual machines", func(t *testing.T) {
+		c, f := setupNetbox(t, inventory.NetboxConfig{User: "deploy", Filters: map[string]string{"site": "ams1"}})
+
+		hosts, err := c.Discover(context.Background())
+		require.NoError(t, err)
+		require.Len(t, hosts, 4)
+
+		web := hosts[0]
+		assert.Equal(t, "web-01", web.ID)
+		assert.Equal(t, "Web 01", web.Name)
+		assert.Equal(t, "10.0.0.1", web.Address)
+		assert.Equal(t, 22, web.Port)
+		assert.Equal(t, "deploy", web.User)
+		assert.Equal(t, "frontend", web.Description)
+		assert.ElementsMatch(t, []string{"managed", "status:active", "site:ams1", "role:web"}, web.Tags)
+		assert.Equal(t, map[string]string{VarKind: KindDevice, VarID: "1"}, web.Vars)
+
+		assert.Equal(t, "db01", hosts[1].ID)
+		assert.Equal(t, "2001:db8::3", hosts[1].Address)
+		assert.Contains(t, hosts[1].Tags, "role:db", "device_role of older versions")
+
+		assert.Equal(t, "netbox-virtual-machine-7", hosts[2].ID)
+		assert.Contains(t, hosts[2].Tags, "cluster:prod-cluster")
+		assert.Equal(t, "db01-virtual_machine", hosts[3].ID, "IDs stay unique")
+
+		for _, q := range f.queries {
+			assert.Contains(t, q, "site=ams1")
+			assert.Contains(t, q, "limit=500")
+		}
+	})
+
+	t.Run("skip kinds", func(t *testing.T) {
+		c, _ := setupNetbox(t, inventory.NetboxConfig{SkipDevices: true})
+
+		hosts, err := c.Discover(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, hosts, 2)
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		c, _ := setupNetbox(t, inventory.NetboxConfig{Token: "wrong"})
+
+		_, err := c.Discover(context.Background())
+		assert.ErrorContains(t, err, "403 Forbidden")
+		assert.ErrorContains(t, err, "Invalid token")
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		c, _ := setupNetbox(t, inventory.NetboxConfig{User: "deploy"})
+		m := inventory.NewManager(t.TempDir())
+		require.NoError(t, m.Load())
+
+		result, err := discovery.Sync(context.Background(), m, c)
+		require.NoError(t, err)
+		assert.Len(t, result.Added, 4)
+
+		h, ok := m.GetHost("web-01")
+		require.True(t, ok)
+		assert.Equal(t, "1", h.Vars[VarID])
+	})
+}
+
+func TestPush(t *testing.T) {
+	c, f := setupNetbox(t, inventory.NetboxConfig{
+		User:        "deploy",
+		StatusField: "ssh_status",
+		FactFields:  map[string]string{"os": "os_release"},
+	})
+	m := inventory.NewManager(t.TempDir())
+	require.NoError(t, m.Load())
+
+	_, err := discovery.Sync(context.Background(), m, c)
+	require.NoError(t, err)
+
+	web, _ := m.GetHost("web-01")
+	web.Facts = map[string]string{"os": "debian 12", "kernel": "6.1"}
+	require.NoError(t, m.UpdateHost(web))
+	require.NoError(t, m.SetHostStatus("web-01", inventory.HostStatusOnline))
+	require.NoError(t, m.SetHostStatus("db01-virtual_machine", inventory.HostStatusOffline))
+
+	manual := inventory.NewHost("manual", "manual", "10.9.9.9")
+	manual.User = "deploy"
+	require.NoError(t, m.AddHost(manual))
+
+	result, err := c.Push(context.Background(), m)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db01-virtual_machine", "web-01"}, result.Pushed)
+	assert.Equal(t, []string{"db01", "netbox-virtual-machine-7"}, result.Skipped)
+
+	assert.Equal(t, map[string]any{"custom_fields": map[string]any{
+		"ssh_status": "online",
+		"os_release": "debian 12",
+	}}, f.patches["/api/dcim/devices/1/"])
+	assert.Equal(t, map[string]any{"custom_fields": map[string]any{
+		"ssh_status": "offline",
+	}}, f.patches["/api/virtualization/virtual-machines/8/"])
+
+	t.Run("nothing to push", func(t *testing.T) {
+		c, _ := setupNetbox(t, inventory.NetboxConfig{})
+		_, err := c.Push(context.Background(), m)
+		assert.Error(t, err)
+	})
+}
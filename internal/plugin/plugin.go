@@ -0,0 +1,432 @@
+// Package plugin extends gossher with external executables that speak JSON over
+// standard input and output, so discovery providers, credential providers,
+// notifiers and output processors can be added without forking.
+//
+// Every call starts the plugin once, writes one request object to its standard
+// input and reads one response object from its standard output:
+//
+//	{"method": "fetch_credential", "params": {"item_id": "web"}}
+//	{"result": {"password": "..."}}  or  {"error": "item not found"}
+//
+// Every plugin answers "describe" with its Manifest. Depending on its
+// capabilities it also answers:
+//
+//	discover                          -> {"hosts": [{"id", "name", "address", "port", "user", "credential_id", "tags", "vars"}]}
+//	fetch_credential {"item_id"}      -> {"password", "private_key", "passphrase"}
+//	notify           {"event": {...}} -> {}
+//	process          {"value": any}   -> {"value": any}
+//
+// The manifest may carry translated messages for the plugin's user-facing strings,
+// keyed by language and message key. They are registered with the i18n catalog
+// under i18n.PluginKey(name, key):
+//
+//	{"name": "vault", ..., "messages": {"en": {"locked": "Vault is locked"}, "ko": {"locked": "볼트가 잠겨 있습니다"}}}
+//
+// Anything the plugin writes to standard error is included in error messages.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"gossher/internal/discovery"
+	"gossher/internal/events"
+	"gossher/internal/executor"
+	"gossher/internal/i18n"
+	"gossher/internal/inventory"
+)
+
+// Ensure Plugin implements the interfaces
+var (
+	_ inventory.CredentialProvider = (*Plugin)(nil)
+	_ discovery.Provider           = (*Plugin)(nil)
+	_ events.Notifier              = (*Plugin)(nil)
+	_ executor.Processor           = (*Plugin)(nil)
+)
+
+// Dir is the subdirectory of the data directory holding plugin executables.
+const Dir = "plugins"
+
+// ProtocolVersion is the version of the protocol plugins must declare.
+const ProtocolVersion = 1
+
+// DefaultTimeout limits a single call to a plugin.
+const DefaultTimeout = 30 * time.Second
+
+// waitDelay bounds the wait for output after a timed out plugin was killed.
+const waitDelay = time.Second
+
+// Capability is a kind of extension a plugin provides.
+type Capability string
+
+const (
+	// CapDiscovery plugins import hosts through discovery.Sync.
+	CapDiscovery Capability = "discovery"
+	// CapCredentials plugins are credential providers selected by Credential.Provider.
+	CapCredentials Capability = "credentials"
+	// CapNotifier plugins receive events from the event bus.
+	CapNotifier Capability = "notifier"
+	// CapProcessor plugins transform extracted output values (OutputSpec.Processor).
+	CapProcessor Capability = "processor"
+)
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Manifest describes a plugin; it is the result of the "describe" call.
+type Manifest struct {
+	Name         string       `json:"name"`
+	Version      string       `json:"version,omitempty"`
+	Protocol     int          `json:"protocol"`
+	Capabilities []Capability `json:"capabilities"`
+	// Events limits the event types sent to notifiers; empty means all.
+	Events []events.Type `json:"events,omitempty"`
+	// Messages holds translations of the plugin's strings by language and key.
+	Messages map[string]map[string]string `json:"messages,omitempty"`
+}
+
+// Validate checks the manifest.
+func (m *Manifest) Validate() error {
+	if !namePattern.MatchString(m.Name) {
+		return fmt.Errorf("invalid plugin name %q", m.Name)
+	}
+	if m.Protocol != ProtocolVersion {
+		return fmt.Errorf("plugin %s: unsupported protocol version %d", m.Name, m.Protocol)
+	}
+	if len(m.Capabilities) == 0 {
+		return fmt.Errorf("plugin %s: no capabilities", m.Name)
+	}
+	for _, c := range m.Capabilities {
+		switch c {
+		case CapDiscovery, CapCredentials, CapNotifier, CapProcessor:
+		default:
+			return fmt.Errorf("plugin %s: unknown capability %s", m.Name, c)
+		}
+	}
+	return nil
+}
+
+// Has reports whether the plugin provides a capability.
+func (m *Manifest) Has(c Capability) bool {
+	for _, have := range m.Capabilities {
+		if have == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Plugin is a loaded plugin executable.
+type Plugin struct {
+	Path     string
+	Manifest Manifest
+	// Timeout limits each call (default DefaultTimeout).
+	Timeout time.Duration
+}
+
+// request is the object written to the plugin.
+type request struct {
+	Method string `json:"method"`
+	Params any    `json:"params,omitempty"`
+}
+
+// response is the object read from the plugin.
+type response struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Load describes the executable at path and validates its manifest.
+func Load(ctx context.Context, path string) (*Plugin, error) {
+	p := &Plugin{Path: path}
+	var m Manifest
+	if err := p.Call(ctx, "describe", nil, &m); err != nil {
+		return nil, err
+	}
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for lang, messages := range m.Messages {
+		if err := registerMessages(m.Name, lang, messages); err != nil {
+			return nil, fmt.Errorf("plugin %s: %w", m.Name, err)
+		}
+	}
+	p.Manifest = m
+	return p, nil
+}
+
+// LoadDir loads every executable in dir, sorted by file name; hidden files are
+// ignored. A missing directory has no plugins. Plugins that fail to load are
+// reported in the joined error, the others are still returned.
+func LoadDir(ctx context.Context, dir string) ([]*Plugin, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	var plugins []*Plugin
+	var errs []error
+	names := map[string]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !isExecutable(info) {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		p, err := Load(ctx, path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if other, ok := names[p.Name()]; ok {
+			errs = append(errs, fmt.Errorf("plugin %s is provided by both %s and %s", p.Name(), other, path))
+			continue
+		}
+		names[p.Name()] = path
+		plugins = append(plugins, p)
+	}
+	return plugins, errors.Join(errs...)
+}
+
+// registerMessages adds the translated strings of a plugin to the i18n catalog.
+func registerMessages(plugin, lang string, messages map[string]string) error {
+	keyed := make(map[string]string, len(messages))
+	for key, msg := range messages {
+		if key == "" {
+			return fmt.Errorf("%s: message key cannot be empty", lang)
+		}
+		keyed[i18n.PluginKey(plugin, key)] = msg
+	}
+	return i18n.Register(lang, keyed)
+}
+
+// isExecutable reports whether a directory entry can be run as a plugin.
+func isExecutable(info os.FileInfo) bool {
+	if !info.Mode().IsRegular() {
+		return false
+	}
+	switch runtime.GOOS {
+	case "windows":
+		switch strings.ToLower(filepath.Ext(info.Name())) {
+		case ".exe", ".bat", ".cmd":
+			return true
+		}
+		return false
+	default:
+		return info.Mode().Perm()&0111 != 0
+	}
+}
+
+// Name returns the plugin name, or the file name before the plugin was described.
+func (p *Plugin) Name() string {
+	if p.Manifest.Name != "" {
+		return p.Manifest.Name
+	}
+	return filepath.Base(p.Path)
+}
+
+// Call runs one request and decodes the result into result, if it is not nil.
+func (p *Plugin) Call(ctx context.Context, method string, params, result any) error {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	input, err := json.Marshal(request{Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("plugin %s: failed to encode %s request: %w", p.Name(), method, err)
+	}
+
+	cmd := exec.CommandContext(ctx, p.Path)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(append(input, '\n'))
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = waitDelay
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return fmt.Errorf("plugin %s: %s: %w%s", p.Name(), method, err, stderrSuffix(&stderr))
+	}
+
+	var resp response
+	if err := json.Unmarshal(bytes.TrimSpace(stdout.Bytes()), &resp); err != nil {
+		return fmt.Errorf("plugin %s: %s: invalid response: %w%s", p.Name(), method, err, stderrSuffix(&stderr))
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("plugin %s: %s: %s", p.Name(), method, resp.Error)
+	}
+	if result != nil && len(resp.Result) > 0 {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("plugin %s: %s: invalid result: %w", p.Name(), method, err)
+		}
+	}
+	return nil
+}
+
+// stderrSuffix formats the standard error of a failed call for error messages.
+func stderrSuffix(stderr *bytes.Buffer) string {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return ": " + msg
+	}
+	return ""
+}
+
+// Register makes the plugin available for its capabilities: as a credential
+// provider, discovery provider and output processor under its name, and as a
+// notifier on bus when bus is not nil. The returned function stops the notifier.
+func (p *Plugin) Register(bus *events.Bus) (stop func()) {
+	stop = func() {}
+	if p.Manifest.Has(CapCredentials) {
+		inventory.RegisterCredentialProvider(p)
+	}
+	if p.Manifest.Has(CapDiscovery) {
+		discovery.Register(p)
+	}
+	if p.Manifest.Has(CapProcessor) {
+		executor.RegisterProcessor(p.Name(), p)
+	}
+	if p.Manifest.Has(CapNotifier) && bus != nil {
+		stop = bus.AddNotifier(p, nil, p.Manifest.Events...)
+	}
+	return stop
+}
+
+// RegisterAll registers plugins and returns a function stopping their notifiers.
+func RegisterAll(plugins []*Plugin, bus *events.Bus) (stop func()) {
+	stops := make([]func(), 0, len(plugins))
+	for _, p := range plugins {
+		stops = append(stops, p.Register(bus))
+	}
+	return func() {
+		for _, s := range stops {
+			s()
+		}
+	}
+}
+
+// ===== Capabilities =====
+
+// secretJSON is the result of fetch_credential.
+type secretJSON struct {
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+// Fetch implements inventory.CredentialProvider.
+func (p *Plugin) Fetch(itemID string) (*inventory.ProviderSecret, error) {
+	var s secretJSON
+	if err := p.Call(context.Background(), "fetch_credential", map[string]string{"item_id": itemID}, &s); err != nil {
+		return nil, err
+	}
+	return &inventory.ProviderSecret{Password: s.Password, PrivateKey: s.PrivateKey, Passphrase: s.Passphrase}, nil
+}
+
+// hostJSON is a host in the result of discover.
+type hostJSON struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name,omitempty"`
+	Description  string            `json:"description,omitempty"`
+	Address      string            `json:"address"`
+	Port         int               `json:"port,omitempty"`
+	User         string            `json:"user,omitempty"`
+	CredentialID string            `json:"credential_id,omitempty"`
+	Tags         []string          `json:"tags,omitempty"`
+	Vars         map[string]string `json:"vars,omitempty"`
+}
+
+// Discover implements discovery.Provider.
+func (p *Plugin) Discover(ctx context.Context) ([]*inventory.Host, error) {
+	var result struct {
+		Hosts []hostJSON `json:"hosts"`
+	}
+	if err := p.Call(ctx, "discover", nil, &result); err != nil {
+		return nil, err
+	}
+
+	hosts := make([]*inventory.Host, 0, len(result.Hosts))
+	for _, hj := range result.Hosts {
+		name := hj.Name
+		if name == "" {
+			name = hj.ID
+		}
+		h := inventory.NewHost(hj.ID, name, hj.Address)
+		h.Description = hj.Description
+		if hj.Port != 0 {
+			h.Port = hj.Port
+		}
+		h.User = hj.User
+		h.CredentialID = hj.CredentialID
+		for _, tag := range hj.Tags {
+			h.AddTag(tag)
+		}
+		for k, v := range hj.Vars {
+			h.SetVar(k, v)
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+// eventJSON is the event sent to notify.
+type eventJSON struct {
+	Type           events.Type `json:"type"`
+	Time           time.Time   `json:"time"`
+	HostID         string      `json:"host_id,omitempty"`
+	Command        string      `json:"command,omitempty"`
+	ExitCode       int         `json:"exit_code,omitempty"`
+	Duration       string      `json:"duration,omitempty"`
+	Error          string      `json:"error,omitempty"`
+	Status         string      `json:"status,omitempty"`
+	PreviousStatus string      `json:"previous_status,omitempty"`
+}
+
+// Notify implements events.Notifier.
+func (p *Plugin) Notify(ctx context.Context, e events.Event) error {
+	ej := eventJSON{
+		Type:           e.Type,
+		Time:           e.Time,
+		HostID:         e.HostID,
+		Command:        e.Command,
+		ExitCode:       e.ExitCode,
+		Error:          e.Error,
+		Status:         e.Status,
+		PreviousStatus: e.PreviousStatus,
+	}
+	if e.Duration > 0 {
+		ej.Duration = e.Duration.String()
+	}
+	return p.Call(ctx, "notify", map[string]any{"event": ej}, nil)
+}
+
+// Process implements executor.Processor.
+func (p *Plugin) Process(value any) (any, error) {
+	var result struct {
+		Value any `json:"value"`
+	}
+	if err := p.Call(context.Background(), "process", map[string]any{"value": value}, &result); err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
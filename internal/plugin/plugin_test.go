@@ -0,0 +1,165 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gossher/internal/discovery"
+	"gossher/internal/events"
+	"gossher/internal/executor"
+	"gossher/internal/i18n"
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePlugin writes a plugin script answering requests by method; body holds the
+// cases of a shell case statement on the request line. Requests are appended to
+// the returned log file.
+func fakePlugin(t *testing.T, dir, name, body string) (path, log string) {
+	log = filepath.Join(dir, name+".log")
+	path = filepath.Join(dir, name)
+	script := "#!/bin/sh\nread -r line\necho \"$line\" >> '" + log + "'\ncase \"$line\" in\n" + body + "\nesac\n"
+	require.NoError(t, os.WriteFile(path, []byte(script), 0755))
+	return path, log
+}
+
+const testPlugin = `*'"describe"'*) echo '{"result": {"name": "vault", "protocol": 1, "capabilities": ["credentials", "discovery", "notifier", "processor"], "events": ["auth_failed"]}}' ;;
+*'"fetch_credential"'*'"item_id":"web"'*) echo '{"result": {"password": "s3cret"}}' ;;
+*'"fetch_credential"'*) echo '{"error": "item not found"}' ;;
+*'"discover"'*) echo '{"result": {"hosts": [{"id": "web01", "address": "10.0.0.1", "user": "deploy", "tags": ["env:prod"], "vars": {"rack": "a1"}}, {"id": "web02", "name": "Web 2", "address": "10.0.0.2", "port": 2222, "user": "deploy"}]}}' ;;
+*'"notify"'*) echo '{}' ;;
+*'"process"'*) echo '{"result": {"value": 42}}' ;;
+*) echo 'unknown method' >&2; exit 1 ;;`
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path, _ := fakePlugin(t, dir, "gossher-vault", testPlugin)
+
+	p, err := Load(context.Background(), path)
+	require.NoError(t, err)
+	assert.Equal(t, "vault", p.Name())
+	assert.True(t, p.Manifest.Has(CapDiscovery))
+
+	t.Run("invalid manifests", func(t *testing.T) {
+		for name, manifest := range map[string]string{
+			"bad-name":     `{"name": "Bad Name", "protocol": 1, "capabilities": ["discovery"]}`,
+			"bad-protocol": `{"name": "x", "protocol": 2, "capabilities": ["discovery"]}`,
+			"no-caps":      `{"name": "x", "protocol": 1}`,
+			"unknown-cap":  `{"name": "x", "protocol": 1, "capabilities": ["teleport"]}`,
+		} {
+			path, _ := fakePlugin(t, dir, name, `*) echo '{"result": `+manifest+`}' ;;`)
+			_, err := Load(context.Background(), path)
+			assert.Error(t, err, name)
+		}
+	})
+
+	t.Run("failures include stderr", func(t *testing.T) {
+		path, _ := fakePlugin(t, dir, "broken", `*) echo 'cannot start' >&2; exit 3 ;;`)
+		_, err := Load(context.Background(), path)
+		assert.ErrorContains(t, err, "cannot start")
+
+		path, _ = fakePlugin(t, dir, "garbage", `*) echo 'not json' ;;`)
+		_, err = Load(context.Background(), path)
+		assert.ErrorContains(t, err, "invalid response")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		path, _ := fakePlugin(t, dir, "slow", `*) sleep 5 ;;`)
+		p := &Plugin{Path: path, Timeout: 100 * time.Millisecond}
+		err := p.Call(context.Background(), "describe", nil, nil)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	fakePlugin(t, dir, "a-vault", testPlugin)
+	fakePlugin(t, dir, "b-vault", testPlugin)
+	fakePlugin(t, dir, "c-broken", `*) exit 1 ;;`)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("not a plugin"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".hidden"), []byte("#!/bin/sh\nexit 1\n"), 0755))
+
+	plugins, err := LoadDir(context.Background(), dir)
+	require.Len(t, plugins, 1)
+	assert.Equal(t, filepath.Join(dir, "a-vault"), plugins[0].Path)
+	assert.ErrorContains(t, err, "provided by both")
+	assert.ErrorContains(t, err, "c-broken")
+
+	plugins, err = LoadDir(context.Background(), filepath.Join(dir, "missing"))
+	assert.NoError(t, err)
+	assert.Empty(t, plugins)
+}
+
+func TestCapabilities(t *testing.T) {
+	dir := t.TempDir()
+	path, log := fakePlugin(t, dir, "gossher-vault", testPlugin)
+	p, err := Load(context.Background(), path)
+	require.NoError(t, err)
+
+	bus := events.NewBus()
+	stop := p.Register(bus)
+
+	t.Run("credentials", func(t *testing.T) {
+		provider, ok := inventory.GetCredentialProvider("vault")
+		require.True(t, ok)
+		secret, err := provider.Fetch("web")
+		require.NoError(t, err)
+		assert.Equal(t, "s3cret", secret.Password)
+
+		_, err = provider.Fetch("db")
+		assert.ErrorContains(t, err, "item not found")
+	})
+
+	t.Run("discovery", func(t *testing.T) {
+		provider, ok := discovery.Get("vault")
+		require.True(t, ok)
+		hosts, err := provider.Discover(context.Background())
+		require.NoError(t, err)
+		require.Len(t, hosts, 2)
+
+		assert.Equal(t, "web01", hosts[0].Name, "name defaults to the ID")
+		assert.Equal(t, 22, hosts[0].Port)
+		assert.True(t, hosts[0].HasTag("env:prod"))
+		assert.Equal(t, "a1", hosts[0].Vars["rack"])
+		assert.Equal(t, "Web 2", hosts[1].Name)
+		assert.Equal(t, 2222, hosts[1].Port)
+	})
+
+	t.Run("processor", func(t *testing.T) {
+		x, err := executor.CompileOutput(inventory.OutputSpec{Name: "n", Processor: "vault", Threshold: &inventory.Threshold{}})
+		assert.Error(t, err, "threshold still validated")
+
+		x, err = executor.CompileOutput(inventory.OutputSpec{Name: "n", Processor: "vault", Numeric: true})
+		require.NoError(t, err)
+		value, err := x.Steps[0].Process("raw output")
+		require.NoError(t, err)
+		assert.Equal(t, 42.0, value)
+	})
+
+	t.Run("notifier", func(t *testing.T) {
+		bus.Publish(events.Event{Type: events.ExecStarted, HostID: "web01"})
+		bus.Publish(events.Event{Type: events.AuthFailed, HostID: "db01", Error: "permission denied"})
+		stop()
+
+		data, err := os.ReadFile(log)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"method":"notify","params":{"event":{"type":"auth_failed"`)
+		assert.Contains(t, string(data), `"host_id":"db01"`)
+		assert.NotContains(t, string(data), "exec_started", "only subscribed events are sent")
+	})
+}
+
+func TestLoadMessages(t *testing.T) {
+	dir := t.TempDir()
+	path, _ := fakePlugin(t, dir, "gossher-vault", `*) echo '{"result": {"name": "vault", "protocol": 1, "capabilities": ["credentials"], "messages": {"en": {"locked": "Vault is locked"}, "ko": {"locked": "볼트가 잠겨 있습니다"}}}}' ;;`)
+
+	_, err := Load(context.Background(), path)
+	require.NoError(t, err)
+	assert.Equal(t, "Vault is locked", i18n.T(i18n.PluginKey("vault", "locked")))
+	assert.Equal(t, "볼트가 잠겨 있습니다", i18n.Default.Translate("ko", i18n.PluginKey("vault", "locked")))
+}
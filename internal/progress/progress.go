@@ -0,0 +1,221 @@
+// Package progress reports the progress of long operations such as group
+// executions, transfers and discovery syncs. Operations start a Task and report
+// work and per-host outcomes to it; the task turns them into Events for a
+// Reporter. Renderers are Reporters: CLI draws a spinner or bar on a terminal,
+// State keeps the latest state of every task for a TUI to draw, and JSON writes
+// the events as JSON lines for API clients.
+package progress
+
+import (
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Unit is what the done and total counts of a task measure.
+type Unit string
+
+const (
+	UnitHosts Unit = "hosts"
+	UnitItems Unit = "items"
+	UnitBytes Unit = "bytes"
+)
+
+// Kind is the kind of a progress event.
+type Kind string
+
+const (
+	// KindStart is reported once when a task starts.
+	KindStart Kind = "start"
+	// KindUpdate is reported when work was done or the total changed.
+	KindUpdate Kind = "update"
+	// KindHost is reported when a host finished; HostID and HostState are set.
+	KindHost Kind = "host"
+	// KindFinish is reported once when the task ends; Error is set on failure.
+	KindFinish Kind = "finish"
+)
+
+// HostState is the outcome of a host.
+type HostState string
+
+const (
+	HostOK      HostState = "ok"
+	HostFailed  HostState = "failed"
+	HostSkipped HostState = "skipped"
+)
+
+// Event is the state of a task after a change.
+type Event struct {
+	Kind  Kind   `json:"kind"`
+	Task  string `json:"task"`
+	Title string `json:"title"`
+	Unit  Unit   `json:"unit"`
+	// Total is zero while the amount of work is unknown.
+	Total int64 `json:"total"`
+	Done  int64 `json:"done"`
+
+	// Succeeded, Failed and Skipped count the finished hosts.
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+
+	HostID    string    `json:"host,omitempty"`
+	HostState HostState `json:"host_state,omitempty"`
+	Error     string    `json:"error,omitempty"`
+
+	Started time.Time `json:"started"`
+	Time    time.Time `json:"time"`
+}
+
+// Fraction returns the completed share of the task, if the total is known.
+func (e Event) Fraction() (float64, bool) {
+	if e.Total <= 0 {
+		return 0, false
+	}
+	f := float64(e.Done) / float64(e.Total)
+	if f > 1 {
+		f = 1
+	}
+	return f, true
+}
+
+// Reporter receives the events of tasks. Report is called synchronously, in order
+// for each task, and must not block.
+type Reporter interface {
+	Report(Event)
+}
+
+// ReporterFunc adapts a function to a Reporter, e.g. to forward events to the
+// event loop of a TUI.
+type ReporterFunc func(Event)
+
+// Report implements Reporter.
+func (f ReporterFunc) Report(e Event) {
+	f(e)
+}
+
+// Nop discards every event.
+var Nop Reporter = ReporterFunc(func(Event) {})
+
+// Multi returns a reporter that forwards every event to each of rs; nil reporters
+// are skipped.
+func Multi(rs ...Reporter) Reporter {
+	return ReporterFunc(func(e Event) {
+		for _, r := range rs {
+			if r != nil {
+				r.Report(e)
+			}
+		}
+	})
+}
+
+// ===== Tasks =====
+
+var taskSeq atomic.Int64
+
+// now returns the current time; replaced in tests.
+var now = time.Now
+
+// Task is a running operation. Its methods are safe for concurrent use and do
+// nothing on a nil Task, so operations can report unconditionally.
+type Task struct {
+	r  Reporter
+	mu sync.Mutex
+	ev Event
+}
+
+// Start starts a task and reports KindStart. A nil reporter discards the events.
+// Total is zero if the amount of work is not known yet.
+func Start(r Reporter, title string, unit Unit, total int64) *Task {
+	if r == nil {
+		r = Nop
+	}
+	task := &Task{r: r, ev: Event{
+		Task:  strconv.FormatInt(taskSeq.Add(1), 10),
+		Title: title,
+		Unit:  unit,
+		Total: total,
+	}}
+	task.report(KindStart, func(e *Event) { e.Started = e.Time })
+	return task
+}
+
+// report applies a change and reports the resulting event.
+func (t *Task) report(kind Kind, change func(*Event)) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.ev.Time = now()
+	change(&t.ev)
+	e := t.ev
+	e.Kind = kind
+	// Host outcomes and errors belong to this event only.
+	t.ev.HostID, t.ev.HostState, t.ev.Error = "", "", ""
+	t.r.Report(e)
+}
+
+// SetTotal sets the amount of work once it is known.
+func (t *Task) SetTotal(total int64) {
+	t.report(KindUpdate, func(e *Event) { e.Total = total })
+}
+
+// Add records n units of done work.
+func (t *Task) Add(n int64) {
+	t.report(KindUpdate, func(e *Event) { e.Done += n })
+}
+
+// Host records the outcome of a host. Tasks counting hosts also advance by one.
+func (t *Task) Host(hostID string, state HostState, err error) {
+	t.report(KindHost, func(e *Event) {
+		e.HostID, e.HostState = hostID, state
+		switch state {
+		case HostOK:
+			e.Succeeded++
+		case HostSkipped:
+			e.Skipped++
+		default:
+			e.Failed++
+		}
+		if e.Unit == UnitHosts {
+			e.Done++
+		}
+		if err != nil {
+			e.Error = err.Error()
+		}
+	})
+}
+
+// Finish ends the task; err is the failure of the task as a whole, if any.
+func (t *Task) Finish(err error) {
+	t.report(KindFinish, func(e *Event) {
+		if err != nil {
+			e.Error = err.Error()
+		}
+	})
+}
+
+// Reader wraps r so that every read adds the bytes read to the task.
+func (t *Task) Reader(r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &countingReader{r: r, t: t}
+}
+
+type countingReader struct {
+	r io.Reader
+	t *Task
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		c.t.Add(int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,124 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gossher/internal/term"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock makes event times advance by one second per event.
+func fakeClock(t *testing.T) {
+	t.Cleanup(func() { now = time.Now })
+	clock := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+}
+
+func TestTask(t *testing.T) {
+	fakeClock(t)
+
+	var events []Event
+	task := Start(ReporterFunc(func(e Event) { events = append(events, e) }), "exec: uptime", UnitHosts, 3)
+	task.Host("web01", HostOK, nil)
+	task.Host("web02", HostFailed, errors.New("exit code 1"))
+	task.Host("web03", HostSkipped, nil)
+	task.Finish(nil)
+
+	require.Len(t, events, 5)
+	assert.Equal(t, KindStart, events[0].Kind)
+	assert.Equal(t, "web02", events[2].HostID)
+	assert.Equal(t, "exit code 1", events[2].Error)
+
+	last := events[4]
+	assert.Equal(t, KindFinish, last.Kind)
+	assert.Equal(t, int64(3), last.Done)
+	assert.Equal(t, []int{1, 1, 1}, []int{last.Succeeded, last.Failed, last.Skipped})
+	assert.Empty(t, last.HostID, "host fields belong to host events only")
+	assert.Empty(t, last.Error)
+
+	t.Run("bytes", func(t *testing.T) {
+		state := NewState()
+		task := Start(state, "transfer: app", UnitBytes, 10)
+		_, err := bytes.NewBuffer(nil).ReadFrom(task.Reader(strings.NewReader("0123456789")))
+		require.NoError(t, err)
+		f, ok := state.Tasks()[0].Fraction()
+		assert.True(t, ok)
+		assert.Equal(t, 1.0, f)
+	})
+
+	t.Run("nil tasks do nothing", func(t *testing.T) {
+		var task *Task
+		task.Add(1)
+		task.Host("web01", HostOK, nil)
+		task.Finish(nil)
+		assert.NotNil(t, task.Reader(strings.NewReader("x")))
+	})
+}
+
+func TestCLI(t *testing.T) {
+	fakeClock(t)
+	t.Cleanup(func() { term.SetPlain(false) })
+	term.SetPlain(true)
+
+	var buf bytes.Buffer
+	task := Start(NewCLI(&buf), "sync: netbox", UnitHosts, 2)
+	task.Host("web01", HostOK, nil)
+	task.Host("web02", HostFailed, errors.New("address cannot be empty"))
+	task.Finish(errors.New("1 host failed"))
+
+	assert.Equal(t, `sync: netbox: started
+sync: netbox: web01: ok
+sync: netbox: web02: address cannot be empty
+sync: netbox: failed: 1 host failed, 1 ok, 1 failed, in 3s
+`, buf.String())
+}
+
+func TestLine(t *testing.T) {
+	e := Event{Kind: KindUpdate, Title: "exec: uptime", Unit: UnitHosts, Total: 4, Done: 2, Succeeded: 1, Failed: 1}
+	assert.Equal(t, "exec: uptime [############............] 2/4 hosts  1 ok, 1 failed", Line(e, 0))
+
+	e = Event{Kind: KindUpdate, Title: "transfer: app", Unit: UnitBytes, Done: 1536}
+	assert.Equal(t, `/ transfer: app 1.5 KiB`, Line(e, 1))
+}
+
+func TestState(t *testing.T) {
+	state := NewState()
+	a := Start(state, "a", UnitItems, 0)
+	b := Start(state, "b", UnitItems, 0)
+	assert.True(t, state.Running())
+
+	a.Finish(nil)
+	b.Add(2)
+	state.Clear()
+	tasks := state.Tasks()
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "b", tasks[0].Title)
+	assert.Equal(t, int64(2), tasks[0].Done)
+}
+
+func TestJSON(t *testing.T) {
+	fakeClock(t)
+
+	var buf bytes.Buffer
+	task := Start(JSON(&buf), "exec: id", UnitHosts, 1)
+	task.Host("web01", HostOK, nil)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var e Event
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &e))
+	assert.Equal(t, KindHost, e.Kind)
+	assert.Equal(t, "web01", e.HostID)
+	assert.Equal(t, HostOK, e.HostState)
+	assert.Contains(t, lines[1], `"host_state":"ok"`)
+}
@@ -0,0 +1,253 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"gossher/internal/term"
+)
+
+// ===== CLI =====
+
+// spinner are the frames drawn for tasks without a known total. They are plain
+// ASCII so every terminal font has them.
+var spinner = []string{"|", "/", "-", `\`}
+
+// barWidth is the number of cells of a progress bar.
+const barWidth = 24
+
+// redrawInterval limits how often a live line is redrawn for byte updates.
+const redrawInterval = 100 * time.Millisecond
+
+// CLI renders tasks for a command line. On a terminal it redraws one status line
+// per task with a spinner or a bar. In plain mode (see package term) or when the
+// output is not a terminal it writes one line per host and a summary instead, which
+// screen readers and log files can follow.
+type CLI struct {
+	w    io.Writer
+	live bool
+
+	mu    sync.Mutex
+	frame int
+	drawn time.Time
+}
+
+// NewCLI creates a CLI renderer writing to w.
+func NewCLI(w io.Writer) *CLI {
+	return &CLI{w: w, live: term.IsTerminal(w) && !term.Plain()}
+}
+
+// Report implements Reporter.
+func (c *CLI) Report(e Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.live {
+		c.redraw(e)
+		return
+	}
+
+	switch e.Kind {
+	case KindStart:
+		fmt.Fprintf(c.w, "%s: started\n", e.Title)
+	case KindHost:
+		if e.Error != "" {
+			fmt.Fprintf(c.w, "%s: %s: %s\n", e.Title, e.HostID, e.Error)
+		} else {
+			fmt.Fprintf(c.w, "%s: %s: %s\n", e.Title, e.HostID, e.HostState)
+		}
+	case KindFinish:
+		fmt.Fprintf(c.w, "%s: %s\n", e.Title, summary(e))
+	}
+}
+
+// redraw replaces the status line. Caller must hold the lock.
+func (c *CLI) redraw(e Event) {
+	if e.Kind == KindUpdate && e.Time.Sub(c.drawn) < redrawInterval {
+		return
+	}
+	c.drawn = e.Time
+	c.frame++
+
+	line := Line(e, c.frame)
+	if e.Kind == KindFinish {
+		fmt.Fprintf(c.w, "\r\x1b[K%s\n", line)
+		return
+	}
+	if e.Kind == KindHost && e.HostState == HostFailed {
+		// Keep failures on screen above the status line.
+		msg := e.Error
+		if msg == "" {
+			msg = string(e.HostState)
+		}
+		fmt.Fprintf(c.w, "\r\x1b[K%s: %s\n", e.HostID, msg)
+	}
+	fmt.Fprintf(c.w, "\r\x1b[K%s", line)
+}
+
+// Line formats the status line of a task, e.g. for a TUI status bar. frame selects
+// the spinner frame of tasks without a known total.
+func Line(e Event, frame int) string {
+	if e.Kind == KindFinish {
+		return e.Title + ": " + summary(e)
+	}
+
+	var b strings.Builder
+	if f, ok := e.Fraction(); ok {
+		filled := int(f * barWidth)
+		fmt.Fprintf(&b, "%s [%s%s] %s", e.Title, strings.Repeat("#", filled), strings.Repeat(".", barWidth-filled), count(e))
+	} else {
+		fmt.Fprintf(&b, "%s %s %s", spinner[frame%len(spinner)], e.Title, count(e))
+	}
+	if hosts := hostCounts(e); hosts != "" {
+		b.WriteString("  " + hosts)
+	}
+	return b.String()
+}
+
+// count formats the done and total amounts.
+func count(e Event) string {
+	format := func(n int64) string { return fmt.Sprint(n) }
+	if e.Unit == UnitBytes {
+		format = FormatBytes
+	}
+	amount := format(e.Done)
+	if e.Total > 0 {
+		amount += "/" + format(e.Total)
+	}
+	if e.Unit == UnitBytes {
+		return amount
+	}
+	return amount + " " + string(e.Unit)
+}
+
+// hostCounts formats the host outcomes, e.g. "3 ok, 1 failed".
+func hostCounts(e Event) string {
+	var parts []string
+	if e.Succeeded > 0 {
+		parts = append(parts, fmt.Sprintf("%d ok", e.Succeeded))
+	}
+	if e.Failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", e.Failed))
+	}
+	if e.Skipped > 0 {
+		parts = append(parts, fmt.Sprintf("%d skipped", e.Skipped))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// summary describes a finished task.
+func summary(e Event) string {
+	status := "done"
+	if e.Error != "" {
+		status = "failed: " + e.Error
+	}
+	parts := []string{status}
+	if hosts := hostCounts(e); hosts != "" {
+		parts = append(parts, hosts)
+	}
+	if e.Unit == UnitBytes && e.Done > 0 {
+		parts = append(parts, FormatBytes(e.Done))
+	}
+	parts = append(parts, "in "+e.Time.Sub(e.Started).Round(time.Millisecond).String())
+	return strings.Join(parts, ", ")
+}
+
+// FormatBytes formats a size with binary units, e.g. "1.5 MiB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// ===== TUI =====
+
+// State keeps the latest event of every task, for a TUI that redraws on its own
+// schedule. Finished tasks are kept until Clear.
+type State struct {
+	mu    sync.Mutex
+	tasks map[string]Event
+	order []string
+}
+
+// NewState creates an empty State.
+func NewState() *State {
+	return &State{tasks: make(map[string]Event)}
+}
+
+// Report implements Reporter.
+func (s *State) Report(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tasks[e.Task]; !ok {
+		s.order = append(s.order, e.Task)
+	}
+	s.tasks[e.Task] = e
+}
+
+// Tasks returns the latest event of every task in start order.
+func (s *State) Tasks() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := make([]Event, 0, len(s.order))
+	for _, id := range s.order {
+		events = append(events, s.tasks[id])
+	}
+	return events
+}
+
+// Running reports whether a task has not finished yet.
+func (s *State) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.tasks {
+		if e.Kind != KindFinish {
+			return true
+		}
+	}
+	return false
+}
+
+// Clear drops the finished tasks.
+func (s *State) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.order[:0]
+	for _, id := range s.order {
+		if s.tasks[id].Kind == KindFinish {
+			delete(s.tasks, id)
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.order = kept
+}
+
+// ===== JSON =====
+
+// JSON returns a reporter writing every event as one JSON object per line, e.g. to
+// stream progress to API clients. Write errors are ignored.
+func JSON(w io.Writer) Reporter {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return ReporterFunc(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(e)
+	})
+}
@@ -0,0 +1,139 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"gossher/internal/transport"
+)
+
+// requestBuffer is the number of frames buffered per request before the reader
+// waits for the request to catch up.
+const requestBuffer = 64
+
+// Agent serves relay requests on the relay host.
+type Agent struct {
+	// Dialer reaches the hosts of the segment.
+	Dialer transport.Dialer
+}
+
+// Serve answers the requests read from r on w until r ends. Requests run
+// concurrently; a request is cancelled when the client cancels it, when ctx is
+// cancelled or when Serve returns, which waits for the requests to finish.
+func (a *Agent) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	type request struct {
+		ctx    context.Context
+		cancel context.CancelFunc
+		frames chan frame
+	}
+	var (
+		mu       sync.Mutex
+		requests = map[uint64]*request{}
+		wg       sync.WaitGroup
+	)
+	ctx, cancel := context.WithCancel(ctx)
+	defer wg.Wait()
+	defer cancel()
+
+	c := newConn(r, w)
+	if err := c.write(frame{Kind: kindHello, Version: ProtocolVersion}); err != nil {
+		return err
+	}
+
+	for {
+		f, err := c.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		req := requests[f.ID]
+		mu.Unlock()
+
+		switch f.Kind {
+		case kindExec, kindUpload, kindDownload:
+			if req != nil || f.Conn == nil {
+				_ = c.write(frame{ID: f.ID, Kind: kindDone, ExitCode: -1, Error: "invalid request"})
+				continue
+			}
+			reqCtx, reqCancel := context.WithCancel(ctx)
+			req = &request{ctx: reqCtx, cancel: reqCancel, frames: make(chan frame, requestBuffer)}
+			mu.Lock()
+			requests[f.ID] = req
+			mu.Unlock()
+
+			wg.Add(1)
+			go func(f frame, req *request) {
+				defer wg.Done()
+				done := a.handle(req.ctx, c, f, req.frames)
+				req.cancel()
+				mu.Lock()
+				delete(requests, f.ID)
+				mu.Unlock()
+				_ = c.write(done)
+			}(f, req)
+
+		case kindData, kindEOF:
+			if req != nil {
+				select {
+				case req.frames <- f:
+				case <-req.ctx.Done():
+				}
+			}
+
+		case kindCancel:
+			if req != nil {
+				req.cancel()
+			}
+		}
+	}
+}
+
+// handle runs one request and returns its done frame.
+func (a *Agent) handle(ctx context.Context, c *conn, f frame, frames <-chan frame) frame {
+	done := frame{ID: f.ID, Kind: kindDone}
+	fail := func(err error) frame {
+		done.ExitCode = -1
+		done.Error = err.Error()
+		return done
+	}
+
+	s, err := a.Dialer.Dial(ctx, f.Conn)
+	if err != nil {
+		return fail(err)
+	}
+	defer s.Close()
+
+	switch f.Kind {
+	case kindExec:
+		done.ExitCode, err = s.Run(ctx, f.Command,
+			dataWriter{c: c, id: f.ID, stream: streamStdout},
+			dataWriter{c: c, id: f.ID, stream: streamStderr})
+		if err != nil {
+			return fail(err)
+		}
+		return done
+	}
+
+	t, err := s.Transfer(ctx)
+	if err != nil {
+		return fail(fmt.Errorf("failed to open transfer to %s: %w", f.Conn.HostID, err))
+	}
+	defer t.Close()
+
+	if f.Kind == kindUpload {
+		err = t.Upload(ctx, &chunkReader{ctx: ctx, frames: frames}, f.Path, f.Mode)
+	} else {
+		err = t.Download(ctx, f.Path, dataWriter{c: c, id: f.ID, stream: streamFile})
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return done
+}
@@ -0,0 +1,330 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"gossher/internal/inventory"
+	"gossher/internal/transport"
+)
+
+// Ensure the types implement the interfaces
+var (
+	_ transport.Dialer   = (*Client)(nil)
+	_ transport.Dialer   = (*Dialer)(nil)
+	_ transport.Session  = (*session)(nil)
+	_ transport.Transfer = (*session)(nil)
+)
+
+// ErrClosed is returned for requests on a client whose agent connection ended.
+var ErrClosed = errors.New("relay agent connection closed")
+
+// pending is a request waiting for its frames.
+type pending struct {
+	id     uint64
+	frames chan frame
+	// gone is closed when the request stopped reading frames.
+	gone chan struct{}
+}
+
+// Client sends requests to an agent over a single stream, such as the stdin and
+// stdout of AgentCommand run over SSH on the relay host. It is a transport.Dialer
+// for the hosts behind the relay.
+type Client struct {
+	c      *conn
+	closer io.Closer
+	relay  string
+
+	ready chan struct{}
+	done  chan struct{}
+
+	mu      sync.Mutex
+	next    uint64
+	pending map[uint64]*pending
+	err     error
+}
+
+// NewClient starts a client on the stream of the agent on the relay host relayID.
+func NewClient(relayID string, rwc io.ReadWriteCloser) *Client {
+	cl := &Client{
+		c:       newConn(rwc, rwc),
+		closer:  rwc,
+		relay:   relayID,
+		ready:   make(chan struct{}),
+		done:    make(chan struct{}),
+		pending: make(map[uint64]*pending),
+	}
+	go cl.readLoop()
+	return cl
+}
+
+// readLoop checks the agent's hello and delivers frames to their requests.
+func (cl *Client) readLoop() {
+	err := cl.hello()
+	if err == nil {
+		close(cl.ready)
+		for {
+			var f frame
+			if f, err = cl.c.read(); err != nil {
+				break
+			}
+			cl.mu.Lock()
+			p := cl.pending[f.ID]
+			cl.mu.Unlock()
+			if p != nil {
+				select {
+				case p.frames <- f:
+				case <-p.gone:
+				}
+			}
+		}
+	}
+
+	if errors.Is(err, io.EOF) {
+		err = ErrClosed
+	}
+	cl.mu.Lock()
+	cl.err = err
+	for id, p := range cl.pending {
+		close(p.frames)
+		delete(cl.pending, id)
+	}
+	cl.mu.Unlock()
+	close(cl.done)
+}
+
+// hello reads the first frame and checks the protocol version.
+func (cl *Client) hello() error {
+	f, err := cl.c.read()
+	if err != nil {
+		return fmt.Errorf("relay %s: agent did not start: %w", cl.relay, err)
+	}
+	if f.Kind != kindHello || f.Version != ProtocolVersion {
+		return fmt.Errorf("relay %s: agent speaks protocol version %d, want %d", cl.relay, f.Version, ProtocolVersion)
+	}
+	return nil
+}
+
+// Err returns why the client stopped, or nil while it runs.
+func (cl *Client) Err() error {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	return cl.err
+}
+
+// Close closes the stream to the agent, which ends its requests.
+func (cl *Client) Close() error {
+	err := cl.closer.Close()
+	<-cl.done
+	return err
+}
+
+// Dial returns a session to the host of conn through the agent. The agent
+// connects for every command and transfer, so Dial itself does not reach the host.
+func (cl *Client) Dial(ctx context.Context, conn *inventory.ResolvedConnection) (transport.Session, error) {
+	select {
+	case <-cl.ready:
+	case <-cl.done:
+		return nil, cl.Err()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	target, err := forward(conn)
+	if err != nil {
+		return nil, err
+	}
+	return &session{cl: cl, conn: target}, nil
+}
+
+// forward copies a connection for the agent: without the relay, and with key files
+// read into PrivateKey, as the paths are those of this machine. The keys are only
+// sent over the encrypted channel to the agent.
+func forward(conn *inventory.ResolvedConnection) (*inventory.ResolvedConnection, error) {
+	c := *conn
+	c.Relay = nil
+	c.DataDir = ""
+	c.PassphraseFile = ""
+	if c.KeyPath != "" && c.PrivateKey == "" {
+		data, err := os.ReadFile(c.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("host %s: failed to read key for relay: %w", c.HostID, err)
+		}
+		c.PrivateKey = string(data)
+	}
+	c.KeyPath = ""
+
+	c.Jumps = make([]*inventory.ResolvedConnection, len(conn.Jumps))
+	for i, j := range conn.Jumps {
+		jump, err := forward(j)
+		if err != nil {
+			return nil, err
+		}
+		c.Jumps[i] = jump
+	}
+	return &c, nil
+}
+
+// start sends the frame starting a request and registers it.
+func (cl *Client) start(f frame) (*pending, error) {
+	p := &pending{frames: make(chan frame, requestBuffer), gone: make(chan struct{})}
+
+	cl.mu.Lock()
+	if cl.err != nil {
+		cl.mu.Unlock()
+		return nil, cl.err
+	}
+	cl.next++
+	p.id = cl.next
+	cl.pending[p.id] = p
+	cl.mu.Unlock()
+
+	f.ID = p.id
+	if err := cl.c.write(f); err != nil {
+		cl.finish(p)
+		return nil, err
+	}
+	return p, nil
+}
+
+// finish unregisters a request.
+func (cl *Client) finish(p *pending) {
+	close(p.gone)
+	cl.mu.Lock()
+	delete(cl.pending, p.id)
+	cl.mu.Unlock()
+}
+
+// cancel tells the agent to abandon a request.
+func (cl *Client) cancel(p *pending) {
+	_ = cl.c.write(frame{ID: p.id, Kind: kindCancel})
+}
+
+// wait passes the data frames of a request to onData until its done frame, and
+// cancels the request when ctx is done.
+func (cl *Client) wait(ctx context.Context, p *pending, onData func(frame) error) (frame, error) {
+	defer cl.finish(p)
+	for {
+		select {
+		case f, ok := <-p.frames:
+			if !ok {
+				return frame{}, cl.Err()
+			}
+			switch f.Kind {
+			case kindData:
+				if err := onData(f); err != nil {
+					cl.cancel(p)
+					return frame{}, err
+				}
+			case kindDone:
+				if f.Error != "" {
+					return f, fmt.Errorf("relay %s: %s", cl.relay, f.Error)
+				}
+				return f, nil
+			}
+		case <-ctx.Done():
+			cl.cancel(p)
+			return frame{}, ctx.Err()
+		}
+	}
+}
+
+// ===== Session =====
+
+// session runs the requests of one target host. Closing it is a no-op: the
+// agent connection is shared.
+type session struct {
+	cl   *Client
+	conn *inventory.ResolvedConnection
+}
+
+func (s *session) Run(ctx context.Context, command string, stdout, stderr io.Writer) (int, error) {
+	p, err := s.cl.start(frame{Kind: kindExec, Conn: s.conn, Command: command})
+	if err != nil {
+		return -1, err
+	}
+	done, err := s.cl.wait(ctx, p, func(f frame) error {
+		w := stdout
+		if f.Stream == streamStderr {
+			w = stderr
+		}
+		_, err := w.Write(f.Data)
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+	return done.ExitCode, nil
+}
+
+func (s *session) Transfer(ctx context.Context) (transport.Transfer, error) {
+	return s, nil
+}
+
+func (s *session) Upload(ctx context.Context, src io.Reader, remotePath string, mode os.FileMode) error {
+	p, err := s.cl.start(frame{Kind: kindUpload, Conn: s.conn, Path: remotePath, Mode: mode})
+	if err != nil {
+		return err
+	}
+
+	// Send the file while watching for an early answer, e.g. a failed login; the
+	// sender stops reading src once the request is over.
+	stop := make(chan struct{})
+	sent := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(dataWriter{c: s.cl.c, id: p.id, stream: streamFile}, stoppableReader{src, stop})
+		if err == nil {
+			err = s.cl.c.write(frame{ID: p.id, Kind: kindEOF})
+		}
+		sent <- err
+	}()
+
+	_, err = s.cl.wait(ctx, p, func(frame) error { return nil })
+	close(stop)
+	if err != nil {
+		<-sent
+		return err
+	}
+	if err := <-sent; err != nil {
+		return fmt.Errorf("failed to upload %s through relay %s: %w", remotePath, s.cl.relay, err)
+	}
+	return nil
+}
+
+func (s *session) Download(ctx context.Context, remotePath string, dst io.Writer) error {
+	p, err := s.cl.start(frame{Kind: kindDownload, Conn: s.conn, Path: remotePath})
+	if err != nil {
+		return err
+	}
+	_, err = s.cl.wait(ctx, p, func(f frame) error {
+		_, err := dst.Write(f.Data)
+		return err
+	})
+	return err
+}
+
+func (s *session) Close() error {
+	return nil
+}
+
+// errStopped ends the upload of a request that is already over.
+var errStopped = errors.New("upload stopped")
+
+// stoppableReader reads from r until stop is closed.
+type stoppableReader struct {
+	r    io.Reader
+	stop <-chan struct{}
+}
+
+func (r stoppableReader) Read(p []byte) (int, error) {
+	select {
+	case <-r.stop:
+		return 0, errStopped
+	default:
+		return r.r.Read(p)
+	}
+}
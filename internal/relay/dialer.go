@@ -0,0 +1,80 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"gossher/internal/inventory"
+	"gossher/internal/transport"
+)
+
+// OpenFunc starts the agent on a relay host and returns its stdin and stdout as
+// one stream, typically by running AgentCommand in an SSH session to relay.
+type OpenFunc func(ctx context.Context, relay *inventory.ResolvedConnection) (io.ReadWriteCloser, error)
+
+// Dialer reaches hosts with a relay host through the agent on the relay and all
+// other hosts with the regular Dialer. One agent connection per relay host is
+// opened on first use and shared by all hosts behind it.
+type Dialer struct {
+	dialer transport.Dialer
+	open   OpenFunc
+
+	mu      sync.Mutex
+	clients map[string]*Client
+}
+
+// NewDialer creates a Dialer that reaches directly connected hosts with d and
+// starts agents with open.
+func NewDialer(d transport.Dialer, open OpenFunc) *Dialer {
+	return &Dialer{dialer: d, open: open, clients: make(map[string]*Client)}
+}
+
+// Dial connects to the host of conn, through its relay if it has one.
+func (d *Dialer) Dial(ctx context.Context, conn *inventory.ResolvedConnection) (transport.Session, error) {
+	if conn.Relay == nil {
+		return d.dialer.Dial(ctx, conn)
+	}
+	cl, err := d.client(ctx, conn.Relay)
+	if err != nil {
+		return nil, err
+	}
+	return cl.Dial(ctx, conn)
+}
+
+// client returns the running client of a relay host, starting the agent if needed.
+func (d *Dialer) client(ctx context.Context, relay *inventory.ResolvedConnection) (*Client, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if cl, ok := d.clients[relay.HostID]; ok {
+		if cl.Err() == nil {
+			return cl, nil
+		}
+		delete(d.clients, relay.HostID)
+	}
+
+	rwc, err := d.open(ctx, relay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start agent on relay %s: %w", relay.HostID, err)
+	}
+	cl := NewClient(relay.HostID, rwc)
+	d.clients[relay.HostID] = cl
+	return cl, nil
+}
+
+// Close stops the agent connections.
+func (d *Dialer) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var first error
+	for id, cl := range d.clients {
+		if err := cl.Close(); err != nil && first == nil {
+			first = err
+		}
+		delete(d.clients, id)
+	}
+	return first
+}
@@ -0,0 +1,175 @@
+// Package relay reaches hosts of isolated network segments through a gossher-agent
+// running on a relay host. The agent is started over SSH on the relay (see
+// AgentCommand) and speaks this package's protocol on its stdin and stdout, so all
+// commands and file transfers to the segment share that single SSH channel.
+//
+// A Dialer routes the connections of hosts with a relay_host_id to the agent of
+// their relay and all others to the regular transport, which keeps relaying
+// transparent to the executor. The agent reaches the segment's hosts with its own
+// transport.Dialer; cmd/gossher-agent uses the ssh client of the relay host.
+//
+// The protocol is a stream of JSON frames, one per line. Each request has an ID;
+// the frames of concurrent requests are interleaved.
+package relay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"gossher/internal/inventory"
+)
+
+// ProtocolVersion is the version of the frame protocol; the agent announces it in
+// its first frame and clients refuse agents of another version.
+const ProtocolVersion = 1
+
+// AgentCommand is the command that starts the agent on a relay host.
+const AgentCommand = "gossher-agent"
+
+// chunkSize is the maximum payload of a data frame.
+const chunkSize = 32 * 1024
+
+// Frame kinds.
+const (
+	// kindHello is sent by the agent when it starts.
+	kindHello = "hello"
+	// kindExec, kindUpload and kindDownload start requests.
+	kindExec     = "exec"
+	kindUpload   = "upload"
+	kindDownload = "download"
+	// kindData carries output, or file contents in either direction.
+	kindData = "data"
+	// kindEOF ends the data of an upload.
+	kindEOF = "eof"
+	// kindDone ends a request with its exit code or error.
+	kindDone = "done"
+	// kindCancel abandons a request.
+	kindCancel = "cancel"
+)
+
+// Data streams.
+const (
+	streamStdout = "stdout"
+	streamStderr = "stderr"
+	streamFile   = "file"
+)
+
+// frame is a single protocol message. Fields that do not apply to the kind are empty.
+type frame struct {
+	ID   uint64 `json:"id,omitempty"`
+	Kind string `json:"kind"`
+
+	// Version is set on hello frames.
+	Version int `json:"version,omitempty"`
+
+	// Conn is the connection of the target host of exec, upload and download frames.
+	Conn    *inventory.ResolvedConnection `json:"conn,omitempty"`
+	Command string                        `json:"command,omitempty"`
+	Path    string                        `json:"path,omitempty"`
+	Mode    os.FileMode                   `json:"mode,omitempty"`
+
+	Stream string `json:"stream,omitempty"`
+	Data   []byte `json:"data,omitempty"`
+
+	ExitCode int    `json:"exit_code,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// conn reads and writes frames; writes may come from several goroutines.
+type conn struct {
+	dec *json.Decoder
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{dec: json.NewDecoder(bufio.NewReader(r)), enc: json.NewEncoder(w)}
+}
+
+// read returns the next frame.
+func (c *conn) read() (frame, error) {
+	var f frame
+	if err := c.dec.Decode(&f); err != nil {
+		if err == io.EOF {
+			return f, err
+		}
+		return f, fmt.Errorf("failed to read relay frame: %w", err)
+	}
+	return f, nil
+}
+
+// write sends a frame.
+func (c *conn) write(f frame) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.enc.Encode(f); err != nil {
+		return fmt.Errorf("failed to write relay frame: %w", err)
+	}
+	return nil
+}
+
+// dataWriter sends everything written to it as data frames of a request.
+type dataWriter struct {
+	c      *conn
+	id     uint64
+	stream string
+}
+
+func (w dataWriter) Write(p []byte) (int, error) {
+	for written := 0; written < len(p); {
+		n := min(len(p)-written, chunkSize)
+		if err := w.c.write(frame{ID: w.id, Kind: kindData, Stream: w.stream, Data: p[written : written+n]}); err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return len(p), nil
+}
+
+// chunkReader reads the data frames delivered to a channel until an EOF frame or
+// until ctx is done.
+type chunkReader struct {
+	ctx    context.Context
+	frames <-chan frame
+	buf    []byte
+	err    error
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		var (
+			f  frame
+			ok bool
+		)
+		select {
+		case f, ok = <-r.frames:
+		case <-r.ctx.Done():
+			r.err = r.ctx.Err()
+			continue
+		}
+		switch {
+		case !ok:
+			r.err = io.ErrUnexpectedEOF
+		case f.Kind == kindData:
+			r.buf = f.Data
+		case f.Kind == kindEOF:
+			r.err = io.EOF
+		case f.Error != "":
+			r.err = fmt.Errorf("relay: %s", f.Error)
+		default:
+			r.err = fmt.Errorf("relay: unexpected %s frame", f.Kind)
+		}
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
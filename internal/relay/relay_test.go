@@ -0,0 +1,257 @@
+package relay_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gossher/internal/executor"
+	"gossher/internal/inventory"
+	"gossher/internal/relay"
+	"gossher/internal/testssh"
+	"gossher/internal/transport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupRelay starts an agent serving the segment network and returns a Dialer
+// whose relayed hosts go to it, direct hosts to direct, and a counter of the
+// agents started.
+func setupRelay(t *testing.T, direct, segment transport.Dialer) (*relay.Dialer, *int) {
+	started := 0
+	var wg sync.WaitGroup
+	d := relay.NewDialer(direct, func(ctx context.Context, r *inventory.ResolvedConnection) (io.ReadWriteCloser, error) {
+		started++
+		client, agentSide := net.Pipe()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			agent := &relay.Agent{Dialer: segment}
+			assert.NoError(t, agent.Serve(context.Background(), agentSide, agentSide))
+			agentSide.Close()
+		}()
+		return client, nil
+	})
+	t.Cleanup(func() {
+		d.Close()
+		wg.Wait()
+	})
+	return d, &started
+}
+
+func relayed(id, address string) *inventory.ResolvedConnection {
+	return &inventory.ResolvedConnection{
+		HostID: id, Address: address, Port: 22, User: "deploy", Password: "hunter22",
+		Relay: &inventory.ResolvedConnection{HostID: "relay", Address: "192.0.2.1", Port: 22, User: "deploy"},
+	}
+}
+
+func TestRelay(t *testing.T) {
+	segment := testssh.NewNetwork()
+	srv, err := segment.NewServer("10.9.0.1", 22, t.TempDir())
+	require.NoError(t, err)
+	srv.AddUser("deploy", "hunter22")
+	srv.Handle("fail", func(s *testssh.Session) int {
+		fmt.Fprint(s.Stdout, "partial")
+		fmt.Fprint(s.Stderr, "boom")
+		return 3
+	})
+	srv.Handle("sleep", func(s *testssh.Session) int {
+		<-s.Ctx.Done()
+		return 1
+	})
+
+	direct := testssh.NewNetwork()
+	web, err := direct.NewServer("10.0.0.1", 22, t.TempDir())
+	require.NoError(t, err)
+	web.AddUser("deploy", "hunter22")
+
+	d, started := setupRelay(t, direct, segment)
+	r := transport.Runner{Dialer: d}
+	ctx := context.Background()
+
+	t.Run("runs commands through the agent", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code, err := r.Run(ctx, relayed("db01", "10.9.0.1"), "fail", &stdout, &stderr)
+		require.NoError(t, err)
+		assert.Equal(t, 3, code)
+		assert.Equal(t, "partial", stdout.String())
+		assert.Equal(t, "boom", stderr.String())
+	})
+
+	t.Run("transfers files through the agent", func(t *testing.T) {
+		data := strings.Repeat("0123456789", 10_000)
+		conn := relayed("db01", "10.9.0.1")
+		require.NoError(t, r.Upload(ctx, conn, strings.NewReader(data), "/srv/data.txt", 0o640))
+		stored, err := srv.ReadFile("/srv/data.txt")
+		require.NoError(t, err)
+		assert.Equal(t, data, string(stored))
+
+		var out bytes.Buffer
+		require.NoError(t, r.Download(ctx, conn, "/srv/data.txt", &out))
+		assert.Equal(t, data, out.String())
+	})
+
+	t.Run("reports errors of the segment", func(t *testing.T) {
+		conn := relayed("db01", "10.9.0.1")
+		conn.Password = "wrong"
+		_, err := r.Run(ctx, conn, "true", io.Discard, io.Discard)
+		assert.ErrorContains(t, err, "relay relay: failed to connect to db01")
+		assert.ErrorContains(t, err, "permission denied")
+
+		err = r.Upload(ctx, conn, strings.NewReader("x"), "/srv/x", 0o644)
+		assert.ErrorContains(t, err, "permission denied")
+	})
+
+	t.Run("cancels requests", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, err := r.Run(ctx, relayed("db01", "10.9.0.1"), "sleep", io.Discard, io.Discard)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("shares one agent for concurrent requests", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var out bytes.Buffer
+				_, err := r.Run(ctx, relayed("db01", "10.9.0.1"), fmt.Sprintf("echo %d", i), &out, io.Discard)
+				assert.NoError(t, err)
+				assert.Equal(t, fmt.Sprintf("%d\n", i), out.String())
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, *started)
+	})
+
+	t.Run("dials other hosts directly", func(t *testing.T) {
+		conn := &inventory.ResolvedConnection{HostID: "web01", Address: "10.0.0.1", Port: 22, User: "deploy", Password: "hunter22"}
+		_, err := r.Run(ctx, conn, "true", io.Discard, io.Discard)
+		require.NoError(t, err)
+		assert.Len(t, web.Requests(), 1)
+	})
+}
+
+func TestClientVersion(t *testing.T) {
+	client, agentSide := net.Pipe()
+	defer client.Close()
+	go func() {
+		fmt.Fprintln(agentSide, `{"kind":"hello","version":99}`)
+		io.Copy(io.Discard, agentSide)
+	}()
+
+	cl := relay.NewClient("relay", client)
+	_, err := cl.Dial(context.Background(), relayed("db01", "10.9.0.1"))
+	assert.ErrorContains(t, err, "protocol version 99")
+}
+
+func TestRelayedHost(t *testing.T) {
+	m := inventory.NewManager(t.TempDir())
+	require.NoError(t, m.Load())
+
+	key, err := testssh.GenerateKey()
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(keyPath, key, 0o600))
+
+	segment := testssh.NewNetwork()
+	srv, err := segment.NewServer("10.9.0.1", 22, t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, srv.AuthorizeKey("deploy", key))
+
+	bastion := inventory.NewHost("bastion", "bastion", "192.0.2.1")
+	bastion.User = "deploy"
+	require.NoError(t, m.AddHost(bastion))
+	db := inventory.NewHost("db01", "db01", "10.9.0.1")
+	db.User = "deploy"
+	db.KeyPath = keyPath
+	db.RelayHostID = "bastion"
+	require.NoError(t, m.AddHost(db))
+
+	d, _ := setupRelay(t, testssh.NewNetwork(), segment)
+	e := executor.NewWithDialer(m, d)
+
+	results, err := e.Exec(context.Background(), executor.ExecOptions{Command: "whoami", HostIDs: []string{"db01"}})
+	require.NoError(t, err)
+	require.True(t, results[0].OK(), results[0].Err)
+	assert.Equal(t, "deploy\n", results[0].Stdout)
+	assert.Equal(t, testssh.AuthPublicKey, srv.Requests()[0].Auth)
+}
+
+func TestCommandDialer(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as ssh")
+	}
+	bin := t.TempDir()
+	argsFile := filepath.Join(bin, "args")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + argsFile + "\n" +
+		"for last; do :; done\n" +
+		"case \"$last\" in\n" +
+		"  unreachable) echo 'ssh: connect to host 10.0.0.9 port 22: Connection timed out' >&2; exit 255 ;;\n" +
+		"  *) sh -c \"$last\" ;;\n" +
+		"esac\n"
+	ssh := filepath.Join(bin, "ssh")
+	require.NoError(t, os.WriteFile(ssh, []byte(script), 0o755))
+
+	d := relay.CommandDialer{SSH: ssh}
+	conn := &inventory.ResolvedConnection{
+		HostID: "db01", Address: "10.0.0.9", Port: 2222, User: "deploy",
+		Timeouts: inventory.Timeouts{Connect: 10 * time.Second},
+	}
+	s, err := d.Dial(context.Background(), conn)
+	require.NoError(t, err)
+
+	t.Run("passes the connect timeout", func(t *testing.T) {
+		code, err := s.Run(context.Background(), "exit 3", io.Discard, io.Discard)
+		require.NoError(t, err)
+		assert.Equal(t, 3, code, "exit codes of the command are reported")
+
+		args, err := os.ReadFile(argsFile)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"-o", "BatchMode=yes", "-p", "2222", "-o", "ConnectTimeout=10",
+			"deploy@10.0.0.9", "--", "exit 3",
+		}, strings.Split(strings.TrimSpace(string(args)), "\n"))
+	})
+
+	t.Run("brackets IPv6 jump hosts", func(t *testing.T) {
+		jumped := *conn
+		jumped.Timeouts = inventory.Timeouts{}
+		jumped.Jumps = []*inventory.ResolvedConnection{
+			{HostID: "bastion", Address: "fe80::1", Port: 22, User: "ops"},
+			{HostID: "inner", Address: "10.0.0.5", Port: 2200},
+		}
+		s, err := d.Dial(context.Background(), &jumped)
+		require.NoError(t, err)
+		_, err = s.Run(context.Background(), "true", io.Discard, io.Discard)
+		require.NoError(t, err)
+
+		args, err := os.ReadFile(argsFile)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"-o", "BatchMode=yes", "-p", "2222", "-J", "ops@[fe80::1]:22,10.0.0.5:2200",
+			"deploy@10.0.0.9", "--", "true",
+		}, strings.Split(strings.TrimSpace(string(args)), "\n"))
+	})
+
+	t.Run("reports ssh failures as errors", func(t *testing.T) {
+		var stderr bytes.Buffer
+		code, err := s.Run(context.Background(), "unreachable", io.Discard, &stderr)
+		require.Error(t, err)
+		assert.Equal(t, -1, code)
+		assert.Equal(t, "failed to connect to db01: ssh: connect to host 10.0.0.9 port 22: Connection timed out", err.Error())
+		assert.Contains(t, stderr.String(), "Connection timed out", "stderr is still passed on")
+	})
+}
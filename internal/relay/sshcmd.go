@@ -0,0 +1,187 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"gossher/internal/inventory"
+	"gossher/internal/transport"
+)
+
+// Ensure the types implement the interfaces
+var (
+	_ transport.Dialer   = CommandDialer{}
+	_ transport.Session  = (*commandSession)(nil)
+	_ transport.Transfer = (*commandSession)(nil)
+)
+
+// CommandDialer reaches hosts with the ssh client installed on the machine, which
+// is how the agent reaches the segment from a relay host. Every command and
+// transfer runs its own ssh process with BatchMode, so only public key
+// authentication works: the keys and ssh-agent of the relay host's user, or the
+// private key sent with the connection, which is written to a temporary file for
+// the duration of the process. Jump hosts use the relay's keys; host keys are
+// checked against the relay's known_hosts, since ssh cannot check the pinned
+// fingerprints of the connection (transport.SSHDialer does). Exit status 255 is
+// ssh's own failure and is reported as an error rather than as the exit code of
+// the command.
+type CommandDialer struct {
+	// SSH is the ssh binary (default "ssh").
+	SSH string
+	// Options are extra -o options, e.g. "StrictHostKeyChecking=accept-new".
+	Options []string
+}
+
+// Dial returns a session for conn; connecting happens for each command.
+func (d CommandDialer) Dial(ctx context.Context, conn *inventory.ResolvedConnection) (transport.Session, error) {
+	if conn.Local {
+		return nil, fmt.Errorf("host %s: local hosts cannot be reached through a relay", conn.HostID)
+	}
+	return &commandSession{d: d, conn: conn}, nil
+}
+
+// commandSession runs ssh processes for one host.
+type commandSession struct {
+	d    CommandDialer
+	conn *inventory.ResolvedConnection
+}
+
+// run runs command on the host with stdin, returning its exit code.
+func (s *commandSession) run(ctx context.Context, command string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	args := []string{"-o", "BatchMode=yes"}
+	for _, o := range s.d.Options {
+		args = append(args, "-o", o)
+	}
+	if s.conn.Port > 0 {
+		args = append(args, "-p", strconv.Itoa(s.conn.Port))
+	}
+	args = append(args, s.conn.ConnectTimeoutOption()...)
+	if len(s.conn.Jumps) > 0 {
+		args = append(args, "-J", s.conn.JumpSpec())
+	}
+	if s.conn.PrivateKey != "" {
+		keyFile, err := writeKey(s.conn.PrivateKey)
+		if err != nil {
+			return -1, err
+		}
+		defer os.Remove(keyFile)
+		args = append(args, "-i", keyFile, "-o", "IdentitiesOnly=yes")
+	}
+	args = append(args, s.conn.Destination(), "--", command)
+
+	bin := s.d.SSH
+	if bin == "" {
+		bin = "ssh"
+	}
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	// The end of stderr explains a failure of ssh itself.
+	tail := &tailBuffer{limit: 1024}
+	cmd.Stderr = io.MultiWriter(stderr, tail)
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.ExitCode() == sshFailure {
+			msg := strings.TrimSpace(tail.String())
+			if msg == "" {
+				msg = "ssh exited with status 255"
+			}
+			return -1, fmt.Errorf("failed to connect to %s: %s", s.conn.HostID, msg)
+		}
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, fmt.Errorf("failed to run ssh to %s: %w", s.conn.HostID, err)
+	}
+	return 0, nil
+}
+
+// sshFailure is the exit status of ssh when it fails itself, e.g. because the
+// host or a jump host cannot be reached. Remote commands exiting with it are
+// reported as connection failures too.
+const sshFailure = 255
+
+// tailBuffer keeps the last limit bytes written to it.
+type tailBuffer struct {
+	buf   []byte
+	limit int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = b.buf[len(b.buf)-b.limit:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.buf)
+}
+
+// writeKey writes a private key to a temporary file only the user can read.
+func writeKey(key string) (string, error) {
+	f, err := os.CreateTemp("", "gossher-agent-key-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to write key: %w", err)
+	}
+	if !strings.HasSuffix(key, "\n") {
+		key += "\n"
+	}
+	_, err = f.WriteString(key)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write key: %w", err)
+	}
+	return f.Name(), nil
+}
+
+func (s *commandSession) Run(ctx context.Context, command string, stdout, stderr io.Writer) (int, error) {
+	return s.run(ctx, command, nil, stdout, stderr)
+}
+
+func (s *commandSession) Transfer(ctx context.Context) (transport.Transfer, error) {
+	return s, nil
+}
+
+func (s *commandSession) Upload(ctx context.Context, src io.Reader, remotePath string, mode os.FileMode) error {
+	p := inventory.ShellQuote(remotePath)
+	return s.transfer(ctx, fmt.Sprintf("cat > %s && chmod %o %s", p, mode.Perm(), p), src, io.Discard, "upload", remotePath)
+}
+
+func (s *commandSession) Download(ctx context.Context, remotePath string, dst io.Writer) error {
+	return s.transfer(ctx, "cat "+inventory.ShellQuote(remotePath), nil, dst, "download", remotePath)
+}
+
+// transfer runs the command of a transfer and turns a failure into an error.
+func (s *commandSession) transfer(ctx context.Context, command string, stdin io.Reader, stdout io.Writer, op, path string) error {
+	var stderr bytes.Buffer
+	code, err := s.run(ctx, command, stdin, stdout, &stderr)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = fmt.Sprintf("exit code %d", code)
+		}
+		return fmt.Errorf("failed to %s %s on %s: %s", op, path, s.conn.HostID, msg)
+	}
+	return nil
+}
+
+func (s *commandSession) Close() error {
+	return nil
+}
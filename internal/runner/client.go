@@ -0,0 +1,99 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultValidity is the validity window of jobs submitted by a Client.
+const DefaultValidity = 5 * time.Minute
+
+// Client submits jobs to a runner, signing them as Issuer.
+type Client struct {
+	// URL is the base URL of the runner, e.g. https://runner.dmz.example.com:8443.
+	URL    string
+	Issuer string
+	Key    ed25519.PrivateKey
+	// Validity is the validity window of submitted jobs (default DefaultValidity).
+	Validity time.Duration
+	// HTTPClient sends the requests (default http.DefaultClient).
+	HTTPClient *http.Client
+}
+
+// Submit signs job and runs it on the runner. The ID, issuer and validity window
+// are set when empty.
+func (c *Client) Submit(ctx context.Context, job Job) (*JobResult, error) {
+	if job.ID == "" {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate job ID: %w", err)
+		}
+		job.ID = hex.EncodeToString(b)
+	}
+	if job.Issuer == "" {
+		job.Issuer = c.Issuer
+	}
+	if job.IssuedAt.IsZero() {
+		job.IssuedAt = time.Now().UTC()
+	}
+	if job.ExpiresAt.IsZero() {
+		validity := c.Validity
+		if validity <= 0 {
+			validity = DefaultValidity
+		}
+		job.ExpiresAt = job.IssuedAt.Add(validity)
+	}
+
+	sj, err := Sign(&job, c.Key)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(sj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.URL, "/")+"/v1/jobs", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit job %s: %w", job.ID, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResultSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read result of job %s: %w", job.ID, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &e) != nil || e.Error == "" {
+			e.Error = resp.Status
+		}
+		return nil, fmt.Errorf("runner rejected job %s: %s", job.ID, e.Error)
+	}
+
+	var result JobResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode result of job %s: %w", job.ID, err)
+	}
+	return &result, nil
+}
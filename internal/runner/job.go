@@ -0,0 +1,148 @@
+// Package runner implements headless exec servers. A runner is a gossher instance
+// without a UI deployed in a network zone: it only accepts jobs signed by trusted
+// issuers and runs them against the hosts of its own inventory segment, so that a
+// central instance can execute commands across zones it cannot reach itself.
+//
+// Jobs are JSON documents signed with ed25519. A runner checks the signature, the
+// validity window and that the job was not seen before, then runs the command on
+// the selected hosts if they are all within its scope.
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gossher/internal/executor"
+)
+
+// Errors of rejected jobs.
+var (
+	ErrUnknownIssuer = errors.New("unknown job issuer")
+	ErrBadSignature  = errors.New("invalid job signature")
+	ErrExpired       = errors.New("job is not valid at this time")
+	ErrReplayed      = errors.New("job was already received")
+	ErrOutOfScope    = errors.New("job targets hosts outside the runner's scope")
+)
+
+// Job is a command to run on the hosts of a runner.
+type Job struct {
+	// ID identifies the job; a runner accepts each ID once.
+	ID string `json:"id"`
+	// Issuer names the key the job is signed with.
+	Issuer string `json:"issuer"`
+
+	Command string   `json:"command"`
+	HostIDs []string `json:"host_ids,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+	// Target is a target spec; see inventory.TargetSpec.
+	Target string `json:"target,omitempty"`
+
+	Concurrency int           `json:"concurrency,omitempty"`
+	Timeout     time.Duration `json:"timeout,omitempty"`
+
+	// IssuedAt and ExpiresAt bound when the job may run.
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// options converts the job into ExecOptions. Jobs cannot force commands blocked
+// by the runner's command policy.
+func (j *Job) options() executor.ExecOptions {
+	return executor.ExecOptions{
+		Command:     j.Command,
+		HostIDs:     append([]string(nil), j.HostIDs...),
+		Groups:      append([]string(nil), j.Groups...),
+		Target:      j.Target,
+		Concurrency: j.Concurrency,
+		Timeout:     j.Timeout,
+	}
+}
+
+// SignedJob is the encoded Job with the signature of its issuer. The payload is
+// signed as is, so runners never verify a re-encoding.
+type SignedJob struct {
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
+
+// Sign encodes and signs a job with the issuer's key.
+func Sign(job *Job, key ed25519.PrivateKey) (*SignedJob, error) {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job: %w", err)
+	}
+	return &SignedJob{Payload: payload, Signature: ed25519.Sign(key, payload)}, nil
+}
+
+// Verify checks the signature against the issuer's key in keys and returns the job.
+func (s *SignedJob) Verify(keys Keyring) (*Job, error) {
+	var job Job
+	if err := json.Unmarshal(s.Payload, &job); err != nil {
+		return nil, fmt.Errorf("failed to decode job: %w", err)
+	}
+	key, ok := keys[job.Issuer]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownIssuer, job.Issuer)
+	}
+	if !ed25519.Verify(key, s.Payload, s.Signature) {
+		return nil, ErrBadSignature
+	}
+	return &job, nil
+}
+
+// ===== Keys =====
+
+// Keyring maps issuer names to their public keys.
+type Keyring map[string]ed25519.PublicKey
+
+// ParseKeyring parses lines of an issuer name and its base64 public key; empty
+// lines and lines starting with # are ignored.
+func ParseKeyring(data []byte) (Keyring, error) {
+	keys := Keyring{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: want an issuer and a key", n)
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("line %d: invalid ed25519 public key", n)
+		}
+		if _, dup := keys[fields[0]]; dup {
+			return nil, fmt.Errorf("line %d: duplicate issuer %s", n, fields[0])
+		}
+		keys[fields[0]] = ed25519.PublicKey(key)
+	}
+	return keys, scanner.Err()
+}
+
+// LoadKeyring reads a keyring file; see ParseKeyring.
+func LoadKeyring(path string) (Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+	keys, err := ParseKeyring(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse keyring %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// KeyringLine formats the keyring line of an issuer's public key.
+func KeyringLine(issuer string, key ed25519.PublicKey) string {
+	return issuer + " " + base64.StdEncoding.EncodeToString(key)
+}
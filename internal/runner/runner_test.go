@@ -0,0 +1,170 @@
+package runner
+
+import (
+	"context"
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gossher/internal/audit"
+	"gossher/internal/executor"
+	"gossher/internal/inventory"
+	"gossher/internal/testssh"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupRunner serves a runner for web01 and web02 of the dmz group; db01 is out
+// of its scope. It returns the runner and a client signing as "central".
+func setupRunner(t *testing.T) (*Server, *Client) {
+	dir := t.TempDir()
+	m := inventory.NewManager(dir)
+	require.NoError(t, m.Load())
+	n := testssh.NewNetwork()
+	for i, id := range []string{"web01", "web02", "db01"} {
+		address := "10.0.0." + string(rune('1'+i))
+		s, err := n.NewServer(address, 22, t.TempDir())
+		require.NoError(t, err)
+		s.AddUser("deploy", "hunter22")
+
+		h := inventory.NewHost(id, id, address)
+		h.User = "deploy"
+		h.Password = "hunter22"
+		if id != "db01" {
+			h.Tags = []string{"dmz"}
+		}
+		require.NoError(t, m.AddHost(h))
+	}
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	s := &Server{
+		Manager:  m,
+		Executor: executor.NewWithDialer(m, n),
+		Keys:     Keyring{"central": pub},
+		Scope:    "tag:dmz",
+		Audit:    audit.OpenInDir(dir),
+	}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, &Client{URL: srv.URL, Issuer: "central", Key: priv}
+}
+
+func TestRunner(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("runs signed jobs", func(t *testing.T) {
+		s, c := setupRunner(t)
+		result, err := c.Submit(ctx, Job{ID: "job-1", Command: "whoami", Target: "tag:dmz"})
+		require.NoError(t, err)
+		assert.Equal(t, "job-1", result.JobID)
+		require.Len(t, result.Results, 2)
+		assert.Equal(t, "web01", result.Results[0].HostID)
+		assert.Equal(t, "deploy\n", result.Results[0].Stdout)
+		assert.Equal(t, 0, result.Results[1].ExitCode)
+
+		events, err := s.Audit.Events()
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, "runner.job", events[0].Action)
+		assert.Equal(t, "central", events[0].Actor)
+		assert.Equal(t, "web01,web02", events[0].Details["hosts"])
+	})
+
+	t.Run("redacts secrets in the audit log", func(t *testing.T) {
+		s, c := setupRunner(t)
+		_, err := c.Submit(ctx, Job{Command: "deploy --token=s3cr3t-value", HostIDs: []string{"web01"}})
+		require.NoError(t, err)
+
+		events, err := s.Audit.Events()
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, "deploy --token=[REDACTED]", events[0].Details["command"])
+	})
+
+	t.Run("rejects hosts outside the scope", func(t *testing.T) {
+		_, c := setupRunner(t)
+		_, err := c.Submit(ctx, Job{Command: "whoami", HostIDs: []string{"web01", "db01"}})
+		assert.ErrorContains(t, err, "outside the runner's scope: db01")
+	})
+
+	t.Run("rejects replays", func(t *testing.T) {
+		_, c := setupRunner(t)
+		_, err := c.Submit(ctx, Job{ID: "job-1", Command: "true", HostIDs: []string{"web01"}})
+		require.NoError(t, err)
+		_, err = c.Submit(ctx, Job{ID: "job-1", Command: "true", HostIDs: []string{"web01"}})
+		assert.ErrorContains(t, err, "already received")
+	})
+
+	t.Run("rejects expired jobs", func(t *testing.T) {
+		_, c := setupRunner(t)
+		issued := time.Now().Add(-time.Hour)
+		_, err := c.Submit(ctx, Job{Command: "true", HostIDs: []string{"web01"}, IssuedAt: issued, ExpiresAt: issued.Add(time.Minute)})
+		assert.ErrorContains(t, err, "not valid at this time")
+
+		_, err = c.Submit(ctx, Job{Command: "true", HostIDs: []string{"web01"}, ExpiresAt: time.Now().Add(24 * time.Hour)})
+		assert.ErrorContains(t, err, "at most 10m0s")
+	})
+
+	t.Run("rejects unknown issuers and forged jobs", func(t *testing.T) {
+		s, c := setupRunner(t)
+		_, other, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+
+		forged := *c
+		forged.Key = other
+		_, err = forged.Submit(ctx, Job{Command: "true", HostIDs: []string{"web01"}})
+		assert.ErrorContains(t, err, "invalid job signature")
+
+		forged.Issuer = "mallory"
+		_, err = forged.Submit(ctx, Job{Command: "true", HostIDs: []string{"web01"}})
+		assert.ErrorContains(t, err, "unknown job issuer")
+
+		sj, err := Sign(&Job{ID: "x", Issuer: "central", Command: "true"}, c.Key)
+		require.NoError(t, err)
+		sj.Payload[len(sj.Payload)-2] ^= 1
+		_, err = s.Run(ctx, sj)
+		assert.Error(t, err)
+
+		events, err := s.Audit.Events()
+		require.NoError(t, err)
+		for _, e := range events {
+			assert.Equal(t, "runner.reject", e.Action)
+		}
+	})
+
+	t.Run("serves health checks only besides jobs", func(t *testing.T) {
+		_, c := setupRunner(t)
+		resp, err := http.Get(c.URL + "/v1/health")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = http.Get(c.URL + "/v1/jobs")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+}
+
+func TestParseKeyring(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	keys, err := ParseKeyring([]byte("# issuers\n\n" + KeyringLine("central", pub) + "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, Keyring{"central": pub}, keys)
+
+	for _, data := range []string{
+		"central",
+		"central not-base64!",
+		"central AAAA",
+		KeyringLine("central", pub) + "\n" + KeyringLine("central", pub),
+	} {
+		_, err := ParseKeyring([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
@@ -0,0 +1,248 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gossher/internal/audit"
+	"gossher/internal/executor"
+	"gossher/internal/inventory"
+	"gossher/internal/redact"
+)
+
+const (
+	// DefaultMaxValidity is the longest validity window a job may have.
+	DefaultMaxValidity = 10 * time.Minute
+	// DefaultClockSkew is tolerated between the clocks of issuers and runners.
+	DefaultClockSkew = 30 * time.Second
+	// maxJobSize limits the size of job requests, maxResultSize that of results.
+	maxJobSize    = 1 << 20
+	maxResultSize = 64 << 20
+)
+
+// HostResult is the outcome of a job on one host.
+type HostResult struct {
+	HostID   string        `json:"host_id"`
+	ExitCode int           `json:"exit_code"`
+	Stdout   string        `json:"stdout,omitempty"`
+	Stderr   string        `json:"stderr,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Skipped  bool          `json:"skipped,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// JobResult is what a runner returns for a job.
+type JobResult struct {
+	JobID   string       `json:"job_id"`
+	Results []HostResult `json:"results"`
+}
+
+// Server runs signed jobs. It serves them over HTTP (see ServeHTTP) and can be
+// called directly with Run.
+type Server struct {
+	Manager  *inventory.Manager
+	Executor *executor.Executor
+	// Keys are the trusted issuers.
+	Keys Keyring
+	// Scope is a target spec of the hosts jobs may run on, the inventory segment of
+	// the runner. Empty allows every host of the inventory.
+	Scope string
+	// Audit, if set, records accepted and rejected jobs.
+	Audit *audit.Log
+
+	// MaxValidity (default DefaultMaxValidity) and ClockSkew (default
+	// DefaultClockSkew) bound the validity windows of accepted jobs.
+	MaxValidity time.Duration
+	ClockSkew   time.Duration
+	// Now returns the current time (default time.Now).
+	Now func() time.Time
+
+	mu sync.Mutex
+	// seen holds the IDs of accepted jobs until they expire, to refuse replays.
+	seen map[string]time.Time
+}
+
+// Run verifies a signed job and runs it. Rejected jobs return an error wrapping
+// one of the Err* values; failures on hosts are reported in the results.
+func (s *Server) Run(ctx context.Context, sj *SignedJob) (*JobResult, error) {
+	job, err := sj.Verify(s.Keys)
+	if err == nil {
+		err = s.accept(job)
+	}
+	var opts executor.ExecOptions
+	if err == nil {
+		opts, err = s.scoped(job)
+	}
+	if err != nil {
+		s.record(job, "runner.reject", map[string]string{"error": redact.String(err.Error())})
+		return nil, err
+	}
+
+	s.record(job, "runner.job", map[string]string{"command": redact.String(job.Command), "hosts": strings.Join(opts.HostIDs, ",")})
+	results, err := s.Executor.Exec(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &JobResult{JobID: job.ID, Results: make([]HostResult, len(results))}
+	for i, r := range results {
+		out.Results[i] = HostResult{
+			HostID:   r.HostID,
+			ExitCode: r.ExitCode,
+			Stdout:   r.Stdout,
+			Stderr:   r.Stderr,
+			Skipped:  r.Skipped,
+			Duration: r.Duration,
+		}
+		if r.Err != nil {
+			out.Results[i].Error = r.Err.Error()
+		}
+	}
+	return out, nil
+}
+
+// accept checks the validity window of a job and that its ID is new.
+func (s *Server) accept(job *Job) error {
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+	maxValidity := s.MaxValidity
+	if maxValidity <= 0 {
+		maxValidity = DefaultMaxValidity
+	}
+	skew := s.ClockSkew
+	if skew <= 0 {
+		skew = DefaultClockSkew
+	}
+
+	switch {
+	case job.ID == "":
+		return fmt.Errorf("job has no ID")
+	case job.Command == "":
+		return fmt.Errorf("job %s has no command", job.ID)
+	case !job.ExpiresAt.After(job.IssuedAt) || job.ExpiresAt.Sub(job.IssuedAt) > maxValidity:
+		return fmt.Errorf("%w: validity must be positive and at most %s", ErrExpired, maxValidity)
+	case now.Add(skew).Before(job.IssuedAt) || now.After(job.ExpiresAt.Add(skew)):
+		return fmt.Errorf("%w: valid from %s to %s", ErrExpired, job.IssuedAt.Format(time.RFC3339), job.ExpiresAt.Format(time.RFC3339))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen == nil {
+		s.seen = map[string]time.Time{}
+	}
+	for id, expires := range s.seen {
+		if now.After(expires.Add(skew)) {
+			delete(s.seen, id)
+		}
+	}
+	key := job.Issuer + "/" + job.ID
+	if _, ok := s.seen[key]; ok {
+		return fmt.Errorf("%w: %s", ErrReplayed, job.ID)
+	}
+	s.seen[key] = job.ExpiresAt
+	return nil
+}
+
+// scoped resolves the targets of a job and checks that they are all in scope. The
+// returned options select exactly the checked hosts.
+func (s *Server) scoped(job *Job) (executor.ExecOptions, error) {
+	opts := job.options()
+	targets, err := s.Executor.ResolveTargets(opts)
+	if err != nil {
+		return opts, err
+	}
+
+	if s.Scope != "" {
+		ids, err := s.Manager.ResolveTargetSpec(s.Scope)
+		if err != nil {
+			return opts, fmt.Errorf("invalid runner scope: %w", err)
+		}
+		inScope := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			inScope[id] = true
+		}
+		var outside []string
+		for _, id := range targets {
+			if !inScope[id] {
+				outside = append(outside, id)
+			}
+		}
+		if len(outside) > 0 {
+			return opts, fmt.Errorf("%w: %s", ErrOutOfScope, strings.Join(outside, ", "))
+		}
+	}
+
+	opts.HostIDs, opts.Groups, opts.Target = targets, nil, ""
+	return opts, nil
+}
+
+// record writes an audit event for a job, if auditing is enabled.
+func (s *Server) record(job *Job, action string, details map[string]string) {
+	if s.Audit == nil {
+		return
+	}
+	e := audit.Event{Action: action, Actor: "unknown", Details: details}
+	if job != nil {
+		e.Actor, e.Target = job.Issuer, job.ID
+	}
+	_ = s.Audit.Record(e)
+}
+
+// ===== HTTP =====
+
+// ServeHTTP serves POST /v1/jobs, which runs the SignedJob in the body and
+// answers with its JobResult, and GET /v1/health. Errors are JSON objects with an
+// "error" field.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/v1/health" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	case r.URL.Path == "/v1/jobs" && r.Method == http.MethodPost:
+		s.serveJob(w, r)
+	case r.URL.Path == "/v1/health" || r.URL.Path == "/v1/jobs":
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (s *Server) serveJob(w http.ResponseWriter, r *http.Request) {
+	var sj SignedJob
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxJobSize)).Decode(&sj); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("failed to decode job: %w", err))
+		return
+	}
+
+	result, err := s.Run(r.Context(), &sj)
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, result)
+	case errors.Is(err, ErrUnknownIssuer), errors.Is(err, ErrBadSignature):
+		writeError(w, http.StatusUnauthorized, err)
+	case errors.Is(err, ErrOutOfScope), errors.Is(err, executor.ErrCommandBlocked):
+		writeError(w, http.StatusForbidden, err)
+	case errors.Is(err, ErrReplayed):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusBadRequest, err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": redact.String(err.Error())})
+}
@@ -0,0 +1,53 @@
+package script
+
+import (
+	"path"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// predeclared are the names scripts see besides the Starlark builtins.
+var predeclared = starlark.StringDict{
+	"gossher": &starlarkstruct.Module{
+		Name: "gossher",
+		Members: starlark.StringDict{
+			"match":     starlark.NewBuiltin("match", builtinMatch),
+			"split_tag": starlark.NewBuiltin("split_tag", builtinSplitTag),
+		},
+	},
+}
+
+// builtinMatch implements gossher.match(pattern, name), which reports whether name
+// matches a shell pattern (*, ? and [...]), case-insensitively like host names.
+func builtinMatch(t *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, name string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &pattern, &name); err != nil {
+		return nil, err
+	}
+	ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(name))
+	if err != nil {
+		return nil, err
+	}
+	return starlark.Bool(ok), nil
+}
+
+// builtinSplitTag implements gossher.split_tag(tag), which returns the namespace
+// and the value of a structured tag ("env:prod" -> ("env", "prod")), or "" and the
+// tag for free-form tags, like inventory.ParseTag.
+func builtinSplitTag(t *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var tag string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &tag); err != nil {
+		return nil, err
+	}
+	tag = strings.TrimSpace(tag)
+	namespace, value, ok := strings.Cut(tag, ":")
+	if !ok {
+		return starlark.Tuple{starlark.String(""), starlark.String(tag)}, nil
+	}
+	return starlark.Tuple{
+		starlark.String(strings.ToLower(strings.TrimSpace(namespace))),
+		starlark.String(strings.TrimSpace(value)),
+	}, nil
+}
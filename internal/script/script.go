@@ -0,0 +1,186 @@
+// Package script evaluates user hooks written in Starlark, the Python-like
+// configuration language, with the go.starlark.net interpreter.
+//
+// Scripts see the Starlark builtins and the gossher module (see builtins.go).
+// Following the Starlark spec, while loops, recursion and load are not allowed,
+// and the values created while a file loads are frozen afterwards, so hooks always
+// terminate (see MaxSteps) and can run concurrently. Sets, top-level if/for
+// statements and reassigning globals are allowed.
+package script
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// Extension is the file extension of script files.
+const Extension = ".star"
+
+// MaxSteps bounds the computation of a file's top level and of each call.
+const MaxSteps = 1_000_000
+
+// fileOptions are the dialect of script files.
+var fileOptions = &syntax.FileOptions{Set: true, TopLevelControl: true, GlobalReassign: true}
+
+// Error is a script error with its location.
+type Error struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// Program holds the functions defined by a set of script files. Functions share
+// no state between calls, so a Program is safe for concurrent use.
+type Program struct {
+	functions map[string]*starlark.Function
+}
+
+// Compile loads a single file given as source.
+func Compile(file, src string) (*Program, error) {
+	p := &Program{functions: map[string]*starlark.Function{}}
+	return p, p.add(file, src)
+}
+
+// LoadDir loads every script file in dir, sorted by name, into one program. A
+// missing directory yields an empty program. Function names must be unique across
+// files.
+func LoadDir(dir string) (*Program, error) {
+	p := &Program{functions: map[string]*starlark.Function{}}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return p, nil
+		}
+		return nil, fmt.Errorf("failed to read script directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != Extension {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read script: %w", err)
+		}
+		if err := p.add(entry.Name(), string(data)); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// add runs the top level of a file and collects its functions. Values created at
+// the top level are frozen afterwards.
+func (p *Program) add(file, src string) error {
+	globals, err := starlark.ExecFileOptions(fileOptions, newThread(file), file, src, predeclared)
+	if err != nil {
+		return locate(err)
+	}
+	globals.Freeze()
+
+	for _, name := range globals.Keys() {
+		f, ok := globals[name].(*starlark.Function)
+		if !ok || strings.HasPrefix(name, "_") {
+			continue
+		}
+		if other, ok := p.functions[name]; ok {
+			pos := f.Position()
+			return &Error{File: file, Line: int(pos.Line), Msg: fmt.Sprintf("function %s is already defined in %s", name, other.Position().Filename())}
+		}
+		p.functions[name] = f
+	}
+	return nil
+}
+
+// Has reports whether the program defines a function.
+func (p *Program) Has(name string) bool {
+	if p == nil {
+		return false
+	}
+	_, ok := p.functions[name]
+	return ok
+}
+
+// Functions returns the names of the functions starting with prefix, sorted.
+func (p *Program) Functions(prefix string) []string {
+	if p == nil {
+		return nil
+	}
+	var names []string
+	for name := range p.functions {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Call calls a function with Go arguments converted by FromGo.
+func (p *Program) Call(name string, args ...any) (Value, error) {
+	var f *starlark.Function
+	if p != nil {
+		f = p.functions[name]
+	}
+	if f == nil {
+		return nil, fmt.Errorf("script function %s not found", name)
+	}
+
+	values := make(starlark.Tuple, len(args))
+	for i, a := range args {
+		v, err := FromGo(a)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		values[i] = v
+	}
+
+	v, err := starlark.Call(newThread(name), f, values, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, locate(err))
+	}
+	return v, nil
+}
+
+// newThread returns a thread limited to MaxSteps.
+func newThread(name string) *starlark.Thread {
+	t := &starlark.Thread{Name: name}
+	t.SetMaxExecutionSteps(MaxSteps)
+	return t
+}
+
+// locate turns the errors of the interpreter into an Error at the innermost
+// position in a script file. Other errors are returned unchanged.
+func locate(err error) error {
+	var syntaxErr syntax.Error
+	if errors.As(err, &syntaxErr) {
+		return &Error{File: syntaxErr.Pos.Filename(), Line: int(syntaxErr.Pos.Line), Msg: syntaxErr.Msg}
+	}
+	var resolveErrs resolve.ErrorList
+	if errors.As(err, &resolveErrs) && len(resolveErrs) > 0 {
+		first := resolveErrs[0]
+		return &Error{File: first.Pos.Filename(), Line: int(first.Pos.Line), Msg: first.Msg}
+	}
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		for i := range evalErr.CallStack {
+			if pos := evalErr.CallStack.At(i).Pos; pos.Line > 0 {
+				return &Error{File: pos.Filename(), Line: int(pos.Line), Msg: evalErr.Msg}
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,191 @@
+package script
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCall(t *testing.T) {
+	src := `
+# Helpers prefixed with _ are private.
+PREFIXES = ["env:", "role:"]
+
+def _namespace(tag):
+    return tag.split(":")[0] if ":" in tag else None
+
+def namespaces(tags):
+    return sorted([_namespace(t) for t in tags if _namespace(t) != None])
+
+def classify(n, limit=10):
+    if n < 0:
+        return "negative"
+    elif n == 0:
+        return "zero"
+    elif n > limit:
+        return "large"
+    else:
+        return "small"
+
+def total(values):
+    sum = 0
+    for i, v in enumerate(values):
+        if v == None:
+            continue
+        if v == "stop":
+            break
+        sum += int(v) * (i + 1)
+    return sum
+
+def facts(host):
+    d = {"name": host.name.upper(), "ports": [p for p in range(20, 25) if p % 2 == 0]}
+    d["tagged"] = any([t.startswith(PREFIXES[0]) for t in host.tags])
+    d["rack"] = host.vars.get("rack", "unknown")
+    d["slice"] = host.name[1:-1]
+    return d
+
+def arith():
+    return [7 // 2, -7 // 2, 7 % 3, -7 % 3, 7 / 2, 2 * "ab", 1 + 2.5, not 0, 3 if False else 4]
+
+def _label(name, env="dev"):
+    return "%s@%s" % (name, env)
+
+def formatting(host):
+    counts = {tag: len(tag) for tag in host.tags}
+    point = (1, "a")
+    return ["%s has %d tags" % (host.name, len(counts)), "{}-{}".format(*point), _label(**{"name": host.name, "env": "prod"})]
+
+def tags(host):
+    return [gossher.split_tag(t) for t in host.tags + ["canary"]]
+
+def named(host, patterns):
+    return [p for p in patterns if gossher.match(p, host.name)]
+`
+	p, err := Compile("test.star", src)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"namespaces"}, p.Functions("names"))
+	assert.False(t, p.Has("_namespace"), "private functions are not exported")
+
+	call := func(name string, args ...any) any {
+		t.Helper()
+		v, err := p.Call(name, args...)
+		require.NoError(t, err)
+		return ToGo(v)
+	}
+
+	assert.Equal(t, []any{"env", "role"}, call("namespaces", []string{"role:web", "canary", "env:prod"}))
+	assert.Equal(t, "negative", call("classify", -1))
+	assert.Equal(t, "zero", call("classify", 0))
+	assert.Equal(t, "large", call("classify", 11))
+	assert.Equal(t, "small", call("classify", 11, 20))
+	assert.Equal(t, int64(1*1+3*3), call("total", []any{"1", nil, 3, "stop", 100}))
+
+	host, err := NewStruct("host", map[string]any{
+		"name": "web01",
+		"tags": []string{"env:prod"},
+		"vars": map[string]string{"dc": "fra"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"name":   "WEB01",
+		"ports":  []any{int64(20), int64(22), int64(24)},
+		"tagged": true,
+		"rack":   "unknown",
+		"slice":  "eb0",
+	}, call("facts", host))
+
+	assert.Equal(t, []any{int64(3), int64(-4), int64(1), int64(2), 3.5, "abab", 3.5, true, int64(4)}, call("arith"))
+
+	assert.Equal(t, []any{"web01 has 1 tags", "1-a", "web01@prod"}, call("formatting", host))
+
+	assert.Equal(t, []any{[]any{"env", "prod"}, []any{"", "canary"}}, call("tags", host))
+	assert.Equal(t, []any{"WEB*", "web0?"}, call("named", host, []string{"WEB*", "db*", "web0?"}))
+}
+
+func TestErrors(t *testing.T) {
+	t.Run("syntax errors have locations", func(t *testing.T) {
+		for src, want := range map[string]string{
+			"def f(:\n    return 1\n":         "bad.star:1: got ':', want ')'",
+			"def f():\nreturn 1\n":            "bad.star:2: got return, want indent",
+			"x = 'unterminated\n":             "bad.star:1: unexpected newline in string",
+			"while True:\n    pass\n":         "bad.star:1: this Starlark dialect does not support while loops",
+			"def f():\n  if x:\n    pass\n y": "bad.star:4: unindent does not match",
+			"x = $\n":                         "bad.star:1: unexpected input character",
+			"f() = 1\n":                       "bad.star:1: can't assign to callexpr",
+			"x = missing\n":                   "bad.star:1: undefined: missing",
+		} {
+			_, err := Compile("bad.star", src)
+			assert.ErrorContains(t, err, want, src)
+		}
+	})
+
+	t.Run("runtime errors have locations", func(t *testing.T) {
+		p, err := Compile("run.star", `
+FROZEN = [1]
+
+def undefined():
+    return {}["missing"]
+
+def divide(n):
+    return 1 // n
+
+def recurse():
+    return recurse()
+
+def mutate():
+    FROZEN.append(2)
+
+def forever():
+    for i in range(1000000):
+        for j in range(1000000):
+            pass
+
+def failing():
+    fail("disk", "full")
+`)
+		require.NoError(t, err)
+
+		for name, want := range map[string]string{
+			"undefined": "run.star:5: key \"missing\" not in dict",
+			"recurse":   "called recursively",
+			"mutate":    "run.star:14: append: cannot append to frozen list",
+			"forever":   "too many steps",
+			"failing":   "run.star:22: fail: disk full",
+			"missing":   "script function missing not found",
+		} {
+			_, err := p.Call(name)
+			assert.ErrorContains(t, err, want, name)
+		}
+		_, err = p.Call("divide", 0)
+		assert.ErrorContains(t, err, "run.star:8: floored division by zero")
+		_, err = p.Call("divide")
+		assert.ErrorContains(t, err, "function divide missing 1 argument (n)")
+	})
+
+	t.Run("top-level errors fail the load", func(t *testing.T) {
+		_, err := Compile("top.star", "x = 1 + 'a'\n")
+		assert.ErrorContains(t, err, "top.star:1: unknown binary op: int + string")
+	})
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.star"), []byte("def filter_web(host):\n    return True\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.star"), []byte("def filter_db(host):\n    return False\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a script"), 0644))
+
+	p, err := LoadDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"filter_db", "filter_web"}, p.Functions("filter_"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c.star"), []byte("def filter_web(host):\n    return False\n"), 0644))
+	_, err = LoadDir(dir)
+	assert.ErrorContains(t, err, "c.star:1: function filter_web is already defined in a.star")
+
+	p, err = LoadDir(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, p.Functions(""))
+}
@@ -0,0 +1,162 @@
+package script
+
+import (
+	"fmt"
+	"sort"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// Value is a script value.
+type Value = starlark.Value
+
+// None is the script value None.
+const None = starlark.None
+
+// Dict is a script dict.
+type Dict = starlark.Dict
+
+// NewStruct creates a read-only record whose fields, converted by FromGo, are
+// accessed as attributes, such as the host passed to hooks. name is shown when the
+// struct is printed.
+func NewStruct(name string, fields map[string]any) (Value, error) {
+	values := make(starlark.StringDict, len(fields))
+	for k, f := range fields {
+		v, err := FromGo(f)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", k, err)
+		}
+		values[k] = v
+	}
+	return starlarkstruct.FromStringDict(starlark.String(name), values), nil
+}
+
+// TypeName returns the script type of a value.
+func TypeName(v Value) string {
+	return v.Type()
+}
+
+// Truth reports whether a value is true in a condition.
+func Truth(v Value) bool {
+	return bool(v.Truth())
+}
+
+// String formats a value like str().
+func String(v Value) string {
+	if s, ok := v.(starlark.String); ok {
+		return string(s)
+	}
+	return v.String()
+}
+
+// ===== Go conversion =====
+
+// FromGo converts Go values (nil, bool, integers, floats, strings, slices and
+// string-keyed maps of those, and script values) to script values.
+func FromGo(v any) (Value, error) {
+	switch x := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case Value:
+		return x, nil
+	case bool:
+		return starlark.Bool(x), nil
+	case int:
+		return starlark.MakeInt(x), nil
+	case int32:
+		return starlark.MakeInt64(int64(x)), nil
+	case int64:
+		return starlark.MakeInt64(x), nil
+	case float32:
+		return starlark.Float(x), nil
+	case float64:
+		return starlark.Float(x), nil
+	case string:
+		return starlark.String(x), nil
+	case []string:
+		elems := make([]Value, len(x))
+		for i, s := range x {
+			elems[i] = starlark.String(s)
+		}
+		return starlark.NewList(elems), nil
+	case []any:
+		elems := make([]Value, len(x))
+		for i, e := range x {
+			sv, err := FromGo(e)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = sv
+		}
+		return starlark.NewList(elems), nil
+	case map[string]string:
+		d := starlark.NewDict(len(x))
+		for _, k := range sortedKeys(x) {
+			d.SetKey(starlark.String(k), starlark.String(x[k]))
+		}
+		return d, nil
+	case map[string]any:
+		d := starlark.NewDict(len(x))
+		for _, k := range sortedKeys(x) {
+			sv, err := FromGo(x[k])
+			if err != nil {
+				return nil, err
+			}
+			d.SetKey(starlark.String(k), sv)
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("cannot convert %T to a script value", v)
+}
+
+// ToGo converts a script value to plain Go values: None becomes nil, ints int64
+// (or their decimal string when they do not fit), lists and tuples []any, dicts
+// and structs map[string]any with keys formatted by str(). Other values are
+// formatted by str().
+func ToGo(v Value) any {
+	switch x := v.(type) {
+	case starlark.NoneType:
+		return nil
+	case starlark.Bool:
+		return bool(x)
+	case starlark.Int:
+		if i, ok := x.Int64(); ok {
+			return i
+		}
+		return x.String()
+	case starlark.Float:
+		return float64(x)
+	case starlark.String:
+		return string(x)
+	case starlark.Indexable:
+		out := make([]any, x.Len())
+		for i := range out {
+			out[i] = ToGo(x.Index(i))
+		}
+		return out
+	case *starlark.Dict:
+		out := make(map[string]any, x.Len())
+		for _, item := range x.Items() {
+			out[String(item[0])] = ToGo(item[1])
+		}
+		return out
+	case *starlarkstruct.Struct:
+		out := make(map[string]any)
+		for _, name := range x.AttrNames() {
+			f, _ := x.Attr(name)
+			out[name] = ToGo(f)
+		}
+		return out
+	}
+	return String(v)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,94 @@
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gossher/internal/inventory"
+)
+
+// Ensure GPG implements the codec interface
+var (
+	_ inventory.FileCodec = (*GPG)(nil)
+)
+
+// GPGExtension is the suffix of GPG-encrypted data files ("credential-admin.yaml.gpg").
+const GPGExtension = ".gpg"
+
+// GPG encrypts and decrypts data files with the gpg binary, producing files that
+// pass(1) and other GPG-based tooling can read.
+type GPG struct {
+	// Recipients are the key IDs (or emails) files are encrypted for.
+	Recipients []string
+	// Binary is the gpg executable (default "gpg").
+	Binary string
+	// HomeDir overrides GNUPGHOME when set.
+	HomeDir string
+}
+
+// NewGPG creates a GPG codec encrypting for the given recipients.
+func NewGPG(recipients ...string) *GPG {
+	return &GPG{Recipients: recipients}
+}
+
+// Extension implements inventory.FileCodec.
+func (g *GPG) Extension() string {
+	return GPGExtension
+}
+
+// Encode encrypts plain YAML for all recipients.
+func (g *GPG) Encode(plain []byte) ([]byte, error) {
+	if len(g.Recipients) == 0 {
+		return nil, fmt.Errorf("gpg: no recipients configured")
+	}
+
+	args := []string{"--batch", "--yes", "--quiet", "--trust-model", "always", "--encrypt"}
+	for _, r := range g.Recipients {
+		args = append(args, "--recipient", r)
+	}
+	return g.run(plain, args...)
+}
+
+// Decode decrypts a GPG message with any secret key available to the agent.
+func (g *GPG) Decode(data []byte) ([]byte, error) {
+	return g.run(data, "--batch", "--quiet", "--decrypt")
+}
+
+func (g *GPG) run(input []byte, args ...string) ([]byte, error) {
+	binary := g.Binary
+	if binary == "" {
+		binary = "gpg"
+	}
+
+	cmd := exec.Command(binary, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	if g.HomeDir != "" {
+		cmd.Env = append(os.Environ(), "GNUPGHOME="+g.HomeDir)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("gpg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// profileCodecs configures GPG for profiles that list recipients. Decryption is always
+// registered so existing encrypted files stay readable after recipients are removed.
+func profileCodecs(p inventory.Profile) (inventory.FileCodec, []inventory.FileCodec) {
+	g := NewGPG(p.GPGRecipients...)
+	if len(p.GPGRecipients) == 0 {
+		return nil, []inventory.FileCodec{g}
+	}
+	return g, []inventory.FileCodec{g}
+}
+
+func init() {
+	inventory.RegisterCodecProvider(profileCodecs)
+}
@@ -0,0 +1,69 @@
+package secrets
+
+import (
+	"os/exec"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupGPGHome creates a throwaway keyring with one unprotected key.
+func setupGPGHome(t *testing.T) string {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not installed")
+	}
+
+	home := t.TempDir()
+	t.Cleanup(func() {
+		kill := exec.Command("gpgconf", "--kill", "gpg-agent")
+		kill.Env = append(kill.Environ(), "GNUPGHOME="+home)
+		kill.Run()
+	})
+
+	cmd := exec.Command("gpg", "--batch", "--passphrase", "", "--quick-gen-key", "test@gossher.local", "default", "default", "never")
+	cmd.Env = append(cmd.Environ(), "GNUPGHOME="+home)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("cannot create gpg key: %v: %s", err, out)
+	}
+	return home
+}
+
+func TestGPGRoundTrip(t *testing.T) {
+	home := setupGPGHome(t)
+
+	g := NewGPG("test@gossher.local")
+	g.HomeDir = home
+
+	encrypted, err := g.Encode([]byte("password: secret\n"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(encrypted), "secret")
+
+	plain, err := g.Decode(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "password: secret\n", string(plain))
+
+	t.Run("no recipients", func(t *testing.T) {
+		_, err := (&GPG{HomeDir: home}).Encode([]byte("x"))
+		assert.Error(t, err)
+	})
+
+	t.Run("manager integration", func(t *testing.T) {
+		dir := t.TempDir()
+		m := inventory.NewManager(dir)
+		m.SetCredentialCodec(g)
+
+		cred := inventory.NewCredential("admin", "Admin", "root")
+		cred.Password = "secret"
+		require.NoError(t, m.AddCredential(cred))
+
+		reloaded := inventory.NewManager(dir)
+		reloaded.RegisterCodec(g)
+		require.NoError(t, reloaded.Load())
+		c, ok := reloaded.GetCredential("admin")
+		require.True(t, ok)
+		assert.Equal(t, "secret", c.Password)
+	})
+}
@@ -0,0 +1,224 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"gossher/internal/inventory"
+)
+
+// Ensure the password managers implement the provider interface
+var (
+	_ inventory.CredentialProvider = (*OnePassword)(nil)
+	_ inventory.CredentialProvider = (*Bitwarden)(nil)
+	_ inventory.CredentialProvider = (*RBW)(nil)
+)
+
+// Custom field labels read from password manager items, compared case-insensitively.
+const (
+	privateKeyField = "private_key"
+	passphraseField = "passphrase"
+)
+
+// ===== 1Password =====
+
+// OnePassword reads items with the 1Password CLI (op). The user must be signed in,
+// either through the desktop app integration or an OP_SESSION variable.
+type OnePassword struct {
+	// Binary is the op executable (default "op").
+	Binary string
+}
+
+// Name implements inventory.CredentialProvider.
+func (p *OnePassword) Name() string {
+	return "op"
+}
+
+// Fetch implements inventory.CredentialProvider. Passwords come from the field with
+// the PASSWORD purpose, keys from SSH Key items or a "private_key" field.
+func (p *OnePassword) Fetch(itemID string) (*inventory.ProviderSecret, error) {
+	if err := checkItemID("op", itemID); err != nil {
+		return nil, err
+	}
+	out, err := runTool(p.Binary, "op", "item", "get", itemID, "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	var item struct {
+		Fields []struct {
+			ID         string `json:"id"`
+			Label      string `json:"label"`
+			Purpose    string `json:"purpose"`
+			Value      string `json:"value"`
+			SSHFormats struct {
+				OpenSSH struct {
+					Value string `json:"value"`
+				} `json:"openssh"`
+			} `json:"ssh_formats"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(out, &item); err != nil {
+		return nil, fmt.Errorf("op: failed to parse item: %w", err)
+	}
+
+	secret := &inventory.ProviderSecret{}
+	for _, f := range item.Fields {
+		switch {
+		case f.Purpose == "PASSWORD":
+			secret.Password = f.Value
+		case f.ID == privateKeyField || strings.EqualFold(f.Label, privateKeyField):
+			secret.PrivateKey = f.Value
+			if f.SSHFormats.OpenSSH.Value != "" {
+				secret.PrivateKey = f.SSHFormats.OpenSSH.Value
+			}
+		case strings.EqualFold(f.Label, passphraseField):
+			secret.Passphrase = f.Value
+		}
+	}
+	return secret, nil
+}
+
+// ===== Bitwarden =====
+
+// bitwardenItem is the item JSON shared by bw and rbw --raw.
+type bitwardenItem struct {
+	Login *struct {
+		Password string `json:"password"`
+	} `json:"login"`
+	SSHKey *struct {
+		PrivateKey string `json:"privateKey"`
+	} `json:"sshKey"`
+	Data *struct {
+		Password   string `json:"password"`
+		PrivateKey string `json:"private_key"`
+	} `json:"data"`
+	Fields []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"fields"`
+}
+
+func (item *bitwardenItem) secret() *inventory.ProviderSecret {
+	secret := &inventory.ProviderSecret{}
+	if item.Login != nil {
+		secret.Password = item.Login.Password
+	}
+	if item.SSHKey != nil {
+		secret.PrivateKey = item.SSHKey.PrivateKey
+	}
+	if item.Data != nil {
+		if item.Data.Password != "" {
+			secret.Password = item.Data.Password
+		}
+		if item.Data.PrivateKey != "" {
+			secret.PrivateKey = item.Data.PrivateKey
+		}
+	}
+	for _, f := range item.Fields {
+		switch {
+		case strings.EqualFold(f.Name, privateKeyField):
+			secret.PrivateKey = f.Value
+		case strings.EqualFold(f.Name, passphraseField):
+			secret.Passphrase = f.Value
+		}
+	}
+	return secret
+}
+
+// Bitwarden reads items with the official Bitwarden CLI (bw). The vault must be
+// unlocked with BW_SESSION exported.
+type Bitwarden struct {
+	// Binary is the bw executable (default "bw").
+	Binary string
+}
+
+// Name implements inventory.CredentialProvider.
+func (p *Bitwarden) Name() string {
+	return "bw"
+}
+
+// Fetch implements inventory.CredentialProvider. Passwords come from the login,
+// keys from SSH key items or a "private_key" custom field.
+func (p *Bitwarden) Fetch(itemID string) (*inventory.ProviderSecret, error) {
+	if err := checkItemID("bw", itemID); err != nil {
+		return nil, err
+	}
+	out, err := runTool(p.Binary, "bw", "get", "item", itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	var item bitwardenItem
+	if err := json.Unmarshal(out, &item); err != nil {
+		return nil, fmt.Errorf("bw: failed to parse item: %w", err)
+	}
+	return item.secret(), nil
+}
+
+// RBW reads items with rbw, the unofficial Bitwarden client whose agent keeps the
+// vault unlocked between calls.
+type RBW struct {
+	// Binary is the rbw executable (default "rbw").
+	Binary string
+}
+
+// Name implements inventory.CredentialProvider.
+func (p *RBW) Name() string {
+	return "rbw"
+}
+
+// Fetch implements inventory.CredentialProvider.
+func (p *RBW) Fetch(itemID string) (*inventory.ProviderSecret, error) {
+	if err := checkItemID("rbw", itemID); err != nil {
+		return nil, err
+	}
+	out, err := runTool(p.Binary, "rbw", "get", "--raw", itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	var item bitwardenItem
+	if err := json.Unmarshal(out, &item); err != nil {
+		return nil, fmt.Errorf("rbw: failed to parse item: %w", err)
+	}
+	return item.secret(), nil
+}
+
+// checkItemID refuses item IDs the CLIs would parse as flags.
+func checkItemID(tool, itemID string) error {
+	if itemID == "" || strings.HasPrefix(itemID, "-") {
+		return fmt.Errorf("%s: invalid item ID %q", tool, itemID)
+	}
+	return nil
+}
+
+// runTool runs a password manager CLI and returns its standard output.
+func runTool(binary, fallback string, args ...string) ([]byte, error) {
+	if binary == "" {
+		binary = fallback
+	}
+	if _, err := exec.LookPath(binary); err != nil {
+		return nil, fmt.Errorf("%s is not installed", binary)
+	}
+
+	cmd := exec.Command(binary, args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", fallback, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+func init() {
+	inventory.RegisterCredentialProvider(&OnePassword{})
+	inventory.RegisterCredentialProvider(&Bitwarden{})
+	inventory.RegisterCredentialProvider(&RBW{})
+}
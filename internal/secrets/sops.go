@@ -0,0 +1,78 @@
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"gossher/internal/inventory"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Ensure SOPS implements the decoder interface
+var (
+	_ inventory.ContentDecoder = (*SOPS)(nil)
+)
+
+// SOPS decrypts sops-encrypted YAML files by shelling out to the sops binary, which
+// takes care of KMS, age and PGP key lookup exactly as it does for the user.
+type SOPS struct {
+	// Binary is the sops executable (default "sops").
+	Binary string
+}
+
+// NewSOPS creates a SOPS decoder using the sops binary from PATH.
+func NewSOPS() *SOPS {
+	return &SOPS{}
+}
+
+// Name implements inventory.ContentDecoder.
+func (s *SOPS) Name() string {
+	return "sops"
+}
+
+// Detect reports whether data is a sops-encrypted YAML document, which carries a
+// top-level "sops" mapping with the encryption metadata.
+func (s *SOPS) Detect(data []byte) bool {
+	if !bytes.HasPrefix(data, []byte("sops:")) && !bytes.Contains(data, []byte("\nsops:")) {
+		return false
+	}
+
+	var doc struct {
+		Sops map[string]any `yaml:"sops"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return false
+	}
+	_, hasMAC := doc.Sops["mac"]
+	_, hasVersion := doc.Sops["version"]
+	return hasMAC || hasVersion
+}
+
+// DecodeFile decrypts the file at path.
+func (s *SOPS) DecodeFile(path string, data []byte) ([]byte, error) {
+	binary := s.Binary
+	if binary == "" {
+		binary = "sops"
+	}
+	if _, err := exec.LookPath(binary); err != nil {
+		return nil, fmt.Errorf("sops-encrypted file found but %s is not installed", binary)
+	}
+
+	cmd := exec.Command(binary, "--decrypt", "--input-type", "yaml", "--output-type", "yaml", path)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("sops: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+func init() {
+	inventory.RegisterContentDecoder(NewSOPS())
+}
@@ -0,0 +1,72 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sopsEncryptedHost = `type: ENC[AES256_GCM,data:x,iv:y,tag:z,type:str]
+id: ENC[AES256_GCM,data:x,iv:y,tag:z,type:str]
+sops:
+    age: []
+    lastmodified: "2024-01-01T00:00:00Z"
+    mac: ENC[AES256_GCM,data:x,iv:y,tag:z,type:str]
+    version: 3.8.1
+`
+
+// fakeTool writes a script named name that prints a fixed output, standing in for
+// an external CLI.
+func fakeTool(t *testing.T, name, output string) string {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "output")
+	require.NoError(t, os.WriteFile(out, []byte(output), 0644))
+
+	script := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\ncat '"+out+"'\n"), 0755))
+	return script
+}
+
+func TestSOPSDetect(t *testing.T) {
+	s := NewSOPS()
+
+	assert.True(t, s.Detect([]byte(sopsEncryptedHost)))
+	assert.False(t, s.Detect([]byte("type: host\nid: web\n")))
+	assert.False(t, s.Detect([]byte("type: host\nvars:\n  sops: yes\n")))
+	assert.False(t, s.Detect([]byte("sops: just-a-string\n")))
+}
+
+func TestSOPSManagerIntegration(t *testing.T) {
+	plain := "type: host\nid: web\nname: web\naddress: 10.0.0.1\nport: 22\nuser: root\n"
+	s := &SOPS{Binary: fakeTool(t, "sops", plain)}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "host-web.yaml"), []byte(sopsEncryptedHost), 0644))
+
+	decoded, err := s.DecodeFile(filepath.Join(dir, "host-web.yaml"), []byte(sopsEncryptedHost))
+	require.NoError(t, err)
+	assert.Equal(t, plain, string(decoded))
+
+	// Swap the globally registered decoder for the fake one.
+	inventory.RegisterContentDecoder(s)
+
+	m := inventory.NewManager(dir)
+	require.NoError(t, m.Load())
+
+	h, ok := m.GetHost("web")
+	require.True(t, ok)
+	assert.Equal(t, "10.0.0.1", h.Address)
+
+	tool, readOnly := m.IsReadOnly("host-web.yaml")
+	assert.True(t, readOnly)
+	assert.Equal(t, "sops", tool)
+
+	h.Address = "10.0.0.2"
+	err = m.UpdateHost(h)
+	assert.Error(t, err, "sops files must not be rewritten in plaintext")
+}
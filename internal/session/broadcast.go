@@ -0,0 +1,200 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+)
+
+// ErrNoPanes is returned by Broadcast.Write when no pane receives the input.
+var ErrNoPanes = errors.New("no pane receives broadcast input")
+
+// Broadcast sends the keystrokes typed once to the sessions of several hosts at
+// the same time, like ClusterSSH. Each host session is a pane that can be muted
+// to keep it out of the broadcast, e.g. to fix up a single host. A TUI writes the
+// keystrokes of its input line to the Broadcast; tmux panes are written through
+// TmuxPane.
+type Broadcast struct {
+	// OnError is called when writing to a pane fails; the pane then stops
+	// receiving input and reports the error in its PaneState.
+	OnError func(name string, err error)
+
+	mu    sync.Mutex
+	panes []*pane
+}
+
+// pane is a session receiving broadcast input.
+type pane struct {
+	name  string
+	w     io.Writer
+	muted bool
+	err   error
+}
+
+// PaneState describes a pane of a Broadcast.
+type PaneState struct {
+	Name  string
+	Muted bool
+	// Err is the write error that detached the pane.
+	Err error
+}
+
+// Add adds the input of a session as a pane; names identify panes, usually by
+// host ID.
+func (b *Broadcast) Add(name string, w io.Writer) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.find(name) != nil {
+		return fmt.Errorf("pane %s already exists", name)
+	}
+	b.panes = append(b.panes, &pane{name: name, w: w})
+	return nil
+}
+
+// Remove removes a pane, e.g. after its session ended.
+func (b *Broadcast) Remove(name string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, p := range b.panes {
+		if p.name == name {
+			b.panes = append(b.panes[:i], b.panes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// SetMuted mutes or unmutes a pane.
+func (b *Broadcast) SetMuted(name string, muted bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p := b.find(name)
+	if p == nil {
+		return fmt.Errorf("pane %s not found", name)
+	}
+	p.muted = muted
+	return nil
+}
+
+// ToggleMute flips the muting of a pane and returns whether it is now muted.
+func (b *Broadcast) ToggleMute(name string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p := b.find(name)
+	if p == nil {
+		return false, fmt.Errorf("pane %s not found", name)
+	}
+	p.muted = !p.muted
+	return p.muted, nil
+}
+
+// Panes returns the state of the panes in the order they were added.
+func (b *Broadcast) Panes() []PaneState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	states := make([]PaneState, len(b.panes))
+	for i, p := range b.panes {
+		states[i] = PaneState{Name: p.name, Muted: p.muted, Err: p.err}
+	}
+	return states
+}
+
+// Write sends p to every pane that is neither muted nor detached by an error. The
+// panes are written concurrently, so a slow session does not hold up the others,
+// and Write returns once all of them received p. Failing panes are detached; Write
+// only fails when no pane received p.
+func (b *Broadcast) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	var targets []*pane
+	for _, pn := range b.panes {
+		if !pn.muted && pn.err == nil {
+			targets = append(targets, pn)
+		}
+	}
+	b.mu.Unlock()
+
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, pn := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = pn.w.Write(p)
+		}()
+	}
+	wg.Wait()
+
+	delivered := 0
+	for i, err := range errs {
+		if err == nil {
+			delivered++
+			continue
+		}
+		b.mu.Lock()
+		targets[i].err = err
+		b.mu.Unlock()
+		if b.OnError != nil {
+			b.OnError(targets[i].name, err)
+		}
+	}
+	if delivered == 0 {
+		return 0, ErrNoPanes
+	}
+	return len(p), nil
+}
+
+// SendTo writes p to a single pane whether it is muted or not, for input typed
+// into a focused pane.
+func (b *Broadcast) SendTo(name string, p []byte) error {
+	b.mu.Lock()
+	pn := b.find(name)
+	b.mu.Unlock()
+	if pn == nil {
+		return fmt.Errorf("pane %s not found", name)
+	}
+	if _, err := pn.w.Write(p); err != nil {
+		return fmt.Errorf("failed to send input to pane %s: %w", name, err)
+	}
+	return nil
+}
+
+// find returns the pane with the given name; b.mu must be held.
+func (b *Broadcast) find(name string) *pane {
+	for _, p := range b.panes {
+		if p.name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// ===== tmux =====
+
+// TmuxPane is the input of a tmux pane, for broadcasting to sessions opened in
+// tmux windows. Writes are sent literally with tmux send-keys.
+type TmuxPane struct {
+	// Target is the tmux target pane, e.g. "gossher:1.0" or a pane ID like "%3".
+	Target string
+	// Run runs tmux (default exec.Command(name, args...).Run).
+	Run func(name string, args ...string) error
+}
+
+func (t TmuxPane) Write(p []byte) (int, error) {
+	run := t.Run
+	if run == nil {
+		run = func(name string, args ...string) error {
+			return exec.Command(name, args...).Run()
+		}
+	}
+	if err := run("tmux", "send-keys", "-t", t.Target, "-l", "--", string(p)); err != nil {
+		return 0, fmt.Errorf("failed to send keys to tmux pane %s: %w", t.Target, err)
+	}
+	return len(p), nil
+}
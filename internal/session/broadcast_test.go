@@ -0,0 +1,88 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("session closed") }
+
+func TestBroadcast(t *testing.T) {
+	t.Run("sends input to the unmuted panes", func(t *testing.T) {
+		var b Broadcast
+		var web01, web02 bytes.Buffer
+		require.NoError(t, b.Add("web01", &web01))
+		require.NoError(t, b.Add("web02", &web02))
+		assert.Error(t, b.Add("web01", &web01))
+
+		_, err := b.Write([]byte("uptime\r"))
+		require.NoError(t, err)
+
+		muted, err := b.ToggleMute("web02")
+		require.NoError(t, err)
+		assert.True(t, muted)
+		_, err = b.Write([]byte("ls\r"))
+		require.NoError(t, err)
+		require.NoError(t, b.SendTo("web02", []byte("df\r")))
+
+		assert.Equal(t, "uptime\rls\r", web01.String())
+		assert.Equal(t, "uptime\rdf\r", web02.String())
+		assert.Equal(t, []PaneState{{Name: "web01"}, {Name: "web02", Muted: true}}, b.Panes())
+
+		require.NoError(t, b.SetMuted("web02", false))
+		assert.True(t, b.Remove("web01"))
+		_, err = b.Write([]byte("w\r"))
+		require.NoError(t, err)
+		assert.Equal(t, "uptime\rls\r", web01.String())
+		assert.Equal(t, "uptime\rdf\rw\r", web02.String())
+
+		assert.Error(t, b.SetMuted("nope", true))
+		_, err = b.ToggleMute("nope")
+		assert.Error(t, err)
+	})
+
+	t.Run("detaches failing panes", func(t *testing.T) {
+		var failed []string
+		b := Broadcast{OnError: func(name string, err error) { failed = append(failed, name) }}
+		var web01 bytes.Buffer
+		require.NoError(t, b.Add("web01", &web01))
+		require.NoError(t, b.Add("web02", failingWriter{}))
+
+		n, err := b.Write([]byte("id\r"))
+		require.NoError(t, err)
+		assert.Equal(t, 3, n)
+		assert.Equal(t, []string{"web02"}, failed)
+		assert.EqualError(t, b.Panes()[1].Err, "session closed")
+
+		_, err = b.Write([]byte("id\r"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web02"}, failed, "detached panes are not written again")
+
+		require.NoError(t, b.SetMuted("web01", true))
+		_, err = b.Write([]byte("id\r"))
+		assert.ErrorIs(t, err, ErrNoPanes)
+	})
+
+	t.Run("writes tmux panes with send-keys", func(t *testing.T) {
+		var mu sync.Mutex
+		var calls [][]string
+		run := func(name string, args ...string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, append([]string{name}, args...))
+			return nil
+		}
+		var b Broadcast
+		require.NoError(t, b.Add("web01", TmuxPane{Target: "%1", Run: run}))
+		_, err := b.Write([]byte("-n\r"))
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"tmux", "send-keys", "-t", "%1", "-l", "--", "-n\r"}}, calls)
+	})
+}
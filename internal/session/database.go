@@ -0,0 +1,195 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"gossher/internal/inventory"
+)
+
+// Host vars describing the database of a host.
+const (
+	// VarDBEngine is the database engine: postgres or mysql (required).
+	VarDBEngine = "db_engine"
+	// VarDBHost is the database address as seen from the host (default localhost).
+	VarDBHost = "db_host"
+	// VarDBPort is the database port (default the port of the engine).
+	VarDBPort = "db_port"
+	// VarDBName is the database to connect to.
+	VarDBName = "db_name"
+	// VarDBUser is the database user.
+	VarDBUser = "db_user"
+)
+
+// DBEngine is a database engine.
+type DBEngine string
+
+const (
+	DBPostgres DBEngine = "postgres"
+	DBMySQL    DBEngine = "mysql"
+)
+
+// dbEngines maps the accepted db_engine values to engines.
+var dbEngines = map[string]DBEngine{
+	"postgres":   DBPostgres,
+	"postgresql": DBPostgres,
+	"pg":         DBPostgres,
+	"mysql":      DBMySQL,
+	"mariadb":    DBMySQL,
+}
+
+// defaultDBPorts are the ports used when db_port is not set.
+var defaultDBPorts = map[DBEngine]int{
+	DBPostgres: 5432,
+	DBMySQL:    3306,
+}
+
+// Database is the database of a host, read from its vars.
+type Database struct {
+	Engine DBEngine
+	Host   string
+	Port   int
+	Name   string
+	User   string
+}
+
+// DatabaseFromVars reads the database of a host from its effective vars.
+func DatabaseFromVars(vars map[string]string) (Database, error) {
+	engine := strings.ToLower(strings.TrimSpace(vars[VarDBEngine]))
+	if engine == "" {
+		return Database{}, fmt.Errorf("%s is not set", VarDBEngine)
+	}
+	db := Database{
+		Engine: dbEngines[engine],
+		Host:   vars[VarDBHost],
+		Name:   vars[VarDBName],
+		User:   vars[VarDBUser],
+	}
+	if db.Engine == "" {
+		return Database{}, fmt.Errorf("unsupported %s %q (supported: postgres, mysql)", VarDBEngine, engine)
+	}
+	if db.Host == "" {
+		db.Host = "localhost"
+	}
+
+	db.Port = defaultDBPorts[db.Engine]
+	if p := vars[VarDBPort]; p != "" {
+		port, err := strconv.Atoi(p)
+		if err != nil || port <= 0 || port > 65535 {
+			return Database{}, fmt.Errorf("invalid %s %q", VarDBPort, p)
+		}
+		db.Port = port
+	}
+	return db, nil
+}
+
+// Target returns the address of the database as seen from the host.
+func (db Database) Target() string {
+	return net.JoinHostPort(db.Host, strconv.Itoa(db.Port))
+}
+
+// URL returns the connection string of the database reached at the local address
+// addr, e.g. postgres://app@127.0.0.1:54321/orders.
+func (db Database) URL(addr string) string {
+	u := url.URL{Scheme: string(db.Engine), Host: addr, Path: "/" + db.Name}
+	if db.User != "" {
+		u.User = url.User(db.User)
+	}
+	return u.String()
+}
+
+// Client returns the command line of the client of the engine connecting to the
+// local address addr: psql or mysql.
+func (db Database) Client(addr string) []string {
+	host, port, _ := net.SplitHostPort(addr)
+	switch db.Engine {
+	case DBMySQL:
+		args := []string{"mysql", "--host", host, "--port", port, "--protocol", "tcp"}
+		if db.User != "" {
+			args = append(args, "--user", db.User)
+		}
+		if db.Name != "" {
+			args = append(args, db.Name)
+		}
+		return args
+	default:
+		args := []string{"psql", "--host", host, "--port", port}
+		if db.User != "" {
+			args = append(args, "--username", db.User)
+		}
+		if db.Name != "" {
+			args = append(args, "--dbname", db.Name)
+		}
+		return args
+	}
+}
+
+// DatabaseTunnel is a port forward to the database of a host.
+type DatabaseTunnel struct {
+	*Tunnel
+	Database Database
+	// Addr is the local address of the forward.
+	Addr string
+}
+
+// URL returns the connection string through the tunnel.
+func (d *DatabaseTunnel) URL() string {
+	return d.Database.URL(d.Addr)
+}
+
+// Client returns the command line of the database client through the tunnel.
+func (d *DatabaseTunnel) Client() []string {
+	return d.Database.Client(d.Addr)
+}
+
+// Launch runs the database client on the terminal and waits for it to exit.
+func (d *DatabaseTunnel) Launch(ctx context.Context) error {
+	args := d.Client()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// DatabaseHelper replaces the usual steps to reach a database behind a bastion,
+// looking up the port, opening the forward and typing the client command, with
+// one call: Open reads the db_* vars of a host, forwards a local port to the
+// database through the host and returns the client command and connection string.
+type DatabaseHelper struct {
+	Manager *inventory.Manager
+	Tunnels *Tunnels
+	// Dial connects to the database from the host.
+	Dial DialFunc
+}
+
+// Open forwards a local port to the database of a host. listen is the local
+// address (default a free port on 127.0.0.1). The tunnel is named db-<host>.
+func (h *DatabaseHelper) Open(ctx context.Context, hostID, listen string) (*DatabaseTunnel, error) {
+	vars, err := h.Manager.ResolveVars(hostID)
+	if err != nil {
+		return nil, err
+	}
+	db, err := DatabaseFromVars(vars)
+	if err != nil {
+		return nil, fmt.Errorf("host %s: %w", hostID, err)
+	}
+
+	t, err := h.Tunnels.Forward(ctx, TunnelOptions{
+		Name:   "db-" + hostID,
+		HostID: hostID,
+		Listen: listen,
+		Target: db.Target(),
+	}, h.Dial)
+	if err != nil {
+		return nil, err
+	}
+	return &DatabaseTunnel{Tunnel: t, Database: db, Addr: t.Stats().Listen}, nil
+}
@@ -0,0 +1,104 @@
+package session
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseFromVars(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		db, err := DatabaseFromVars(map[string]string{VarDBEngine: "PostgreSQL"})
+		require.NoError(t, err)
+		assert.Equal(t, Database{Engine: DBPostgres, Host: "localhost", Port: 5432}, db)
+		assert.Equal(t, "localhost:5432", db.Target())
+
+		db, err = DatabaseFromVars(map[string]string{VarDBEngine: "mariadb"})
+		require.NoError(t, err)
+		assert.Equal(t, 3306, db.Port)
+	})
+
+	t.Run("invalid vars", func(t *testing.T) {
+		_, err := DatabaseFromVars(map[string]string{})
+		assert.EqualError(t, err, "db_engine is not set")
+		_, err = DatabaseFromVars(map[string]string{VarDBEngine: "oracle"})
+		assert.ErrorContains(t, err, "unsupported db_engine")
+		_, err = DatabaseFromVars(map[string]string{VarDBEngine: "pg", VarDBPort: "70000"})
+		assert.EqualError(t, err, `invalid db_port "70000"`)
+	})
+
+	t.Run("client commands", func(t *testing.T) {
+		pg := Database{Engine: DBPostgres, Host: "10.0.5.4", Port: 6432, Name: "orders", User: "app"}
+		assert.Equal(t, []string{"psql", "--host", "127.0.0.1", "--port", "54321", "--username", "app", "--dbname", "orders"},
+			pg.Client("127.0.0.1:54321"))
+		assert.Equal(t, "postgres://app@127.0.0.1:54321/orders", pg.URL("127.0.0.1:54321"))
+
+		my := Database{Engine: DBMySQL, Host: "localhost", Port: 3306, Name: "shop"}
+		assert.Equal(t, []string{"mysql", "--host", "127.0.0.1", "--port", "33060", "--protocol", "tcp", "shop"},
+			my.Client("127.0.0.1:33060"))
+		assert.Equal(t, "mysql://127.0.0.1:33060/shop", my.URL("127.0.0.1:33060"))
+	})
+}
+
+func TestDatabaseHelper(t *testing.T) {
+	m := inventory.NewManager(t.TempDir())
+	require.NoError(t, m.Load())
+	g := inventory.NewGroup("databases")
+	g.HostIDs = []string{"db01"}
+	g.Vars = map[string]string{VarDBEngine: "postgres", VarDBUser: "app"}
+	require.NoError(t, m.AddGroup(g))
+	h := inventory.NewHost("db01", "db01", "10.0.5.4")
+	h.User = "deploy"
+	h.Vars = map[string]string{VarDBPort: "6432", VarDBName: "orders"}
+	require.NoError(t, m.AddHost(h))
+	web := inventory.NewHost("web01", "web01", "10.0.1.1")
+	web.User = "deploy"
+	require.NoError(t, m.AddHost(web))
+
+	dialed := make(chan string, 1)
+	helper := &DatabaseHelper{
+		Manager: m,
+		Tunnels: &Tunnels{},
+		Dial: func(ctx context.Context, hostID, addr string) (net.Conn, error) {
+			dialed <- hostID + " " + addr
+			local, remote := net.Pipe()
+			go func() {
+				defer remote.Close()
+				remote.Write([]byte("R"))
+			}()
+			return local, nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d, err := helper.Open(ctx, "db01", "")
+	require.NoError(t, err)
+	assert.Equal(t, "db-db01", d.Stats().Name)
+	assert.Equal(t, "localhost:6432", d.Stats().Target)
+	assert.Equal(t, "postgres://app@"+d.Addr+"/orders", d.URL())
+	assert.Equal(t, "psql", d.Client()[0])
+
+	c, err := net.Dial("tcp", d.Addr)
+	require.NoError(t, err)
+	greeting, err := io.ReadAll(c)
+	require.NoError(t, err)
+	c.Close()
+	assert.Equal(t, "R", string(greeting))
+	assert.Equal(t, "db01 localhost:6432", <-dialed)
+
+	_, err = helper.Open(ctx, "db01", "")
+	assert.ErrorContains(t, err, "already exists")
+	_, err = helper.Open(ctx, "web01", "")
+	assert.EqualError(t, err, "host web01: db_engine is not set")
+
+	require.NoError(t, d.Close())
+	_, err = net.Dial("tcp", d.Addr)
+	assert.Error(t, err)
+}
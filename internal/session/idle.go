@@ -0,0 +1,202 @@
+// Package session tracks user activity and open SSH sessions and tunnels, and
+// broadcasts input to several sessions.
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrLocked is returned by Guard while the application is locked.
+var ErrLocked = errors.New("application is locked")
+
+// IdleOptions configures an IdleMonitor.
+type IdleOptions struct {
+	// Timeout is the inactivity period after which the application locks and idle
+	// sessions are closed. Zero disables the monitor.
+	Timeout time.Duration
+	// Verify checks the master passphrase when unlocking.
+	Verify func(passphrase string) error
+	// OnLock is called once each time the application locks.
+	OnLock func()
+	// Now returns the current time (default time.Now).
+	Now func() time.Time
+}
+
+// IdleMonitor locks the application and closes idle sessions and tunnels after a
+// period without activity.
+type IdleMonitor struct {
+	opts IdleOptions
+
+	mu      sync.Mutex
+	last    time.Time
+	locked  bool
+	tracked map[*Activity]struct{}
+}
+
+// Activity is a tracked session or tunnel with its own inactivity clock.
+type Activity struct {
+	Name string
+
+	monitor *IdleMonitor
+	closer  io.Closer
+	last    time.Time
+}
+
+// NewIdleMonitor creates a monitor; the application starts unlocked and active.
+func NewIdleMonitor(opts IdleOptions) *IdleMonitor {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &IdleMonitor{
+		opts:    opts,
+		last:    opts.Now(),
+		tracked: make(map[*Activity]struct{}),
+	}
+}
+
+// Enabled reports whether a timeout is configured.
+func (m *IdleMonitor) Enabled() bool {
+	return m.opts.Timeout > 0
+}
+
+// Touch records user activity in the application.
+func (m *IdleMonitor) Touch() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.locked {
+		m.last = m.opts.Now()
+	}
+}
+
+// Locked reports whether the application is locked.
+func (m *IdleMonitor) Locked() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.locked
+}
+
+// Guard returns ErrLocked while the application is locked and records activity otherwise.
+func (m *IdleMonitor) Guard() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.locked {
+		return ErrLocked
+	}
+	m.last = m.opts.Now()
+	return nil
+}
+
+// Lock locks the application immediately.
+func (m *IdleMonitor) Lock() {
+	m.mu.Lock()
+	wasLocked := m.locked
+	m.locked = true
+	m.mu.Unlock()
+
+	if !wasLocked && m.opts.OnLock != nil {
+		m.opts.OnLock()
+	}
+}
+
+// Unlock verifies the master passphrase and unlocks the application.
+func (m *IdleMonitor) Unlock(passphrase string) error {
+	if m.opts.Verify != nil {
+		if err := m.opts.Verify(passphrase); err != nil {
+			return fmt.Errorf("failed to unlock: %w", err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.locked = false
+	m.last = m.opts.Now()
+	return nil
+}
+
+// Track registers a session or tunnel that is closed once it has been idle for the
+// timeout. Call Touch on the returned Activity whenever data flows through it.
+func (m *IdleMonitor) Track(name string, c io.Closer) *Activity {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a := &Activity{Name: name, monitor: m, closer: c, last: m.opts.Now()}
+	m.tracked[a] = struct{}{}
+	return a
+}
+
+// Touch records activity on the session or tunnel.
+func (a *Activity) Touch() {
+	a.monitor.mu.Lock()
+	defer a.monitor.mu.Unlock()
+	a.last = a.monitor.opts.Now()
+}
+
+// Done stops tracking without closing, e.g. after the session ended on its own.
+func (a *Activity) Done() {
+	a.monitor.mu.Lock()
+	defer a.monitor.mu.Unlock()
+	delete(a.monitor.tracked, a)
+}
+
+// Check closes sessions and tunnels that have been idle for the timeout and locks
+// the application if it has. It returns the names of the closed activities.
+func (m *IdleMonitor) Check() []string {
+	if !m.Enabled() {
+		return nil
+	}
+
+	m.mu.Lock()
+	now := m.opts.Now()
+
+	var expired []*Activity
+	for a := range m.tracked {
+		if now.Sub(a.last) >= m.opts.Timeout {
+			expired = append(expired, a)
+			delete(m.tracked, a)
+		}
+	}
+	lock := !m.locked && now.Sub(m.last) >= m.opts.Timeout
+	m.mu.Unlock()
+
+	closed := make([]string, 0, len(expired))
+	for _, a := range expired {
+		a.closer.Close()
+		closed = append(closed, a.Name)
+	}
+
+	if lock {
+		m.Lock()
+	}
+	return closed
+}
+
+// Run calls Check periodically until ctx is cancelled.
+func (m *IdleMonitor) Run(ctx context.Context) {
+	if !m.Enabled() {
+		return
+	}
+
+	interval := m.opts.Timeout / 10
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check()
+		}
+	}
+}
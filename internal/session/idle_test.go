@@ -0,0 +1,96 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+type fakeCloser struct{ closed bool }
+
+func (c *fakeCloser) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestIdleMonitor(t *testing.T) {
+	newMonitor := func() (*IdleMonitor, *fakeClock, *int) {
+		clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		locks := 0
+		m := NewIdleMonitor(IdleOptions{
+			Timeout: 5 * time.Minute,
+			Now:     clock.now,
+			OnLock:  func() { locks++ },
+			Verify: func(p string) error {
+				if p != "master" {
+					return errors.New("wrong passphrase")
+				}
+				return nil
+			},
+		})
+		return m, clock, &locks
+	}
+
+	t.Run("locks after inactivity", func(t *testing.T) {
+		m, clock, locks := newMonitor()
+
+		clock.advance(4 * time.Minute)
+		m.Touch()
+		clock.advance(4 * time.Minute)
+		m.Check()
+		assert.False(t, m.Locked(), "activity resets the timer")
+
+		clock.advance(time.Minute)
+		m.Check()
+		m.Check()
+		assert.True(t, m.Locked())
+		assert.Equal(t, 1, *locks)
+		assert.ErrorIs(t, m.Guard(), ErrLocked)
+	})
+
+	t.Run("unlock requires passphrase", func(t *testing.T) {
+		m, _, _ := newMonitor()
+		m.Lock()
+
+		assert.Error(t, m.Unlock("guess"))
+		assert.True(t, m.Locked())
+
+		require.NoError(t, m.Unlock("master"))
+		assert.False(t, m.Locked())
+		assert.NoError(t, m.Guard())
+	})
+
+	t.Run("closes idle sessions only", func(t *testing.T) {
+		m, clock, _ := newMonitor()
+
+		idle, busy, ended := &fakeCloser{}, &fakeCloser{}, &fakeCloser{}
+		m.Track("web01", idle)
+		active := m.Track("tunnel:5432", busy)
+		m.Track("db01", ended).Done()
+
+		clock.advance(3 * time.Minute)
+		active.Touch()
+		clock.advance(3 * time.Minute)
+
+		closed := m.Check()
+		assert.Equal(t, []string{"web01"}, closed)
+		assert.True(t, idle.closed)
+		assert.False(t, busy.closed)
+		assert.False(t, ended.closed)
+	})
+
+	t.Run("disabled without timeout", func(t *testing.T) {
+		m := NewIdleMonitor(IdleOptions{})
+		assert.False(t, m.Enabled())
+		assert.Nil(t, m.Check())
+		assert.False(t, m.Locked())
+	})
+}
@@ -0,0 +1,240 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"gossher/internal/inventory"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Host vars describing the Kubernetes cluster reached through a host.
+const (
+	// VarKubeconfig is the kubeconfig holding the cluster and its credentials
+	// (default $KUBECONFIG or ~/.kube/config).
+	VarKubeconfig = "kubeconfig"
+	// VarKubeContext is the context of the kubeconfig to use (default its current
+	// context).
+	VarKubeContext = "kube_context"
+	// VarKubeAPIServer is the address of the API server as seen from the host,
+	// host:port (default the server of the context's cluster).
+	VarKubeAPIServer = "kube_api_server"
+)
+
+// KubeTunnel is a port forward to a Kubernetes API server with a temporary
+// kubeconfig pointing at it. Closing the tunnel removes the kubeconfig.
+type KubeTunnel struct {
+	*Tunnel
+	// Kubeconfig is the path of the temporary kubeconfig.
+	Kubeconfig string
+	// Context is the only context of the temporary kubeconfig.
+	Context string
+}
+
+// Env returns the environment variable that points kubectl at the tunnel.
+func (k *KubeTunnel) Env() string {
+	return "KUBECONFIG=" + k.Kubeconfig
+}
+
+// KubeHelper reaches the API server of a cluster that is only reachable from a
+// jump host: Open forwards a local port to the API server through the host and
+// writes a temporary kubeconfig whose cluster points at the local forward.
+type KubeHelper struct {
+	Manager *inventory.Manager
+	Tunnels *Tunnels
+	// Dial connects to the API server from the host.
+	Dial DialFunc
+	// TempDir is where temporary kubeconfigs are written (default os.TempDir).
+	TempDir string
+}
+
+// Open forwards a local port to the API server of the cluster configured in the
+// kube vars of a host. listen is the local address (default a free port on
+// 127.0.0.1). The tunnel is named kube-<host>.
+func (h *KubeHelper) Open(ctx context.Context, hostID, listen string) (*KubeTunnel, error) {
+	vars, err := h.Manager.ResolveVars(hostID)
+	if err != nil {
+		return nil, err
+	}
+
+	path := vars[VarKubeconfig]
+	if path == "" {
+		path = defaultKubeconfig()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubeconfig: %w", err)
+	}
+	cfg, err := parseKubeconfig(data, vars[VarKubeContext])
+	if err != nil {
+		return nil, fmt.Errorf("kubeconfig %s: %w", path, err)
+	}
+	cfg.dir = filepath.Dir(path)
+	target := vars[VarKubeAPIServer]
+	if target == "" {
+		if target, err = cfg.serverAddr(); err != nil {
+			return nil, fmt.Errorf("kubeconfig %s: %w", path, err)
+		}
+	}
+
+	f, err := os.CreateTemp(h.TempDir, "gossher-kubeconfig-*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubeconfig: %w", err)
+	}
+	f.Close()
+	tmp := f.Name()
+
+	t, err := h.Tunnels.forward(ctx, TunnelOptions{
+		Name:   "kube-" + hostID,
+		HostID: hostID,
+		Listen: listen,
+		Target: target,
+	}, h.Dial, func() error { return os.Remove(tmp) })
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+
+	out, err := cfg.forwarded(t.Stats().Listen)
+	if err == nil {
+		err = os.WriteFile(tmp, out, 0o600)
+	}
+	if err != nil {
+		t.Close()
+		return nil, fmt.Errorf("failed to write kubeconfig: %w", err)
+	}
+	return &KubeTunnel{Tunnel: t, Kubeconfig: tmp, Context: cfg.context}, nil
+}
+
+// defaultKubeconfig returns the kubeconfig kubectl uses by default.
+func defaultKubeconfig() string {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		return filepath.SplitList(env)[0]
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".kube", "config")
+}
+
+// kubeconfig is the selected context of a kubeconfig with its cluster and user,
+// kept as generic YAML so that fields unknown here are preserved.
+type kubeconfig struct {
+	// dir is the directory of the kubeconfig, against which kubectl resolves
+	// relative file paths.
+	dir     string
+	context string
+	ctx     map[string]any
+	cluster map[string]any
+	user    map[string]any
+}
+
+// parseKubeconfig selects a context of a kubeconfig, the current one if name is
+// empty.
+func parseKubeconfig(data []byte, name string) (*kubeconfig, error) {
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name, _ = doc["current-context"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("no current context")
+		}
+	}
+
+	cfg := &kubeconfig{context: name}
+	cfg.ctx = namedEntry(doc, "contexts", name)
+	if cfg.ctx == nil {
+		return nil, fmt.Errorf("context %s not found", name)
+	}
+	inner, _ := cfg.ctx["context"].(map[string]any)
+	clusterName, _ := inner["cluster"].(string)
+	if cfg.cluster = namedEntry(doc, "clusters", clusterName); cfg.cluster == nil {
+		return nil, fmt.Errorf("cluster %q of context %s not found", clusterName, name)
+	}
+	if userName, _ := inner["user"].(string); userName != "" {
+		if cfg.user = namedEntry(doc, "users", userName); cfg.user == nil {
+			return nil, fmt.Errorf("user %q of context %s not found", userName, name)
+		}
+	}
+	return cfg, nil
+}
+
+// namedEntry returns the entry of the list key of a kubeconfig with the given name.
+func namedEntry(doc map[string]any, key, name string) map[string]any {
+	list, _ := doc[key].([]any)
+	for _, item := range list {
+		if entry, ok := item.(map[string]any); ok && entry["name"] == name {
+			return entry
+		}
+	}
+	return nil
+}
+
+// serverAddr returns host:port of the cluster's API server.
+func (k *kubeconfig) serverAddr() (string, error) {
+	inner, _ := k.cluster["cluster"].(map[string]any)
+	server, _ := inner["server"].(string)
+	u, err := url.Parse(server)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid server %q of cluster %v", server, k.cluster["name"])
+	}
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), "443"), nil
+	}
+	return u.Host, nil
+}
+
+// forwarded returns a kubeconfig with only the selected context, whose cluster
+// points at the local address addr; the scheme and path of the server are kept.
+// The API server's certificate is still checked against its original name.
+// Relative file paths are made absolute, since the kubeconfig is written
+// elsewhere.
+func (k *kubeconfig) forwarded(addr string) ([]byte, error) {
+	inner, _ := k.cluster["cluster"].(map[string]any)
+	cluster := k.absPaths(inner, "certificate-authority")
+	if u, err := url.Parse(fmt.Sprint(inner["server"])); err == nil && u.Hostname() != "" {
+		if _, set := cluster["tls-server-name"]; !set {
+			cluster["tls-server-name"] = u.Hostname()
+		}
+		u.Host = addr
+		cluster["server"] = u.String()
+	} else {
+		cluster["server"] = "https://" + addr
+	}
+
+	doc := map[string]any{
+		"apiVersion":      "v1",
+		"kind":            "Config",
+		"current-context": k.context,
+		"contexts":        []any{k.ctx},
+		"clusters":        []any{map[string]any{"name": k.cluster["name"], "cluster": cluster}},
+	}
+	if k.user != nil {
+		user, _ := k.user["user"].(map[string]any)
+		doc["users"] = []any{map[string]any{
+			"name": k.user["name"],
+			"user": k.absPaths(user, "client-certificate", "client-key", "tokenFile"),
+		}}
+	}
+	return yaml.Marshal(doc)
+}
+
+// absPaths returns a copy of a kubeconfig entry whose relative file paths under
+// keys are resolved against the directory of the kubeconfig.
+func (k *kubeconfig) absPaths(entry map[string]any, keys ...string) map[string]any {
+	out := make(map[string]any, len(entry)+1)
+	for key, v := range entry {
+		out[key] = v
+	}
+	for _, key := range keys {
+		if p, ok := out[key].(string); ok && p != "" && !filepath.IsAbs(p) {
+			out[key] = filepath.Join(k.dir, p)
+		}
+	}
+	return out
+}
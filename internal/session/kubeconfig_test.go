@@ -0,0 +1,191 @@
+package session
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: prod
+clusters:
+- name: prod-cluster
+  cluster:
+    server: https://api.prod.internal:6443
+    certificate-authority-data: Q0E=
+- name: dev-cluster
+  cluster:
+    server: https://10.9.0.1
+contexts:
+- name: prod
+  context:
+    cluster: prod-cluster
+    user: prod-admin
+    namespace: web
+- name: dev
+  context:
+    cluster: dev-cluster
+    user: dev-admin
+users:
+- name: prod-admin
+  user:
+    token: prod-token
+- name: dev-admin
+  user:
+    token: dev-token
+`
+
+func TestParseKubeconfig(t *testing.T) {
+	t.Run("current context", func(t *testing.T) {
+		cfg, err := parseKubeconfig([]byte(testKubeconfig), "")
+		require.NoError(t, err)
+		addr, err := cfg.serverAddr()
+		require.NoError(t, err)
+		assert.Equal(t, "api.prod.internal:6443", addr)
+
+		out, err := cfg.forwarded("127.0.0.1:40001")
+		require.NoError(t, err)
+		var doc struct {
+			CurrentContext string `yaml:"current-context"`
+			Clusters       []struct {
+				Name    string            `yaml:"name"`
+				Cluster map[string]string `yaml:"cluster"`
+			} `yaml:"clusters"`
+			Contexts []map[string]any `yaml:"contexts"`
+			Users    []struct {
+				Name string            `yaml:"name"`
+				User map[string]string `yaml:"user"`
+			} `yaml:"users"`
+		}
+		require.NoError(t, yaml.Unmarshal(out, &doc))
+		assert.Equal(t, "prod", doc.CurrentContext)
+		require.Len(t, doc.Clusters, 1)
+		assert.Equal(t, map[string]string{
+			"server":                     "https://127.0.0.1:40001",
+			"tls-server-name":            "api.prod.internal",
+			"certificate-authority-data": "Q0E=",
+		}, doc.Clusters[0].Cluster)
+		assert.Len(t, doc.Contexts, 1)
+		require.Len(t, doc.Users, 1)
+		assert.Equal(t, "prod-token", doc.Users[0].User["token"])
+	})
+
+	t.Run("named context", func(t *testing.T) {
+		cfg, err := parseKubeconfig([]byte(testKubeconfig), "dev")
+		require.NoError(t, err)
+		addr, err := cfg.serverAddr()
+		require.NoError(t, err)
+		assert.Equal(t, "10.9.0.1:443", addr)
+	})
+
+	t.Run("keeps the server path and resolves relative files", func(t *testing.T) {
+		src := `current-context: rancher
+clusters:
+- name: c
+  cluster:
+    server: https://rancher.example.com/k8s/clusters/c-m-abc
+    certificate-authority: certs/ca.pem
+contexts:
+- name: rancher
+  context:
+    cluster: c
+    user: u
+users:
+- name: u
+  user:
+    client-certificate: certs/admin.pem
+    client-key: /etc/kube/admin-key.pem
+`
+		cfg, err := parseKubeconfig([]byte(src), "")
+		require.NoError(t, err)
+		cfg.dir = "/home/ops/.kube"
+		addr, err := cfg.serverAddr()
+		require.NoError(t, err)
+		assert.Equal(t, "rancher.example.com:443", addr)
+
+		out, err := cfg.forwarded("127.0.0.1:40001")
+		require.NoError(t, err)
+		var doc struct {
+			Clusters []struct {
+				Cluster map[string]string `yaml:"cluster"`
+			} `yaml:"clusters"`
+			Users []struct {
+				Name string            `yaml:"name"`
+				User map[string]string `yaml:"user"`
+			} `yaml:"users"`
+		}
+		require.NoError(t, yaml.Unmarshal(out, &doc))
+		assert.Equal(t, map[string]string{
+			"server":                "https://127.0.0.1:40001/k8s/clusters/c-m-abc",
+			"tls-server-name":       "rancher.example.com",
+			"certificate-authority": filepath.Join("/home/ops/.kube", "certs/ca.pem"),
+		}, doc.Clusters[0].Cluster)
+		assert.Equal(t, "u", doc.Users[0].Name)
+		assert.Equal(t, map[string]string{
+			"client-certificate": filepath.Join("/home/ops/.kube", "certs/admin.pem"),
+			"client-key":         "/etc/kube/admin-key.pem",
+		}, doc.Users[0].User)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := parseKubeconfig([]byte(testKubeconfig), "staging")
+		assert.EqualError(t, err, "context staging not found")
+		_, err = parseKubeconfig([]byte("apiVersion: v1\n"), "")
+		assert.EqualError(t, err, "no current context")
+	})
+}
+
+func TestKubeHelper(t *testing.T) {
+	dir := t.TempDir()
+	kubeconfig := filepath.Join(dir, "config")
+	require.NoError(t, os.WriteFile(kubeconfig, []byte(testKubeconfig), 0o600))
+
+	m := inventory.NewManager(t.TempDir())
+	require.NoError(t, m.Load())
+	h := inventory.NewHost("bastion", "bastion", "203.0.113.10")
+	h.User = "ops"
+	h.Vars = map[string]string{VarKubeconfig: kubeconfig}
+	require.NoError(t, m.AddHost(h))
+
+	dialed := make(chan string, 1)
+	helper := &KubeHelper{
+		Manager: m,
+		Tunnels: &Tunnels{},
+		TempDir: dir,
+		Dial: func(ctx context.Context, hostID, addr string) (net.Conn, error) {
+			dialed <- hostID + " " + addr
+			local, remote := net.Pipe()
+			remote.Close()
+			return local, nil
+		},
+	}
+
+	k, err := helper.Open(context.Background(), "bastion", "")
+	require.NoError(t, err)
+	assert.Equal(t, "kube-bastion", k.Stats().Name)
+	assert.Equal(t, "prod", k.Context)
+	assert.Equal(t, "KUBECONFIG="+k.Kubeconfig, k.Env())
+	info, err := os.Stat(k.Kubeconfig)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	data, err := os.ReadFile(k.Kubeconfig)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "https://"+k.Stats().Listen)
+
+	c, err := net.Dial("tcp", k.Stats().Listen)
+	require.NoError(t, err)
+	c.Close()
+	assert.Equal(t, "bastion api.prod.internal:6443", <-dialed)
+
+	require.NoError(t, k.Close())
+	assert.NoFileExists(t, k.Kubeconfig)
+}
@@ -0,0 +1,102 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"gossher/internal/inventory"
+)
+
+// PaneOpener opens the interactive session of a pane and returns its input, e.g.
+// the stdin of an SSH shell.
+type PaneOpener func(ctx context.Context, pane inventory.SessionPane) (io.Writer, error)
+
+// OpenSet opens the panes of a session set in order and returns a Broadcast with
+// a pane for each, named by PaneTitle and muted as configured. Pane commands are
+// typed into their sessions, and entered for panes with Run. A pane that fails to
+// open does not stop the others; the failures are returned together with the
+// Broadcast of the panes that opened.
+func OpenSet(ctx context.Context, set *inventory.SessionSet, open PaneOpener) (*Broadcast, error) {
+	b := &Broadcast{}
+	var errs []error
+	for _, p := range set.Panes {
+		w, err := open(ctx, p)
+		if err == nil {
+			err = b.Add(p.PaneTitle(), w)
+		}
+		if err == nil && p.Muted {
+			err = b.SetMuted(p.PaneTitle(), true)
+		}
+		if err == nil && p.Command != "" {
+			err = b.SendTo(p.PaneTitle(), []byte(typed(p)))
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pane %s: %w", p.PaneTitle(), err))
+		}
+	}
+	return b, errors.Join(errs...)
+}
+
+// typed returns the keystrokes of a pane's command.
+func typed(p inventory.SessionPane) string {
+	if p.Run {
+		return p.Command + "\r"
+	}
+	return p.Command
+}
+
+// TmuxCommands returns the tmux commands that open a session set as the tmux
+// session name: one pane per session, started with the shell command returned by
+// shell for its host (usually Manager.ConnectionString with FormatSSHCommand),
+// titled, with its command typed and arranged with the set's layout. When the set
+// broadcasts, the unmuted panes synchronize their input.
+func TmuxCommands(set *inventory.SessionSet, name string, shell func(hostID string) (string, error)) ([][]string, error) {
+	var cmds [][]string
+	for i, p := range set.Panes {
+		sh, err := shell(p.HostID)
+		if err != nil {
+			return nil, fmt.Errorf("pane %s: %w", p.PaneTitle(), err)
+		}
+
+		// Each new pane is the active one, so the commands after it target the
+		// session; retiling after each split keeps room for the next one.
+		if i == 0 {
+			cmds = append(cmds, []string{"tmux", "new-session", "-d", "-s", name, "-n", set.ID, sh})
+		} else {
+			cmds = append(cmds,
+				[]string{"tmux", "split-window", "-t", name, sh},
+				[]string{"tmux", "select-layout", "-t", name, string(inventory.LayoutTiled)})
+		}
+		cmds = append(cmds, []string{"tmux", "select-pane", "-t", name, "-T", p.PaneTitle()})
+		if p.Command != "" {
+			cmds = append(cmds, []string{"tmux", "send-keys", "-t", name, "-l", "--", typed(p)})
+		}
+		if set.Broadcast && !p.Muted {
+			cmds = append(cmds, []string{"tmux", "set-option", "-p", "-t", name, "synchronize-panes", "on"})
+		}
+	}
+	cmds = append(cmds, []string{"tmux", "select-layout", "-t", name, string(set.PaneLayout())})
+	return cmds, nil
+}
+
+// OpenTmux runs TmuxCommands with run (default exec.Command(name, args...).Run).
+func OpenTmux(set *inventory.SessionSet, name string, shell func(hostID string) (string, error), run func(name string, args ...string) error) error {
+	cmds, err := TmuxCommands(set, name, shell)
+	if err != nil {
+		return err
+	}
+	if run == nil {
+		run = func(name string, args ...string) error {
+			return exec.Command(name, args...).Run()
+		}
+	}
+	for _, c := range cmds {
+		if err := run(c[0], c[1:]...); err != nil {
+			return fmt.Errorf("failed to open session set %s in tmux: %w", set.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func incidentSet() *inventory.SessionSet {
+	s := inventory.NewSessionSet("incident-response", "Incident response")
+	s.Layout = inventory.LayoutMainVertical
+	s.Broadcast = true
+	s.AddPane(inventory.SessionPane{HostID: "lb01"})
+	s.AddPane(inventory.SessionPane{HostID: "app01", Command: "journalctl -f"})
+	s.AddPane(inventory.SessionPane{HostID: "db01", Title: "db", Command: "psql", Run: true, Muted: true})
+	return s
+}
+
+func TestOpenSet(t *testing.T) {
+	inputs := map[string]*bytes.Buffer{}
+	open := func(ctx context.Context, p inventory.SessionPane) (io.Writer, error) {
+		if p.HostID == "lb01" {
+			return nil, errors.New("connection refused")
+		}
+		inputs[p.HostID] = &bytes.Buffer{}
+		return inputs[p.HostID], nil
+	}
+
+	b, err := OpenSet(context.Background(), incidentSet(), open)
+	assert.EqualError(t, err, "pane lb01: connection refused")
+	assert.Equal(t, []PaneState{{Name: "app01"}, {Name: "db", Muted: true}}, b.Panes())
+	assert.Equal(t, "journalctl -f", inputs["app01"].String())
+	assert.Equal(t, "psql\r", inputs["db01"].String())
+
+	_, err = b.Write([]byte("\r"))
+	require.NoError(t, err)
+	assert.Equal(t, "journalctl -f\r", inputs["app01"].String())
+	assert.Equal(t, "psql\r", inputs["db01"].String())
+}
+
+func TestTmuxCommands(t *testing.T) {
+	shell := func(hostID string) (string, error) { return "ssh " + hostID, nil }
+
+	cmds, err := TmuxCommands(incidentSet(), "ir", shell)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"tmux", "new-session", "-d", "-s", "ir", "-n", "incident-response", "ssh lb01"},
+		{"tmux", "select-pane", "-t", "ir", "-T", "lb01"},
+		{"tmux", "set-option", "-p", "-t", "ir", "synchronize-panes", "on"},
+		{"tmux", "split-window", "-t", "ir", "ssh app01"},
+		{"tmux", "select-layout", "-t", "ir", "tiled"},
+		{"tmux", "select-pane", "-t", "ir", "-T", "app01"},
+		{"tmux", "send-keys", "-t", "ir", "-l", "--", "journalctl -f"},
+		{"tmux", "set-option", "-p", "-t", "ir", "synchronize-panes", "on"},
+		{"tmux", "split-window", "-t", "ir", "ssh db01"},
+		{"tmux", "select-layout", "-t", "ir", "tiled"},
+		{"tmux", "select-pane", "-t", "ir", "-T", "db"},
+		{"tmux", "send-keys", "-t", "ir", "-l", "--", "psql\r"},
+		{"tmux", "select-layout", "-t", "ir", "main-vertical"},
+	}, cmds)
+
+	var ran int
+	err = OpenTmux(incidentSet(), "ir", shell, func(name string, args ...string) error {
+		ran++
+		if args[0] == "split-window" {
+			return errors.New("no space for new pane")
+		}
+		return nil
+	})
+	assert.ErrorContains(t, err, "no space for new pane")
+	assert.Equal(t, 4, ran)
+
+	_, err = TmuxCommands(incidentSet(), "ir", func(string) (string, error) { return "", errors.New("host lb01 not found") })
+	assert.ErrorContains(t, err, "pane lb01: host lb01 not found")
+}
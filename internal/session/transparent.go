@@ -0,0 +1,388 @@
+package session
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTransparentDisabled is returned by Transparent.Start unless transparent
+	// tunnels are allowed in the config.
+	ErrTransparentDisabled = errors.New("transparent tunnels are disabled; set transparent_proxy in the config to allow them")
+	// ErrNotPrivileged is returned by Transparent.Start when not running as root,
+	// which changing the firewall rules needs.
+	ErrNotPrivileged = errors.New("transparent tunnels need root privileges to change the firewall rules")
+)
+
+// transparentScope is appended to errors of Transparent.Start so that users
+// looking for a full VPN learn what the tunnel would carry.
+const transparentScope = "only TCP and DNS are redirected, other UDP and ICMP are not proxied"
+
+// maxChainName is the longest iptables chain name.
+const maxChainName = 28
+
+// TransparentOptions describe a transparent tunnel opened with Transparent.Start.
+type TransparentOptions struct {
+	Name   string
+	HostID string
+	// Subnets are the CIDRs routed through the host, e.g. "10.20.0.0/16". Only
+	// TCP connections to them are redirected; see Transparent.
+	Subnets []string
+	// Exclude are CIDRs inside Subnets that stay local. The addresses of the host
+	// and its jump hosts must be excluded when Subnets contain them, or the SSH
+	// connection itself would be redirected.
+	Exclude []string
+	// DNS is the address of a name server as seen from the host, e.g.
+	// "10.20.0.2:53". When set, local DNS queries over UDP are sent to it through
+	// the tunnel.
+	DNS string
+	// Listen is the local address of the redirect listener (default 127.0.0.1:0).
+	Listen string
+	// IdleTimeout is passed on to TunnelOptions.
+	IdleTimeout time.Duration
+}
+
+// Transparent opens transparent tunnels: like sshuttle, it redirects the TCP
+// connections to the subnets with the local firewall (iptables NAT rules) to a
+// listener that forwards them through an SSH connection to the host. Nothing has
+// to be installed on the host, as the connections are carried as ordinary
+// port forwards. UDP is only forwarded for DNS, whose queries are sent over TCP,
+// since SSH cannot carry datagrams.
+//
+// There is no tun device: other UDP traffic and ICMP (e.g. ping) to the subnets
+// are not redirected and leave through the local routes as before, so they are
+// not proxied.
+//
+// Transparent tunnels are gated: they must be enabled in the config and need root
+// privileges, and only work on Linux.
+type Transparent struct {
+	// Tunnels registers the tunnels for their statistics and idle timeout.
+	Tunnels *Tunnels
+	// Enabled allows transparent tunnels; see inventory.GetTransparentProxy.
+	Enabled bool
+	// Dial connects to the original destinations from the host.
+	Dial DialFunc
+
+	// Run runs a firewall command (default exec.Command(name, args...).Run).
+	Run func(name string, args ...string) error
+	// Geteuid returns the effective user ID (default os.Geteuid).
+	Geteuid func() int
+	// OriginalDst returns the destination a redirected connection was sent to
+	// (default: read from the connection tracking of the kernel).
+	OriginalDst func(net.Conn) (string, error)
+}
+
+// Start checks the gates, installs the firewall rules and opens the tunnel.
+// Closing the tunnel, also for inactivity, removes the rules again. The tunnel
+// stops when ctx is cancelled.
+func (tp *Transparent) Start(ctx context.Context, opts TransparentOptions) (*Tunnel, error) {
+	if !tp.Enabled {
+		return nil, ErrTransparentDisabled
+	}
+	if !transparentSupported {
+		return nil, fmt.Errorf("transparent tunnels are only supported on Linux (%s)", transparentScope)
+	}
+	geteuid := tp.Geteuid
+	if geteuid == nil {
+		geteuid = os.Geteuid
+	}
+	if geteuid() != 0 {
+		return nil, ErrNotPrivileged
+	}
+	if tp.Dial == nil {
+		return nil, fmt.Errorf("transparent tunnel %s: no dialer", opts.Name)
+	}
+	if opts.Name == "" {
+		return nil, fmt.Errorf("tunnel name cannot be empty")
+	}
+
+	subnets, err := parsePrefixes(opts.Subnets)
+	if err != nil {
+		return nil, fmt.Errorf("transparent tunnel %s: %w", opts.Name, err)
+	}
+	if len(subnets) == 0 {
+		return nil, fmt.Errorf("transparent tunnel %s: no subnets", opts.Name)
+	}
+	exclude, err := parsePrefixes(opts.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("transparent tunnel %s: %w", opts.Name, err)
+	}
+	if opts.DNS != "" {
+		if _, _, err := net.SplitHostPort(opts.DNS); err != nil {
+			return nil, fmt.Errorf("transparent tunnel %s: invalid DNS server %q: %w", opts.Name, opts.DNS, err)
+		}
+	}
+	chain := "gossher-" + opts.Name
+	if len(chain) > maxChainName {
+		return nil, fmt.Errorf("transparent tunnel %s: name is longer than %d characters", opts.Name, maxChainName-len("gossher-"))
+	}
+
+	listen := opts.Listen
+	if listen == "" {
+		listen = "127.0.0.1:0"
+	}
+	p := &transparentProxy{tp: tp, hostID: opts.HostID, dnsServer: opts.DNS, run: tp.Run}
+	if p.run == nil {
+		p.run = func(name string, args ...string) error {
+			return exec.Command(name, args...).Run()
+		}
+	}
+	if p.listener, err = net.Listen("tcp", listen); err != nil {
+		return nil, fmt.Errorf("transparent tunnel %s: failed to listen: %w", opts.Name, err)
+	}
+	dnsPort := 0
+	if opts.DNS != "" {
+		host, _, _ := net.SplitHostPort(p.listener.Addr().String())
+		if p.dns, err = net.ListenPacket("udp", net.JoinHostPort(host, "0")); err != nil {
+			p.listener.Close()
+			return nil, fmt.Errorf("transparent tunnel %s: failed to listen for DNS: %w", opts.Name, err)
+		}
+		dnsPort = p.dns.LocalAddr().(*net.UDPAddr).Port
+	}
+
+	port := p.listener.Addr().(*net.TCPAddr).Port
+	setup, teardown := transparentRules(chain, port, dnsPort, subnets, exclude)
+	p.teardown = teardown
+	for _, cmd := range setup {
+		if err := p.run(cmd[0], cmd[1:]...); err != nil {
+			p.close()
+			return nil, fmt.Errorf("transparent tunnel %s: failed to run %s (%s): %w", opts.Name, strings.Join(cmd, " "), transparentScope, err)
+		}
+	}
+
+	p.tunnel, err = tp.tunnels().Open(TunnelOptions{
+		Name:        opts.Name,
+		Kind:        TunnelTransparent,
+		HostID:      opts.HostID,
+		Listen:      p.listener.Addr().String(),
+		Target:      strings.Join(opts.Subnets, ","),
+		IdleTimeout: opts.IdleTimeout,
+	}, p)
+	if err != nil {
+		p.close()
+		return nil, err
+	}
+
+	ctx, p.cancel = context.WithCancel(ctx)
+	go p.accept(ctx)
+	if p.dns != nil {
+		go p.serveDNS(ctx)
+	}
+	go func() {
+		<-ctx.Done()
+		p.tunnel.Close()
+	}()
+	return p.tunnel, nil
+}
+
+func (tp *Transparent) tunnels() *Tunnels {
+	if tp.Tunnels == nil {
+		tp.Tunnels = &Tunnels{}
+	}
+	return tp.Tunnels
+}
+
+func (tp *Transparent) originalDst(c net.Conn) (string, error) {
+	if tp.OriginalDst != nil {
+		return tp.OriginalDst(c)
+	}
+	return originalDst(c)
+}
+
+// parsePrefixes parses CIDRs, masking host bits.
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid subnet %q: %w", cidr, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// transparentRules returns the commands installing and removing the NAT rules of a
+// transparent tunnel: TCP connections to the subnets, except the excluded ones,
+// are redirected to port, and DNS queries to dnsPort unless it is zero. IPv6
+// subnets are handled by ip6tables.
+func transparentRules(chain string, port, dnsPort int, subnets, exclude []netip.Prefix) (setup, teardown [][]string) {
+	for _, tool := range []string{"iptables", "ip6tables"} {
+		is6 := tool == "ip6tables"
+		if !hasFamily(subnets, is6) && (is6 || dnsPort == 0) {
+			continue
+		}
+		var rules [][]string
+		for _, prefix := range exclude {
+			if prefix.Addr().Is6() == is6 {
+				rules = append(rules, []string{"-d", prefix.String(), "-j", "RETURN"})
+			}
+		}
+		for _, prefix := range subnets {
+			if prefix.Addr().Is6() == is6 {
+				rules = append(rules, []string{"-p", "tcp", "-d", prefix.String(), "-j", "REDIRECT", "--to-ports", strconv.Itoa(port)})
+			}
+		}
+		if dnsPort != 0 && !is6 {
+			rules = append(rules, []string{"-p", "udp", "--dport", "53", "-j", "REDIRECT", "--to-ports", strconv.Itoa(dnsPort)})
+		}
+
+		setup = append(setup, []string{tool, "-t", "nat", "-N", chain})
+		for _, rule := range rules {
+			setup = append(setup, append([]string{tool, "-t", "nat", "-A", chain}, rule...))
+		}
+		setup = append(setup, []string{tool, "-t", "nat", "-I", "OUTPUT", "1", "-j", chain})
+
+		teardown = append(teardown,
+			[]string{tool, "-t", "nat", "-D", "OUTPUT", "-j", chain},
+			[]string{tool, "-t", "nat", "-F", chain},
+			[]string{tool, "-t", "nat", "-X", chain})
+	}
+	return setup, teardown
+}
+
+func hasFamily(prefixes []netip.Prefix, is6 bool) bool {
+	for _, prefix := range prefixes {
+		if prefix.Addr().Is6() == is6 {
+			return true
+		}
+	}
+	return false
+}
+
+// ===== Proxy =====
+
+// transparentProxy forwards the redirected connections of a transparent tunnel.
+type transparentProxy struct {
+	tp        *Transparent
+	hostID    string
+	dnsServer string
+	run       func(name string, args ...string) error
+	listener  net.Listener
+	dns       net.PacketConn
+	teardown  [][]string
+	tunnel    *Tunnel
+	cancel    context.CancelFunc
+
+	once sync.Once
+	err  error
+}
+
+// Close stops the listeners and removes the firewall rules.
+func (p *transparentProxy) Close() error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	return p.close()
+}
+
+func (p *transparentProxy) close() error {
+	p.once.Do(func() {
+		p.listener.Close()
+		if p.dns != nil {
+			p.dns.Close()
+		}
+		// Remove every rule even if one fails, e.g. because setup stopped early.
+		var errs []error
+		for _, cmd := range p.teardown {
+			if err := p.run(cmd[0], cmd[1:]...); err != nil {
+				errs = append(errs, fmt.Errorf("failed to run %s: %w", strings.Join(cmd, " "), err))
+			}
+		}
+		p.err = errors.Join(errs...)
+	})
+	return p.err
+}
+
+func (p *transparentProxy) accept(ctx context.Context) {
+	for {
+		c, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		go p.forward(ctx, c)
+	}
+}
+
+// forward connects a redirected connection to its original destination through
+// the host.
+func (p *transparentProxy) forward(ctx context.Context, c net.Conn) {
+	local := p.tunnel.Wrap(c, false)
+	defer local.Close()
+
+	dst, err := p.tp.originalDst(c)
+	if err != nil {
+		return
+	}
+	remote, err := p.tp.Dial(ctx, p.hostID, dst)
+	if err != nil {
+		return
+	}
+	defer remote.Close()
+
+	splice(ctx, local, c, remote)
+}
+
+// serveDNS answers the redirected DNS queries by sending them to the DNS server
+// over TCP through the host.
+func (p *transparentProxy) serveDNS(ctx context.Context) {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := p.dns.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		query := append([]byte(nil), buf[:n]...)
+		go func() {
+			answer, err := p.queryDNS(ctx, query)
+			if err != nil {
+				return
+			}
+			p.dns.WriteTo(answer, addr)
+		}()
+	}
+}
+
+// dnsTimeout bounds a DNS query through the tunnel.
+const dnsTimeout = 10 * time.Second
+
+// queryDNS sends one DNS message over TCP, which prefixes it with its length.
+func (p *transparentProxy) queryDNS(ctx context.Context, query []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout)
+	defer cancel()
+
+	c, err := p.tp.Dial(ctx, p.hostID, p.dnsServer)
+	if err != nil {
+		return nil, err
+	}
+	conn := p.tunnel.Wrap(c, true)
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	msg := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	if _, err := conn.Write(append(msg, query...)); err != nil {
+		return nil, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	answer := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
@@ -0,0 +1,59 @@
+package session
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+const transparentSupported = true
+
+// soOriginalDst is SO_ORIGINAL_DST of netfilter, the same for IPv4 and IPv6.
+const soOriginalDst = 80
+
+// originalDst reads the destination of a connection redirected by a NAT rule
+// from the connection tracking of the kernel.
+func originalDst(c net.Conn) (string, error) {
+	tc, ok := c.(*net.TCPConn)
+	if !ok {
+		return "", fmt.Errorf("original destination: not a TCP connection")
+	}
+	raw, err := tc.SyscallConn()
+	if err != nil {
+		return "", fmt.Errorf("original destination: %w", err)
+	}
+
+	is6 := tc.LocalAddr().(*net.TCPAddr).IP.To4() == nil
+	var addr netip.AddrPort
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		if is6 {
+			// sockaddr_in6 fits the IPv6MTUInfo layout.
+			info, err := syscall.GetsockoptIPv6MTUInfo(int(fd), syscall.IPPROTO_IPV6, soOriginalDst)
+			if err != nil {
+				sockErr = err
+				return
+			}
+			port := binary.BigEndian.Uint16(binary.NativeEndian.AppendUint16(nil, info.Addr.Port))
+			addr = netip.AddrPortFrom(netip.AddrFrom16(info.Addr.Addr), port)
+			return
+		}
+		// sockaddr_in fits the IPv6Mreq layout: family, port, address.
+		mreq, err := syscall.GetsockoptIPv6Mreq(int(fd), syscall.IPPROTO_IP, soOriginalDst)
+		if err != nil {
+			sockErr = err
+			return
+		}
+		port := binary.BigEndian.Uint16(mreq.Multiaddr[2:4])
+		addr = netip.AddrPortFrom(netip.AddrFrom4([4]byte(mreq.Multiaddr[4:8])), port)
+	})
+	if err == nil {
+		err = sockErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read original destination: %w", err)
+	}
+	return addr.String(), nil
+}
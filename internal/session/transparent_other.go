@@ -0,0 +1,14 @@
+//go:build !linux
+
+package session
+
+import (
+	"errors"
+	"net"
+)
+
+const transparentSupported = false
+
+func originalDst(net.Conn) (string, error) {
+	return "", errors.ErrUnsupported
+}
@@ -0,0 +1,233 @@
+package session
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransparentRules(t *testing.T) {
+	prefixes := func(cidrs ...string) []netip.Prefix {
+		p, err := parsePrefixes(cidrs)
+		require.NoError(t, err)
+		return p
+	}
+
+	t.Run("redirects subnets and skips exclusions", func(t *testing.T) {
+		setup, teardown := transparentRules("gossher-lab", 12300, 0,
+			prefixes("10.20.0.0/16", "fd00::/8"), prefixes("10.20.0.5/32"))
+		lines := joinCommands(setup)
+		assert.Equal(t, []string{
+			"iptables -t nat -N gossher-lab",
+			"iptables -t nat -A gossher-lab -d 10.20.0.5/32 -j RETURN",
+			"iptables -t nat -A gossher-lab -p tcp -d 10.20.0.0/16 -j REDIRECT --to-ports 12300",
+			"iptables -t nat -I OUTPUT 1 -j gossher-lab",
+			"ip6tables -t nat -N gossher-lab",
+			"ip6tables -t nat -A gossher-lab -p tcp -d fd00::/8 -j REDIRECT --to-ports 12300",
+			"ip6tables -t nat -I OUTPUT 1 -j gossher-lab",
+		}, lines)
+		assert.Equal(t, []string{
+			"iptables -t nat -D OUTPUT -j gossher-lab",
+			"iptables -t nat -F gossher-lab",
+			"iptables -t nat -X gossher-lab",
+			"ip6tables -t nat -D OUTPUT -j gossher-lab",
+			"ip6tables -t nat -F gossher-lab",
+			"ip6tables -t nat -X gossher-lab",
+		}, joinCommands(teardown))
+	})
+
+	t.Run("redirects DNS", func(t *testing.T) {
+		setup, _ := transparentRules("gossher-lab", 12300, 12301, prefixes("10.20.1.7/16"), nil)
+		assert.Equal(t, []string{
+			"iptables -t nat -N gossher-lab",
+			"iptables -t nat -A gossher-lab -p tcp -d 10.20.0.0/16 -j REDIRECT --to-ports 12300",
+			"iptables -t nat -A gossher-lab -p udp --dport 53 -j REDIRECT --to-ports 12301",
+			"iptables -t nat -I OUTPUT 1 -j gossher-lab",
+		}, joinCommands(setup))
+	})
+}
+
+func joinCommands(cmds [][]string) []string {
+	lines := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		lines[i] = strings.Join(cmd, " ")
+	}
+	return lines
+}
+
+// fakeFirewall records the firewall commands of a Transparent.
+type fakeFirewall struct {
+	mu   sync.Mutex
+	cmds []string
+	fail string
+}
+
+func (f *fakeFirewall) run(name string, args ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cmd := name + " " + strings.Join(args, " ")
+	f.cmds = append(f.cmds, cmd)
+	if f.fail != "" && strings.Contains(cmd, f.fail) {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func (f *fakeFirewall) commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.cmds...)
+}
+
+func TestTransparent(t *testing.T) {
+	opts := TransparentOptions{Name: "lab", HostID: "bastion", Subnets: []string{"10.20.0.0/16"}}
+
+	newTransparent := func(fw *fakeFirewall, dial func(ctx context.Context, hostID, addr string) (net.Conn, error)) *Transparent {
+		return &Transparent{
+			Tunnels:     &Tunnels{},
+			Enabled:     true,
+			Dial:        dial,
+			Run:         fw.run,
+			Geteuid:     func() int { return 0 },
+			OriginalDst: func(net.Conn) (string, error) { return "10.20.0.9:80", nil },
+		}
+	}
+	noDial := func(context.Context, string, string) (net.Conn, error) { return nil, io.EOF }
+
+	t.Run("is gated", func(t *testing.T) {
+		if !transparentSupported {
+			t.Skip("transparent tunnels need Linux")
+		}
+		fw := &fakeFirewall{}
+		tp := newTransparent(fw, noDial)
+		tp.Enabled = false
+		_, err := tp.Start(context.Background(), opts)
+		assert.ErrorIs(t, err, ErrTransparentDisabled)
+
+		tp.Enabled = true
+		tp.Geteuid = func() int { return 1000 }
+		_, err = tp.Start(context.Background(), opts)
+		assert.ErrorIs(t, err, ErrNotPrivileged)
+		assert.Empty(t, fw.commands())
+	})
+
+	t.Run("rejects invalid options", func(t *testing.T) {
+		if !transparentSupported {
+			t.Skip("transparent tunnels need Linux")
+		}
+		tp := newTransparent(&fakeFirewall{}, noDial)
+		_, err := tp.Start(context.Background(), TransparentOptions{Name: "lab"})
+		assert.ErrorContains(t, err, "no subnets")
+		_, err = tp.Start(context.Background(), TransparentOptions{Name: "lab", Subnets: []string{"10.20.0.0"}})
+		assert.ErrorContains(t, err, "invalid subnet")
+		_, err = tp.Start(context.Background(), TransparentOptions{Name: strings.Repeat("x", 21), Subnets: []string{"10.0.0.0/8"}})
+		assert.ErrorContains(t, err, "longer than 20 characters")
+	})
+
+	t.Run("removes the rules when setup fails", func(t *testing.T) {
+		if !transparentSupported {
+			t.Skip("transparent tunnels need Linux")
+		}
+		fw := &fakeFirewall{fail: "-I OUTPUT"}
+		tp := newTransparent(fw, noDial)
+		_, err := tp.Start(context.Background(), opts)
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "other UDP and ICMP are not proxied", "the error tells what the tunnel carries")
+		assert.Contains(t, fw.commands(), "iptables -t nat -X gossher-lab")
+		assert.Empty(t, tp.Tunnels.Stats())
+	})
+
+	t.Run("forwards redirected connections", func(t *testing.T) {
+		if !transparentSupported {
+			t.Skip("transparent tunnels need Linux")
+		}
+		fw := &fakeFirewall{}
+		dialed := make(chan string, 1)
+		tp := newTransparent(fw, func(ctx context.Context, hostID, addr string) (net.Conn, error) {
+			dialed <- hostID + " " + addr
+			local, remote := net.Pipe()
+			go func() {
+				defer remote.Close()
+				buf := make([]byte, 4)
+				io.ReadFull(remote, buf)
+				remote.Write([]byte("pong"))
+			}()
+			return local, nil
+		})
+		tun, err := tp.Start(context.Background(), opts)
+		require.NoError(t, err)
+		assert.Equal(t, TunnelTransparent, tun.Stats().Kind)
+		assert.Equal(t, "10.20.0.0/16", tun.Stats().Target)
+
+		c, err := net.Dial("tcp", tun.Stats().Listen)
+		require.NoError(t, err)
+		_, err = c.Write([]byte("ping"))
+		require.NoError(t, err)
+		answer, err := io.ReadAll(c)
+		require.NoError(t, err)
+		c.Close()
+		assert.Equal(t, "pong", string(answer))
+		assert.Equal(t, "bastion 10.20.0.9:80", <-dialed)
+		assert.Eventually(t, func() bool { return tun.Stats().Active == 0 }, time.Second, 10*time.Millisecond)
+		stats := tun.Stats()
+		assert.Equal(t, int64(4), stats.BytesIn)
+		assert.Equal(t, int64(4), stats.BytesOut)
+
+		require.NoError(t, tun.Close())
+		assert.Contains(t, fw.commands(), "iptables -t nat -D OUTPUT -j gossher-lab")
+		_, err = net.Dial("tcp", stats.Listen)
+		assert.Error(t, err)
+		assert.Empty(t, tp.Tunnels.Stats())
+	})
+
+	t.Run("forwards DNS queries over TCP", func(t *testing.T) {
+		if !transparentSupported {
+			t.Skip("transparent tunnels need Linux")
+		}
+		dialed := make(chan string, 1)
+		tp := newTransparent(&fakeFirewall{}, func(ctx context.Context, hostID, addr string) (net.Conn, error) {
+			dialed <- addr
+			local, remote := net.Pipe()
+			go func() {
+				defer remote.Close()
+				var length [2]byte
+				io.ReadFull(remote, length[:])
+				query := make([]byte, binary.BigEndian.Uint16(length[:]))
+				io.ReadFull(remote, query)
+				answer := append([]byte("answer to "), query...)
+				remote.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(answer))), answer...))
+			}()
+			return local, nil
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		withDNS := opts
+		withDNS.DNS = "10.20.0.2:53"
+		tun, err := tp.Start(ctx, withDNS)
+		require.NoError(t, err)
+
+		p := tun.closer.(*transparentProxy)
+		c, err := net.Dial("udp", p.dns.LocalAddr().String())
+		require.NoError(t, err)
+		defer c.Close()
+		_, err = c.Write([]byte("example.lab"))
+		require.NoError(t, err)
+		c.SetReadDeadline(time.Now().Add(5 * time.Second))
+		buf := make([]byte, 512)
+		n, err := c.Read(buf)
+		require.NoError(t, err)
+		assert.Equal(t, "answer to example.lab", string(buf[:n]))
+		assert.Equal(t, "10.20.0.2:53", <-dialed)
+
+		cancel()
+		assert.Eventually(t, func() bool { return len(tp.Tunnels.Stats()) == 0 }, time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,428 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TunnelKind is the kind of a tunnel.
+type TunnelKind string
+
+const (
+	// TunnelLocal forwards a local port to a remote address (ssh -L).
+	TunnelLocal TunnelKind = "local"
+	// TunnelRemote forwards a remote port to a local address (ssh -R).
+	TunnelRemote TunnelKind = "remote"
+	// TunnelSOCKS is a dynamic SOCKS proxy (ssh -D).
+	TunnelSOCKS TunnelKind = "socks"
+	// TunnelTransparent routes whole subnets through a host; see Transparent.
+	TunnelTransparent TunnelKind = "transparent"
+)
+
+// TunnelStats are the counters of a tunnel.
+type TunnelStats struct {
+	Name   string     `json:"name"`
+	Kind   TunnelKind `json:"kind"`
+	HostID string     `json:"host_id"`
+	// Listen is the address the tunnel accepts connections on, Target where it
+	// forwards them (empty for SOCKS proxies).
+	Listen string `json:"listen"`
+	Target string `json:"target,omitempty"`
+
+	Opened     time.Time `json:"opened"`
+	LastActive time.Time `json:"last_active"`
+	// Idle is the time since the last traffic; IdleTimeout closes the tunnel once
+	// Idle reaches it (zero keeps it open).
+	Idle        time.Duration `json:"idle"`
+	IdleTimeout time.Duration `json:"idle_timeout,omitempty"`
+
+	// BytesIn were received from the remote side, BytesOut sent to it.
+	BytesIn  int64 `json:"bytes_in"`
+	BytesOut int64 `json:"bytes_out"`
+	// Connections counts the connections carried so far, Active those still open.
+	Connections int64 `json:"connections"`
+	Active      int64 `json:"active"`
+}
+
+// TunnelOptions describe a tunnel opened with Tunnels.Open.
+type TunnelOptions struct {
+	Name   string
+	Kind   TunnelKind
+	HostID string
+	Listen string
+	Target string
+	// IdleTimeout overrides the default of Tunnels for this tunnel; negative keeps
+	// the tunnel open however long it is idle.
+	IdleTimeout time.Duration
+}
+
+// Tunnels tracks the port forwards and SOCKS proxies of the application: their
+// byte counters and idle time. Tunnels without traffic for their idle timeout are
+// closed by Check, so forgotten tunnels do not stay open and keep costing.
+type Tunnels struct {
+	// IdleTimeout is the default inactivity timeout; see
+	// inventory.GetTunnelIdleTimeout. Zero keeps tunnels open.
+	IdleTimeout time.Duration
+	// OnClose is called after a tunnel was closed for inactivity.
+	OnClose func(stats TunnelStats)
+	// Now returns the current time (default time.Now).
+	Now func() time.Time
+
+	mu      sync.Mutex
+	tunnels map[string]*Tunnel
+}
+
+// Tunnel is an open tunnel. Wrap the connections it carries to count them.
+type Tunnel struct {
+	tunnels *Tunnels
+	opts    TunnelOptions
+	closer  io.Closer
+	opened  time.Time
+
+	// last is the time of the last traffic in Unix nanoseconds.
+	last        atomic.Int64
+	bytesIn     atomic.Int64
+	bytesOut    atomic.Int64
+	connections atomic.Int64
+	active      atomic.Int64
+}
+
+func (ts *Tunnels) now() time.Time {
+	if ts.Now != nil {
+		return ts.Now()
+	}
+	return time.Now()
+}
+
+// Open registers a tunnel; closer stops it, e.g. its listener. Names are unique
+// among open tunnels.
+func (ts *Tunnels) Open(opts TunnelOptions, closer io.Closer) (*Tunnel, error) {
+	if opts.Name == "" {
+		return nil, fmt.Errorf("tunnel name cannot be empty")
+	}
+	switch opts.Kind {
+	case TunnelLocal, TunnelRemote, TunnelSOCKS, TunnelTransparent:
+	default:
+		return nil, fmt.Errorf("tunnel %s: invalid kind: %s", opts.Name, opts.Kind)
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if _, exists := ts.tunnels[opts.Name]; exists {
+		return nil, fmt.Errorf("tunnel %s already exists", opts.Name)
+	}
+	if ts.tunnels == nil {
+		ts.tunnels = make(map[string]*Tunnel)
+	}
+	t := &Tunnel{tunnels: ts, opts: opts, closer: closer, opened: ts.now()}
+	t.last.Store(t.opened.UnixNano())
+	ts.tunnels[opts.Name] = t
+	return t, nil
+}
+
+// Close closes a tunnel by name.
+func (ts *Tunnels) Close(name string) error {
+	ts.mu.Lock()
+	t, ok := ts.tunnels[name]
+	ts.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("tunnel %s not found", name)
+	}
+	return t.Close()
+}
+
+// Stats returns the statistics of the open tunnels sorted by name.
+func (ts *Tunnels) Stats() []TunnelStats {
+	ts.mu.Lock()
+	list := make([]*Tunnel, 0, len(ts.tunnels))
+	for _, t := range ts.tunnels {
+		list = append(list, t)
+	}
+	ts.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].opts.Name < list[j].opts.Name })
+	stats := make([]TunnelStats, len(list))
+	for i, t := range list {
+		stats[i] = t.Stats()
+	}
+	return stats
+}
+
+// Check closes the tunnels that were idle for their timeout and returns their
+// names. A tunnel is idle while no bytes flow, even with connections open.
+func (ts *Tunnels) Check() []string {
+	ts.mu.Lock()
+	var expired []*Tunnel
+	for _, t := range ts.tunnels {
+		if timeout := t.idleTimeout(); timeout > 0 && t.idle() >= timeout {
+			expired = append(expired, t)
+		}
+	}
+	ts.mu.Unlock()
+
+	sort.Slice(expired, func(i, j int) bool { return expired[i].opts.Name < expired[j].opts.Name })
+	closed := make([]string, 0, len(expired))
+	for _, t := range expired {
+		stats := t.Stats()
+		t.Close()
+		closed = append(closed, t.opts.Name)
+		if ts.OnClose != nil {
+			ts.OnClose(stats)
+		}
+	}
+	return closed
+}
+
+// Run calls Check every interval until ctx is cancelled.
+func (ts *Tunnels) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ts.Check()
+		}
+	}
+}
+
+// Close stops the tunnel and unregisters it; closing it again is a no-op.
+func (t *Tunnel) Close() error {
+	t.tunnels.mu.Lock()
+	if t.tunnels.tunnels[t.opts.Name] != t {
+		t.tunnels.mu.Unlock()
+		return nil
+	}
+	delete(t.tunnels.tunnels, t.opts.Name)
+	t.tunnels.mu.Unlock()
+
+	if t.closer == nil {
+		return nil
+	}
+	return t.closer.Close()
+}
+
+// Stats returns the current statistics of the tunnel.
+func (t *Tunnel) Stats() TunnelStats {
+	return TunnelStats{
+		Name:        t.opts.Name,
+		Kind:        t.opts.Kind,
+		HostID:      t.opts.HostID,
+		Listen:      t.opts.Listen,
+		Target:      t.opts.Target,
+		Opened:      t.opened,
+		LastActive:  time.Unix(0, t.last.Load()),
+		Idle:        t.idle(),
+		IdleTimeout: max(t.idleTimeout(), 0),
+		BytesIn:     t.bytesIn.Load(),
+		BytesOut:    t.bytesOut.Load(),
+		Connections: t.connections.Load(),
+		Active:      t.active.Load(),
+	}
+}
+
+func (t *Tunnel) idle() time.Duration {
+	return t.tunnels.now().Sub(time.Unix(0, t.last.Load()))
+}
+
+func (t *Tunnel) idleTimeout() time.Duration {
+	if t.opts.IdleTimeout != 0 {
+		return t.opts.IdleTimeout
+	}
+	return t.tunnels.IdleTimeout
+}
+
+func (t *Tunnel) touch() {
+	t.last.Store(t.tunnels.now().UnixNano())
+}
+
+// Wrap counts the traffic of a connection carried by the tunnel: reads are bytes
+// in from the remote side when conn is the remote end of the tunnel, and bytes out
+// when it is the local end, as given by remote.
+func (t *Tunnel) Wrap(conn net.Conn, remote bool) net.Conn {
+	t.connections.Add(1)
+	t.active.Add(1)
+	t.touch()
+	c := &tunnelConn{Conn: conn, t: t, read: &t.bytesOut, written: &t.bytesIn}
+	if remote {
+		c.read, c.written = &t.bytesIn, &t.bytesOut
+	}
+	return c
+}
+
+// tunnelConn counts the bytes of a connection for its tunnel.
+type tunnelConn struct {
+	net.Conn
+	t             *Tunnel
+	read, written *atomic.Int64
+	closed        atomic.Bool
+}
+
+func (c *tunnelConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.read.Add(int64(n))
+		c.t.touch()
+	}
+	return n, err
+}
+
+func (c *tunnelConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.written.Add(int64(n))
+		c.t.touch()
+	}
+	return n, err
+}
+
+func (c *tunnelConn) Close() error {
+	if c.closed.CompareAndSwap(false, true) {
+		c.t.active.Add(-1)
+	}
+	return c.Conn.Close()
+}
+
+// ===== Port forwards =====
+
+// DialFunc connects to addr from the host hostID, e.g. over an SSH direct-tcpip
+// channel.
+type DialFunc func(ctx context.Context, hostID, addr string) (net.Conn, error)
+
+// Forward opens a local port forward (ssh -L): it listens on opts.Listen and
+// connects every accepted connection to opts.Target from opts.HostID with dial.
+// The tunnel stops when it is closed or ctx is cancelled.
+func (ts *Tunnels) Forward(ctx context.Context, opts TunnelOptions, dial DialFunc) (*Tunnel, error) {
+	return ts.forward(ctx, opts, dial, nil)
+}
+
+// forward is Forward calling cleanup, unless nil, once the tunnel is closed.
+func (ts *Tunnels) forward(ctx context.Context, opts TunnelOptions, dial DialFunc, cleanup func() error) (*Tunnel, error) {
+	if opts.Target == "" {
+		return nil, fmt.Errorf("tunnel %s: no target", opts.Name)
+	}
+	listen := opts.Listen
+	if listen == "" {
+		listen = "127.0.0.1:0"
+	}
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, fmt.Errorf("tunnel %s: failed to listen: %w", opts.Name, err)
+	}
+	opts.Kind = TunnelLocal
+	opts.Listen = l.Addr().String()
+
+	ctx, cancel := context.WithCancel(ctx)
+	t, err := ts.Open(opts, closerFunc(func() error {
+		cancel()
+		err := l.Close()
+		if cleanup != nil {
+			err = errors.Join(err, cleanup())
+		}
+		return err
+	}))
+	if err != nil {
+		cancel()
+		l.Close()
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		t.Close()
+	}()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				local := t.Wrap(c, false)
+				defer local.Close()
+				remote, err := dial(ctx, opts.HostID, opts.Target)
+				if err != nil {
+					return
+				}
+				defer remote.Close()
+				splice(ctx, local, c, remote)
+			}()
+		}
+	}()
+	return t, nil
+}
+
+// splice copies between a local connection, wrapped as local, and a remote one
+// until both directions ended or ctx is done. The unwrapped raw connection is
+// half-closed when the remote side finished.
+func splice(ctx context.Context, local, raw, remote net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, local)
+		closeWrite(remote)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		closeWrite(raw)
+		done <- struct{}{}
+	}()
+	select {
+	case <-done:
+		<-done
+	case <-ctx.Done():
+	}
+}
+
+// closeWrite half-closes a connection that supports it, so the peer sees the end
+// of the stream while the answer can still be read.
+func closeWrite(c net.Conn) {
+	if cw, ok := c.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	}
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+// ===== API =====
+
+// ServeHTTP serves the tunnel statistics for the daemon API: GET /v1/tunnels
+// lists them as JSON and DELETE /v1/tunnels/{name} closes a tunnel.
+func (ts *Tunnels) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, isTunnel := strings.CutPrefix(r.URL.Path, "/v1/tunnels/")
+	switch {
+	case r.URL.Path == "/v1/tunnels" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, ts.Stats())
+	case isTunnel && name != "" && r.Method == http.MethodDelete:
+		if err := ts.Close(name); err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == "/v1/tunnels" || isTunnel:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
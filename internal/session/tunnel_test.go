@@ -0,0 +1,117 @@
+package session
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTunnels(t *testing.T) {
+	newTunnels := func() (*Tunnels, *fakeClock) {
+		clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		return &Tunnels{IdleTimeout: 10 * time.Minute, Now: clock.now}, clock
+	}
+
+	t.Run("counts bytes and connections", func(t *testing.T) {
+		ts, clock := newTunnels()
+		tun, err := ts.Open(TunnelOptions{Name: "pg", Kind: TunnelLocal, HostID: "db01", Listen: "127.0.0.1:5432", Target: "localhost:5432"}, nil)
+		require.NoError(t, err)
+
+		local, peer := net.Pipe()
+		c := tun.Wrap(local, false)
+		go func() {
+			buf := make([]byte, 5)
+			io.ReadFull(peer, buf)
+			peer.Write([]byte("hello world"))
+		}()
+		clock.advance(time.Minute)
+		_, err = c.Write([]byte("hello"))
+		require.NoError(t, err)
+		buf := make([]byte, 11)
+		_, err = io.ReadFull(c, buf)
+		require.NoError(t, err)
+
+		stats := ts.Stats()
+		require.Len(t, stats, 1)
+		assert.Equal(t, int64(5), stats[0].BytesIn, "written to the local end")
+		assert.Equal(t, int64(11), stats[0].BytesOut, "read from the local end")
+		assert.Equal(t, int64(1), stats[0].Connections)
+		assert.Equal(t, int64(1), stats[0].Active)
+		assert.True(t, clock.t.Equal(stats[0].LastActive))
+
+		c.Close()
+		c.Close()
+		assert.Equal(t, int64(0), tun.Stats().Active)
+	})
+
+	t.Run("closes idle tunnels", func(t *testing.T) {
+		ts, clock := newTunnels()
+		var closedStats []TunnelStats
+		ts.OnClose = func(s TunnelStats) { closedStats = append(closedStats, s) }
+
+		web := &fakeCloser{}
+		socks := &fakeCloser{}
+		pinned := &fakeCloser{}
+		_, err := ts.Open(TunnelOptions{Name: "web", Kind: TunnelLocal}, web)
+		require.NoError(t, err)
+		busy, err := ts.Open(TunnelOptions{Name: "socks", Kind: TunnelSOCKS, IdleTimeout: time.Hour}, socks)
+		require.NoError(t, err)
+		_, err = ts.Open(TunnelOptions{Name: "pinned", Kind: TunnelRemote, IdleTimeout: -1}, pinned)
+		require.NoError(t, err)
+		_, err = ts.Open(TunnelOptions{Name: "web", Kind: TunnelLocal}, nil)
+		assert.Error(t, err)
+
+		clock.advance(9 * time.Minute)
+		assert.Empty(t, ts.Check())
+		clock.advance(time.Minute)
+		assert.Equal(t, []string{"web"}, ts.Check())
+		assert.True(t, web.closed)
+		require.Len(t, closedStats, 1)
+		assert.Equal(t, 10*time.Minute, closedStats[0].Idle)
+
+		busy.touch()
+		clock.advance(59 * time.Minute)
+		assert.Empty(t, ts.Check())
+		clock.advance(2 * time.Minute)
+		assert.Equal(t, []string{"socks"}, ts.Check())
+		assert.False(t, pinned.closed)
+		assert.Len(t, ts.Stats(), 1)
+	})
+
+	t.Run("serves statistics", func(t *testing.T) {
+		ts, _ := newTunnels()
+		closer := &fakeCloser{}
+		_, err := ts.Open(TunnelOptions{Name: "pg", Kind: TunnelLocal}, closer)
+		require.NoError(t, err)
+		srv := httptest.NewServer(ts)
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL + "/v1/tunnels")
+		require.NoError(t, err)
+		var stats []TunnelStats
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+		resp.Body.Close()
+		require.Len(t, stats, 1)
+		assert.Equal(t, "pg", stats[0].Name)
+		assert.Equal(t, 10*time.Minute, stats[0].IdleTimeout)
+
+		req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/v1/tunnels/pg", nil)
+		resp, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.True(t, closer.closed)
+
+		resp, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
@@ -0,0 +1,169 @@
+package share
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gossher/internal/inventory"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// LinkPrefix starts every host share link.
+const LinkPrefix = "gossher://host/"
+
+// maxPayloadSize bounds the decompressed size of a share link.
+const maxPayloadSize = 64 * 1024
+
+// payload is the shareable subset of a host. It never carries secrets or
+// references that only make sense in the sender's inventory (credentials).
+type payload struct {
+	Version int `json:"v"`
+
+	ID          string            `json:"id"`
+	Name        string            `json:"n"`
+	Description string            `json:"d,omitempty"`
+	Address     string            `json:"a"`
+	Port        int               `json:"p,omitempty"`
+	User        string            `json:"u,omitempty"`
+	JumpHostID  string            `json:"j,omitempty"`
+	Tags        []string          `json:"t,omitempty"`
+	Vars        map[string]string `json:"vars,omitempty"`
+	Groups      []string          `json:"g,omitempty"`
+}
+
+const payloadVersion = 1
+
+// EncodeHost renders a host from the inventory as a compact share link.
+// Passwords, key paths and credential references are dropped; the resolved
+// user name is kept so the receiver only has to pick a key.
+func EncodeHost(m *inventory.Manager, hostID string) (string, error) {
+	b, err := m.ExportHost(hostID, inventory.ExportOptions{IncludeCredential: true, Anonymize: true})
+	if err != nil {
+		return "", err
+	}
+
+	h := b.Host
+	p := payload{
+		Version:     payloadVersion,
+		ID:          h.ID,
+		Name:        h.Name,
+		Description: h.Description,
+		Address:     h.Address,
+		Port:        h.Port,
+		User:        h.User,
+		JumpHostID:  h.JumpHostID,
+		Tags:        h.Tags,
+		Vars:        h.Vars,
+		Groups:      b.Groups,
+	}
+	if p.User == "" && b.Credential != nil {
+		p.User = b.Credential.User
+	}
+	if p.Port == 22 {
+		p.Port = 0
+	}
+
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal host: %w", err)
+	}
+
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", fmt.Errorf("failed to compress host: %w", err)
+	}
+	if _, err := w.Write(raw); err != nil {
+		return "", fmt.Errorf("failed to compress host: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress host: %w", err)
+	}
+
+	return LinkPrefix + base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// Decode parses a share link into an importable bundle. The prefix is optional.
+func Decode(link string) (*inventory.HostBundle, error) {
+	encoded := strings.TrimPrefix(strings.TrimSpace(link), LinkPrefix)
+
+	compressed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid share link: %w", err)
+	}
+
+	r := flate.NewReader(bytes.NewReader(compressed))
+	defer r.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(r, maxPayloadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid share link: %w", err)
+	}
+	if len(raw) > maxPayloadSize {
+		return nil, fmt.Errorf("invalid share link: payload too large")
+	}
+
+	var p payload
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("invalid share link: %w", err)
+	}
+	if p.Version != payloadVersion {
+		return nil, fmt.Errorf("unsupported share link version: %d", p.Version)
+	}
+
+	h := inventory.NewHost(p.ID, p.Name, p.Address)
+	h.Description = p.Description
+	h.User = p.User
+	h.JumpHostID = p.JumpHostID
+	if p.Port != 0 {
+		h.Port = p.Port
+	}
+	if p.Tags != nil {
+		h.Tags = p.Tags
+	}
+	for k, v := range p.Vars {
+		h.SetVar(k, v)
+	}
+
+	return &inventory.HostBundle{Host: h, Groups: p.Groups}, nil
+}
+
+// Import decodes a share link and adds the host to the inventory. A credential ID
+// may be given to use one of the receiver's own credentials instead of the shared user.
+func Import(m *inventory.Manager, link, credentialID string, opts inventory.ImportOptions) (*inventory.ImportResult, error) {
+	b, err := Decode(link)
+	if err != nil {
+		return nil, err
+	}
+	if credentialID != "" {
+		b.Host.CredentialID = credentialID
+		b.Host.User = ""
+	}
+	return m.ImportHost(b, opts)
+}
+
+// ===== QR codes =====
+
+// QRString renders text as a QR code made of Unicode half blocks for terminals.
+func QRString(text string) (string, error) {
+	q, err := qrcode.New(text, qrcode.Medium)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	return q.ToSmallString(false), nil
+}
+
+// QRPNG renders text as a PNG QR code of the given size in pixels.
+func QRPNG(text string, size int) ([]byte, error) {
+	png, err := qrcode.Encode(text, qrcode.Medium, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	return png, nil
+}
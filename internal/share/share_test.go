@@ -0,0 +1,72 @@
+package share
+
+import (
+	"strings"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShareRoundTrip(t *testing.T) {
+	src := inventory.NewManager(t.TempDir())
+
+	cred := inventory.NewCredential("ops", "Ops", "ops")
+	cred.Password = "top-secret"
+	require.NoError(t, src.AddCredential(cred))
+
+	h := inventory.NewHostWithCredential("web1", "Web 1", "10.0.0.1", "ops")
+	h.Port = 2222
+	h.Tags = []string{"env:prod"}
+	h.SetVar("app", "shop")
+	require.NoError(t, src.AddHost(h))
+
+	g := inventory.NewGroup("web")
+	g.AddHost("web1")
+	require.NoError(t, src.AddGroup(g))
+
+	link, err := EncodeHost(src, "web1")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(link, LinkPrefix))
+
+	b, err := Decode(link)
+	require.NoError(t, err)
+	assert.Equal(t, "ops", b.Host.User)
+	assert.Equal(t, 2222, b.Host.Port)
+	assert.Empty(t, b.Host.CredentialID)
+	assert.Empty(t, b.Host.Password)
+	assert.Equal(t, []string{"web"}, b.Groups)
+
+	t.Run("import into other inventory", func(t *testing.T) {
+		dst := inventory.NewManager(t.TempDir())
+		require.NoError(t, dst.AddGroup(inventory.NewGroup("web")))
+
+		result, err := Import(dst, link, "", inventory.ImportOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web"}, result.JoinedGroups)
+
+		got, ok := dst.GetHost("web1")
+		require.True(t, ok)
+		assert.Equal(t, "shop", got.Vars["app"])
+	})
+
+	t.Run("QR rendering", func(t *testing.T) {
+		qr, err := QRString(link)
+		require.NoError(t, err)
+		assert.NotEmpty(t, qr)
+
+		png, err := QRPNG(link, 128)
+		require.NoError(t, err)
+		assert.Equal(t, "\x89PNG", string(png[:4]))
+	})
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	_, err := Decode(LinkPrefix + "!!!")
+	assert.Error(t, err)
+
+	_, err = Decode("AAAA")
+	assert.Error(t, err)
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func FuzzRead(f *testing.F) {
+	for _, seed := range []string{
+		"type: host\nid: web1\nname: web1\naddress: 10.0.0.1\nport: 22\n",
+		"type: group\nname: web\nhost_ids: [web1]\n---\ntype: host\nid: web1\nname: web1\naddress: 10.0.0.1\n",
+		"type: host\nid: web1\nnotes: |\n  ---\n---\ntype: group\nname: web\n",
+		"--- # first\ntype: credential\nid: admin\nname: Admin\n...\n--- {type: group, name: db}\n",
+		"type: config\ndata_dir: /tmp\n",
+		"type: host\nid: [\n",
+		"type: unknown\n",
+		"",
+	} {
+		f.Add([]byte(seed), false)
+		f.Add([]byte(seed), true)
+	}
+
+	dir := f.TempDir()
+	f.Fuzz(func(t *testing.T, data []byte, strict bool) {
+		repo := &Repository{baseDir: dir, opts: Options{Strict: strict}}
+		if err := os.WriteFile(filepath.Join(dir, "fuzz.yaml"), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		typ, doc, err := repo.Read("fuzz.yaml")
+		if err == nil && (typ == "" || doc == nil) {
+			t.Fatalf("Read returned no document without error: %q %v", typ, doc)
+		}
+
+		docs, err := repo.ReadAll("fuzz.yaml")
+		if err == nil {
+			for i, doc := range docs {
+				if doc == nil {
+					t.Fatalf("ReadAll returned a nil document %d", i)
+				}
+			}
+		}
+	})
+}
@@ -1,7 +1,10 @@
 package storage
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sync"
@@ -18,14 +21,32 @@ const (
 	TypeHost       = inventory.TypeHost
 	TypeGroup      = inventory.TypeGroup
 	TypeCredential = inventory.TypeCredential
+	TypeCommand    = inventory.TypeCommand
+	TypeCheck      = inventory.TypeCheck
+	TypeWorkflow   = inventory.TypeWorkflow
+	TypeTheme      = inventory.TypeTheme
+	TypeSessionSet = inventory.TypeSessionSet
 )
 
 // Repository handles reading and writing YAML files with type discrimination.
 type Repository struct {
 	baseDir string
+	opts    Options
 	mu      sync.RWMutex
 }
 
+// Options controls how strictly a Repository checks documents. Both checks are off
+// by default.
+type Options struct {
+	// Validate calls Validate() on entities before they are written.
+	Validate bool
+	// Strict rejects fields that the document type does not know, e.g. "adress:".
+	// See inventory.GetStrictYAML for the configured default.
+	Strict bool
+	// Permissions sets the file modes; files holding credentials get the secret mode.
+	Permissions inventory.FilePermissions
+}
+
 // Global repository singleton
 var (
 	globalRepository *Repository
@@ -43,7 +64,7 @@ func Init(baseDir string) error {
 			return
 		}
 
-		if err := os.MkdirAll(baseDir, 0755); err != nil {
+		if err := os.MkdirAll(baseDir, inventory.DefaultDirMode); err != nil {
 			initErr = fmt.Errorf("failed to create base directory: %w", err)
 			return
 		}
@@ -69,27 +90,84 @@ func GetRepository() *Repository {
 	return globalRepository
 }
 
+// SetOptions changes how documents are checked on Write and Read.
+func (r *Repository) SetOptions(opts Options) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.opts = opts
+}
+
+// Options returns the current document checks.
+func (r *Repository) Options() Options {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.opts
+}
+
 // ===== Core Operations =====
 
 // Write writes a struct to a YAML file (struct already has type field).
+// With Options.Validate, entities that fail validation are not written.
+// Comments and formatting of an existing file are kept.
 func (r *Repository) Write(filename string, v any) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	data, err := yaml.Marshal(v)
+	if r.opts.Validate {
+		if e, ok := v.(inventory.Validatable); ok {
+			if err := e.Validate(); err != nil {
+				return fmt.Errorf("invalid %s: %w", filename, err)
+			}
+		}
+	}
+
+	path := filepath.Join(r.baseDir, filename)
+	if err := checkWritable(path, filename); err != nil {
+		return err
+	}
+	data, err := render(path, v)
 	if err != nil {
-		return fmt.Errorf("failed to marshal YAML: %w", err)
+		return err
+	}
+
+	return writeFile(path, data, r.opts.Permissions.ModeFor(v))
+}
+
+// WriteAll writes several structs to one multi-document YAML file, in order, e.g. a
+// group followed by its hosts. With Options.Validate, nothing is written unless every
+// entity is valid. Comments and formatting of existing documents are kept.
+func (r *Repository) WriteAll(filename string, entities ...any) error {
+	if len(entities) == 0 {
+		return fmt.Errorf("no entities to write to %s", filename)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.opts.Validate {
+		for i, v := range entities {
+			if e, ok := v.(inventory.Validatable); ok {
+				if err := e.Validate(); err != nil {
+					return fmt.Errorf("invalid %s (document %d): %w", filename, i+1, err)
+				}
+			}
+		}
 	}
 
 	path := filepath.Join(r.baseDir, filename)
-	if err := os.WriteFile(path, data, 0644); err != nil {
-		return fmt.Errorf("failed to write file %s: %w", path, err)
+	if err := checkWritable(path, filename); err != nil {
+		return err
+	}
+	data, err := render(path, entities...)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return writeFile(path, data, r.opts.Permissions.ModeFor(entities...))
 }
 
-// / Read reads a YAML file and returns the appropriate typed struct.
+// Read reads a YAML file and returns the appropriate typed struct.
+// With Options.Strict, unknown fields are an error.
 func (r *Repository) Read(filename string) (DocumentType, any, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -103,37 +181,93 @@ func (r *Repository) Read(filename string) (DocumentType, any, error) {
 		return "", nil, fmt.Errorf("failed to read file %s: %w", filename, err)
 	}
 
+	// Step 0: Decrypt externally encrypted files (e.g. sops)
+	data, _, err = inventory.DecodeContent(path, data)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err = inventory.ToYAML(filename, data)
+	if err != nil {
+		return "", nil, err
+	}
+
 	// Step 1: Extract type first
 	var typeDoc struct {
 		Type DocumentType `yaml:"type"`
 	}
 	if err := yaml.Unmarshal(data, &typeDoc); err != nil {
-		return "", nil, fmt.Errorf("failed to extract type: %w", err)
+		return "", nil, fmt.Errorf("failed to extract type: %w", inventory.NewParseError(path, data, 0, err))
 	}
 
 	// Step 2: Create appropriate struct based on type
-	var result any
-	switch typeDoc.Type {
-	case TypeHost:
-		result = &inventory.Host{}
-	case TypeGroup:
-		result = &inventory.Group{}
-	case TypeCredential:
-		result = &inventory.Credential{}
-	case TypeConfig:
-		result = &inventory.Config{} // map 대신 Config 구조체
-	default:
-		return "", nil, fmt.Errorf("unknown document type: %s", typeDoc.Type)
+	result, err := newDocument(typeDoc.Type)
+	if err != nil {
+		return "", nil, err
 	}
 
 	// Step 3: Unmarshal into the created struct
-	if err := yaml.Unmarshal(data, result); err != nil {
-		return "", nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
+	if err := decode(data, result, r.opts.Strict); err != nil {
+		return "", nil, fmt.Errorf("failed to unmarshal YAML: %w", inventory.NewParseError(path, data, 0, err))
 	}
 
 	return typeDoc.Type, result, nil
 }
 
+// ReadAll reads every document of a (possibly multi-document) YAML file and returns
+// the typed structs in file order.
+func (r *Repository) ReadAll(filename string) ([]any, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	path := filepath.Join(r.baseDir, filename)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("file not found: %s", filename)
+		}
+		return nil, fmt.Errorf("failed to read file %s: %w", filename, err)
+	}
+
+	data, _, err = inventory.DecodeContent(path, data)
+	if err != nil {
+		return nil, err
+	}
+	data, err = inventory.ToYAML(filename, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Two decoders walk the stream in step: one only extracts the type, the other
+	// decodes the typed struct, so errors keep their positions in the file.
+	types := yaml.NewDecoder(bytes.NewReader(data))
+	values := yaml.NewDecoder(bytes.NewReader(data))
+	values.KnownFields(r.opts.Strict)
+
+	var docs []any
+	for {
+		var typeDoc struct {
+			Type DocumentType `yaml:"type"`
+		}
+		if err := types.Decode(&typeDoc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to extract type: %w", inventory.NewParseError(path, data, 0, err))
+		}
+		result, err := newDocument(typeDoc.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s (document %d): %w", filename, len(docs)+1, err)
+		}
+		if err := values.Decode(result); err != nil {
+			err = inventory.UnknownFieldsOf(err)
+			return nil, fmt.Errorf("failed to unmarshal YAML: %w", inventory.NewParseError(path, data, 0, err))
+		}
+		docs = append(docs, result)
+	}
+
+	return docs, nil
+}
+
 // ReadAs reads a YAML file and unmarshals into the provided struct (legacy support).
 func (r *Repository) ReadAs(filename string, v any) (DocumentType, error) {
 	r.mu.RLock()
@@ -145,15 +279,24 @@ func (r *Repository) ReadAs(filename string, v any) (DocumentType, error) {
 		return "", fmt.Errorf("failed to read file %s: %w", path, err)
 	}
 
+	data, _, err = inventory.DecodeContent(path, data)
+	if err != nil {
+		return "", err
+	}
+	data, err = inventory.ToYAML(filename, data)
+	if err != nil {
+		return "", err
+	}
+
 	var typeDoc struct {
 		Type DocumentType `yaml:"type"`
 	}
 	if err := yaml.Unmarshal(data, &typeDoc); err != nil {
-		return "", fmt.Errorf("failed to extract type: %w", err)
+		return "", fmt.Errorf("failed to extract type: %w", inventory.NewParseError(path, data, 0, err))
 	}
 
-	if err := yaml.Unmarshal(data, v); err != nil {
-		return "", fmt.Errorf("failed to unmarshal YAML: %w", err)
+	if err := decode(data, v, r.opts.Strict); err != nil {
+		return "", fmt.Errorf("failed to unmarshal YAML: %w", inventory.NewParseError(path, data, 0, err))
 	}
 
 	return typeDoc.Type, nil
@@ -196,7 +339,7 @@ func (r *Repository) List() ([]string, error) {
 
 	var files []string
 	for _, entry := range entries {
-		if !entry.IsDir() && isYAMLFile(entry.Name()) {
+		if !entry.IsDir() && isDocumentFile(entry.Name()) {
 			files = append(files, entry.Name())
 		}
 	}
@@ -218,6 +361,14 @@ func (r *Repository) ListByType(docType DocumentType) ([]string, error) {
 		if err != nil {
 			continue // 읽기 실패 시 건너뜀
 		}
+		data, _, err = inventory.DecodeContent(path, data)
+		if err != nil {
+			continue // 복호화 실패 시 건너뜀
+		}
+		data, err = inventory.ToYAML(filename, data)
+		if err != nil {
+			continue // 변환 실패 시 건너뜀
+		}
 
 		var typeDoc struct {
 			Type DocumentType `yaml:"type"`
@@ -236,6 +387,106 @@ func (r *Repository) ListByType(docType DocumentType) ([]string, error) {
 
 // ===== Helper Functions =====
 
+// checkWritable refuses to rewrite files encrypted with a content decoder, which
+// would otherwise be replaced with plaintext, like inventory.Manager does.
+func checkWritable(path, filename string) error {
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	if tool := inventory.DetectContent(original); tool != "" {
+		return fmt.Errorf("file %s is encrypted with %s; edit it with %s instead", filename, tool, tool)
+	}
+	return nil
+}
+
+// render marshals values as the documents of path in the format of its extension.
+// When path already holds plain YAML, its comments, key order and indentation are kept.
+func render(path string, values ...any) ([]byte, error) {
+	if format, _ := inventory.FormatOf(path); format != inventory.FormatYAML {
+		data, err := marshalDocuments(values)
+		if err != nil {
+			return nil, err
+		}
+		return inventory.FromYAML(path, data)
+	}
+
+	if original, err := os.ReadFile(path); err == nil {
+		if merged, err := inventory.MergeYAML(original, values...); err == nil {
+			return merged, nil
+		}
+		// Unparsable files are replaced as a whole
+	}
+	return marshalDocuments(values)
+}
+
+// marshalDocuments renders values as a multi-document YAML stream.
+func marshalDocuments(values []any) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, v := range values {
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal YAML: %w", err)
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+// newDocument returns an empty struct for a document type.
+func newDocument(docType DocumentType) (any, error) {
+	switch docType {
+	case TypeHost:
+		return &inventory.Host{}, nil
+	case TypeGroup:
+		return &inventory.Group{}, nil
+	case TypeCredential:
+		return &inventory.Credential{}, nil
+	case TypeCommand:
+		return &inventory.SavedCommand{}, nil
+	case TypeCheck:
+		return &inventory.Check{}, nil
+	case TypeWorkflow:
+		return &inventory.Workflow{}, nil
+	case TypeTheme:
+		return &inventory.Theme{}, nil
+	case TypeSessionSet:
+		return &inventory.SessionSet{}, nil
+	case TypeConfig:
+		return &inventory.Config{}, nil // map 대신 Config 구조체
+	default:
+		return nil, fmt.Errorf("unknown document type: %s", docType)
+	}
+}
+
+// decode unmarshals a single YAML document, rejecting unknown fields when strict.
+func decode(data []byte, v any, strict bool) error {
+	if strict {
+		return inventory.StrictUnmarshal(data, v)
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+// writeFile writes data and applies mode, which WriteFile skips for existing files.
+func writeFile(path string, data []byte, mode os.FileMode) error {
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		return fmt.Errorf("failed to change permissions of %s: %w", path, err)
+	}
+	return nil
+}
+
+// isDocumentFile reports whether a file is YAML, JSON or TOML.
+func isDocumentFile(filename string) bool {
+	_, ok := inventory.FormatOf(filename)
+	return ok
+}
+
 func isYAMLFile(filename string) bool {
 	ext := filepath.Ext(filename)
 	return ext == ".yaml" || ext == ".yml"
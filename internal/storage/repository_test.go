@@ -1,9 +1,11 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 
@@ -460,6 +462,212 @@ func TestGetBaseDir(t *testing.T) {
 	assert.Equal(t, tmpDir, baseDir)
 }
 
+func TestWriteAll(t *testing.T) {
+	group := &inventory.Group{Type: inventory.TypeGroup, Name: "web", HostIDs: []string{"web1", "web2"}}
+	web1 := inventory.NewHost("web1", "web1", "10.0.0.1")
+	web1.User = "deploy"
+	web2 := inventory.NewHost("web2", "web2", "10.0.0.2")
+	web2.User = "deploy"
+
+	t.Run("group and hosts in one file", func(t *testing.T) {
+		repo, tmpDir := setupTestRepo(t)
+		require.NoError(t, repo.WriteAll("web.yaml", group, web1, web2))
+
+		data, err := os.ReadFile(filepath.Join(tmpDir, "web.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, 2, strings.Count(string(data), "---\n"))
+
+		docs, err := repo.ReadAll("web.yaml")
+		require.NoError(t, err)
+		require.Len(t, docs, 3)
+		assert.Equal(t, "web", docs[0].(*inventory.Group).Name)
+		assert.Equal(t, "web2", docs[2].(*inventory.Host).ID)
+
+		docType, first, err := repo.Read("web.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, inventory.TypeGroup, docType)
+		assert.Equal(t, group.HostIDs, first.(*inventory.Group).HostIDs)
+	})
+
+	t.Run("validation is all or nothing", func(t *testing.T) {
+		repo, tmpDir := setupTestRepo(t)
+		repo.SetOptions(Options{Validate: true})
+
+		invalid := &inventory.Host{Type: inventory.TypeHost, ID: "bad"}
+		err := repo.WriteAll("web.yaml", group, web1, invalid)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "document 3")
+		assert.NoFileExists(t, filepath.Join(tmpDir, "web.yaml"))
+
+		assert.Error(t, repo.WriteAll("empty.yaml"))
+	})
+
+	t.Run("strict errors point into the file", func(t *testing.T) {
+		repo, tmpDir := setupTestRepo(t)
+		repo.SetOptions(Options{Strict: true})
+		content := "type: group\nname: web\n---\ntype: host\nid: web1\nadress: x\n"
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "web.yaml"), []byte(content), 0644))
+
+		_, err := repo.ReadAll("web.yaml")
+		var pe *inventory.ParseError
+		require.True(t, errors.As(err, &pe))
+		assert.Equal(t, 6, pe.Line)
+		assert.Contains(t, pe.Msg, "adress")
+	})
+}
+
+func TestDocumentFormats(t *testing.T) {
+	host := inventory.NewHost("web1", "web1", "10.0.0.1")
+	host.User = "deploy"
+	host.Port = 2222
+
+	for _, name := range []string{"web1.json", "web1.toml"} {
+		t.Run(name, func(t *testing.T) {
+			repo, _ := setupTestRepo(t)
+			require.NoError(t, repo.Write(name, host))
+
+			docType, v, err := repo.Read(name)
+			require.NoError(t, err)
+			assert.Equal(t, inventory.TypeHost, docType)
+			assert.Equal(t, 2222, v.(*inventory.Host).Port)
+
+			files, err := repo.List()
+			require.NoError(t, err)
+			assert.Contains(t, files, name)
+
+			hosts, err := repo.ListByType(inventory.TypeHost)
+			require.NoError(t, err)
+			assert.Equal(t, []string{name}, hosts)
+		})
+	}
+
+	t.Run("multiple documents", func(t *testing.T) {
+		repo, _ := setupTestRepo(t)
+		group := &inventory.Group{Type: inventory.TypeGroup, Name: "web", HostIDs: []string{"web1"}}
+		require.NoError(t, repo.WriteAll("web.toml", group, host))
+
+		docs, err := repo.ReadAll("web.toml")
+		require.NoError(t, err)
+		require.Len(t, docs, 2)
+		assert.Equal(t, "web1", docs[1].(*inventory.Host).ID)
+	})
+}
+
+func TestWritePreservesComments(t *testing.T) {
+	repo, tmpDir := setupTestRepo(t)
+	path := filepath.Join(tmpDir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`# personal settings
+type: config
+theme: dark # easier on the eyes
+language: en
+`), 0644))
+
+	_, v, err := repo.Read("config.yaml")
+	require.NoError(t, err)
+	cfg := v.(*inventory.Config)
+	cfg.Language = "ko"
+	require.NoError(t, repo.Write("config.yaml", cfg))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "# personal settings\ntype: config\n")
+	assert.Contains(t, string(data), "theme: dark # easier on the eyes\nlanguage: ko\n")
+}
+
+// fakeDecoder decodes files starting with its marker by dropping the marker.
+type fakeDecoder struct{}
+
+const fakeMarker = "# fake-encrypted\n"
+
+func (fakeDecoder) Name() string { return "fake" }
+
+func (fakeDecoder) Detect(data []byte) bool { return strings.HasPrefix(string(data), fakeMarker) }
+
+func (fakeDecoder) DecodeFile(path string, data []byte) ([]byte, error) {
+	return []byte(strings.TrimPrefix(string(data), fakeMarker)), nil
+}
+
+func TestEncryptedFiles(t *testing.T) {
+	inventory.RegisterContentDecoder(fakeDecoder{})
+	repo, tmpDir := setupTestRepo(t)
+	path := filepath.Join(tmpDir, "host-web.yaml")
+	content := fakeMarker + "type: host\nid: web\nname: web\naddress: 10.0.0.1\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	_, entity, err := repo.Read("host-web.yaml")
+	require.NoError(t, err)
+	h := entity.(*inventory.Host)
+	assert.Equal(t, "10.0.0.1", h.Address)
+
+	h.Address = "10.0.0.2"
+	assert.ErrorContains(t, repo.Write("host-web.yaml", h), "encrypted with fake")
+	assert.ErrorContains(t, repo.WriteAll("host-web.yaml", h), "encrypted with fake")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data), "the encrypted file is not replaced with plaintext")
+}
+
+func TestOptions(t *testing.T) {
+	t.Run("validate before write", func(t *testing.T) {
+		repo, tmpDir := setupTestRepo(t)
+		invalid := &inventory.Host{Type: inventory.TypeHost, ID: "h1", Address: "10.0.0.1"}
+
+		require.NoError(t, repo.Write("lenient.yaml", invalid), "validation is off by default")
+
+		repo.SetOptions(Options{Validate: true})
+		err := repo.Write("h1.yaml", invalid)
+		assert.Error(t, err)
+		assert.NoFileExists(t, filepath.Join(tmpDir, "h1.yaml"))
+
+		valid := inventory.NewHost("h2", "h2", "10.0.0.2")
+		valid.User = "root"
+		assert.NoError(t, repo.Write("h2.yaml", valid))
+	})
+
+	t.Run("strict read", func(t *testing.T) {
+		repo, tmpDir := setupTestRepo(t)
+		content := `type: host
+id: h1
+name: h1
+adress: 10.0.0.1
+`
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "typo.yaml"), []byte(content), 0644))
+
+		_, _, err := repo.Read("typo.yaml")
+		require.NoError(t, err, "unknown fields are ignored by default")
+
+		repo.SetOptions(Options{Strict: true})
+		assert.True(t, repo.Options().Strict)
+
+		_, _, err = repo.Read("typo.yaml")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "adress")
+		assert.Contains(t, err.Error(), "line 4")
+
+		var host inventory.Host
+		_, err = repo.ReadAs("typo.yaml", &host)
+		assert.Error(t, err)
+	})
+	t.Run("credential files are private", func(t *testing.T) {
+		repo, tmpDir := setupTestRepo(t)
+		cred := inventory.NewCredential("deploy", "Deploy", "deploy")
+		cred.Password = "secret"
+		path := filepath.Join(tmpDir, "deploy.yaml")
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+
+		require.NoError(t, repo.Write("deploy.yaml", cred))
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		repo.SetOptions(Options{Permissions: inventory.FilePermissions{File: "0640"}})
+		require.NoError(t, repo.Write("web1.yaml", inventory.NewHost("web1", "web1", "10.0.0.1")))
+		info, err = os.Stat(filepath.Join(tmpDir, "web1.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	})
+}
+
 func TestConcurrency(t *testing.T) {
 	repo, _ := setupTestRepo(t)
 
@@ -0,0 +1,287 @@
+// Package telemetry collects anonymous usage statistics: how often features are
+// used and which panics occur. It is strictly opt-in: reports are only sent when
+// inventory.TelemetryConfig.Enabled is set, and Preview shows the exact report
+// beforehand. Reports never contain host data: events are counted by type only,
+// feature names are fixed identifiers and crashes carry the panic type and the
+// function names of the stack, not the panic message or file paths.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"gossher/internal/events"
+	"gossher/internal/inventory"
+)
+
+// SchemaVersion is the version of the report format.
+const SchemaVersion = 1
+
+// InstallIDFile is the file in the data directory holding the random installation
+// ID that lets reports of one installation be told apart.
+const InstallIDFile = "telemetry-id"
+
+// DefaultTimeout limits sending a report.
+const DefaultTimeout = 10 * time.Second
+
+const (
+	// maxCrashes is the number of crashes kept per report.
+	maxCrashes = 10
+	// maxFrames is the number of stack frames kept per crash.
+	maxFrames = 32
+)
+
+// ErrDisabled is returned by Send when telemetry is not enabled.
+var ErrDisabled = errors.New("telemetry is disabled")
+
+// featurePattern restricts feature names to identifiers such as "exec.saved", so no
+// free text ends up in a report.
+var featurePattern = regexp.MustCompile(`^[a-z][a-z0-9_.-]{0,63}$`)
+
+// Crash is a recovered panic without its message.
+type Crash struct {
+	Time time.Time `json:"time"`
+	// Type is the Go type of the panic value, e.g. "runtime.boundsError".
+	Type string `json:"type"`
+	// Stack lists the functions of the panicking goroutine, innermost first.
+	Stack []string `json:"stack"`
+}
+
+// Report is what is sent.
+type Report struct {
+	Schema    int       `json:"schema"`
+	InstallID string    `json:"install_id"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+	GoVersion string    `json:"go_version"`
+	Since     time.Time `json:"since"`
+	Until     time.Time `json:"until"`
+	// Features counts uses of features by name.
+	Features map[string]int `json:"features"`
+	// Events counts published events by type.
+	Events  map[string]int `json:"events"`
+	Crashes []Crash        `json:"crashes"`
+}
+
+// Collector counts usage until the report is sent. Counting is local and cheap, so
+// it happens whether or not telemetry is enabled; only Send looks at the setting.
+type Collector struct {
+	installID string
+	client    *http.Client
+	now       func() time.Time
+
+	mu       sync.Mutex
+	since    time.Time
+	features map[string]int
+	events   map[string]int
+	crashes  []Crash
+}
+
+// New creates a collector for the installation with the given ID.
+func New(installID string) *Collector {
+	c := &Collector{
+		installID: installID,
+		client:    &http.Client{Timeout: DefaultTimeout},
+		now:       time.Now,
+	}
+	c.reset()
+	return c
+}
+
+// SetHTTPClient replaces the HTTP client, e.g. to use a proxy.
+func (c *Collector) SetHTTPClient(hc *http.Client) {
+	c.client = hc
+}
+
+// reset starts a new report period.
+func (c *Collector) reset() {
+	c.since = c.now().UTC()
+	c.features = map[string]int{}
+	c.events = map[string]int{}
+	c.crashes = nil
+}
+
+// Count records a use of a feature. Names must be fixed identifiers like
+// "exec.saved"; anything else is ignored.
+func (c *Collector) Count(feature string) {
+	if !featurePattern.MatchString(feature) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.features[feature]++
+}
+
+// Subscribe counts the events published on a bus by type. The returned function
+// removes the subscription.
+func (c *Collector) Subscribe(bus *events.Bus) (unsubscribe func()) {
+	return bus.Subscribe(func(e events.Event) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.events[string(e.Type)]++
+	})
+}
+
+// Crash records a recovered panic value with the stack of debug.Stack.
+func (c *Collector) Crash(value any, stack []byte) {
+	crash := Crash{Time: c.now().UTC(), Type: fmt.Sprintf("%T", value), Stack: stackFunctions(stack)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.crashes) < maxCrashes {
+		c.crashes = append(c.crashes, crash)
+	}
+}
+
+// Recover records a panic and panics again. Defer it at the top of goroutines:
+//
+//	defer collector.Recover()
+func (c *Collector) Recover() {
+	if r := recover(); r != nil {
+		c.Crash(r, debug.Stack())
+		panic(r)
+	}
+}
+
+// stackFunctions returns the function names of a goroutine dump, dropping the
+// arguments, the file paths and the frames of the panic machinery.
+func stackFunctions(stack []byte) []string {
+	var frames []string
+	for _, line := range strings.Split(string(stack), "\n") {
+		if line == "" || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "goroutine ") {
+			continue
+		}
+		if i := strings.LastIndex(line, "("); i > 0 {
+			line = line[:i]
+		}
+		if strings.HasPrefix(line, "runtime/debug.") || strings.HasPrefix(line, "panic") ||
+			strings.HasPrefix(line, "runtime.gopanic") || strings.HasSuffix(line, ").Recover") {
+			continue
+		}
+		frames = append(frames, line)
+		if len(frames) == maxFrames {
+			break
+		}
+	}
+	return frames
+}
+
+// Snapshot returns the report of the current period.
+func (c *Collector) Snapshot() Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := Report{
+		Schema:    SchemaVersion,
+		InstallID: c.installID,
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		GoVersion: runtime.Version(),
+		Since:     c.since,
+		Until:     c.now().UTC(),
+		Features:  make(map[string]int, len(c.features)),
+		Events:    make(map[string]int, len(c.events)),
+		Crashes:   append([]Crash{}, c.crashes...),
+	}
+	for k, v := range c.features {
+		r.Features[k] = v
+	}
+	for k, v := range c.events {
+		r.Events[k] = v
+	}
+	return r
+}
+
+// Preview returns the report Send would post, as indented JSON.
+func (c *Collector) Preview() ([]byte, error) {
+	data, err := json.MarshalIndent(c.Snapshot(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal telemetry report: %w", err)
+	}
+	return data, nil
+}
+
+// Send posts the report to the configured endpoint and starts a new period. It
+// returns ErrDisabled, sending nothing, unless telemetry is enabled.
+func (c *Collector) Send(ctx context.Context, cfg inventory.TelemetryConfig) error {
+	if !cfg.Enabled {
+		return ErrDisabled
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	report := c.Snapshot()
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal telemetry report: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create telemetry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send telemetry: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to send telemetry: %s", resp.Status)
+	}
+
+	// Keep what was counted while the report was in flight.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range report.Features {
+		if c.features[k] -= v; c.features[k] <= 0 {
+			delete(c.features, k)
+		}
+	}
+	for k, v := range report.Events {
+		if c.events[k] -= v; c.events[k] <= 0 {
+			delete(c.events, k)
+		}
+	}
+	c.crashes = c.crashes[len(report.Crashes):]
+	c.since = report.Until
+	return nil
+}
+
+// LoadInstallID returns the installation ID stored in dir, creating a random one
+// on first use. The ID is not derived from anything about the machine or the user.
+func LoadInstallID(dir string) (string, error) {
+	path := filepath.Join(dir, InstallIDFile)
+	if data, err := os.ReadFile(path); err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read install ID: %w", err)
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to create install ID: %w", err)
+	}
+	id := hex.EncodeToString(b)
+	if err := os.WriteFile(path, []byte(id+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("failed to write install ID: %w", err)
+	}
+	return id, nil
+}
@@ -0,0 +1,117 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gossher/internal/events"
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	t.Run("counts features and events without host data", func(t *testing.T) {
+		c := New("install")
+		bus := events.NewBus()
+		unsubscribe := c.Subscribe(bus)
+		defer unsubscribe()
+
+		c.Count("exec.saved")
+		c.Count("exec.saved")
+		c.Count("web01 deploy") // free text is ignored
+		bus.Publish(events.Event{Type: events.ExecFinished, HostID: "web01", Command: "uptime"})
+
+		report := c.Snapshot()
+		assert.Equal(t, map[string]int{"exec.saved": 2}, report.Features)
+		assert.Equal(t, map[string]int{"exec_finished": 1}, report.Events)
+
+		preview, err := c.Preview()
+		require.NoError(t, err)
+		assert.NotContains(t, string(preview), "web01")
+		assert.NotContains(t, string(preview), "uptime")
+	})
+
+	t.Run("records crashes without the message", func(t *testing.T) {
+		c := New("install")
+		func() {
+			defer func() { _ = recover() }()
+			defer c.Recover()
+			panic("failed on web01")
+		}()
+
+		report := c.Snapshot()
+		require.Len(t, report.Crashes, 1)
+		assert.Equal(t, "string", report.Crashes[0].Type)
+		require.NotEmpty(t, report.Crashes[0].Stack)
+		assert.Contains(t, report.Crashes[0].Stack[0], "TestCollector")
+
+		preview, err := c.Preview()
+		require.NoError(t, err)
+		assert.NotContains(t, string(preview), "web01")
+		assert.NotContains(t, string(preview), ".go:")
+	})
+}
+
+func TestSend(t *testing.T) {
+	t.Run("does nothing unless enabled", func(t *testing.T) {
+		c := New("install")
+		err := c.Send(context.Background(), inventory.TelemetryConfig{Endpoint: "http://127.0.0.1:1"})
+		assert.ErrorIs(t, err, ErrDisabled)
+	})
+
+	t.Run("posts the report and starts a new period", func(t *testing.T) {
+		var received Report
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			body, _ := io.ReadAll(r.Body)
+			assert.NoError(t, json.Unmarshal(body, &received))
+		}))
+		defer srv.Close()
+
+		c := New("install")
+		c.Count("init")
+		require.NoError(t, c.Send(context.Background(), inventory.TelemetryConfig{Enabled: true, Endpoint: srv.URL}))
+
+		assert.Equal(t, SchemaVersion, received.Schema)
+		assert.Equal(t, "install", received.InstallID)
+		assert.Equal(t, map[string]int{"init": 1}, received.Features)
+		assert.Empty(t, c.Snapshot().Features)
+	})
+
+	t.Run("keeps the counts when sending fails", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+
+		c := New("install")
+		c.Count("init")
+		err := c.Send(context.Background(), inventory.TelemetryConfig{Enabled: true, Endpoint: srv.URL})
+		assert.ErrorContains(t, err, "503")
+		assert.Equal(t, map[string]int{"init": 1}, c.Snapshot().Features)
+	})
+}
+
+func TestLoadInstallID(t *testing.T) {
+	dir := t.TempDir()
+	id, err := LoadInstallID(dir)
+	require.NoError(t, err)
+	assert.Len(t, id, 32)
+
+	again, err := LoadInstallID(dir)
+	require.NoError(t, err)
+	assert.Equal(t, id, again)
+
+	data, err := os.ReadFile(filepath.Join(dir, InstallIDFile))
+	require.NoError(t, err)
+	assert.Equal(t, id, strings.TrimSpace(string(data)))
+}
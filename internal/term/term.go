@@ -0,0 +1,142 @@
+// Package term adapts output to the terminal and to the user's accessibility
+// needs. Two switches control it:
+//
+//   - Plain mode (Config.PlainOutput, or TERM=dumb) is for screen readers and
+//     simple terminals: tables are not padded into columns, colors, spinners and
+//     redrawn lines are off, and front ends fall back from the full-screen TUI to
+//     line-based prompts.
+//   - Color is additionally disabled when NO_COLOR is set (https://no-color.org)
+//     or the output is not a terminal.
+package term
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+var (
+	mu    sync.RWMutex
+	plain bool
+)
+
+// getenv reads the environment; replaced in tests.
+var getenv = os.Getenv
+
+// SetPlain switches plain mode on or off, e.g. from inventory.GetPlainOutput at
+// startup.
+func SetPlain(on bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	plain = on
+}
+
+// Plain reports whether output should be plain: set with SetPlain or requested by
+// TERM=dumb.
+func Plain() bool {
+	mu.RLock()
+	on := plain
+	mu.RUnlock()
+	return on || getenv("TERM") == "dumb"
+}
+
+// NoColor reports whether the NO_COLOR environment variable asks for no color.
+// Any non-empty value counts.
+func NoColor() bool {
+	return getenv("NO_COLOR") != ""
+}
+
+// ColorEnabled reports whether w gets colored output: plain mode and NO_COLOR are
+// off and w is a terminal.
+func ColorEnabled(w io.Writer) bool {
+	if Plain() || NoColor() {
+		return false
+	}
+	return IsTerminal(w)
+}
+
+// IsTerminal reports whether w is a character device such as a terminal.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// ===== Color =====
+
+// Paint wraps text in the escape codes of color when w gets colored output, and
+// returns text unchanged otherwise. The color is "#rrggbb" or "#rgb" for true
+// color or "0"-"255" for an ANSI palette index, as in inventory.Color; an empty
+// or invalid color leaves text unchanged.
+func Paint(w io.Writer, color, text string) string {
+	if !ColorEnabled(w) {
+		return text
+	}
+	return paint(color, text)
+}
+
+// paint wraps text in the escape codes of color unconditionally.
+func paint(color, text string) string {
+	code, ok := sgr(color)
+	if !ok || text == "" {
+		return text
+	}
+	return "\x1b[" + code + "m" + text + "\x1b[0m"
+}
+
+// sgr returns the foreground Select Graphic Rendition parameters of a color.
+func sgr(color string) (string, bool) {
+	if hex, ok := strings.CutPrefix(color, "#"); ok {
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) != 6 {
+			return "", false
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return "", false
+		}
+		return fmt.Sprintf("38;2;%d;%d;%d", v>>16, v>>8&0xff, v&0xff), true
+	}
+	n, err := strconv.Atoi(color)
+	if err != nil || n < 0 || n > 255 {
+		return "", false
+	}
+	return fmt.Sprintf("38;5;%d", n), true
+}
+
+// ===== Tables =====
+
+// Table writes rows of tab-separated cells.
+type Table interface {
+	io.Writer
+	// Flush writes buffered rows; call it once after the last row.
+	Flush() error
+}
+
+// NewTable returns a table writer for w. Normally cells are padded into aligned
+// columns. In plain mode cells stay separated by a single tab, which screen
+// readers announce once instead of reading runs of padding.
+func NewTable(w io.Writer) Table {
+	if Plain() {
+		return plainTable{w}
+	}
+	return tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+}
+
+// plainTable passes rows through unchanged.
+type plainTable struct {
+	io.Writer
+}
+
+func (plainTable) Flush() error {
+	return nil
+}
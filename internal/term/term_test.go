@@ -0,0 +1,78 @@
+package term
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setEnv replaces the environment seen by the package for one test.
+func setEnv(t *testing.T, env map[string]string) {
+	t.Cleanup(func() { getenv = os.Getenv })
+	getenv = func(key string) string { return env[key] }
+}
+
+func TestModes(t *testing.T) {
+	t.Cleanup(func() { SetPlain(false) })
+
+	t.Run("NO_COLOR disables color only", func(t *testing.T) {
+		setEnv(t, map[string]string{"NO_COLOR": "1"})
+		assert.True(t, NoColor())
+		assert.False(t, Plain())
+		assert.False(t, ColorEnabled(os.Stdout))
+	})
+
+	t.Run("TERM=dumb is plain", func(t *testing.T) {
+		setEnv(t, map[string]string{"TERM": "dumb"})
+		assert.True(t, Plain())
+	})
+
+	t.Run("SetPlain", func(t *testing.T) {
+		setEnv(t, nil)
+		SetPlain(true)
+		assert.True(t, Plain())
+		SetPlain(false)
+		assert.False(t, Plain())
+	})
+
+	t.Run("buffers are not terminals", func(t *testing.T) {
+		setEnv(t, nil)
+		assert.False(t, ColorEnabled(&bytes.Buffer{}))
+		assert.Equal(t, "online", Paint(&bytes.Buffer{}, "#00ff00", "online"))
+	})
+}
+
+func TestPaint(t *testing.T) {
+	for color, want := range map[string]string{
+		"#1a7f37": "\x1b[38;2;26;127;55mok\x1b[0m",
+		"#0f0":    "\x1b[38;2;0;255;0mok\x1b[0m",
+		"9":       "\x1b[38;5;9mok\x1b[0m",
+		"":        "ok",
+		"red":     "ok",
+		"256":     "ok",
+	} {
+		assert.Equal(t, want, paint(color, "ok"), color)
+	}
+}
+
+func TestNewTable(t *testing.T) {
+	t.Cleanup(func() { SetPlain(false) })
+	setEnv(t, nil)
+
+	render := func() string {
+		var buf bytes.Buffer
+		tw := NewTable(&buf)
+		fmt.Fprintf(tw, "HOST\tSTATUS\n")
+		fmt.Fprintf(tw, "web01.example.com\tok\n")
+		require.NoError(t, tw.Flush())
+		return buf.String()
+	}
+
+	assert.Equal(t, "HOST               STATUS\nweb01.example.com  ok\n", render())
+	SetPlain(true)
+	assert.Equal(t, "HOST\tSTATUS\nweb01.example.com\tok\n", render())
+}
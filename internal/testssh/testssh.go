@@ -0,0 +1,657 @@
+// Package testssh provides SSH servers for integration tests of the connection,
+// exec and transfer features, without Docker or real hosts.
+//
+// Each Server speaks the SSH protocol of golang.org/x/crypto/ssh on a loopback
+// listener with its own host key. It authenticates users by password or by key,
+// runs exec requests with registered handlers, serves the sftp subsystem from a
+// root directory on disk and forwards direct-tcpip channels, the way jump hosts
+// are traversed.
+//
+// A Network maps the addresses of the inventory to its servers: it is a
+// transport.Dialer that connects with transport.SSHDialer, trusting the host
+// keys of its servers, and implements the executor's Runner, Uploader and
+// Downloader interfaces.
+package testssh
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gossher/internal/inventory"
+	"gossher/internal/transport"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Ensure Network implements the interface
+var _ transport.Dialer = (*Network)(nil)
+
+var (
+	// ErrUnreachable is returned when no server listens at the address of a host.
+	ErrUnreachable = errors.New("connection refused")
+	// ErrAuthFailed is returned when a server rejects the user's key and password.
+	ErrAuthFailed = transport.ErrAuthFailed
+)
+
+// Auth methods recorded in requests.
+const (
+	AuthPassword  = "password"
+	AuthPublicKey = "publickey"
+)
+
+// Request kinds recorded by servers.
+const (
+	RequestExec     = "exec"
+	RequestUpload   = "sftp-put"
+	RequestDownload = "sftp-get"
+)
+
+// GenerateKey returns a new ed25519 private key in the OpenSSH PEM format, for
+// AuthorizeKey and the key files of test hosts.
+func GenerateKey() ([]byte, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+// ===== Network =====
+
+// Network connects to the servers registered on it.
+type Network struct {
+	mu      sync.RWMutex
+	servers map[string]*Server
+}
+
+// NewNetwork creates a network without servers.
+func NewNetwork() *Network {
+	return &Network{servers: make(map[string]*Server)}
+}
+
+// NewServer starts a server for address:port that keeps its files under root.
+// A port of 0 means 22, like in the inventory.
+func (n *Network) NewServer(address string, port int, root string) (*Server, error) {
+	addr := joinAddr(address, port)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, exists := n.servers[addr]; exists {
+		return nil, fmt.Errorf("server %s already exists", addr)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create server root: %w", err)
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate host key: %w", err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create host key: %w", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	s := &Server{
+		Addr:     addr,
+		network:  n,
+		listener: l,
+		hostKey:  hostKey,
+		root:     root,
+		users:    make(map[string]*user),
+		handlers: make(map[string]Handler),
+	}
+	s.config = &ssh.ServerConfig{PasswordCallback: s.checkPassword, PublicKeyCallback: s.checkKey}
+	s.config.AddHostKey(hostKey)
+	n.servers[addr] = s
+	go s.serve()
+	return s, nil
+}
+
+// Server returns the server at address:port.
+func (n *Network) Server(address string, port int) (*Server, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	s, ok := n.servers[joinAddr(address, port)]
+	return s, ok
+}
+
+// Close stops all servers.
+func (n *Network) Close() error {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	for _, s := range n.servers {
+		s.listener.Close()
+	}
+	return nil
+}
+
+// dialNet connects to the listener of the server at address, an address:port of
+// the inventory.
+func (n *Network) dialNet(ctx context.Context, network, address string) (net.Conn, error) {
+	n.mu.RLock()
+	s, ok := n.servers[address]
+	n.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", address, ErrUnreachable)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, s.listener.Addr().String())
+}
+
+// checkHostKey accepts the host keys of the servers at their address.
+func (n *Network) checkHostKey(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	n.mu.RLock()
+	s, ok := n.servers[hostname]
+	n.mu.RUnlock()
+	if !ok || !bytes.Equal(s.hostKey.PublicKey().Marshal(), key.Marshal()) {
+		return fmt.Errorf("unknown host key for %s", hostname)
+	}
+	return nil
+}
+
+// Dialer returns the SSH client of the network.
+func (n *Network) Dialer() transport.SSHDialer {
+	return transport.SSHDialer{DialNet: n.dialNet, HostKeyCallback: n.checkHostKey}
+}
+
+// Dial connects to the server of conn through its jump hosts.
+func (n *Network) Dial(ctx context.Context, conn *inventory.ResolvedConnection) (transport.Session, error) {
+	return n.Dialer().Dial(ctx, conn)
+}
+
+// Run runs command on the server of conn.
+func (n *Network) Run(ctx context.Context, conn *inventory.ResolvedConnection, command string, stdout, stderr io.Writer) (int, error) {
+	return transport.Runner{Dialer: n}.Run(ctx, conn, command, stdout, stderr)
+}
+
+// Upload writes src to remotePath on the server of conn.
+func (n *Network) Upload(ctx context.Context, conn *inventory.ResolvedConnection, src io.Reader, remotePath string, mode os.FileMode) error {
+	return transport.Runner{Dialer: n}.Upload(ctx, conn, src, remotePath, mode)
+}
+
+// Download copies remotePath on the server of conn to dst.
+func (n *Network) Download(ctx context.Context, conn *inventory.ResolvedConnection, remotePath string, dst io.Writer) error {
+	return transport.Runner{Dialer: n}.Download(ctx, conn, remotePath, dst)
+}
+
+// ===== Server =====
+
+// Server is an SSH server of a Network.
+type Server struct {
+	// Addr is the address:port of the inventory the server stands for.
+	Addr string
+
+	network  *Network
+	listener net.Listener
+	hostKey  ssh.Signer
+	config   *ssh.ServerConfig
+
+	mu       sync.Mutex
+	root     string
+	users    map[string]*user
+	handlers map[string]Handler
+	requests []Request
+}
+
+type user struct {
+	password string
+	keys     []ssh.PublicKey
+}
+
+// Request is a request a server handled, for assertions.
+type Request struct {
+	User string
+	// Auth is the method the user logged in with, AuthPassword or AuthPublicKey.
+	Auth string
+	// Kind is RequestExec, RequestUpload or RequestDownload.
+	Kind string
+	// Command is the command of exec requests, Path the absolute remote path of
+	// transfers.
+	Command  string
+	Path     string
+	ExitCode int
+}
+
+// HostKey returns the public key the server identifies with.
+func (s *Server) HostKey() ssh.PublicKey {
+	return s.hostKey.PublicKey()
+}
+
+// ListenAddr returns the loopback address the server listens at.
+func (s *Server) ListenAddr() string {
+	return s.listener.Addr().String()
+}
+
+// AddUser creates a user that logs in with password; an empty password only
+// allows keys.
+func (s *Server) AddUser(name, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[name]; ok {
+		u.password = password
+		return
+	}
+	s.users[name] = &user{password: password}
+}
+
+// AuthorizeKey lets a user log in with a key, given as the PEM of the private key
+// or as an authorized_keys line. The user is created if needed.
+func (s *Server) AuthorizeKey(name string, key []byte) error {
+	var pub ssh.PublicKey
+	if signer, err := ssh.ParsePrivateKey(key); err == nil {
+		pub = signer.PublicKey()
+	} else if pub, _, _, _, err = ssh.ParseAuthorizedKey(key); err != nil {
+		return fmt.Errorf("failed to parse key: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[name]
+	if !ok {
+		u = &user{}
+		s.users[name] = u
+	}
+	u.keys = append(u.keys, pub)
+	return nil
+}
+
+func (s *Server) checkPassword(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[meta.User()]; ok && u.password != "" && string(password) == u.password {
+		return &ssh.Permissions{Extensions: map[string]string{"auth": AuthPassword}}, nil
+	}
+	return nil, fmt.Errorf("%s@%s: %w", meta.User(), s.Addr, ErrAuthFailed)
+}
+
+func (s *Server) checkKey(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[meta.User()]; ok {
+		for _, k := range u.keys {
+			if bytes.Equal(k.Marshal(), key.Marshal()) {
+				return &ssh.Permissions{Extensions: map[string]string{"auth": AuthPublicKey}}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s@%s: %w", meta.User(), s.Addr, ErrAuthFailed)
+}
+
+// Requests returns the requests handled so far, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) record(r Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+}
+
+// Path returns the file on disk that holds remotePath for a user. Relative paths
+// and "~" are relative to /home/<user>.
+func (s *Server) Path(name, remotePath string) string {
+	switch {
+	case remotePath == "~":
+		remotePath = "/home/" + name
+	case strings.HasPrefix(remotePath, "~/"):
+		remotePath = "/home/" + name + remotePath[1:]
+	case !strings.HasPrefix(remotePath, "/"):
+		remotePath = "/home/" + name + "/" + remotePath
+	}
+	return filepath.Join(s.root, filepath.FromSlash(path.Clean(remotePath)))
+}
+
+// ReadFile returns the content of a remote file; relative paths are relative to
+// /home/root.
+func (s *Server) ReadFile(remotePath string) ([]byte, error) {
+	return os.ReadFile(s.Path("root", remotePath))
+}
+
+// WriteFile creates a remote file, with its parent directories.
+func (s *Server) WriteFile(remotePath string, data []byte, mode os.FileMode) error {
+	p := s.Path("root", remotePath)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", path.Dir(remotePath), err)
+	}
+	return os.WriteFile(p, data, mode)
+}
+
+// serve accepts connections until the listener is closed.
+func (s *Server) serve() {
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handleConn(c)
+	}
+}
+
+// handleConn runs the handshake and serves the channels of a connection.
+func (s *Server) handleConn(c net.Conn) {
+	sc, chans, reqs, err := ssh.NewServerConn(c, s.config)
+	if err != nil {
+		c.Close()
+		return
+	}
+	defer sc.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for newCh := range chans {
+		switch newCh.ChannelType() {
+		case "session":
+			go s.handleSession(sc, newCh)
+		case "direct-tcpip":
+			go s.forward(newCh)
+		default:
+			newCh.Reject(ssh.UnknownChannelType, "unsupported channel type")
+		}
+	}
+}
+
+// handleSession serves the exec request or the sftp subsystem of a session.
+func (s *Server) handleSession(sc *ssh.ServerConn, newCh ssh.NewChannel) {
+	ch, reqs, err := newCh.Accept()
+	if err != nil {
+		return
+	}
+	defer ch.Close()
+	name, auth := sc.User(), sc.Permissions.Extensions["auth"]
+
+	for req := range reqs {
+		switch req.Type {
+		case "exec":
+			var p struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &p); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+
+			// The handler stops when the client closes the session.
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				ssh.DiscardRequests(reqs)
+				cancel()
+			}()
+			code := s.exec(ctx, name, auth, p.Command, ch, ch.Stderr())
+			cancel()
+			ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(code)}))
+			return
+		case "subsystem":
+			var p struct{ Name string }
+			if err := ssh.Unmarshal(req.Payload, &p); err != nil || p.Name != "sftp" {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			go ssh.DiscardRequests(reqs)
+
+			h := &fileHandler{server: s, user: name, auth: auth}
+			handlers := sftp.Handlers{FileGet: h, FilePut: h, FileCmd: h, FileList: h}
+			srv := sftp.NewRequestServer(ch, handlers, sftp.WithStartDirectory("/home/"+name))
+			srv.Serve()
+			srv.Close()
+			return
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+// forward connects a direct-tcpip channel to the server at its destination.
+func (s *Server) forward(newCh ssh.NewChannel) {
+	var p struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newCh.ExtraData(), &p); err != nil {
+		newCh.Reject(ssh.ConnectionFailed, "invalid direct-tcpip request")
+		return
+	}
+	c, err := s.network.dialNet(context.Background(), "tcp", joinAddr(p.Host, int(p.Port)))
+	if err != nil {
+		newCh.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := newCh.Accept()
+	if err != nil {
+		c.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(ch, c)
+		ch.CloseWrite()
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(c, ch)
+		c.(*net.TCPConn).CloseWrite()
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+	ch.Close()
+	c.Close()
+}
+
+// ===== Exec =====
+
+// Session is an exec request passed to a Handler.
+type Session struct {
+	Ctx     context.Context
+	Server  *Server
+	User    string
+	Command string
+	// Args are the whitespace-separated words of Command; quoting is not supported.
+	Args   []string
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Path returns the file on disk that holds remotePath for the session's user.
+func (s *Session) Path(remotePath string) string {
+	return s.Server.Path(s.User, remotePath)
+}
+
+// Handler runs the commands named by Args[0] and returns the exit code.
+type Handler func(s *Session) int
+
+// Handle registers the handler of a command name, replacing a built-in one. The
+// built-in commands are true, false, echo, whoami, hostname and cat.
+func (s *Server) Handle(name string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[name] = h
+}
+
+// exec runs a command with its handler and returns its exit code. Unknown
+// commands exit with 127 like in a shell.
+func (s *Server) exec(ctx context.Context, name, auth, command string, stdout, stderr io.Writer) int {
+	sess := &Session{Ctx: ctx, Server: s, User: name, Command: command, Args: strings.Fields(command), Stdout: stdout, Stderr: stderr}
+
+	code := 0
+	if len(sess.Args) > 0 {
+		s.mu.Lock()
+		h, ok := s.handlers[sess.Args[0]]
+		s.mu.Unlock()
+		if !ok {
+			h, ok = builtins[sess.Args[0]]
+		}
+		if ok {
+			code = h(sess)
+		} else {
+			fmt.Fprintf(stderr, "sh: %s: command not found\n", sess.Args[0])
+			code = 127
+		}
+	}
+	s.record(Request{User: name, Auth: auth, Kind: RequestExec, Command: command, ExitCode: code})
+	return code
+}
+
+var builtins = map[string]Handler{
+	"true":  func(*Session) int { return 0 },
+	"false": func(*Session) int { return 1 },
+	"echo": func(s *Session) int {
+		fmt.Fprintln(s.Stdout, strings.Join(s.Args[1:], " "))
+		return 0
+	},
+	"whoami": func(s *Session) int {
+		fmt.Fprintln(s.Stdout, s.User)
+		return 0
+	},
+	"hostname": func(s *Session) int {
+		host, _, _ := net.SplitHostPort(s.Server.Addr)
+		fmt.Fprintln(s.Stdout, host)
+		return 0
+	},
+	"cat": func(s *Session) int {
+		code := 0
+		for _, p := range s.Args[1:] {
+			data, err := os.ReadFile(s.Path(p))
+			if err != nil {
+				fmt.Fprintf(s.Stderr, "cat: %s: No such file or directory\n", p)
+				code = 1
+				continue
+			}
+			s.Stdout.Write(data)
+		}
+		return code
+	},
+}
+
+// ===== SFTP =====
+
+// fileHandler serves the sftp requests of a user from the server's root.
+// Uploads create the parent directories of the file.
+type fileHandler struct {
+	server *Server
+	user   string
+	auth   string
+}
+
+func (h *fileHandler) path(r *sftp.Request) string {
+	return h.server.Path(h.user, r.Filepath)
+}
+
+func (h *fileHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	h.server.record(Request{User: h.user, Auth: h.auth, Kind: RequestDownload, Path: r.Filepath})
+	return os.Open(h.path(r))
+}
+
+func (h *fileHandler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	h.server.record(Request{User: h.user, Auth: h.auth, Kind: RequestUpload, Path: r.Filepath})
+	p := h.path(r)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return nil, err
+	}
+	flags := os.O_WRONLY | os.O_CREATE
+	if r.Pflags().Trunc {
+		flags |= os.O_TRUNC
+	}
+	return os.OpenFile(p, flags, 0o644)
+}
+
+func (h *fileHandler) Filecmd(r *sftp.Request) error {
+	p := h.path(r)
+	switch r.Method {
+	case "Setstat":
+		if r.AttrFlags().Permissions {
+			return os.Chmod(p, r.Attributes().FileMode().Perm())
+		}
+		return nil
+	case "Rename":
+		return os.Rename(p, h.server.Path(h.user, r.Target))
+	case "Remove", "Rmdir":
+		return os.Remove(p)
+	case "Mkdir":
+		return os.Mkdir(p, 0o755)
+	}
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+func (h *fileHandler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	p := h.path(r)
+	switch r.Method {
+	case "Stat":
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{info}, nil
+	case "List":
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+		infos := make(listerAt, 0, len(entries))
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil {
+				infos = append(infos, info)
+			}
+		}
+		return infos, nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+// listerAt lists a fixed set of files.
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(ls []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(ls, l[offset:])
+	if n < len(ls) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// ===== Helpers =====
+
+// joinAddr returns address:port, with port 22 when unset like in the inventory.
+func joinAddr(address string, port int) string {
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(address, strconv.Itoa(port))
+}
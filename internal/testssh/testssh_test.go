@@ -0,0 +1,288 @@
+package testssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/transport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func setupNetwork(t *testing.T) (*Network, *Server) {
+	n := NewNetwork()
+	s, err := n.NewServer("10.0.0.1", 0, t.TempDir())
+	require.NoError(t, err)
+	s.AddUser("deploy", "hunter22")
+	t.Cleanup(func() { n.Close() })
+	return n, s
+}
+
+func TestAuth(t *testing.T) {
+	t.Run("password", func(t *testing.T) {
+		n, s := setupNetwork(t)
+		var out bytes.Buffer
+		code, err := n.Run(context.Background(), &inventory.ResolvedConnection{HostID: "web01", Address: "10.0.0.1", Port: 22, User: "deploy", Password: "hunter22"}, "whoami", &out, &out)
+		require.NoError(t, err)
+		assert.Equal(t, 0, code)
+		assert.Equal(t, "deploy\n", out.String())
+		assert.Equal(t, AuthPassword, s.Requests()[0].Auth)
+	})
+
+	t.Run("key file before password", func(t *testing.T) {
+		n, s := setupNetwork(t)
+		key, err := GenerateKey()
+		require.NoError(t, err)
+		require.NoError(t, s.AuthorizeKey("deploy", key))
+		keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+		require.NoError(t, os.WriteFile(keyPath, key, 0o600))
+
+		_, err = n.Run(context.Background(), &inventory.ResolvedConnection{Address: "10.0.0.1", User: "deploy", KeyPath: keyPath, Password: "hunter22"}, "true", &bytes.Buffer{}, &bytes.Buffer{})
+		require.NoError(t, err)
+		assert.Equal(t, AuthPublicKey, s.Requests()[0].Auth)
+	})
+
+	t.Run("rejects wrong credentials", func(t *testing.T) {
+		n, s := setupNetwork(t)
+		_, err := n.Run(context.Background(), &inventory.ResolvedConnection{Address: "10.0.0.1", User: "deploy", Password: "wrong"}, "true", &bytes.Buffer{}, &bytes.Buffer{})
+		assert.ErrorIs(t, err, ErrAuthFailed)
+
+		key, err := GenerateKey()
+		require.NoError(t, err)
+		_, err = n.Run(context.Background(), &inventory.ResolvedConnection{Address: "10.0.0.1", User: "nobody", PrivateKey: string(key)}, "true", &bytes.Buffer{}, &bytes.Buffer{})
+		assert.ErrorIs(t, err, ErrAuthFailed)
+		assert.Empty(t, s.Requests())
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		n, _ := setupNetwork(t)
+		_, err := n.Run(context.Background(), &inventory.ResolvedConnection{Address: "10.0.0.1", Port: 2222, User: "deploy", Password: "hunter22"}, "true", &bytes.Buffer{}, &bytes.Buffer{})
+		assert.ErrorIs(t, err, ErrUnreachable)
+	})
+
+	t.Run("jump hosts", func(t *testing.T) {
+		n, s := setupNetwork(t)
+		bastion, err := n.NewServer("bastion.example.com", 2222, t.TempDir())
+		require.NoError(t, err)
+		bastion.AddUser("jump", "secret")
+
+		conn := &inventory.ResolvedConnection{Address: "10.0.0.1", User: "deploy", Password: "hunter22",
+			Jumps: []*inventory.ResolvedConnection{{HostID: "bastion", Address: "bastion.example.com", Port: 2222, User: "jump", Password: "secret"}}}
+		_, err = n.Run(context.Background(), conn, "true", &bytes.Buffer{}, &bytes.Buffer{})
+		require.NoError(t, err)
+		assert.Len(t, s.Requests(), 1)
+
+		conn.Jumps[0].Password = "wrong"
+		_, err = n.Run(context.Background(), conn, "true", &bytes.Buffer{}, &bytes.Buffer{})
+		assert.ErrorIs(t, err, ErrAuthFailed)
+		assert.Contains(t, err.Error(), "jump host bastion")
+	})
+}
+
+func TestServer(t *testing.T) {
+	t.Run("speaks SSH on its listener", func(t *testing.T) {
+		_, s := setupNetwork(t)
+		client, err := ssh.Dial("tcp", s.ListenAddr(), &ssh.ClientConfig{
+			User:            "deploy",
+			Auth:            []ssh.AuthMethod{ssh.Password("hunter22")},
+			HostKeyCallback: ssh.FixedHostKey(s.HostKey()),
+		})
+		require.NoError(t, err)
+		defer client.Close()
+
+		sess, err := client.NewSession()
+		require.NoError(t, err)
+		defer sess.Close()
+		out, err := sess.Output("whoami")
+		require.NoError(t, err)
+		assert.Equal(t, "deploy\n", string(out))
+	})
+
+	t.Run("rejects unknown host keys", func(t *testing.T) {
+		n, s := setupNetwork(t)
+		d := n.Dialer()
+		d.HostKeyCallback = func(string, net.Addr, ssh.PublicKey) error { return errors.New("host key mismatch") }
+		_, err := d.Dial(context.Background(), &inventory.ResolvedConnection{HostID: "web01", Address: "10.0.0.1", User: "deploy", Password: "hunter22"})
+		assert.ErrorContains(t, err, "host key mismatch")
+		assert.Empty(t, s.Requests())
+	})
+}
+
+func TestHostKeys(t *testing.T) {
+	conn := func() *inventory.ResolvedConnection {
+		return &inventory.ResolvedConnection{HostID: "web01", Address: "10.0.0.1", User: "deploy", Password: "hunter22"}
+	}
+
+	t.Run("pinned keys", func(t *testing.T) {
+		n, s := setupNetwork(t)
+		c := conn()
+		c.HostKeys = []inventory.HostKey{{KeyType: "ssh-ed25519", Fingerprint: ssh.FingerprintSHA256(s.HostKey())}}
+		_, err := n.Run(context.Background(), c, "true", &bytes.Buffer{}, &bytes.Buffer{})
+		require.NoError(t, err)
+
+		c.HostKeys = []inventory.HostKey{{KeyType: "ssh-ed25519", Fingerprint: "SHA256:other"}}
+		_, err = n.Run(context.Background(), c, "true", &bytes.Buffer{}, &bytes.Buffer{})
+		assert.ErrorIs(t, err, transport.ErrHostKeyMismatch, "pins take precedence over the keys the network trusts")
+		assert.ErrorContains(t, err, "web01 presented ssh-ed25519 key "+ssh.FingerprintSHA256(s.HostKey()))
+		assert.Len(t, s.Requests(), 1)
+	})
+
+	t.Run("pinned keys of jump hosts", func(t *testing.T) {
+		n, s := setupNetwork(t)
+		bastion, err := n.NewServer("bastion.example.com", 22, t.TempDir())
+		require.NoError(t, err)
+		bastion.AddUser("jump", "secret")
+
+		c := conn()
+		c.Jumps = []*inventory.ResolvedConnection{{HostID: "bastion", Address: "bastion.example.com", User: "jump", Password: "secret",
+			HostKeys: []inventory.HostKey{{KeyType: "ssh-ed25519", Fingerprint: ssh.FingerprintSHA256(s.HostKey())}}}}
+		_, err = n.Run(context.Background(), c, "true", &bytes.Buffer{}, &bytes.Buffer{})
+		assert.ErrorIs(t, err, transport.ErrHostKeyMismatch)
+		assert.ErrorContains(t, err, "jump host bastion")
+		assert.Empty(t, s.Requests())
+	})
+
+	t.Run("known_hosts without pins", func(t *testing.T) {
+		n, s := setupNetwork(t)
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		d := n.Dialer()
+		d.HostKeyCallback = nil
+
+		_, err := d.Dial(context.Background(), conn())
+		assert.ErrorContains(t, err, "known_hosts cannot be read")
+
+		require.NoError(t, os.MkdirAll(filepath.Join(home, ".ssh"), 0o700))
+		line := knownhosts.Line([]string{"10.0.0.1"}, s.HostKey())
+		require.NoError(t, os.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), []byte(line+"\n"), 0o600))
+		sess, err := d.Dial(context.Background(), conn())
+		require.NoError(t, err)
+		require.NoError(t, sess.Close())
+	})
+}
+
+func TestExec(t *testing.T) {
+	conn := &inventory.ResolvedConnection{Address: "10.0.0.1", User: "deploy", Password: "hunter22"}
+
+	t.Run("handlers", func(t *testing.T) {
+		n, s := setupNetwork(t)
+		s.Handle("uptime", func(sess *Session) int {
+			sess.Stdout.Write([]byte("up 3 days"))
+			return 0
+		})
+		s.Handle("false", func(sess *Session) int { return 3 })
+
+		var stdout, stderr bytes.Buffer
+		code, err := n.Run(context.Background(), conn, "uptime", &stdout, &stderr)
+		require.NoError(t, err)
+		assert.Equal(t, 0, code)
+		assert.Equal(t, "up 3 days", stdout.String())
+
+		code, err = n.Run(context.Background(), conn, "false", &stdout, &stderr)
+		require.NoError(t, err)
+		assert.Equal(t, 3, code)
+
+		code, err = n.Run(context.Background(), conn, "reboot now", &stdout, &stderr)
+		require.NoError(t, err)
+		assert.Equal(t, 127, code)
+		assert.Contains(t, stderr.String(), "reboot: command not found")
+
+		reqs := s.Requests()
+		require.Len(t, reqs, 3)
+		assert.Equal(t, Request{User: "deploy", Auth: AuthPassword, Kind: RequestExec, Command: "reboot now", ExitCode: 127}, reqs[2])
+	})
+
+	t.Run("builtins", func(t *testing.T) {
+		n, s := setupNetwork(t)
+		require.NoError(t, s.WriteFile("/etc/motd", []byte("hello\n"), 0o644))
+
+		var stdout, stderr bytes.Buffer
+		_, err := n.Run(context.Background(), conn, "echo a b", &stdout, &stderr)
+		require.NoError(t, err)
+		_, err = n.Run(context.Background(), conn, "hostname", &stdout, &stderr)
+		require.NoError(t, err)
+		_, err = n.Run(context.Background(), conn, "cat /etc/motd", &stdout, &stderr)
+		require.NoError(t, err)
+		assert.Equal(t, "a b\n10.0.0.1\nhello\n", stdout.String())
+
+		code, err := n.Run(context.Background(), conn, "cat /missing", &stdout, &stderr)
+		require.NoError(t, err)
+		assert.Equal(t, 1, code)
+	})
+
+	t.Run("stops handlers when the client leaves", func(t *testing.T) {
+		n, s := setupNetwork(t)
+		s.Handle("sleep", func(sess *Session) int {
+			<-sess.Ctx.Done()
+			return 1
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		code, err := n.Run(ctx, conn, "sleep", &bytes.Buffer{}, &bytes.Buffer{})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, -1, code)
+		assert.Eventually(t, func() bool { return len(s.Requests()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		n, _ := setupNetwork(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		code, err := n.Run(ctx, conn, "true", &bytes.Buffer{}, &bytes.Buffer{})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, -1, code)
+	})
+}
+
+func TestTransfer(t *testing.T) {
+	conn := &inventory.ResolvedConnection{Address: "10.0.0.1", User: "deploy", Password: "hunter22"}
+
+	t.Run("upload and download", func(t *testing.T) {
+		n, s := setupNetwork(t)
+		require.NoError(t, n.Upload(context.Background(), conn, strings.NewReader("payload"), "/srv/app.txt", 0o640))
+
+		data, err := s.ReadFile("/srv/app.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "payload", string(data))
+		info, err := os.Stat(s.Path("deploy", "/srv/app.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+
+		var out bytes.Buffer
+		require.NoError(t, n.Download(context.Background(), conn, "/srv/app.txt", &out))
+		assert.Equal(t, "payload", out.String())
+
+		reqs := s.Requests()
+		require.Len(t, reqs, 2)
+		assert.Equal(t, RequestUpload, reqs[0].Kind)
+		assert.Equal(t, RequestDownload, reqs[1].Kind)
+	})
+
+	t.Run("home directory", func(t *testing.T) {
+		n, s := setupNetwork(t)
+		require.NoError(t, n.Upload(context.Background(), conn, strings.NewReader("x"), "notes.txt", 0o600))
+		assert.Equal(t, s.Path("deploy", "~/notes.txt"), s.Path("root", "/home/deploy/notes.txt"))
+		assert.FileExists(t, s.Path("deploy", "~/notes.txt"))
+	})
+
+	t.Run("paths stay under the root", func(t *testing.T) {
+		_, s := setupNetwork(t)
+		assert.Equal(t, s.Path("deploy", "/etc/passwd"), s.Path("deploy", "/../../etc/passwd"))
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		n, _ := setupNetwork(t)
+		assert.Error(t, n.Download(context.Background(), conn, "/missing", &bytes.Buffer{}))
+	})
+}
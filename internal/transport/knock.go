@@ -0,0 +1,84 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"gossher/internal/inventory"
+)
+
+// DefaultKnockTimeout bounds each TCP knock. Knocked ports are normally filtered,
+// so the attempt is expected to time out or be refused.
+const DefaultKnockTimeout = 500 * time.Millisecond
+
+// Ensure KnockDialer implements the interface
+var _ Dialer = KnockDialer{}
+
+// KnockDialer sends the port knocking sequences of a connection before dialing it.
+// The sequences of the jump hosts and of the host are sent in the order the hops
+// are traversed, all from this machine. Of hosts behind a relay host only the hops
+// of the relay are knocked; the agent on the relay reaches the rest.
+type KnockDialer struct {
+	Dialer Dialer
+	// DialNet sends the knocks (default a net.Dialer with DefaultKnockTimeout).
+	DialNet func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Dial knocks and then dials conn with the wrapped Dialer.
+func (d KnockDialer) Dial(ctx context.Context, conn *inventory.ResolvedConnection) (Session, error) {
+	for _, hop := range localHops(conn) {
+		if err := Knock(ctx, hop, d.DialNet); err != nil {
+			return nil, err
+		}
+	}
+	return d.Dialer.Dial(ctx, conn)
+}
+
+// localHops returns the hops of conn reached from this machine, outermost first:
+// its jump hosts and the host itself, or the hops of its relay host.
+func localHops(conn *inventory.ResolvedConnection) []*inventory.ResolvedConnection {
+	if conn.Relay != nil {
+		return localHops(conn.Relay)
+	}
+	return append(append([]*inventory.ResolvedConnection(nil), conn.Jumps...), conn)
+}
+
+// Knock sends the knock sequence of a single hop, waiting the delay of each step
+// after it. TCP knocks only need the SYN to arrive, so failing to connect is not
+// an error; failing to send a UDP knock is.
+func Knock(ctx context.Context, conn *inventory.ResolvedConnection, dial func(ctx context.Context, network, address string) (net.Conn, error)) error {
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: DefaultKnockTimeout}).DialContext
+	}
+
+	for i, step := range conn.Knock {
+		address := net.JoinHostPort(conn.Address, strconv.Itoa(step.Port))
+		c, err := dial(ctx, step.KnockProtocol(), address)
+		switch {
+		case err == nil && step.KnockProtocol() == inventory.KnockUDP:
+			_, err = c.Write([]byte{0})
+			c.Close()
+		case err == nil:
+			c.Close()
+		case step.KnockProtocol() == inventory.KnockTCP && ctx.Err() == nil:
+			err = nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to knock on %s (knock %d of host %s): %w", address, i+1, conn.HostID, err)
+		}
+
+		if step.Delay > 0 {
+			t := time.NewTimer(step.Delay)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return fmt.Errorf("failed to knock on host %s: %w", conn.HostID, ctx.Err())
+			case <-t.C:
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKnockDialer(t *testing.T) {
+	t.Run("knocks every hop in order before dialing", func(t *testing.T) {
+		var knocks []string
+		dialNet := func(ctx context.Context, network, address string) (net.Conn, error) {
+			knocks = append(knocks, network+" "+address)
+			if network == "udp" {
+				client, server := net.Pipe()
+				go func() { _, _ = server.Read(make([]byte, 1)); server.Close() }()
+				return client, nil
+			}
+			return nil, errors.New("connection refused")
+		}
+		dialed := false
+		d := KnockDialer{
+			Dialer: DialerFunc(func(ctx context.Context, conn *inventory.ResolvedConnection) (Session, error) {
+				dialed = true
+				return nil, nil
+			}),
+			DialNet: dialNet,
+		}
+
+		conn := &inventory.ResolvedConnection{
+			HostID: "web01", Address: "10.0.0.1", Port: 22,
+			Knock: []inventory.KnockStep{{Port: 7000}, {Port: 8000, Protocol: "udp", Delay: time.Millisecond}},
+			Jumps: []*inventory.ResolvedConnection{{
+				HostID: "bastion", Address: "192.0.2.1", Port: 22,
+				Knock: []inventory.KnockStep{{Port: 1234}},
+			}},
+		}
+		_, err := d.Dial(context.Background(), conn)
+		require.NoError(t, err)
+		assert.True(t, dialed)
+		assert.Equal(t, []string{"tcp 192.0.2.1:1234", "tcp 10.0.0.1:7000", "udp 10.0.0.1:8000"}, knocks)
+	})
+
+	t.Run("knocks a real listener", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer ln.Close()
+		accepted := make(chan struct{})
+		go func() {
+			if c, err := ln.Accept(); err == nil {
+				c.Close()
+				close(accepted)
+			}
+		}()
+
+		port := ln.Addr().(*net.TCPAddr).Port
+		conn := &inventory.ResolvedConnection{HostID: "local", Address: "127.0.0.1", Knock: []inventory.KnockStep{{Port: port}}}
+		require.NoError(t, Knock(context.Background(), conn, nil))
+		select {
+		case <-accepted:
+		case <-time.After(time.Second):
+			t.Fatal("knock did not arrive")
+		}
+	})
+
+	t.Run("stops when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		conn := &inventory.ResolvedConnection{HostID: "web01", Address: "127.0.0.1", Knock: []inventory.KnockStep{{Port: 1, Delay: time.Hour}}}
+		err := Knock(ctx, conn, nil)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,304 @@
+// Command mockgen generates mocks of the interfaces declared in a Go file. Each
+// mock has a <Method>Func field per method that is called when set, zero values
+// being returned otherwise, and records its calls:
+//
+//	go run ./mockgen -source transport.go -import gossher/internal/transport -out transportmock/mocks.go
+//
+// The mocks go to a package named after the output directory.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	source := flag.String("source", "", "Go file declaring the interfaces")
+	importPath := flag.String("import", "", "import path of the source package")
+	out := flag.String("out", "", "output file")
+	flag.Parse()
+
+	if *source == "" || *importPath == "" || *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	src, err := os.ReadFile(*source)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	pkg := filepath.Base(filepath.Dir(*out))
+	code, err := Generate(*source, src, *importPath, pkg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(filepath.Dir(*out), 0o755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*out, code, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Generate returns the source of package pkg with mocks of the interfaces declared
+// in src, the file filename of the package importPath.
+func Generate(filename string, src []byte, importPath, pkg string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+
+	g := &generator{
+		srcPkg:  file.Name.Name,
+		imports: map[string]string{file.Name.Name: importPath},
+		used:    map[string]bool{"sync": true},
+	}
+	for _, imp := range file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		name := filepath.Base(path)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		g.imports[name] = path
+	}
+	g.imports["sync"] = "sync"
+
+	var body bytes.Buffer
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if iface, ok := ts.Type.(*ast.InterfaceType); ok && ts.Name.IsExported() {
+				if err := g.mock(&body, ts.Name.Name, iface); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by mockgen from %s. DO NOT EDIT.\n\n", filepath.Base(filename))
+	fmt.Fprintf(&b, "// Package %s provides mocks of the interfaces of package %s.\n", pkg, g.srcPkg)
+	fmt.Fprintf(&b, "package %s\n\nimport (\n", pkg)
+	// Group the imports like goimports: standard library, this module, others.
+	module, _, _ := strings.Cut(importPath, "/")
+	groups := make([][]string, 3)
+	for name := range g.used {
+		path := g.imports[name]
+		first, _, _ := strings.Cut(path, "/")
+		switch {
+		case first == module:
+			groups[1] = append(groups[1], name)
+		case strings.Contains(first, "."):
+			groups[2] = append(groups[2], name)
+		default:
+			groups[0] = append(groups[0], name)
+		}
+	}
+	sep := ""
+	for _, names := range groups {
+		if len(names) == 0 {
+			continue
+		}
+		b.WriteString(sep)
+		sep = "\n"
+		sort.Slice(names, func(i, j int) bool { return g.imports[names[i]] < g.imports[names[j]] })
+		for _, name := range names {
+			path := g.imports[name]
+			if filepath.Base(path) == name {
+				fmt.Fprintf(&b, "\t%q\n", path)
+			} else {
+				fmt.Fprintf(&b, "\t%s %q\n", name, path)
+			}
+		}
+	}
+	b.WriteString(")\n\n")
+	b.WriteString(callType)
+	b.Write(body.Bytes())
+
+	code, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format mocks: %w", err)
+	}
+	return code, nil
+}
+
+const callType = `// Call is a recorded call of a mock method.
+type Call struct {
+	Method string
+	Args   []any
+}
+
+`
+
+type generator struct {
+	srcPkg  string
+	imports map[string]string
+	used    map[string]bool
+}
+
+// mock writes the mock of an interface.
+func (g *generator) mock(b *bytes.Buffer, name string, iface *ast.InterfaceType) error {
+	type method struct {
+		name    string
+		params  []string
+		types   []string
+		results []string
+		varargs bool
+	}
+
+	var methods []method
+	for _, field := range iface.Methods.List {
+		fn, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) == 0 {
+			return fmt.Errorf("%s: embedded interfaces are not supported", name)
+		}
+		m := method{name: field.Names[0].Name}
+		if fn.Params != nil {
+			for _, p := range fn.Params.List {
+				typ := g.expr(p.Type)
+				n := max(len(p.Names), 1)
+				for range n {
+					m.params = append(m.params, fmt.Sprintf("p%d", len(m.params)))
+					m.types = append(m.types, typ)
+				}
+				if _, ok := p.Type.(*ast.Ellipsis); ok {
+					m.varargs = true
+				}
+			}
+		}
+		if fn.Results != nil {
+			for _, r := range fn.Results.List {
+				for range max(len(r.Names), 1) {
+					m.results = append(m.results, g.expr(r.Type))
+				}
+			}
+		}
+		methods = append(methods, m)
+	}
+
+	fmt.Fprintf(b, "// %s is a mock of %s.%s.\n", name, g.srcPkg, name)
+	fmt.Fprintf(b, "type %s struct {\n", name)
+	for _, m := range methods {
+		fmt.Fprintf(b, "\t%sFunc func(%s) %s\n", m.name, strings.Join(m.types, ", "), resultList(m.results))
+	}
+	b.WriteString("\n\tmu    sync.Mutex\n\tcalls []Call\n}\n\n")
+
+	for _, m := range methods {
+		sig := make([]string, len(m.params))
+		for i := range m.params {
+			sig[i] = m.params[i] + " " + m.types[i]
+		}
+		args := strings.Join(m.params, ", ")
+		if m.varargs {
+			args += "..."
+		}
+
+		fmt.Fprintf(b, "// %s records the call and calls %sFunc if it is set.\n", m.name, m.name)
+		fmt.Fprintf(b, "func (m *%s) %s(%s) %s {\n", name, m.name, strings.Join(sig, ", "), resultList(m.results))
+		fmt.Fprintf(b, "\tm.mu.Lock()\n\tm.calls = append(m.calls, Call{Method: %q, Args: []any{%s}})\n\tm.mu.Unlock()\n\n",
+			m.name, strings.Join(m.params, ", "))
+		fmt.Fprintf(b, "\tif m.%sFunc != nil {\n", m.name)
+		if len(m.results) > 0 {
+			fmt.Fprintf(b, "\t\treturn m.%sFunc(%s)\n\t}\n", m.name, args)
+			zero := make([]string, len(m.results))
+			for i, r := range m.results {
+				fmt.Fprintf(b, "\tvar r%d %s\n", i, r)
+				zero[i] = fmt.Sprintf("r%d", i)
+			}
+			fmt.Fprintf(b, "\treturn %s\n}\n\n", strings.Join(zero, ", "))
+		} else {
+			fmt.Fprintf(b, "\t\tm.%sFunc(%s)\n\t}\n}\n\n", m.name, args)
+		}
+	}
+
+	fmt.Fprintf(b, "// Calls returns the recorded calls in order.\n")
+	fmt.Fprintf(b, "func (m *%s) Calls() []Call {\n\tm.mu.Lock()\n\tdefer m.mu.Unlock()\n\treturn append([]Call(nil), m.calls...)\n}\n\n", name)
+	return nil
+}
+
+// expr renders a type expression, qualifying the exported names of the source
+// package and marking the imports used.
+func (g *generator) expr(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.Ident:
+		if t.IsExported() {
+			g.used[g.srcPkg] = true
+			return g.srcPkg + "." + t.Name
+		}
+		return t.Name
+	case *ast.SelectorExpr:
+		if x, ok := t.X.(*ast.Ident); ok {
+			g.used[x.Name] = true
+		}
+		return g.expr(t.X) + "." + t.Sel.Name
+	case *ast.StarExpr:
+		return "*" + g.expr(t.X)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "[]" + g.expr(t.Elt)
+		}
+		return "[" + t.Len.(*ast.BasicLit).Value + "]" + g.expr(t.Elt)
+	case *ast.MapType:
+		return "map[" + g.expr(t.Key) + "]" + g.expr(t.Value)
+	case *ast.Ellipsis:
+		return "..." + g.expr(t.Elt)
+	case *ast.ChanType:
+		switch t.Dir {
+		case ast.SEND:
+			return "chan<- " + g.expr(t.Value)
+		case ast.RECV:
+			return "<-chan " + g.expr(t.Value)
+		}
+		return "chan " + g.expr(t.Value)
+	case *ast.InterfaceType:
+		return "interface{}"
+	case *ast.FuncType:
+		var params, results []string
+		if t.Params != nil {
+			for _, p := range t.Params.List {
+				for range max(len(p.Names), 1) {
+					params = append(params, g.expr(p.Type))
+				}
+			}
+		}
+		if t.Results != nil {
+			for _, r := range t.Results.List {
+				for range max(len(r.Names), 1) {
+					results = append(results, g.expr(r.Type))
+				}
+			}
+		}
+		return "func(" + strings.Join(params, ", ") + ") " + resultList(results)
+	}
+	panic(fmt.Sprintf("mockgen: unsupported type expression %T", e))
+}
+
+// resultList renders the results of a signature.
+func resultList(results []string) string {
+	switch len(results) {
+	case 0:
+		return ""
+	case 1:
+		return results[0]
+	}
+	return "(" + strings.Join(results, ", ") + ")"
+}
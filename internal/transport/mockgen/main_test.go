@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Run("transport mocks are up to date", func(t *testing.T) {
+		src, err := os.ReadFile("../transport.go")
+		require.NoError(t, err)
+		want, err := Generate("transport.go", src, "gossher/internal/transport", "transportmock")
+		require.NoError(t, err)
+
+		got, err := os.ReadFile("../transportmock/mocks.go")
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(got), `run "go generate ./internal/transport"`)
+	})
+
+	t.Run("signatures", func(t *testing.T) {
+		src := []byte(`package store
+
+import "io"
+
+type Item struct{}
+
+type Store interface {
+	Get(key string) (*Item, bool)
+	Put(keys []string, items map[string]Item, w io.Writer)
+	Log(format string, args ...any)
+	Watch() <-chan Item
+}
+
+type notMocked interface{ Get() }
+`)
+		code, err := Generate("store.go", src, "example.com/store", "storemock")
+		require.NoError(t, err)
+		out := string(code)
+
+		assert.Contains(t, out, "package storemock")
+		assert.Contains(t, out, "\t\"io\"\n\t\"sync\"\n\n\t\"example.com/store\"\n")
+		assert.Contains(t, out, "func (m *Store) Get(p0 string) (*store.Item, bool) {")
+		assert.Contains(t, out, "func (m *Store) Put(p0 []string, p1 map[string]store.Item, p2 io.Writer) {")
+		assert.Contains(t, out, "\t\tm.LogFunc(p0, p1...)\n")
+		assert.Contains(t, out, "WatchFunc func() <-chan store.Item")
+		assert.NotContains(t, out, "notMocked")
+	})
+
+	t.Run("embedded interfaces", func(t *testing.T) {
+		src := []byte("package p\n\nimport \"io\"\n\ntype RW interface {\n\tio.Reader\n}\n")
+		_, err := Generate("p.go", src, "example.com/p", "pmock")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,168 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+
+	"gossher/internal/inventory"
+)
+
+const (
+	// DefaultProbeTimeout bounds each TCP connection to a network probe.
+	DefaultProbeTimeout = 2 * time.Second
+	// DefaultUpWait is how long a network is polled after its up command ran.
+	DefaultUpWait = 15 * time.Second
+	// upPollInterval is the pause between checks while waiting for a network.
+	upPollInterval = 500 * time.Millisecond
+)
+
+// ErrNetworkUnavailable wraps the reason a host's required network is down.
+var ErrNetworkUnavailable = errors.New("required network is not available")
+
+// Ensure NetworkDialer implements the interface
+var _ Dialer = NetworkDialer{}
+
+// NetworkChecker checks the networks a connection requires (see
+// inventory.NetworkRequirement). The zero value probes with TCP connections,
+// looks up the interfaces of this machine and runs up commands with sh -c.
+type NetworkChecker struct {
+	// DialNet connects to probes (default a net.Dialer with DefaultProbeTimeout).
+	DialNet func(ctx context.Context, network, address string) (net.Conn, error)
+	// Interfaces lists the network interfaces (default net.Interfaces).
+	Interfaces func() ([]net.Interface, error)
+	// RunUp runs the up command of a network and returns its output.
+	RunUp func(ctx context.Context, command string) ([]byte, error)
+	// UpWait is how long the network is polled after the up command (default
+	// DefaultUpWait).
+	UpWait time.Duration
+}
+
+// Check checks the networks of every hop of conn reached from this machine (those
+// of the relay host for hosts behind one). A network that is down is brought up
+// with its up command, if it has one; the error of a network that stays down wraps
+// ErrNetworkUnavailable and includes the network's message.
+func (c NetworkChecker) Check(ctx context.Context, conn *inventory.ResolvedConnection) error {
+	checked := map[string]bool{}
+	for _, hop := range localHops(conn) {
+		for _, n := range hop.Networks {
+			if checked[n.Name] {
+				continue
+			}
+			checked[n.Name] = true
+			if err := c.checkNetwork(ctx, n); err != nil {
+				return fmt.Errorf("%w: host %s requires network %s: %w", ErrNetworkUnavailable, hop.HostID, n.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkNetwork checks one network, running its up command when it is down.
+func (c NetworkChecker) checkNetwork(ctx context.Context, n inventory.RequiredNetwork) error {
+	if n.Undefined {
+		return fmt.Errorf("network is not defined in the config")
+	}
+
+	err := c.probe(ctx, n.NetworkRequirement)
+	if err != nil && n.Up != "" {
+		err = c.up(ctx, n.NetworkRequirement, err)
+	}
+	if err != nil && n.Message != "" {
+		return fmt.Errorf("%s (%w)", n.Message, err)
+	}
+	return err
+}
+
+// up runs the up command and polls the network until it is up or UpWait passed.
+func (c NetworkChecker) up(ctx context.Context, n inventory.NetworkRequirement, probeErr error) error {
+	run := c.RunUp
+	if run == nil {
+		run = func(ctx context.Context, command string) ([]byte, error) {
+			return exec.CommandContext(ctx, "sh", "-c", command).CombinedOutput()
+		}
+	}
+	if out, err := run(ctx, n.Up); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return fmt.Errorf("%w; up command failed: %w", probeErr, err)
+	}
+
+	wait := c.UpWait
+	if wait <= 0 {
+		wait = DefaultUpWait
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		err := c.probe(ctx, n)
+		if err == nil || !time.Now().Before(deadline) {
+			return err
+		}
+		t := time.NewTimer(min(upPollInterval, wait))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// probe checks the interface and the probe address of a network.
+func (c NetworkChecker) probe(ctx context.Context, n inventory.NetworkRequirement) error {
+	if n.Interface != "" {
+		list := c.Interfaces
+		if list == nil {
+			list = net.Interfaces
+		}
+		ifaces, err := list()
+		if err != nil {
+			return fmt.Errorf("failed to list network interfaces: %w", err)
+		}
+		found := false
+		for _, iface := range ifaces {
+			if iface.Name == n.Interface {
+				if iface.Flags&net.FlagUp == 0 {
+					return fmt.Errorf("interface %s is down", n.Interface)
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("interface %s not found", n.Interface)
+		}
+	}
+
+	if n.Probe != "" {
+		dial := c.DialNet
+		if dial == nil {
+			dial = (&net.Dialer{Timeout: DefaultProbeTimeout}).DialContext
+		}
+		conn, err := dial(ctx, "tcp", n.Probe)
+		if err != nil {
+			return fmt.Errorf("%s is unreachable", n.Probe)
+		}
+		conn.Close()
+	}
+	return nil
+}
+
+// NetworkDialer checks the required networks of a connection before dialing it.
+type NetworkDialer struct {
+	Dialer  Dialer
+	Checker NetworkChecker
+}
+
+// Dial checks the networks and then dials conn with the wrapped Dialer.
+func (d NetworkDialer) Dial(ctx context.Context, conn *inventory.ResolvedConnection) (Session, error) {
+	if err := d.Checker.Check(ctx, conn); err != nil {
+		return nil, err
+	}
+	return d.Dialer.Dial(ctx, conn)
+}
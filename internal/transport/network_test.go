@@ -0,0 +1,122 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkChecker(t *testing.T) {
+	corp := inventory.RequiredNetwork{
+		Name: "corp",
+		NetworkRequirement: inventory.NetworkRequirement{
+			Probe:   "10.1.0.1:443",
+			Message: "connect to corp VPN first",
+		},
+	}
+	conn := func(networks ...inventory.RequiredNetwork) *inventory.ResolvedConnection {
+		return &inventory.ResolvedConnection{HostID: "web01", Address: "10.1.2.3", Port: 22, Networks: networks}
+	}
+	down := func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, errors.New("no route to host")
+	}
+
+	t.Run("reports the message of a network that is down", func(t *testing.T) {
+		err := NetworkChecker{DialNet: down}.Check(context.Background(), conn(corp))
+		assert.ErrorIs(t, err, ErrNetworkUnavailable)
+		assert.ErrorContains(t, err, "host web01 requires network corp: connect to corp VPN first (10.1.0.1:443 is unreachable)")
+	})
+
+	t.Run("passes when the probe answers", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer ln.Close()
+
+		local := corp
+		local.Probe = ln.Addr().String()
+		assert.NoError(t, NetworkChecker{}.Check(context.Background(), conn(local)))
+	})
+
+	t.Run("runs the up command and checks again", func(t *testing.T) {
+		up := false
+		c := NetworkChecker{
+			DialNet: func(ctx context.Context, network, address string) (net.Conn, error) {
+				if !up {
+					return nil, errors.New("no route to host")
+				}
+				client, server := net.Pipe()
+				server.Close()
+				return client, nil
+			},
+			RunUp: func(ctx context.Context, command string) ([]byte, error) {
+				assert.Equal(t, "wg-quick up corp", command)
+				up = true
+				return nil, nil
+			},
+		}
+		withUp := corp
+		withUp.Up = "wg-quick up corp"
+		assert.NoError(t, c.Check(context.Background(), conn(withUp)))
+	})
+
+	t.Run("gives up when the network stays down", func(t *testing.T) {
+		c := NetworkChecker{
+			DialNet: down,
+			RunUp:   func(ctx context.Context, command string) ([]byte, error) { return nil, nil },
+			UpWait:  time.Millisecond,
+		}
+		withUp := corp
+		withUp.Up = "true"
+		err := c.Check(context.Background(), conn(withUp))
+		assert.ErrorIs(t, err, ErrNetworkUnavailable)
+	})
+
+	t.Run("reports a failing up command", func(t *testing.T) {
+		c := NetworkChecker{
+			DialNet: down,
+			RunUp: func(ctx context.Context, command string) ([]byte, error) {
+				return []byte("permission denied\n"), errors.New("exit status 1")
+			},
+		}
+		withUp := corp
+		withUp.Up = "vpn connect"
+		err := c.Check(context.Background(), conn(withUp))
+		assert.ErrorContains(t, err, "up command failed: exit status 1: permission denied")
+	})
+
+	t.Run("checks interfaces", func(t *testing.T) {
+		c := NetworkChecker{Interfaces: func() ([]net.Interface, error) {
+			return []net.Interface{{Name: "eth0", Flags: net.FlagUp}, {Name: "tun0"}}, nil
+		}}
+		iface := func(name string) inventory.RequiredNetwork {
+			return inventory.RequiredNetwork{Name: "vpn", NetworkRequirement: inventory.NetworkRequirement{Interface: name}}
+		}
+		assert.NoError(t, c.Check(context.Background(), conn(iface("eth0"))))
+		assert.ErrorContains(t, c.Check(context.Background(), conn(iface("tun0"))), "interface tun0 is down")
+		assert.ErrorContains(t, c.Check(context.Background(), conn(iface("wg0"))), "interface wg0 not found")
+	})
+
+	t.Run("rejects undefined networks", func(t *testing.T) {
+		err := NetworkChecker{}.Check(context.Background(), conn(inventory.RequiredNetwork{Name: "lab", Undefined: true}))
+		assert.ErrorContains(t, err, "network lab: network is not defined")
+	})
+
+	t.Run("dialer stops before dialing", func(t *testing.T) {
+		d := NetworkDialer{
+			Dialer: DialerFunc(func(ctx context.Context, conn *inventory.ResolvedConnection) (Session, error) {
+				t.Fatal("dialed although the network is down")
+				return nil, nil
+			}),
+			Checker: NetworkChecker{DialNet: down},
+		}
+		_, err := d.Dial(context.Background(), conn(corp))
+		assert.ErrorIs(t, err, ErrNetworkUnavailable)
+	})
+}
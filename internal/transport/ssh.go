@@ -0,0 +1,352 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gossher/internal/inventory"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Ensure the types implement the interfaces
+var (
+	_ Dialer                  = SSHDialer{}
+	_ inventory.Authenticator = SSHDialer{}
+	_ Session                 = (*sshSession)(nil)
+	_ Transfer                = (*sftpTransfer)(nil)
+)
+
+var (
+	// ErrAuthFailed is returned when a server rejects every authentication method.
+	ErrAuthFailed = errors.New("permission denied")
+	// ErrHostKeyMismatch is returned when a server presents a host key that is not
+	// pinned for its host.
+	ErrHostKeyMismatch = errors.New("host key is not pinned")
+)
+
+// SSHDialer connects to hosts with a native SSH client. Jump hosts are traversed
+// with direct-tcpip channels of the hop before, users authenticate with their key
+// (PrivateKey or KeyPath, decrypted with Passphrase) before their password like
+// ssh, commands run in exec sessions and files are transferred with sftp.
+//
+// A hop with pinned host keys (inventory.Host.HostKeys) must present one of them.
+// The keys of hops without pins are checked by HostKeyCallback.
+type SSHDialer struct {
+	// DialNet connects to the first hop (default a net.Dialer).
+	DialNet func(ctx context.Context, network, address string) (net.Conn, error)
+	// HostKeyCallback checks the host keys of hops without pinned keys (default
+	// the entries of inventory.DefaultKnownHostsPath).
+	HostKeyCallback ssh.HostKeyCallback
+}
+
+// Dial connects to the host of conn through its jump hosts and authenticates on
+// each of them.
+func (d SSHDialer) Dial(ctx context.Context, conn *inventory.ResolvedConnection) (Session, error) {
+	if conn.Local || conn.Relay != nil || conn.GatewayURL != "" {
+		return nil, fmt.Errorf("host %s cannot be reached with an SSH client", conn.HostID)
+	}
+	s := &sshSession{}
+	hops := append(append([]*inventory.ResolvedConnection(nil), conn.Jumps...), conn)
+	for i, hop := range hops {
+		client, err := d.dialHop(ctx, s.client(), hop)
+		if err != nil {
+			s.Close()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if i < len(hops)-1 {
+				return nil, fmt.Errorf("failed to connect to jump host %s: %w", hop.HostID, err)
+			}
+			return nil, fmt.Errorf("failed to connect to %s: %w", hop.HostID, err)
+		}
+		s.clients = append(s.clients, client)
+	}
+	return s, nil
+}
+
+// Authenticate logs in on the host of conn and disconnects.
+func (d SSHDialer) Authenticate(ctx context.Context, conn *inventory.ResolvedConnection) error {
+	s, err := d.Dial(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return s.Close()
+}
+
+// dialHop connects to one hop, through via unless it is the first, and
+// authenticates.
+func (d SSHDialer) dialHop(ctx context.Context, via *ssh.Client, hop *inventory.ResolvedConnection) (*ssh.Client, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	auth, err := authMethods(hop)
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := d.hostKeyCallback(hop)
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ClientConfig{User: hop.User, Auth: auth, HostKeyCallback: hostKeys}
+
+	addr := net.JoinHostPort(hop.Address, strconv.Itoa(sshPort(hop.Port)))
+	var c net.Conn
+	switch {
+	case via != nil:
+		c, err = via.DialContext(ctx, "tcp", addr)
+	case d.DialNet != nil:
+		c, err = d.DialNet(ctx, "tcp", addr)
+	default:
+		var nd net.Dialer
+		c, err = nd.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The handshake does not take a context: closing the connection stops it.
+	stop := context.AfterFunc(ctx, func() { c.Close() })
+	sc, chans, reqs, err := ssh.NewClientConn(c, addr, config)
+	if !stop() {
+		if err == nil {
+			sc.Close()
+		}
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		c.Close()
+		if strings.Contains(err.Error(), "unable to authenticate") {
+			return nil, fmt.Errorf("%w: %v", ErrAuthFailed, err)
+		}
+		return nil, err
+	}
+	return ssh.NewClient(sc, chans, reqs), nil
+}
+
+// hostKeyCallback checks the host key of a hop against its pinned keys, or with
+// the HostKeyCallback of d when it has none.
+func (d SSHDialer) hostKeyCallback(hop *inventory.ResolvedConnection) (ssh.HostKeyCallback, error) {
+	if len(hop.HostKeys) == 0 {
+		if d.HostKeyCallback != nil {
+			return d.HostKeyCallback, nil
+		}
+		callback, err := knownhosts.New(inventory.DefaultKnownHostsPath())
+		if err != nil {
+			return nil, fmt.Errorf("no host key is pinned and known_hosts cannot be read: %w", err)
+		}
+		return callback, nil
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		fingerprint := ssh.FingerprintSHA256(key)
+		for _, k := range hop.HostKeys {
+			if k.Fingerprint == fingerprint {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s presented %s key %s", ErrHostKeyMismatch, hop.HostID, key.Type(), fingerprint)
+	}, nil
+}
+
+// authMethods returns the key and the password of a hop, in this order.
+func authMethods(hop *inventory.ResolvedConnection) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	key, err := privateKey(hop)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) > 0 {
+		var signer ssh.Signer
+		if hop.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(hop.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key: %w", err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if hop.Password != "" {
+		methods = append(methods, ssh.Password(hop.Password))
+	}
+	return methods, nil
+}
+
+// privateKey returns the key of a connection: the PEM fetched by ResolveSecrets,
+// else the content of the key file.
+func privateKey(conn *inventory.ResolvedConnection) ([]byte, error) {
+	if conn.PrivateKey != "" {
+		return []byte(conn.PrivateKey), nil
+	}
+	if conn.KeyPath == "" {
+		return nil, nil
+	}
+	p := inventory.ExpandPath(conn.KeyPath)
+	if !filepath.IsAbs(p) && conn.DataDir != "" {
+		p = filepath.Join(conn.DataDir, p)
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %s: %w", conn.KeyPath, err)
+	}
+	return data, nil
+}
+
+// sshPort returns port, or 22 when unset like in the inventory.
+func sshPort(port int) int {
+	if port == 0 {
+		return 22
+	}
+	return port
+}
+
+// sshSession is the chain of clients to a host, jump hosts first.
+type sshSession struct {
+	clients []*ssh.Client
+}
+
+// client returns the last client of the chain, nil while it is empty.
+func (s *sshSession) client() *ssh.Client {
+	if len(s.clients) == 0 {
+		return nil
+	}
+	return s.clients[len(s.clients)-1]
+}
+
+// Run runs command in an exec session. The session is closed when ctx is done.
+func (s *sshSession) Run(ctx context.Context, command string, stdout, stderr io.Writer) (int, error) {
+	sess, err := s.client().NewSession()
+	if err != nil {
+		return -1, fmt.Errorf("failed to open session: %w", err)
+	}
+	defer sess.Close()
+	// The output streams are copied concurrently, and may share a writer.
+	var mu sync.Mutex
+	sess.Stdout, sess.Stderr = &lockedWriter{mu: &mu, w: stdout}, &lockedWriter{mu: &mu, w: stderr}
+
+	stop := context.AfterFunc(ctx, func() { sess.Close() })
+	defer stop()
+	err = sess.Run(command)
+	if ctx.Err() != nil {
+		return -1, ctx.Err()
+	}
+	var exit *ssh.ExitError
+	switch {
+	case err == nil:
+		return 0, nil
+	case errors.As(err, &exit):
+		return exit.ExitStatus(), nil
+	}
+	return -1, err
+}
+
+// lockedWriter serializes writes with the other writers of its mutex.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// Transfer opens the sftp subsystem.
+func (s *sshSession) Transfer(ctx context.Context) (Transfer, error) {
+	c, err := sftp.NewClient(s.client())
+	if err != nil {
+		return nil, err
+	}
+	return &sftpTransfer{c: c}, nil
+}
+
+// Close closes the clients, the host's first.
+func (s *sshSession) Close() error {
+	var err error
+	for i := len(s.clients) - 1; i >= 0; i-- {
+		if cerr := s.clients[i].Close(); cerr != nil && err == nil && !errors.Is(cerr, net.ErrClosed) {
+			err = cerr
+		}
+	}
+	s.clients = nil
+	return err
+}
+
+// sftpTransfer copies files over sftp. Paths starting with "~/" are relative to
+// the home directory, like relative paths.
+type sftpTransfer struct {
+	c *sftp.Client
+}
+
+func (t *sftpTransfer) Upload(ctx context.Context, src io.Reader, remotePath string, mode os.FileMode) error {
+	stop := context.AfterFunc(ctx, func() { t.c.Close() })
+	defer stop()
+
+	p := sftpPath(remotePath)
+	f, err := t.c.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", remotePath, ctxErr(ctx, err))
+	}
+	if _, err := f.ReadFrom(src); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", remotePath, ctxErr(ctx, err))
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", remotePath, ctxErr(ctx, err))
+	}
+	if err := t.c.Chmod(p, mode); err != nil {
+		return fmt.Errorf("failed to chmod %s: %w", remotePath, ctxErr(ctx, err))
+	}
+	return nil
+}
+
+func (t *sftpTransfer) Download(ctx context.Context, remotePath string, dst io.Writer) error {
+	stop := context.AfterFunc(ctx, func() { t.c.Close() })
+	defer stop()
+
+	f, err := t.c.Open(sftpPath(remotePath))
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", remotePath, ctxErr(ctx, err))
+	}
+	defer f.Close()
+	if _, err := f.WriteTo(dst); err != nil {
+		return fmt.Errorf("failed to read %s: %w", remotePath, ctxErr(ctx, err))
+	}
+	return nil
+}
+
+func (t *sftpTransfer) Close() error {
+	if err := t.c.Close(); err != nil && !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// sftpPath turns "~" and "~/..." into paths relative to the home directory.
+func sftpPath(p string) string {
+	if p == "~" {
+		return "."
+	}
+	return strings.TrimPrefix(p, "~/")
+}
+
+// ctxErr returns the error of ctx once it is done, which is why a transfer
+// failed, and err otherwise.
+func ctxErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
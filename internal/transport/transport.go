@@ -0,0 +1,228 @@
+// Package transport defines the interfaces between gossher and the connections to
+// its hosts: a Dialer opens a Session to a host, which runs commands and opens a
+// Transfer for files. Higher-level features depend on these interfaces only, so
+// alternative transports can be injected and the features can be unit-tested with
+// the mocks of package transportmock. SSHDialer is the native SSH client.
+package transport
+
+//go:generate go run ./mockgen -source transport.go -import gossher/internal/transport -out transportmock/mocks.go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gossher/internal/inventory"
+)
+
+// ErrNoTransfer is returned by sessions that cannot transfer files.
+var ErrNoTransfer = errors.New("session cannot transfer files")
+
+// Dialer opens sessions to hosts.
+type Dialer interface {
+	// Dial connects to the host of conn through its jump hosts and authenticates.
+	Dial(ctx context.Context, conn *inventory.ResolvedConnection) (Session, error)
+}
+
+// Session is an authenticated connection to a host.
+type Session interface {
+	// Run runs a command and returns its exit code. err is set when the command
+	// could not be run, not when it exits with a non-zero status.
+	Run(ctx context.Context, command string, stdout, stderr io.Writer) (exitCode int, err error)
+	// Transfer opens a file transfer channel, like the sftp subsystem.
+	Transfer(ctx context.Context) (Transfer, error)
+	Close() error
+}
+
+// Transfer copies files to and from a host.
+type Transfer interface {
+	Upload(ctx context.Context, src io.Reader, remotePath string, mode os.FileMode) error
+	Download(ctx context.Context, remotePath string, dst io.Writer) error
+	Close() error
+}
+
+// DialerFunc adapts a function to a Dialer.
+type DialerFunc func(ctx context.Context, conn *inventory.ResolvedConnection) (Session, error)
+
+// Dial calls f.
+func (f DialerFunc) Dial(ctx context.Context, conn *inventory.ResolvedConnection) (Session, error) {
+	return f(ctx, conn)
+}
+
+// LinkRecorder receives connection statistics; *inventory.Manager implements it.
+type LinkRecorder interface {
+	RecordHandshake(hostID string, d time.Duration)
+	RecordRoundTrip(hostID string, d time.Duration)
+	RecordTransfer(hostID string, n int64, d time.Duration)
+}
+
+// Ensure Manager implements the interface
+var _ LinkRecorder = (*inventory.Manager)(nil)
+
+// ===== Runner =====
+
+// Runner runs commands and transfers files with one session per call. It
+// implements the Runner, Uploader and Downloader interfaces of the executor.
+type Runner struct {
+	Dialer Dialer
+	// Stats, if set, records the handshake time of each session (the time Dialer
+	// takes, including any network checks and knocks), the round trip of each
+	// command and the throughput of each transfer that succeeded.
+	Stats LinkRecorder
+}
+
+// Run runs command in a new session to the host of conn.
+func (r Runner) Run(ctx context.Context, conn *inventory.ResolvedConnection, command string, stdout, stderr io.Writer) (exitCode int, err error) {
+	s, err := r.dial(ctx, conn)
+	if err != nil {
+		return -1, err
+	}
+	defer closeInto(s, &err)
+
+	start := time.Now()
+	exitCode, err = s.Run(ctx, command, stdout, stderr)
+	if err == nil && r.Stats != nil {
+		r.Stats.RecordRoundTrip(conn.HostID, time.Since(start))
+	}
+	return exitCode, err
+}
+
+// Upload copies src to remotePath on the host of conn.
+func (r Runner) Upload(ctx context.Context, conn *inventory.ResolvedConnection, src io.Reader, remotePath string, mode os.FileMode) error {
+	counter := &countingReader{r: src}
+	return r.transfer(ctx, conn, &counter.n, func(t Transfer) error {
+		return t.Upload(ctx, counter, remotePath, mode)
+	})
+}
+
+// Download copies remotePath on the host of conn to dst.
+func (r Runner) Download(ctx context.Context, conn *inventory.ResolvedConnection, remotePath string, dst io.Writer) error {
+	counter := &countingWriter{w: dst}
+	return r.transfer(ctx, conn, &counter.n, func(t Transfer) error {
+		return t.Download(ctx, remotePath, counter)
+	})
+}
+
+// dial opens a session and records the handshake time. Dialing, jump hosts
+// included, is limited to conn.Timeouts.Connect: the context of the dial is
+// canceled then, and a dialer that does not return is abandoned, its session
+// closed when it arrives.
+func (r Runner) dial(ctx context.Context, conn *inventory.ResolvedConnection) (Session, error) {
+	start := time.Now()
+	limit := conn.Timeouts.Connect
+	if limit <= 0 {
+		s, err := r.Dialer.Dial(ctx, conn)
+		if err == nil && r.Stats != nil {
+			r.Stats.RecordHandshake(conn.HostID, time.Since(start))
+		}
+		return s, err
+	}
+
+	// The session may keep using the context of the dial, so it is only canceled
+	// when the time runs out, not when the dial returns.
+	dialCtx, cancel := context.WithCancel(ctx)
+	timer := time.AfterFunc(limit, cancel)
+
+	type dialed struct {
+		s   Session
+		err error
+	}
+	done := make(chan dialed, 1)
+	go func() {
+		s, err := r.Dialer.Dial(dialCtx, conn)
+		done <- dialed{s, err}
+	}()
+
+	select {
+	case d := <-done:
+		if !timer.Stop() && d.err == nil {
+			// The time ran out just as the dial returned; the session is unusable.
+			d.s.Close()
+			return nil, connectTimeout(conn, limit)
+		}
+		if d.err != nil {
+			if dialCtx.Err() != nil && ctx.Err() == nil {
+				return nil, fmt.Errorf("%w: %v", connectTimeout(conn, limit), d.err)
+			}
+			return nil, d.err
+		}
+		if r.Stats != nil {
+			r.Stats.RecordHandshake(conn.HostID, time.Since(start))
+		}
+		return d.s, nil
+	case <-dialCtx.Done():
+		go func() {
+			if d := <-done; d.err == nil {
+				d.s.Close()
+			}
+		}()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, connectTimeout(conn, limit)
+	}
+}
+
+// connectTimeout is the error of a dial that did not finish in time.
+func connectTimeout(conn *inventory.ResolvedConnection, limit time.Duration) error {
+	return fmt.Errorf("failed to connect to %s: timed out after %s: %w", conn.HostID, limit, context.DeadlineExceeded)
+}
+
+// transfer opens a session and a transfer channel for fn and closes them after.
+// n counts the bytes fn transferred, for the throughput statistics.
+func (r Runner) transfer(ctx context.Context, conn *inventory.ResolvedConnection, n *int64, fn func(Transfer) error) (err error) {
+	s, err := r.dial(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer closeInto(s, &err)
+
+	t, err := s.Transfer(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open transfer to %s: %w", conn.HostID, err)
+	}
+	defer closeInto(t, &err)
+
+	start := time.Now()
+	if err := fn(t); err != nil {
+		return err
+	}
+	if r.Stats != nil {
+		r.Stats.RecordTransfer(conn.HostID, *n, time.Since(start))
+	}
+	return nil
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// closeInto closes c, reporting its error in err unless err is already set.
+func closeInto(c io.Closer, err *error) {
+	if cerr := c.Close(); cerr != nil && *err == nil {
+		*err = cerr
+	}
+}
@@ -0,0 +1,177 @@
+package transport_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/transport"
+	"gossher/internal/transport/transportmock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunner(t *testing.T) {
+	conn := &inventory.ResolvedConnection{HostID: "web01"}
+
+	t.Run("runs in a new session", func(t *testing.T) {
+		session := &transportmock.Session{
+			RunFunc: func(ctx context.Context, command string, stdout, stderr io.Writer) (int, error) {
+				io.WriteString(stdout, "ran "+command)
+				return 3, nil
+			},
+		}
+		dialer := &transportmock.Dialer{
+			DialFunc: func(context.Context, *inventory.ResolvedConnection) (transport.Session, error) {
+				return session, nil
+			},
+		}
+
+		var stdout bytes.Buffer
+		code, err := transport.Runner{Dialer: dialer}.Run(context.Background(), conn, "uptime", &stdout, io.Discard)
+		require.NoError(t, err)
+		assert.Equal(t, 3, code)
+		assert.Equal(t, "ran uptime", stdout.String())
+
+		require.Len(t, dialer.Calls(), 1)
+		assert.Same(t, conn, dialer.Calls()[0].Args[1])
+		calls := session.Calls()
+		require.Len(t, calls, 2)
+		assert.Equal(t, "Run", calls[0].Method)
+		assert.Equal(t, "Close", calls[1].Method)
+	})
+
+	t.Run("dial errors", func(t *testing.T) {
+		dialErr := errors.New("connection refused")
+		dialer := &transportmock.Dialer{
+			DialFunc: func(context.Context, *inventory.ResolvedConnection) (transport.Session, error) {
+				return nil, dialErr
+			},
+		}
+		code, err := transport.Runner{Dialer: dialer}.Run(context.Background(), conn, "uptime", io.Discard, io.Discard)
+		assert.ErrorIs(t, err, dialErr)
+		assert.Equal(t, -1, code)
+	})
+
+	t.Run("connect timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		hanging := &transportmock.Session{}
+		dialer := transport.DialerFunc(func(context.Context, *inventory.ResolvedConnection) (transport.Session, error) {
+			<-release // ignores the context, like a stuck handshake
+			return hanging, nil
+		})
+		slow := &inventory.ResolvedConnection{HostID: "web01", Timeouts: inventory.Timeouts{Connect: 20 * time.Millisecond}}
+
+		start := time.Now()
+		code, err := transport.Runner{Dialer: dialer}.Run(context.Background(), slow, "uptime", io.Discard, io.Discard)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Contains(t, err.Error(), "failed to connect to web01: timed out after 20ms")
+		assert.Equal(t, -1, code)
+		assert.Less(t, time.Since(start), time.Second)
+
+		var dialCtx context.Context
+		fast := transport.DialerFunc(func(ctx context.Context, _ *inventory.ResolvedConnection) (transport.Session, error) {
+			dialCtx = ctx
+			return &transportmock.Session{}, nil
+		})
+		_, err = transport.Runner{Dialer: fast}.Run(context.Background(), slow, "uptime", io.Discard, io.Discard)
+		require.NoError(t, err)
+		time.Sleep(40 * time.Millisecond)
+		assert.NoError(t, dialCtx.Err(), "the limit ends with the dial")
+	})
+
+	t.Run("close errors", func(t *testing.T) {
+		closeErr := errors.New("broken pipe")
+		session := &transportmock.Session{CloseFunc: func() error { return closeErr }}
+		dialer := &transportmock.Dialer{
+			DialFunc: func(context.Context, *inventory.ResolvedConnection) (transport.Session, error) {
+				return session, nil
+			},
+		}
+		_, err := transport.Runner{Dialer: dialer}.Run(context.Background(), conn, "true", io.Discard, io.Discard)
+		assert.ErrorIs(t, err, closeErr)
+	})
+
+	t.Run("transfers", func(t *testing.T) {
+		files := map[string]string{}
+		xfer := &transportmock.Transfer{
+			UploadFunc: func(ctx context.Context, src io.Reader, remotePath string, mode os.FileMode) error {
+				data, err := io.ReadAll(src)
+				files[remotePath] = string(data)
+				return err
+			},
+			DownloadFunc: func(ctx context.Context, remotePath string, dst io.Writer) error {
+				_, err := io.WriteString(dst, files[remotePath])
+				return err
+			},
+		}
+		session := &transportmock.Session{
+			TransferFunc: func(context.Context) (transport.Transfer, error) { return xfer, nil },
+		}
+		r := transport.Runner{Dialer: transport.DialerFunc(func(context.Context, *inventory.ResolvedConnection) (transport.Session, error) {
+			return session, nil
+		})}
+
+		require.NoError(t, r.Upload(context.Background(), conn, strings.NewReader("payload"), "/srv/app.txt", 0o644))
+		var out bytes.Buffer
+		require.NoError(t, r.Download(context.Background(), conn, "/srv/app.txt", &out))
+		assert.Equal(t, "payload", out.String())
+
+		methods := []string{}
+		for _, c := range xfer.Calls() {
+			methods = append(methods, c.Method)
+		}
+		assert.Equal(t, []string{"Upload", "Close", "Download", "Close"}, methods)
+	})
+
+	t.Run("sessions without transfers", func(t *testing.T) {
+		session := &transportmock.Session{
+			TransferFunc: func(context.Context) (transport.Transfer, error) { return nil, transport.ErrNoTransfer },
+		}
+		r := transport.Runner{Dialer: transport.DialerFunc(func(context.Context, *inventory.ResolvedConnection) (transport.Session, error) {
+			return session, nil
+		})}
+		err := r.Upload(context.Background(), conn, strings.NewReader("x"), "/tmp/x", 0o644)
+		assert.ErrorIs(t, err, transport.ErrNoTransfer)
+		assert.Equal(t, "Close", session.Calls()[1].Method)
+	})
+
+	t.Run("records link statistics", func(t *testing.T) {
+		xfer := &transportmock.Transfer{
+			UploadFunc: func(ctx context.Context, src io.Reader, remotePath string, mode os.FileMode) error {
+				_, err := io.Copy(io.Discard, src)
+				return err
+			},
+		}
+		session := &transportmock.Session{
+			TransferFunc: func(context.Context) (transport.Transfer, error) { return xfer, nil },
+		}
+		stats := &transportmock.LinkRecorder{}
+		r := transport.Runner{
+			Dialer: transport.DialerFunc(func(context.Context, *inventory.ResolvedConnection) (transport.Session, error) {
+				return session, nil
+			}),
+			Stats: stats,
+		}
+
+		_, err := r.Run(context.Background(), conn, "uptime", io.Discard, io.Discard)
+		require.NoError(t, err)
+		require.NoError(t, r.Upload(context.Background(), conn, strings.NewReader("payload"), "/srv/app.txt", 0o644))
+
+		methods := []string{}
+		for _, c := range stats.Calls() {
+			methods = append(methods, c.Method)
+			assert.Equal(t, "web01", c.Args[0])
+		}
+		assert.Equal(t, []string{"RecordHandshake", "RecordRoundTrip", "RecordHandshake", "RecordTransfer"}, methods)
+		assert.Equal(t, int64(7), stats.Calls()[3].Args[1])
+	})
+}
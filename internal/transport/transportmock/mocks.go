@@ -0,0 +1,214 @@
+// Code generated by mockgen from transport.go. DO NOT EDIT.
+
+// Package transportmock provides mocks of the interfaces of package transport.
+package transportmock
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"gossher/internal/inventory"
+	"gossher/internal/transport"
+)
+
+// Call is a recorded call of a mock method.
+type Call struct {
+	Method string
+	Args   []any
+}
+
+// Dialer is a mock of transport.Dialer.
+type Dialer struct {
+	DialFunc func(context.Context, *inventory.ResolvedConnection) (transport.Session, error)
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Dial records the call and calls DialFunc if it is set.
+func (m *Dialer) Dial(p0 context.Context, p1 *inventory.ResolvedConnection) (transport.Session, error) {
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{Method: "Dial", Args: []any{p0, p1}})
+	m.mu.Unlock()
+
+	if m.DialFunc != nil {
+		return m.DialFunc(p0, p1)
+	}
+	var r0 transport.Session
+	var r1 error
+	return r0, r1
+}
+
+// Calls returns the recorded calls in order.
+func (m *Dialer) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// Session is a mock of transport.Session.
+type Session struct {
+	RunFunc      func(context.Context, string, io.Writer, io.Writer) (int, error)
+	TransferFunc func(context.Context) (transport.Transfer, error)
+	CloseFunc    func() error
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Run records the call and calls RunFunc if it is set.
+func (m *Session) Run(p0 context.Context, p1 string, p2 io.Writer, p3 io.Writer) (int, error) {
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{Method: "Run", Args: []any{p0, p1, p2, p3}})
+	m.mu.Unlock()
+
+	if m.RunFunc != nil {
+		return m.RunFunc(p0, p1, p2, p3)
+	}
+	var r0 int
+	var r1 error
+	return r0, r1
+}
+
+// Transfer records the call and calls TransferFunc if it is set.
+func (m *Session) Transfer(p0 context.Context) (transport.Transfer, error) {
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{Method: "Transfer", Args: []any{p0}})
+	m.mu.Unlock()
+
+	if m.TransferFunc != nil {
+		return m.TransferFunc(p0)
+	}
+	var r0 transport.Transfer
+	var r1 error
+	return r0, r1
+}
+
+// Close records the call and calls CloseFunc if it is set.
+func (m *Session) Close() error {
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{Method: "Close", Args: []any{}})
+	m.mu.Unlock()
+
+	if m.CloseFunc != nil {
+		return m.CloseFunc()
+	}
+	var r0 error
+	return r0
+}
+
+// Calls returns the recorded calls in order.
+func (m *Session) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// Transfer is a mock of transport.Transfer.
+type Transfer struct {
+	UploadFunc   func(context.Context, io.Reader, string, os.FileMode) error
+	DownloadFunc func(context.Context, string, io.Writer) error
+	CloseFunc    func() error
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Upload records the call and calls UploadFunc if it is set.
+func (m *Transfer) Upload(p0 context.Context, p1 io.Reader, p2 string, p3 os.FileMode) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{Method: "Upload", Args: []any{p0, p1, p2, p3}})
+	m.mu.Unlock()
+
+	if m.UploadFunc != nil {
+		return m.UploadFunc(p0, p1, p2, p3)
+	}
+	var r0 error
+	return r0
+}
+
+// Download records the call and calls DownloadFunc if it is set.
+func (m *Transfer) Download(p0 context.Context, p1 string, p2 io.Writer) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{Method: "Download", Args: []any{p0, p1, p2}})
+	m.mu.Unlock()
+
+	if m.DownloadFunc != nil {
+		return m.DownloadFunc(p0, p1, p2)
+	}
+	var r0 error
+	return r0
+}
+
+// Close records the call and calls CloseFunc if it is set.
+func (m *Transfer) Close() error {
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{Method: "Close", Args: []any{}})
+	m.mu.Unlock()
+
+	if m.CloseFunc != nil {
+		return m.CloseFunc()
+	}
+	var r0 error
+	return r0
+}
+
+// Calls returns the recorded calls in order.
+func (m *Transfer) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// LinkRecorder is a mock of transport.LinkRecorder.
+type LinkRecorder struct {
+	RecordHandshakeFunc func(string, time.Duration)
+	RecordRoundTripFunc func(string, time.Duration)
+	RecordTransferFunc  func(string, int64, time.Duration)
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// RecordHandshake records the call and calls RecordHandshakeFunc if it is set.
+func (m *LinkRecorder) RecordHandshake(p0 string, p1 time.Duration) {
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{Method: "RecordHandshake", Args: []any{p0, p1}})
+	m.mu.Unlock()
+
+	if m.RecordHandshakeFunc != nil {
+		m.RecordHandshakeFunc(p0, p1)
+	}
+}
+
+// RecordRoundTrip records the call and calls RecordRoundTripFunc if it is set.
+func (m *LinkRecorder) RecordRoundTrip(p0 string, p1 time.Duration) {
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{Method: "RecordRoundTrip", Args: []any{p0, p1}})
+	m.mu.Unlock()
+
+	if m.RecordRoundTripFunc != nil {
+		m.RecordRoundTripFunc(p0, p1)
+	}
+}
+
+// RecordTransfer records the call and calls RecordTransferFunc if it is set.
+func (m *LinkRecorder) RecordTransfer(p0 string, p1 int64, p2 time.Duration) {
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{Method: "RecordTransfer", Args: []any{p0, p1, p2}})
+	m.mu.Unlock()
+
+	if m.RecordTransferFunc != nil {
+		m.RecordTransferFunc(p0, p1, p2)
+	}
+}
+
+// Calls returns the recorded calls in order.
+func (m *LinkRecorder) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
@@ -0,0 +1,372 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gossher/internal/inventory"
+)
+
+// webSocketGUID is appended to the handshake key to compute the accept key (RFC 6455).
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// maxControlPayload is the largest payload of close, ping and pong frames.
+const maxControlPayload = 125
+
+// DialFirstHop opens the network connection an SSH client starts with for conn: to
+// the first hop reached from this machine (its outermost jump host, the host
+// itself or the first hop of its relay host), through the hop's WebSocket gateway
+// if it has one. dial opens TCP connections (default a net.Dialer).
+func DialFirstHop(ctx context.Context, conn *inventory.ResolvedConnection, dial func(ctx context.Context, network, address string) (net.Conn, error)) (net.Conn, error) {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	hop := localHops(conn)[0]
+	if target := hop.GatewayTarget(); target != "" {
+		c, err := WebSocketDialer{DialNet: dial}.DialContext(ctx, target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s through its gateway: %w", hop.HostID, err)
+		}
+		return c, nil
+	}
+	return dial(ctx, "tcp", hop.HostPort())
+}
+
+// WebSocketDialer opens byte streams through WebSocket gateways such as websockify
+// or wstunnel, which forward the binary messages of a connection to a TCP port.
+// This lets SSH reach hosts from networks that only allow HTTPS egress.
+type WebSocketDialer struct {
+	// DialNet opens the TCP connection to the gateway (default a net.Dialer).
+	DialNet func(ctx context.Context, network, address string) (net.Conn, error)
+	// TLSConfig is used for wss and https gateways; ServerName defaults to the host
+	// of the URL.
+	TLSConfig *tls.Config
+	// Header is added to the handshake request, e.g. for an access token.
+	Header http.Header
+}
+
+// DialContext connects to the gateway at rawURL and performs the WebSocket
+// handshake. User info in the URL is sent as basic authentication. ctx bounds the
+// handshake; the returned connection outlives it.
+func (d WebSocketDialer) DialContext(ctx context.Context, rawURL string) (net.Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gateway URL: %w", err)
+	}
+	var secure bool
+	port := "80"
+	switch u.Scheme {
+	case "ws", "http":
+	case "wss", "https":
+		secure, port = true, "443"
+	default:
+		return nil, fmt.Errorf("invalid gateway URL scheme: %s", u.Scheme)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	address := net.JoinHostPort(u.Hostname(), port)
+
+	dial := d.DialNet
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	c, err := dial(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	// Abort the handshake when ctx is done.
+	stop := context.AfterFunc(ctx, func() { c.SetDeadline(time.Unix(1, 0)) })
+	ws, err := d.handshake(ctx, c, u, secure)
+	if !stop() && err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("websocket handshake with %s failed: %w", address, err)
+	}
+	c.SetDeadline(time.Time{})
+	return ws, nil
+}
+
+// handshake upgrades c, wrapping it in TLS first for secure gateways.
+func (d WebSocketDialer) handshake(ctx context.Context, c net.Conn, u *url.URL, secure bool) (*webSocketConn, error) {
+	if secure {
+		cfg := &tls.Config{}
+		if d.TLSConfig != nil {
+			cfg = d.TLSConfig.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+		tc := tls.Client(c, cfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		c = tc
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	reqURL := *u
+	reqURL.User = nil
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &reqURL,
+		Host:       u.Host,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     d.Header.Clone(),
+	}
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		req.SetBasicAuth(u.User.Username(), password)
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(c); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(c)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("gateway answered %s", resp.Status)
+	}
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") || resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, errors.New("gateway sent an invalid upgrade response")
+	}
+	return &webSocketConn{Conn: c, br: br}, nil
+}
+
+// acceptKey returns the Sec-WebSocket-Accept value for a handshake key.
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// ===== Connection =====
+
+// webSocketConn is a net.Conn carrying its stream in binary WebSocket messages.
+// Message boundaries are not preserved; pings are answered while reading.
+type webSocketConn struct {
+	net.Conn
+	br *bufio.Reader
+
+	// Read state: the rest of the current data frame.
+	remaining int64
+	header    frameHeader
+	pos       int
+	readErr   error
+
+	wmu       sync.Mutex
+	closeOnce sync.Once
+}
+
+func (c *webSocketConn) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if c.readErr != nil {
+			return 0, c.readErr
+		}
+		c.readErr = c.nextFrame()
+	}
+
+	n, err := c.br.Read(p[:min(int64(len(p)), c.remaining)])
+	c.pos = maskBytes(c.header, c.pos, p[:n])
+	c.remaining -= int64(n)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// nextFrame reads frame headers until a data frame with payload, handling the
+// control frames in between.
+func (c *webSocketConn) nextFrame() error {
+	h, err := readFrameHeader(c.br)
+	if err != nil {
+		return err
+	}
+	switch h.opcode {
+	case opBinary, opText, opContinuation:
+		c.header, c.pos, c.remaining = h, 0, h.length
+		return nil
+	case opClose, opPing, opPong:
+	default:
+		return fmt.Errorf("websocket: unknown opcode %#x", h.opcode)
+	}
+
+	if h.length > maxControlPayload {
+		return errors.New("websocket: control frame too large")
+	}
+	payload := make([]byte, h.length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return err
+	}
+	maskBytes(h, 0, payload)
+
+	switch h.opcode {
+	case opPing:
+		return c.writeFrame(opPong, payload)
+	case opClose:
+		c.closeOnce.Do(func() {
+			// Echo the status code, as RFC 6455 asks.
+			_ = c.writeFrame(opClose, payload[:min(len(payload), 2)])
+		})
+		return io.EOF
+	}
+	return nil
+}
+
+func (c *webSocketConn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(opBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeFrame sends a masked frame, as clients must.
+func (c *webSocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return writeFrame(c.Conn, opcode, payload, true)
+}
+
+// Close sends a normal closure and closes the connection without waiting for the
+// gateway's answer.
+func (c *webSocketConn) Close() error {
+	c.closeOnce.Do(func() {
+		_ = c.writeFrame(opClose, []byte{0x03, 0xe8})
+	})
+	return c.Conn.Close()
+}
+
+// ===== Framing =====
+
+// frameHeader is the header of a WebSocket frame.
+type frameHeader struct {
+	fin    bool
+	opcode byte
+	length int64
+	masked bool
+	mask   [4]byte
+}
+
+// readFrameHeader reads the header of the next frame.
+func readFrameHeader(r io.Reader) (frameHeader, error) {
+	var h frameHeader
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:2]); err != nil {
+		return h, err
+	}
+	h.fin = b[0]&0x80 != 0
+	h.opcode = b[0] & 0x0f
+	h.masked = b[1]&0x80 != 0
+	h.length = int64(b[1] & 0x7f)
+
+	switch h.length {
+	case 126:
+		if _, err := io.ReadFull(r, b[:2]); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(r, b[:8]); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint64(b[:8]) & (1<<63 - 1))
+	}
+	if h.masked {
+		if _, err := io.ReadFull(r, h.mask[:]); err != nil {
+			return h, err
+		}
+	}
+	return h, nil
+}
+
+// writeFrame writes a single final frame, masking the payload with a random key if
+// masked is set.
+func writeFrame(w io.Writer, opcode byte, payload []byte, masked bool) error {
+	buf := make([]byte, 0, 14+len(payload))
+	buf = append(buf, 0x80|opcode)
+
+	var maskBit byte
+	if masked {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		buf = append(buf, maskBit|byte(n))
+	case n <= 0xffff:
+		buf = append(buf, maskBit|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, maskBit|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+
+	h := frameHeader{masked: masked}
+	if masked {
+		if _, err := rand.Read(h.mask[:]); err != nil {
+			return err
+		}
+		buf = append(buf, h.mask[:]...)
+	}
+	start := len(buf)
+	buf = append(buf, payload...)
+	maskBytes(h, 0, buf[start:])
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// maskBytes applies the mask of h to b, which starts at payload offset pos, and
+// returns the offset after b. Unmasked frames are left as they are.
+func maskBytes(h frameHeader, pos int, b []byte) int {
+	if !h.masked {
+		return pos + len(b)
+	}
+	for i := range b {
+		b[i] ^= h.mask[(pos+i)%4]
+	}
+	return pos + len(b)
+}
@@ -0,0 +1,227 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gossher/internal/inventory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatewayHandler upgrades requests and passes the connection to serve.
+func gatewayHandler(t *testing.T, serve func(c net.Conn, br *bufio.Reader)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+			http.Error(w, "not a websocket request", http.StatusBadRequest)
+			return
+		}
+		c, brw, err := w.(http.Hijacker).Hijack()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer c.Close()
+		fmt.Fprintf(c, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+			acceptKey(r.Header.Get("Sec-WebSocket-Key")))
+		serve(c, brw.Reader)
+	}
+}
+
+// readServerFrame reads a frame sent by a client, which must be masked.
+func readServerFrame(t *testing.T, br *bufio.Reader) (byte, []byte, error) {
+	h, err := readFrameHeader(br)
+	if err != nil {
+		return 0, nil, err
+	}
+	assert.True(t, h.masked, "client frames are masked")
+	payload := make([]byte, h.length)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		return 0, nil, err
+	}
+	maskBytes(h, 0, payload)
+	return h.opcode, payload, nil
+}
+
+// echo sends back the payload of every data frame until the client closes.
+func echo(t *testing.T) func(c net.Conn, br *bufio.Reader) {
+	return func(c net.Conn, br *bufio.Reader) {
+		for {
+			op, payload, err := readServerFrame(t, br)
+			if err != nil {
+				return
+			}
+			if op == opClose {
+				_ = writeFrame(c, opClose, payload, false)
+				return
+			}
+			if err := writeFrame(c, opBinary, payload, false); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestWebSocketDialer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("carries a byte stream", func(t *testing.T) {
+		srv := httptest.NewServer(gatewayHandler(t, echo(t)))
+		defer srv.Close()
+
+		c, err := WebSocketDialer{}.DialContext(ctx, wsURL(srv)+"/ssh")
+		require.NoError(t, err)
+		defer c.Close()
+
+		for _, size := range []int{10, 200, 70_000} {
+			data := bytes.Repeat([]byte{byte(size)}, size)
+			_, err := c.Write(data)
+			require.NoError(t, err)
+			got := make([]byte, size)
+			_, err = io.ReadFull(c, got)
+			require.NoError(t, err)
+			assert.Equal(t, data, got)
+		}
+	})
+
+	t.Run("answers pings and ends on close", func(t *testing.T) {
+		pong := make(chan []byte, 1)
+		srv := httptest.NewServer(gatewayHandler(t, func(c net.Conn, br *bufio.Reader) {
+			_ = writeFrame(c, opPing, []byte("hi"), false)
+			_ = writeFrame(c, opBinary, []byte("SSH-2.0-test\r\n"), false)
+			_ = writeFrame(c, opClose, []byte{0x03, 0xe8}, false)
+			op, payload, err := readServerFrame(t, br)
+			if assert.NoError(t, err) && assert.Equal(t, byte(opPong), op) {
+				pong <- payload
+			}
+			_, _, _ = readServerFrame(t, br)
+		}))
+		defer srv.Close()
+
+		c, err := WebSocketDialer{}.DialContext(ctx, wsURL(srv))
+		require.NoError(t, err)
+		defer c.Close()
+
+		data, err := io.ReadAll(c)
+		require.NoError(t, err)
+		assert.Equal(t, "SSH-2.0-test\r\n", string(data))
+		assert.Equal(t, []byte("hi"), <-pong)
+	})
+
+	t.Run("sends credentials and headers", func(t *testing.T) {
+		var auth, token string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth = r.Header.Get("Authorization")
+			token = r.Header.Get("X-Token")
+			gatewayHandler(t, echo(t))(w, r)
+		}))
+		defer srv.Close()
+
+		d := WebSocketDialer{Header: http.Header{"X-Token": {"abc"}}}
+		c, err := d.DialContext(ctx, "ws://ops:s3cret@"+strings.TrimPrefix(srv.URL, "http://"))
+		require.NoError(t, err)
+		c.Close()
+		assert.Equal(t, "Basic b3BzOnMzY3JldA==", auth)
+		assert.Equal(t, "abc", token)
+	})
+
+	t.Run("uses TLS for wss and https", func(t *testing.T) {
+		srv := httptest.NewTLSServer(gatewayHandler(t, echo(t)))
+		defer srv.Close()
+		pool := x509.NewCertPool()
+		pool.AddCert(srv.Certificate())
+
+		c, err := WebSocketDialer{TLSConfig: &tls.Config{RootCAs: pool}}.DialContext(ctx, srv.URL)
+		require.NoError(t, err)
+		defer c.Close()
+		_, err = c.Write([]byte("ping"))
+		require.NoError(t, err)
+		got := make([]byte, 4)
+		_, err = io.ReadFull(c, got)
+		require.NoError(t, err)
+		assert.Equal(t, "ping", string(got))
+
+		_, err = WebSocketDialer{}.DialContext(ctx, "wss"+strings.TrimPrefix(srv.URL, "https"))
+		assert.ErrorContains(t, err, "certificate")
+	})
+
+	t.Run("rejects failed upgrades", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+		}))
+		defer srv.Close()
+		_, err := WebSocketDialer{}.DialContext(ctx, wsURL(srv))
+		assert.ErrorContains(t, err, "403 Forbidden")
+
+		bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c, _, _ := w.(http.Hijacker).Hijack()
+			defer c.Close()
+			fmt.Fprint(c, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: nope\r\n\r\n")
+		}))
+		defer bad.Close()
+		_, err = WebSocketDialer{}.DialContext(ctx, wsURL(bad))
+		assert.ErrorContains(t, err, "invalid upgrade response")
+
+		_, err = WebSocketDialer{}.DialContext(ctx, "ftp://example.com")
+		assert.ErrorContains(t, err, "scheme")
+	})
+}
+
+func TestDialFirstHop(t *testing.T) {
+	t.Run("dials the first hop directly", func(t *testing.T) {
+		var dialed string
+		dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed = network + " " + address
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		}
+		conn := &inventory.ResolvedConnection{
+			HostID: "db", Address: "10.0.0.5", Port: 22,
+			Jumps: []*inventory.ResolvedConnection{{HostID: "bastion", Address: "192.0.2.1", Port: 2222}},
+		}
+		c, err := DialFirstHop(context.Background(), conn, dial)
+		require.NoError(t, err)
+		c.Close()
+		assert.Equal(t, "tcp 192.0.2.1:2222", dialed)
+	})
+
+	t.Run("tunnels through the gateway of the first hop", func(t *testing.T) {
+		var target string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			target = r.URL.RequestURI()
+			gatewayHandler(t, echo(t))(w, r)
+		}))
+		defer srv.Close()
+
+		conn := &inventory.ResolvedConnection{
+			HostID: "db", Address: "10.0.0.5", Port: 22,
+			Jumps: []*inventory.ResolvedConnection{{
+				HostID: "bastion", Address: "2001:db8::1", Port: 2222,
+				GatewayURL: srv.URL + "/tunnel?host={host}&port={port}",
+			}},
+		}
+		c, err := DialFirstHop(context.Background(), conn, nil)
+		require.NoError(t, err)
+		c.Close()
+		assert.Equal(t, "/tunnel?host=2001%3Adb8%3A%3A1&port=2222", target)
+
+		srv.Close()
+		_, err = DialFirstHop(context.Background(), conn, nil)
+		assert.ErrorContains(t, err, "failed to connect to bastion through its gateway")
+	})
+}